@@ -0,0 +1,33 @@
+package auth
+
+import (
+	"crypto/hkdf"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"strings"
+)
+
+// EmailDigest derives a value from an email that only the server can compute, the same for every request.
+// The answers made up for the unknown emails are drawn from it, so asking twice does not tell them apart from
+// the stored ones of the registered accounts.
+// Parameters:
+// - purpose: what the value is used for, the digests of different purposes are unrelated
+// - email: the email, compared case-insensitively
+// Returns: the HMAC-SHA256 of the purpose and the email, or an error if the JWT secret is not valid Base64
+func EmailDigest(purpose, email string) ([]byte, error) {
+	secret, err := base64.StdEncoding.DecodeString(JWTSecret)
+	if err != nil {
+		return nil, err
+	}
+	key, err := hkdf.Key(sha256.New, secret, nil, "email-digest", 32)
+	if err != nil {
+		return nil, err
+	}
+
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(purpose))
+	mac.Write([]byte{0})
+	mac.Write([]byte(strings.ToLower(email)))
+	return mac.Sum(nil), nil
+}
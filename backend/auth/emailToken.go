@@ -0,0 +1,110 @@
+package auth
+
+import (
+	"crypto/hkdf"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"strings"
+	"time"
+)
+
+// Purposes of the email tokens, a token issued for one purpose is refused for the others
+const (
+	EmailTokenVerify    = "verify"     // Verifies the address of a new account
+	EmailTokenChangeOld = "change-old" // Confirms an email change from the current address
+	EmailTokenChangeNew = "change-new" // Confirms an email change from the new address
+)
+
+// ErrInvalidEmailToken is returned when an email token is malformed, forged or expired
+var ErrInvalidEmailToken = errors.New("invalid or expired email token")
+
+// EmailClaims is the content of an email token
+type EmailClaims struct {
+	Purpose   string `json:"purpose"`
+	UserID    uint32 `json:"user_id"`
+	Email     string `json:"email"`           // Address the token was sent to
+	Nonce     string `json:"nonce,omitempty"` // Email change the token confirms
+	ExpiresAt int64  `json:"exp"`             // Unix time
+}
+
+// emailTokenKey derives the key of the email tokens from the JWT secret, so a token cannot be passed off as a JWT
+func emailTokenKey() ([]byte, error) {
+	secret, err := base64.StdEncoding.DecodeString(JWTSecret)
+	if err != nil {
+		return nil, err
+	}
+	return hkdf.Key(sha256.New, secret, nil, "email-token", 32)
+}
+
+// GenerateEmailToken signs the claims of an email token: Base64url(claims) "." Base64url(HMAC-SHA256).
+// Parameters:
+// - purpose: one of the EmailToken purposes
+// - userID: the ID of the user
+// - email: the address the token is sent to
+// - nonce: the email change the token confirms, empty for a verification
+// - ttl: how long the token is valid
+// Returns: the token, or an error if the JWT secret is not valid Base64
+func GenerateEmailToken(purpose string, userID uint32, email, nonce string, ttl time.Duration) (string, error) {
+	key, err := emailTokenKey()
+	if err != nil {
+		return "", err
+	}
+	claims, err := json.Marshal(EmailClaims{
+		Purpose:   purpose,
+		UserID:    userID,
+		Email:     email,
+		Nonce:     nonce,
+		ExpiresAt: time.Now().Add(ttl).Unix(),
+	})
+	if err != nil {
+		return "", err
+	}
+
+	payload := base64.RawURLEncoding.EncodeToString(claims)
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(payload))
+	return payload + "." + base64.RawURLEncoding.EncodeToString(mac.Sum(nil)), nil
+}
+
+// VerifyEmailToken checks the signature, the expiry and the purpose of an email token.
+// Parameters:
+// - token: the token from the email
+// - purposes: the purposes the token is accepted for
+// Returns: the claims of the token, or ErrInvalidEmailToken
+func VerifyEmailToken(token string, purposes ...string) (*EmailClaims, error) {
+	key, err := emailTokenKey()
+	if err != nil {
+		return nil, err
+	}
+	payload, signature, found := strings.Cut(token, ".")
+	if !found {
+		return nil, ErrInvalidEmailToken
+	}
+	signatureBytes, err := base64.RawURLEncoding.DecodeString(signature)
+	if err != nil {
+		return nil, ErrInvalidEmailToken
+	}
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(payload))
+	if !hmac.Equal(mac.Sum(nil), signatureBytes) {
+		return nil, ErrInvalidEmailToken
+	}
+
+	claimsBytes, err := base64.RawURLEncoding.DecodeString(payload)
+	if err != nil {
+		return nil, ErrInvalidEmailToken
+	}
+	var claims EmailClaims
+	if err := json.Unmarshal(claimsBytes, &claims); err != nil || time.Now().Unix() > claims.ExpiresAt {
+		return nil, ErrInvalidEmailToken
+	}
+	for _, purpose := range purposes {
+		if claims.Purpose == purpose {
+			return &claims, nil
+		}
+	}
+	return nil, ErrInvalidEmailToken
+}
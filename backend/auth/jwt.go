@@ -1,6 +1,7 @@
 package auth
 
 import (
+	"backend/crypto"
 	"encoding/base64"
 	"errors"
 	"fmt"
@@ -31,26 +32,22 @@ type JWTClaims struct {
 // Function that generates a JWT token from the user's ID and signs it with the user's secret key.
 // Parameters:
 // - user_id: the ID of the user for whom the token is being generated
-// - sign_type: the preferred signing method ("hmac-sha256", "hmac-sha512")
+// - sign_type: the HMAC type of the user, its JWT signing method comes from the crypto suite registry
 // Returns: the signed JWT token as a string, or an error if the signing process fails
 func GenerateJWTToken(user_id uint32, sign_type string) (string, error) {
 	key, err := base64.StdEncoding.DecodeString(JWTSecret)
 	if err != nil {
 		return "", err
 	}
+	mac := crypto.LookupMAC(sign_type)
+	if mac == nil {
+		return "", errors.New("the signing method is not valid for the JWT token signing")
+	}
 	claims := jwt.MapClaims{}
 	claims["user_id"] = user_id
 	claims["exp"] = time.Now().Add(time.Second * time.Duration(JWTExpiration))
-	switch sign_type {
-	case "hmac-sha256":
-		token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
-		return token.SignedString(key)
-	case "hmac-sha512":
-		token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
-		return token.SignedString(key)
-	default:
-		return "", errors.New("the signing method is not valid for the JWT token signing")
-	}
+	token := jwt.NewWithClaims(jwt.GetSigningMethod(mac.JWTMethod), claims)
+	return token.SignedString(key)
 }
 
 // Function that verifies a JWT token and returns its contents.
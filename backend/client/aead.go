@@ -0,0 +1,80 @@
+package client
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"encoding/base64"
+	"errors"
+
+	"golang.org/x/crypto/chacha20poly1305"
+)
+
+// Additional data of the AEAD ciphertexts, a title can never be decrypted as a body or the other way around
+var (
+	titleAAD = []byte("title")
+	bodyAAD  = []byte("body")
+)
+
+// newAEAD creates the AEAD cipher of the encryption type of the user
+func newAEAD(keys *Keys) (cipher.AEAD, error) {
+	if keys.EncryptionType == XChaCha20Poly1305 {
+		return chacha20poly1305.NewX(keys.EncryptionKey)
+	}
+	block, err := aes.NewCipher(keys.EncryptionKey)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// seal encrypts a text with the AEAD of the user, like aeadEncrypt in encryption.ts.
+// Parameters:
+// - keys: the keys of the user
+// - text: the plaintext
+// - nonce: the nonce, 12 bytes for AES-256-GCM and 24 bytes for XChaCha20-Poly1305
+// - aad: the additional data, titleAAD or bodyAAD
+// Returns: the Base64 ciphertext and the Base64 authentication tag
+func seal(keys *Keys, text string, nonce, aad []byte) (string, string, error) {
+	aead, err := newAEAD(keys)
+	if err != nil {
+		return "", "", err
+	}
+
+	sealed := aead.Seal(nil, nonce, []byte(text), aad)
+	ciphertext, tag := sealed[:len(sealed)-aead.Overhead()], sealed[len(sealed)-aead.Overhead():]
+	return base64.StdEncoding.EncodeToString(ciphertext), base64.StdEncoding.EncodeToString(tag), nil
+}
+
+// open checks and decrypts a text sealed by seal or encryption.ts.
+// Parameters:
+// - keys: the keys of the user
+// - ciphertextBase64: the Base64 ciphertext
+// - nonceBase64: the Base64 nonce
+// - tagBase64: the Base64 authentication tag
+// - aad: the additional data, titleAAD or bodyAAD
+// Returns: the plaintext, ErrInvalidMAC if the tag does not match, or an error if a field is invalid
+func open(keys *Keys, ciphertextBase64, nonceBase64, tagBase64 string, aad []byte) (string, error) {
+	aead, err := newAEAD(keys)
+	if err != nil {
+		return "", err
+	}
+
+	ciphertext, err := base64.StdEncoding.DecodeString(ciphertextBase64)
+	if err != nil {
+		return "", errors.New("invalid ciphertext format")
+	}
+	nonce, err := base64.StdEncoding.DecodeString(nonceBase64)
+	if err != nil || len(nonce) != aead.NonceSize() {
+		return "", errors.New("invalid nonce format")
+	}
+	tag, err := base64.StdEncoding.DecodeString(tagBase64)
+	if err != nil || len(tag) != aead.Overhead() {
+		return "", errors.New("invalid tag format")
+	}
+
+	plaintext, err := aead.Open(nil, nonce, append(ciphertext, tag...), aad)
+	if err != nil {
+		return "", ErrInvalidMAC
+	}
+	return string(plaintext), nil
+}
@@ -0,0 +1,271 @@
+package client
+
+import (
+	"backend/crypto"
+	"backend/models"
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hmac"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"time"
+
+	"golang.org/x/crypto/ed25519"
+)
+
+// ErrInvalidMAC is returned when the MAC or an authentication tag of a block does not match its ciphertexts
+var ErrInvalidMAC = errors.New("the block failed the integrity check")
+
+// padPKCS7 appends PKCS#7 padding, always at least one byte
+func padPKCS7(data []byte) []byte {
+	padLen := aes.BlockSize - len(data)%aes.BlockSize
+	return append(data, bytes.Repeat([]byte{byte(padLen)}, padLen)...)
+}
+
+// unpadPKCS7 removes and checks PKCS#7 padding
+func unpadPKCS7(data []byte) ([]byte, error) {
+	if len(data) == 0 {
+		return nil, errors.New("invalid padding")
+	}
+	padLen := int(data[len(data)-1])
+	if padLen == 0 || padLen > aes.BlockSize || padLen > len(data) {
+		return nil, errors.New("invalid padding")
+	}
+	for _, b := range data[len(data)-padLen:] {
+		if int(b) != padLen {
+			return nil, errors.New("invalid padding")
+		}
+	}
+	return data[:len(data)-padLen], nil
+}
+
+// encrypt encrypts a text with AES-128 in the mode of the user, like encryption.ts.
+// In CBC mode encryption.ts pads the plaintext itself and @noble/ciphers adds its own PKCS#7 layer on
+// top, so the plaintext is padded twice to decrypt the same way in the browser.
+// Parameters:
+// - keys: the keys of the user
+// - text: the plaintext
+// - iv: the 16 byte IV, or initial counter in CTR mode
+// Returns: the Base64 ciphertext
+func encrypt(keys *Keys, text string, iv []byte) (string, error) {
+	block, err := aes.NewCipher(keys.EncryptionKey)
+	if err != nil {
+		return "", err
+	}
+
+	plaintext := []byte(text)
+	var ciphertext []byte
+	if keys.EncryptionType == AES128CBC {
+		plaintext = padPKCS7(padPKCS7(plaintext))
+		ciphertext = make([]byte, len(plaintext))
+		cipher.NewCBCEncrypter(block, iv).CryptBlocks(ciphertext, plaintext)
+	} else {
+		ciphertext = make([]byte, len(plaintext))
+		cipher.NewCTR(block, iv).XORKeyStream(ciphertext, plaintext)
+	}
+
+	return base64.StdEncoding.EncodeToString(ciphertext), nil
+}
+
+// decrypt decrypts a Base64 ciphertext written by encrypt or encryption.ts.
+// Parameters:
+// - keys: the keys of the user
+// - ciphertextBase64: the Base64 ciphertext
+// - ivBase64: the Base64 IV, or initial counter in CTR mode
+// Returns: the plaintext, or an error if the ciphertext, the IV or the padding is invalid
+func decrypt(keys *Keys, ciphertextBase64, ivBase64 string) (string, error) {
+	ciphertext, err := base64.StdEncoding.DecodeString(ciphertextBase64)
+	if err != nil {
+		return "", errors.New("invalid ciphertext format")
+	}
+	iv, err := base64.StdEncoding.DecodeString(ivBase64)
+	if err != nil || len(iv) != ivSize {
+		return "", errors.New("invalid IV format")
+	}
+
+	block, err := aes.NewCipher(keys.EncryptionKey)
+	if err != nil {
+		return "", err
+	}
+
+	plaintext := make([]byte, len(ciphertext))
+	if keys.EncryptionType == AES128CBC {
+		if len(ciphertext) == 0 || len(ciphertext)%aes.BlockSize != 0 {
+			return "", errors.New("invalid ciphertext length")
+		}
+		cipher.NewCBCDecrypter(block, iv).CryptBlocks(plaintext, ciphertext)
+		// The layer of @noble/ciphers, then the layer of encryption.ts
+		if plaintext, err = unpadPKCS7(plaintext); err != nil {
+			return "", err
+		}
+		if plaintext, err = unpadPKCS7(plaintext); err != nil {
+			return "", err
+		}
+	} else {
+		cipher.NewCTR(block, iv).XORKeyStream(plaintext, ciphertext)
+	}
+
+	return string(plaintext), nil
+}
+
+// blockMAC computes the HMAC of the ciphertexts of a block, like createBlock.ts
+func blockMAC(keys *Keys, cipherTitle, ciphertext string) []byte {
+	mac := hmac.New(keys.Suite.MAC.New, keys.HMACKey)
+	mac.Write([]byte(cipherTitle + ciphertext))
+	return mac.Sum(nil)
+}
+
+// SignBlock signs a block with the Ed25519 key of the user and sets its signature.
+// With an ML-DSA key both keys sign the hybrid payload, the ML-DSA signature is deterministic like the Ed25519 one.
+// Parameters:
+// - keys: the keys of the user
+// - block: a pointer to the block to sign
+// Returns: an error if the ML-DSA signature fails
+func SignBlock(keys *Keys, block *models.Block) error {
+	if keys.PQSigningKey == nil {
+		signature := ed25519.Sign(keys.SigningKey, crypto.BlockSignaturePayload(block))
+		block.Signature = base64.StdEncoding.EncodeToString(signature)
+		return nil
+	}
+
+	payload := crypto.HybridBlockSignaturePayload(block)
+	pqSignature, err := keys.PQSigningKey.SignDeterministic(payload, nil)
+	if err != nil {
+		return err
+	}
+	block.Signature = base64.StdEncoding.EncodeToString(ed25519.Sign(keys.SigningKey, payload))
+	block.PQSignature = base64.StdEncoding.EncodeToString(pqSignature)
+	return nil
+}
+
+// NewBlock encrypts, authenticates and signs a version of a note.
+// Parameters:
+// - keys: the keys of the user
+// - title: the plaintext title
+// - body: the plaintext body
+// - prevHash: the hash of the head of the note, InitialHash for a new note
+// Returns: the signed block, or an error if the encryption fails
+func NewBlock(keys *Keys, title, body, prevHash string) (*models.Block, error) {
+	ivTitle := make([]byte, keys.ivLength())
+	ivBody := make([]byte, keys.ivLength())
+	if _, err := rand.Read(ivTitle); err != nil {
+		return nil, err
+	}
+	if _, err := rand.Read(ivBody); err != nil {
+		return nil, err
+	}
+
+	// The signature covers the RFC 3339 timestamp, which has a one second precision
+	return BuildBlock(keys, title, body, prevHash, ivTitle, ivBody, time.Now().UTC().Truncate(time.Second))
+}
+
+// BuildBlock encrypts, authenticates and signs a version of a note with the given IVs and timestamp.
+// NewBlock should be used to create real blocks, this deterministic variant exists for the test vectors.
+// Parameters:
+// - keys: the keys of the user
+// - title: the plaintext title
+// - body: the plaintext body
+// - prevHash: the hash of the head of the note, InitialHash for a new note
+// - ivTitle: the IV of the title, or its nonce for the AEAD types
+// - ivBody: the IV of the body, or its nonce for the AEAD types
+// - timestamp: the creation time of the block, with a one second precision
+// Returns: the signed block, or an error if an IV is invalid or the encryption fails
+func BuildBlock(keys *Keys, title, body, prevHash string, ivTitle, ivBody []byte, timestamp time.Time) (*models.Block, error) {
+	if len(ivTitle) != keys.ivLength() || len(ivBody) != keys.ivLength() {
+		return nil, errors.New("invalid IV size")
+	}
+
+	block := &models.Block{
+		PrevHash:  prevHash,
+		Timestamp: timestamp,
+	}
+
+	var err error
+	if keys.Suite.Cipher.AEAD {
+		// The tags authenticate the ciphertexts, the block has no IV and no MAC
+		if block.CipherTitle, block.TagTitle, err = seal(keys, title, ivTitle, titleAAD); err != nil {
+			return nil, err
+		}
+		if block.Ciphertext, block.Tag, err = seal(keys, body, ivBody, bodyAAD); err != nil {
+			return nil, err
+		}
+		block.NonceTitle = base64.StdEncoding.EncodeToString(ivTitle)
+		block.Nonce = base64.StdEncoding.EncodeToString(ivBody)
+	} else {
+		if block.CipherTitle, err = encrypt(keys, title, ivTitle); err != nil {
+			return nil, err
+		}
+		if block.Ciphertext, err = encrypt(keys, body, ivBody); err != nil {
+			return nil, err
+		}
+		block.IVTitle = base64.StdEncoding.EncodeToString(ivTitle)
+		block.IV = base64.StdEncoding.EncodeToString(ivBody)
+		block.MAC = base64.StdEncoding.EncodeToString(blockMAC(keys, block.CipherTitle, block.Ciphertext))
+	}
+
+	if err = SignBlock(keys, block); err != nil {
+		return nil, err
+	}
+	return block, nil
+}
+
+// ValidateMAC checks the MAC of a block in constant time, like validateMac in decryptBody.ts.
+// The blocks of the AEAD types have no MAC, their tags are checked when they are decrypted.
+// Parameters:
+// - keys: the keys of the user
+// - block: a pointer to the block
+// Returns: true if the MAC matches the ciphertexts of the block
+func ValidateMAC(keys *Keys, block *models.Block) bool {
+	mac, err := base64.StdEncoding.DecodeString(block.MAC)
+	if err != nil {
+		return false
+	}
+	return hmac.Equal(mac, blockMAC(keys, block.CipherTitle, block.Ciphertext))
+}
+
+// DecryptTitle decrypts the title of a note, as listed by the titles endpoint.
+// Parameters:
+// - keys: the keys of the user
+// - title: a pointer to the encrypted title
+// Returns: the plaintext title, or an error if it cannot be decrypted or, for the AEAD types, authenticated
+func DecryptTitle(keys *Keys, title *models.Title) (string, error) {
+	if keys.Suite.Cipher.AEAD {
+		return open(keys, title.CipherTitle, title.NonceTitle, title.TagTitle, titleAAD)
+	}
+	return decrypt(keys, title.CipherTitle, title.IV)
+}
+
+// DecryptBlock checks the integrity of a block and decrypts its title and body.
+// Unlike decryptBody.ts nothing is decrypted when the MAC is invalid.
+// Parameters:
+// - keys: the keys of the user
+// - block: a pointer to the block
+// Returns: the plaintext title and body, ErrInvalidMAC if the block was tampered with, or an error if it cannot be decrypted
+func DecryptBlock(keys *Keys, block *models.Block) (string, string, error) {
+	if keys.Suite.Cipher.AEAD {
+		title, err := open(keys, block.CipherTitle, block.NonceTitle, block.TagTitle, titleAAD)
+		if err != nil {
+			return "", "", err
+		}
+		body, err := open(keys, block.Ciphertext, block.Nonce, block.Tag, bodyAAD)
+		if err != nil {
+			return "", "", err
+		}
+		return title, body, nil
+	}
+
+	if !ValidateMAC(keys, block) {
+		return "", "", ErrInvalidMAC
+	}
+	title, err := decrypt(keys, block.CipherTitle, block.IVTitle)
+	if err != nil {
+		return "", "", err
+	}
+	body, err := decrypt(keys, block.Ciphertext, block.IV)
+	if err != nil {
+		return "", "", err
+	}
+	return title, body, nil
+}
@@ -0,0 +1,90 @@
+package client
+
+import (
+	"backend/crypto"
+	"backend/models"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// BlockHash computes the hash of a block, the prev_hash of the next block.
+// It is crypto.BlockHash, the hash the server chains and logs, so both sides always agree.
+// Parameters:
+// - block: a pointer to the block
+// Returns: the Base64 SHA-256 hash of the block, or an error if it cannot be marshaled
+func BlockHash(block *models.Block) (string, error) {
+	return crypto.BlockHash(*block)
+}
+
+// Signer holds the public keys a block is verified with
+type Signer struct {
+	PublicKey   string // Base64 Ed25519 public key
+	PQPublicKey string // Base64 ML-DSA public key of the hybrid signature types, empty otherwise
+}
+
+// VerifyChain checks a chain of blocks end to end: the links between the blocks and the signatures of every block.
+// Parameters:
+// - blocks: the blocks of the note in chain order
+// - signers: the keys that signed each block, a single signer applies to every block
+// Returns: an error describing the first problem found, nil if the chain is valid
+func VerifyChain(blocks []models.Block, signers ...Signer) error {
+	if len(blocks) == 0 {
+		return errors.New("the note has no blocks")
+	}
+	if len(signers) != 1 && len(signers) != len(blocks) {
+		return errors.New("a signer is needed for every block")
+	}
+
+	valid, err := crypto.VerifyBlockChain(blocks)
+	if err != nil || !valid {
+		return errors.New("the blocks do not form a valid chain")
+	}
+
+	for i := range blocks {
+		signer := signers[0]
+		if len(signers) > 1 {
+			signer = signers[i]
+		}
+		valid, err := crypto.VerifyBlockSignature(signer.PublicKey, signer.PQPublicKey, &blocks[i])
+		if err != nil || !valid {
+			return fmt.Errorf("block %d has an invalid signature", i+1)
+		}
+	}
+	return nil
+}
+
+// SignLifecycle signs the deletion or the restore of a note at its current head, like signTombstone.ts.
+// Parameters:
+// - keys: the keys of the user
+// - kind: models.BlockKindTombstone or models.BlockKindRestore
+// - noteID: the ID of the note
+// - headHash: the hash of the head block of the note
+// Returns: a pointer to the signed request, or an error if the ML-DSA signature fails
+func SignLifecycle(keys *Keys, kind, noteID, headHash string) (*models.Tombstone, error) {
+	return SignLifecycleAt(keys, kind, noteID, headHash, time.Now().UTC().Truncate(time.Second))
+}
+
+// SignLifecycleAt signs the deletion or the restore of a note with the given timestamp, for the test vectors.
+// The request is signed like the lifecycle block the server appends to the chain of the note.
+// Parameters:
+// - keys: the keys of the user
+// - kind: models.BlockKindTombstone or models.BlockKindRestore
+// - noteID: the ID of the note
+// - headHash: the hash of the head block of the note
+// - timestamp: the deletion or restore time, with a one second precision
+// Returns: a pointer to the signed request, or an error if the ML-DSA signature fails
+func SignLifecycleAt(keys *Keys, kind, noteID, headHash string, timestamp time.Time) (*models.Tombstone, error) {
+	tombstone := &models.Tombstone{
+		NoteID:    noteID,
+		HeadHash:  headHash,
+		Timestamp: timestamp,
+	}
+	block := tombstone.Block(kind)
+	if err := SignBlock(keys, &block); err != nil {
+		return nil, err
+	}
+	tombstone.Signature = block.Signature
+	tombstone.PQSignature = block.PQSignature
+	return tombstone, nil
+}
@@ -0,0 +1,397 @@
+package client
+
+import (
+	"backend/crypto"
+	"backend/models"
+	"bytes"
+	"crypto/mldsa"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"golang.org/x/crypto/ed25519"
+)
+
+// authCookie is the name of the cookie holding the JWT set by the login endpoint
+const authCookie = "auth_token"
+
+var (
+	// ErrNotLoggedIn is returned when a call needs a session and Login was not called, or the session expired
+	ErrNotLoggedIn = errors.New("not logged in")
+	// ErrWrongPassword is returned by Unlock when the password does not derive the key of the user
+	ErrWrongPassword = errors.New("wrong password")
+)
+
+// Client talks to a CantTouchMe server on behalf of one user.
+// Fields:
+// - BaseURL: the URL of the API, for example http://localhost:3000
+// - HTTP: the HTTP client used for the requests
+// - Token: the JWT of the session, set by Login
+// - User: the user of the session, set by Login
+// - Keys: the keys derived from the password, set by Login, they never leave the process
+type Client struct {
+	BaseURL string
+	HTTP    *http.Client
+	Token   string
+	User    *models.User
+	Keys    *Keys
+}
+
+// New creates a client for a server.
+// Parameters:
+// - baseURL: the URL of the API
+// Returns: a pointer to the client, not logged in
+func New(baseURL string) *Client {
+	return &Client{
+		BaseURL: strings.TrimRight(baseURL, "/"),
+		HTTP:    &http.Client{Timeout: 30 * time.Second},
+	}
+}
+
+// send sends a request and checks its status.
+// The session token is sent as the auth cookie by hand: the server sets it with the Secure flag, which a
+// cookie jar would refuse to send over plain HTTP to a development server.
+// Parameters:
+// - method: the HTTP method
+// - path: the path of the endpoint
+// - body: the value encoded as the JSON body, nil for no body
+// Returns: the HTTP response, whose body the caller must close, or an error if the request failed
+func (c *Client) send(method, path string, body any) (*http.Response, error) {
+	var reader io.Reader
+	if body != nil {
+		data, err := json.Marshal(body)
+		if err != nil {
+			return nil, err
+		}
+		reader = bytes.NewReader(data)
+	}
+
+	req, err := http.NewRequest(method, c.BaseURL+path, reader)
+	if err != nil {
+		return nil, err
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	if c.Token != "" {
+		req.AddCookie(&http.Cookie{Name: authCookie, Value: c.Token})
+	}
+
+	resp, err := c.HTTP.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode >= 300 {
+		defer resp.Body.Close()
+		message, _ := io.ReadAll(io.LimitReader(resp.Body, 4096))
+		if resp.StatusCode == http.StatusUnauthorized {
+			return resp, fmt.Errorf("%s %s: %w: %s", method, path, ErrNotLoggedIn, strings.TrimSpace(string(message)))
+		}
+		return resp, fmt.Errorf("%s %s: %s: %s", method, path, resp.Status, strings.TrimSpace(string(message)))
+	}
+	return resp, nil
+}
+
+// do sends a request and decodes the JSON response.
+// Parameters:
+// - method: the HTTP method
+// - path: the path of the endpoint
+// - body: the value encoded as the JSON body, nil for no body
+// - out: a pointer the JSON response is decoded into, nil to ignore the response
+// Returns: the HTTP response, with its body already consumed, or an error if the request failed
+func (c *Client) do(method, path string, body any, out any) (*http.Response, error) {
+	resp, err := c.send(method, path, body)
+	if err != nil {
+		return resp, err
+	}
+	defer resp.Body.Close()
+
+	if out != nil {
+		if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+			return resp, fmt.Errorf("%s %s: invalid response: %v", method, path, err)
+		}
+	}
+	return resp, nil
+}
+
+// Register creates an account with fresh random salts, like register.ts, and leaves the client logged out.
+// Parameters:
+// - name: the name of the user
+// - email: the email of the user
+// - password: the password of the user, only used locally to derive the public key
+// - hmacType: HMACSHA256 or HMACSHA512
+// - encryptionType: AES128CBC, AES128CTR, AES256GCM or XChaCha20Poly1305, the server refuses the deprecated suites
+// - signatureType: crypto.SignatureEd25519, or crypto.SignatureEd25519MLDSA65 to also sign the blocks with ML-DSA
+// Returns: the ID of the new user, or an error if the registration failed
+func (c *Client) Register(name, email, password, hmacType, encryptionType, signatureType string) (uint32, error) {
+	userID, _, err := c.register(name, email, password, hmacType, encryptionType, signatureType, false)
+	return userID, err
+}
+
+// RegisterWithRecovery creates an account like Register, with an offline recovery key escrowing its encryption and
+// HMAC keys. The recovery key is returned once, the user must keep it to recover the account with Recover.
+// Parameters: the same as Register
+// Returns: the ID of the new user and the Base64 recovery key, or an error if the registration failed
+func (c *Client) RegisterWithRecovery(name, email, password, hmacType, encryptionType, signatureType string) (uint32, string, error) {
+	return c.register(name, email, password, hmacType, encryptionType, signatureType, true)
+}
+
+// register creates an account, with a recovery key when withRecovery is set, see Register and RegisterWithRecovery
+func (c *Client) register(name, email, password, hmacType, encryptionType, signatureType string, withRecovery bool) (uint32, string, error) {
+	salts := make([]string, 3)
+	for i := range salts {
+		salt := make([]byte, 32)
+		if _, err := rand.Read(salt); err != nil {
+			return 0, "", err
+		}
+		salts[i] = base64.StdEncoding.EncodeToString(salt)
+	}
+
+	signingKey, err := DeriveSigningKey(password, salts[0], crypto.RecommendedKDF)
+	if err != nil {
+		return 0, "", err
+	}
+
+	var challenge struct {
+		Challenge string `json:"challenge"`
+	}
+	if _, err := c.do(http.MethodPost, "/auth/register/challenge", nil, &challenge); err != nil {
+		return 0, "", err
+	}
+
+	kdf := crypto.RecommendedKDF
+	profile := &crypto.RegistrationProfile{
+		Email:          email,
+		Name:           name,
+		LoginSalt:      salts[0],
+		EncryptionSalt: salts[1],
+		HMACSalt:       salts[2],
+		PublicKey:      base64.StdEncoding.EncodeToString(signingKey.Public().(ed25519.PublicKey)),
+		HMACType:       hmacType,
+		EncryptionType: encryptionType,
+		KDF:            &kdf,
+		SignatureType:  signatureType,
+	}
+	request := map[string]any{
+		"kdf":             crypto.RecommendedKDF,
+		"name":            name,
+		"email":           email,
+		"hmac_type":       hmacType,
+		"encryption_type": encryptionType,
+		"login_salt":      salts[0],
+		"encryption_salt": salts[1],
+		"hmac_salt":       salts[2],
+		"public_key":      profile.PublicKey,
+		"signature_type":  signatureType,
+	}
+	var pqSigningKey *mldsa.PrivateKey
+	if crypto.IsHybridSignature(signatureType) {
+		if pqSigningKey, err = DerivePQSigningKey(signingKey); err != nil {
+			return 0, "", err
+		}
+		profile.PQPublicKey = base64.StdEncoding.EncodeToString(pqSigningKey.PublicKey().Bytes())
+		request["pq_public_key"] = profile.PQPublicKey
+	}
+
+	var recoveryKey string
+	if withRecovery {
+		keys, err := DeriveKeys(password, &models.User{
+			LoginSalt:      salts[0],
+			EncryptionSalt: salts[1],
+			HMACSalt:       salts[2],
+			HMACType:       hmacType,
+			EncryptionType: encryptionType,
+			KDF:            crypto.RecommendedKDF,
+		})
+		if err != nil {
+			return 0, "", err
+		}
+		var fields map[string]string
+		if recoveryKey, fields, err = recoveryEnrollment(keys); err != nil {
+			return 0, "", err
+		}
+		for name, value := range fields {
+			request[name] = value
+		}
+		profile.RecoveryPublicKey = fields["recovery_public_key"]
+		profile.RecoveryEscrow = fields["recovery_escrow"]
+	}
+
+	// The registration is signed with the new keys, proving the client holds them
+	payload := crypto.RegistrationPayload(challenge.Challenge, profile)
+	request["challenge"] = challenge.Challenge
+	request["signature"] = base64.StdEncoding.EncodeToString(ed25519.Sign(signingKey, payload))
+	if pqSigningKey != nil {
+		pqSignature, err := pqSigningKey.Sign(nil, payload, nil)
+		if err != nil {
+			return 0, "", err
+		}
+		request["pq_signature"] = base64.StdEncoding.EncodeToString(pqSignature)
+	}
+
+	var response struct {
+		UserID uint32 `json:"user_id"`
+	}
+	if _, err := c.do(http.MethodPost, "/auth/register", request, &response); err != nil {
+		return 0, "", err
+	}
+	return response.UserID, recoveryKey, nil
+}
+
+// Login runs the challenge flow of the server and derives the keys of the user.
+// Parameters:
+// - email: the email of the user
+// - password: the password of the user, only used locally to derive the keys
+// Returns: an error if the credentials are wrong or the server cannot be reached
+func (c *Client) Login(email, password string) error {
+	var challenge struct {
+		Challenge string           `json:"challenge"`
+		LoginSalt string           `json:"login_salt"`
+		KDF       models.KDFParams `json:"kdf"`
+	}
+	if _, err := c.do(http.MethodPost, "/auth/challenge", map[string]string{"email": email}, &challenge); err != nil {
+		return err
+	}
+
+	signingKey, err := DeriveSigningKey(password, challenge.LoginSalt, challenge.KDF)
+	if err != nil {
+		return err
+	}
+	challengeBytes, err := base64.StdEncoding.DecodeString(challenge.Challenge)
+	if err != nil {
+		return errors.New("invalid challenge format")
+	}
+	signature := ed25519.Sign(signingKey, challengeBytes)
+
+	request := map[string]string{
+		"email":     email,
+		"challenge": challenge.Challenge,
+		"signature": base64.StdEncoding.EncodeToString(signature),
+	}
+	var login struct {
+		User       models.User       `json:"user"`
+		KDFUpgrade *models.KDFParams `json:"kdf_upgrade"`
+	}
+	resp, err := c.do(http.MethodPost, "/auth/login", request, &login)
+	if err != nil {
+		return err
+	}
+
+	for _, cookie := range resp.Cookies() {
+		if cookie.Name == authCookie {
+			c.Token = cookie.Value
+		}
+	}
+	if c.Token == "" {
+		return errors.New("the server did not return a session")
+	}
+
+	keys, err := DeriveKeys(password, &login.User)
+	if err != nil {
+		return err
+	}
+	c.User = &login.User
+	c.Keys = keys
+
+	// The old login key keeps working, a failed upgrade is offered again at the next login
+	if login.KDFUpgrade != nil {
+		_ = c.UpgradeKDF(password, *login.KDFUpgrade)
+	}
+	return nil
+}
+
+// UpgradeKDF derives a new login key with a fresh salt and stronger KDF parameters, like upgradeKDF.ts.
+// The change is signed with the current and the new login key, the encryption and HMAC keys do not change.
+// Parameters:
+// - password: the password of the user
+// - kdf: the new KDF parameters, stronger than the current ones
+// Returns: ErrNotLoggedIn without a session, or an error if the server refused the upgrade
+func (c *Client) UpgradeKDF(password string, kdf models.KDFParams) error {
+	if c.Keys == nil || c.User == nil {
+		return ErrNotLoggedIn
+	}
+
+	salt := make([]byte, 32)
+	if _, err := rand.Read(salt); err != nil {
+		return err
+	}
+	loginSalt := base64.StdEncoding.EncodeToString(salt)
+	signingKey, err := DeriveSigningKey(password, loginSalt, kdf)
+	if err != nil {
+		return err
+	}
+	publicKey := base64.StdEncoding.EncodeToString(signingKey.Public().(ed25519.PublicKey))
+
+	// The ML-DSA key of the hybrid accounts is derived from the login key, it changes with it
+	var pqSigningKey *mldsa.PrivateKey
+	var pqPublicKey string
+	if c.Keys.PQSigningKey != nil {
+		if pqSigningKey, err = DerivePQSigningKey(signingKey); err != nil {
+			return err
+		}
+		pqPublicKey = base64.StdEncoding.EncodeToString(pqSigningKey.PublicKey().Bytes())
+	}
+
+	payload := crypto.KDFUpgradePayload(c.Keys.PublicKey(), publicKey, pqPublicKey, loginSalt, kdf)
+	request := map[string]any{
+		"kdf":           kdf,
+		"login_salt":    loginSalt,
+		"public_key":    publicKey,
+		"signature":     base64.StdEncoding.EncodeToString(ed25519.Sign(c.Keys.SigningKey, payload)),
+		"new_signature": base64.StdEncoding.EncodeToString(ed25519.Sign(signingKey, payload)),
+	}
+	if pqSigningKey != nil {
+		pqSignature, err := pqSigningKey.Sign(nil, payload, nil)
+		if err != nil {
+			return err
+		}
+		request["pq_public_key"] = pqPublicKey
+		request["pq_signature"] = base64.StdEncoding.EncodeToString(pqSignature)
+	}
+	var response struct {
+		User models.User `json:"user"`
+	}
+	if _, err := c.do(http.MethodPost, "/auth/kdf", request, &response); err != nil {
+		return err
+	}
+
+	c.User = &response.User
+	c.Keys.SigningKey = signingKey
+	c.Keys.PQSigningKey = pqSigningKey
+	return nil
+}
+
+// Logout ends the session and forgets the keys.
+// Returns: an error if the server cannot be reached, the keys are forgotten anyway
+func (c *Client) Logout() error {
+	_, err := c.do(http.MethodPost, "/auth/logout", nil, nil)
+	c.Token = ""
+	c.User = nil
+	c.Keys = nil
+	return err
+}
+
+// Unlock derives the keys of a resumed session, whose token and user were saved after an earlier Login.
+// Parameters:
+// - password: the password of the user
+// Returns: ErrWrongPassword if the password does not match the public key of the user
+func (c *Client) Unlock(password string) error {
+	if c.User == nil {
+		return ErrNotLoggedIn
+	}
+	keys, err := DeriveKeys(password, c.User)
+	if err != nil {
+		return err
+	}
+	if keys.PublicKey() != c.User.PubKey {
+		return ErrWrongPassword
+	}
+	c.Keys = keys
+	return nil
+}
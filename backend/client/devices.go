@@ -0,0 +1,195 @@
+package client
+
+import (
+	"backend/crypto"
+	"backend/models"
+	"crypto/mldsa"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"net/http"
+
+	"golang.org/x/crypto/ed25519"
+)
+
+// DeviceKeys holds the signing keys generated by a device, unlike the login key they do not depend on the password
+type DeviceKeys struct {
+	SigningKey   ed25519.PrivateKey
+	PQSigningKey *mldsa.PrivateKey // ML-DSA key of the hybrid signature types, nil otherwise
+}
+
+// GenerateDeviceKeys generates the signing keys of a new device.
+// Parameters:
+// - signatureType: the signature type of the account, an ML-DSA key is generated for the hybrid types
+// Returns: the keys, or an error if the random generator fails
+func GenerateDeviceKeys(signatureType string) (*DeviceKeys, error) {
+	_, signingKey, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		return nil, err
+	}
+	device := &DeviceKeys{SigningKey: signingKey}
+	if crypto.IsHybridSignature(signatureType) {
+		if device.PQSigningKey, err = mldsa.GenerateKey(mldsa.MLDSA65()); err != nil {
+			return nil, err
+		}
+	}
+	return device, nil
+}
+
+// PublicKey returns the Base64 Ed25519 public key of the device
+func (d *DeviceKeys) PublicKey() string {
+	return base64.StdEncoding.EncodeToString(d.SigningKey.Public().(ed25519.PublicKey))
+}
+
+// PQPublicKey returns the Base64 ML-DSA public key of the device, empty without an ML-DSA key
+func (d *DeviceKeys) PQPublicKey() string {
+	if d.PQSigningKey == nil {
+		return ""
+	}
+	return base64.StdEncoding.EncodeToString(d.PQSigningKey.PublicKey().Bytes())
+}
+
+// EnrollDevice enrolls the keys of a new device, vouched for by the key the client currently signs with.
+// Parameters:
+// - name: the name of the device
+// - device: the keys of the new device, from GenerateDeviceKeys
+// Returns: the enrolled device, or an error if the server refused the enrollment
+func (c *Client) EnrollDevice(name string, device *DeviceKeys) (*models.DeviceKey, error) {
+	if c.Keys == nil || c.User == nil {
+		return nil, ErrNotLoggedIn
+	}
+
+	enrolledBy := c.Keys.PublicKey()
+	payload := crypto.DeviceEnrollmentPayload(c.User.ID, enrolledBy, device.PublicKey(), device.PQPublicKey(), name)
+	request := map[string]any{
+		"name":             name,
+		"public_key":       device.PublicKey(),
+		"enrolled_by":      enrolledBy,
+		"signature":        base64.StdEncoding.EncodeToString(ed25519.Sign(c.Keys.SigningKey, payload)),
+		"device_signature": base64.StdEncoding.EncodeToString(ed25519.Sign(device.SigningKey, payload)),
+	}
+	if device.PQSigningKey != nil {
+		pqSignature, err := device.PQSigningKey.Sign(nil, payload, nil)
+		if err != nil {
+			return nil, err
+		}
+		request["pq_public_key"] = device.PQPublicKey()
+		request["pq_signature"] = base64.StdEncoding.EncodeToString(pqSignature)
+	}
+
+	var response struct {
+		Device models.DeviceKey `json:"device"`
+	}
+	if _, err := c.do(http.MethodPost, "/auth/devices/enroll", request, &response); err != nil {
+		return nil, err
+	}
+	return &response.Device, nil
+}
+
+// UseDevice makes the client sign the blocks with the keys of an enrolled device instead of the login key.
+// The encryption and HMAC keys still come from the password.
+// Parameters:
+// - device: the keys of the device
+// Returns: ErrNotLoggedIn without keys
+func (c *Client) UseDevice(device *DeviceKeys) error {
+	if c.Keys == nil {
+		return ErrNotLoggedIn
+	}
+	c.Keys.SigningKey = device.SigningKey
+	c.Keys.PQSigningKey = device.PQSigningKey
+	return nil
+}
+
+// Devices lists the device keys of the user, the revoked ones included.
+// Returns: the devices in enrollment order, or an error if the request failed
+func (c *Client) Devices() ([]*models.DeviceKey, error) {
+	var devices []*models.DeviceKey
+	if _, err := c.do(http.MethodGet, "/auth/devices", nil, &devices); err != nil {
+		return nil, err
+	}
+	return devices, nil
+}
+
+// RevokeDevice revokes a device key, for example of a lost device, signed with the key the client currently
+// signs with.
+// Parameters:
+// - deviceID: the ID of the device
+// Returns: an error if the device does not exist or is already revoked, or if the server refused the revocation
+func (c *Client) RevokeDevice(deviceID uint32) error {
+	if c.Keys == nil || c.User == nil {
+		return ErrNotLoggedIn
+	}
+	devices, err := c.Devices()
+	if err != nil {
+		return err
+	}
+	var device *models.DeviceKey
+	for _, candidate := range devices {
+		if candidate.ID == deviceID && candidate.RevokedAt == nil {
+			device = candidate
+		}
+	}
+	if device == nil {
+		return fmt.Errorf("no active device %d", deviceID)
+	}
+
+	revokedBy := c.Keys.PublicKey()
+	payload := crypto.DeviceRevocationPayload(c.User.ID, revokedBy, device.PubKey)
+	request := map[string]any{
+		"device_id":  deviceID,
+		"revoked_by": revokedBy,
+		"signature":  base64.StdEncoding.EncodeToString(ed25519.Sign(c.Keys.SigningKey, payload)),
+	}
+	_, err = c.do(http.MethodPost, "/auth/devices/revoke", request, nil)
+	return err
+}
+
+// signers returns the keys the notes of the user can be signed with: the keys of the client, the login key of
+// the user and the keys of their active devices.
+// Returns: the signers, or an error if the devices cannot be listed
+func (c *Client) signers() ([]Signer, error) {
+	signers := []Signer{c.Keys.Signer()}
+	if c.User != nil {
+		signers = append(signers, Signer{PublicKey: c.User.PubKey, PQPublicKey: c.User.PQPubKey})
+	}
+	devices, err := c.Devices()
+	if err != nil {
+		return nil, err
+	}
+	for _, device := range devices {
+		if device.RevokedAt == nil {
+			signers = append(signers, Signer{PublicKey: device.PubKey, PQPublicKey: device.PQPubKey})
+		}
+	}
+	return signers, nil
+}
+
+// verifySignedByUser checks that every block is signed with a key of the user. The blocks may have been written by
+// another device of the user, their keys are only fetched then.
+// Parameters:
+// - blocks: the blocks to check
+// Returns: an error if a block is not signed with a key of the user, or if the devices cannot be listed
+func (c *Client) verifySignedByUser(blocks []models.Block) error {
+	var signers []Signer
+	for i := range blocks {
+		valid, err := crypto.VerifyBlockSignature(c.Keys.PublicKey(), c.Keys.PQPublicKey(), &blocks[i])
+		if err == nil && valid {
+			continue
+		}
+		if signers == nil {
+			if signers, err = c.signers(); err != nil {
+				return err
+			}
+		}
+		for _, signer := range signers[1:] {
+			if valid, err = crypto.VerifyBlockSignature(signer.PublicKey, signer.PQPublicKey, &blocks[i]); err == nil && valid {
+				break
+			}
+		}
+		if !valid {
+			return errors.New("a block of the note is not signed with a key of the user")
+		}
+	}
+	return nil
+}
@@ -0,0 +1,73 @@
+package client
+
+import (
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// ConfirmEmail applies the token of a verification or email change link, no session is needed.
+// Parameters:
+// - link: the link of the email, or its bare token
+// Returns: the status of the confirmation, "verified", "pending" or "changed", or an error if the token is refused
+func (c *Client) ConfirmEmail(link string) (string, error) {
+	token := link
+	if strings.Contains(link, "?") {
+		parsed, err := url.Parse(link)
+		if err != nil {
+			return "", err
+		}
+		token = parsed.Query().Get("token")
+	}
+
+	var response struct {
+		Status string `json:"status"`
+	}
+	if _, err := c.do(http.MethodPost, "/auth/email/confirm", map[string]string{"token": token}, &response); err != nil {
+		return "", err
+	}
+	if response.Status == "changed" && c.User != nil && c.User.PendingEmail != "" {
+		c.User.Email = c.User.PendingEmail
+		c.User.PendingEmail = ""
+	}
+	return response.Status, nil
+}
+
+// ResendVerification asks for a new verification link of an account, the server answers the same whether it exists.
+// Parameters:
+// - email: the address of the account
+// Returns: an error if the server cannot be reached or refuses the address
+func (c *Client) ResendVerification(email string) error {
+	_, err := c.do(http.MethodPost, "/auth/email/resend", map[string]string{"email": email}, nil)
+	return err
+}
+
+// ChangeEmail starts the change of the email of the user, which applies once the links sent to both the current
+// and the new address are confirmed with ConfirmEmail.
+// Parameters:
+// - email: the new address
+// Returns: an error if the address is invalid, already used, or the emails cannot be sent
+func (c *Client) ChangeEmail(email string) error {
+	var response struct {
+		PendingEmail string `json:"pending_email"`
+	}
+	if _, err := c.do(http.MethodPost, "/auth/email/change", map[string]string{"email": email}, &response); err != nil {
+		return err
+	}
+	if c.User != nil {
+		c.User.PendingEmail = response.PendingEmail
+	}
+	return nil
+}
+
+// CancelEmailChange drops the pending email change of the user.
+// Returns: an error if the server cannot be reached
+func (c *Client) CancelEmailChange() error {
+	if _, err := c.do(http.MethodPost, "/auth/email/change/cancel", nil, nil); err != nil {
+		return err
+	}
+	if c.User != nil {
+		c.User.PendingEmail = ""
+	}
+	return nil
+}
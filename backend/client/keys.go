@@ -0,0 +1,186 @@
+// Package client implements the client side of the CantTouchMe protocol in Go.
+//
+// It mirrors frontend/src/auth/crypto and frontend/src/notes/crypto: the keys are derived from the
+// password and the salts of the user, the notes are encrypted and authenticated locally, and the blocks
+// are signed with the Ed25519 key of the user, so the server never sees a password or a plaintext.
+//
+// The block hashes and signature payloads come from the crypto package of the server, so a block built
+// here verifies on the server and in the browser. Client wraps the HTTP API: it logs in with the
+// challenge flow, then lists, reads, edits, deletes, verifies and exports the notes of the user.
+package client
+
+import (
+	"backend/crypto"
+	"backend/models"
+	"crypto/hkdf"
+	"crypto/mldsa"
+	"crypto/pbkdf2"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"hash"
+
+	"golang.org/x/crypto/argon2"
+	"golang.org/x/crypto/ed25519"
+)
+
+// kdfIterations is the PBKDF2 iteration count of the encryption and HMAC keys
+const kdfIterations = 100_000
+
+// Supported algorithms, the values stored in the hmac_type and encryption_type of the user
+const (
+	HMACSHA256        = crypto.HMACSHA256
+	HMACSHA512        = crypto.HMACSHA512
+	AES128CBC         = crypto.EncryptionAES128CBC
+	AES128CTR         = crypto.EncryptionAES128CTR
+	AES256GCM         = crypto.EncryptionAES256GCM
+	XChaCha20Poly1305 = crypto.EncryptionXChaCha20Poly1305
+)
+
+// InitialHash is the prev_hash of the first block of every note
+const InitialHash = "AAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAA="
+
+// ivSize is one AES block, used as the IV in CBC mode and as the initial counter in CTR mode
+const ivSize = 16
+
+// pqSeedInfo is the HKDF info of the ML-DSA seed, derived from the Ed25519 seed of the user
+const pqSeedInfo = "ml-dsa-65"
+
+// Keys holds the keys derived from the password of a user, they only ever live in memory
+type Keys struct {
+	SigningKey     ed25519.PrivateKey // Signs the login challenges, the blocks and the tombstones
+	PQSigningKey   *mldsa.PrivateKey  // Also signs the blocks for the hybrid signature types, nil otherwise
+	EncryptionKey  []byte             // AES-128 key of the titles and bodies, 256 bit key for the AEAD types
+	HMACKey        []byte             // Authenticates the ciphertexts of the AES-128 types
+	HMACType       string             // HMACSHA256 or HMACSHA512
+	EncryptionType string             // AES128CBC, AES128CTR, AES256GCM or XChaCha20Poly1305
+	Suite          *crypto.Suite      // Crypto suite of the user, with the key and IV sizes of its algorithms
+}
+
+// derive runs PBKDF2 over the password with a Base64 salt
+func derive(h func() hash.Hash, password, saltBase64 string, keyLen int) ([]byte, error) {
+	salt, err := base64.StdEncoding.DecodeString(saltBase64)
+	if err != nil {
+		return nil, errors.New("invalid salt format")
+	}
+	return pbkdf2.Key(h, password, salt, kdfIterations, keyLen)
+}
+
+// DeriveSigningKey derives the Ed25519 key of a user, like derivePrivateKey in keyDerivation.ts.
+// Parameters:
+// - password: the password of the user
+// - loginSaltBase64: the Base64 login salt of the user
+// - kdf: the KDF parameters of the user, crypto.LegacyKDF when empty
+// Returns: the private key, or an error if the salt or the parameters are invalid
+func DeriveSigningKey(password, loginSaltBase64 string, kdf models.KDFParams) (ed25519.PrivateKey, error) {
+	// Older servers and archives do not send the parameters
+	if kdf.Algorithm == "" {
+		kdf = crypto.LegacyKDF
+	}
+	if err := crypto.ValidateKDFParams(kdf); err != nil {
+		return nil, err
+	}
+	salt, err := base64.StdEncoding.DecodeString(loginSaltBase64)
+	if err != nil {
+		return nil, errors.New("invalid salt format")
+	}
+
+	var seed []byte
+	if kdf.Algorithm == crypto.KDFArgon2id {
+		seed = argon2.IDKey([]byte(password), salt, kdf.Iterations, kdf.MemoryKiB, kdf.Parallelism, ed25519.SeedSize)
+	} else if seed, err = pbkdf2.Key(sha256.New, password, salt, int(kdf.Iterations), ed25519.SeedSize); err != nil {
+		return nil, err
+	}
+	return ed25519.NewKeyFromSeed(seed), nil
+}
+
+// DerivePQSigningKey derives the ML-DSA-65 key of a hybrid account from its Ed25519 key, like derivePQPrivateKey
+// in keyDerivation.ts. The seed goes through HKDF-SHA256, so the login key is never used as is.
+// Parameters:
+// - signingKey: the Ed25519 key of the user
+// Returns: the ML-DSA private key, or an error if the seed cannot be derived
+func DerivePQSigningKey(signingKey ed25519.PrivateKey) (*mldsa.PrivateKey, error) {
+	seed, err := hkdf.Key(sha256.New, signingKey.Seed(), nil, pqSeedInfo, mldsa.PrivateKeySize)
+	if err != nil {
+		return nil, err
+	}
+	return mldsa.NewPrivateKey(mldsa.MLDSA65(), seed)
+}
+
+// DeriveKeys derives every key of a user from their password, with an ML-DSA key for the hybrid signature types.
+// The encryption and HMAC keys of a recovered account are opened from its wrapped keys instead.
+// Parameters:
+// - password: the password of the user
+// - user: a pointer to the user, as returned by the login endpoint
+// Returns: the keys, or an error if a salt or an algorithm is invalid
+func DeriveKeys(password string, user *models.User) (*Keys, error) {
+	suite, err := crypto.SuiteOf(user)
+	if err != nil {
+		return nil, err
+	}
+
+	signingKey, err := DeriveSigningKey(password, user.LoginSalt, user.KDF)
+	if err != nil {
+		return nil, err
+	}
+
+	var encryptionKey, hmacKey []byte
+	if user.WrappedKeys != "" {
+		// A recovered account can no longer derive its keys from the password, they are sealed under it
+		wrappingKey, err := passwordWrappingKey(password, user.EncryptionSalt)
+		if err != nil {
+			return nil, err
+		}
+		encryptionKey, hmacKey, err = openKeys(wrappingKey, crypto.KeyEnvelopePassword, user.WrappedKeys, suite)
+		if err != nil {
+			return nil, err
+		}
+	} else {
+		if encryptionKey, err = derive(sha256.New, password, user.EncryptionSalt, suite.Cipher.KeySize); err != nil {
+			return nil, err
+		}
+		if hmacKey, err = derive(suite.MAC.New, password, user.HMACSalt, suite.MAC.KeySize); err != nil {
+			return nil, err
+		}
+	}
+
+	var pqSigningKey *mldsa.PrivateKey
+	if crypto.IsHybridSignature(user.SignatureType) {
+		if pqSigningKey, err = DerivePQSigningKey(signingKey); err != nil {
+			return nil, err
+		}
+	}
+
+	return &Keys{
+		SigningKey:     signingKey,
+		PQSigningKey:   pqSigningKey,
+		EncryptionKey:  encryptionKey,
+		HMACKey:        hmacKey,
+		HMACType:       suite.MAC.Name,
+		EncryptionType: suite.Cipher.Name,
+		Suite:          suite,
+	}, nil
+}
+
+// PublicKey returns the Base64 public key matching the signing key, the public_key of the user
+func (k *Keys) PublicKey() string {
+	return base64.StdEncoding.EncodeToString(k.SigningKey.Public().(ed25519.PublicKey))
+}
+
+// PQPublicKey returns the Base64 ML-DSA public key, the pq_public_key of the user, empty without an ML-DSA key
+func (k *Keys) PQPublicKey() string {
+	if k.PQSigningKey == nil {
+		return ""
+	}
+	return base64.StdEncoding.EncodeToString(k.PQSigningKey.PublicKey().Bytes())
+}
+
+// Signer returns the public keys the blocks signed with these keys verify with
+func (k *Keys) Signer() Signer {
+	return Signer{PublicKey: k.PublicKey(), PQPublicKey: k.PQPublicKey()}
+}
+
+// ivLength returns the size of the IVs, or of the nonces for the AEAD types
+func (k *Keys) ivLength() int {
+	return k.Suite.Cipher.IVSize
+}
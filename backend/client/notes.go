@@ -0,0 +1,332 @@
+package client
+
+import (
+	"backend/crypto"
+	"backend/models"
+	"backend/vault"
+	"errors"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+)
+
+// titlesPageSize is the page size used to list the titles, the largest the server accepts
+const titlesPageSize = 200
+
+// NoteTitle is a decrypted entry of the titles endpoint
+type NoteTitle struct {
+	NoteID    string
+	Title     string
+	Timestamp time.Time // Timestamp of the latest version (last modification)
+	CreatedAt time.Time // Timestamp of the first block
+	HeadHash  string    // Hash of the head block
+}
+
+// Note is a decrypted version of a note
+type Note struct {
+	NoteID   string
+	Title    string
+	Body     string
+	Block    *models.Block // The encrypted block of the version
+	HeadHash string        // Hash of the head block of the note, the prev_hash of the next version
+}
+
+// noteRequest is the body of the endpoints addressing a single note
+type noteRequest struct {
+	NoteID string `json:"note_id"`
+}
+
+// CreateNote encrypts and signs a new note and uploads it.
+// Parameters:
+// - title: the plaintext title
+// - body: the plaintext body
+// Returns: the ID of the new note and the receipt of the server, or an error if the upload failed
+func (c *Client) CreateNote(title, body string) (string, *models.Receipt, error) {
+	if c.Keys == nil {
+		return "", nil, ErrNotLoggedIn
+	}
+
+	block, err := NewBlock(c.Keys, title, body, InitialHash)
+	if err != nil {
+		return "", nil, err
+	}
+
+	var response struct {
+		NoteID  string          `json:"note_id"`
+		Receipt *models.Receipt `json:"receipt"`
+	}
+	if _, err := c.do(http.MethodPost, "/notes/new", block, &response); err != nil {
+		return "", nil, err
+	}
+	return response.NoteID, response.Receipt, nil
+}
+
+// Titles lists and decrypts the titles of every note of the user, most recently modified first.
+// Returns: the titles, or an error if a page cannot be fetched or a title cannot be decrypted
+func (c *Client) Titles() ([]*NoteTitle, error) {
+	if c.Keys == nil {
+		return nil, ErrNotLoggedIn
+	}
+
+	titles := []*NoteTitle{}
+	cursor := ""
+	for {
+		query := url.Values{"limit": {strconv.Itoa(titlesPageSize)}}
+		if cursor != "" {
+			query.Set("cursor", cursor)
+		}
+
+		var page models.TitlePage
+		if _, err := c.do(http.MethodGet, "/notes/titles?"+query.Encode(), nil, &page); err != nil {
+			return nil, err
+		}
+
+		for _, encrypted := range page.Titles {
+			title, err := DecryptTitle(c.Keys, encrypted)
+			if err != nil {
+				return nil, err
+			}
+			titles = append(titles, &NoteTitle{
+				NoteID:    encrypted.NoteID,
+				Title:     title,
+				Timestamp: encrypted.Timestamp,
+				CreatedAt: encrypted.CreatedAt,
+				HeadHash:  encrypted.HeadHash,
+			})
+		}
+
+		if page.NextCursor == "" || len(page.Titles) == 0 {
+			return titles, nil
+		}
+		cursor = page.NextCursor
+	}
+}
+
+// GetNote fetches the latest version of a note, checks its signature and MAC, and decrypts it.
+// The version is followed by the tombstones and restores of the note since it was written, their signatures and
+// links are checked too and the last of them is the head the next version extends.
+// Parameters:
+// - noteID: the ID of the note
+// Returns: a pointer to the decrypted note, or an error if it cannot be fetched, verified or decrypted
+func (c *Client) GetNote(noteID string) (*Note, error) {
+	if c.Keys == nil {
+		return nil, ErrNotLoggedIn
+	}
+
+	var head models.NoteHead
+	if _, err := c.do(http.MethodPost, "/notes/get", noteRequest{NoteID: noteID}, &head); err != nil {
+		return nil, err
+	}
+	block := head.Block
+
+	blocks := append([]models.Block{block}, head.Lifecycle...)
+	for i := 1; i < len(blocks); i++ {
+		if err := crypto.ValidateLifecycleBlock(&blocks[i]); err != nil || blocks[i].NoteID != noteID {
+			return nil, errors.New("the note is followed by an invalid lifecycle block")
+		}
+		prevHash, err := BlockHash(&blocks[i-1])
+		if err != nil {
+			return nil, err
+		}
+		if blocks[i].PrevHash != prevHash {
+			return nil, errors.New("the lifecycle blocks do not extend the note")
+		}
+	}
+	if err := c.verifySignedByUser(blocks); err != nil {
+		return nil, err
+	}
+
+	title, body, err := DecryptBlock(c.Keys, &block)
+	if err != nil {
+		return nil, err
+	}
+	headHash, err := BlockHash(&blocks[len(blocks)-1])
+	if err != nil {
+		return nil, err
+	}
+
+	return &Note{
+		NoteID:   noteID,
+		Title:    title,
+		Body:     body,
+		Block:    &block,
+		HeadHash: headHash,
+	}, nil
+}
+
+// EditNote appends a new version to a note, chained to its current head.
+// Parameters:
+// - noteID: the ID of the note
+// - title: the new plaintext title
+// - body: the new plaintext body
+// Returns: the receipt of the server, or an error if the upload failed, for example with a 409 status when
+// the note was modified since its head was fetched
+func (c *Client) EditNote(noteID, title, body string) (*models.Receipt, error) {
+	note, err := c.GetNote(noteID)
+	if err != nil {
+		return nil, err
+	}
+	return c.AppendBlock(noteID, note.HeadHash, title, body)
+}
+
+// AppendBlock encrypts and signs a new version of a note on top of a known head and uploads it.
+// Parameters:
+// - noteID: the ID of the note
+// - headHash: the hash of the head block the new version extends
+// - title: the new plaintext title
+// - body: the new plaintext body
+// Returns: the receipt of the server, or an error if the upload failed
+func (c *Client) AppendBlock(noteID, headHash, title, body string) (*models.Receipt, error) {
+	if c.Keys == nil {
+		return nil, ErrNotLoggedIn
+	}
+
+	block, err := NewBlock(c.Keys, title, body, headHash)
+	if err != nil {
+		return nil, err
+	}
+
+	var response struct {
+		Receipt *models.Receipt `json:"receipt"`
+	}
+	request := models.Note{NoteID: noteID, Block: *block}
+	if _, err := c.do(http.MethodPost, "/notes/edit", request, &response); err != nil {
+		return nil, err
+	}
+	return response.Receipt, nil
+}
+
+// DeleteNote moves a note to the trash with a tombstone signed over its current head.
+// Parameters:
+// - noteID: the ID of the note
+// Returns: the receipt of the server for the tombstone block, or an error if the note cannot be fetched or deleted
+func (c *Client) DeleteNote(noteID string) (*models.Receipt, error) {
+	note, err := c.GetNote(noteID)
+	if err != nil {
+		return nil, err
+	}
+	return c.appendLifecycle(http.MethodDelete, "/notes/delete", models.BlockKindTombstone, noteID, note.HeadHash)
+}
+
+// Trash lists the titles of the notes inside the trash, decrypted.
+// Returns: the titles, most recently deleted first, or an error if the request failed or a title cannot be decrypted
+func (c *Client) Trash() ([]*NoteTitle, error) {
+	if c.Keys == nil {
+		return nil, ErrNotLoggedIn
+	}
+
+	var trash []*models.TrashedTitle
+	if _, err := c.do(http.MethodGet, "/notes/trash", nil, &trash); err != nil {
+		return nil, err
+	}
+
+	titles := make([]*NoteTitle, 0, len(trash))
+	for _, encrypted := range trash {
+		title, err := DecryptTitle(c.Keys, &encrypted.Title)
+		if err != nil {
+			return nil, err
+		}
+		titles = append(titles, &NoteTitle{
+			NoteID:    encrypted.NoteID,
+			Title:     title,
+			Timestamp: encrypted.Timestamp,
+			CreatedAt: encrypted.CreatedAt,
+			HeadHash:  encrypted.HeadHash,
+		})
+	}
+	return titles, nil
+}
+
+// RestoreNote moves a note out of the trash with a restore signed over its tombstone.
+// Parameters:
+// - noteID: the ID of the note
+// Returns: the receipt of the server for the restore block, or an error if the note is not inside the trash or
+// cannot be restored
+func (c *Client) RestoreNote(noteID string) (*models.Receipt, error) {
+	trash, err := c.Trash()
+	if err != nil {
+		return nil, err
+	}
+	for _, title := range trash {
+		if title.NoteID == noteID {
+			return c.appendLifecycle(http.MethodPost, "/notes/restore", models.BlockKindRestore, noteID, title.HeadHash)
+		}
+	}
+	return nil, errors.New("the note is not inside the trash")
+}
+
+// appendLifecycle signs a tombstone or a restore over the head of a note and sends it.
+// Parameters:
+// - method: the HTTP method of the endpoint
+// - path: the path of the endpoint
+// - kind: models.BlockKindTombstone or models.BlockKindRestore
+// - noteID: the ID of the note
+// - headHash: the hash of the head block of the note
+// Returns: the receipt of the server, or an error if the request failed
+func (c *Client) appendLifecycle(method, path, kind, noteID, headHash string) (*models.Receipt, error) {
+	tombstone, err := SignLifecycle(c.Keys, kind, noteID, headHash)
+	if err != nil {
+		return nil, err
+	}
+
+	var response struct {
+		Receipt *models.Receipt `json:"receipt"`
+	}
+	if _, err := c.do(method, path, tombstone, &response); err != nil {
+		return nil, err
+	}
+	return response.Receipt, nil
+}
+
+// History fetches every version of a note with the status of its signature and time-stamp token.
+// Parameters:
+// - noteID: the ID of the note
+// Returns: the versions in chain order, or an error if the request failed
+func (c *Client) History(noteID string) ([]*models.BlockHistory, error) {
+	var history []*models.BlockHistory
+	if _, err := c.do(http.MethodPost, "/notes/history", noteRequest{NoteID: noteID}, &history); err != nil {
+		return nil, err
+	}
+	return history, nil
+}
+
+// Verify asks the server to check the chain, the signatures and the time-stamp tokens of a note.
+// Parameters:
+// - noteID: the ID of the note
+// Returns: the report of the server, or an error if the request failed
+func (c *Client) Verify(noteID string) (*models.NoteVerification, error) {
+	var report models.NoteVerification
+	if _, err := c.do(http.MethodPost, "/notes/verify", noteRequest{NoteID: noteID}, &report); err != nil {
+		return nil, err
+	}
+	return &report, nil
+}
+
+// Export downloads the vault archive of the user.
+// Parameters:
+// - w: the writer the tar.gz archive is copied to
+// Returns: an error if the download failed
+func (c *Client) Export(w io.Writer) error {
+	resp, err := c.send(http.MethodGet, "/notes/export", nil)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	_, err = io.Copy(w, resp.Body)
+	return err
+}
+
+// Vault downloads and parses the vault archive of the user, it holds the whole chain of every note.
+// Returns: a pointer to the archive, or an error if it cannot be downloaded or read
+func (c *Client) Vault() (*vault.Archive, error) {
+	resp, err := c.send(http.MethodGet, "/notes/export", nil)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	return vault.Read(resp.Body, vault.DefaultMaxSize)
+}
@@ -0,0 +1,40 @@
+package client
+
+import (
+	"backend/models"
+	"net/http"
+)
+
+// NotificationPreferences returns the security notification preferences of the user and their latest deliveries.
+// Returns: the preferences, without the webhook secret, the deliveries, or an error if the request fails
+func (c *Client) NotificationPreferences() (*models.NotificationPreferences, []*models.NotificationDelivery, error) {
+	var response struct {
+		Preferences *models.NotificationPreferences `json:"preferences"`
+		Deliveries  []*models.NotificationDelivery  `json:"deliveries"`
+	}
+	if _, err := c.do(http.MethodGet, "/auth/notifications", nil, &response); err != nil {
+		return nil, nil, err
+	}
+	return response.Preferences, response.Deliveries, nil
+}
+
+// SetNotificationPreferences replaces the security notification preferences of the user.
+// Parameters:
+// - prefs: the new preferences, their webhook secret is ignored
+// - rotateSecret: whether to generate a new webhook secret, one is also generated when the webhook URL changes
+// Returns: the new webhook secret, empty when it did not change, or an error if the preferences are refused
+func (c *Client) SetNotificationPreferences(prefs *models.NotificationPreferences, rotateSecret bool) (string, error) {
+	request := map[string]any{
+		"email_enabled":         prefs.EmailEnabled,
+		"webhook_url":           prefs.WebhookURL,
+		"muted_events":          prefs.MutedEvents,
+		"rotate_webhook_secret": rotateSecret,
+	}
+	var response struct {
+		Preferences models.NotificationPreferences `json:"preferences"`
+	}
+	if _, err := c.do(http.MethodPut, "/auth/notifications/update", request, &response); err != nil {
+		return "", err
+	}
+	return response.Preferences.WebhookSecret, nil
+}
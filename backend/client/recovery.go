@@ -0,0 +1,231 @@
+package client
+
+import (
+	"backend/crypto"
+	"backend/models"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hkdf"
+	"crypto/mldsa"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"net/http"
+	"strings"
+
+	"golang.org/x/crypto/ed25519"
+)
+
+// HKDF info of the keys derived from a recovery key
+const recoverySigningInfo = "recovery-signing"
+
+// ErrInvalidKeyEnvelope is returned when a key envelope cannot be opened, the recovery key or the password is wrong
+var ErrInvalidKeyEnvelope = errors.New("the keys cannot be opened, wrong recovery key or password")
+
+// GenerateRecoveryKey generates an offline recovery key, the user writes it down and keeps it away from the server.
+// Returns: the Base64 recovery key, or an error if the random generator fails
+func GenerateRecoveryKey() (string, error) {
+	return crypto.GenerateSaltBase64(crypto.RecoveryKeySize)
+}
+
+// recoveryKeys derives the Ed25519 key and the escrow key of a recovery key.
+// Parameters:
+// - recoveryKeyBase64: the Base64 recovery key
+// Returns: the signing key and the key sealing the escrow, or an error if the recovery key is malformed
+func recoveryKeys(recoveryKeyBase64 string) (ed25519.PrivateKey, []byte, error) {
+	recoveryKey, err := base64.StdEncoding.DecodeString(recoveryKeyBase64)
+	if err != nil || len(recoveryKey) != crypto.RecoveryKeySize {
+		return nil, nil, errors.New("invalid recovery key")
+	}
+	seed, err := hkdf.Key(sha256.New, recoveryKey, nil, recoverySigningInfo, ed25519.SeedSize)
+	if err != nil {
+		return nil, nil, err
+	}
+	escrowKey, err := hkdf.Key(sha256.New, recoveryKey, nil, crypto.KeyEnvelopeEscrow, 32)
+	if err != nil {
+		return nil, nil, err
+	}
+	return ed25519.NewKeyFromSeed(seed), escrowKey, nil
+}
+
+// passwordWrappingKey derives the key sealing the wrapped keys of a recovered account from its password
+func passwordWrappingKey(password, encryptionSaltBase64 string) ([]byte, error) {
+	secret, err := derive(sha256.New, password, encryptionSaltBase64, 32)
+	if err != nil {
+		return nil, err
+	}
+	return hkdf.Key(sha256.New, secret, nil, crypto.KeyEnvelopePassword, 32)
+}
+
+// sealKeys seals the encryption and HMAC keys of a user into a key envelope, see crypto.KeyEnvelopeSize.
+// Parameters:
+// - wrappingKey: the 256 bit key sealing the envelope
+// - purpose: crypto.KeyEnvelopeEscrow or crypto.KeyEnvelopePassword, the additional data of the envelope
+// - keys: the keys of the user
+// Returns: the Base64 envelope, or an error if the random generator fails
+func sealKeys(wrappingKey []byte, purpose string, keys *Keys) (string, error) {
+	aead, err := envelopeAEAD(wrappingKey)
+	if err != nil {
+		return "", err
+	}
+	nonce := make([]byte, crypto.KeyEnvelopeNonceSize)
+	if _, err := rand.Read(nonce); err != nil {
+		return "", err
+	}
+	plaintext := append(append([]byte{}, keys.EncryptionKey...), keys.HMACKey...)
+	return base64.StdEncoding.EncodeToString(aead.Seal(nonce, nonce, plaintext, []byte(purpose))), nil
+}
+
+// openKeys opens a key envelope sealed by sealKeys.
+// Parameters:
+// - wrappingKey: the 256 bit key sealing the envelope
+// - purpose: the additional data the envelope was sealed with
+// - envelopeBase64: the Base64 envelope
+// - suite: a pointer to the suite of the user, giving the sizes of the keys
+// Returns: the encryption key and the HMAC key, or ErrInvalidKeyEnvelope if the envelope does not open
+func openKeys(wrappingKey []byte, purpose, envelopeBase64 string, suite *crypto.Suite) ([]byte, []byte, error) {
+	if crypto.ValidateKeyEnvelope(envelopeBase64, suite) != nil {
+		return nil, nil, ErrInvalidKeyEnvelope
+	}
+	envelope, _ := base64.StdEncoding.DecodeString(envelopeBase64)
+
+	aead, err := envelopeAEAD(wrappingKey)
+	if err != nil {
+		return nil, nil, err
+	}
+	nonce, sealed := envelope[:crypto.KeyEnvelopeNonceSize], envelope[crypto.KeyEnvelopeNonceSize:]
+	plaintext, err := aead.Open(nil, nonce, sealed, []byte(purpose))
+	if err != nil {
+		return nil, nil, ErrInvalidKeyEnvelope
+	}
+	return plaintext[:suite.Cipher.KeySize], plaintext[suite.Cipher.KeySize:], nil
+}
+
+// envelopeAEAD creates the AES-256-GCM cipher of the key envelopes
+func envelopeAEAD(wrappingKey []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(wrappingKey)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// recoveryEnrollment builds the recovery fields of a registration: a fresh recovery key, the encryption and HMAC
+// keys escrowed under it, and its signature of the identity of the account.
+// Parameters:
+// - keys: the keys of the new account
+// Returns: the Base64 recovery key and the fields to add to the registration, or an error if the generation fails
+func recoveryEnrollment(keys *Keys) (string, map[string]string, error) {
+	recoveryKey, err := GenerateRecoveryKey()
+	if err != nil {
+		return "", nil, err
+	}
+	signingKey, escrowKey, err := recoveryKeys(recoveryKey)
+	if err != nil {
+		return "", nil, err
+	}
+	escrow, err := sealKeys(escrowKey, crypto.KeyEnvelopeEscrow, keys)
+	if err != nil {
+		return "", nil, err
+	}
+
+	recoveryPublicKey := base64.StdEncoding.EncodeToString(signingKey.Public().(ed25519.PublicKey))
+	payload := crypto.RecoveryEnrollmentPayload(keys.PublicKey(), recoveryPublicKey, escrow)
+	return recoveryKey, map[string]string{
+		"recovery_public_key": recoveryPublicKey,
+		"recovery_escrow":     escrow,
+		"recovery_signature":  base64.StdEncoding.EncodeToString(ed25519.Sign(signingKey, payload)),
+	}, nil
+}
+
+// Recover re-keys an account to a new password with its offline recovery key and leaves the client logged out.
+// The escrowed encryption and HMAC keys are opened with the recovery key and sealed under the new password,
+// so the notes stay readable.
+// Parameters:
+// - email: the email of the user
+// - recoveryKey: the Base64 recovery key returned by RegisterWithRecovery
+// - password: the new password
+// Returns: ErrInvalidKeyEnvelope if the recovery key does not open the escrow, or an error if the server refused
+// the recovery
+func (c *Client) Recover(email, recoveryKey, password string) error {
+	var start struct {
+		PublicKey      string `json:"public_key"`
+		EncryptionSalt string `json:"encryption_salt"`
+		HMACSalt       string `json:"hmac_salt"`
+		HMACType       string `json:"hmac_type"`
+		EncryptionType string `json:"encryption_type"`
+		SuiteID        uint16 `json:"suite_id"`
+		SignatureType  string `json:"signature_type"`
+		RecoveryEscrow string `json:"recovery_escrow"`
+	}
+	if _, err := c.do(http.MethodPost, "/auth/recover/start", map[string]string{"email": email}, &start); err != nil {
+		return err
+	}
+
+	recoverySigningKey, escrowKey, err := recoveryKeys(recoveryKey)
+	if err != nil {
+		return err
+	}
+	suite, err := crypto.SuiteOf(&models.User{SuiteID: start.SuiteID, EncryptionType: start.EncryptionType, HMACType: start.HMACType})
+	if err != nil {
+		return err
+	}
+	encryptionKey, hmacKey, err := openKeys(escrowKey, crypto.KeyEnvelopeEscrow, start.RecoveryEscrow, suite)
+	if err != nil {
+		return err
+	}
+
+	// The keys of the notes are sealed under the new password, the login key is derived from it as usual
+	wrappingKey, err := passwordWrappingKey(password, start.EncryptionSalt)
+	if err != nil {
+		return err
+	}
+	wrappedKeys, err := sealKeys(wrappingKey, crypto.KeyEnvelopePassword, &Keys{EncryptionKey: encryptionKey, HMACKey: hmacKey})
+	if err != nil {
+		return err
+	}
+
+	salt := make([]byte, 32)
+	if _, err := rand.Read(salt); err != nil {
+		return err
+	}
+	loginSalt := base64.StdEncoding.EncodeToString(salt)
+	signingKey, err := DeriveSigningKey(password, loginSalt, crypto.RecommendedKDF)
+	if err != nil {
+		return err
+	}
+	publicKey := base64.StdEncoding.EncodeToString(signingKey.Public().(ed25519.PublicKey))
+
+	var pqSigningKey *mldsa.PrivateKey
+	var pqPublicKey string
+	if crypto.IsHybridSignature(start.SignatureType) {
+		if pqSigningKey, err = DerivePQSigningKey(signingKey); err != nil {
+			return err
+		}
+		pqPublicKey = base64.StdEncoding.EncodeToString(pqSigningKey.PublicKey().Bytes())
+	}
+
+	// The server stores the emails in lowercase
+	email = strings.ToLower(email)
+	payload := crypto.RecoveryPayload(email, start.PublicKey, publicKey, pqPublicKey, loginSalt, crypto.RecommendedKDF, wrappedKeys)
+	request := map[string]any{
+		"email":         email,
+		"kdf":           crypto.RecommendedKDF,
+		"login_salt":    loginSalt,
+		"public_key":    publicKey,
+		"wrapped_keys":  wrappedKeys,
+		"signature":     base64.StdEncoding.EncodeToString(ed25519.Sign(recoverySigningKey, payload)),
+		"new_signature": base64.StdEncoding.EncodeToString(ed25519.Sign(signingKey, payload)),
+	}
+	if pqSigningKey != nil {
+		pqSignature, err := pqSigningKey.Sign(nil, payload, nil)
+		if err != nil {
+			return err
+		}
+		request["pq_public_key"] = pqPublicKey
+		request["pq_signature"] = base64.StdEncoding.EncodeToString(pqSignature)
+	}
+	_, err = c.do(http.MethodPost, "/auth/recover", request, nil)
+	return err
+}
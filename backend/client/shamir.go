@@ -0,0 +1,103 @@
+package client
+
+import (
+	"crypto/rand"
+	"errors"
+)
+
+// Shamir's secret sharing over GF(256), with the AES polynomial x^8 + x^4 + x^3 + x + 1.
+// Every byte of the secret is the constant term of its own random polynomial of degree threshold - 1, a share is
+// its x coordinate followed by the value of each polynomial at x.
+
+// gfMul multiplies two elements of GF(256)
+func gfMul(a, b byte) byte {
+	var product byte
+	for b > 0 {
+		if b&1 == 1 {
+			product ^= a
+		}
+		carry := a & 0x80
+		a <<= 1
+		if carry != 0 {
+			a ^= 0x1b
+		}
+		b >>= 1
+	}
+	return product
+}
+
+// gfInv inverts a non-zero element of GF(256), a^254 being a^-1
+func gfInv(a byte) byte {
+	inverse := byte(1)
+	for i := 0; i < 254; i++ {
+		inverse = gfMul(inverse, a)
+	}
+	return inverse
+}
+
+// splitSecret splits a secret into shares, any threshold of them rebuilding it.
+// Parameters:
+// - secret: the secret to split
+// - shares: the number of shares, at most 255
+// - threshold: the number of shares needed to rebuild the secret, at least 2
+// Returns: the shares, their x coordinates going from 1 to shares, or an error if the parameters are invalid
+func splitSecret(secret []byte, shares, threshold int) ([][]byte, error) {
+	if threshold < 2 || threshold > shares || shares > 255 {
+		return nil, errors.New("invalid number of shares or threshold")
+	}
+
+	coefficients := make([]byte, threshold-1)
+	result := make([][]byte, shares)
+	for i := range result {
+		result[i] = make([]byte, 1+len(secret))
+		result[i][0] = byte(i + 1)
+	}
+	for j, value := range secret {
+		if _, err := rand.Read(coefficients); err != nil {
+			return nil, err
+		}
+		for _, share := range result {
+			// Horner's rule, from the highest coefficient down to the secret byte
+			y := byte(0)
+			for k := len(coefficients) - 1; k >= 0; k-- {
+				y = gfMul(y, share[0]) ^ coefficients[k]
+			}
+			share[1+j] = gfMul(y, share[0]) ^ value
+		}
+	}
+	return result, nil
+}
+
+// combineShares rebuilds a secret from threshold of its shares by Lagrange interpolation at x = 0.
+// More shares than the threshold are fine, fewer rebuild a wrong secret without any error.
+// Parameters:
+// - shares: the shares, each one its x coordinate followed by the values
+// Returns: the secret, or an error if the shares are malformed or repeat an x coordinate
+func combineShares(shares [][]byte) ([]byte, error) {
+	if len(shares) < 2 {
+		return nil, errors.New("at least two shares are needed")
+	}
+	size := len(shares[0])
+	seen := map[byte]bool{}
+	for _, share := range shares {
+		if len(share) != size || size < 2 || share[0] == 0 || seen[share[0]] {
+			return nil, errors.New("invalid shares")
+		}
+		seen[share[0]] = true
+	}
+
+	secret := make([]byte, size-1)
+	for i, share := range shares {
+		// Lagrange basis polynomial of the share at 0: the product of xj / (xj - xi), subtraction being XOR
+		basis := byte(1)
+		for j, other := range shares {
+			if i != j {
+				basis = gfMul(basis, gfMul(other[0], gfInv(other[0]^share[0])))
+			}
+		}
+		for k := range secret {
+			secret[k] ^= gfMul(basis, share[1+k])
+		}
+	}
+	return secret, nil
+}
@@ -0,0 +1,221 @@
+package client
+
+import (
+	"backend/crypto"
+	"bytes"
+	"crypto/ed25519"
+	"crypto/rand"
+	"encoding/base64"
+	"testing"
+)
+
+// testSecret returns a random secret the size of a recovery key
+func testSecret(t *testing.T) []byte {
+	t.Helper()
+	secret := make([]byte, crypto.RecoveryKeySize)
+	if _, err := rand.Read(secret); err != nil {
+		t.Fatal(err)
+	}
+	return secret
+}
+
+// subsets calls fn with every subset of k of the n shares
+func subsets(shares [][]byte, k int, fn func([][]byte)) {
+	var walk func(start int, chosen [][]byte)
+	walk = func(start int, chosen [][]byte) {
+		if len(chosen) == k {
+			fn(chosen)
+			return
+		}
+		for i := start; i < len(shares); i++ {
+			walk(i+1, append(chosen[:len(chosen):len(chosen)], shares[i]))
+		}
+	}
+	walk(0, nil)
+}
+
+func TestGFInverse(t *testing.T) {
+	for a := 1; a < 256; a++ {
+		if product := gfMul(byte(a), gfInv(byte(a))); product != 1 {
+			t.Errorf("%d times its inverse is %d", a, product)
+		}
+	}
+}
+
+func TestShamirRoundTrip(t *testing.T) {
+	tests := []struct{ threshold, shares int }{
+		{2, 2}, {2, 3}, {3, 3}, {3, 5}, {4, 7}, {5, 5}, {2, 255},
+	}
+
+	for _, tt := range tests {
+		secret := testSecret(t)
+		shares, err := splitSecret(secret, tt.shares, tt.threshold)
+		if err != nil {
+			t.Fatalf("(%d,%d): %v", tt.threshold, tt.shares, err)
+		}
+		if len(shares) != tt.shares {
+			t.Fatalf("(%d,%d): got %d shares", tt.threshold, tt.shares, len(shares))
+		}
+		for i, share := range shares {
+			if len(share) != crypto.RecoveryShareSize || share[0] != byte(i+1) {
+				t.Fatalf("(%d,%d): share %d has x %d and %d bytes", tt.threshold, tt.shares, i, share[0], len(share))
+			}
+		}
+
+		// Every subset of threshold shares rebuilds the secret, the 255 shares only sample a few of them
+		if tt.shares <= 7 {
+			subsets(shares, tt.threshold, func(chosen [][]byte) {
+				got, err := combineShares(chosen)
+				if err != nil {
+					t.Fatalf("(%d,%d): %v", tt.threshold, tt.shares, err)
+				}
+				if !bytes.Equal(got, secret) {
+					t.Errorf("(%d,%d): shares %v rebuilt a wrong secret", tt.threshold, tt.shares, xCoordinates(chosen))
+				}
+			})
+		} else {
+			for _, chosen := range [][][]byte{shares[:2], shares[253:], {shares[0], shares[254]}} {
+				got, err := combineShares(chosen)
+				if err != nil || !bytes.Equal(got, secret) {
+					t.Errorf("(%d,%d): shares %v did not rebuild the secret", tt.threshold, tt.shares, xCoordinates(chosen))
+				}
+			}
+		}
+
+		// More shares than the threshold rebuild it too, in any order
+		reversed := make([][]byte, len(shares))
+		for i, share := range shares {
+			reversed[len(shares)-1-i] = share
+		}
+		if got, err := combineShares(reversed); err != nil || !bytes.Equal(got, secret) {
+			t.Errorf("(%d,%d): all the shares did not rebuild the secret", tt.threshold, tt.shares)
+		}
+	}
+}
+
+func TestShamirTooFewShares(t *testing.T) {
+	secret := testSecret(t)
+	shares, err := splitSecret(secret, 5, 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Two shares of a threshold of three interpolate a line that has nothing to do with the secret
+	subsets(shares, 2, func(chosen [][]byte) {
+		got, err := combineShares(chosen)
+		if err != nil {
+			t.Fatalf("shares %v: %v", xCoordinates(chosen), err)
+		}
+		if bytes.Equal(got, secret) {
+			t.Errorf("shares %v rebuilt the secret below the threshold", xCoordinates(chosen))
+		}
+	})
+
+	if _, err := combineShares(shares[:1]); err == nil {
+		t.Error("a single share was combined")
+	}
+	if _, err := combineShares(nil); err == nil {
+		t.Error("no share was combined")
+	}
+}
+
+func TestShamirRejectsInvalidShares(t *testing.T) {
+	shares, err := splitSecret(testSecret(t), 4, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	duplicate := append([]byte{}, shares[2]...)
+	duplicate[0] = shares[0][0]
+	zero := append([]byte{}, shares[1]...)
+	zero[0] = 0
+
+	tests := []struct {
+		name   string
+		shares [][]byte
+	}{
+		{"duplicate share", [][]byte{shares[0], shares[1], shares[0]}},
+		{"duplicate share index", [][]byte{shares[0], duplicate}},
+		{"share index zero", [][]byte{shares[0], zero}},
+		{"shares of different sizes", [][]byte{shares[0], shares[1][:len(shares[1])-1]}},
+		{"shares without values", [][]byte{shares[0][:1], shares[1][:1]}},
+	}
+	for _, tt := range tests {
+		if _, err := combineShares(tt.shares); err == nil {
+			t.Errorf("%s: the shares were combined", tt.name)
+		}
+	}
+}
+
+func TestShamirRejectsInvalidParameters(t *testing.T) {
+	tests := []struct{ shares, threshold int }{
+		{3, 1}, {3, 0}, {2, 3}, {256, 2},
+	}
+	for _, tt := range tests {
+		if _, err := splitSecret(testSecret(t), tt.shares, tt.threshold); err == nil {
+			t.Errorf("%d shares with a threshold of %d were accepted", tt.shares, tt.threshold)
+		}
+	}
+}
+
+func TestSealedShareRoundTrip(t *testing.T) {
+	publicKey, privateKey, err := ed25519.GenerateKey(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	recipient, err := x25519PublicKey(base64.StdEncoding.EncodeToString(publicKey))
+	if err != nil {
+		t.Fatal(err)
+	}
+	recipientKey, err := x25519PrivateKey(privateKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	shares, err := splitSecret(testSecret(t), 3, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sealed, err := sealShare(recipient, shares[1])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := crypto.ValidateSealedShare(sealed); err != nil {
+		t.Fatalf("the sealed share does not have the size the server expects: %v", err)
+	}
+
+	opened, err := openShare(recipientKey, sealed)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(opened, shares[1]) {
+		t.Error("the opened share differs from the sealed one")
+	}
+
+	// The share only opens for its recipient, and not once modified or truncated
+	_, otherKey, _ := ed25519.GenerateKey(nil)
+	other, err := x25519PrivateKey(otherKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := openShare(other, sealed); err != ErrInvalidShare {
+		t.Errorf("another key opened the share: %v", err)
+	}
+	raw, _ := base64.StdEncoding.DecodeString(sealed)
+	raw[len(raw)-1] ^= 1
+	if _, err := openShare(recipientKey, base64.StdEncoding.EncodeToString(raw)); err != ErrInvalidShare {
+		t.Errorf("a modified share opened: %v", err)
+	}
+	if _, err := openShare(recipientKey, base64.StdEncoding.EncodeToString(raw[:len(raw)-1])); err != ErrInvalidShare {
+		t.Errorf("a truncated share opened: %v", err)
+	}
+}
+
+// xCoordinates lists the x coordinates of shares, for the error messages
+func xCoordinates(shares [][]byte) []byte {
+	xs := make([]byte, len(shares))
+	for i, share := range shares {
+		xs[i] = share[0]
+	}
+	return xs
+}
@@ -0,0 +1,361 @@
+package client
+
+import (
+	"backend/crypto"
+	"backend/models"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/ecdh"
+	"crypto/hkdf"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"filippo.io/edwards25519"
+	"golang.org/x/crypto/ed25519"
+)
+
+var (
+	// ErrRecoveryNotReleased is returned while the shares of a social recovery are not released yet, the delay is
+	// running or too few contacts submitted their share
+	ErrRecoveryNotReleased = errors.New("the shares of the recovery are not released yet")
+	// ErrInvalidShare is returned when a sealed share cannot be opened
+	ErrInvalidShare = errors.New("the share cannot be opened")
+)
+
+// SocialRecovery is a social recovery started by the client, kept until the shares are released.
+// Fields:
+// - Email: the email of the account being recovered
+// - RequestID: the secret ID of the recovery
+// - EphemeralKey: the X25519 key the contacts seal their shares to, it never leaves the process
+// - ReleaseAt: when the shares are released, if enough contacts submitted theirs
+type SocialRecovery struct {
+	Email        string
+	RequestID    string
+	EphemeralKey *ecdh.PrivateKey
+	ReleaseAt    time.Time
+}
+
+// EphemeralPublicKey returns the Base64 X25519 public key of the recovery, the contacts check it with the
+// requester before submitting their share
+func (s *SocialRecovery) EphemeralPublicKey() string {
+	return base64.StdEncoding.EncodeToString(s.EphemeralKey.PublicKey().Bytes())
+}
+
+// x25519PublicKey converts an Ed25519 public key to the X25519 key of the same secret, see RFC 7748
+func x25519PublicKey(publicKeyBase64 string) (*ecdh.PublicKey, error) {
+	publicKey, err := base64.StdEncoding.DecodeString(publicKeyBase64)
+	if err != nil || len(publicKey) != ed25519.PublicKeySize {
+		return nil, errors.New("invalid public key")
+	}
+	point, err := new(edwards25519.Point).SetBytes(publicKey)
+	if err != nil {
+		return nil, errors.New("invalid public key")
+	}
+	return ecdh.X25519().NewPublicKey(point.BytesMontgomery())
+}
+
+// x25519PrivateKey converts an Ed25519 private key to X25519, the scalar being the clamped first half of the
+// SHA-512 of the seed like in Ed25519
+func x25519PrivateKey(signingKey ed25519.PrivateKey) (*ecdh.PrivateKey, error) {
+	digest := sha512.Sum512(signingKey.Seed())
+	return ecdh.X25519().NewPrivateKey(digest[:32])
+}
+
+// shareAEAD derives the AES-256-GCM cipher sealing a share from an X25519 exchange
+func shareAEAD(privateKey *ecdh.PrivateKey, publicKey *ecdh.PublicKey, ephemeralKey, recipientKey []byte) (cipher.AEAD, error) {
+	shared, err := privateKey.ECDH(publicKey)
+	if err != nil {
+		return nil, err
+	}
+	salt := append(append([]byte{}, ephemeralKey...), recipientKey...)
+	key, err := hkdf.Key(sha256.New, shared, salt, crypto.SealedShareInfo, 32)
+	if err != nil {
+		return nil, err
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// sealShare seals a share to an X25519 key, see crypto.SealedShareSize.
+// Every share is sealed under a key of its own, derived from a fresh ephemeral key, so the nonce is always zero.
+// Parameters:
+// - recipient: the X25519 key of the recipient
+// - share: the share
+// Returns: the Base64 sealed share, or an error if the random generator fails
+func sealShare(recipient *ecdh.PublicKey, share []byte) (string, error) {
+	ephemeral, err := ecdh.X25519().GenerateKey(nil)
+	if err != nil {
+		return "", err
+	}
+	ephemeralKey := ephemeral.PublicKey().Bytes()
+	aead, err := shareAEAD(ephemeral, recipient, ephemeralKey, recipient.Bytes())
+	if err != nil {
+		return "", err
+	}
+	sealed := aead.Seal(ephemeralKey, make([]byte, aead.NonceSize()), share, nil)
+	return base64.StdEncoding.EncodeToString(sealed), nil
+}
+
+// openShare opens a share sealed by sealShare.
+// Parameters:
+// - recipient: the X25519 key the share was sealed to
+// - sealedBase64: the Base64 sealed share
+// Returns: the share, or ErrInvalidShare if it does not open
+func openShare(recipient *ecdh.PrivateKey, sealedBase64 string) ([]byte, error) {
+	if crypto.ValidateSealedShare(sealedBase64) != nil {
+		return nil, ErrInvalidShare
+	}
+	sealed, _ := base64.StdEncoding.DecodeString(sealedBase64)
+
+	ephemeralKey := sealed[:crypto.X25519KeySize]
+	ephemeral, err := ecdh.X25519().NewPublicKey(ephemeralKey)
+	if err != nil {
+		return nil, ErrInvalidShare
+	}
+	aead, err := shareAEAD(recipient, ephemeral, ephemeralKey, recipient.PublicKey().Bytes())
+	if err != nil {
+		return nil, ErrInvalidShare
+	}
+	share, err := aead.Open(nil, make([]byte, aead.NonceSize()), sealed[crypto.X25519KeySize:], nil)
+	if err != nil {
+		return nil, ErrInvalidShare
+	}
+	return share, nil
+}
+
+// DistributeShares splits a recovery key between trusted contacts, any threshold of them being able to rebuild
+// it. Each share is sealed to the login key of its contact, the previous shares are replaced.
+// Parameters:
+// - recoveryKey: the Base64 recovery key to split, empty to generate a new one replacing the current one
+// - threshold: the number of contacts needed to rebuild the recovery key, at least 2
+// - contactEmails: the emails of the contacts, one share each
+// Returns: the recovery key that was split, or an error if a contact is unknown or the server refused the shares
+func (c *Client) DistributeShares(recoveryKey string, threshold int, contactEmails []string) (string, error) {
+	if c.Keys == nil || c.User == nil {
+		return "", ErrNotLoggedIn
+	}
+
+	request := map[string]any{"threshold": threshold}
+	if recoveryKey == "" {
+		key, fields, err := recoveryEnrollment(c.Keys)
+		if err != nil {
+			return "", err
+		}
+		// The login key vouches for the new recovery key, a session alone cannot replace it
+		loginKey, err := c.loginSigningKey()
+		if err != nil {
+			return "", err
+		}
+		payload := crypto.RecoveryEnrollmentPayload(c.User.PubKey, fields["recovery_public_key"], fields["recovery_escrow"])
+		for name, value := range fields {
+			request[name] = value
+		}
+		request["signature"] = base64.StdEncoding.EncodeToString(ed25519.Sign(loginKey, payload))
+		recoveryKey = key
+	}
+
+	secret, err := base64.StdEncoding.DecodeString(recoveryKey)
+	if err != nil || len(secret) != crypto.RecoveryKeySize {
+		return "", errors.New("invalid recovery key")
+	}
+	parts, err := splitSecret(secret, len(contactEmails), threshold)
+	if err != nil {
+		return "", err
+	}
+
+	shares := make([]map[string]any, len(contactEmails))
+	for i, email := range contactEmails {
+		var contact struct {
+			UserID    uint32 `json:"user_id"`
+			PublicKey string `json:"public_key"`
+		}
+		if _, err := c.do(http.MethodPost, "/auth/recovery/contact", map[string]string{"email": email}, &contact); err != nil {
+			return "", err
+		}
+		recipient, err := x25519PublicKey(contact.PublicKey)
+		if err != nil {
+			return "", fmt.Errorf("contact %s: %v", email, err)
+		}
+		sealed, err := sealShare(recipient, parts[i])
+		if err != nil {
+			return "", err
+		}
+		shares[i] = map[string]any{
+			"contact_id":      contact.UserID,
+			"share_index":     parts[i][0],
+			"contact_key":     contact.PublicKey,
+			"encrypted_share": sealed,
+		}
+	}
+	request["shares"] = shares
+
+	if _, err := c.do(http.MethodPost, "/auth/recovery/shares/distribute", request, nil); err != nil {
+		return "", err
+	}
+	return recoveryKey, nil
+}
+
+// RecoveryShares lists the shares the user distributed, without their content.
+// Returns: the shares, the stale ones sealed to a key their contact no longer has, or an error if the request failed
+func (c *Client) RecoveryShares() ([]*models.RecoveryShare, error) {
+	var shares []*models.RecoveryShare
+	if _, err := c.do(http.MethodGet, "/auth/recovery/shares", nil, &shares); err != nil {
+		return nil, err
+	}
+	return shares, nil
+}
+
+// HeldShares lists the shares the user holds for other users, with their pending recoveries.
+// Returns: the shares, or an error if the request failed
+func (c *Client) HeldShares() ([]*models.HeldShare, error) {
+	var shares []*models.HeldShare
+	if _, err := c.do(http.MethodGet, "/auth/recovery/held", nil, &shares); err != nil {
+		return nil, err
+	}
+	return shares, nil
+}
+
+// SubmitShare opens a share the user holds and seals it to the ephemeral key of a recovery of its owner.
+// The user must first check with the owner, out of band, that the ephemeral key is theirs.
+// Parameters:
+// - share: the share, from HeldShares
+// - request: the recovery, one of the requests of the share
+// Returns: ErrInvalidShare if the share was sealed to another key of the user, or an error if the server refused it
+func (c *Client) SubmitShare(share *models.HeldShare, request *models.RecoveryRequest) error {
+	if c.Keys == nil {
+		return ErrNotLoggedIn
+	}
+	loginKey, err := c.loginSigningKey()
+	if err != nil {
+		return err
+	}
+	if base64.StdEncoding.EncodeToString(loginKey.Public().(ed25519.PublicKey)) != share.ContactKey {
+		return ErrInvalidShare
+	}
+
+	privateKey, err := x25519PrivateKey(loginKey)
+	if err != nil {
+		return err
+	}
+	opened, err := openShare(privateKey, share.EncryptedShare)
+	if err != nil {
+		return err
+	}
+	ephemeralKey, err := base64.StdEncoding.DecodeString(request.EphemeralKey)
+	if err != nil {
+		return errors.New("invalid ephemeral key")
+	}
+	recipient, err := ecdh.X25519().NewPublicKey(ephemeralKey)
+	if err != nil {
+		return errors.New("invalid ephemeral key")
+	}
+	sealed, err := sealShare(recipient, opened)
+	if err != nil {
+		return err
+	}
+
+	_, err = c.do(http.MethodPost, "/auth/recovery/submit", map[string]string{
+		"request_id":      request.ID,
+		"encrypted_share": sealed,
+	}, nil)
+	return err
+}
+
+// StartSocialRecovery starts the social recovery of an account whose password and recovery key are lost.
+// The contacts holding the shares submit them once they checked the ephemeral key with the requester.
+// Parameters:
+// - email: the email of the account
+// Returns: the recovery to pass to FinishSocialRecovery once released, or an error if the server refused it
+func (c *Client) StartSocialRecovery(email string) (*SocialRecovery, error) {
+	ephemeral, err := ecdh.X25519().GenerateKey(nil)
+	if err != nil {
+		return nil, err
+	}
+	recovery := &SocialRecovery{Email: email, EphemeralKey: ephemeral}
+
+	var response struct {
+		RequestID string    `json:"request_id"`
+		ReleaseAt time.Time `json:"release_at"`
+	}
+	request := map[string]string{"email": email, "ephemeral_key": recovery.EphemeralPublicKey()}
+	if _, err := c.do(http.MethodPost, "/auth/recovery/social/start", request, &response); err != nil {
+		return nil, err
+	}
+	recovery.RequestID, recovery.ReleaseAt = response.RequestID, response.ReleaseAt
+	return recovery, nil
+}
+
+// FinishSocialRecovery rebuilds the recovery key from the released shares and re-keys the account to a new
+// password, like Recover.
+// Parameters:
+// - recovery: the recovery returned by StartSocialRecovery
+// - password: the new password
+// Returns: ErrRecoveryNotReleased while the shares are not released, or an error if the shares do not rebuild
+// the recovery key or the server refused the recovery
+func (c *Client) FinishSocialRecovery(recovery *SocialRecovery, password string) error {
+	var status struct {
+		models.RecoveryRequest
+		Submissions []*models.RecoverySubmission `json:"submissions"`
+	}
+	request := map[string]string{"request_id": recovery.RequestID}
+	if _, err := c.do(http.MethodPost, "/auth/recovery/social/status", request, &status); err != nil {
+		return err
+	}
+	if len(status.Submissions) == 0 {
+		return ErrRecoveryNotReleased
+	}
+
+	shares := make([][]byte, len(status.Submissions))
+	for i, submission := range status.Submissions {
+		share, err := openShare(recovery.EphemeralKey, submission.EncryptedShare)
+		if err != nil {
+			return err
+		}
+		if len(share) != crypto.RecoveryShareSize || share[0] != submission.ShareIndex {
+			return ErrInvalidShare
+		}
+		shares[i] = share
+	}
+	recoveryKey, err := combineShares(shares)
+	if err != nil {
+		return err
+	}
+	return c.Recover(recovery.Email, base64.StdEncoding.EncodeToString(recoveryKey), password)
+}
+
+// RecoveryRequests lists the pending social recoveries of the account of the user.
+// Returns: the recoveries, or an error if the request failed
+func (c *Client) RecoveryRequests() ([]*models.RecoveryRequest, error) {
+	var requests []*models.RecoveryRequest
+	if _, err := c.do(http.MethodGet, "/auth/recovery/requests", nil, &requests); err != nil {
+		return nil, err
+	}
+	return requests, nil
+}
+
+// CancelRecovery cancels a pending social recovery of the account of the user, one they did not start.
+// Parameters:
+// - requestID: the ID of the recovery
+// Returns: an error if the recovery does not exist or is no longer pending
+func (c *Client) CancelRecovery(requestID string) error {
+	_, err := c.do(http.MethodPost, "/auth/recovery/requests/cancel", map[string]string{"request_id": requestID}, nil)
+	return err
+}
+
+// loginSigningKey returns the login key of the user, the shares are sealed to it and it vouches for a new
+// recovery key. A client signing with a device key since UseDevice no longer holds it.
+// Returns: the login key, or an error if the client signs with a device key
+func (c *Client) loginSigningKey() (ed25519.PrivateKey, error) {
+	if c.User == nil || c.Keys.PublicKey() != c.User.PubKey {
+		return nil, errors.New("the login key is needed, unlock the client with the password")
+	}
+	return c.Keys.SigningKey, nil
+}
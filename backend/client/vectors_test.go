@@ -0,0 +1,90 @@
+package client_test
+
+import (
+	"backend/client"
+	"backend/models"
+	"backend/testvectors"
+	"encoding/base64"
+	"testing"
+)
+
+// userKeys derives the keys of a user of the vectors and checks them against the expected values
+func userKeys(t *testing.T, user *testvectors.User) *client.Keys {
+	t.Helper()
+	keys, err := client.DeriveKeys(user.Password, &models.User{
+		LoginSalt:      user.LoginSalt,
+		EncryptionSalt: user.EncryptionSalt,
+		HMACSalt:       user.HMACSalt,
+		HMACType:       user.HMACType,
+		EncryptionType: user.EncryptionType,
+		KDF:            user.KDF,
+		SignatureType:  user.SignatureType,
+	})
+	if err != nil {
+		t.Fatalf("%s: deriving the keys: %v", user.Name, err)
+	}
+	if keys.Suite.ID != user.SuiteID {
+		t.Errorf("%s: got suite %d, want %d", user.Name, keys.Suite.ID, user.SuiteID)
+	}
+
+	derived := map[string][2]string{
+		"signing seed":   {base64.StdEncoding.EncodeToString(keys.SigningKey.Seed()), user.SigningSeed},
+		"public key":     {keys.PublicKey(), user.PublicKey},
+		"encryption key": {base64.StdEncoding.EncodeToString(keys.EncryptionKey), user.EncryptionKey},
+		"hmac key":       {base64.StdEncoding.EncodeToString(keys.HMACKey), user.HMACKey},
+		"ml-dsa key":     {keys.PQPublicKey(), user.PQPublicKey},
+	}
+	for name, values := range derived {
+		if values[0] != values[1] {
+			t.Errorf("%s: got %s %s, want %s", user.Name, name, values[0], values[1])
+		}
+	}
+	return keys
+}
+
+func TestBlockVectors(t *testing.T) {
+	vectors, err := testvectors.Load()
+	if err != nil {
+		t.Fatalf("loading the vectors: %v", err)
+	}
+
+	for _, user := range vectors.Users {
+		keys := userKeys(t, user)
+		for _, note := range user.Notes {
+			for i, version := range note.Versions {
+				want := version.Block
+				ivTitle, _ := base64.StdEncoding.DecodeString(want.IVTitle + want.NonceTitle)
+				ivBody, _ := base64.StdEncoding.DecodeString(want.IV + want.Nonce)
+
+				block, err := client.BuildBlock(keys, version.Title, version.Body, want.PrevHash, ivTitle, ivBody, want.Timestamp)
+				if err != nil {
+					t.Fatalf("%s version %d: %v", user.Name, i+1, err)
+				}
+				if *block != want {
+					t.Errorf("%s version %d: got block %+v, want %+v", user.Name, i+1, *block, want)
+				}
+
+				title, body, err := client.DecryptBlock(keys, &want)
+				if err != nil || title != version.Title || body != version.Body {
+					t.Errorf("%s version %d: decrypted %q %q (%v), want %q %q", user.Name, i+1, title, body, err, version.Title, version.Body)
+				}
+
+				tampered := want
+				tampered.Ciphertext = want.CipherTitle
+				tampered.Tag = want.TagTitle
+				if _, _, err := client.DecryptBlock(keys, &tampered); err != client.ErrInvalidMAC {
+					t.Errorf("%s version %d: a tampered block decrypted with %v", user.Name, i+1, err)
+				}
+			}
+
+			tombstone, err := client.SignLifecycleAt(keys, models.BlockKindTombstone, note.NoteID, note.Tombstone.HeadHash,
+				note.Tombstone.Timestamp)
+			if err != nil {
+				t.Fatalf("%s: signing the tombstone: %v", user.Name, err)
+			}
+			if *tombstone != *note.Tombstone {
+				t.Errorf("%s: got tombstone %+v, want %+v", user.Name, *tombstone, *note.Tombstone)
+			}
+		}
+	}
+}
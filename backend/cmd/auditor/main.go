@@ -0,0 +1,202 @@
+// Command auditor replays the transparency log and checks it against the blocks table.
+//
+// It recomputes every leaf hash and the root of every signed tree head, verifies the tree head
+// signatures with the server public key, and checks that every stored block is committed to the log
+// and that every committed block of a note that still exists is stored unchanged.
+//
+// Usage:
+//
+//	go run ./cmd/auditor -server-key <base64 Ed25519 public key>
+//
+// The database connection is configured with the same MYSQL_* variables as the server.
+package main
+
+import (
+	"backend/config"
+	"backend/crypto"
+	"backend/db"
+	"backend/models"
+	"bytes"
+	"encoding/base64"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+)
+
+// batchSize is the number of log entries read per query
+const batchSize = 1000
+
+// blockKey identifies a block inside the log and the blocks table
+type blockKey struct {
+	noteID string
+	seq    uint
+}
+
+// auditor accumulates the problems found while replaying the log
+type auditor struct {
+	problems int
+}
+
+// report prints a problem and counts it
+func (a *auditor) report(format string, args ...any) {
+	a.problems++
+	fmt.Printf("FAIL: "+format+"\n", args...)
+}
+
+func main() {
+	serverKey := flag.String("server-key", "", "Base64 Ed25519 public key of the server, published at /.well-known/server-keys")
+	flag.Parse()
+
+	db.InitDB(config.LoadDbConfig())
+	defer db.CloseDB()
+
+	repo := db.NewTransparencyRepository(db.GetDB())
+	a := &auditor{}
+
+	// 1. Replay the log: consecutive indexes and leaf hashes matching their content
+	var hashes [][]byte
+	commitments := make(map[blockKey]string)
+	for start := 0; ; start += batchSize {
+		entries, err := repo.GetEntries(start, start+batchSize)
+		if err != nil {
+			log.Fatalf("Error reading log entries: %v", err)
+		}
+		for _, entry := range entries {
+			if entry.Index != len(hashes) {
+				a.report("entry %d found at position %d, the log has a gap", entry.Index, len(hashes))
+			}
+			expected := crypto.TransparencyLeafHash(entry.NoteID, entry.Seq, entry.BlockHash)
+			if entry.LeafHash != expected {
+				a.report("entry %d has leaf hash %s, expected %s", entry.Index, entry.LeafHash, expected)
+			}
+			key := blockKey{entry.NoteID, entry.Seq}
+			if _, exists := commitments[key]; exists {
+				a.report("entry %d commits block %d of note %s a second time", entry.Index, entry.Seq, entry.NoteID)
+			}
+			commitments[key] = entry.BlockHash
+
+			hash, err := base64.StdEncoding.DecodeString(expected)
+			if err != nil {
+				log.Fatalf("Error decoding leaf hash: %v", err)
+			}
+			hashes = append(hashes, hash)
+		}
+		if len(entries) < batchSize {
+			break
+		}
+	}
+	fmt.Printf("Replayed %d log entries\n", len(hashes))
+
+	treeSize, err := repo.GetTreeSize()
+	if err != nil {
+		log.Fatalf("Error reading the log size: %v", err)
+	}
+	if treeSize != len(hashes) {
+		a.report("the log size is %d but %d entries were replayed", treeSize, len(hashes))
+	}
+
+	// 2. Every signed tree head must match the replayed log, which also proves the log only grew
+	heads, err := repo.GetTreeHeads()
+	if err != nil {
+		log.Fatalf("Error reading the signed tree heads: %v", err)
+	}
+	if *serverKey == "" {
+		fmt.Println("WARNING: no -server-key given, the tree head signatures are not verified")
+	}
+	var previous *models.SignedTreeHead
+	for _, sth := range heads {
+		checkTreeHead(a, sth, hashes, *serverKey)
+		if previous != nil && sth.Timestamp.Before(previous.Timestamp) {
+			a.report("tree head %d was signed before the smaller tree head %d", sth.TreeSize, previous.TreeSize)
+		}
+		previous = sth
+	}
+	fmt.Printf("Checked %d signed tree heads\n", len(heads))
+
+	// The entries appended since the last sequencing have no index yet, they still commit their blocks
+	pending, err := repo.GetPendingEntries()
+	if err != nil {
+		log.Fatalf("Error reading the pending log entries: %v", err)
+	}
+	for _, entry := range pending {
+		expected := crypto.TransparencyLeafHash(entry.NoteID, entry.Seq, entry.BlockHash)
+		if entry.LeafHash != expected {
+			a.report("pending entry of block %d of note %s has leaf hash %s, expected %s", entry.Seq, entry.NoteID, entry.LeafHash, expected)
+		}
+		key := blockKey{entry.NoteID, entry.Seq}
+		if _, exists := commitments[key]; exists {
+			a.report("pending entry commits block %d of note %s a second time", entry.Seq, entry.NoteID)
+		}
+		commitments[key] = entry.BlockHash
+	}
+	fmt.Printf("Found %d entries waiting to be sequenced\n", len(pending))
+
+	// 3. Every stored block must be committed to the log with its current hash
+	storedBlocks := 0
+	existingNotes := make(map[string]bool)
+	stored := make(map[blockKey]bool)
+	err = repo.ScanBlocks(func(noteID string, seq uint, block *models.Block) error {
+		storedBlocks++
+		existingNotes[noteID] = true
+		stored[blockKey{noteID, seq}] = true
+
+		blockHash, err := crypto.BlockHash(*block)
+		if err != nil {
+			return err
+		}
+		committed, ok := commitments[blockKey{noteID, seq}]
+		switch {
+		case !ok:
+			a.report("block %d of note %s is not committed to the log", seq, noteID)
+		case committed != blockHash:
+			a.report("block %d of note %s was changed after being committed", seq, noteID)
+		}
+		return nil
+	})
+	if err != nil {
+		log.Fatalf("Error reading the blocks: %v", err)
+	}
+	fmt.Printf("Checked %d stored blocks\n", storedBlocks)
+
+	// 4. A committed block may only be missing if its whole note was purged
+	for key := range commitments {
+		if existingNotes[key.noteID] && !stored[key] {
+			a.report("block %d of note %s is committed to the log but missing from the blocks", key.seq, key.noteID)
+		}
+	}
+
+	if a.problems > 0 {
+		fmt.Printf("Audit failed with %d problems\n", a.problems)
+		os.Exit(1)
+	}
+	fmt.Println("Audit passed")
+}
+
+// checkTreeHead checks a signed tree head against the replayed log.
+// Parameters:
+// - a: the auditor collecting the problems
+// - sth: the signed tree head to check
+// - hashes: the replayed leaf hashes
+// - serverKey: the Base64 public key of the server, empty to skip the signature check
+func checkTreeHead(a *auditor, sth *models.SignedTreeHead, hashes [][]byte, serverKey string) {
+	if sth.TreeSize > len(hashes) {
+		a.report("tree head %d is larger than the log, entries were removed", sth.TreeSize)
+		return
+	}
+
+	root, err := base64.StdEncoding.DecodeString(sth.RootHash)
+	if err != nil || !bytes.Equal(root, crypto.MerkleRoot(hashes[:sth.TreeSize])) {
+		a.report("tree head %d does not match the log, entries were rewritten", sth.TreeSize)
+	}
+
+	if serverKey != "" {
+		valid, err := crypto.VerifyTreeHead(serverKey, sth)
+		if err != nil {
+			log.Fatalf("Invalid server key: %v", err)
+		}
+		if !valid {
+			a.report("tree head %d has an invalid signature", sth.TreeSize)
+		}
+	}
+}
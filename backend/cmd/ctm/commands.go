@@ -0,0 +1,324 @@
+package main
+
+import (
+	"backend/client"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+	"text/tabwriter"
+	"time"
+)
+
+// defaultExportFile is the archive written by ctm export when no file is given
+const defaultExportFile = "canttouchme-vault.tar.gz"
+
+// errUsage is returned by a command called with invalid arguments, main prints its usage
+var errUsage = errors.New("invalid arguments")
+
+// noteIDArg returns the only argument of a command addressing a note
+func noteIDArg(args []string) (string, error) {
+	if len(args) != 1 {
+		return "", errUsage
+	}
+	return args[0], nil
+}
+
+// runLogin logs in and caches the session
+func runLogin(args []string) error {
+	flags := flag.NewFlagSet("login", flag.ContinueOnError)
+	server := flags.String("server", "http://localhost:3000", "URL of the CantTouchMe API")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	if flags.NArg() != 1 {
+		return errUsage
+	}
+
+	password, err := readPassword()
+	if err != nil {
+		return err
+	}
+
+	c := client.New(*server)
+	if err := c.Login(flags.Arg(0), password); err != nil {
+		return err
+	}
+	if err := saveSession(c); err != nil {
+		return fmt.Errorf("logged in, but the session cannot be saved: %v", err)
+	}
+
+	fmt.Printf("Logged in as %s\n", c.User.Email)
+	return nil
+}
+
+// runLogout ends the session and removes the session file
+func runLogout(args []string) error {
+	c, err := loadSession()
+	if errors.Is(err, client.ErrNotLoggedIn) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	// The cookie is forgotten locally even when the server cannot be reached
+	if err := c.Logout(); err != nil {
+		fmt.Fprintf(os.Stderr, "ctm logout: %v\n", err)
+	}
+	return removeSession()
+}
+
+// runList prints the notes, most recently modified first
+func runList(args []string) error {
+	c, err := unlockedSession()
+	if err != nil {
+		return err
+	}
+
+	titles, err := c.Titles()
+	if err != nil {
+		return err
+	}
+
+	table := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	for _, title := range titles {
+		fmt.Fprintf(table, "%s\t%s\t%s\n", title.NoteID, title.Timestamp.Local().Format(time.DateTime), title.Title)
+	}
+	return table.Flush()
+}
+
+// runCat prints the title and the body of a note
+func runCat(args []string) error {
+	noteID, err := noteIDArg(args)
+	if err != nil {
+		return err
+	}
+	c, err := unlockedSession()
+	if err != nil {
+		return err
+	}
+
+	note, err := c.GetNote(noteID)
+	if err != nil {
+		return err
+	}
+	fmt.Print(formatNote(note.Title, note.Body))
+	return nil
+}
+
+// runEdit opens a note in the editor and appends the result as a new block
+func runEdit(args []string) error {
+	noteID, err := noteIDArg(args)
+	if err != nil {
+		return err
+	}
+	c, err := unlockedSession()
+	if err != nil {
+		return err
+	}
+
+	note, err := c.GetNote(noteID)
+	if err != nil {
+		return err
+	}
+
+	original := formatNote(note.Title, note.Body)
+	edited, err := editText(original)
+	if err != nil {
+		return err
+	}
+	if edited == original {
+		fmt.Println("No changes")
+		return nil
+	}
+
+	title, body := parseNote(edited)
+	if title == "" {
+		return errors.New("the title cannot be empty, the note was not changed")
+	}
+
+	// The block extends the head that was edited, the server refuses it if the note changed in the meantime
+	receipt, err := c.AppendBlock(noteID, note.HeadHash, title, body)
+	if err != nil {
+		return err
+	}
+	if receipt != nil {
+		fmt.Printf("Saved version %d\n", receipt.Seq)
+	} else {
+		fmt.Println("Saved")
+	}
+	return nil
+}
+
+// runHistory prints every version of a note
+func runHistory(args []string) error {
+	noteID, err := noteIDArg(args)
+	if err != nil {
+		return err
+	}
+	c, err := loadSession()
+	if err != nil {
+		return err
+	}
+
+	history, err := c.History(noteID)
+	if err != nil {
+		return err
+	}
+
+	table := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(table, "SEQ\tTIMESTAMP\tHASH\tSIGNATURE\tTIME-STAMP TOKEN")
+	for _, entry := range history {
+		signature := "valid"
+		if !entry.ValidSignature {
+			signature = "INVALID"
+		}
+		token := "none"
+		if status := entry.TimestampToken; status != nil {
+			token = status.GenTime.Local().Format(time.DateTime) + " " + status.Authority
+			if !status.Verified {
+				token = "INVALID: " + status.Error
+			}
+		}
+		fmt.Fprintf(table, "%d\t%s\t%s\t%s\t%s\n", entry.Seq, entry.Timestamp.Local().Format(time.DateTime),
+			entry.BlockHash, signature, token)
+	}
+	return table.Flush()
+}
+
+// runVerify checks a note: the report of the server, then the head block against the keys of the user
+func runVerify(args []string) error {
+	noteID, err := noteIDArg(args)
+	if err != nil {
+		return err
+	}
+	c, err := unlockedSession()
+	if err != nil {
+		return err
+	}
+
+	report, err := c.Verify(noteID)
+	if err != nil {
+		return err
+	}
+
+	problems := report.Problems
+	// GetNote checks the signature and the MAC of the head block with the keys derived locally
+	note, err := c.GetNote(noteID)
+	switch {
+	case err != nil:
+		problems = append(problems, "the head block cannot be verified locally: "+err.Error())
+	case len(report.Blocks) == 0 || report.Blocks[len(report.Blocks)-1].BlockHash != note.HeadHash:
+		problems = append(problems, "the head block does not match the history reported by the server")
+	}
+
+	fmt.Printf("Note %s: %d versions\n", noteID, len(report.Blocks))
+	fmt.Printf("  chain:            %s\n", status(report.ChainValid))
+	fmt.Printf("  signatures:       %s\n", status(report.SignaturesValid))
+	fmt.Printf("  time-stamps:      %s\n", status(report.TimestampsValid))
+	fmt.Printf("  head, locally:    %s\n", status(len(problems) == len(report.Problems)))
+	for _, problem := range problems {
+		fmt.Printf("  - %s\n", problem)
+	}
+
+	if len(problems) > 0 {
+		return errors.New("the note failed verification")
+	}
+	return nil
+}
+
+// runExport downloads the vault archive
+func runExport(args []string) error {
+	if len(args) > 1 {
+		return errUsage
+	}
+	path := defaultExportFile
+	if len(args) == 1 {
+		path = args[0]
+	}
+
+	c, err := loadSession()
+	if err != nil {
+		return err
+	}
+
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0o600)
+	if err != nil {
+		return err
+	}
+	if err := c.Export(file); err != nil {
+		file.Close()
+		os.Remove(path)
+		return err
+	}
+	if err := file.Close(); err != nil {
+		return err
+	}
+
+	fmt.Printf("Exported the vault to %s\n", path)
+	return nil
+}
+
+// status formats the result of a check
+func status(ok bool) string {
+	if ok {
+		return "ok"
+	}
+	return "FAILED"
+}
+
+// formatNote lays out a note for the terminal and the editor: the title, an empty line, then the body
+func formatNote(title, body string) string {
+	return title + "\n\n" + body + "\n"
+}
+
+// parseNote reads back a note laid out by formatNote
+func parseNote(text string) (string, string) {
+	title, body, _ := strings.Cut(text, "\n")
+	body = strings.TrimPrefix(body, "\n")
+	return strings.TrimSpace(title), strings.TrimSuffix(body, "\n")
+}
+
+// editText opens a text in the editor of the user through a private temporary file
+func editText(text string) (string, error) {
+	file, err := os.CreateTemp("", "ctm-*.md")
+	if err != nil {
+		return "", err
+	}
+	defer os.Remove(file.Name())
+
+	if _, err := file.WriteString(text); err != nil {
+		file.Close()
+		return "", err
+	}
+	if err := file.Close(); err != nil {
+		return "", err
+	}
+
+	editor := os.Getenv("VISUAL")
+	if editor == "" {
+		editor = os.Getenv("EDITOR")
+	}
+	if strings.TrimSpace(editor) == "" {
+		editor = "vi"
+	}
+
+	// The editor may come with arguments, like "code --wait"
+	fields := strings.Fields(editor)
+	cmd := exec.Command(fields[0], append(fields[1:], file.Name())...)
+	cmd.Stdin = os.Stdin
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	if err := cmd.Run(); err != nil {
+		return "", fmt.Errorf("the editor failed: %v", err)
+	}
+
+	edited, err := os.ReadFile(file.Name())
+	if err != nil {
+		return "", err
+	}
+	return string(edited), nil
+}
@@ -0,0 +1,82 @@
+// Command ctm is a terminal client for CantTouchMe notes.
+//
+// The notes are decrypted, encrypted and signed locally with the keys derived from the password, exactly
+// like the web client does. The session cookie is cached in the session file so every command does not
+// need a new login, but the keys are derived again by every command and only ever live in memory.
+//
+// Usage:
+//
+//	ctm login [-server http://localhost:3000] <email>
+//	ctm ls
+//	ctm cat <note id>
+//	ctm edit <note id>
+//	ctm history <note id>
+//	ctm verify <note id>
+//	ctm export [file]
+//	ctm logout
+//
+// The password is read from the CTM_PASSWORD environment variable, or prompted on the terminal.
+// The session file is $CTM_SESSION, by default ctm/session.json inside the user configuration directory.
+// The editor is $VISUAL or $EDITOR, by default vi.
+package main
+
+import (
+	"backend/client"
+	"errors"
+	"fmt"
+	"os"
+)
+
+// command is a subcommand of ctm
+type command struct {
+	usage string
+	run   func(args []string) error
+}
+
+var commands = map[string]command{
+	"login":   {"login [-server url] <email>", runLogin},
+	"logout":  {"logout", runLogout},
+	"ls":      {"ls", runList},
+	"cat":     {"cat <note id>", runCat},
+	"edit":    {"edit <note id>", runEdit},
+	"history": {"history <note id>", runHistory},
+	"verify":  {"verify <note id>", runVerify},
+	"export":  {"export [file]", runExport},
+}
+
+// commandOrder is the order of the commands in the usage
+var commandOrder = []string{"login", "ls", "cat", "edit", "history", "verify", "export", "logout"}
+
+func main() {
+	if len(os.Args) < 2 {
+		usage()
+		os.Exit(2)
+	}
+
+	cmd, ok := commands[os.Args[1]]
+	if !ok {
+		usage()
+		os.Exit(2)
+	}
+
+	if err := cmd.run(os.Args[2:]); err != nil {
+		if errors.Is(err, errUsage) {
+			fmt.Fprintf(os.Stderr, "usage: ctm %s\n", cmd.usage)
+			os.Exit(2)
+		}
+		if errors.Is(err, client.ErrNotLoggedIn) {
+			fmt.Fprintln(os.Stderr, "ctm: the session expired or does not exist, run ctm login")
+		} else {
+			fmt.Fprintf(os.Stderr, "ctm %s: %v\n", os.Args[1], err)
+		}
+		os.Exit(1)
+	}
+}
+
+// usage prints the list of commands
+func usage() {
+	fmt.Fprintln(os.Stderr, "usage:")
+	for _, name := range commandOrder {
+		fmt.Fprintf(os.Stderr, "  ctm %s\n", commands[name].usage)
+	}
+}
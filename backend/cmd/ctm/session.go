@@ -0,0 +1,139 @@
+package main
+
+import (
+	"backend/client"
+	"backend/models"
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+)
+
+// session is what the session file holds: the cookie and the public record of the user, never a key
+type session struct {
+	Server string       `json:"server"`
+	Token  string       `json:"token"`
+	User   *models.User `json:"user"`
+}
+
+// sessionPath returns the path of the session file
+func sessionPath() (string, error) {
+	if path := os.Getenv("CTM_SESSION"); path != "" {
+		return path, nil
+	}
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "ctm", "session.json"), nil
+}
+
+// saveSession writes the session of a logged in client, readable by the user only
+func saveSession(c *client.Client) error {
+	path, err := sessionPath()
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o700); err != nil {
+		return err
+	}
+
+	data, err := json.MarshalIndent(session{Server: c.BaseURL, Token: c.Token, User: c.User}, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, data, 0o600)
+}
+
+// loadSession restores the client of the cached session, without its keys
+func loadSession() (*client.Client, error) {
+	path, err := sessionPath()
+	if err != nil {
+		return nil, err
+	}
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, client.ErrNotLoggedIn
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var cached session
+	if err := json.Unmarshal(data, &cached); err != nil {
+		return nil, fmt.Errorf("invalid session file %s: %v", path, err)
+	}
+	if cached.Token == "" || cached.User == nil {
+		return nil, client.ErrNotLoggedIn
+	}
+
+	c := client.New(cached.Server)
+	c.Token = cached.Token
+	c.User = cached.User
+	return c, nil
+}
+
+// removeSession deletes the session file
+func removeSession() error {
+	path, err := sessionPath()
+	if err != nil {
+		return err
+	}
+	if err := os.Remove(path); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+	return nil
+}
+
+// unlockedSession restores the cached session and derives its keys from the password
+func unlockedSession() (*client.Client, error) {
+	c, err := loadSession()
+	if err != nil {
+		return nil, err
+	}
+	password, err := readPassword()
+	if err != nil {
+		return nil, err
+	}
+	if err := c.Unlock(password); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+// readPassword reads the password from CTM_PASSWORD, or prompts for it without echoing it
+func readPassword() (string, error) {
+	if password := os.Getenv("CTM_PASSWORD"); password != "" {
+		return password, nil
+	}
+
+	fmt.Fprint(os.Stderr, "Password: ")
+	// Best effort: stty is missing on some systems and fails when the input is not a terminal
+	if echo(false) == nil {
+		defer func() {
+			echo(true)
+			fmt.Fprintln(os.Stderr)
+		}()
+	}
+
+	line, err := bufio.NewReader(os.Stdin).ReadString('\n')
+	if err != nil && line == "" {
+		return "", err
+	}
+	return strings.TrimRight(line, "\r\n"), nil
+}
+
+// echo turns the echo of the terminal on or off
+func echo(on bool) error {
+	mode := "-echo"
+	if on {
+		mode = "echo"
+	}
+	stty := exec.Command("stty", mode)
+	stty.Stdin = os.Stdin
+	return stty.Run()
+}
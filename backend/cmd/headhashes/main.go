@@ -0,0 +1,39 @@
+// Command headhashes computes the head hashes of the notes created by migration 002.
+//
+// Migration 002 moves the notes to the notes table with an empty head hash: the head hash is the hash of the JSON
+// encoding of the head block, which only crypto.BlockHash computes exactly. Run it right after migration 002 and
+// before migration 004, which builds the account logs from the head hashes.
+//
+// Usage:
+//
+//	go run ./cmd/headhashes [-dry-run]
+//
+// The database connection is configured with the same MYSQL_* variables as the server.
+package main
+
+import (
+	"backend/config"
+	"backend/db"
+	"flag"
+	"fmt"
+	"log"
+)
+
+func main() {
+	dryRun := flag.Bool("dry-run", false, "only count the notes without a head hash")
+	flag.Parse()
+
+	db.InitDB(config.LoadDbConfig())
+	defer db.CloseDB()
+
+	filled, err := db.NewBlockRepository(db.GetDB()).FillHeadHashes(*dryRun)
+	if err != nil {
+		log.Fatalf("Error computing the head hashes after %d notes: %v", filled, err)
+	}
+
+	if *dryRun {
+		fmt.Printf("%d notes have no head hash\n", filled)
+		return
+	}
+	fmt.Printf("Computed the head hash of %d notes\n", filled)
+}
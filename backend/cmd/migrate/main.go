@@ -0,0 +1,91 @@
+// Command migrate uploads the notes of another note taking tool to CantTouchMe.
+//
+// The notes are read from a plaintext export, then encrypted and signed locally with the keys derived
+// from the password, exactly like the web client does, and uploaded as new notes.
+//
+// Usage:
+//
+//	go run ./cmd/migrate -server http://localhost:3000 -email me@example.com -format joplin export.jex
+//
+// Formats: markdown (directory of .md/.txt files), joplin (RAW export directory or .jex archive),
+// standardnotes (decrypted backup file or zip export). The password is read from the CTM_PASSWORD
+// environment variable, or from the standard input.
+package main
+
+import (
+	"backend/client"
+	"backend/migrate"
+	"bufio"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+)
+
+func main() {
+	server := flag.String("server", "http://localhost:3000", "URL of the CantTouchMe API")
+	email := flag.String("email", "", "email of the account to upload the notes to")
+	format := flag.String("format", migrate.FormatMarkdown, "format of the export: markdown, joplin or standardnotes")
+	dryRun := flag.Bool("dry-run", false, "only list the notes that would be uploaded")
+	flag.Parse()
+
+	if flag.NArg() != 1 {
+		fmt.Fprintln(os.Stderr, "usage: migrate [flags] <export path>")
+		flag.PrintDefaults()
+		os.Exit(2)
+	}
+
+	notes, err := migrate.Read(*format, flag.Arg(0))
+	if err != nil {
+		log.Fatalf("Error reading the export: %v", err)
+	}
+	fmt.Printf("Read %d notes\n", len(notes))
+
+	if *dryRun {
+		for _, note := range notes {
+			fmt.Printf("%s (%d bytes) from %s\n", note.Title, len(note.Body), note.Source)
+		}
+		return
+	}
+
+	if *email == "" {
+		log.Fatal("-email is required")
+	}
+	password, err := readPassword()
+	if err != nil {
+		log.Fatalf("Error reading the password: %v", err)
+	}
+
+	c := client.New(*server)
+	if err := c.Login(*email, password); err != nil {
+		log.Fatalf("Login failed: %v", err)
+	}
+
+	uploaded := migrate.Upload(c, notes, func(note migrate.Note, noteID string, err error) {
+		if err != nil {
+			fmt.Printf("FAIL: %s: %v\n", note.Source, err)
+			return
+		}
+		fmt.Printf("OK: %s -> %s\n", note.Source, noteID)
+	})
+
+	fmt.Printf("Uploaded %d of %d notes\n", uploaded, len(notes))
+	if uploaded != len(notes) {
+		os.Exit(1)
+	}
+}
+
+// readPassword reads the password from CTM_PASSWORD, or the first line of the standard input
+func readPassword() (string, error) {
+	if password := os.Getenv("CTM_PASSWORD"); password != "" {
+		return password, nil
+	}
+
+	fmt.Fprint(os.Stderr, "Password: ")
+	line, err := bufio.NewReader(os.Stdin).ReadString('\n')
+	if err != nil && line == "" {
+		return "", err
+	}
+	return strings.TrimRight(line, "\r\n"), nil
+}
@@ -0,0 +1,37 @@
+// Command testvectors writes the cross-language crypto test vectors.
+//
+// Usage, from the backend directory:
+//
+//	go run ./cmd/testvectors
+//
+// It overwrites testvectors/crypto.json at the root of the repository. The vectors only change when the
+// protocol changes on purpose: every client has to be updated in the same change, and existing chains
+// may no longer verify.
+package main
+
+import (
+	"backend/testvectors"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+)
+
+func main() {
+	output := flag.String("o", testvectors.Path(), "file to write the vectors to")
+	flag.Parse()
+
+	vectors, err := testvectors.Generate()
+	if err != nil {
+		log.Fatalf("Error generating the vectors: %v", err)
+	}
+	data, err := testvectors.Encode(vectors)
+	if err != nil {
+		log.Fatalf("Error encoding the vectors: %v", err)
+	}
+	if err := os.WriteFile(*output, data, 0o644); err != nil {
+		log.Fatalf("Error writing the vectors: %v", err)
+	}
+
+	fmt.Printf("Wrote the vectors of %d users to %s\n", len(vectors.Users), *output)
+}
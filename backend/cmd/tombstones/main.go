@@ -0,0 +1,49 @@
+// Command tombstones appends the tombstones stored before migration 018 to the chains of their notes.
+//
+// Before migration 018 a deleted note kept its signed tombstone in the tombstones table, it is now a block of
+// the chain of the note. Every tombstone signed over the current head of a note inside the trash is appended as
+// a tombstone block and removed from the table. The other tombstones are left in the table and listed, the
+// table can be dropped once they have been reviewed.
+//
+// Usage:
+//
+//	go run ./cmd/tombstones [-dry-run]
+//
+// The database connection is configured with the same MYSQL_* variables as the server.
+package main
+
+import (
+	"backend/config"
+	"backend/db"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+)
+
+func main() {
+	dryRun := flag.Bool("dry-run", false, "only list the tombstones that would be appended or skipped")
+	flag.Parse()
+
+	db.InitDB(config.LoadDbConfig())
+	defer db.CloseDB()
+
+	migrated, skipped, err := db.NewTrashRepository(db.GetDB()).MigrateTombstones(*dryRun)
+	if err != nil {
+		log.Fatalf("Error migrating the tombstones after %d of them: %v", migrated, err)
+	}
+
+	for _, tombstone := range skipped {
+		fmt.Printf("SKIPPED: note %s of user %d, %s\n", tombstone.NoteID, tombstone.UserID, tombstone.Reason)
+	}
+	if *dryRun {
+		fmt.Printf("%d tombstones would be appended, %d skipped\n", migrated, len(skipped))
+		return
+	}
+	fmt.Printf("Appended %d tombstones to the chains of their notes, %d skipped\n", migrated, len(skipped))
+	if len(skipped) > 0 {
+		fmt.Println("The skipped tombstones are kept in the tombstones table, drop it once they have been reviewed")
+		os.Exit(1)
+	}
+	fmt.Println("The tombstones table is empty and can be dropped")
+}
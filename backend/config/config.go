@@ -1,5 +1,10 @@
 package config
 
+import (
+	"encoding/base64"
+	"fmt"
+)
+
 var cfg *Config
 
 // Config holds all configuration for our application
@@ -7,10 +12,46 @@ type Config struct {
 	Port                    int
 	Environment             string
 	JWTSecret               string
-	JWTExpiration           int // JWT expiration time in seconds
-	ChallengeCleanupMinutes int // Challenge cleanup interval in minutes
+	JWTExpiration           int      // JWT expiration time in seconds
+	ChallengeCleanupMinutes int      // Challenge cleanup interval in minutes
+	TrashRetentionDays      int      // How long a deleted note stays in the trash, in days
+	TrashPurgeMinutes       int      // Interval between two purges of the expired notes, in minutes
+	ServerSigningKey        string   // Base64 Ed25519 seed the server signs its receipts with
+	AllowEphemeralKey       bool     // Whether the server may start without SERVER_SIGNING_KEY, for development only
+	TreeHeadMinutes         int      // Interval between two signed tree heads of the transparency log, in minutes
+	TSAURL                  string   // URL of an RFC 3161 time-stamp authority, empty uses the bundled local authority
+	TSACAFile               string   // PEM file with the roots trusted for the tokens of the remote authority
+	TSAKey                  string   // Base64 PKCS#8 ECDSA P-256 key of the local time-stamp authority
+	TSAPolicyOID            string   // Policy OID written in the tokens of the local time-stamp authority
+	ImportMaxMB             int      // Maximum size of an imported vault archive, compressed and decompressed, in megabytes
+	TrustedServerKeys       []string // Base64 Ed25519 keys of the other servers whose vault archives can be imported
+	SocialRecoveryHours     int      // Delay before the shares of a social recovery are released, in hours
+	AppURL                  string   // URL of the frontend, the links of the emails point to it
+	Mailer                  string   // How the emails are sent: smtp, file or log
+	MailFrom                string   // Sender address of the emails
+	MailDir                 string   // Directory the file mailer writes the emails to
+	SMTPHost                string   // Host of the SMTP server of the smtp mailer
+	SMTPPort                int      // Port of the SMTP server
+	SMTPUsername            string   // User of the SMTP server, empty to send without authentication
+	SMTPPassword            string   // Password of the SMTP user
+	EmailTokenHours         int      // Validity of the links of the verification and email change emails, in hours
+	UnverifiedAccess        string   // What an account can do before verifying its email: full, read-only or none
+	NotifyMaxAttempts       int      // Attempts of a security notification before it is given up
+	NotifyRetryMinutes      int      // Interval between two runs of the retries of the notifications, in minutes
+	NotifyBackoffSeconds    int      // Wait before the first retry of a notification, doubled after every failure, in seconds
+	NotifyRetentionDays     int      // How long the sent and failed notifications are kept, in days
+	FailedSignatureBurst    int      // Failed login signatures within the window that are notified, 0 disables it
+	FailedSignatureMinutes  int      // Window of the failed login signatures, in minutes
+	WebhookAllowPrivate     bool     // Whether the webhooks may reach private addresses and use plain HTTP
 }
 
+// What an account can do before verifying its email, see Config.UnverifiedAccess
+const (
+	UnverifiedAccessFull     = "full"      // Everything, the verification is only informative
+	UnverifiedAccessReadOnly = "read-only" // Log in and read the notes, but not write them
+	UnverifiedAccessNone     = "none"      // Nothing, the login is refused until the email is verified
+)
+
 // LoadConfig loads the configuration from environment variables
 // Reurns - a pointer to Config struct
 func LoadConfig() *Config {
@@ -24,6 +65,35 @@ func LoadConfig() *Config {
 		JWTSecret:               getEnv("JWT_SECRET", "DEFAULT_JWT_DO_NOT_USE_IN_PRODUCTION"),
 		JWTExpiration:           getEnvAsInt("JWT_EXPIRATION_SECONDS", 3600),  // Default to 1 hour
 		ChallengeCleanupMinutes: getEnvAsInt("CHALLENGE_CLEANUP_MINUTES", 15), // Default to 15 minutes
+		TrashRetentionDays:      getEnvAsInt("TRASH_RETENTION_DAYS", 30),      // Default to 30 days
+		TrashPurgeMinutes:       getEnvAsInt("TRASH_PURGE_MINUTES", 60),       // Default to 1 hour
+		ServerSigningKey:        getEnv("SERVER_SIGNING_KEY", ""),             // Required unless ALLOW_EPHEMERAL_SERVER_KEY is set
+		AllowEphemeralKey:       getEnvAsBool("ALLOW_EPHEMERAL_SERVER_KEY", false),
+		TreeHeadMinutes:         getEnvAsInt("TREE_HEAD_MINUTES", 5),          // Default to 5 minutes
+		TSAURL:                  getEnv("TSA_URL", ""),                        // Empty uses the local authority
+		TSACAFile:               getEnv("TSA_CA_FILE", ""),                    // Empty uses the system roots
+		TSAKey:                  getEnv("TSA_KEY", ""),                        // Empty generates an ephemeral key
+		TSAPolicyOID:            getEnv("TSA_POLICY_OID", "1.2.3.4.1"),        // Default to the OpenSSL example policy
+		ImportMaxMB:             getEnvAsInt("IMPORT_MAX_MB", 64),             // Default to 64 MB
+		TrustedServerKeys:       getEnvAsList("TRUSTED_SERVER_KEYS"),          // Empty only trusts the archives of this server
+		SocialRecoveryHours:     getEnvAsInt("SOCIAL_RECOVERY_HOURS", 72),     // Default to 3 days
+		AppURL:                  getEnv("APP_URL", "http://localhost"),        // Default to the development frontend
+		Mailer:                  getEnv("MAILER", "log"),                      // Default to writing the emails to the log
+		MailFrom:                getEnv("MAIL_FROM", "no-reply@localhost"),    // Default to a local sender
+		MailDir:                 getEnv("MAIL_DIR", ""),                       // Only used by the file mailer
+		SMTPHost:                getEnv("SMTP_HOST", ""),                      // Only used by the smtp mailer
+		SMTPPort:                getEnvAsInt("SMTP_PORT", 587),                // Default to the submission port
+		SMTPUsername:            getEnv("SMTP_USERNAME", ""),                  // Empty sends without authentication
+		SMTPPassword:            getEnv("SMTP_PASSWORD", ""),                  // Only sent over TLS
+		EmailTokenHours:         getEnvAsInt("EMAIL_TOKEN_HOURS", 24),         // Default to 1 day
+		UnverifiedAccess:        getEnv("UNVERIFIED_ACCESS", "read-only"),     // Default to reading the notes only
+		NotifyMaxAttempts:       getEnvAsInt("NOTIFY_MAX_ATTEMPTS", 8),        // Default to retrying for about 2 hours
+		NotifyRetryMinutes:      getEnvAsInt("NOTIFY_RETRY_MINUTES", 1),       // Default to 1 minute
+		NotifyBackoffSeconds:    getEnvAsInt("NOTIFY_BACKOFF_SECONDS", 60),    // Default to 1 minute
+		NotifyRetentionDays:     getEnvAsInt("NOTIFY_RETENTION_DAYS", 30),     // Default to 30 days
+		FailedSignatureBurst:    getEnvAsInt("FAILED_SIGNATURE_BURST", 5),     // Default to 5 failures
+		FailedSignatureMinutes:  getEnvAsInt("FAILED_SIGNATURE_MINUTES", 15),  // Default to 15 minutes
+		WebhookAllowPrivate:     getEnvAsBool("WEBHOOK_ALLOW_PRIVATE", false), // Only for development
 	}
 
 	return cfg
@@ -36,3 +106,61 @@ func GetConfig() *Config {
 	}
 	return cfg
 }
+
+// Validate checks the settings a typo would otherwise only reveal at runtime, the cron intervals feed tickers
+// that panic when they are not positive, and a zero limit or delay would reject or expire everything.
+// Returns: an error describing the first invalid setting
+func (c *Config) Validate() error {
+	intervals := []struct {
+		name    string
+		minutes int
+	}{
+		{"CHALLENGE_CLEANUP_MINUTES", c.ChallengeCleanupMinutes},
+		{"TRASH_PURGE_MINUTES", c.TrashPurgeMinutes},
+		{"TREE_HEAD_MINUTES", c.TreeHeadMinutes},
+		{"NOTIFY_RETRY_MINUTES", c.NotifyRetryMinutes},
+	}
+	for _, interval := range intervals {
+		if interval.minutes <= 0 {
+			return fmt.Errorf("%s must be a positive number of minutes, got %d", interval.name, interval.minutes)
+		}
+	}
+	limits := []struct {
+		name  string
+		value int
+	}{
+		{"IMPORT_MAX_MB", c.ImportMaxMB},
+		{"SOCIAL_RECOVERY_HOURS", c.SocialRecoveryHours},
+		{"EMAIL_TOKEN_HOURS", c.EmailTokenHours},
+		{"NOTIFY_MAX_ATTEMPTS", c.NotifyMaxAttempts},
+		{"NOTIFY_BACKOFF_SECONDS", c.NotifyBackoffSeconds},
+		{"FAILED_SIGNATURE_MINUTES", c.FailedSignatureMinutes},
+	}
+	for _, limit := range limits {
+		if limit.value <= 0 {
+			return fmt.Errorf("%s must be positive, got %d", limit.name, limit.value)
+		}
+	}
+	if c.TrashRetentionDays < 0 {
+		return fmt.Errorf("TRASH_RETENTION_DAYS cannot be negative, got %d", c.TrashRetentionDays)
+	}
+
+	// The receipts and the signed tree heads of an ephemeral key can no longer be verified after a restart
+	if c.ServerSigningKey == "" && !c.AllowEphemeralKey {
+		return fmt.Errorf("SERVER_SIGNING_KEY must be set, or ALLOW_EPHEMERAL_SERVER_KEY enabled for development")
+	}
+
+	for _, key := range c.TrustedServerKeys {
+		decoded, err := base64.StdEncoding.DecodeString(key)
+		if err != nil || len(decoded) != 32 {
+			return fmt.Errorf("TRUSTED_SERVER_KEYS must list Base64 Ed25519 public keys, got %q", key)
+		}
+	}
+
+	switch c.UnverifiedAccess {
+	case UnverifiedAccessFull, UnverifiedAccessReadOnly, UnverifiedAccessNone:
+	default:
+		return fmt.Errorf("UNVERIFIED_ACCESS %q must be full, read-only or none", c.UnverifiedAccess)
+	}
+	return nil
+}
@@ -3,6 +3,7 @@ package config
 import (
 	"os"
 	"strconv"
+	"strings"
 )
 
 // function to get an environment variable with a default value
@@ -29,3 +30,30 @@ func getEnvAsInt(key string, defaultValue int) int {
 	}
 	return defaultValue
 }
+
+// function to get an environment variable as a boolean
+// Parameters:
+// - key: the name of the environment variable to retrieve
+// - defaultValue: the default boolean value to return if the environment variable is not set or is not a boolean
+// Returns: the boolean value of the environment variable if set and valid, otherwise the default boolean value
+func getEnvAsBool(key string, defaultValue bool) bool {
+	valueStr := getEnv(key, "")
+	if value, err := strconv.ParseBool(valueStr); err == nil {
+		return value
+	}
+	return defaultValue
+}
+
+// function to get an environment variable as a comma separated list
+// Parameters:
+// - key: the name of the environment variable to retrieve
+// Returns: the trimmed non empty items of the environment variable, nil if it is not set
+func getEnvAsList(key string) []string {
+	var items []string
+	for _, item := range strings.Split(getEnv(key, ""), ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
@@ -2,7 +2,12 @@ package cron
 
 import (
 	"backend/config"
+	"backend/crypto"
 	"backend/db"
+	"backend/models"
+	"backend/notify"
+	"encoding/base64"
+	"errors"
 	"log"
 	"time"
 )
@@ -49,6 +54,9 @@ func (cs *CronScheduler) Stop() {
 func (cs *CronScheduler) run() {
 	// Run cleanup immediately on startup
 	cs.cleanupExpiredChallenges()
+	cs.purgeExpiredNotes()
+	cs.signTreeHead()
+	cs.retryNotifications()
 
 	// Get cleanup interval from config
 	cfg := config.GetConfig()
@@ -60,10 +68,35 @@ func (cs *CronScheduler) run() {
 
 	log.Printf("Challenge cleanup cron job scheduled to run every %d minutes", cfg.ChallengeCleanupMinutes)
 
+	// The trash is purged on its own interval
+	purgeTicker := time.NewTicker(time.Duration(cfg.TrashPurgeMinutes) * time.Minute)
+	defer purgeTicker.Stop()
+
+	log.Printf("Trash purge cron job scheduled to run every %d minutes", cfg.TrashPurgeMinutes)
+
+	// A new tree head of the transparency log is signed on its own interval
+	treeHeadTicker := time.NewTicker(time.Duration(cfg.TreeHeadMinutes) * time.Minute)
+	defer treeHeadTicker.Stop()
+
+	log.Printf("Tree head signing cron job scheduled to run every %d minutes", cfg.TreeHeadMinutes)
+
+	// The failed security notifications are retried on their own interval
+	notifyTicker := time.NewTicker(time.Duration(cfg.NotifyRetryMinutes) * time.Minute)
+	defer notifyTicker.Stop()
+
+	log.Printf("Notification retry cron job scheduled to run every %d minutes", cfg.NotifyRetryMinutes)
+
 	for {
 		select {
 		case <-ticker.C:
 			cs.cleanupExpiredChallenges()
+			cs.purgeNotifications()
+		case <-purgeTicker.C:
+			cs.purgeExpiredNotes()
+		case <-treeHeadTicker.C:
+			cs.signTreeHead()
+		case <-notifyTicker.C:
+			cs.retryNotifications()
 		case <-cs.stopCh:
 			log.Println("Cron scheduler stopped")
 			return
@@ -84,3 +117,107 @@ func (cs *CronScheduler) cleanupExpiredChallenges() {
 
 	log.Println("Successfully cleaned up expired challenges")
 }
+
+// purgeExpiredNotes permanently deletes the notes that stayed in the trash longer than the retention period
+func (cs *CronScheduler) purgeExpiredNotes() {
+	log.Println("Running scheduled purge of the trash...")
+
+	cfg := config.GetConfig()
+	before := time.Now().UTC().Add(-time.Duration(cfg.TrashRetentionDays) * 24 * time.Hour)
+
+	trashRepo := db.NewTrashRepository(db.GetDB())
+	purged, err := trashRepo.PurgeExpiredNotes(before)
+	if err != nil {
+		log.Printf("Error purging the trash: %v", err)
+		return
+	}
+
+	log.Printf("Successfully purged %d notes from the trash", purged)
+}
+
+// sequenceBatchSize is the number of pending transparency log entries sequenced per transaction
+const sequenceBatchSize = 1000
+
+// signTreeHead sequences the pending entries of the transparency log, then signs its current tree head if the log
+// grew since the last one
+func (cs *CronScheduler) signTreeHead() {
+	transparencyRepo := db.NewTransparencyRepository(db.GetDB())
+
+	for {
+		sequenced, err := transparencyRepo.SequenceLeaves(sequenceBatchSize)
+		if err != nil {
+			log.Printf("Error sequencing the transparency log: %v", err)
+			return
+		}
+		if sequenced < sequenceBatchSize {
+			break
+		}
+	}
+
+	treeSize, err := transparencyRepo.GetTreeSize()
+	if err != nil {
+		log.Printf("Error reading the transparency log size: %v", err)
+		return
+	}
+
+	latest, err := transparencyRepo.GetLatestTreeHead()
+	if err != nil && !errors.Is(err, db.ErrTreeHeadNotFound) {
+		log.Printf("Error reading the latest tree head: %v", err)
+		return
+	}
+	if latest != nil && latest.TreeSize == treeSize {
+		return
+	}
+
+	root, err := transparencyRepo.GetRoot(treeSize)
+	if err != nil {
+		log.Printf("Error computing the root of the transparency log: %v", err)
+		return
+	}
+
+	sth := &models.SignedTreeHead{
+		TreeHead: models.TreeHead{
+			TreeSize: treeSize,
+			RootHash: base64.StdEncoding.EncodeToString(root),
+		},
+		Timestamp: time.Now().UTC().Truncate(time.Second),
+	}
+	if err := crypto.SignTreeHead(sth); err != nil {
+		log.Printf("Error signing the tree head: %v", err)
+		return
+	}
+	if err := transparencyRepo.SaveTreeHead(sth); err != nil {
+		log.Printf("Error storing the tree head: %v", err)
+		return
+	}
+
+	log.Printf("Signed tree head of the transparency log at size %d", sth.TreeSize)
+}
+
+// retryNotifications attempts the security notifications whose retry is due
+func (cs *CronScheduler) retryNotifications() {
+	bus, err := notify.GetBus()
+	if err != nil {
+		return
+	}
+	bus.RetryDue()
+}
+
+// purgeNotifications removes the sent and failed security notifications older than the retention period
+func (cs *CronScheduler) purgeNotifications() {
+	bus, err := notify.GetBus()
+	if err != nil {
+		return
+	}
+
+	cfg := config.GetConfig()
+	before := time.Now().UTC().Add(-time.Duration(cfg.NotifyRetentionDays) * 24 * time.Hour)
+	purged, err := bus.PurgeDeliveries(before)
+	if err != nil {
+		log.Printf("Error purging the notifications: %v", err)
+		return
+	}
+	if purged > 0 {
+		log.Printf("Successfully purged %d notifications", purged)
+	}
+}
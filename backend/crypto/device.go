@@ -0,0 +1,53 @@
+package crypto
+
+import (
+	"fmt"
+	"strconv"
+)
+
+// DeviceEnrollmentPayload builds the bytes signed to enroll the key of a new device.
+// The enrolling key vouches for the new device, and the new device signs the same payload to prove it owns its key.
+// The user ID and the enrolling key bind the enrollment to one account and one trusted key, the name comes last
+// as it is the only part that can contain any character.
+// Parameters:
+// - userID: the ID of the user
+// - enrolledByBase64: the public key that enrolls the device, the login key or the key of another active device
+// - publicKeyBase64: the Ed25519 public key of the new device
+// - pqPublicKeyBase64: the ML-DSA public key of the new device, empty for the Ed25519 accounts
+// - name: the name of the device
+// Returns: the payload to sign or verify
+func DeviceEnrollmentPayload(userID uint32, enrolledByBase64, publicKeyBase64, pqPublicKeyBase64, name string) []byte {
+	return []byte(fmt.Sprintf("device_enroll%d:%s:%s:%s:%s", userID, enrolledByBase64, publicKeyBase64, pqPublicKeyBase64, name))
+}
+
+// DeviceRevocationPayload builds the bytes a trusted key signs to revoke the key of a device.
+// Naming the revoked key, not only its ID, binds the revocation to that key, and every field is prefixed with its
+// length in bytes like in the registration payload.
+// Parameters:
+// - userID: the ID of the user
+// - revokedByBase64: the public key that revokes the device, the login key or the key of an active device
+// - publicKeyBase64: the Ed25519 public key of the revoked device
+// Returns: the payload to sign or verify
+func DeviceRevocationPayload(userID uint32, revokedByBase64, publicKeyBase64 string) []byte {
+	return lengthPrefixed("device_revoke", strconv.FormatUint(uint64(userID), 10), revokedByBase64, publicKeyBase64)
+}
+
+// VerifyDeviceEnrollmentEd25519Signature verifies a signature over a device enrollment payload.
+// Parameters:
+// - publicKeyBase64: the Base64-encoded Ed25519 public key, the enrolling key or the key of the new device
+// - payload: the payload built by DeviceEnrollmentPayload
+// - signatureBase64: the Base64-encoded signature
+// Returns: a boolean indicating whether the signature is valid, and an error if any input is invalid
+func VerifyDeviceEnrollmentEd25519Signature(publicKeyBase64 string, payload []byte, signatureBase64 string) (bool, error) {
+	return verifyEd25519Payload(publicKeyBase64, payload, signatureBase64)
+}
+
+// VerifyDeviceRevocationEd25519Signature verifies a signature over a device revocation payload.
+// Parameters:
+// - publicKeyBase64: the Base64-encoded Ed25519 public key of the revoking key
+// - payload: the payload built by DeviceRevocationPayload
+// - signatureBase64: the Base64-encoded signature
+// Returns: a boolean indicating whether the signature is valid, and an error if any input is invalid
+func VerifyDeviceRevocationEd25519Signature(publicKeyBase64 string, payload []byte, signatureBase64 string) (bool, error) {
+	return verifyEd25519Payload(publicKeyBase64, payload, signatureBase64)
+}
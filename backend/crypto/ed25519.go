@@ -1,10 +1,8 @@
 package crypto
 
 import (
-	"bytes"
 	"encoding/base64"
 	"errors"
-	"log"
 	"time"
 
 	"golang.org/x/crypto/ed25519"
@@ -52,7 +50,29 @@ func VerifyEd25519Signature(publicKeyBase64 string, messageBase64 string, signat
 	return ed25519.Verify(publicKey, messageBytes, signatureBytes), nil
 }
 
-// verifyBlockEd25519Signature verifies the signature of a block.
+// BlockSignaturePayload builds the bytes covered by the signature of a block.
+// The blocks of the AES-128 encryption types sign their IVs and MAC, the blocks of the AEAD encryption
+// types sign their nonces and tags behind an "aead" prefix, so a block cannot be read as the other kind.
+// The lifecycle blocks sign their kind, the note ID and the head they follow: the kind prefix keeps them from
+// ever being valid for a block of content, and a tombstone block signs the payload of the tombstones.
+// Parameters:
+// - block: a pointer to the block
+// Returns: the payload to sign or verify
+func BlockSignaturePayload(block *models.Block) []byte {
+	timestamp := block.Timestamp.Format(time.RFC3339)
+	if block.Kind != "" {
+		return []byte(block.Kind + block.NoteID + block.PrevHash + timestamp)
+	}
+	if IsAEADBlock(block) {
+		return []byte("aead" + block.PrevHash + block.Nonce + block.NonceTitle + block.CipherTitle + block.Ciphertext +
+			block.Tag + block.TagTitle + timestamp)
+	}
+	return []byte(block.PrevHash + block.IV + block.IVTitle + block.CipherTitle + block.Ciphertext + block.MAC + timestamp)
+}
+
+// VerifyBlockEd25519Signature verifies the Ed25519 signature of a block.
+// A block carrying an ML-DSA signature is checked against its hybrid payload, see VerifyBlockSignature
+// to check both signatures.
 // Parameters:
 // - publicKeyBase64: the Base64-encoded Ed25519 public key
 // - block: a pointer to the block whose signature is to be verified
@@ -63,16 +83,12 @@ func VerifyBlockEd25519Signature(publicKeyBase64 string, block *models.Block) (b
 	if err != nil {
 		return false, errors.New("invalid public key format")
 	}
+	if len(publicKeyBytes) != ed25519.PublicKeySize {
+		return false, errors.New("invalid public key size")
+	}
 
-	// Concatenate the string
 	// Prepare the data to verify
-	var buffer bytes.Buffer
-	string := block.PrevHash + block.IV + block.IVTitle + block.CipherTitle + block.Ciphertext + block.MAC + block.Timestamp.Format(time.RFC3339)
-
-	log.Printf("Data to verify: %s", string)
-	buffer.WriteString(string)
-
-	dataToVerify := buffer.Bytes()
+	dataToVerify := signedBlockPayload(block)
 
 	// Decode the signature
 	signatureBytes, err := base64.StdEncoding.DecodeString(block.Signature)
@@ -84,3 +100,53 @@ func VerifyBlockEd25519Signature(publicKeyBase64 string, block *models.Block) (b
 	publicKey := ed25519.PublicKey(publicKeyBytes)
 	return ed25519.Verify(publicKey, dataToVerify, signatureBytes), nil
 }
+
+// VerifyImportEd25519Signature verifies the proof that the owner of an archived vault allows an account to import it.
+// The proof is signed with the key of the archived account over the current public key of the importing account.
+// Parameters:
+// - archiveKeyBase64: the Base64-encoded Ed25519 public key of the archived account
+// - accountKeyBase64: the Base64-encoded Ed25519 public key of the importing account
+// - signatureBase64: the Base64-encoded signature
+// Returns: a boolean indicating whether the proof is valid, and an error if any input is invalid
+func VerifyImportEd25519Signature(archiveKeyBase64, accountKeyBase64, signatureBase64 string) (bool, error) {
+	publicKeyBytes, err := base64.StdEncoding.DecodeString(archiveKeyBase64)
+	if err != nil {
+		return false, errors.New("invalid public key format")
+	}
+	if len(publicKeyBytes) != ed25519.PublicKeySize {
+		return false, errors.New("invalid public key size")
+	}
+
+	// The "vault_import" prefix keeps an import proof from ever being valid for a block
+	dataToVerify := []byte("vault_import" + accountKeyBase64)
+
+	signatureBytes, err := base64.StdEncoding.DecodeString(signatureBase64)
+	if err != nil {
+		return false, errors.New("invalid signature format")
+	}
+
+	return ed25519.Verify(ed25519.PublicKey(publicKeyBytes), dataToVerify, signatureBytes), nil
+}
+
+// verifyEd25519Payload verifies an Ed25519 signature over a payload built by this package.
+// Parameters:
+// - publicKeyBase64: the Base64-encoded Ed25519 public key
+// - payload: the signed bytes
+// - signatureBase64: the Base64-encoded signature
+// Returns: a boolean indicating whether the signature is valid, and an error if any input is invalid
+func verifyEd25519Payload(publicKeyBase64 string, payload []byte, signatureBase64 string) (bool, error) {
+	publicKeyBytes, err := base64.StdEncoding.DecodeString(publicKeyBase64)
+	if err != nil {
+		return false, errors.New("invalid public key format")
+	}
+	if len(publicKeyBytes) != ed25519.PublicKeySize {
+		return false, errors.New("invalid public key size")
+	}
+
+	signatureBytes, err := base64.StdEncoding.DecodeString(signatureBase64)
+	if err != nil {
+		return false, errors.New("invalid signature format")
+	}
+
+	return ed25519.Verify(ed25519.PublicKey(publicKeyBytes), payload, signatureBytes), nil
+}
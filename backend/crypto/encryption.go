@@ -0,0 +1,126 @@
+package crypto
+
+import (
+	"backend/models"
+	"encoding/base64"
+	"errors"
+	"fmt"
+)
+
+// Encryption types a user can choose at registration, stored in their encryption_type.
+// The AES-128 types authenticate the ciphertexts with a separate HMAC, the AEAD types authenticate
+// them with the cipher itself.
+const (
+	EncryptionAES128CBC         = "aes-128-cbc"
+	EncryptionAES128CTR         = "aes-128-ctr"
+	EncryptionAES256GCM         = "aes-256-gcm"
+	EncryptionXChaCha20Poly1305 = "xchacha20-poly1305"
+)
+
+// ValidEncryptionType tells whether an encryption type is supported.
+// Parameters:
+// - encryptionType: the encryption type
+// Returns: true if the type is one of the Encryption constants
+func ValidEncryptionType(encryptionType string) bool {
+	return LookupCipher(encryptionType) != nil
+}
+
+// IsAEAD tells whether an encryption type is an AEAD, whose blocks carry nonces and tags instead of IVs and a MAC
+func IsAEAD(encryptionType string) bool {
+	cipher := LookupCipher(encryptionType)
+	return cipher != nil && cipher.AEAD
+}
+
+// IVSize returns the size of the IVs of an encryption type, or of its nonces for the AEAD types
+func IVSize(encryptionType string) int {
+	if cipher := LookupCipher(encryptionType); cipher != nil {
+		return cipher.IVSize
+	}
+	return 0
+}
+
+// IsAEADBlock tells whether a block carries any AEAD field
+func IsAEADBlock(block *models.Block) bool {
+	return block.Nonce != "" || block.NonceTitle != "" || block.Tag != "" || block.TagTitle != ""
+}
+
+// ValidateBlockFields checks that a block carries exactly the fields of the cipher of a suite.
+// It is a block of content: the lifecycle blocks are only built by the server from a signed tombstone.
+// Parameters:
+// - block: a pointer to the block
+// - suite: a pointer to the suite of the user who signs the block
+// Returns: an error describing the first invalid field, nil if the block is well formed
+func ValidateBlockFields(block *models.Block, suite *Suite) error {
+	if block.PrevHash == "" || block.Signature == "" || block.Timestamp.IsZero() {
+		return errors.New("missing fields")
+	}
+	if block.Kind != "" || block.NoteID != "" {
+		return errors.New("lifecycle blocks are only appended by deleting or restoring a note")
+	}
+
+	cipher := suite.Cipher
+	if !cipher.AEAD {
+		if block.CipherTitle == "" || block.Ciphertext == "" {
+			return errors.New("missing fields")
+		}
+		if IsAEADBlock(block) {
+			return fmt.Errorf("%s blocks cannot carry a nonce or a tag", cipher.Name)
+		}
+		if block.MAC == "" {
+			return errors.New("missing mac")
+		}
+		if err := checkEncodedSize("iv", block.IV, cipher.IVSize); err != nil {
+			return err
+		}
+		return checkEncodedSize("iv_title", block.IVTitle, cipher.IVSize)
+	}
+
+	// An empty title or body seals to an empty ciphertext, its tag still authenticates it
+	if block.IV != "" || block.IVTitle != "" || block.MAC != "" {
+		return fmt.Errorf("%s blocks cannot carry an IV or a MAC", cipher.Name)
+	}
+	fields := []struct {
+		name  string
+		value string
+		size  int
+	}{
+		{"nonce", block.Nonce, cipher.IVSize},
+		{"nonce_title", block.NonceTitle, cipher.IVSize},
+		{"tag", block.Tag, cipher.TagSize},
+		{"tag_title", block.TagTitle, cipher.TagSize},
+	}
+	for _, field := range fields {
+		if err := checkEncodedSize(field.name, field.value, field.size); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ValidateLifecycleBlock checks that a tombstone or restore block carries no content, only its kind, its note ID,
+// the previous hash, the timestamp and its signatures.
+// Parameters:
+// - block: a pointer to the block
+// Returns: an error describing the first invalid field, nil if the block is well formed
+func ValidateLifecycleBlock(block *models.Block) error {
+	if block.Kind != models.BlockKindTombstone && block.Kind != models.BlockKindRestore {
+		return fmt.Errorf("unknown block kind %q", block.Kind)
+	}
+	if block.NoteID == "" || block.PrevHash == "" || block.Signature == "" || block.Timestamp.IsZero() {
+		return errors.New("missing fields")
+	}
+	if block.IV != "" || block.IVTitle != "" || block.CipherTitle != "" || block.Ciphertext != "" || block.MAC != "" ||
+		IsAEADBlock(block) {
+		return fmt.Errorf("%s blocks cannot carry any content", block.Kind)
+	}
+	return nil
+}
+
+// checkEncodedSize checks that a Base64 field decodes to the expected number of bytes
+func checkEncodedSize(name, value string, size int) error {
+	decoded, err := base64.StdEncoding.DecodeString(value)
+	if err != nil || len(decoded) != size {
+		return fmt.Errorf("%s must be %d bytes encoded in base64", name, size)
+	}
+	return nil
+}
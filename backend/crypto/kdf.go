@@ -0,0 +1,104 @@
+package crypto
+
+import (
+	"backend/models"
+	"errors"
+	"fmt"
+)
+
+// Login key derivation algorithms, stored in the kdf_algorithm of the users
+const (
+	KDFPBKDF2SHA256 = "pbkdf2-sha256"
+	KDFArgon2id     = "argon2id"
+)
+
+// LegacyKDF is the login key derivation of the accounts created before the parameters were stored
+var LegacyKDF = models.KDFParams{Algorithm: KDFPBKDF2SHA256, Iterations: 100_000}
+
+// RecommendedKDF is the login key derivation of the new accounts, the weaker accounts are offered to upgrade to it.
+// These are the OWASP minimum parameters of Argon2id, the key is derived in the browser.
+var RecommendedKDF = models.KDFParams{Algorithm: KDFArgon2id, Iterations: 2, MemoryKiB: 19 * 1024, Parallelism: 1}
+
+// Bounds of the parameters: below the minimums the login key is too cheap to brute force, above the maximums
+// a client could not derive it anymore
+const (
+	minPBKDF2Iterations = 100_000
+	maxPBKDF2Iterations = 10_000_000
+	minArgon2Passes     = 1
+	maxArgon2Passes     = 16
+	minArgon2MemoryKiB  = 19 * 1024
+	maxArgon2MemoryKiB  = 1024 * 1024
+	maxArgon2Lanes      = 16
+)
+
+// ValidateKDFParams checks that login key derivation parameters are supported and within bounds.
+// Parameters:
+// - params: the parameters
+// Returns: an error describing the invalid parameter, nil if they are valid
+func ValidateKDFParams(params models.KDFParams) error {
+	switch params.Algorithm {
+	case KDFPBKDF2SHA256:
+		if params.MemoryKiB != 0 || params.Parallelism != 0 {
+			return errors.New("pbkdf2-sha256 takes no memory or parallelism")
+		}
+		if params.Iterations < minPBKDF2Iterations || params.Iterations > maxPBKDF2Iterations {
+			return fmt.Errorf("pbkdf2-sha256 iterations must be between %d and %d", minPBKDF2Iterations, maxPBKDF2Iterations)
+		}
+	case KDFArgon2id:
+		if params.Iterations < minArgon2Passes || params.Iterations > maxArgon2Passes {
+			return fmt.Errorf("argon2id iterations must be between %d and %d", minArgon2Passes, maxArgon2Passes)
+		}
+		if params.MemoryKiB < minArgon2MemoryKiB || params.MemoryKiB > maxArgon2MemoryKiB {
+			return fmt.Errorf("argon2id memory must be between %d and %d KiB", minArgon2MemoryKiB, maxArgon2MemoryKiB)
+		}
+		if params.Parallelism < 1 || params.Parallelism > maxArgon2Lanes {
+			return fmt.Errorf("argon2id parallelism must be between 1 and %d", maxArgon2Lanes)
+		}
+	default:
+		return fmt.Errorf("unsupported kdf algorithm %q", params.Algorithm)
+	}
+	return nil
+}
+
+// IsKDFUpgrade tells whether new login key derivation parameters are stronger than the current ones.
+// PBKDF2 can only be upgraded to more iterations or to Argon2id, and Argon2id can never be downgraded to PBKDF2.
+// Parameters:
+// - current: the parameters of the account
+// - next: the proposed parameters
+// Returns: true if no parameter is weaker and at least one is stronger
+func IsKDFUpgrade(current, next models.KDFParams) bool {
+	switch {
+	case current.Algorithm == KDFPBKDF2SHA256 && next.Algorithm == KDFArgon2id:
+		return true
+	case current.Algorithm != next.Algorithm:
+		return false
+	case next.Iterations < current.Iterations || next.MemoryKiB < current.MemoryKiB:
+		return false
+	}
+	return next.Iterations > current.Iterations || next.MemoryKiB > current.MemoryKiB
+}
+
+// KDFUpgradePayload builds the bytes signed by both login keys to change the derivation of the login key.
+// The old public key binds the upgrade to the current state of the account, so it cannot be replayed.
+// The ML-DSA key of the hybrid accounts is derived from the login key, so it changes with it and is named last.
+// Like the registration payload, every field is prefixed with its length in bytes.
+// Parameters:
+// - oldKeyBase64: the current public key of the user
+// - newKeyBase64: the public key derived with the new salt and parameters
+// - newPQKeyBase64: the ML-DSA public key derived from the new login key, empty for the Ed25519 accounts
+// - loginSalt: the new Base64 login salt
+// - params: the new parameters
+// Returns: the payload to sign or verify
+func KDFUpgradePayload(oldKeyBase64, newKeyBase64, newPQKeyBase64, loginSalt string, params models.KDFParams) []byte {
+	return lengthPrefixed("kdf_upgrade", oldKeyBase64, newKeyBase64, loginSalt, kdfField(params), newPQKeyBase64)
+}
+
+// VerifyKDFUpgradeEd25519Signature verifies a signature over a KDF upgrade payload.
+// Parameters:
+// - publicKeyBase64: the Base64-encoded Ed25519 public key, the old or the new login key
+// - payload: the payload built by KDFUpgradePayload
+// - signatureBase64: the Base64-encoded signature
+// Returns: a boolean indicating whether the signature is valid, and an error if any input is invalid
+func VerifyKDFUpgradeEd25519Signature(publicKeyBase64 string, payload []byte, signatureBase64 string) (bool, error) {
+	return verifyEd25519Payload(publicKeyBase64, payload, signatureBase64)
+}
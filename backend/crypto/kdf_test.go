@@ -0,0 +1,43 @@
+package crypto_test
+
+import (
+	"backend/crypto"
+	"testing"
+)
+
+func TestKDFUpgradePayloadPrefixesEveryField(t *testing.T) {
+	got := string(crypto.KDFUpgradePayload("old", "new", "", "salt", crypto.LegacyKDF))
+	want := "kdf_upgrade:3:old:3:new:4:salt:24:pbkdf2-sha256:100000:0:0:0:"
+	if got != want {
+		t.Errorf("got payload %q, want %q", got, want)
+	}
+
+	// Moving bytes from a field to the next one changes the payload
+	payloads := map[string]bool{}
+	for _, fields := range [][4]string{
+		{"ab", "c", "d", ""},
+		{"a", "bc", "d", ""},
+		{"a", "b", "cd", ""},
+		{"a", "b", "c", "d"},
+	} {
+		payload := string(crypto.KDFUpgradePayload(fields[0], fields[1], fields[3], fields[2], crypto.RecommendedKDF))
+		if payloads[payload] {
+			t.Errorf("fields %q give the payload of other fields", fields)
+		}
+		payloads[payload] = true
+	}
+}
+
+func TestRecoveryPayloadPrefixesEveryField(t *testing.T) {
+	got := string(crypto.RecoveryPayload("a@b.c", "old", "new", "pq", "salt", crypto.LegacyKDF, "keys"))
+	want := "account_recovery:5:a@b.c:3:old:3:new:4:salt:24:pbkdf2-sha256:100000:0:0:4:keys:2:pq"
+	if got != want {
+		t.Errorf("got payload %q, want %q", got, want)
+	}
+
+	// The email cannot take over the start of the old key
+	if string(crypto.RecoveryPayload("a@b.c:", "old", "new", "", "salt", crypto.LegacyKDF, "keys")) ==
+		string(crypto.RecoveryPayload("a@b.c", ":old", "new", "", "salt", crypto.LegacyKDF, "keys")) {
+		t.Error("moving a byte from the email to the old key gives the same payload")
+	}
+}
@@ -0,0 +1,389 @@
+package crypto
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"math/bits"
+	"strconv"
+)
+
+// The Merkle tree follows RFC 6962 (Certificate Transparency): leaves and inner nodes are hashed
+// with different prefixes, so a leaf can never be passed off as an inner node.
+const (
+	merkleLeafPrefix = 0x00
+	merkleNodePrefix = 0x01
+)
+
+// MerkleLeafHash computes the hash of a leaf of the Merkle tree.
+// Parameters:
+// - data: the content of the leaf
+// Returns: the SHA-256 hash of the leaf prefix followed by the data
+func MerkleLeafHash(data []byte) []byte {
+	hash := sha256.Sum256(append([]byte{merkleLeafPrefix}, data...))
+	return hash[:]
+}
+
+// merkleNodeHash computes the hash of an inner node from the hashes of its children
+func merkleNodeHash(left, right []byte) []byte {
+	buffer := make([]byte, 0, 1+len(left)+len(right))
+	buffer = append(buffer, merkleNodePrefix)
+	buffer = append(buffer, left...)
+	buffer = append(buffer, right...)
+	hash := sha256.Sum256(buffer)
+	return hash[:]
+}
+
+// merkleSplit returns the largest power of two smaller than n, where the tree of n leaves is split
+func merkleSplit(n int) int {
+	k := 1
+	for k<<1 < n {
+		k <<= 1
+	}
+	return k
+}
+
+// MerkleRoot computes the root of the Merkle tree built over a list of leaf hashes.
+// Parameters:
+// - leaves: the leaf hashes, in the order they were appended
+// Returns: the root hash, the hash of the empty string for an empty tree
+func MerkleRoot(leaves [][]byte) []byte {
+	switch len(leaves) {
+	case 0:
+		hash := sha256.Sum256(nil)
+		return hash[:]
+	case 1:
+		return leaves[0]
+	}
+
+	k := merkleSplit(len(leaves))
+	return merkleNodeHash(MerkleRoot(leaves[:k]), MerkleRoot(leaves[k:]))
+}
+
+// MerkleInclusionProof computes the audit path proving that a leaf is part of the tree.
+// Parameters:
+// - leaves: the leaf hashes of the tree
+// - index: the position of the leaf, starting at 0
+// Returns: the audit path from the leaf to the root, or an error if the index is outside the tree
+func MerkleInclusionProof(leaves [][]byte, index int) ([][]byte, error) {
+	if index < 0 || index >= len(leaves) {
+		return nil, errors.New("leaf index outside the tree")
+	}
+	return merkleInclusionPath(leaves, index), nil
+}
+
+// merkleInclusionPath is the PATH function of RFC 6962 section 2.1.1
+func merkleInclusionPath(leaves [][]byte, index int) [][]byte {
+	if len(leaves) <= 1 {
+		return [][]byte{}
+	}
+
+	k := merkleSplit(len(leaves))
+	if index < k {
+		return append(merkleInclusionPath(leaves[:k], index), MerkleRoot(leaves[k:]))
+	}
+	return append(merkleInclusionPath(leaves[k:], index-k), MerkleRoot(leaves[:k]))
+}
+
+// VerifyMerkleInclusion checks an audit path against a root.
+// Parameters:
+// - leafHash: the hash of the leaf
+// - index: the position of the leaf, starting at 0
+// - treeSize: the number of leaves of the tree the root was computed for
+// - proof: the audit path returned by MerkleInclusionProof
+// - root: the expected root hash
+// Returns: true if the leaf is part of the tree with this root
+func VerifyMerkleInclusion(leafHash []byte, index, treeSize int, proof [][]byte, root []byte) bool {
+	if index < 0 || index >= treeSize {
+		return false
+	}
+
+	// Algorithm of RFC 9162 section 2.1.3.2
+	fn, sn := index, treeSize-1
+	hash := leafHash
+	for _, sibling := range proof {
+		if sn == 0 {
+			return false
+		}
+		if fn&1 == 1 || fn == sn {
+			hash = merkleNodeHash(sibling, hash)
+			for fn&1 == 0 && fn != 0 {
+				fn >>= 1
+				sn >>= 1
+			}
+		} else {
+			hash = merkleNodeHash(hash, sibling)
+		}
+		fn >>= 1
+		sn >>= 1
+	}
+
+	return sn == 0 && bytes.Equal(hash, root)
+}
+
+// MerkleConsistencyProof computes the proof that a tree is an append-only extension of an older tree.
+// Parameters:
+// - leaves: the leaf hashes of the newer tree
+// - oldSize: the number of leaves of the older tree
+// Returns: the consistency proof, or an error if the older tree is larger than the newer one
+func MerkleConsistencyProof(leaves [][]byte, oldSize int) ([][]byte, error) {
+	if oldSize < 0 || oldSize > len(leaves) {
+		return nil, errors.New("old tree size outside the tree")
+	}
+	if oldSize == 0 || oldSize == len(leaves) {
+		return [][]byte{}, nil
+	}
+	return merkleSubProof(oldSize, leaves, true), nil
+}
+
+// merkleSubProof is the SUBPROOF function of RFC 6962 section 2.1.2
+func merkleSubProof(m int, leaves [][]byte, complete bool) [][]byte {
+	n := len(leaves)
+	if m == n {
+		if complete {
+			return [][]byte{}
+		}
+		return [][]byte{MerkleRoot(leaves)}
+	}
+
+	k := merkleSplit(n)
+	if m <= k {
+		return append(merkleSubProof(m, leaves[:k], complete), MerkleRoot(leaves[k:]))
+	}
+	return append(merkleSubProof(m-k, leaves[k:], false), MerkleRoot(leaves[:k]))
+}
+
+// VerifyMerkleConsistency checks that a newer root extends an older root without changing its leaves.
+// Parameters:
+// - oldSize: the number of leaves of the older tree
+// - newSize: the number of leaves of the newer tree
+// - oldRoot: the root hash of the older tree
+// - newRoot: the root hash of the newer tree
+// - proof: the consistency proof returned by MerkleConsistencyProof
+// Returns: true if the newer tree contains the older tree as a prefix
+func VerifyMerkleConsistency(oldSize, newSize int, oldRoot, newRoot []byte, proof [][]byte) bool {
+	switch {
+	case oldSize < 0 || oldSize > newSize:
+		return false
+	case oldSize == newSize:
+		return len(proof) == 0 && bytes.Equal(oldRoot, newRoot)
+	case oldSize == 0:
+		// Every tree extends the empty tree
+		return len(proof) == 0
+	case len(proof) == 0:
+		return false
+	}
+
+	// Algorithm of RFC 9162 section 2.1.4.2
+	// When the older tree is a complete subtree, its root is the first node of the path
+	if oldSize&(oldSize-1) == 0 {
+		proof = append([][]byte{oldRoot}, proof...)
+	}
+
+	fn, sn := oldSize-1, newSize-1
+	for fn&1 == 1 {
+		fn >>= 1
+		sn >>= 1
+	}
+
+	fr, sr := proof[0], proof[0]
+	for _, node := range proof[1:] {
+		if sn == 0 {
+			return false
+		}
+		if fn&1 == 1 || fn == sn {
+			fr = merkleNodeHash(node, fr)
+			sr = merkleNodeHash(node, sr)
+			for fn&1 == 0 && fn != 0 {
+				fn >>= 1
+				sn >>= 1
+			}
+		} else {
+			sr = merkleNodeHash(sr, node)
+		}
+		fn >>= 1
+		sn >>= 1
+	}
+
+	return sn == 0 && bytes.Equal(fr, oldRoot) && bytes.Equal(sr, newRoot)
+}
+
+// MerkleTree is an append-only Merkle tree that keeps the hash of every complete subtree, so the root and the
+// proofs of any of its sizes take O(log² n) hashes instead of rehashing every leaf.
+// It is not safe for concurrent use.
+type MerkleTree struct {
+	levels [][][]byte // levels[h][i] is the root of the complete subtree of the leaves i*2^h to (i+1)*2^h - 1
+}
+
+// Size returns the number of leaves of the tree
+func (t *MerkleTree) Size() int {
+	if len(t.levels) == 0 {
+		return 0
+	}
+	return len(t.levels[0])
+}
+
+// Append adds a leaf hash to the tree, and the complete subtrees it closes.
+// Parameters:
+// - leaf: the leaf hash
+func (t *MerkleTree) Append(leaf []byte) {
+	if len(t.levels) == 0 {
+		t.levels = [][][]byte{{}}
+	}
+	t.levels[0] = append(t.levels[0], leaf)
+
+	// A right child closes the subtree of its parent
+	for h, i := 0, len(t.levels[0])-1; i&1 == 1; h, i = h+1, i>>1 {
+		if h+1 == len(t.levels) {
+			t.levels = append(t.levels, [][]byte{})
+		}
+		t.levels[h+1] = append(t.levels[h+1], merkleNodeHash(t.levels[h][i-1], t.levels[h][i]))
+	}
+}
+
+// Root computes the root of the first leaves of the tree.
+// Parameters:
+// - size: the number of leaves
+// Returns: the root hash, or an error if the tree has fewer leaves
+func (t *MerkleTree) Root(size int) ([]byte, error) {
+	if size < 0 || size > t.Size() {
+		return nil, errors.New("tree size outside the tree")
+	}
+	return t.subtreeRoot(0, size), nil
+}
+
+// InclusionProof computes the audit path of a leaf in the tree of the first leaves, like MerkleInclusionProof.
+// Parameters:
+// - index: the position of the leaf, starting at 0
+// - size: the number of leaves of the tree the proof is for
+// Returns: the audit path from the leaf to the root, or an error if the index or the size is outside the tree
+func (t *MerkleTree) InclusionProof(index, size int) ([][]byte, error) {
+	if size < 0 || size > t.Size() {
+		return nil, errors.New("tree size outside the tree")
+	}
+	if index < 0 || index >= size {
+		return nil, errors.New("leaf index outside the tree")
+	}
+	return t.inclusionPath(index, 0, size), nil
+}
+
+// ConsistencyProof computes the proof that the tree of the first leaves extends an older tree,
+// like MerkleConsistencyProof.
+// Parameters:
+// - oldSize: the number of leaves of the older tree
+// - size: the number of leaves of the newer tree
+// Returns: the consistency proof, or an error if a size is outside the tree or the older tree is larger
+func (t *MerkleTree) ConsistencyProof(oldSize, size int) ([][]byte, error) {
+	if size < 0 || size > t.Size() {
+		return nil, errors.New("tree size outside the tree")
+	}
+	if oldSize < 0 || oldSize > size {
+		return nil, errors.New("old tree size outside the tree")
+	}
+	if oldSize == 0 || oldSize == size {
+		return [][]byte{}, nil
+	}
+	return t.subProof(oldSize, 0, size, true), nil
+}
+
+// subtreeRoot is MerkleRoot of the n leaves starting at start. The RFC 6962 recursion only asks for ranges whose
+// start is aligned on the largest power of two below n, so they split into complete subtrees of the cache.
+func (t *MerkleTree) subtreeRoot(start, n int) []byte {
+	if n == 0 {
+		hash := sha256.Sum256(nil)
+		return hash[:]
+	}
+	if n&(n-1) == 0 {
+		h := bits.TrailingZeros(uint(n))
+		return t.levels[h][start>>h]
+	}
+	k := merkleSplit(n)
+	return merkleNodeHash(t.subtreeRoot(start, k), t.subtreeRoot(start+k, n-k))
+}
+
+// inclusionPath is merkleInclusionPath over the n leaves starting at start
+func (t *MerkleTree) inclusionPath(index, start, n int) [][]byte {
+	if n <= 1 {
+		return [][]byte{}
+	}
+
+	k := merkleSplit(n)
+	if index < k {
+		return append(t.inclusionPath(index, start, k), t.subtreeRoot(start+k, n-k))
+	}
+	return append(t.inclusionPath(index-k, start+k, n-k), t.subtreeRoot(start, k))
+}
+
+// subProof is merkleSubProof over the n leaves starting at start
+func (t *MerkleTree) subProof(m, start, n int, complete bool) [][]byte {
+	if m == n {
+		if complete {
+			return [][]byte{}
+		}
+		return [][]byte{t.subtreeRoot(start, n)}
+	}
+
+	k := merkleSplit(n)
+	if m <= k {
+		return append(t.subProof(m, start, k, complete), t.subtreeRoot(start+k, n-k))
+	}
+	return append(t.subProof(m-k, start+k, n-k, false), t.subtreeRoot(start, k))
+}
+
+// The frontier of a tree is the list of the roots of its largest complete subtrees, from the left: one per bit set
+// in the size, so at most log n hashes. It is enough to append a leaf and to compute the root, without the leaves.
+
+// MerkleFrontierAppend appends a leaf to the tree of a frontier.
+// Parameters:
+// - frontier: the frontier of the tree, it is not modified
+// - size: the number of leaves of the tree
+// - leaf: the leaf hash to append
+// Returns: the frontier of the tree with one more leaf
+func MerkleFrontierAppend(frontier [][]byte, size int, leaf []byte) [][]byte {
+	next := append([][]byte{}, frontier...)
+	node := leaf
+	// Every complete subtree of the size of the new node merges with it, like the carries of a binary increment
+	for ; size&1 == 1; size >>= 1 {
+		node = merkleNodeHash(next[len(next)-1], node)
+		next = next[:len(next)-1]
+	}
+	return append(next, node)
+}
+
+// MerkleFrontierRoot computes the root of the tree of a frontier.
+// The tree of n leaves splits at the largest power of two below n, so its root folds the frontier from the right.
+// Parameters:
+// - frontier: the frontier of the tree
+// Returns: the root hash, the hash of the empty string for an empty tree
+func MerkleFrontierRoot(frontier [][]byte) []byte {
+	if len(frontier) == 0 {
+		return MerkleRoot(nil)
+	}
+	root := frontier[len(frontier)-1]
+	for i := len(frontier) - 2; i >= 0; i-- {
+		root = merkleNodeHash(frontier[i], root)
+	}
+	return root
+}
+
+// AccountLeafHash computes the leaf hash of an account log entry.
+// Parameters:
+// - event: the event recorded by the leaf (head, trash, restore or purge)
+// - noteID: the ID of the note
+// - headHash: the Base64 hash of the head of the note after the event
+// Returns: the Base64-encoded leaf hash of "event:noteID:headHash"
+func AccountLeafHash(event, noteID, headHash string) string {
+	return base64.StdEncoding.EncodeToString(MerkleLeafHash([]byte(event + ":" + noteID + ":" + headHash)))
+}
+
+// TransparencyLeafHash computes the leaf hash of a transparency log entry.
+// Parameters:
+// - noteID: the ID of the note
+// - seq: the position of the block in the note chain
+// - blockHash: the Base64 hash of the accepted block
+// Returns: the Base64-encoded leaf hash of "block:noteID:seq:blockHash"
+func TransparencyLeafHash(noteID string, seq uint, blockHash string) string {
+	data := "block:" + noteID + ":" + strconv.FormatUint(uint64(seq), 10) + ":" + blockHash
+	return base64.StdEncoding.EncodeToString(MerkleLeafHash([]byte(data)))
+}
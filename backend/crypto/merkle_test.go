@@ -0,0 +1,334 @@
+package crypto_test
+
+import (
+	"backend/crypto"
+	"bytes"
+	"encoding/hex"
+	"math/bits"
+	"strconv"
+	"testing"
+)
+
+// testLeaves returns the leaf hashes of n distinct leaves
+func testLeaves(n int) [][]byte {
+	leaves := make([][]byte, n)
+	for i := range leaves {
+		leaves[i] = crypto.MerkleLeafHash([]byte("leaf " + strconv.Itoa(i)))
+	}
+	return leaves
+}
+
+// equalHashes tells whether two lists of hashes are identical
+func equalHashes(a, b [][]byte) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if !bytes.Equal(a[i], b[i]) {
+			return false
+		}
+	}
+	return true
+}
+
+// rfcLeaves returns the leaf hashes of the eight leaves of the RFC 6962 reference test data,
+// shared by the Certificate Transparency implementations
+func rfcLeaves(t *testing.T) [][]byte {
+	t.Helper()
+	inputs := []string{"", "00", "10", "2021", "3031", "40414243", "5051525354555657", "606162636465666768696a6b6c6d6e6f"}
+	leaves := make([][]byte, len(inputs))
+	for i, input := range inputs {
+		leaves[i] = crypto.MerkleLeafHash(decodeHex(t, input))
+	}
+	return leaves
+}
+
+// decodeHex decodes a hexadecimal test value
+func decodeHex(t *testing.T, value string) []byte {
+	t.Helper()
+	decoded, err := hex.DecodeString(value)
+	if err != nil {
+		t.Fatalf("invalid hex %q: %v", value, err)
+	}
+	return decoded
+}
+
+// decodeHashes decodes a list of hexadecimal hashes
+func decodeHashes(t *testing.T, values []string) [][]byte {
+	t.Helper()
+	hashes := make([][]byte, len(values))
+	for i, value := range values {
+		hashes[i] = decodeHex(t, value)
+	}
+	return hashes
+}
+
+// Roots of the first n reference leaves, the empty tree hashes to SHA-256 of the empty string
+var rfcRoots = []string{
+	"e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855",
+	"6e340b9cffb37a989ca544e6bb780a2c78901d3fb33738768511a30617afa01d",
+	"fac54203e7cc696cf0dfcb42c92a1d9dbaf70ad9e621f4bd8d98662f00e3c125",
+	"aeb6bcfe274b70a14fb067a5e5578264db0fa9b51af5e0ba159158f329e06e77",
+	"d37ee418976dd95753c1c73862b9398fa2a2cf9b4ff0fdfe8b30cd95209614b7",
+	"4e3bbb1f7b478dcfe71fb631631519a3bca12c9aefca1612bfce4c13a86264d4",
+	"76e67dadbcdf1e10e1b74ddc608abd2f98dfb16fbce75277b5232a127f2087ef",
+	"ddb89be403809e325750d3d263cd78929c2942b7942a34b77e122c9594a74c8c",
+	"5dc9da79a70659a9ad559cb701ded9a2ab9d823aad2f4960cfe370eff4604328",
+}
+
+func TestMerkleRootMatchesTheReferenceVectors(t *testing.T) {
+	leaves := rfcLeaves(t)
+	tree := &crypto.MerkleTree{}
+	for _, leaf := range leaves {
+		tree.Append(leaf)
+	}
+
+	for size, want := range rfcRoots {
+		if got := crypto.MerkleRoot(leaves[:size]); hex.EncodeToString(got) != want {
+			t.Errorf("MerkleRoot of %d leaves = %x, want %s", size, got, want)
+		}
+		got, err := tree.Root(size)
+		if err != nil {
+			t.Fatalf("size %d: %v", size, err)
+		}
+		if hex.EncodeToString(got) != want {
+			t.Errorf("MerkleTree.Root(%d) = %x, want %s", size, got, want)
+		}
+	}
+}
+
+func TestMerkleInclusionProofMatchesTheReferenceVectors(t *testing.T) {
+	leaves := rfcLeaves(t)
+	tree := &crypto.MerkleTree{}
+	for _, leaf := range leaves {
+		tree.Append(leaf)
+	}
+
+	tests := []struct {
+		index, size int
+		path        []string
+	}{
+		{0, 1, nil},
+		{0, 8, []string{
+			"96a296d224f285c67bee93c30f8a309157f0daa35dc5b87e410b78630a09cfc7",
+			"5f083f0a1a33ca076a95279832580db3e0ef4584bdff1f54c8a360f50de3031e",
+			"6b47aaf29ee3c2af9af889bc1fb9254dabd31177f16232dd6aab035ca39bf6e4",
+		}},
+		{5, 8, []string{
+			"bc1a0643b12e4d2d7c77918f44e0f4f79a838b6cf9ec5b5c283e1f4d88599e6b",
+			"ca854ea128ed050b41b35ffc1b87b8eb2bde461e9e3b5596ece6b9d5975a0ae0",
+			"d37ee418976dd95753c1c73862b9398fa2a2cf9b4ff0fdfe8b30cd95209614b7",
+		}},
+		{2, 3, []string{
+			"fac54203e7cc696cf0dfcb42c92a1d9dbaf70ad9e621f4bd8d98662f00e3c125",
+		}},
+		{1, 5, []string{
+			"6e340b9cffb37a989ca544e6bb780a2c78901d3fb33738768511a30617afa01d",
+			"5f083f0a1a33ca076a95279832580db3e0ef4584bdff1f54c8a360f50de3031e",
+			"bc1a0643b12e4d2d7c77918f44e0f4f79a838b6cf9ec5b5c283e1f4d88599e6b",
+		}},
+	}
+
+	for _, tt := range tests {
+		want := decodeHashes(t, tt.path)
+		root := decodeHex(t, rfcRoots[tt.size])
+
+		path, err := crypto.MerkleInclusionProof(leaves[:tt.size], tt.index)
+		if err != nil {
+			t.Fatalf("index %d size %d: %v", tt.index, tt.size, err)
+		}
+		if !equalHashes(path, want) {
+			t.Errorf("index %d size %d: MerkleInclusionProof differs from the reference path", tt.index, tt.size)
+		}
+		cached, err := tree.InclusionProof(tt.index, tt.size)
+		if err != nil {
+			t.Fatalf("index %d size %d: %v", tt.index, tt.size, err)
+		}
+		if !equalHashes(cached, want) {
+			t.Errorf("index %d size %d: MerkleTree.InclusionProof differs from the reference path", tt.index, tt.size)
+		}
+
+		if !crypto.VerifyMerkleInclusion(leaves[tt.index], tt.index, tt.size, want, root) {
+			t.Errorf("index %d size %d: the reference path does not verify", tt.index, tt.size)
+		}
+		if crypto.VerifyMerkleInclusion(leaves[(tt.index+1)%8], tt.index, tt.size, want, root) {
+			t.Errorf("index %d size %d: the path verified another leaf", tt.index, tt.size)
+		}
+		if len(want) > 0 {
+			tampered := decodeHashes(t, tt.path)
+			tampered[0][0] ^= 1
+			if crypto.VerifyMerkleInclusion(leaves[tt.index], tt.index, tt.size, tampered, root) {
+				t.Errorf("index %d size %d: a tampered path verified", tt.index, tt.size)
+			}
+		}
+	}
+}
+
+func TestMerkleConsistencyProofMatchesTheReferenceVectors(t *testing.T) {
+	leaves := rfcLeaves(t)
+	tree := &crypto.MerkleTree{}
+	for _, leaf := range leaves {
+		tree.Append(leaf)
+	}
+
+	tests := []struct {
+		oldSize, size int
+		proof         []string
+	}{
+		{0, 0, nil},
+		{0, 5, nil},
+		{1, 1, nil},
+		{5, 5, nil},
+		{8, 8, nil},
+		{1, 8, []string{
+			"96a296d224f285c67bee93c30f8a309157f0daa35dc5b87e410b78630a09cfc7",
+			"5f083f0a1a33ca076a95279832580db3e0ef4584bdff1f54c8a360f50de3031e",
+			"6b47aaf29ee3c2af9af889bc1fb9254dabd31177f16232dd6aab035ca39bf6e4",
+		}},
+		{6, 8, []string{
+			"0ebc5d3437fbe2db158b9f126a1d118e308181031d0a949f8dededebc558ef6a",
+			"ca854ea128ed050b41b35ffc1b87b8eb2bde461e9e3b5596ece6b9d5975a0ae0",
+			"d37ee418976dd95753c1c73862b9398fa2a2cf9b4ff0fdfe8b30cd95209614b7",
+		}},
+		{2, 5, []string{
+			"5f083f0a1a33ca076a95279832580db3e0ef4584bdff1f54c8a360f50de3031e",
+			"bc1a0643b12e4d2d7c77918f44e0f4f79a838b6cf9ec5b5c283e1f4d88599e6b",
+		}},
+	}
+
+	for _, tt := range tests {
+		want := decodeHashes(t, tt.proof)
+		oldRoot, root := decodeHex(t, rfcRoots[tt.oldSize]), decodeHex(t, rfcRoots[tt.size])
+
+		proof, err := crypto.MerkleConsistencyProof(leaves[:tt.size], tt.oldSize)
+		if err != nil {
+			t.Fatalf("sizes %d to %d: %v", tt.oldSize, tt.size, err)
+		}
+		if !equalHashes(proof, want) {
+			t.Errorf("sizes %d to %d: MerkleConsistencyProof differs from the reference proof", tt.oldSize, tt.size)
+		}
+		cached, err := tree.ConsistencyProof(tt.oldSize, tt.size)
+		if err != nil {
+			t.Fatalf("sizes %d to %d: %v", tt.oldSize, tt.size, err)
+		}
+		if !equalHashes(cached, want) {
+			t.Errorf("sizes %d to %d: MerkleTree.ConsistencyProof differs from the reference proof", tt.oldSize, tt.size)
+		}
+
+		if !crypto.VerifyMerkleConsistency(tt.oldSize, tt.size, oldRoot, root, want) {
+			t.Errorf("sizes %d to %d: the reference proof does not verify", tt.oldSize, tt.size)
+		}
+		if tt.oldSize > 0 && tt.oldSize < tt.size {
+			if crypto.VerifyMerkleConsistency(tt.oldSize, tt.size, root, root, want) {
+				t.Errorf("sizes %d to %d: the proof verified a wrong older root", tt.oldSize, tt.size)
+			}
+			if crypto.VerifyMerkleConsistency(tt.oldSize, tt.size, oldRoot, root, want[:len(want)-1]) {
+				t.Errorf("sizes %d to %d: a truncated proof verified", tt.oldSize, tt.size)
+			}
+		}
+	}
+
+	// The same size with different roots is not consistent, even with an empty proof
+	if crypto.VerifyMerkleConsistency(5, 5, decodeHex(t, rfcRoots[4]), decodeHex(t, rfcRoots[5]), nil) {
+		t.Error("two different roots of the same size verified as consistent")
+	}
+}
+
+func TestMerkleTreeMatchesTheLeafFunctions(t *testing.T) {
+	const maxSize = 70
+	leaves := testLeaves(maxSize)
+	tree := &crypto.MerkleTree{}
+	for _, leaf := range leaves {
+		tree.Append(leaf)
+	}
+	if tree.Size() != maxSize {
+		t.Fatalf("the tree has %d leaves, want %d", tree.Size(), maxSize)
+	}
+
+	for size := 0; size <= maxSize; size++ {
+		root, err := tree.Root(size)
+		if err != nil {
+			t.Fatalf("size %d: %v", size, err)
+		}
+		if !bytes.Equal(root, crypto.MerkleRoot(leaves[:size])) {
+			t.Errorf("size %d: the cached root differs from MerkleRoot", size)
+		}
+
+		for index := 0; index < size; index++ {
+			path, err := tree.InclusionProof(index, size)
+			if err != nil {
+				t.Fatalf("size %d index %d: %v", size, index, err)
+			}
+			want, _ := crypto.MerkleInclusionProof(leaves[:size], index)
+			if !equalHashes(path, want) {
+				t.Errorf("size %d index %d: the cached audit path differs from MerkleInclusionProof", size, index)
+			}
+		}
+
+		for oldSize := 0; oldSize <= size; oldSize++ {
+			proof, err := tree.ConsistencyProof(oldSize, size)
+			if err != nil {
+				t.Fatalf("sizes %d to %d: %v", oldSize, size, err)
+			}
+			want, _ := crypto.MerkleConsistencyProof(leaves[:size], oldSize)
+			if !equalHashes(proof, want) {
+				t.Errorf("sizes %d to %d: the cached proof differs from MerkleConsistencyProof", oldSize, size)
+			}
+		}
+	}
+}
+
+func TestMerkleFrontierMatchesMerkleRoot(t *testing.T) {
+	leaves := testLeaves(100)
+	var frontier [][]byte
+	for size := 0; size <= len(leaves); size++ {
+		if !bytes.Equal(crypto.MerkleFrontierRoot(frontier), crypto.MerkleRoot(leaves[:size])) {
+			t.Fatalf("size %d: the frontier root differs from MerkleRoot", size)
+		}
+		if want := bits.OnesCount(uint(size)); len(frontier) != want {
+			t.Fatalf("size %d: the frontier has %d hashes, want %d", size, len(frontier), want)
+		}
+		if size < len(leaves) {
+			// The frontier of the older tree stays usable, the stored tree head is only replaced once committed
+			previous := frontier
+			saved := append([][]byte{}, frontier...)
+			frontier = crypto.MerkleFrontierAppend(frontier, size, leaves[size])
+			if !equalHashes(previous, saved) {
+				t.Fatalf("size %d: appending modified the previous frontier", size)
+			}
+		}
+	}
+
+	// The reference vectors of RFC 6962
+	reference := rfcLeaves(t)
+	frontier = nil
+	for size, leaf := range reference {
+		frontier = crypto.MerkleFrontierAppend(frontier, size, leaf)
+		if hex.EncodeToString(crypto.MerkleFrontierRoot(frontier)) != rfcRoots[size+1] {
+			t.Errorf("size %d: the frontier root differs from the reference root", size+1)
+		}
+	}
+}
+
+func TestMerkleTreeRejectsSizesOutsideTheTree(t *testing.T) {
+	tree := &crypto.MerkleTree{}
+	for _, leaf := range testLeaves(5) {
+		tree.Append(leaf)
+	}
+
+	if _, err := tree.Root(6); err == nil {
+		t.Error("Root accepted a size larger than the tree")
+	}
+	if _, err := tree.InclusionProof(5, 5); err == nil {
+		t.Error("InclusionProof accepted an index outside the tree")
+	}
+	if _, err := tree.InclusionProof(0, 6); err == nil {
+		t.Error("InclusionProof accepted a size larger than the tree")
+	}
+	if _, err := tree.ConsistencyProof(4, 3); err == nil {
+		t.Error("ConsistencyProof accepted an older tree larger than the newer one")
+	}
+	if _, err := tree.ConsistencyProof(3, 6); err == nil {
+		t.Error("ConsistencyProof accepted a size larger than the tree")
+	}
+}
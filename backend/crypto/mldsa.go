@@ -0,0 +1,126 @@
+package crypto
+
+import (
+	"backend/models"
+	"crypto/mldsa"
+	"encoding/base64"
+	"errors"
+	"fmt"
+)
+
+// Signature types of the blocks, stored in the signature_type of the users like the encryption and HMAC types
+const (
+	SignatureEd25519        = "ed25519"         // The blocks carry an Ed25519 signature
+	SignatureEd25519MLDSA65 = "ed25519-mldsa65" // The blocks carry an Ed25519 and an ML-DSA-65 signature, both must be valid
+)
+
+// MLDSAPublicKeySize is the size of the ML-DSA-65 public keys, before the Base64 encoding
+const MLDSAPublicKeySize = mldsa.MLDSA65PublicKeySize
+
+// ErrMissingPQSignature is returned when a block of a hybrid account has no ML-DSA signature
+var ErrMissingPQSignature = errors.New("the block has no ML-DSA signature")
+
+// ValidSignatureType tells whether a signature type is supported
+func ValidSignatureType(signatureType string) bool {
+	return signatureType == SignatureEd25519 || signatureType == SignatureEd25519MLDSA65
+}
+
+// IsHybridSignature tells whether the blocks of a signature type carry an ML-DSA signature next to the Ed25519 one
+func IsHybridSignature(signatureType string) bool {
+	return signatureType == SignatureEd25519MLDSA65
+}
+
+// HybridBlockSignaturePayload builds the bytes covered by both signatures of a hybrid block.
+// The "hybrid" prefix keeps the Ed25519 signature of a hybrid block from being valid once the ML-DSA signature
+// is stripped, so a hybrid block can never be passed off as an Ed25519 block.
+// Parameters:
+// - block: a pointer to the block
+// Returns: the payload to sign or verify
+func HybridBlockSignaturePayload(block *models.Block) []byte {
+	return append([]byte("hybrid"), BlockSignaturePayload(block)...)
+}
+
+// signedBlockPayload returns the payload the signatures of a block cover, depending on whether it is hybrid
+func signedBlockPayload(block *models.Block) []byte {
+	if block.PQSignature != "" {
+		return HybridBlockSignaturePayload(block)
+	}
+	return BlockSignaturePayload(block)
+}
+
+// ParseMLDSAPublicKey decodes a Base64 ML-DSA-65 public key.
+// Parameters:
+// - publicKeyBase64: the Base64-encoded public key
+// Returns: the public key, or an error if it is not a valid ML-DSA-65 key
+func ParseMLDSAPublicKey(publicKeyBase64 string) (*mldsa.PublicKey, error) {
+	publicKeyBytes, err := base64.StdEncoding.DecodeString(publicKeyBase64)
+	if err != nil {
+		return nil, errors.New("invalid public key format")
+	}
+	if len(publicKeyBytes) != MLDSAPublicKeySize {
+		return nil, errors.New("invalid public key size")
+	}
+	publicKey, err := mldsa.NewPublicKey(mldsa.MLDSA65(), publicKeyBytes)
+	if err != nil {
+		return nil, fmt.Errorf("invalid public key: %v", err)
+	}
+	return publicKey, nil
+}
+
+// VerifyMLDSASignature verifies an ML-DSA-65 signature with an empty context.
+// Parameters:
+// - publicKeyBase64: the Base64-encoded ML-DSA-65 public key
+// - payload: the signed bytes
+// - signatureBase64: the Base64-encoded signature
+// Returns: a boolean indicating whether the signature is valid, and an error if any input is invalid
+func VerifyMLDSASignature(publicKeyBase64 string, payload []byte, signatureBase64 string) (bool, error) {
+	publicKey, err := ParseMLDSAPublicKey(publicKeyBase64)
+	if err != nil {
+		return false, err
+	}
+
+	signatureBytes, err := base64.StdEncoding.DecodeString(signatureBase64)
+	if err != nil {
+		return false, errors.New("invalid signature format")
+	}
+	if len(signatureBytes) != mldsa.MLDSA65SignatureSize {
+		return false, errors.New("invalid signature size")
+	}
+
+	return mldsa.Verify(publicKey, payload, signatureBytes, nil) == nil, nil
+}
+
+// VerifyBlockMLDSASignature verifies the ML-DSA-65 signature of a hybrid block.
+// Parameters:
+// - publicKeyBase64: the Base64-encoded ML-DSA-65 public key
+// - block: a pointer to the block whose signature is to be verified
+// Returns: a boolean indicating whether the ML-DSA signature of the block is valid, and an error if any input is invalid
+func VerifyBlockMLDSASignature(publicKeyBase64 string, block *models.Block) (bool, error) {
+	if block.PQSignature == "" {
+		return false, ErrMissingPQSignature
+	}
+	return VerifyMLDSASignature(publicKeyBase64, HybridBlockSignaturePayload(block), block.PQSignature)
+}
+
+// VerifyBlockSignature verifies a block against the keys of its signer.
+// Without an ML-DSA key only the Ed25519 signature is checked and the block must not claim an ML-DSA signature,
+// with one both signatures must be present and valid.
+// Parameters:
+// - publicKeyBase64: the Base64-encoded Ed25519 public key
+// - pqPublicKeyBase64: the Base64-encoded ML-DSA-65 public key, empty for the Ed25519 signers
+// - block: a pointer to the block whose signatures are to be verified
+// Returns: a boolean indicating whether the block's signatures are valid, and an error if any input is invalid
+func VerifyBlockSignature(publicKeyBase64, pqPublicKeyBase64 string, block *models.Block) (bool, error) {
+	if pqPublicKeyBase64 == "" {
+		if block.PQSignature != "" {
+			return false, errors.New("the block has an ML-DSA signature but the signer has no ML-DSA key")
+		}
+		return VerifyBlockEd25519Signature(publicKeyBase64, block)
+	}
+
+	valid, err := VerifyBlockEd25519Signature(publicKeyBase64, block)
+	if err != nil || !valid {
+		return valid, err
+	}
+	return VerifyBlockMLDSASignature(pqPublicKeyBase64, block)
+}
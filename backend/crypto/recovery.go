@@ -0,0 +1,102 @@
+package crypto
+
+import (
+	"backend/models"
+)
+
+// Key envelopes hold the encryption and HMAC keys of an account sealed with AES-256-GCM, so they survive a change
+// of password. The server only stores them, they are sealed and opened by the clients:
+// Base64(nonce || ciphertext || tag), the plaintext being the encryption key followed by the HMAC key.
+const (
+	KeyEnvelopeNonceSize = 12 // Bytes of the AES-256-GCM nonce
+	KeyEnvelopeTagSize   = 16 // Bytes of the AES-256-GCM tag
+)
+
+// Additional data of the key envelopes, an envelope sealed for one purpose never opens for the other
+const (
+	KeyEnvelopeEscrow   = "recovery-escrow" // Sealed under the recovery key, the recovery_escrow of the user
+	KeyEnvelopePassword = "wrapped-keys"    // Sealed under a key derived from the password, the wrapped_keys of the user
+)
+
+// RecoveryKeySize is the size of the offline recovery keys, they are random and never derived from a password
+const RecoveryKeySize = 32
+
+// KeyEnvelopeSize returns the size of the key envelopes of a suite, before the Base64 encoding
+func KeyEnvelopeSize(suite *Suite) int {
+	return KeyEnvelopeNonceSize + suite.Cipher.KeySize + suite.MAC.KeySize + KeyEnvelopeTagSize
+}
+
+// ValidateKeyEnvelope checks that a key envelope has the size of the keys of a suite.
+// Parameters:
+// - envelopeBase64: the Base64 envelope
+// - suite: a pointer to the suite of the account
+// Returns: an error if the envelope is not valid Base64 or has the wrong size
+func ValidateKeyEnvelope(envelopeBase64 string, suite *Suite) error {
+	return checkEncodedSize("key envelope", envelopeBase64, KeyEnvelopeSize(suite))
+}
+
+// RecoveryEnrollmentPayload builds the bytes the recovery key signs at registration.
+// It binds the recovery key to the Ed25519 identity of the account and to its escrowed keys.
+// Parameters:
+// - publicKeyBase64: the Ed25519 public key of the account
+// - recoveryKeyBase64: the Ed25519 public key of the recovery key
+// - escrowBase64: the key envelope sealed under the recovery key
+// Returns: the payload to sign or verify
+func RecoveryEnrollmentPayload(publicKeyBase64, recoveryKeyBase64, escrowBase64 string) []byte {
+	return []byte("recovery_enroll" + publicKeyBase64 + ":" + recoveryKeyBase64 + ":" + escrowBase64)
+}
+
+// RecoveryPayload builds the bytes signed by the recovery key and the new login key to re-key a forgotten password.
+// Like the KDF upgrade payload it names the current public key of the account, so it cannot be replayed, and
+// prefixes every field with its length in bytes.
+// Parameters:
+// - email: the email of the account
+// - oldKeyBase64: the current public key of the account
+// - newKeyBase64: the public key derived from the new password
+// - newPQKeyBase64: the ML-DSA public key derived from the new login key, empty for the Ed25519 accounts
+// - loginSalt: the new Base64 login salt
+// - params: the KDF parameters of the new login key
+// - wrappedKeys: the key envelope sealed under the new password
+// Returns: the payload to sign or verify
+func RecoveryPayload(email, oldKeyBase64, newKeyBase64, newPQKeyBase64, loginSalt string, params models.KDFParams, wrappedKeys string) []byte {
+	return lengthPrefixed("account_recovery", email, oldKeyBase64, newKeyBase64, loginSalt, kdfField(params),
+		wrappedKeys, newPQKeyBase64)
+}
+
+// VerifyRecoveryEd25519Signature verifies a signature over a recovery enrollment or a recovery payload.
+// Parameters:
+// - publicKeyBase64: the Base64-encoded Ed25519 public key, the recovery key or the new login key
+// - payload: the payload built by RecoveryEnrollmentPayload or RecoveryPayload
+// - signatureBase64: the Base64-encoded signature
+// Returns: a boolean indicating whether the signature is valid, and an error if any input is invalid
+func VerifyRecoveryEd25519Signature(publicKeyBase64 string, payload []byte, signatureBase64 string) (bool, error) {
+	return verifyEd25519Payload(publicKeyBase64, payload, signatureBase64)
+}
+
+// Social recovery splits the recovery key into shares with Shamir's secret sharing over GF(256): a share is its
+// x coordinate followed by one byte per byte of the recovery key. Each share is sealed to the Ed25519 key of a
+// trusted contact, converted to X25519: Base64(ephemeral X25519 key || AES-256-GCM ciphertext || tag), the
+// AES key being HKDF-SHA256 of the shared secret, salted with both public keys and with SealedShareInfo as info.
+// The contacts seal the shares they submit the same way, to the ephemeral X25519 key of the recovery.
+const (
+	RecoveryShareSize = 1 + RecoveryKeySize                                    // Bytes of a share, x coordinate included
+	X25519KeySize     = 32                                                     // Bytes of the X25519 public keys
+	SealedShareSize   = X25519KeySize + RecoveryShareSize + KeyEnvelopeTagSize // Bytes of a sealed share
+	SealedShareInfo   = "recovery-share"                                       // HKDF info of the keys sealing the shares
+)
+
+// ValidateSealedShare checks that a sealed share has the size of a share of a recovery key.
+// Parameters:
+// - shareBase64: the Base64 sealed share
+// Returns: an error if the share is not valid Base64 or has the wrong size
+func ValidateSealedShare(shareBase64 string) error {
+	return checkEncodedSize("encrypted share", shareBase64, SealedShareSize)
+}
+
+// ValidateX25519Key checks that a public key has the size of an X25519 key.
+// Parameters:
+// - publicKeyBase64: the Base64 public key
+// Returns: an error if the key is not valid Base64 or has the wrong size
+func ValidateX25519Key(publicKeyBase64 string) error {
+	return checkEncodedSize("ephemeral key", publicKeyBase64, X25519KeySize)
+}
@@ -0,0 +1,37 @@
+package crypto_test
+
+import (
+	"backend/crypto"
+	"encoding/base64"
+	"testing"
+)
+
+func TestRecoveryShareSizes(t *testing.T) {
+	encoded := func(size int) string {
+		return base64.StdEncoding.EncodeToString(make([]byte, size))
+	}
+
+	tests := []struct {
+		name     string
+		validate func(string) error
+		size     int
+	}{
+		{"sealed share", crypto.ValidateSealedShare, crypto.SealedShareSize},
+		{"X25519 key", crypto.ValidateX25519Key, crypto.X25519KeySize},
+	}
+	for _, tt := range tests {
+		if err := tt.validate(encoded(tt.size)); err != nil {
+			t.Errorf("%s of %d bytes: %v", tt.name, tt.size, err)
+		}
+		for _, value := range []string{"", encoded(tt.size - 1), encoded(tt.size + 1), "not base64!"} {
+			if tt.validate(value) == nil {
+				t.Errorf("%s %q was accepted", tt.name, value)
+			}
+		}
+	}
+
+	// A share is the x coordinate followed by one byte per byte of the recovery key
+	if crypto.RecoveryShareSize != 1+crypto.RecoveryKeySize {
+		t.Errorf("RecoveryShareSize is %d, want %d", crypto.RecoveryShareSize, 1+crypto.RecoveryKeySize)
+	}
+}
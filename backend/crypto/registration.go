@@ -0,0 +1,104 @@
+package crypto
+
+import (
+	"backend/models"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"filippo.io/edwards25519"
+)
+
+// RegistrationSaltSize is the size of the login, encryption and HMAC salts of a new account
+const RegistrationSaltSize = 32
+
+// RegistrationProfile holds the fields of a registration signed by the new account, as the client sent them
+type RegistrationProfile struct {
+	Email             string
+	Name              string
+	LoginSalt         string
+	EncryptionSalt    string
+	HMACSalt          string
+	PublicKey         string
+	HMACType          string
+	EncryptionType    string
+	Suites            []uint16          // Suites offered by the client, empty when it named the algorithms
+	KDF               *models.KDFParams // nil for the clients deriving the login key with LegacyKDF
+	SignatureType     string
+	PQPublicKey       string
+	RecoveryPublicKey string
+	RecoveryEscrow    string
+}
+
+// RegistrationPayload builds the bytes a new account signs with its login key, and its ML-DSA key for the hybrid
+// signature types, to prove it holds them. The "register" prefix and the challenge are followed by every field
+// of the profile prefixed with its length in bytes, so a field cannot run into the next one.
+// Parameters:
+// - challenge: the Base64 registration challenge
+// - profile: a pointer to the fields of the registration
+// Returns: the payload to sign or verify
+func RegistrationPayload(challenge string, profile *RegistrationProfile) []byte {
+	suites := make([]string, len(profile.Suites))
+	for i, id := range profile.Suites {
+		suites[i] = strconv.Itoa(int(id))
+	}
+	var kdf string
+	if profile.KDF != nil {
+		kdf = kdfField(*profile.KDF)
+	}
+
+	return lengthPrefixed("register"+challenge, profile.Email, profile.Name, profile.LoginSalt, profile.EncryptionSalt,
+		profile.HMACSalt, profile.PublicKey, profile.HMACType, profile.EncryptionType, strings.Join(suites, ","), kdf,
+		profile.SignatureType, profile.PQPublicKey, profile.RecoveryPublicKey, profile.RecoveryEscrow)
+}
+
+// lengthPrefixed builds a signed payload: a prefix naming what is signed, then every field as ":" length ":" field,
+// the length in bytes, so a field cannot run into the next one
+func lengthPrefixed(prefix string, fields ...string) []byte {
+	var payload strings.Builder
+	payload.WriteString(prefix)
+	for _, field := range fields {
+		fmt.Fprintf(&payload, ":%d:%s", len(field), field)
+	}
+	return []byte(payload.String())
+}
+
+// kdfField formats KDF parameters as a field of a signed payload
+func kdfField(params models.KDFParams) string {
+	return fmt.Sprintf("%s:%d:%d:%d", params.Algorithm, params.Iterations, params.MemoryKiB, params.Parallelism)
+}
+
+// ValidateEd25519PublicKey strictly decodes an Ed25519 public key and checks it is a point of the curve that
+// does not have a small order, such a key would verify forged signatures.
+// Parameters:
+// - publicKeyBase64: the Base64 public key, padded and without trailing bits
+// Returns: an error describing why the key is invalid
+func ValidateEd25519PublicKey(publicKeyBase64 string) error {
+	publicKey, err := base64.StdEncoding.Strict().DecodeString(publicKeyBase64)
+	if err != nil || len(publicKey) != 32 {
+		return errors.New("public key must be 32 bytes encoded in base64")
+	}
+	point, err := new(edwards25519.Point).SetBytes(publicKey)
+	if err != nil {
+		return errors.New("public key is not a point of Ed25519")
+	}
+	if new(edwards25519.Point).MultByCofactor(point).Equal(edwards25519.NewIdentityPoint()) == 1 {
+		return errors.New("public key has a small order")
+	}
+	return nil
+}
+
+// ValidateSalt strictly decodes a salt of a new account.
+// Parameters:
+// - name: the name of the salt, for the error
+// - saltBase64: the Base64 salt, padded and without trailing bits
+// Returns: an error if the salt is not RegistrationSaltSize bytes of strict Base64
+func ValidateSalt(name, saltBase64 string) error {
+	salt, err := base64.StdEncoding.Strict().DecodeString(saltBase64)
+	if err != nil || len(salt) != RegistrationSaltSize {
+		return fmt.Errorf("%s must be %d bytes encoded in base64", name, RegistrationSaltSize)
+	}
+	return nil
+}
@@ -3,6 +3,7 @@ package crypto
 import (
 	"crypto/rand"
 	"encoding/base64"
+	"encoding/hex"
 	"io"
 )
 
@@ -27,3 +28,15 @@ func GenerateSaltBase64(size int) (string, error) {
 	}
 	return base64.StdEncoding.EncodeToString(salt), nil
 }
+
+// GenerateIDHex generates a cryptographically secure random identifier encoded in lowercase hexadecimal.
+// Parameters:
+// - size: the size of the identifier in bytes
+// Returns: a hex string of 2*size characters, or an error if the generation fails
+func GenerateIDHex(size int) (string, error) {
+	id, err := GenerateSalt(size)
+	if err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(id), nil
+}
@@ -0,0 +1,212 @@
+package crypto
+
+import (
+	"backend/models"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"log"
+	"strconv"
+	"time"
+
+	"golang.org/x/crypto/ed25519"
+)
+
+var (
+	serverPrivateKey ed25519.PrivateKey
+	serverKeyID      string
+)
+
+// SetServerSigningKey sets the Ed25519 key the server signs its receipts with.
+// Parameters:
+// - seedBase64: the Base64-encoded 32 byte seed of the key, an empty seed generates an ephemeral key, which the
+// configuration only allows in development
+// Returns: an error if the seed is invalid
+func SetServerSigningKey(seedBase64 string) error {
+	var seed []byte
+	if seedBase64 == "" {
+		// Receipts signed with an ephemeral key can no longer be verified once the server restarts
+		log.Println("WARNING: SERVER_SIGNING_KEY is not set, using an ephemeral server signing key for development")
+		var err error
+		seed, err = GenerateSalt(ed25519.SeedSize)
+		if err != nil {
+			return err
+		}
+	} else {
+		var err error
+		seed, err = base64.StdEncoding.DecodeString(seedBase64)
+		if err != nil {
+			return errors.New("invalid server signing key format")
+		}
+		if len(seed) != ed25519.SeedSize {
+			return errors.New("invalid server signing key size")
+		}
+	}
+
+	serverPrivateKey = ed25519.NewKeyFromSeed(seed)
+	serverKeyID = KeyID(serverPrivateKey.Public().(ed25519.PublicKey))
+	return nil
+}
+
+// KeyID computes the identifier of a public key.
+// Parameters:
+// - publicKey: the raw public key
+// Returns: the first 8 bytes of the SHA-256 hash of the key, hex encoded
+func KeyID(publicKey []byte) string {
+	hash := sha256.Sum256(publicKey)
+	return hex.EncodeToString(hash[:8])
+}
+
+// ServerPublicKey returns the public half of the server signing key.
+// Returns: the published server key, or an error if SetServerSigningKey was not called
+func ServerPublicKey() (*models.ServerKey, error) {
+	if serverPrivateKey == nil {
+		return nil, errors.New("server signing key is not set")
+	}
+	return &models.ServerKey{
+		KeyID:     serverKeyID,
+		Algorithm: "ed25519",
+		PublicKey: base64.StdEncoding.EncodeToString(serverPrivateKey.Public().(ed25519.PublicKey)),
+	}, nil
+}
+
+// receiptPayload builds the bytes covered by the signature of a receipt.
+// The "receipt" prefix keeps a receipt signature from ever being valid for another kind of message.
+func receiptPayload(receipt *models.Receipt) []byte {
+	return []byte("receipt" + receipt.NoteID + strconv.FormatUint(uint64(receipt.Seq), 10) +
+		receipt.BlockHash + receipt.ReceivedAt.Format(time.RFC3339) + receipt.KeyID)
+}
+
+// SignReceipt signs a receipt with the server signing key.
+// Parameters:
+// - receipt: a pointer to the receipt to sign, its key ID and signature are set
+// Returns: an error if SetServerSigningKey was not called
+func SignReceipt(receipt *models.Receipt) error {
+	if serverPrivateKey == nil {
+		return errors.New("server signing key is not set")
+	}
+
+	receipt.KeyID = serverKeyID
+	signature := ed25519.Sign(serverPrivateKey, receiptPayload(receipt))
+	receipt.Signature = base64.StdEncoding.EncodeToString(signature)
+	return nil
+}
+
+// VerifyReceipt verifies the signature of a receipt.
+// Parameters:
+// - publicKeyBase64: the Base64-encoded Ed25519 public key of the server
+// - receipt: a pointer to the receipt whose signature is to be verified
+// Returns: a boolean indicating whether the receipt's signature is valid, and an error if any input is invalid
+func VerifyReceipt(publicKeyBase64 string, receipt *models.Receipt) (bool, error) {
+	publicKeyBytes, err := base64.StdEncoding.DecodeString(publicKeyBase64)
+	if err != nil {
+		return false, errors.New("invalid public key format")
+	}
+	if len(publicKeyBytes) != ed25519.PublicKeySize {
+		return false, errors.New("invalid public key size")
+	}
+	if receipt.KeyID != KeyID(publicKeyBytes) {
+		return false, nil
+	}
+
+	signatureBytes, err := base64.StdEncoding.DecodeString(receipt.Signature)
+	if err != nil {
+		return false, errors.New("invalid signature format")
+	}
+
+	return ed25519.Verify(ed25519.PublicKey(publicKeyBytes), receiptPayload(receipt), signatureBytes), nil
+}
+
+// treeHeadPayload builds the bytes covered by the signature of a tree head
+func treeHeadPayload(sth *models.SignedTreeHead) []byte {
+	return []byte("tree_head" + strconv.Itoa(sth.TreeSize) + sth.RootHash + sth.Timestamp.Format(time.RFC3339) + sth.KeyID)
+}
+
+// SignTreeHead signs a tree head of the transparency log with the server signing key.
+// Parameters:
+// - sth: a pointer to the tree head to sign, its key ID and signature are set
+// Returns: an error if SetServerSigningKey was not called
+func SignTreeHead(sth *models.SignedTreeHead) error {
+	if serverPrivateKey == nil {
+		return errors.New("server signing key is not set")
+	}
+
+	sth.KeyID = serverKeyID
+	signature := ed25519.Sign(serverPrivateKey, treeHeadPayload(sth))
+	sth.Signature = base64.StdEncoding.EncodeToString(signature)
+	return nil
+}
+
+// VerifyTreeHead verifies the signature of a tree head of the transparency log.
+// Parameters:
+// - publicKeyBase64: the Base64-encoded Ed25519 public key of the server
+// - sth: a pointer to the tree head whose signature is to be verified
+// Returns: a boolean indicating whether the tree head's signature is valid, and an error if any input is invalid
+func VerifyTreeHead(publicKeyBase64 string, sth *models.SignedTreeHead) (bool, error) {
+	publicKeyBytes, err := base64.StdEncoding.DecodeString(publicKeyBase64)
+	if err != nil {
+		return false, errors.New("invalid public key format")
+	}
+	if len(publicKeyBytes) != ed25519.PublicKeySize {
+		return false, errors.New("invalid public key size")
+	}
+	if sth.KeyID != KeyID(publicKeyBytes) {
+		return false, nil
+	}
+
+	signatureBytes, err := base64.StdEncoding.DecodeString(sth.Signature)
+	if err != nil {
+		return false, errors.New("invalid signature format")
+	}
+
+	return ed25519.Verify(ed25519.PublicKey(publicKeyBytes), treeHeadPayload(sth), signatureBytes), nil
+}
+
+// vaultManifestPayload builds the bytes covered by the signature of a vault manifest
+func vaultManifestPayload(manifest []byte) []byte {
+	return append([]byte("vault_manifest"), manifest...)
+}
+
+// SignVaultManifest signs the manifest file of a vault archive with the server signing key.
+// Parameters:
+// - manifest: the exact bytes of the manifest file
+// Returns: the signature, or an error if SetServerSigningKey was not called
+func SignVaultManifest(manifest []byte) (*models.VaultSignature, error) {
+	if serverPrivateKey == nil {
+		return nil, errors.New("server signing key is not set")
+	}
+
+	signature := ed25519.Sign(serverPrivateKey, vaultManifestPayload(manifest))
+	return &models.VaultSignature{
+		KeyID:     serverKeyID,
+		Algorithm: "ed25519",
+		Signature: base64.StdEncoding.EncodeToString(signature),
+	}, nil
+}
+
+// VerifyVaultManifest verifies the server signature over the manifest file of a vault archive.
+// Parameters:
+// - publicKeyBase64: the Base64-encoded Ed25519 public key of the server
+// - manifest: the exact bytes of the manifest file
+// - signature: a pointer to the signature to verify
+// Returns: a boolean indicating whether the signature is valid, and an error if any input is invalid
+func VerifyVaultManifest(publicKeyBase64 string, manifest []byte, signature *models.VaultSignature) (bool, error) {
+	publicKeyBytes, err := base64.StdEncoding.DecodeString(publicKeyBase64)
+	if err != nil {
+		return false, errors.New("invalid public key format")
+	}
+	if len(publicKeyBytes) != ed25519.PublicKeySize {
+		return false, errors.New("invalid public key size")
+	}
+	if signature.KeyID != KeyID(publicKeyBytes) {
+		return false, nil
+	}
+
+	signatureBytes, err := base64.StdEncoding.DecodeString(signature.Signature)
+	if err != nil {
+		return false, errors.New("invalid signature format")
+	}
+
+	return ed25519.Verify(ed25519.PublicKey(publicKeyBytes), vaultManifestPayload(manifest), signatureBytes), nil
+}
@@ -0,0 +1,192 @@
+package crypto
+
+import (
+	"backend/models"
+	"crypto/sha256"
+	"crypto/sha512"
+	"errors"
+	"fmt"
+	"hash"
+	"sort"
+)
+
+// HMAC types a user can choose at registration, stored in their hmac_type
+const (
+	HMACSHA256 = "hmac-sha256"
+	HMACSHA512 = "hmac-sha512"
+)
+
+// Errors returned by the suite lookups
+var (
+	ErrUnknownSuite  = errors.New("unknown crypto suite")
+	ErrNoCommonSuite = errors.New("no supported crypto suite in common")
+)
+
+// Cipher describes an encryption type
+type Cipher struct {
+	Name    string `json:"name"`               // Value of the encryption_type of the users
+	KeySize int    `json:"key_size"`           // Bytes of the key derived from the encryption salt
+	IVSize  int    `json:"iv_size"`            // Bytes of the IVs, or of the nonces of the AEAD ciphers
+	TagSize int    `json:"tag_size,omitempty"` // Bytes of the authentication tags of the AEAD ciphers
+	AEAD    bool   `json:"aead"`               // The blocks carry nonces and tags instead of IVs and a MAC
+}
+
+// MAC describes an HMAC type. Its key authenticates the blocks of the AES-128 ciphers and keys the search tokens.
+type MAC struct {
+	Name      string           `json:"name"`     // Value of the hmac_type of the users
+	Hash      string           `json:"hash"`     // Hash function of the HMAC and of the PBKDF2 of its key
+	KeySize   int              `json:"key_size"` // Bytes of the key derived from the HMAC salt
+	New       func() hash.Hash `json:"-"`
+	JWTMethod string           `json:"-"` // Signing method of the session tokens of the users
+}
+
+// KDF describes how the encryption and HMAC keys are derived from the password and the salts of a user.
+// The login key has its own parameters per user, see models.KDFParams.
+type KDF struct {
+	Algorithm  string `json:"algorithm"`
+	Hash       string `json:"hash"`
+	Iterations int    `json:"iterations"`
+}
+
+// Suite is a combination of algorithms a user is registered with. Users and blocks reference it by its ID,
+// so a suite is never changed or removed once published: a weaker one is deprecated, new accounts cannot
+// choose it and the existing accounts keep working.
+type Suite struct {
+	ID          uint16  `json:"id"`
+	Name        string  `json:"name"`
+	Cipher      *Cipher `json:"cipher"`
+	MAC         *MAC    `json:"mac"`
+	KDF         KDF     `json:"kdf"`
+	Signature   string  `json:"signature"`    // Signature scheme of the blocks, the tombstones and the login challenges
+	HashVersion int     `json:"hash_version"` // 1: Base64 SHA-256 of the JSON block
+	Deprecated  bool    `json:"deprecated"`
+}
+
+// ciphers is every supported encryption type
+var ciphers = map[string]*Cipher{
+	EncryptionAES128CBC:         {Name: EncryptionAES128CBC, KeySize: 16, IVSize: 16},
+	EncryptionAES128CTR:         {Name: EncryptionAES128CTR, KeySize: 16, IVSize: 16},
+	EncryptionAES256GCM:         {Name: EncryptionAES256GCM, KeySize: 32, IVSize: 12, TagSize: 16, AEAD: true},
+	EncryptionXChaCha20Poly1305: {Name: EncryptionXChaCha20Poly1305, KeySize: 32, IVSize: 24, TagSize: 16, AEAD: true},
+}
+
+// macs is every supported HMAC type
+var macs = map[string]*MAC{
+	HMACSHA256: {Name: HMACSHA256, Hash: "sha256", KeySize: sha256.Size, New: sha256.New, JWTMethod: "HS256"},
+	HMACSHA512: {Name: HMACSHA512, Hash: "sha512", KeySize: sha512.Size, New: sha512.New, JWTMethod: "HS512"},
+}
+
+// pbkdf2SHA256 is the derivation of the encryption and HMAC keys of every suite, the same as keyDerivation.ts
+var pbkdf2SHA256 = KDF{Algorithm: "pbkdf2", Hash: "sha256", Iterations: 100_000}
+
+// suites is the registry, indexed by ID. The IDs are stored in the users and blocks tables and must never be reused.
+// AES-128-CBC is deprecated: its blocks carry two layers of padding and are only authenticated by the separate HMAC.
+var suites = map[uint16]*Suite{
+	1: newSuite(1, EncryptionAES128CBC, HMACSHA256, true),
+	2: newSuite(2, EncryptionAES128CBC, HMACSHA512, true),
+	3: newSuite(3, EncryptionAES128CTR, HMACSHA256, false),
+	4: newSuite(4, EncryptionAES128CTR, HMACSHA512, false),
+	5: newSuite(5, EncryptionAES256GCM, HMACSHA256, false),
+	6: newSuite(6, EncryptionAES256GCM, HMACSHA512, false),
+	7: newSuite(7, EncryptionXChaCha20Poly1305, HMACSHA256, false),
+	8: newSuite(8, EncryptionXChaCha20Poly1305, HMACSHA512, false),
+}
+
+// DefaultSuiteID is the suite picked when a client registers without stating a preference
+const DefaultSuiteID uint16 = 7
+
+// newSuite builds a suite of the registry from a cipher and a MAC
+func newSuite(id uint16, encryptionType, hmacType string, deprecated bool) *Suite {
+	return &Suite{
+		ID:          id,
+		Name:        encryptionType + "+" + hmacType,
+		Cipher:      ciphers[encryptionType],
+		MAC:         macs[hmacType],
+		KDF:         pbkdf2SHA256,
+		Signature:   "ed25519",
+		HashVersion: 1,
+		Deprecated:  deprecated,
+	}
+}
+
+// Suites lists the registry, deprecated suites included so the clients of the older accounts can find theirs.
+// Returns: every suite, ordered by ID
+func Suites() []*Suite {
+	list := make([]*Suite, 0, len(suites))
+	for _, suite := range suites {
+		list = append(list, suite)
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].ID < list[j].ID })
+	return list
+}
+
+// LookupSuite finds a suite by its ID.
+// Parameters:
+// - id: the ID of the suite
+// Returns: a pointer to the suite, or ErrUnknownSuite
+func LookupSuite(id uint16) (*Suite, error) {
+	suite, ok := suites[id]
+	if !ok {
+		return nil, fmt.Errorf("%w: %d", ErrUnknownSuite, id)
+	}
+	return suite, nil
+}
+
+// SuiteFor finds the suite of an encryption type and an HMAC type.
+// Parameters:
+// - encryptionType: the encryption type
+// - hmacType: the HMAC type
+// Returns: a pointer to the suite, or ErrUnknownSuite if the combination is not supported
+func SuiteFor(encryptionType, hmacType string) (*Suite, error) {
+	for _, suite := range suites {
+		if suite.Cipher.Name == encryptionType && suite.MAC.Name == hmacType {
+			return suite, nil
+		}
+	}
+	return nil, fmt.Errorf("%w: %s with %s", ErrUnknownSuite, encryptionType, hmacType)
+}
+
+// SuiteOf finds the suite of a user. The accounts created before the registry have no suite ID, their suite
+// is found from their encryption and HMAC types.
+// Parameters:
+// - user: a pointer to the user
+// Returns: a pointer to the suite, or ErrUnknownSuite
+func SuiteOf(user *models.User) (*Suite, error) {
+	if user.SuiteID != 0 {
+		return LookupSuite(user.SuiteID)
+	}
+	return SuiteFor(user.EncryptionType, user.HMACType)
+}
+
+// NegotiateSuite picks the suite of a new account from the preferences of its client.
+// Parameters:
+// - preferred: the IDs of the suites the client supports, most preferred first, may be empty
+// Returns: the first supported suite that is not deprecated, the default suite if there are no preferences,
+// or ErrNoCommonSuite
+func NegotiateSuite(preferred []uint16) (*Suite, error) {
+	if len(preferred) == 0 {
+		return LookupSuite(DefaultSuiteID)
+	}
+	for _, id := range preferred {
+		if suite, ok := suites[id]; ok && !suite.Deprecated {
+			return suite, nil
+		}
+	}
+	return nil, ErrNoCommonSuite
+}
+
+// LookupCipher finds an encryption type.
+// Parameters:
+// - encryptionType: the encryption type
+// Returns: a pointer to the cipher, nil if the type is not supported
+func LookupCipher(encryptionType string) *Cipher {
+	return ciphers[encryptionType]
+}
+
+// LookupMAC finds an HMAC type.
+// Parameters:
+// - hmacType: the HMAC type
+// Returns: a pointer to the MAC, nil if the type is not supported
+func LookupMAC(hmacType string) *MAC {
+	return macs[hmacType]
+}
@@ -0,0 +1,122 @@
+package crypto_test
+
+import (
+	"backend/crypto"
+	"backend/models"
+	"backend/testvectors"
+	"encoding/json"
+	"testing"
+)
+
+// loadVectors reads the committed vectors or fails the test
+func loadVectors(t *testing.T) *testvectors.Vectors {
+	t.Helper()
+	vectors, err := testvectors.Load()
+	if err != nil {
+		t.Fatalf("loading the vectors: %v", err)
+	}
+	if len(vectors.Users) == 0 {
+		t.Fatal("the vectors have no users")
+	}
+	return vectors
+}
+
+func TestBlockHashVectors(t *testing.T) {
+	for _, user := range loadVectors(t).Users {
+		for _, note := range user.Notes {
+			for i, version := range note.Versions {
+				blockJSON, err := json.Marshal(version.Block)
+				if err != nil {
+					t.Fatalf("%s version %d: %v", user.Name, i+1, err)
+				}
+				if string(blockJSON) != version.BlockJSON {
+					t.Errorf("%s version %d: the JSON of models.Block changed\n got: %s\nwant: %s", user.Name, i+1, blockJSON, version.BlockJSON)
+				}
+
+				hash, err := crypto.BlockHash(version.Block)
+				if err != nil {
+					t.Fatalf("%s version %d: %v", user.Name, i+1, err)
+				}
+				if hash != version.BlockHash {
+					t.Errorf("%s version %d: got hash %s, want %s", user.Name, i+1, hash, version.BlockHash)
+				}
+			}
+		}
+	}
+}
+
+func TestBlockChainVectors(t *testing.T) {
+	for _, user := range loadVectors(t).Users {
+		for _, note := range user.Notes {
+			blocks := make([]models.Block, len(note.Versions))
+			for i, version := range note.Versions {
+				blocks[i] = version.Block
+			}
+
+			valid, err := crypto.VerifyBlockChain(blocks)
+			if err != nil || !valid {
+				t.Errorf("%s: the chain does not verify: %v", user.Name, err)
+			}
+
+			// Dropping a version must break the chain
+			valid, _ = crypto.VerifyBlockChain(append([]models.Block{blocks[0]}, blocks[2:]...))
+			if valid {
+				t.Errorf("%s: a chain with a missing version verifies", user.Name)
+			}
+		}
+	}
+}
+
+func TestBlockSignatureVectors(t *testing.T) {
+	for _, user := range loadVectors(t).Users {
+		for _, note := range user.Notes {
+			for i, version := range note.Versions {
+				block := version.Block
+				valid, err := crypto.VerifyBlockSignature(user.PublicKey, user.PQPublicKey, &block)
+				if err != nil || !valid {
+					t.Errorf("%s version %d: the signature does not verify: %v", user.Name, i+1, err)
+				}
+
+				// A hybrid block stripped of its ML-DSA signature must not pass for an Ed25519 block
+				if block.PQSignature != "" {
+					stripped := block
+					stripped.PQSignature = ""
+					if valid, _ := crypto.VerifyBlockEd25519Signature(user.PublicKey, &stripped); valid {
+						t.Errorf("%s version %d: the Ed25519 signature verifies without the ML-DSA signature", user.Name, i+1)
+					}
+				}
+
+				block.Ciphertext = version.Block.CipherTitle
+				if valid, _ := crypto.VerifyBlockSignature(user.PublicKey, user.PQPublicKey, &block); valid {
+					t.Errorf("%s version %d: the signature verifies a modified block", user.Name, i+1)
+				}
+			}
+		}
+	}
+}
+
+func TestTombstoneVectors(t *testing.T) {
+	for _, user := range loadVectors(t).Users {
+		for _, note := range user.Notes {
+			block := note.Tombstone.Block(models.BlockKindTombstone)
+			valid, err := crypto.VerifyBlockSignature(user.PublicKey, user.PQPublicKey, &block)
+			if err != nil || !valid {
+				t.Errorf("%s: the tombstone does not verify: %v", user.Name, err)
+			}
+
+			blockJSON, err := json.Marshal(block)
+			if err != nil || string(blockJSON) != note.TombstoneBlockJSON {
+				t.Errorf("%s: got tombstone block JSON %s, want %s", user.Name, blockJSON, note.TombstoneBlockJSON)
+			}
+			if hash, err := crypto.BlockHash(block); err != nil || hash != note.TombstoneHash {
+				t.Errorf("%s: got tombstone hash %s, want %s", user.Name, hash, note.TombstoneHash)
+			}
+
+			// A tombstone never verifies as a restore of the same head
+			restore := note.Tombstone.Block(models.BlockKindRestore)
+			if valid, _ := crypto.VerifyBlockSignature(user.PublicKey, user.PQPublicKey, &restore); valid {
+				t.Errorf("%s: the tombstone verifies as a restore", user.Name)
+			}
+		}
+	}
+}
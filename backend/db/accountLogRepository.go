@@ -0,0 +1,232 @@
+package db
+
+import (
+	"backend/crypto"
+	"backend/models"
+	"database/sql"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// AccountLogRepository provides methods to read the append-only account log of a user.
+// Every change of the head of a note appends a leaf, the Merkle tree over these leaves lets
+// a client detect a note being silently dropped or rolled back to an older head.
+// Fields:
+// - DB: a pointer to the SQL database connection
+type AccountLogRepository struct {
+	DB *sql.DB
+}
+
+// NewAccountLogRepository creates a new instance of AccountLogRepository.
+// Parameters:
+// - db: a pointer to the SQL database connection
+// Returns: a pointer to the newly created AccountLogRepository
+func NewAccountLogRepository(db *sql.DB) *AccountLogRepository {
+	return &AccountLogRepository{
+		DB: db,
+	}
+}
+
+// GetLeaves retrieves the first leaves of the account log of a user.
+// Parameters:
+// - userID: the ID of the user
+// - treeSize: the number of leaves to return, 0 returns the whole log
+// Returns: the leaves ordered by index, or an error if a query error occurs
+func (r *AccountLogRepository) GetLeaves(userID uint32, treeSize int) ([]*models.LogLeaf, error) {
+	query := `
+		SELECT leaf_index, note_id, event, head_hash, leaf_hash, created_at
+		FROM account_log
+		WHERE user_id = ?
+		ORDER BY leaf_index
+	`
+	args := []any{userID}
+	if treeSize > 0 {
+		query += " LIMIT ?"
+		args = append(args, treeSize)
+	}
+
+	rows, err := r.DB.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var leaves []*models.LogLeaf
+	for rows.Next() {
+		leaf := &models.LogLeaf{}
+		if err := rows.Scan(
+			&leaf.Index,
+			&leaf.NoteID,
+			&leaf.Event,
+			&leaf.HeadHash,
+			&leaf.LeafHash,
+			&leaf.CreatedAt,
+		); err != nil {
+			return nil, err
+		}
+		leaves = append(leaves, leaf)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return leaves, nil
+}
+
+// appendAccountLeaf appends a leaf to the account log of a user inside a transaction.
+// The user row is locked so two concurrent changes of the same account get consecutive indexes.
+// Parameters:
+// - tx: the transaction changing the head of the note
+// - userID: the ID of the user
+// - noteID: the ID of the note
+// - event: the event to record, one of the models.LogEvent constants
+// - headHash: the hash of the head of the note after the event
+// Returns: an error if the insertion fails
+func appendAccountLeaf(tx *sql.Tx, userID uint32, noteID string, event string, headHash string) error {
+	const lockQuery = `SELECT id FROM users WHERE id = ? FOR UPDATE`
+	var lockedID uint32
+	if err := tx.QueryRow(lockQuery, userID).Scan(&lockedID); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return fmt.Errorf("user %d not found", userID)
+		}
+		return err
+	}
+
+	treeSize, frontier, err := loadAccountFrontier(tx, userID)
+	if err != nil {
+		return err
+	}
+
+	const insertQuery = `
+		INSERT INTO account_log (user_id, leaf_index, note_id, event, head_hash, leaf_hash)
+		VALUES (?, ?, ?, ?, ?, ?)
+	`
+	leafHash := crypto.AccountLeafHash(event, noteID, headHash)
+	if _, err := tx.Exec(insertQuery, userID, treeSize, noteID, event, headHash, leafHash); err != nil {
+		return fmt.Errorf("error appending to the account log: %v", err)
+	}
+
+	leaf, _ := base64.StdEncoding.DecodeString(leafHash)
+	return storeAccountFrontier(tx, userID, treeSize+1, crypto.MerkleFrontierAppend(frontier, treeSize, leaf))
+}
+
+// GetTreeHead retrieves the size and the root of the account log of a user, kept up to date by every append so
+// listing the notes never reads the whole log. A log written before the tree heads were stored is read once.
+// Parameters:
+// - userID: the ID of the user
+// Returns: the tree head, or an error if a query error occurs
+func (r *AccountLogRepository) GetTreeHead(userID uint32) (models.TreeHead, error) {
+	const query = `SELECT tree_size, root_hash FROM account_log_heads WHERE user_id = ?`
+	var head models.TreeHead
+	err := r.DB.QueryRow(query, userID).Scan(&head.TreeSize, &head.RootHash)
+	if err == nil {
+		return head, nil
+	}
+	if !errors.Is(err, sql.ErrNoRows) {
+		return head, err
+	}
+
+	// Store the tree head of the older log, under the same lock as the appends
+	tx, err := r.DB.Begin()
+	if err != nil {
+		return head, err
+	}
+	defer tx.Rollback()
+
+	var lockedID uint32
+	if err := tx.QueryRow(`SELECT id FROM users WHERE id = ? FOR UPDATE`, userID).Scan(&lockedID); err != nil {
+		return head, err
+	}
+	treeSize, frontier, err := loadAccountFrontier(tx, userID)
+	if err != nil {
+		return head, err
+	}
+	if err := storeAccountFrontier(tx, userID, treeSize, frontier); err != nil {
+		return head, err
+	}
+	if err := tx.Commit(); err != nil {
+		return head, err
+	}
+
+	head.TreeSize = treeSize
+	head.RootHash = base64.StdEncoding.EncodeToString(crypto.MerkleFrontierRoot(frontier))
+	return head, nil
+}
+
+// loadAccountFrontier reads the size and the frontier of the account log of a user inside a transaction.
+// A log written before the tree heads were stored has none, its frontier is rebuilt from its leaves.
+// Parameters:
+// - tx: the transaction, the user row must already be locked
+// - userID: the ID of the user
+// Returns: the number of leaves, the frontier, or an error if a query error occurs or a hash is malformed
+func loadAccountFrontier(tx *sql.Tx, userID uint32) (int, [][]byte, error) {
+	const headQuery = `SELECT tree_size, frontier FROM account_log_heads WHERE user_id = ?`
+	var treeSize int
+	var encoded string
+	err := tx.QueryRow(headQuery, userID).Scan(&treeSize, &encoded)
+	if err == nil {
+		var frontier [][]byte
+		if encoded != "" {
+			for _, hash := range strings.Split(encoded, ",") {
+				decoded, err := base64.StdEncoding.DecodeString(hash)
+				if err != nil {
+					return 0, nil, fmt.Errorf("invalid account log frontier of user %d: %v", userID, err)
+				}
+				frontier = append(frontier, decoded)
+			}
+		}
+		return treeSize, frontier, nil
+	}
+	if !errors.Is(err, sql.ErrNoRows) {
+		return 0, nil, err
+	}
+
+	rows, err := tx.Query(`SELECT leaf_hash FROM account_log WHERE user_id = ? ORDER BY leaf_index`, userID)
+	if err != nil {
+		return 0, nil, err
+	}
+	defer rows.Close()
+
+	var frontier [][]byte
+	treeSize = 0
+	for rows.Next() {
+		var leafHash string
+		if err := rows.Scan(&leafHash); err != nil {
+			return 0, nil, err
+		}
+		leaf, err := base64.StdEncoding.DecodeString(leafHash)
+		if err != nil {
+			return 0, nil, fmt.Errorf("invalid leaf hash at index %d: %v", treeSize, err)
+		}
+		frontier = crypto.MerkleFrontierAppend(frontier, treeSize, leaf)
+		treeSize++
+	}
+	return treeSize, frontier, rows.Err()
+}
+
+// storeAccountFrontier stores the tree head and the frontier of the account log of a user inside a transaction.
+// Parameters:
+// - tx: the transaction appending to the log
+// - userID: the ID of the user
+// - treeSize: the number of leaves of the log
+// - frontier: the frontier of the log
+// Returns: an error if the tree head cannot be stored
+func storeAccountFrontier(tx *sql.Tx, userID uint32, treeSize int, frontier [][]byte) error {
+	encoded := make([]string, len(frontier))
+	for i, hash := range frontier {
+		encoded[i] = base64.StdEncoding.EncodeToString(hash)
+	}
+	const query = `
+		INSERT INTO account_log_heads (user_id, tree_size, root_hash, frontier)
+		VALUES (?, ?, ?, ?)
+		ON DUPLICATE KEY UPDATE tree_size = VALUES(tree_size), root_hash = VALUES(root_hash), frontier = VALUES(frontier)
+	`
+	root := base64.StdEncoding.EncodeToString(crypto.MerkleFrontierRoot(frontier))
+	if _, err := tx.Exec(query, userID, treeSize, root, strings.Join(encoded, ",")); err != nil {
+		return fmt.Errorf("error storing the account log tree head: %v", err)
+	}
+	return nil
+}
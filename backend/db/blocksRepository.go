@@ -1,12 +1,22 @@
 package db
 
 import (
+	"backend/crypto"
 	"backend/models"
 	"database/sql"
+	"errors"
 	"fmt"
+	"strings"
 )
 
-// BlockRepository provides methods to interact with the blocks database table.
+var (
+	// ErrNoteNotFound is returned when a note does not exist or does not belong to the user
+	ErrNoteNotFound = errors.New("note not found")
+	// ErrHeadMismatch is returned when a new block does not extend the current head of the note
+	ErrHeadMismatch = errors.New("block does not extend the current head of the note")
+)
+
+// BlockRepository provides methods to interact with the blocks and notes database tables.
 // Fields:
 // - DB: a pointer to the SQL database connection
 type BlockRepository struct {
@@ -23,41 +33,106 @@ func NewBlockRepository(db *sql.DB) *BlockRepository {
 	}
 }
 
-// GetNoteBlock retrieves the latest block for a specific note and user.
+// GetNote retrieves the metadata of a note (owner, timestamps, head hash, block count).
 // Parameters:
 // - userID: the ID of the user
 // - noteID: the ID of the note
-// Returns: a pointer to the retrieved block, or an error if no block is found or a query error occurs
-func (r *BlockRepository) GetNoteBlock(userID uint32, noteID uint) (*models.Block, error) {
+// Returns: a pointer to the note information, or ErrNoteNotFound if the note does not exist or is deleted
+func (r *BlockRepository) GetNote(userID uint32, noteID string) (*models.NoteInfo, error) {
 	const query = `
-        SELECT note_id, user_id, prev_hash, timestamp, iv, iv_title, cipher_title, ciphertext, mac, signature
-        FROM blocks
-        WHERE note_id = ? AND user_id = ?
-        ORDER BY timestamp DESC
-        LIMIT 1
+		SELECT id, created_at, updated_at, head_hash, block_count
+		FROM notes
+		WHERE id = ? AND user_id = ? AND deleted = FALSE
+	`
+
+	note := &models.NoteInfo{}
+	err := r.DB.QueryRow(query, noteID, userID).Scan(
+		&note.NoteID,
+		&note.CreatedAt,
+		&note.UpdatedAt,
+		&note.HeadHash,
+		&note.BlockCount,
+	)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, fmt.Errorf("%w: noteID %s and userID %d", ErrNoteNotFound, noteID, userID)
+		}
+		return nil, fmt.Errorf("error scanning note: %v", err)
+	}
+
+	return note, nil
+}
+
+// GetNoteHead retrieves the latest block of content of a note and the lifecycle blocks appended after it,
+// with the keys that signed them.
+// Parameters:
+// - userID: the ID of the user
+// - noteID: the ID of the note
+// Returns: a pointer to the head of the note, the signers of its block of content and of its lifecycle blocks in
+// chain order, or ErrNoteNotFound if the note does not exist or is deleted
+func (r *BlockRepository) GetNoteHead(userID uint32, noteID string) (*models.NoteHead, []models.BlockSigner, error) {
+	const query = `
+        SELECT b.prev_hash, b.timestamp, b.iv, b.iv_title, b.cipher_title, b.ciphertext, b.mac, b.signature,
+               b.nonce, b.nonce_title, b.tag, b.tag_title, b.suite_id, b.pq_signature, b.kind,
+               b.signer_key, b.pq_signer_key, b.device_id
+        FROM notes n
+        INNER JOIN blocks b ON b.note_id = n.id AND b.seq >= n.content_seq
+        WHERE n.id = ? AND n.user_id = ? AND n.deleted = FALSE
+        ORDER BY b.seq ASC
     `
-	row := r.DB.QueryRow(query, noteID, userID)
-
-	block := &models.Block{}
-	if err := row.Scan(
-		&noteID,
-		&userID,
-		&block.PrevHash,
-		&block.Timestamp,
-		&block.IV,
-		&block.IVTitle,
-		&block.CipherTitle,
-		&block.Ciphertext,
-		&block.MAC,
-		&block.Signature,
-	); err != nil {
-		if err == sql.ErrNoRows {
-			return nil, fmt.Errorf("no block found for noteID %d and userID %d", noteID, userID)
+	rows, err := r.DB.Query(query, noteID, userID)
+	if err != nil {
+		return nil, nil, fmt.Errorf("error querying blocks: %v", err)
+	}
+	defer rows.Close()
+
+	var blocks []models.Block
+	var signers []models.BlockSigner
+	for rows.Next() {
+		var block models.Block
+		var signer models.BlockSigner
+		var deviceID sql.NullInt32
+		if err := rows.Scan(
+			&block.PrevHash,
+			&block.Timestamp,
+			&block.IV,
+			&block.IVTitle,
+			&block.CipherTitle,
+			&block.Ciphertext,
+			&block.MAC,
+			&block.Signature,
+			&block.Nonce,
+			&block.NonceTitle,
+			&block.Tag,
+			&block.TagTitle,
+			&block.SuiteID,
+			&block.PQSignature,
+			&block.Kind,
+			&signer.PubKey,
+			&signer.PQPubKey,
+			&deviceID,
+		); err != nil {
+			return nil, nil, fmt.Errorf("error scanning block: %v", err)
+		}
+		if block.Kind != "" {
+			block.NoteID = noteID
+		}
+		if deviceID.Valid {
+			id := uint32(deviceID.Int32)
+			signer.DeviceID = &id
 		}
-		return nil, fmt.Errorf("error scanning block: %v", err)
+		blocks = append(blocks, block)
+		signers = append(signers, signer)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, nil, fmt.Errorf("error iterating over rows: %v", err)
 	}
 
-	return block, nil
+	if len(blocks) == 0 {
+		return nil, nil, fmt.Errorf("%w: noteID %s and userID %d", ErrNoteNotFound, noteID, userID)
+	}
+
+	return &models.NoteHead{Block: blocks[0], Lifecycle: blocks[1:]}, signers, nil
 }
 
 // GetNoteBlockChain retrieves the entire blockchain for a specific note and user.
@@ -65,12 +140,13 @@ func (r *BlockRepository) GetNoteBlock(userID uint32, noteID uint) (*models.Bloc
 // - userID: the ID of the user
 // - noteID: the ID of the note
 // Returns: a pointer to the NoteBlockChain containing all blocks, or an error if a query error occurs
-func (r *BlockRepository) GetNoteBlockChain(userID uint32, noteID uint) (*models.NoteBlockChain, error) {
+func (r *BlockRepository) GetNoteBlockChain(userID uint32, noteID string) (*models.NoteBlockChain, error) {
 	const query = `
-        SELECT prev_hash, timestamp, iv, iv_title, cipher_title, ciphertext, mac, signature
+        SELECT prev_hash, timestamp, iv, iv_title, cipher_title, ciphertext, mac, signature, nonce, nonce_title, tag, tag_title, suite_id,
+               pq_signature, kind
         FROM blocks
         WHERE note_id = ? AND user_id = ?
-        ORDER BY timestamp ASC
+        ORDER BY seq ASC
     `
 
 	rows, err := r.DB.Query(query, noteID, userID)
@@ -91,9 +167,20 @@ func (r *BlockRepository) GetNoteBlockChain(userID uint32, noteID uint) (*models
 			&block.Ciphertext,
 			&block.MAC,
 			&block.Signature,
+			&block.Nonce,
+			&block.NonceTitle,
+			&block.Tag,
+			&block.TagTitle,
+			&block.SuiteID,
+			&block.PQSignature,
+			&block.Kind,
 		); err != nil {
 			return nil, fmt.Errorf("error scanning block: %v", err)
 		}
+		// The lifecycle blocks sign the ID of their note, it is kept in the note_id column
+		if block.Kind != "" {
+			block.NoteID = noteID
+		}
 		blocks = append(blocks, block)
 	}
 
@@ -107,66 +194,220 @@ func (r *BlockRepository) GetNoteBlockChain(userID uint32, noteID uint) (*models
 	}, nil
 }
 
-// CreateBlock inserts a new block into the database for a specific note and user.
+// GetNoteSigners retrieves the keys that signed each block of a note, and the device they belong to.
 // Parameters:
 // - userID: the ID of the user
 // - noteID: the ID of the note
-// - block: a pointer to the block to be inserted
-// Returns: the timestamp of the inserted block, or an error if the insertion fails
-func (r *BlockRepository) CreateBlock(userID uint32, noteID uint, block *models.Block) error {
+// Returns: the signers in chain order, the same order as GetNoteBlockChain, or an error if a query error occurs
+func (r *BlockRepository) GetNoteSigners(userID uint32, noteID string) ([]models.BlockSigner, error) {
+	const query = `SELECT signer_key, pq_signer_key, device_id FROM blocks WHERE note_id = ? AND user_id = ? ORDER BY seq ASC`
+
+	rows, err := r.DB.Query(query, noteID, userID)
+	if err != nil {
+		return nil, fmt.Errorf("error querying signer keys: %v", err)
+	}
+	defer rows.Close()
+
+	var signers []models.BlockSigner
+	for rows.Next() {
+		var signer models.BlockSigner
+		var deviceID sql.NullInt32
+		if err := rows.Scan(&signer.PubKey, &signer.PQPubKey, &deviceID); err != nil {
+			return nil, fmt.Errorf("error scanning signer key: %v", err)
+		}
+		if deviceID.Valid {
+			id := uint32(deviceID.Int32)
+			signer.DeviceID = &id
+		}
+		signers = append(signers, signer)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating over rows: %v", err)
+	}
+
+	return signers, nil
+}
+
+// GetUserSignerKeys retrieves every public key that signed a block of a user, the historic keys of the user.
+// Parameters:
+// - userID: the ID of the user
+// Returns: the distinct Ed25519 and ML-DSA keys, or an error if a query error occurs
+func (r *BlockRepository) GetUserSignerKeys(userID uint32) ([]string, error) {
 	const query = `
-		INSERT INTO blocks (note_id, user_id, prev_hash, timestamp, iv, iv_title, cipher_title, ciphertext, mac, signature)
-		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+		SELECT signer_key FROM blocks WHERE user_id = ?
+		UNION
+		SELECT pq_signer_key FROM blocks WHERE user_id = ? AND pq_signer_key <> ''
 	`
 
-	// Execute the insert query
-	_, err := r.DB.Exec(query,
-		noteID,
-		userID,
-		block.PrevHash,
-		block.Timestamp,
-		block.IV,
-		block.IVTitle,
-		block.CipherTitle,
-		block.Ciphertext,
-		block.MAC,
-		block.Signature,
-	)
+	rows, err := r.DB.Query(query, userID, userID)
 	if err != nil {
-		return err
+		return nil, fmt.Errorf("error querying signer keys: %v", err)
 	}
+	defer rows.Close()
 
-	return nil
+	var keys []string
+	for rows.Next() {
+		var key string
+		if err := rows.Scan(&key); err != nil {
+			return nil, fmt.Errorf("error scanning signer key: %v", err)
+		}
+		keys = append(keys, key)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating over rows: %v", err)
+	}
+
+	return keys, nil
 }
 
-// CreateNewNote creates a new note by incrementing the note ID for the user and inserting the first block.
+// CreateBlock appends a new block to a note and makes it the head of the note.
+// The note row is locked while the block is appended, so two concurrent edits can never both extend the same head.
 // Parameters:
 // - userID: the ID of the user
+// - noteID: the ID of the note
 // - block: a pointer to the block to be inserted
-// Returns: the new note ID, the timestamp of the inserted block, or an error if the operation fails
-func (r *BlockRepository) CreateNewNote(userID uint32, block *models.Block) (uint, error) {
+// - signer: the keys the signatures of the block were verified with
+// - searchTokens: the keyword tokens of the new version, they replace the tokens of the previous one
+// Returns: the seq of the new block, ErrNoteNotFound if the note does not exist, ErrHeadMismatch if the block
+// does not extend the current head, or an error if the insertion fails
+func (r *BlockRepository) CreateBlock(userID uint32, noteID string, block *models.Block, signer models.BlockSigner, searchTokens []string) (uint, error) {
+	blockHash, err := crypto.BlockHash(*block)
+	if err != nil {
+		return 0, err
+	}
+
 	tx, err := r.DB.Begin()
 	if err != nil {
 		return 0, err
 	}
 	defer tx.Rollback()
 
-	// First get the new note_id
-	const getMaxNoteID = `SELECT COALESCE(MAX(note_id), 0) + 1 FROM blocks WHERE user_id = ?`
-	var noteID uint
-	err = tx.QueryRow(getMaxNoteID, userID).Scan(&noteID)
-	if err != nil {
+	// Lock the note while the new head is appended
+	const lockQuery = `SELECT head_hash, block_count FROM notes WHERE id = ? AND user_id = ? AND deleted = FALSE FOR UPDATE`
+	var headHash string
+	var blockCount uint
+	if err = tx.QueryRow(lockQuery, noteID, userID).Scan(&headHash, &blockCount); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return 0, fmt.Errorf("%w: noteID %s and userID %d", ErrNoteNotFound, noteID, userID)
+		}
+		return 0, err
+	}
+
+	if block.PrevHash != headHash {
+		return 0, ErrHeadMismatch
+	}
+
+	if err = insertBlock(tx, userID, noteID, blockCount+1, block, signer); err != nil {
+		return 0, err
+	}
+
+	// Move the head of the note to the new block, it is also its latest version
+	const updateHeadQuery = `
+		UPDATE notes SET head_hash = ?, block_count = ?, content_seq = ?, updated_at = ?
+		WHERE id = ?
+	`
+	if _, err = tx.Exec(updateHeadQuery, blockHash, blockCount+1, blockCount+1, block.Timestamp, noteID); err != nil {
+		return 0, err
+	}
+
+	if err = replaceNoteTokens(tx, userID, noteID, blockHash, searchTokens); err != nil {
 		return 0, err
 	}
 
+	if err = appendAccountLeaf(tx, userID, noteID, models.LogEventHead, blockHash); err != nil {
+		return 0, err
+	}
+
+	if err = appendTransparencyLeaf(tx, noteID, blockCount+1, blockHash); err != nil {
+		return 0, err
+	}
+
+	if err = tx.Commit(); err != nil {
+		return 0, err
+	}
+
+	return blockCount + 1, nil
+}
+
+// CreateNewNote creates a new note with a random opaque ID and inserts its first block.
+// Parameters:
+// - userID: the ID of the user
+// - block: a pointer to the block to be inserted
+// - signer: the keys the signatures of the block were verified with
+// - searchTokens: the keyword tokens of the first version
+// Returns: the new note ID, or an error if the operation fails
+func (r *BlockRepository) CreateNewNote(userID uint32, block *models.Block, signer models.BlockSigner, searchTokens []string) (string, error) {
+	blockHash, err := crypto.BlockHash(*block)
+	if err != nil {
+		return "", err
+	}
+
+	// Random 128 bit IDs do not race and do not reveal how many notes a user has created
+	noteID, err := crypto.GenerateIDHex(16)
+	if err != nil {
+		return "", err
+	}
+
+	tx, err := r.DB.Begin()
+	if err != nil {
+		return "", err
+	}
+	defer tx.Rollback()
+
+	// The first block is the head of the new note
+	const insertNoteQuery = `
+		INSERT INTO notes (id, user_id, created_at, updated_at, head_hash, block_count, content_seq)
+		VALUES (?, ?, ?, ?, ?, 1, 1)
+	`
+	_, err = tx.Exec(insertNoteQuery, noteID, userID, block.Timestamp, block.Timestamp, blockHash)
+	if err != nil {
+		return "", err
+	}
+
 	// Then insert the new block
-	const insertQuery = `
-		INSERT INTO blocks (note_id, user_id, prev_hash, timestamp, iv, iv_title, cipher_title, ciphertext, mac, signature)
-		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+	if err = insertBlock(tx, userID, noteID, 1, block, signer); err != nil {
+		return "", err
+	}
+
+	if err = replaceNoteTokens(tx, userID, noteID, blockHash, searchTokens); err != nil {
+		return "", err
+	}
+
+	if err = appendAccountLeaf(tx, userID, noteID, models.LogEventHead, blockHash); err != nil {
+		return "", err
+	}
+
+	if err = appendTransparencyLeaf(tx, noteID, 1, blockHash); err != nil {
+		return "", err
+	}
+
+	if err = tx.Commit(); err != nil {
+		return "", err
+	}
+
+	return noteID, nil
+}
+
+// insertBlock inserts a block row inside a transaction.
+// Parameters:
+// - tx: the transaction in which the block is inserted
+// - userID: the ID of the user
+// - noteID: the ID of the note
+// - seq: the position of the block in the note chain, starting at 1
+// - block: a pointer to the block to be inserted
+// - signer: the keys the signatures of the block were verified with, and the device they belong to
+// Returns: an error if the insertion fails
+func insertBlock(tx *sql.Tx, userID uint32, noteID string, seq uint, block *models.Block, signer models.BlockSigner) error {
+	const query = `
+		INSERT INTO blocks (note_id, user_id, seq, prev_hash, timestamp, iv, iv_title, cipher_title, ciphertext, mac, signature,
+			nonce, nonce_title, tag, tag_title, signer_key, suite_id, pq_signature, pq_signer_key, device_id, kind)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
 	`
-	_, err = tx.Exec(insertQuery,
+
+	_, err := tx.Exec(query,
 		noteID,
 		userID,
+		seq,
 		block.PrevHash,
 		block.Timestamp,
 		block.IV,
@@ -175,39 +416,68 @@ func (r *BlockRepository) CreateNewNote(userID uint32, block *models.Block) (uin
 		block.Ciphertext,
 		block.MAC,
 		block.Signature,
+		block.Nonce,
+		block.NonceTitle,
+		block.Tag,
+		block.TagTitle,
+		signer.PubKey,
+		block.SuiteID,
+		block.PQSignature,
+		signer.PQPubKey,
+		signer.DeviceID,
+		block.Kind,
 	)
-	if err != nil {
-		return 0, err
+	return err
+}
+
+// GetTitles retrieves one page of the latest cipher title and timestamp of the notes of a user.
+// The notes table keeps the seq of the latest block of content of every note, the block holding its title, so the
+// page is read with an index range scan instead of computing the latest block of every note.
+// Parameters:
+// - userID: the ID of the user
+// - filter: the folder, tag and keyword tokens the notes must match, an empty filter returns every note
+// - page: the sort order, page size and cursor of the page to return
+// Returns: a slice of Title objects containing the note ID, cipher title, IV, head hash, timestamps and encrypted metadata,
+// whether there are more titles after this page, or an error if a query error occurs
+func (r *BlockRepository) GetTitles(userID uint32, filter models.TitleFilter, page models.TitlePagination) ([]*models.Title, bool, error) {
+	sortColumn := "n.updated_at"
+	if page.Sort == models.SortByCreated {
+		sortColumn = "n.created_at"
+	}
+	direction, comparison := "DESC", "<"
+	if page.Ascending {
+		direction, comparison = "ASC", ">"
 	}
 
-	if err = tx.Commit(); err != nil {
-		return 0, err
+	var query strings.Builder
+	query.WriteString(`
+		SELECT n.id, b.cipher_title, b.iv_title, b.nonce_title, b.tag_title, n.head_hash, n.updated_at, n.created_at, COALESCE(m.cipher_meta, ''), COALESCE(m.iv_meta, '')
+		FROM notes n
+		INNER JOIN blocks b
+		ON b.note_id = n.id AND b.seq = n.content_seq
+		LEFT JOIN note_metadata m
+		ON m.note_id = n.id
+		WHERE n.user_id = ? AND n.deleted = FALSE
+	`)
+	args := []any{userID}
+
+	filterClause, filterArgs := titleFilterClause(filter)
+	query.WriteString(filterClause)
+	args = append(args, filterArgs...)
+
+	// Keyset pagination: continue right after the last title of the previous page
+	if page.After != nil {
+		query.WriteString(" AND (" + sortColumn + " " + comparison + " ? OR (" + sortColumn + " = ? AND n.id " + comparison + " ?))")
+		args = append(args, page.After.SortValue, page.After.SortValue, page.After.NoteID)
 	}
 
-	return noteID, nil
-}
+	// Fetch one extra title to know if there is a next page
+	query.WriteString(" ORDER BY " + sortColumn + " " + direction + ", n.id " + direction + " LIMIT ?")
+	args = append(args, page.Limit+1)
 
-// GetTitles retrieves the latest cipher title and timestamp for each note of a user.
-// Parameters:
-// - userID: the ID of the user
-// Returns: a slice of Title objects containing the note ID, cipher title, IV, and timestamp, or an error if a query error occurs
-func (r *BlockRepository) GetTitles(userID uint32) ([]*models.Title, error) {
-	// Updated query to return the latest block for each note_id
-	const query = `
-		SELECT b.note_id, b.cipher_title, b.iv_title, b.timestamp
-		FROM blocks b
-		INNER JOIN (
-			SELECT note_id, MAX(timestamp) AS max_timestamp
-			FROM blocks
-			WHERE user_id = ?
-			GROUP BY note_id
-		) latest_blocks
-		ON b.note_id = latest_blocks.note_id AND b.timestamp = latest_blocks.max_timestamp
-		ORDER BY b.timestamp DESC
-	`
-	rows, err := r.DB.Query(query, userID)
+	rows, err := r.DB.Query(query.String(), args...)
 	if err != nil {
-		return nil, err
+		return nil, false, err
 	}
 	defer rows.Close()
 
@@ -218,43 +488,135 @@ func (r *BlockRepository) GetTitles(userID uint32) ([]*models.Title, error) {
 			&title.NoteID,
 			&title.CipherTitle,
 			&title.IV,
+			&title.NonceTitle,
+			&title.TagTitle,
+			&title.HeadHash,
 			&title.Timestamp,
+			&title.CreatedAt,
+			&title.CipherMeta,
+			&title.IVMeta,
 		); err != nil {
-			return nil, err
+			return nil, false, err
 		}
 		titles = append(titles, title)
 	}
 
 	if err := rows.Err(); err != nil {
-		return nil, err
+		return nil, false, err
+	}
+
+	hasMore := len(titles) > page.Limit
+	if hasMore {
+		titles = titles[:page.Limit]
 	}
-	return titles, nil
+	return titles, hasMore, nil
 }
 
-// DeleteNoteBlocks deletes all blocks associated with a specific note ID and user ID.
+// CountTitles counts the notes of a user that match a filter.
 // Parameters:
 // - userID: the ID of the user
-// - noteID: the ID of the note
-// Returns: an error if the deletion fails or no blocks are found
-func (r *BlockRepository) DeleteNoteBlocks(userID uint32, noteID uint) error {
+// - filter: the folder, tag and keyword tokens the notes must match
+// Returns: the number of matching notes, or an error if a query error occurs
+func (r *BlockRepository) CountTitles(userID uint32, filter models.TitleFilter) (int, error) {
+	filterClause, filterArgs := titleFilterClause(filter)
+	query := `
+		SELECT COUNT(*)
+		FROM notes n
+		LEFT JOIN note_metadata m
+		ON m.note_id = n.id
+		WHERE n.user_id = ? AND n.deleted = FALSE
+	` + filterClause
+
+	var total int
+	if err := r.DB.QueryRow(query, append([]any{userID}, filterArgs...)...).Scan(&total); err != nil {
+		return 0, err
+	}
+	return total, nil
+}
+
+// titleFilterClause builds the WHERE conditions of a title filter.
+// The filters only ever compare client computed tokens, the server never learns the folder, tag or keyword names.
+// Parameters:
+// - filter: the filter to translate, the notes table must be aliased as n and the metadata table as m
+// Returns: the SQL conditions, each starting with AND, and their arguments
+func titleFilterClause(filter models.TitleFilter) (string, []any) {
+	var clause strings.Builder
+	var args []any
+
+	if filter.FolderToken != "" {
+		clause.WriteString(" AND m.folder_token = ?")
+		args = append(args, filter.FolderToken)
+	}
+	for _, token := range filter.TagTokens {
+		clause.WriteString(" AND EXISTS (SELECT 1 FROM note_tags t WHERE t.note_id = n.id AND t.tag_token = ?)")
+		args = append(args, token)
+	}
+	if len(filter.SearchTokens) > 0 && filter.MatchAnyKeyword {
+		placeholders := strings.TrimSuffix(strings.Repeat("?, ", len(filter.SearchTokens)), ", ")
+		clause.WriteString(" AND EXISTS (SELECT 1 FROM search_index s WHERE s.note_id = n.id AND s.token IN (" + placeholders + "))")
+		for _, token := range filter.SearchTokens {
+			args = append(args, token)
+		}
+	} else {
+		for _, token := range filter.SearchTokens {
+			clause.WriteString(" AND EXISTS (SELECT 1 FROM search_index s WHERE s.note_id = n.id AND s.token = ?)")
+			args = append(args, token)
+		}
+	}
+
+	return clause.String(), args
+}
+
+// FillHeadHashes computes the head hash of the notes migration 002 left without one, with crypto.BlockHash so the
+// hash is exactly the one the server computes for the new blocks.
+// The blocks of these notes predate the AEAD and hybrid blocks, only the columns of the original blocks are hashed.
+// Parameters:
+// - dryRun: only count the notes without a head hash, without writing anything
+// Returns: the number of notes filled, or an error if a head block cannot be read, hashed or updated
+func (r *BlockRepository) FillHeadHashes(dryRun bool) (int, error) {
 	const query = `
-		DELETE FROM blocks
-		WHERE note_id = ? AND user_id = ?
+		SELECT n.id, b.prev_hash, b.iv, b.iv_title, b.cipher_title, b.ciphertext, b.mac, b.signature, b.timestamp
+		FROM notes n
+		INNER JOIN blocks b ON b.note_id = n.id AND b.seq = n.block_count
+		WHERE n.head_hash = ''
+		ORDER BY n.id
 	`
-
-	result, err := r.DB.Exec(query, noteID, userID)
+	rows, err := r.DB.Query(query)
 	if err != nil {
-		return fmt.Errorf("error deleting blocks: %v", err)
+		return 0, fmt.Errorf("error listing the notes without a head hash: %v", err)
 	}
 
-	rowsAffected, err := result.RowsAffected()
-	if err != nil {
-		return fmt.Errorf("error checking rows affected: %v", err)
+	hashes := map[string]string{}
+	for rows.Next() {
+		var noteID string
+		var head models.Block
+		if err := rows.Scan(&noteID, &head.PrevHash, &head.IV, &head.IVTitle, &head.CipherTitle, &head.Ciphertext,
+			&head.MAC, &head.Signature, &head.Timestamp); err != nil {
+			rows.Close()
+			return 0, err
+		}
+		hash, err := crypto.BlockHash(head)
+		if err != nil {
+			rows.Close()
+			return 0, fmt.Errorf("error hashing the head of note %s: %v", noteID, err)
+		}
+		hashes[noteID] = hash
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return 0, err
 	}
 
-	if rowsAffected == 0 {
-		return fmt.Errorf("no blocks found for noteID %d and userID %d", noteID, userID)
+	if dryRun {
+		return len(hashes), nil
 	}
 
-	return nil
+	filled := 0
+	for noteID, hash := range hashes {
+		if _, err := r.DB.Exec(`UPDATE notes SET head_hash = ? WHERE id = ? AND head_hash = ''`, hash, noteID); err != nil {
+			return filled, fmt.Errorf("error updating note %s: %v", noteID, err)
+		}
+		filled++
+	}
+	return filled, nil
 }
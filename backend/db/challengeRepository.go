@@ -4,8 +4,12 @@ import (
 	"backend/models"
 	"database/sql"
 	"errors"
+	"time"
 )
 
+// ErrRegistrationChallengeInvalid is returned when a registration challenge does not exist, was used or expired
+var ErrRegistrationChallengeInvalid = errors.New("invalid or expired registration challenge")
+
 // ChallengeRepository handles all database operations related to login challenges.
 // Fields:
 // - DB: a pointer to the SQL database connection
@@ -99,5 +103,45 @@ func (r *ChallengeRepository) ClearExpiredChallenges() error {
 	query := `DELETE FROM challenges WHERE expires_at < NOW()`
 
 	_, err := r.DB.Exec(query)
+	if err != nil {
+		return err
+	}
+
+	_, err = r.DB.Exec(`DELETE FROM registration_challenges WHERE expires_at < NOW()`)
+	return err
+}
+
+// CreateRegistrationChallenge adds a new registration challenge to the database.
+// Parameters:
+// - challengeValue: the Base64 value of the challenge
+// - expiresAt: when the challenge expires
+// Returns: an error if the insertion fails
+func (r *ChallengeRepository) CreateRegistrationChallenge(challengeValue string, expiresAt time.Time) error {
+	query := `INSERT INTO registration_challenges (challenge_value, expires_at) VALUES (?, ?)`
+
+	_, err := r.DB.Exec(query, challengeValue, expiresAt)
 	return err
 }
+
+// ConsumeRegistrationChallenge deletes a registration challenge that has not expired, so it can only be used once.
+// Parameters:
+// - challengeValue: the Base64 value of the challenge
+// Returns: ErrRegistrationChallengeInvalid if there is no such challenge or it expired, or an error if the
+// deletion fails
+func (r *ChallengeRepository) ConsumeRegistrationChallenge(challengeValue string) error {
+	query := `DELETE FROM registration_challenges WHERE challenge_value = ? AND expires_at > ?`
+
+	result, err := r.DB.Exec(query, challengeValue, time.Now().UTC())
+	if err != nil {
+		return err
+	}
+
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if affected == 0 {
+		return ErrRegistrationChallengeInvalid
+	}
+	return nil
+}
@@ -0,0 +1,124 @@
+package db
+
+import (
+	"backend/models"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+var (
+	// ErrDeviceNotFound is returned when a device does not exist, belongs to another user or is already revoked
+	ErrDeviceNotFound = errors.New("device not found")
+	// ErrDeviceExists is returned when a key is enrolled twice, a revoked key can never be enrolled again
+	ErrDeviceExists = errors.New("this key is already enrolled")
+)
+
+// DeviceRepository handles all database operations related to the device keys.
+// Fields:
+// - DB: a pointer to the SQL database connection
+type DeviceRepository struct {
+	DB *sql.DB
+}
+
+// NewDeviceRepository creates a new instance of DeviceRepository.
+// Parameters:
+// - db: a pointer to the SQL database connection
+// Returns: a pointer to the newly created DeviceRepository
+func NewDeviceRepository(db *sql.DB) *DeviceRepository {
+	return &DeviceRepository{
+		DB: db,
+	}
+}
+
+// CreateDevice stores the key of a newly enrolled device.
+// Parameters:
+// - userID: the ID of the user
+// - device: a pointer to the device, its enrollment signature already verified
+// Returns: the ID of the device, ErrDeviceExists if the key was already enrolled, or an error if the insertion fails
+func (r *DeviceRepository) CreateDevice(userID uint32, device *models.DeviceKey) (uint32, error) {
+	const query = `
+		INSERT INTO device_keys (user_id, name, pub_key, pq_pub_key, enrolled_by, signature, created_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?)
+	`
+	result, err := r.DB.Exec(query, userID, device.Name, device.PubKey, device.PQPubKey, device.EnrolledBy,
+		device.Signature, device.CreatedAt)
+	if err != nil {
+		if strings.Contains(err.Error(), "Duplicate entry") {
+			return 0, ErrDeviceExists
+		}
+		return 0, err
+	}
+
+	id, err := result.LastInsertId()
+	if err != nil {
+		return 0, err
+	}
+	return uint32(id), nil
+}
+
+// GetDevices retrieves every device of a user, the revoked ones included.
+// Parameters:
+// - userID: the ID of the user
+// - activeOnly: only return the devices that are not revoked
+// Returns: the devices in enrollment order, or an error if a query error occurs
+func (r *DeviceRepository) GetDevices(userID uint32, activeOnly bool) ([]*models.DeviceKey, error) {
+	query := `
+		SELECT id, name, pub_key, pq_pub_key, enrolled_by, signature, created_at, revoked_at
+		FROM device_keys
+		WHERE user_id = ?
+	`
+	if activeOnly {
+		query += ` AND revoked_at IS NULL`
+	}
+	query += ` ORDER BY id ASC`
+
+	rows, err := r.DB.Query(query, userID)
+	if err != nil {
+		return nil, fmt.Errorf("error querying devices: %v", err)
+	}
+	defer rows.Close()
+
+	devices := []*models.DeviceKey{}
+	for rows.Next() {
+		device := &models.DeviceKey{}
+		var revokedAt sql.NullTime
+		if err := rows.Scan(&device.ID, &device.Name, &device.PubKey, &device.PQPubKey, &device.EnrolledBy,
+			&device.Signature, &device.CreatedAt, &revokedAt); err != nil {
+			return nil, fmt.Errorf("error scanning device: %v", err)
+		}
+		if revokedAt.Valid {
+			device.RevokedAt = &revokedAt.Time
+		}
+		devices = append(devices, device)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating over rows: %v", err)
+	}
+
+	return devices, nil
+}
+
+// RevokeDevice revokes an active device, its key can no longer sign blocks or enroll other devices.
+// Parameters:
+// - userID: the ID of the user
+// - deviceID: the ID of the device
+// Returns: ErrDeviceNotFound if the user has no such active device, or an error if the update fails
+func (r *DeviceRepository) RevokeDevice(userID, deviceID uint32) error {
+	const query = `UPDATE device_keys SET revoked_at = ? WHERE id = ? AND user_id = ? AND revoked_at IS NULL`
+	result, err := r.DB.Exec(query, time.Now().UTC(), deviceID, userID)
+	if err != nil {
+		return err
+	}
+
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if affected == 0 {
+		return fmt.Errorf("%w: deviceID %d and userID %d", ErrDeviceNotFound, deviceID, userID)
+	}
+	return nil
+}
@@ -0,0 +1,193 @@
+package db
+
+import (
+	"backend/crypto"
+	"backend/models"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+var (
+	// ErrNoteExists is returned when an imported note has the ID of a note that already exists
+	ErrNoteExists = errors.New("a note with this ID already exists")
+	// ErrNoteNotRenamable is returned when an imported note holds lifecycle blocks and must be stored under another ID
+	ErrNoteNotRenamable = errors.New("the note holds lifecycle blocks signed for its own ID")
+)
+
+// ImportRepository stores the notes of an imported vault archive.
+// Fields:
+// - DB: a pointer to the SQL database connection
+type ImportRepository struct {
+	DB *sql.DB
+}
+
+// NewImportRepository creates a new instance of ImportRepository.
+// Parameters:
+// - db: a pointer to the SQL database connection
+// Returns: a pointer to the newly created ImportRepository
+func NewImportRepository(db *sql.DB) *ImportRepository {
+	return &ImportRepository{
+		DB: db,
+	}
+}
+
+// FindNote looks up a note of a user by ID, including the notes inside the trash.
+// The notes of the other users are never looked at, the import must not tell which IDs they use.
+// Parameters:
+// - userID: the ID of the user
+// - noteID: the ID of the note
+// Returns: the head hash of the note, or ErrNoteNotFound if the user has no note with this ID
+func (r *ImportRepository) FindNote(userID uint32, noteID string) (string, error) {
+	const query = `SELECT head_hash FROM notes WHERE id = ? AND user_id = ?`
+
+	var headHash string
+	if err := r.DB.QueryRow(query, noteID, userID).Scan(&headHash); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return "", fmt.Errorf("%w: noteID %s and userID %d", ErrNoteNotFound, noteID, userID)
+		}
+		return "", fmt.Errorf("error scanning note: %v", err)
+	}
+	return headHash, nil
+}
+
+// ImportNote stores a verified note of an archive with its whole chain, in a single transaction.
+// The blocks are committed to the account log and the transparency log like the blocks of a new note.
+// A note whose head is a tombstone block stays inside the trash and its retention period starts again.
+// The lifecycle blocks sign the ID of their note, a note holding some cannot be stored under another ID.
+// Parameters:
+// - userID: the ID of the user importing the note
+// - noteID: the ID the note is stored under, it differs from note.NoteID when the note is renamed
+// - note: a pointer to the verified note
+// Returns: ErrNoteExists if a note with this ID already exists, ErrNoteNotRenamable if the note holds lifecycle
+// blocks and noteID is another ID, or an error if the insertion fails
+func (r *ImportRepository) ImportNote(userID uint32, noteID string, note *models.VaultNote) error {
+	contentSeq := 0
+	for i := range note.Blocks {
+		if note.Blocks[i].Kind == "" {
+			contentSeq = i + 1
+		} else if noteID != note.NoteID {
+			return fmt.Errorf("%w: noteID %s", ErrNoteNotRenamable, note.NoteID)
+		}
+	}
+	content := note.Blocks[contentSeq-1]
+	head := note.Blocks[len(note.Blocks)-1]
+
+	deleted := head.Kind == models.BlockKindTombstone
+	var deletedAt sql.NullTime
+	if deleted {
+		deletedAt = sql.NullTime{Time: time.Now().UTC(), Valid: true}
+	}
+
+	tx, err := r.DB.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	const insertNoteQuery = `
+		INSERT INTO notes (id, user_id, created_at, updated_at, head_hash, block_count, content_seq, deleted, deleted_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)
+	`
+	_, err = tx.Exec(insertNoteQuery, noteID, userID, note.Blocks[0].Timestamp, content.Timestamp,
+		note.HeadHash, len(note.Blocks), contentSeq, deleted, deletedAt)
+	if err != nil {
+		if strings.Contains(err.Error(), "Duplicate entry") {
+			return fmt.Errorf("%w: noteID %s", ErrNoteExists, noteID)
+		}
+		return fmt.Errorf("error inserting note %s: %v", noteID, err)
+	}
+
+	for i := range note.Blocks {
+		seq := uint(i + 1)
+		if err = insertBlock(tx, userID, noteID, seq, &note.Blocks[i], models.BlockSigner{
+			PubKey:   note.SignerKeys[i],
+			PQPubKey: note.PQSignerKey(i),
+		}); err != nil {
+			return fmt.Errorf("error inserting block %d of note %s: %v", seq, noteID, err)
+		}
+
+		blockHash, err := crypto.BlockHash(note.Blocks[i])
+		if err != nil {
+			return err
+		}
+		if err = appendTransparencyLeaf(tx, noteID, seq, blockHash); err != nil {
+			return err
+		}
+	}
+
+	contentHash, err := crypto.BlockHash(content)
+	if err != nil {
+		return err
+	}
+	if err = importNoteIndexes(tx, userID, noteID, contentHash, note); err != nil {
+		return err
+	}
+
+	for _, timestamp := range note.Timestamps {
+		if timestamp.Seq < 1 || timestamp.Seq > uint(len(note.Blocks)) {
+			continue
+		}
+		const insertTimestampQuery = `
+			INSERT INTO block_timestamps (note_id, seq, authority, token, gen_time)
+			VALUES (?, ?, ?, ?, ?)
+		`
+		_, err = tx.Exec(insertTimestampQuery, noteID, timestamp.Seq, timestamp.Authority, timestamp.Token, timestamp.GenTime)
+		if err != nil {
+			return fmt.Errorf("error inserting timestamp of block %d of note %s: %v", timestamp.Seq, noteID, err)
+		}
+	}
+
+	event := models.LogEventHead
+	if deleted {
+		event = models.LogEventTrash
+	}
+	if err = appendAccountLeaf(tx, userID, noteID, event, note.HeadHash); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// importNoteIndexes stores the encrypted metadata and the blind index tokens of an imported note.
+// Parameters:
+// - tx: the transaction importing the note
+// - userID: the ID of the user
+// - noteID: the ID the note is stored under
+// - contentHash: the hash of the latest block of content of the note, the version its keyword tokens were computed for
+// - note: a pointer to the imported note
+// Returns: an error if an insertion fails
+func importNoteIndexes(tx *sql.Tx, userID uint32, noteID, contentHash string, note *models.VaultNote) error {
+	if note.Metadata != nil {
+		var folderToken sql.NullString
+		if note.Metadata.FolderToken != "" {
+			folderToken = sql.NullString{String: note.Metadata.FolderToken, Valid: true}
+		}
+
+		const insertMetadataQuery = `
+			INSERT INTO note_metadata (note_id, user_id, cipher_meta, iv_meta, folder_token)
+			VALUES (?, ?, ?, ?, ?)
+		`
+		if _, err := tx.Exec(insertMetadataQuery, noteID, userID, note.Metadata.CipherMeta, note.Metadata.IVMeta, folderToken); err != nil {
+			return fmt.Errorf("error storing metadata: %v", err)
+		}
+
+		const insertTagQuery = `INSERT IGNORE INTO note_tags (note_id, user_id, tag_token) VALUES (?, ?, ?)`
+		for _, token := range note.Metadata.TagTokens {
+			if _, err := tx.Exec(insertTagQuery, noteID, userID, token); err != nil {
+				return fmt.Errorf("error storing tag: %v", err)
+			}
+		}
+	}
+
+	const insertTokenQuery = `INSERT IGNORE INTO search_index (note_id, user_id, token, block_hash) VALUES (?, ?, ?, ?)`
+	for _, token := range note.SearchTokens {
+		if _, err := tx.Exec(insertTokenQuery, noteID, userID, token, contentHash); err != nil {
+			return fmt.Errorf("error storing search tokens: %v", err)
+		}
+	}
+
+	return nil
+}
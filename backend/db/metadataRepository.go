@@ -0,0 +1,69 @@
+package db
+
+import (
+	"backend/models"
+	"database/sql"
+	"fmt"
+)
+
+// MetadataRepository handles all database operations related to the encrypted note metadata (folders and tags).
+// Fields:
+// - DB: a pointer to the SQL database connection
+type MetadataRepository struct {
+	DB *sql.DB
+}
+
+// NewMetadataRepository creates a new instance of MetadataRepository.
+// Parameters:
+// - db: a pointer to the SQL database connection
+// Returns: a pointer to the newly created MetadataRepository
+func NewMetadataRepository(db *sql.DB) *MetadataRepository {
+	return &MetadataRepository{
+		DB: db,
+	}
+}
+
+// SetNoteMetadata replaces the encrypted metadata and the blind index tokens of a note.
+// Moving a note to another folder only touches this table, the note blockchain is left untouched.
+// Parameters:
+// - userID: the ID of the user
+// - meta: a pointer to the metadata to store
+// Returns: an error if the operation fails
+func (r *MetadataRepository) SetNoteMetadata(userID uint32, meta *models.NoteMetadata) error {
+	tx, err := r.DB.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	// An empty folder token means the note is not inside any folder
+	var folderToken sql.NullString
+	if meta.FolderToken != "" {
+		folderToken = sql.NullString{String: meta.FolderToken, Valid: true}
+	}
+
+	const upsertQuery = `
+		INSERT INTO note_metadata (note_id, user_id, cipher_meta, iv_meta, folder_token)
+		VALUES (?, ?, ?, ?, ?)
+		ON DUPLICATE KEY UPDATE cipher_meta = VALUES(cipher_meta), iv_meta = VALUES(iv_meta), folder_token = VALUES(folder_token)
+	`
+	_, err = tx.Exec(upsertQuery, meta.NoteID, userID, meta.CipherMeta, meta.IVMeta, folderToken)
+	if err != nil {
+		return fmt.Errorf("error storing metadata: %v", err)
+	}
+
+	// Replace the tag tokens
+	const deleteTagsQuery = `DELETE FROM note_tags WHERE note_id = ? AND user_id = ?`
+	if _, err = tx.Exec(deleteTagsQuery, meta.NoteID, userID); err != nil {
+		return fmt.Errorf("error deleting tags: %v", err)
+	}
+
+	const insertTagQuery = `INSERT IGNORE INTO note_tags (note_id, user_id, tag_token) VALUES (?, ?, ?)`
+	for _, token := range meta.TagTokens {
+		if _, err = tx.Exec(insertTagQuery, meta.NoteID, userID, token); err != nil {
+			return fmt.Errorf("error storing tag: %v", err)
+		}
+	}
+
+	return tx.Commit()
+}
@@ -0,0 +1,256 @@
+package db
+
+import (
+	"backend/models"
+	"database/sql"
+	"errors"
+	"strings"
+	"time"
+)
+
+// NotificationRepository handles all database operations related to the security notifications: the preferences
+// of the users, the deliveries and their retries, and the clients the users logged in from.
+// Fields:
+// - DB: a pointer to the SQL database connection
+type NotificationRepository struct {
+	DB *sql.DB
+}
+
+// NewNotificationRepository creates a new instance of NotificationRepository.
+// Parameters:
+// - db: a pointer to the SQL database connection
+// Returns: a pointer to the newly created NotificationRepository
+func NewNotificationRepository(db *sql.DB) *NotificationRepository {
+	return &NotificationRepository{
+		DB: db,
+	}
+}
+
+// GetPreferences retrieves the notification preferences of a user, with the webhook secret.
+// Parameters:
+// - userID: the ID of the user
+// Returns: the stored preferences, the defaults if the user never changed them, or an error if the query fails
+func (r *NotificationRepository) GetPreferences(userID uint32) (*models.NotificationPreferences, error) {
+	const query = `
+		SELECT email_enabled, webhook_url, webhook_secret, muted_events
+		FROM notification_preferences WHERE user_id = ?
+	`
+	var prefs models.NotificationPreferences
+	var mutedEvents string
+	err := r.DB.QueryRow(query, userID).Scan(&prefs.EmailEnabled, &prefs.WebhookURL, &prefs.WebhookSecret, &mutedEvents)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return &models.NotificationPreferences{EmailEnabled: true, MutedEvents: []string{}}, nil
+		}
+		return nil, err
+	}
+
+	prefs.MutedEvents = []string{}
+	for _, event := range strings.Split(mutedEvents, ",") {
+		if event != "" {
+			prefs.MutedEvents = append(prefs.MutedEvents, event)
+		}
+	}
+	return &prefs, nil
+}
+
+// SavePreferences stores the notification preferences of a user, replacing the previous ones.
+// Parameters:
+// - userID: the ID of the user
+// - prefs: a pointer to the preferences, already validated
+// Returns: an error if the query fails
+func (r *NotificationRepository) SavePreferences(userID uint32, prefs *models.NotificationPreferences) error {
+	const query = `
+		INSERT INTO notification_preferences (user_id, email_enabled, webhook_url, webhook_secret, muted_events)
+		VALUES (?, ?, ?, ?, ?)
+		ON DUPLICATE KEY UPDATE email_enabled = VALUES(email_enabled), webhook_url = VALUES(webhook_url),
+			webhook_secret = VALUES(webhook_secret), muted_events = VALUES(muted_events)
+	`
+	_, err := r.DB.Exec(query, userID, prefs.EmailEnabled, prefs.WebhookURL, prefs.WebhookSecret,
+		strings.Join(prefs.MutedEvents, ","))
+	return err
+}
+
+// CreateDeliveries stores the deliveries of an event, in a single transaction, and sets their IDs.
+// Parameters:
+// - deliveries: the deliveries, pending with their first attempt due
+// Returns: an error if an insertion fails
+func (r *NotificationRepository) CreateDeliveries(deliveries []*models.NotificationDelivery) error {
+	tx, err := r.DB.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	const query = `
+		INSERT INTO notification_deliveries (user_id, event_type, channel, target, secret, payload, status,
+			attempts, next_attempt_at, created_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+	`
+	for _, delivery := range deliveries {
+		result, err := tx.Exec(query, delivery.UserID, delivery.EventType, delivery.Channel, delivery.Target,
+			delivery.Secret, delivery.Payload, delivery.Status, delivery.Attempts, delivery.NextAttemptAt,
+			delivery.CreatedAt)
+		if err != nil {
+			return err
+		}
+		id, err := result.LastInsertId()
+		if err != nil {
+			return err
+		}
+		delivery.ID = uint64(id)
+	}
+	return tx.Commit()
+}
+
+// ClaimDelivery reserves a pending delivery for an attempt by pushing its next attempt back, so the retries of
+// another worker skip it while it runs.
+// Parameters:
+// - id: the ID of the delivery
+// - now: the current time, the delivery must be due
+// - leaseUntil: when the delivery is due again if the attempt never reports back
+// Returns: whether the delivery was claimed, false if it is no longer pending or another worker claimed it,
+// or an error if the update fails
+func (r *NotificationRepository) ClaimDelivery(id uint64, now, leaseUntil time.Time) (bool, error) {
+	const query = `
+		UPDATE notification_deliveries SET next_attempt_at = ?
+		WHERE id = ? AND status = ? AND next_attempt_at <= ?
+	`
+	result, err := r.DB.Exec(query, leaseUntil, id, models.DeliveryPending, now)
+	if err != nil {
+		return false, err
+	}
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+	return affected == 1, nil
+}
+
+// GetDueDeliveries retrieves the pending deliveries whose next attempt is due, the oldest first.
+// Parameters:
+// - now: the current time
+// - limit: the maximum number of deliveries
+// Returns: the due deliveries, or an error if the query fails
+func (r *NotificationRepository) GetDueDeliveries(now time.Time, limit int) ([]*models.NotificationDelivery, error) {
+	const query = `
+		SELECT id, user_id, event_type, channel, target, secret, payload, status, attempts, next_attempt_at,
+			last_error, created_at
+		FROM notification_deliveries
+		WHERE status = ? AND next_attempt_at <= ?
+		ORDER BY next_attempt_at
+		LIMIT ?
+	`
+	rows, err := r.DB.Query(query, models.DeliveryPending, now, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	deliveries := []*models.NotificationDelivery{}
+	for rows.Next() {
+		var delivery models.NotificationDelivery
+		err := rows.Scan(&delivery.ID, &delivery.UserID, &delivery.EventType, &delivery.Channel, &delivery.Target,
+			&delivery.Secret, &delivery.Payload, &delivery.Status, &delivery.Attempts, &delivery.NextAttemptAt,
+			&delivery.LastError, &delivery.CreatedAt)
+		if err != nil {
+			return nil, err
+		}
+		deliveries = append(deliveries, &delivery)
+	}
+	return deliveries, rows.Err()
+}
+
+// UpdateDelivery records the outcome of an attempt: its status, its number of attempts, when it is retried and
+// the error of the attempt.
+// Parameters:
+// - delivery: a pointer to the delivery with its new state
+// Returns: an error if the update fails
+func (r *NotificationRepository) UpdateDelivery(delivery *models.NotificationDelivery) error {
+	const query = `
+		UPDATE notification_deliveries SET status = ?, attempts = ?, next_attempt_at = ?, last_error = ?
+		WHERE id = ?
+	`
+	lastError := delivery.LastError
+	if len(lastError) > 255 {
+		lastError = lastError[:255]
+	}
+	_, err := r.DB.Exec(query, delivery.Status, delivery.Attempts, delivery.NextAttemptAt, lastError, delivery.ID)
+	return err
+}
+
+// GetRecentDeliveries retrieves the latest deliveries of the notifications of a user, the most recent first.
+// Parameters:
+// - userID: the ID of the user
+// - limit: the maximum number of deliveries
+// Returns: the deliveries, without their payload and secret, or an error if the query fails
+func (r *NotificationRepository) GetRecentDeliveries(userID uint32, limit int) ([]*models.NotificationDelivery, error) {
+	const query = `
+		SELECT id, event_type, channel, target, status, attempts, next_attempt_at, last_error, created_at
+		FROM notification_deliveries
+		WHERE user_id = ?
+		ORDER BY created_at DESC, id DESC
+		LIMIT ?
+	`
+	rows, err := r.DB.Query(query, userID, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	deliveries := []*models.NotificationDelivery{}
+	for rows.Next() {
+		delivery := models.NotificationDelivery{UserID: userID}
+		err := rows.Scan(&delivery.ID, &delivery.EventType, &delivery.Channel, &delivery.Target, &delivery.Status,
+			&delivery.Attempts, &delivery.NextAttemptAt, &delivery.LastError, &delivery.CreatedAt)
+		if err != nil {
+			return nil, err
+		}
+		deliveries = append(deliveries, &delivery)
+	}
+	return deliveries, rows.Err()
+}
+
+// DeleteDeliveriesBefore removes the sent and failed deliveries created before a date.
+// Parameters:
+// - before: the deliveries created before this time are removed
+// Returns: the number of removed deliveries, or an error if the deletion fails
+func (r *NotificationRepository) DeleteDeliveriesBefore(before time.Time) (int64, error) {
+	const query = `DELETE FROM notification_deliveries WHERE status <> ? AND created_at < ?`
+	result, err := r.DB.Exec(query, models.DeliveryPending, before)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+// RecordLoginSource records a login of a user from a client, identified by a fingerprint.
+// Parameters:
+// - userID: the ID of the user
+// - fingerprint: the hex SHA-256 of the IP address and the user agent of the client
+// - seenAt: the time of the login
+// Returns: whether the client is new while the user already logged in from others, meaning the login is worth
+// a notification, or an error if a query fails
+func (r *NotificationRepository) RecordLoginSource(userID uint32, fingerprint string, seenAt time.Time) (bool, error) {
+	var sources int
+	err := r.DB.QueryRow(`SELECT COUNT(*) FROM login_sources WHERE user_id = ?`, userID).Scan(&sources)
+	if err != nil {
+		return false, err
+	}
+
+	const query = `
+		INSERT INTO login_sources (user_id, fingerprint, first_seen, last_seen)
+		VALUES (?, ?, ?, ?)
+		ON DUPLICATE KEY UPDATE last_seen = VALUES(last_seen)
+	`
+	result, err := r.DB.Exec(query, userID, fingerprint, seenAt, seenAt)
+	if err != nil {
+		return false, err
+	}
+	// MySQL counts 1 affected row for an insertion, 2 for an update and 0 for an unchanged row
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+	return affected == 1 && sources > 0, nil
+}
@@ -0,0 +1,43 @@
+package db
+
+import (
+	"database/sql"
+	"fmt"
+	"strings"
+)
+
+// The search index only holds keyed keyword tokens computed by the client, never the keywords themselves.
+// It is written with the blocks, see CreateBlock and CreateNewNote, and filtered on by GetTitles.
+
+// replaceNoteTokens replaces the search tokens of a note with the tokens of its newest version, inside the
+// transaction appending that version so the index never lags behind the head of the note.
+// Parameters:
+// - tx: the transaction appending the version, the note row must already be locked
+// - userID: the ID of the user
+// - noteID: the ID of the note
+// - blockHash: the hash of the block (note version) the tokens were computed for
+// - tokens: the keyed keyword tokens, an empty slice removes the note from the index
+// Returns: an error if the operation fails
+func replaceNoteTokens(tx *sql.Tx, userID uint32, noteID string, blockHash string, tokens []string) error {
+	// The tokens of the previous version are stale as soon as a new head is appended
+	const deleteQuery = `DELETE FROM search_index WHERE note_id = ? AND user_id = ?`
+	if _, err := tx.Exec(deleteQuery, noteID, userID); err != nil {
+		return fmt.Errorf("error deleting search tokens: %v", err)
+	}
+
+	if len(tokens) > 0 {
+		// Insert all the tokens with a single statement
+		values := strings.TrimSuffix(strings.Repeat("(?, ?, ?, ?), ", len(tokens)), ", ")
+		args := make([]any, 0, len(tokens)*4)
+		for _, token := range tokens {
+			args = append(args, noteID, userID, token, blockHash)
+		}
+
+		insertQuery := `INSERT IGNORE INTO search_index (note_id, user_id, token, block_hash) VALUES ` + values
+		if _, err := tx.Exec(insertQuery, args...); err != nil {
+			return fmt.Errorf("error storing search tokens: %v", err)
+		}
+	}
+
+	return nil
+}
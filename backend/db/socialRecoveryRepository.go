@@ -0,0 +1,393 @@
+package db
+
+import (
+	"backend/models"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+var (
+	// ErrNoRecoveryShares is returned when a user did not split their recovery key, or a contact holds no share of it
+	ErrNoRecoveryShares = errors.New("no recovery shares")
+	// ErrRecoveryRequestNotFound is returned when a social recovery does not exist, is no longer pending or expired
+	ErrRecoveryRequestNotFound = errors.New("recovery request not found")
+	// ErrShareSubmitted is returned when a contact submits their share twice for the same recovery
+	ErrShareSubmitted = errors.New("the share was already submitted")
+)
+
+// SocialRecoveryRepository handles all database operations related to the shares of the recovery keys and the
+// social recoveries.
+// Fields:
+// - DB: a pointer to the SQL database connection
+type SocialRecoveryRepository struct {
+	DB *sql.DB
+}
+
+// NewSocialRecoveryRepository creates a new instance of SocialRecoveryRepository.
+// Parameters:
+// - db: a pointer to the SQL database connection
+// Returns: a pointer to the newly created SocialRecoveryRepository
+func NewSocialRecoveryRepository(db *sql.DB) *SocialRecoveryRepository {
+	return &SocialRecoveryRepository{
+		DB: db,
+	}
+}
+
+// ReplaceShares replaces the shares of the recovery key of a user, in a single transaction.
+// The pending recoveries of the user are cancelled, the shares their contacts would submit belong to the old set.
+// Parameters:
+// - ownerID: the ID of the user
+// - shares: the new shares, their contact keys already checked
+// - recoveryPubKey: the public key of a new recovery key, empty when the shares split the current one
+// - recoveryEscrow: the keys sealed under the new recovery key, empty when the shares split the current one
+// Returns: an error if a query fails
+func (r *SocialRecoveryRepository) ReplaceShares(ownerID uint32, shares []*models.RecoveryShare, recoveryPubKey, recoveryEscrow string) error {
+	tx, err := r.DB.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if recoveryPubKey != "" {
+		const updateUserQuery = `UPDATE users SET recovery_pub_key = ?, recovery_escrow = ? WHERE id = ?`
+		if _, err := tx.Exec(updateUserQuery, recoveryPubKey, recoveryEscrow, ownerID); err != nil {
+			return fmt.Errorf("error updating the recovery key: %v", err)
+		}
+	}
+
+	const cancelQuery = `UPDATE recovery_requests SET status = ? WHERE user_id = ? AND status = ?`
+	if _, err := tx.Exec(cancelQuery, models.RecoveryCancelled, ownerID, models.RecoveryPending); err != nil {
+		return fmt.Errorf("error cancelling the recoveries: %v", err)
+	}
+	if _, err := tx.Exec(`DELETE FROM recovery_shares WHERE owner_id = ?`, ownerID); err != nil {
+		return fmt.Errorf("error deleting the shares: %v", err)
+	}
+
+	const insertQuery = `
+		INSERT INTO recovery_shares (owner_id, contact_id, share_index, threshold, contact_key, encrypted_share, created_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?)
+	`
+	now := time.Now().UTC()
+	for _, share := range shares {
+		_, err := tx.Exec(insertQuery, ownerID, share.ContactID, share.ShareIndex, share.Threshold, share.ContactKey,
+			share.EncryptedShare, now)
+		if err != nil {
+			return fmt.Errorf("error inserting the share of contact %d: %v", share.ContactID, err)
+		}
+	}
+
+	return tx.Commit()
+}
+
+// GetShares retrieves the shares a user distributed, without their content.
+// Parameters:
+// - ownerID: the ID of the user
+// Returns: the shares ordered by index, marked stale when the contact changed their key since, or an error if a
+// query error occurs
+func (r *SocialRecoveryRepository) GetShares(ownerID uint32) ([]*models.RecoveryShare, error) {
+	const query = `
+		SELECT s.contact_id, u.name, u.email, s.share_index, s.threshold, s.contact_key, s.created_at, u.pub_key
+		FROM recovery_shares s
+		JOIN users u ON u.id = s.contact_id
+		WHERE s.owner_id = ?
+		ORDER BY s.share_index ASC
+	`
+	rows, err := r.DB.Query(query, ownerID)
+	if err != nil {
+		return nil, fmt.Errorf("error querying shares: %v", err)
+	}
+	defer rows.Close()
+
+	shares := []*models.RecoveryShare{}
+	for rows.Next() {
+		share := &models.RecoveryShare{}
+		var contactPubKey string
+		if err := rows.Scan(&share.ContactID, &share.ContactName, &share.ContactEmail, &share.ShareIndex,
+			&share.Threshold, &share.ContactKey, &share.CreatedAt, &contactPubKey); err != nil {
+			return nil, fmt.Errorf("error scanning share: %v", err)
+		}
+		share.Stale = contactPubKey != share.ContactKey
+		shares = append(shares, share)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating over rows: %v", err)
+	}
+
+	return shares, nil
+}
+
+// GetHeldShares retrieves the shares a contact holds for other users, with their pending recoveries.
+// Parameters:
+// - contactID: the ID of the contact
+// Returns: the shares, or an error if a query error occurs
+func (r *SocialRecoveryRepository) GetHeldShares(contactID uint32) ([]*models.HeldShare, error) {
+	const query = `
+		SELECT s.owner_id, u.name, u.email, s.share_index, s.threshold, s.contact_key, s.encrypted_share
+		FROM recovery_shares s
+		JOIN users u ON u.id = s.owner_id
+		WHERE s.contact_id = ?
+		ORDER BY u.name ASC
+	`
+	rows, err := r.DB.Query(query, contactID)
+	if err != nil {
+		return nil, fmt.Errorf("error querying held shares: %v", err)
+	}
+
+	shares := []*models.HeldShare{}
+	var ownerIDs []uint32
+	for rows.Next() {
+		share := &models.HeldShare{}
+		var ownerID uint32
+		if err := rows.Scan(&ownerID, &share.OwnerName, &share.OwnerEmail, &share.ShareIndex, &share.Threshold,
+			&share.ContactKey, &share.EncryptedShare); err != nil {
+			rows.Close()
+			return nil, fmt.Errorf("error scanning held share: %v", err)
+		}
+		shares = append(shares, share)
+		ownerIDs = append(ownerIDs, ownerID)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating over rows: %v", err)
+	}
+
+	for i, share := range shares {
+		if share.Requests, err = r.GetPendingRequests(ownerIDs[i]); err != nil {
+			return nil, err
+		}
+	}
+	return shares, nil
+}
+
+// GetShare retrieves the share a contact holds for a user.
+// Parameters:
+// - ownerID: the ID of the user
+// - contactID: the ID of the contact
+// Returns: the share, ErrNoRecoveryShares if the contact holds none, or an error if a query error occurs
+func (r *SocialRecoveryRepository) GetShare(ownerID, contactID uint32) (*models.RecoveryShare, error) {
+	const query = `
+		SELECT contact_id, share_index, threshold, contact_key, encrypted_share, created_at
+		FROM recovery_shares
+		WHERE owner_id = ? AND contact_id = ?
+	`
+	share := &models.RecoveryShare{}
+	err := r.DB.QueryRow(query, ownerID, contactID).Scan(&share.ContactID, &share.ShareIndex, &share.Threshold,
+		&share.ContactKey, &share.EncryptedShare, &share.CreatedAt)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, fmt.Errorf("%w: ownerID %d and contactID %d", ErrNoRecoveryShares, ownerID, contactID)
+		}
+		return nil, fmt.Errorf("error scanning share: %v", err)
+	}
+	return share, nil
+}
+
+// GetThreshold retrieves the number of shares needed to rebuild the recovery key of a user.
+// Parameters:
+// - ownerID: the ID of the user
+// Returns: the threshold, ErrNoRecoveryShares if the user did not split their recovery key, or an error if a
+// query error occurs
+func (r *SocialRecoveryRepository) GetThreshold(ownerID uint32) (uint8, error) {
+	const query = `SELECT threshold FROM recovery_shares WHERE owner_id = ? LIMIT 1`
+
+	var threshold uint8
+	if err := r.DB.QueryRow(query, ownerID).Scan(&threshold); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return 0, fmt.Errorf("%w: ownerID %d", ErrNoRecoveryShares, ownerID)
+		}
+		return 0, fmt.Errorf("error scanning threshold: %v", err)
+	}
+	return threshold, nil
+}
+
+// CreateRequest stores a new social recovery of a user.
+// Parameters:
+// - userID: the ID of the user being recovered
+// - request: a pointer to the recovery, pending
+// Returns: an error if the insertion fails
+func (r *SocialRecoveryRepository) CreateRequest(userID uint32, request *models.RecoveryRequest) error {
+	const query = `
+		INSERT INTO recovery_requests (id, user_id, ephemeral_key, threshold, status, created_at, release_at, expires_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?)
+	`
+	_, err := r.DB.Exec(query, request.ID, userID, request.EphemeralKey, request.Threshold, request.Status,
+		request.CreatedAt, request.ReleaseAt, request.ExpiresAt)
+	return err
+}
+
+// CreateDummyRequest stores a made up social recovery, started for an email without shares.
+// It is stored like a real one so it answers the status lookups the same way, and counts toward the pending limit.
+// Parameters:
+// - emailDigest: the hex digest of the email, see auth.EmailDigest
+// - request: a pointer to the recovery, pending
+// Returns: an error if the insertion fails
+func (r *SocialRecoveryRepository) CreateDummyRequest(emailDigest string, request *models.RecoveryRequest) error {
+	const query = `
+		INSERT INTO recovery_requests (id, email_digest, ephemeral_key, threshold, status, created_at, release_at, expires_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?)
+	`
+	_, err := r.DB.Exec(query, request.ID, emailDigest, request.EphemeralKey, request.Threshold, request.Status,
+		request.CreatedAt, request.ReleaseAt, request.ExpiresAt)
+	return err
+}
+
+// CountPendingDummyRequests counts the made up social recoveries of an email that are pending and not expired.
+// Parameters:
+// - emailDigest: the hex digest of the email, see auth.EmailDigest
+// Returns: the number of recoveries, or an error if a query error occurs
+func (r *SocialRecoveryRepository) CountPendingDummyRequests(emailDigest string) (int, error) {
+	const query = `
+		SELECT COUNT(*) FROM recovery_requests
+		WHERE email_digest = ? AND status = ? AND expires_at > ?
+	`
+	var count int
+	if err := r.DB.QueryRow(query, emailDigest, models.RecoveryPending, time.Now().UTC()).Scan(&count); err != nil {
+		return 0, fmt.Errorf("error counting recovery requests: %v", err)
+	}
+	return count, nil
+}
+
+// requestColumns are the columns of a recovery, with the number of shares submitted for it
+const requestColumns = `
+	r.id, r.status, r.ephemeral_key, r.threshold, r.created_at, r.release_at, r.expires_at,
+	(SELECT COUNT(*) FROM recovery_submissions s WHERE s.request_id = r.id)
+`
+
+// scanRequest scans a row of requestColumns
+func scanRequest(row interface{ Scan(...any) error }, request *models.RecoveryRequest) error {
+	return row.Scan(&request.ID, &request.Status, &request.EphemeralKey, &request.Threshold, &request.CreatedAt,
+		&request.ReleaseAt, &request.ExpiresAt, &request.Submitted)
+}
+
+// GetPendingRequests retrieves the social recoveries of a user that are pending and not expired.
+// Parameters:
+// - userID: the ID of the user
+// Returns: the recoveries, oldest first, or an error if a query error occurs
+func (r *SocialRecoveryRepository) GetPendingRequests(userID uint32) ([]*models.RecoveryRequest, error) {
+	query := `SELECT ` + requestColumns + `
+		FROM recovery_requests r
+		WHERE r.user_id = ? AND r.status = ? AND r.expires_at > ?
+		ORDER BY r.created_at ASC
+	`
+	rows, err := r.DB.Query(query, userID, models.RecoveryPending, time.Now().UTC())
+	if err != nil {
+		return nil, fmt.Errorf("error querying recovery requests: %v", err)
+	}
+	defer rows.Close()
+
+	requests := []*models.RecoveryRequest{}
+	for rows.Next() {
+		request := &models.RecoveryRequest{}
+		if err := scanRequest(rows, request); err != nil {
+			return nil, fmt.Errorf("error scanning recovery request: %v", err)
+		}
+		requests = append(requests, request)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating over rows: %v", err)
+	}
+
+	return requests, nil
+}
+
+// GetRequest retrieves a social recovery, whatever its status.
+// Parameters:
+// - requestID: the ID of the recovery
+// Returns: the recovery and the ID of the user it recovers, 0 for a made up recovery, ErrRecoveryRequestNotFound
+// if it does not exist, or an error if a query error occurs
+func (r *SocialRecoveryRepository) GetRequest(requestID string) (*models.RecoveryRequest, uint32, error) {
+	query := `SELECT COALESCE(r.user_id, 0), ` + requestColumns + ` FROM recovery_requests r WHERE r.id = ?`
+
+	request := &models.RecoveryRequest{}
+	var userID uint32
+	err := r.DB.QueryRow(query, requestID).Scan(&userID, &request.ID, &request.Status, &request.EphemeralKey,
+		&request.Threshold, &request.CreatedAt, &request.ReleaseAt, &request.ExpiresAt, &request.Submitted)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, 0, fmt.Errorf("%w: requestID %s", ErrRecoveryRequestNotFound, requestID)
+		}
+		return nil, 0, fmt.Errorf("error scanning recovery request: %v", err)
+	}
+	return request, userID, nil
+}
+
+// CancelRequest cancels a pending social recovery of a user.
+// Parameters:
+// - userID: the ID of the user
+// - requestID: the ID of the recovery
+// Returns: ErrRecoveryRequestNotFound if the user has no such pending recovery, or an error if the update fails
+func (r *SocialRecoveryRepository) CancelRequest(userID uint32, requestID string) error {
+	const query = `UPDATE recovery_requests SET status = ? WHERE id = ? AND user_id = ? AND status = ?`
+	result, err := r.DB.Exec(query, models.RecoveryCancelled, requestID, userID, models.RecoveryPending)
+	if err != nil {
+		return err
+	}
+
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if affected == 0 {
+		return fmt.Errorf("%w: requestID %s and userID %d", ErrRecoveryRequestNotFound, requestID, userID)
+	}
+	return nil
+}
+
+// CompleteRequests marks the pending social recoveries of a user as completed, once the account was re-keyed
+// Parameters:
+// - userID: the ID of the user
+// Returns: an error if the update fails
+func (r *SocialRecoveryRepository) CompleteRequests(userID uint32) error {
+	const query = `UPDATE recovery_requests SET status = ? WHERE user_id = ? AND status = ?`
+	_, err := r.DB.Exec(query, models.RecoveryCompleted, userID, models.RecoveryPending)
+	return err
+}
+
+// AddSubmission stores the share a contact submits for a social recovery.
+// Parameters:
+// - requestID: the ID of the recovery
+// - contactID: the ID of the contact
+// - submission: a pointer to the share, sealed to the ephemeral key of the recovery
+// Returns: ErrShareSubmitted if the contact already submitted their share, or an error if the insertion fails
+func (r *SocialRecoveryRepository) AddSubmission(requestID string, contactID uint32, submission *models.RecoverySubmission) error {
+	const query = `
+		INSERT INTO recovery_submissions (request_id, contact_id, share_index, encrypted_share, created_at)
+		VALUES (?, ?, ?, ?, ?)
+	`
+	_, err := r.DB.Exec(query, requestID, contactID, submission.ShareIndex, submission.EncryptedShare, time.Now().UTC())
+	if err != nil && strings.Contains(err.Error(), "Duplicate entry") {
+		return ErrShareSubmitted
+	}
+	return err
+}
+
+// GetSubmissions retrieves the shares submitted for a social recovery.
+// Parameters:
+// - requestID: the ID of the recovery
+// Returns: the shares ordered by index, or an error if a query error occurs
+func (r *SocialRecoveryRepository) GetSubmissions(requestID string) ([]*models.RecoverySubmission, error) {
+	const query = `SELECT share_index, encrypted_share FROM recovery_submissions WHERE request_id = ? ORDER BY share_index ASC`
+
+	rows, err := r.DB.Query(query, requestID)
+	if err != nil {
+		return nil, fmt.Errorf("error querying submissions: %v", err)
+	}
+	defer rows.Close()
+
+	submissions := []*models.RecoverySubmission{}
+	for rows.Next() {
+		submission := &models.RecoverySubmission{}
+		if err := rows.Scan(&submission.ShareIndex, &submission.EncryptedShare); err != nil {
+			return nil, fmt.Errorf("error scanning submission: %v", err)
+		}
+		submissions = append(submissions, submission)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating over rows: %v", err)
+	}
+
+	return submissions, nil
+}
@@ -0,0 +1,76 @@
+package db
+
+import (
+	"backend/models"
+	"database/sql"
+	"fmt"
+)
+
+// TimestampRepository handles all database operations related to the time-stamp tokens of the blocks.
+// Fields:
+// - DB: a pointer to the SQL database connection
+type TimestampRepository struct {
+	DB *sql.DB
+}
+
+// NewTimestampRepository creates a new instance of TimestampRepository.
+// Parameters:
+// - db: a pointer to the SQL database connection
+// Returns: a pointer to the newly created TimestampRepository
+func NewTimestampRepository(db *sql.DB) *TimestampRepository {
+	return &TimestampRepository{
+		DB: db,
+	}
+}
+
+// SaveBlockTimestamp stores the time-stamp token of a block.
+// Parameters:
+// - noteID: the ID of the note
+// - timestamp: a pointer to the token, its seq selects the block
+// Returns: an error if the block does not exist or the insertion fails
+func (r *TimestampRepository) SaveBlockTimestamp(noteID string, timestamp *models.BlockTimestamp) error {
+	const query = `
+		INSERT INTO block_timestamps (note_id, seq, authority, token, gen_time)
+		VALUES (?, ?, ?, ?, ?)
+	`
+
+	_, err := r.DB.Exec(query, noteID, timestamp.Seq, timestamp.Authority, timestamp.Token, timestamp.GenTime)
+	if err != nil {
+		return fmt.Errorf("error inserting timestamp of block %d of note %s: %v", timestamp.Seq, noteID, err)
+	}
+	return nil
+}
+
+// GetNoteTimestamps retrieves the time-stamp tokens of every block of a note.
+// Parameters:
+// - userID: the ID of the user
+// - noteID: the ID of the note
+// Returns: the tokens indexed by the seq of their block, or an error if the query fails
+func (r *TimestampRepository) GetNoteTimestamps(userID uint32, noteID string) (map[uint]*models.BlockTimestamp, error) {
+	const query = `
+		SELECT t.seq, t.authority, t.token, t.gen_time
+		FROM block_timestamps t
+		INNER JOIN notes n ON n.id = t.note_id
+		WHERE t.note_id = ? AND n.user_id = ?
+	`
+
+	rows, err := r.DB.Query(query, noteID, userID)
+	if err != nil {
+		return nil, fmt.Errorf("error querying timestamps: %v", err)
+	}
+	defer rows.Close()
+
+	timestamps := make(map[uint]*models.BlockTimestamp)
+	for rows.Next() {
+		timestamp := &models.BlockTimestamp{}
+		if err := rows.Scan(&timestamp.Seq, &timestamp.Authority, &timestamp.Token, &timestamp.GenTime); err != nil {
+			return nil, fmt.Errorf("error scanning timestamp: %v", err)
+		}
+		timestamps[timestamp.Seq] = timestamp
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating timestamps: %v", err)
+	}
+
+	return timestamps, nil
+}
@@ -0,0 +1,492 @@
+package db
+
+import (
+	"backend/crypto"
+	"backend/models"
+	"database/sql"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"sync"
+)
+
+var (
+	// ErrLogEntryNotFound is returned when a block has no entry in the transparency log
+	ErrLogEntryNotFound = errors.New("log entry not found")
+	// ErrTreeHeadNotFound is returned when no tree head was signed for the requested size
+	ErrTreeHeadNotFound = errors.New("tree head not found")
+)
+
+// TransparencyRepository provides methods to read the global transparency log.
+// Every accepted block is committed to the log, and the server periodically signs its tree head,
+// so the operator is accountable for the blocks it stored even though it cannot read them.
+// Fields:
+// - DB: a pointer to the SQL database connection
+type TransparencyRepository struct {
+	DB *sql.DB
+}
+
+// NewTransparencyRepository creates a new instance of TransparencyRepository.
+// Parameters:
+// - db: a pointer to the SQL database connection
+// Returns: a pointer to the newly created TransparencyRepository
+func NewTransparencyRepository(db *sql.DB) *TransparencyRepository {
+	return &TransparencyRepository{
+		DB: db,
+	}
+}
+
+// GetTreeSize retrieves the number of entries of the transparency log.
+// Returns: the size of the log, or an error if a query error occurs
+func (r *TransparencyRepository) GetTreeSize() (int, error) {
+	const query = `SELECT size FROM transparency_log_size WHERE id = 1`
+
+	var size int
+	if err := r.DB.QueryRow(query).Scan(&size); err != nil {
+		return 0, err
+	}
+	return size, nil
+}
+
+// GetEntries retrieves a range of entries of the transparency log.
+// Parameters:
+// - start: the index of the first entry
+// - end: the index after the last entry
+// Returns: the entries ordered by index, or an error if a query error occurs
+func (r *TransparencyRepository) GetEntries(start, end int) ([]*models.LogEntry, error) {
+	const query = `
+		SELECT leaf_index, note_id, seq, block_hash, leaf_hash, created_at
+		FROM transparency_log
+		WHERE leaf_index >= ? AND leaf_index < ?
+		ORDER BY leaf_index
+	`
+
+	rows, err := r.DB.Query(query, start, end)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var entries []*models.LogEntry
+	for rows.Next() {
+		entry := &models.LogEntry{}
+		if err := rows.Scan(
+			&entry.Index,
+			&entry.NoteID,
+			&entry.Seq,
+			&entry.BlockHash,
+			&entry.LeafHash,
+			&entry.CreatedAt,
+		); err != nil {
+			return nil, err
+		}
+		entries = append(entries, entry)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return entries, nil
+}
+
+// leafBatchSize is the number of leaf hashes read per query while the cached tree catches up with the log
+const leafBatchSize = 10000
+
+// transparencyTree caches the Merkle tree of the transparency log. The leaves of the log never change, so the tree
+// only reads the leaves appended since it was last used, and the roots and proofs never rehash the whole log.
+var transparencyTree struct {
+	sync.Mutex
+	tree crypto.MerkleTree
+}
+
+// withTree calls a function with the cached Merkle tree of the log, once it holds at least the first leaves.
+// Parameters:
+// - size: the number of leaves the function needs
+// - fn: the function, it must not keep the tree
+// Returns: the error returned by fn, or an error if the log has fewer leaves or a query error occurs
+func (r *TransparencyRepository) withTree(size int, fn func(tree *crypto.MerkleTree) error) error {
+	transparencyTree.Lock()
+	defer transparencyTree.Unlock()
+
+	tree := &transparencyTree.tree
+	for tree.Size() < size {
+		hashes, err := r.getLeafHashes(tree.Size(), min(size, tree.Size()+leafBatchSize))
+		if err != nil {
+			return err
+		}
+		if len(hashes) == 0 {
+			return fmt.Errorf("the transparency log has only %d leaves, %d requested", tree.Size(), size)
+		}
+		for _, hash := range hashes {
+			tree.Append(hash)
+		}
+	}
+	return fn(tree)
+}
+
+// GetRoot computes the root hash of the first leaves of the log.
+// Parameters:
+// - treeSize: the number of leaves
+// Returns: the root hash, or an error if the log has fewer leaves or a query error occurs
+func (r *TransparencyRepository) GetRoot(treeSize int) ([]byte, error) {
+	var root []byte
+	err := r.withTree(treeSize, func(tree *crypto.MerkleTree) (err error) {
+		root, err = tree.Root(treeSize)
+		return err
+	})
+	return root, err
+}
+
+// GetInclusionProof computes the audit path of an entry in the tree of the first leaves of the log.
+// Parameters:
+// - index: the position of the entry
+// - treeSize: the number of leaves of the tree
+// Returns: the audit path, or an error if the entry is outside the tree or a query error occurs
+func (r *TransparencyRepository) GetInclusionProof(index, treeSize int) ([][]byte, error) {
+	var path [][]byte
+	err := r.withTree(treeSize, func(tree *crypto.MerkleTree) (err error) {
+		path, err = tree.InclusionProof(index, treeSize)
+		return err
+	})
+	return path, err
+}
+
+// GetConsistencyProof computes the proof that the log only grew between two sizes, with the roots of both sizes.
+// Parameters:
+// - first: the size of the older tree
+// - second: the size of the newer tree
+// Returns: the proof, the root of the older tree, the root of the newer tree, or an error if a size is outside
+// the log or a query error occurs
+func (r *TransparencyRepository) GetConsistencyProof(first, second int) ([][]byte, []byte, []byte, error) {
+	var proof [][]byte
+	var firstRoot, secondRoot []byte
+	err := r.withTree(second, func(tree *crypto.MerkleTree) (err error) {
+		if proof, err = tree.ConsistencyProof(first, second); err != nil {
+			return err
+		}
+		if firstRoot, err = tree.Root(first); err != nil {
+			return err
+		}
+		secondRoot, err = tree.Root(second)
+		return err
+	})
+	return proof, firstRoot, secondRoot, err
+}
+
+// getLeafHashes retrieves the decoded leaf hashes of a range of entries of the log.
+// Parameters:
+// - start: the index of the first entry
+// - end: the index after the last entry
+// Returns: the leaf hashes ordered by index, or an error if a query error occurs
+func (r *TransparencyRepository) getLeafHashes(start, end int) ([][]byte, error) {
+	const query = `SELECT leaf_hash FROM transparency_log WHERE leaf_index >= ? AND leaf_index < ? ORDER BY leaf_index`
+
+	rows, err := r.DB.Query(query, start, end)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	hashes := make([][]byte, 0, end-start)
+	for rows.Next() {
+		var leafHash string
+		if err := rows.Scan(&leafHash); err != nil {
+			return nil, err
+		}
+		hash, err := base64.StdEncoding.DecodeString(leafHash)
+		if err != nil {
+			return nil, fmt.Errorf("invalid leaf hash at index %d: %v", start+len(hashes), err)
+		}
+		hashes = append(hashes, hash)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return hashes, nil
+}
+
+// GetEntryByBlockHash retrieves the log entry of a block.
+// Parameters:
+// - blockHash: the hash of the block
+// Returns: a pointer to the entry, or ErrLogEntryNotFound if the block was never committed to the log
+func (r *TransparencyRepository) GetEntryByBlockHash(blockHash string) (*models.LogEntry, error) {
+	const query = `
+		SELECT leaf_index, note_id, seq, block_hash, leaf_hash, created_at
+		FROM transparency_log
+		WHERE block_hash = ? AND leaf_index IS NOT NULL
+		ORDER BY leaf_index
+		LIMIT 1
+	`
+
+	entry := &models.LogEntry{}
+	err := r.DB.QueryRow(query, blockHash).Scan(
+		&entry.Index,
+		&entry.NoteID,
+		&entry.Seq,
+		&entry.BlockHash,
+		&entry.LeafHash,
+		&entry.CreatedAt,
+	)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, fmt.Errorf("%w: block %s", ErrLogEntryNotFound, blockHash)
+		}
+		return nil, err
+	}
+	return entry, nil
+}
+
+// SaveTreeHead stores a signed tree head.
+// Parameters:
+// - sth: a pointer to the signed tree head
+// Returns: an error if the insertion fails
+func (r *TransparencyRepository) SaveTreeHead(sth *models.SignedTreeHead) error {
+	const query = `
+		INSERT INTO signed_tree_heads (tree_size, root_hash, timestamp, key_id, signature)
+		VALUES (?, ?, ?, ?, ?)
+	`
+
+	_, err := r.DB.Exec(query, sth.TreeSize, sth.RootHash, sth.Timestamp, sth.KeyID, sth.Signature)
+	if err != nil {
+		return fmt.Errorf("error storing tree head: %v", err)
+	}
+	return nil
+}
+
+// GetLatestTreeHead retrieves the most recent signed tree head.
+// Returns: a pointer to the tree head, or ErrTreeHeadNotFound if no tree head was signed yet
+func (r *TransparencyRepository) GetLatestTreeHead() (*models.SignedTreeHead, error) {
+	const query = `
+		SELECT tree_size, root_hash, timestamp, key_id, signature
+		FROM signed_tree_heads
+		ORDER BY tree_size DESC
+		LIMIT 1
+	`
+	return r.scanTreeHead(r.DB.QueryRow(query))
+}
+
+// GetTreeHead retrieves the signed tree head of a given size.
+// Parameters:
+// - treeSize: the size of the tree head
+// Returns: a pointer to the tree head, or ErrTreeHeadNotFound if no tree head was signed for this size
+func (r *TransparencyRepository) GetTreeHead(treeSize int) (*models.SignedTreeHead, error) {
+	const query = `
+		SELECT tree_size, root_hash, timestamp, key_id, signature
+		FROM signed_tree_heads
+		WHERE tree_size = ?
+	`
+	return r.scanTreeHead(r.DB.QueryRow(query, treeSize))
+}
+
+// scanTreeHead reads a signed tree head from a query row
+func (r *TransparencyRepository) scanTreeHead(row *sql.Row) (*models.SignedTreeHead, error) {
+	sth := &models.SignedTreeHead{}
+	err := row.Scan(&sth.TreeSize, &sth.RootHash, &sth.Timestamp, &sth.KeyID, &sth.Signature)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrTreeHeadNotFound
+		}
+		return nil, err
+	}
+	return sth, nil
+}
+
+// GetTreeHeads retrieves every signed tree head, smallest first.
+// Returns: the signed tree heads, or an error if a query error occurs
+func (r *TransparencyRepository) GetTreeHeads() ([]*models.SignedTreeHead, error) {
+	const query = `
+		SELECT tree_size, root_hash, timestamp, key_id, signature
+		FROM signed_tree_heads
+		ORDER BY tree_size
+	`
+
+	rows, err := r.DB.Query(query)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var heads []*models.SignedTreeHead
+	for rows.Next() {
+		sth := &models.SignedTreeHead{}
+		if err := rows.Scan(&sth.TreeSize, &sth.RootHash, &sth.Timestamp, &sth.KeyID, &sth.Signature); err != nil {
+			return nil, err
+		}
+		heads = append(heads, sth)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return heads, nil
+}
+
+// ScanBlocks calls a function for every stored block, used by the auditor to check the log against the blocks.
+// Parameters:
+// - fn: the function called with the note ID, seq and content of each block, an error stops the scan
+// Returns: the error returned by fn, or an error if a query error occurs
+func (r *TransparencyRepository) ScanBlocks(fn func(noteID string, seq uint, block *models.Block) error) error {
+	const query = `
+		SELECT note_id, seq, prev_hash, timestamp, iv, iv_title, cipher_title, ciphertext, mac, signature,
+			nonce, nonce_title, tag, tag_title, pq_signature, kind
+		FROM blocks
+		ORDER BY note_id, seq
+	`
+
+	rows, err := r.DB.Query(query)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var noteID string
+		var seq uint
+		block := &models.Block{}
+		if err := rows.Scan(
+			&noteID,
+			&seq,
+			&block.PrevHash,
+			&block.Timestamp,
+			&block.IV,
+			&block.IVTitle,
+			&block.CipherTitle,
+			&block.Ciphertext,
+			&block.MAC,
+			&block.Signature,
+			&block.Nonce,
+			&block.NonceTitle,
+			&block.Tag,
+			&block.TagTitle,
+			&block.PQSignature,
+			&block.Kind,
+		); err != nil {
+			return err
+		}
+		// The lifecycle blocks sign the ID of their note, it is part of their hash
+		if block.Kind != "" {
+			block.NoteID = noteID
+		}
+		if err := fn(noteID, seq, block); err != nil {
+			return err
+		}
+	}
+
+	return rows.Err()
+}
+
+// appendTransparencyLeaf commits an accepted block to the transparency log inside a transaction.
+// The entry is pending until SequenceLeaves gives it its index, so the write transactions of the blocks never wait
+// on each other for the log.
+// Parameters:
+// - tx: the transaction storing the block
+// - noteID: the ID of the note
+// - seq: the position of the block in the note chain
+// - blockHash: the hash of the block
+// Returns: an error if the insertion fails
+func appendTransparencyLeaf(tx *sql.Tx, noteID string, seq uint, blockHash string) error {
+	const insertQuery = `
+		INSERT INTO transparency_log (note_id, seq, block_hash, leaf_hash)
+		VALUES (?, ?, ?, ?)
+	`
+	leafHash := crypto.TransparencyLeafHash(noteID, seq, blockHash)
+	if _, err := tx.Exec(insertQuery, noteID, seq, blockHash, leafHash); err != nil {
+		return fmt.Errorf("error appending to the transparency log: %v", err)
+	}
+	return nil
+}
+
+// SequenceLeaves gives the next indexes of the log to the pending entries, in the order they were appended.
+// Only this method locks the size row, so the entries get consecutive indexes without gaps while the blocks are
+// written concurrently.
+// Parameters:
+// - limit: the maximum number of entries to sequence
+// Returns: the number of entries sequenced, or an error if the operation fails
+func (r *TransparencyRepository) SequenceLeaves(limit int) (int, error) {
+	tx, err := r.DB.Begin()
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	const lockQuery = `SELECT size FROM transparency_log_size WHERE id = 1 FOR UPDATE`
+	var treeSize int
+	if err := tx.QueryRow(lockQuery).Scan(&treeSize); err != nil {
+		return 0, fmt.Errorf("error locking the transparency log: %v", err)
+	}
+
+	const pendingQuery = `SELECT id FROM transparency_log WHERE leaf_index IS NULL ORDER BY id LIMIT ?`
+	rows, err := tx.Query(pendingQuery, limit)
+	if err != nil {
+		return 0, fmt.Errorf("error listing the pending entries: %v", err)
+	}
+	var ids []uint64
+	for rows.Next() {
+		var id uint64
+		if err := rows.Scan(&id); err != nil {
+			rows.Close()
+			return 0, err
+		}
+		ids = append(ids, id)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return 0, err
+	}
+	if len(ids) == 0 {
+		return 0, nil
+	}
+
+	const indexQuery = `UPDATE transparency_log SET leaf_index = ? WHERE id = ?`
+	for i, id := range ids {
+		if _, err := tx.Exec(indexQuery, treeSize+i, id); err != nil {
+			return 0, fmt.Errorf("error sequencing entry %d: %v", id, err)
+		}
+	}
+
+	const sizeQuery = `UPDATE transparency_log_size SET size = ? WHERE id = 1`
+	if _, err := tx.Exec(sizeQuery, treeSize+len(ids)); err != nil {
+		return 0, fmt.Errorf("error updating the transparency log size: %v", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return 0, err
+	}
+	return len(ids), nil
+}
+
+// GetPendingEntries retrieves the entries of the log that were not sequenced yet, for the auditor.
+// Returns: the pending entries in the order they were appended, their Index is -1, or an error if a query error occurs
+func (r *TransparencyRepository) GetPendingEntries() ([]*models.LogEntry, error) {
+	const query = `
+		SELECT note_id, seq, block_hash, leaf_hash, created_at
+		FROM transparency_log
+		WHERE leaf_index IS NULL
+		ORDER BY id
+	`
+
+	rows, err := r.DB.Query(query)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var entries []*models.LogEntry
+	for rows.Next() {
+		entry := &models.LogEntry{Index: -1}
+		if err := rows.Scan(&entry.NoteID, &entry.Seq, &entry.BlockHash, &entry.LeafHash, &entry.CreatedAt); err != nil {
+			return nil, err
+		}
+		entries = append(entries, entry)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return entries, nil
+}
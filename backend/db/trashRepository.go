@@ -0,0 +1,387 @@
+package db
+
+import (
+	"backend/crypto"
+	"backend/models"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// TrashRepository handles all database operations related to the trash.
+// A trashed note keeps its blocks, metadata and search tokens until it is purged, the signed tombstone block
+// appended to its chain records which version of the note the user deleted.
+// Fields:
+// - DB: a pointer to the SQL database connection
+type TrashRepository struct {
+	DB *sql.DB
+}
+
+// NewTrashRepository creates a new instance of TrashRepository.
+// Parameters:
+// - db: a pointer to the SQL database connection
+// Returns: a pointer to the newly created TrashRepository
+func NewTrashRepository(db *sql.DB) *TrashRepository {
+	return &TrashRepository{
+		DB: db,
+	}
+}
+
+// TrashNote moves a note to the trash by appending its signed tombstone block.
+// Parameters:
+// - userID: the ID of the user
+// - block: a pointer to the verified tombstone block of the note
+// - signer: the keys the signatures of the block were verified with
+// Returns: the seq and the hash of the tombstone block, ErrNoteNotFound if the note does not exist or is already
+// trashed, ErrHeadMismatch if the tombstone was not signed over the current head of the note, or an error if the
+// operation fails
+func (r *TrashRepository) TrashNote(userID uint32, block *models.Block, signer models.BlockSigner) (uint, string, error) {
+	return r.appendLifecycleBlock(userID, block, signer, true)
+}
+
+// SkippedTombstone is a tombstone of the tombstones table that MigrateTombstones could not append to its note
+type SkippedTombstone struct {
+	NoteID string
+	UserID uint32
+	Reason string
+}
+
+// MigrateTombstones appends the tombstones stored before the deletions were blocks of the note chains to the
+// chains of their notes, see migration 018. Every appended tombstone is removed from the tombstones table, the
+// skipped ones are kept and reported so the operator can review them before dropping the table.
+// A tombstone was verified with the key of its user when it was stored, it is checked again with that key.
+// Parameters:
+// - dryRun: only report what would be appended, without writing anything
+// Returns: the number of tombstones appended, the tombstones skipped, or an error if the migration fails
+func (r *TrashRepository) MigrateTombstones(dryRun bool) (int, []SkippedTombstone, error) {
+	const query = `
+		SELECT t.note_id, t.user_id, t.head_hash, t.timestamp, t.signature, u.pub_key,
+			n.deleted, n.head_hash, b.suite_id, b.kind
+		FROM tombstones t
+		INNER JOIN notes n ON n.id = t.note_id
+		INNER JOIN blocks b ON b.note_id = n.id AND b.seq = n.block_count
+		INNER JOIN users u ON u.id = t.user_id
+		ORDER BY t.note_id
+	`
+	rows, err := r.DB.Query(query)
+	if err != nil {
+		return 0, nil, fmt.Errorf("error listing tombstones: %v", err)
+	}
+
+	type storedTombstone struct {
+		userID uint32
+		pubKey string
+		block  models.Block
+	}
+	var tombstones []storedTombstone
+	var skipped []SkippedTombstone
+	for rows.Next() {
+		var tombstone models.Tombstone
+		var stored storedTombstone
+		var deleted bool
+		var headHash, headKind string
+		var suiteID uint16
+		if err := rows.Scan(&tombstone.NoteID, &stored.userID, &tombstone.HeadHash, &tombstone.Timestamp,
+			&tombstone.Signature, &stored.pubKey, &deleted, &headHash, &suiteID, &headKind); err != nil {
+			rows.Close()
+			return 0, nil, err
+		}
+		stored.block = tombstone.Block(models.BlockKindTombstone)
+		stored.block.SuiteID = suiteID
+
+		reason := ""
+		switch {
+		case !deleted:
+			reason = "the note is not inside the trash"
+		case headHash != tombstone.HeadHash:
+			reason = "the tombstone was not signed over the head of the note"
+		case headKind != "":
+			reason = "the note already ends with a " + headKind + " block"
+		default:
+			if valid, err := crypto.VerifyBlockSignature(stored.pubKey, "", &stored.block); err != nil || !valid {
+				reason = "the signature does not verify with the key of the user"
+			}
+		}
+		if reason != "" {
+			skipped = append(skipped, SkippedTombstone{NoteID: tombstone.NoteID, UserID: stored.userID, Reason: reason})
+			continue
+		}
+		tombstones = append(tombstones, stored)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return 0, nil, err
+	}
+
+	if dryRun {
+		return len(tombstones), skipped, nil
+	}
+	for i, stored := range tombstones {
+		if err := r.migrateTombstone(stored.userID, &stored.block, stored.pubKey); err != nil {
+			return i, skipped, err
+		}
+	}
+	return len(tombstones), skipped, nil
+}
+
+// migrateTombstone appends one stored tombstone to the chain of its note and removes it from the tombstones table,
+// keeping the note and its retention period inside the trash.
+// Parameters:
+// - userID: the ID of the owner of the note
+// - block: a pointer to the tombstone block
+// - pubKey: the key of the user the tombstone was verified with
+// Returns: an error if the operation fails
+func (r *TrashRepository) migrateTombstone(userID uint32, block *models.Block, pubKey string) error {
+	blockHash, err := crypto.BlockHash(*block)
+	if err != nil {
+		return err
+	}
+
+	tx, err := r.DB.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	const lockQuery = `SELECT block_count FROM notes WHERE id = ? AND head_hash = ? AND deleted = TRUE FOR UPDATE`
+	var blockCount uint
+	if err = tx.QueryRow(lockQuery, block.NoteID, block.PrevHash).Scan(&blockCount); err != nil {
+		return fmt.Errorf("error locking note %s: %v", block.NoteID, err)
+	}
+
+	seq := blockCount + 1
+	if err = insertBlock(tx, userID, block.NoteID, seq, block, models.BlockSigner{PubKey: pubKey}); err != nil {
+		return fmt.Errorf("error appending the tombstone of note %s: %v", block.NoteID, err)
+	}
+
+	const updateHeadQuery = `UPDATE notes SET head_hash = ?, block_count = ? WHERE id = ?`
+	if _, err = tx.Exec(updateHeadQuery, blockHash, seq, block.NoteID); err != nil {
+		return fmt.Errorf("error moving the head of note %s: %v", block.NoteID, err)
+	}
+
+	const deleteTombstoneQuery = `DELETE FROM tombstones WHERE note_id = ?`
+	if _, err = tx.Exec(deleteTombstoneQuery, block.NoteID); err != nil {
+		return fmt.Errorf("error removing the tombstone of note %s: %v", block.NoteID, err)
+	}
+
+	if err = appendAccountLeaf(tx, userID, block.NoteID, models.LogEventTrash, blockHash); err != nil {
+		return err
+	}
+
+	if err = appendTransparencyLeaf(tx, block.NoteID, seq, blockHash); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// GetTrash retrieves the titles of the trashed notes of a user, most recently deleted first.
+// Parameters:
+// - userID: the ID of the user
+// - retention: how long a note stays in the trash before being purged
+// Returns: a slice of trashed titles, or an error if a query error occurs
+func (r *TrashRepository) GetTrash(userID uint32, retention time.Duration) ([]*models.TrashedTitle, error) {
+	const query = `
+		SELECT n.id, b.cipher_title, b.iv_title, b.nonce_title, b.tag_title, n.head_hash, n.updated_at, n.created_at, COALESCE(m.cipher_meta, ''), COALESCE(m.iv_meta, ''), n.deleted_at
+		FROM notes n
+		INNER JOIN blocks b
+		ON b.note_id = n.id AND b.seq = n.content_seq
+		LEFT JOIN note_metadata m
+		ON m.note_id = n.id
+		WHERE n.user_id = ? AND n.deleted = TRUE
+		ORDER BY n.deleted_at DESC, n.id DESC
+	`
+
+	rows, err := r.DB.Query(query, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var titles []*models.TrashedTitle
+	for rows.Next() {
+		title := &models.TrashedTitle{}
+		if err := rows.Scan(
+			&title.NoteID,
+			&title.CipherTitle,
+			&title.IV,
+			&title.NonceTitle,
+			&title.TagTitle,
+			&title.HeadHash,
+			&title.Timestamp,
+			&title.CreatedAt,
+			&title.CipherMeta,
+			&title.IVMeta,
+			&title.DeletedAt,
+		); err != nil {
+			return nil, err
+		}
+		title.PurgeAt = title.DeletedAt.Add(retention)
+		titles = append(titles, title)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return titles, nil
+}
+
+// RestoreNote moves a note out of the trash by appending its signed restore block.
+// Parameters:
+// - userID: the ID of the user
+// - block: a pointer to the verified restore block of the note
+// - signer: the keys the signatures of the block were verified with
+// Returns: the seq and the hash of the restore block, ErrNoteNotFound if the note is not inside the trash,
+// ErrHeadMismatch if the restore was not signed over the current head of the note, or an error if the operation fails
+func (r *TrashRepository) RestoreNote(userID uint32, block *models.Block, signer models.BlockSigner) (uint, string, error) {
+	return r.appendLifecycleBlock(userID, block, signer, false)
+}
+
+// appendLifecycleBlock appends a tombstone or a restore block to the chain of a note and moves the note in or
+// out of the trash. The block becomes the head of the note, its latest block of content is left unchanged.
+// Parameters:
+// - userID: the ID of the user
+// - block: a pointer to the verified lifecycle block
+// - signer: the keys the signatures of the block were verified with
+// - deleted: true to move the note to the trash, false to move it out of the trash
+// Returns: the seq and the hash of the block, or an error if the operation fails
+func (r *TrashRepository) appendLifecycleBlock(userID uint32, block *models.Block, signer models.BlockSigner, deleted bool) (uint, string, error) {
+	blockHash, err := crypto.BlockHash(*block)
+	if err != nil {
+		return 0, "", err
+	}
+
+	tx, err := r.DB.Begin()
+	if err != nil {
+		return 0, "", err
+	}
+	defer tx.Rollback()
+
+	// Lock the note so no block can be appended while it is moved in or out of the trash
+	const lockQuery = `SELECT head_hash, block_count FROM notes WHERE id = ? AND user_id = ? AND deleted = ? FOR UPDATE`
+	var headHash string
+	var blockCount uint
+	if err = tx.QueryRow(lockQuery, block.NoteID, userID, !deleted).Scan(&headHash, &blockCount); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return 0, "", fmt.Errorf("%w: noteID %s and userID %d", ErrNoteNotFound, block.NoteID, userID)
+		}
+		return 0, "", err
+	}
+
+	if block.PrevHash != headHash {
+		return 0, "", ErrHeadMismatch
+	}
+
+	seq := blockCount + 1
+	if err = insertBlock(tx, userID, block.NoteID, seq, block, signer); err != nil {
+		return 0, "", err
+	}
+
+	// The retention period starts from the server time, a client cannot backdate its deletion
+	var deletedAt sql.NullTime
+	if deleted {
+		deletedAt = sql.NullTime{Time: time.Now().UTC(), Valid: true}
+	}
+	const updateHeadQuery = `UPDATE notes SET head_hash = ?, block_count = ?, deleted = ?, deleted_at = ? WHERE id = ?`
+	if _, err = tx.Exec(updateHeadQuery, blockHash, seq, deleted, deletedAt, block.NoteID); err != nil {
+		return 0, "", fmt.Errorf("error moving the head of note %s: %v", block.NoteID, err)
+	}
+
+	event := models.LogEventRestore
+	if deleted {
+		event = models.LogEventTrash
+	}
+	if err = appendAccountLeaf(tx, userID, block.NoteID, event, blockHash); err != nil {
+		return 0, "", err
+	}
+
+	if err = appendTransparencyLeaf(tx, block.NoteID, seq, blockHash); err != nil {
+		return 0, "", err
+	}
+
+	if err = tx.Commit(); err != nil {
+		return 0, "", err
+	}
+
+	return seq, blockHash, nil
+}
+
+// PurgeExpiredNotes permanently deletes the notes that were moved to the trash before a given time.
+// Their blocks, metadata and search tokens are removed with them through the foreign keys,
+// and a purge leaf is appended to the account log of their owner.
+// Parameters:
+// - before: notes trashed before this time are purged
+// Returns: the number of purged notes, or an error if the deletion fails
+func (r *TrashRepository) PurgeExpiredNotes(before time.Time) (int64, error) {
+	const query = `SELECT id, user_id FROM notes WHERE deleted = TRUE AND deleted_at < ?`
+
+	rows, err := r.DB.Query(query, before)
+	if err != nil {
+		return 0, fmt.Errorf("error listing expired notes: %v", err)
+	}
+
+	type expiredNote struct {
+		noteID string
+		userID uint32
+	}
+	var expired []expiredNote
+	for rows.Next() {
+		var note expiredNote
+		if err := rows.Scan(&note.noteID, &note.userID); err != nil {
+			rows.Close()
+			return 0, err
+		}
+		expired = append(expired, note)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return 0, err
+	}
+
+	var purged int64
+	for _, note := range expired {
+		if err := r.purgeNote(note.userID, note.noteID, before); err != nil {
+			return purged, err
+		}
+		purged++
+	}
+
+	return purged, nil
+}
+
+// purgeNote permanently deletes one expired note and records the purge in the account log.
+// Parameters:
+// - userID: the ID of the owner of the note
+// - noteID: the ID of the note
+// - before: the note is only purged if it is still in the trash since before this time
+// Returns: an error if the deletion fails
+func (r *TrashRepository) purgeNote(userID uint32, noteID string, before time.Time) error {
+	tx, err := r.DB.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	// The note may have been restored since it was listed
+	const lockQuery = `SELECT head_hash FROM notes WHERE id = ? AND deleted = TRUE AND deleted_at < ? FOR UPDATE`
+	var headHash string
+	if err = tx.QueryRow(lockQuery, noteID, before).Scan(&headHash); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil
+		}
+		return err
+	}
+
+	const deleteQuery = `DELETE FROM notes WHERE id = ?`
+	if _, err = tx.Exec(deleteQuery, noteID); err != nil {
+		return fmt.Errorf("error purging note %s: %v", noteID, err)
+	}
+
+	if err = appendAccountLeaf(tx, userID, noteID, models.LogEventPurge, headHash); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
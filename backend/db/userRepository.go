@@ -1,9 +1,23 @@
 package db
 
 import (
+	"backend/crypto"
 	"backend/models"
 	"database/sql"
 	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+)
+
+var (
+	// ErrLoginKeyChanged is returned when the public key of a user changed since a login key change was signed
+	ErrLoginKeyChanged = errors.New("the login key of the user changed")
+	// ErrEmailChangeNotFound is returned when a user has no pending email change matching a token, or it expired
+	ErrEmailChangeNotFound = errors.New("email change not found")
+	// ErrEmailTaken is returned when the new address of an email change belongs to another account
+	ErrEmailTaken = errors.New("email already in use")
 )
 
 // UserRepository handles all database operations related to users.
@@ -28,11 +42,15 @@ func NewUserRepository(db *sql.DB) *UserRepository {
 // - user: a pointer to the User object to be added
 // Returns: the ID of the newly created user, or an error if the insertion fails
 func (r *UserRepository) CreateUser(user *models.User) (uint32, error) {
-	query := `INSERT INTO users (name, email, pub_key, login_salt, encryption_salt, hmac_salt, hmac_type, encryption_type) 
-              VALUES (?, ?, ?, ?, ?, ?, ?, ?)`
+	query := `INSERT INTO users (name, email, pub_key, login_salt, encryption_salt, hmac_salt, hmac_type, encryption_type, suite_id,
+                kdf_algorithm, kdf_iterations, kdf_memory_kib, kdf_parallelism, signature_type, pq_pub_key,
+                recovery_pub_key, recovery_escrow, wrapped_keys) 
+              VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`
 
 	result, err := r.DB.Exec(query, user.Name, user.Email, user.PubKey, user.LoginSalt, user.EncryptionSalt,
-		user.HMACSalt, user.HMACType, user.EncryptionType)
+		user.HMACSalt, user.HMACType, user.EncryptionType, user.SuiteID,
+		user.KDF.Algorithm, user.KDF.Iterations, user.KDF.MemoryKiB, user.KDF.Parallelism, user.SignatureType, user.PQPubKey,
+		user.RecoveryPubKey, user.RecoveryEscrow, user.WrappedKeys)
 	if err != nil {
 		return 0, err
 	}
@@ -50,13 +68,18 @@ func (r *UserRepository) CreateUser(user *models.User) (uint32, error) {
 // - email: the email address of the user to find
 // Returns: a pointer to the retrieved User object, or an error if no user is found or a query error occurs
 func (r *UserRepository) GetUserByEmail(email string) (*models.User, error) {
-	query := `SELECT id, name, email, pub_key, login_salt, encryption_salt, hmac_salt, hmac_type, encryption_type 
+	query := `SELECT id, name, email, pub_key, login_salt, encryption_salt, hmac_salt, hmac_type, encryption_type, suite_id,
+                     kdf_algorithm, kdf_iterations, kdf_memory_kib, kdf_parallelism, signature_type, pq_pub_key,
+                     recovery_pub_key, recovery_escrow, wrapped_keys, email_verified, pending_email
               FROM users WHERE email = ?`
 
 	var user models.User
 	err := r.DB.QueryRow(query, email).Scan(
 		&user.ID, &user.Name, &user.Email, &user.PubKey, &user.LoginSalt,
-		&user.EncryptionSalt, &user.HMACSalt, &user.HMACType, &user.EncryptionType)
+		&user.EncryptionSalt, &user.HMACSalt, &user.HMACType, &user.EncryptionType, &user.SuiteID,
+		&user.KDF.Algorithm, &user.KDF.Iterations, &user.KDF.MemoryKiB, &user.KDF.Parallelism,
+		&user.SignatureType, &user.PQPubKey, &user.RecoveryPubKey, &user.RecoveryEscrow, &user.WrappedKeys,
+		&user.EmailVerified, &user.PendingEmail)
 
 	if err != nil {
 		if errors.Is(err, sql.ErrNoRows) {
@@ -68,18 +91,62 @@ func (r *UserRepository) GetUserByEmail(email string) (*models.User, error) {
 	return &user, nil
 }
 
+// legacyKDFShareTTL is how long the share of the accounts deriving their login key with crypto.LegacyKDF is cached
+const legacyKDFShareTTL = time.Hour
+
+// legacyKDFShare caches the share of the accounts still on crypto.LegacyKDF, read on every challenge of an unknown
+// email but changing slowly
+var legacyKDFShare struct {
+	sync.Mutex
+	share     float64
+	updatedAt time.Time
+}
+
+// GetLegacyKDFShare retrieves the share of the accounts deriving their login key with crypto.LegacyKDF.
+// Returns: the share between 0 and 1, or an error if a query error occurs
+func (r *UserRepository) GetLegacyKDFShare() (float64, error) {
+	legacyKDFShare.Lock()
+	defer legacyKDFShare.Unlock()
+	if time.Since(legacyKDFShare.updatedAt) < legacyKDFShareTTL {
+		return legacyKDFShare.share, nil
+	}
+
+	const query = `
+		SELECT COUNT(*), COALESCE(SUM(kdf_algorithm = ? AND kdf_iterations = ? AND kdf_memory_kib = ? AND kdf_parallelism = ?), 0)
+		FROM users
+	`
+	legacy := crypto.LegacyKDF
+	var total, legacyCount int
+	err := r.DB.QueryRow(query, legacy.Algorithm, legacy.Iterations, legacy.MemoryKiB, legacy.Parallelism).Scan(&total, &legacyCount)
+	if err != nil {
+		return 0, fmt.Errorf("error counting the login key derivations: %v", err)
+	}
+
+	legacyKDFShare.share = 0
+	if total > 0 {
+		legacyKDFShare.share = float64(legacyCount) / float64(total)
+	}
+	legacyKDFShare.updatedAt = time.Now()
+	return legacyKDFShare.share, nil
+}
+
 // GetUserByID finds a user by their ID.
 // Parameters:
 // - id: the ID of the user to find
 // Returns: a pointer to the retrieved User object, or an error if no user is found or a query error occurs
 func (r *UserRepository) GetUserByID(id uint32) (*models.User, error) {
-	query := `SELECT id, name, email, pub_key, login_salt, encryption_salt, hmac_salt, hmac_type, encryption_type, login_salt
+	query := `SELECT id, name, email, pub_key, login_salt, encryption_salt, hmac_salt, hmac_type, encryption_type, login_salt, suite_id,
+                     kdf_algorithm, kdf_iterations, kdf_memory_kib, kdf_parallelism, signature_type, pq_pub_key,
+                     recovery_pub_key, recovery_escrow, wrapped_keys, email_verified, pending_email
               FROM users WHERE id = ?`
 
 	var user models.User
 	err := r.DB.QueryRow(query, id).Scan(
 		&user.ID, &user.Name, &user.Email, &user.PubKey, &user.LoginSalt,
-		&user.EncryptionSalt, &user.HMACSalt, &user.HMACType, &user.EncryptionType, &user.LoginSalt)
+		&user.EncryptionSalt, &user.HMACSalt, &user.HMACType, &user.EncryptionType, &user.LoginSalt, &user.SuiteID,
+		&user.KDF.Algorithm, &user.KDF.Iterations, &user.KDF.MemoryKiB, &user.KDF.Parallelism,
+		&user.SignatureType, &user.PQPubKey, &user.RecoveryPubKey, &user.RecoveryEscrow, &user.WrappedKeys,
+		&user.EmailVerified, &user.PendingEmail)
 
 	if err != nil {
 		if errors.Is(err, sql.ErrNoRows) {
@@ -109,6 +176,73 @@ func (r *UserRepository) UpdateUser(user *models.User) error {
 	return err
 }
 
+// UpdateLoginKey replaces the login salt, the KDF parameters and the public keys of a user.
+// The update only applies while the public key is still the one the change was signed with, so two concurrent
+// upgrades cannot both succeed.
+// Parameters:
+// - userID: the ID of the user
+// - oldPubKey: the public key the change was signed with
+// - pubKey: the new public key
+// - pqPubKey: the new ML-DSA public key, empty for the Ed25519 accounts
+// - loginSalt: the new login salt
+// - kdf: the new KDF parameters
+// Returns: ErrLoginKeyChanged if the public key changed in the meantime, or an error if the update fails
+func (r *UserRepository) UpdateLoginKey(userID uint32, oldPubKey, pubKey, pqPubKey, loginSalt string, kdf models.KDFParams) error {
+	query := `
+		UPDATE users
+		SET pub_key = ?, pq_pub_key = ?, login_salt = ?, kdf_algorithm = ?, kdf_iterations = ?, kdf_memory_kib = ?, kdf_parallelism = ?
+		WHERE id = ? AND pub_key = ?
+	`
+	result, err := r.DB.Exec(query, pubKey, pqPubKey, loginSalt, kdf.Algorithm, kdf.Iterations, kdf.MemoryKiB, kdf.Parallelism,
+		userID, oldPubKey)
+	if err != nil {
+		return err
+	}
+
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if affected == 0 {
+		return ErrLoginKeyChanged
+	}
+	return nil
+}
+
+// RecoverLoginKey replaces the login key of a user who forgot their password, with the keys sealed under the new
+// password. Like UpdateLoginKey, it only applies while the public key is still the one the recovery was signed for.
+// Parameters:
+// - userID: the ID of the user
+// - oldPubKey: the public key the recovery was signed for
+// - pubKey: the new public key
+// - pqPubKey: the new ML-DSA public key, empty for the Ed25519 accounts
+// - loginSalt: the new login salt
+// - kdf: the KDF parameters of the new login key
+// - wrappedKeys: the encryption and HMAC keys sealed under the new password
+// Returns: ErrLoginKeyChanged if the public key changed in the meantime, or an error if the update fails
+func (r *UserRepository) RecoverLoginKey(userID uint32, oldPubKey, pubKey, pqPubKey, loginSalt string, kdf models.KDFParams, wrappedKeys string) error {
+	query := `
+		UPDATE users
+		SET pub_key = ?, pq_pub_key = ?, login_salt = ?, kdf_algorithm = ?, kdf_iterations = ?, kdf_memory_kib = ?, kdf_parallelism = ?,
+			wrapped_keys = ?
+		WHERE id = ? AND pub_key = ?
+	`
+	result, err := r.DB.Exec(query, pubKey, pqPubKey, loginSalt, kdf.Algorithm, kdf.Iterations, kdf.MemoryKiB, kdf.Parallelism,
+		wrappedKeys, userID, oldPubKey)
+	if err != nil {
+		return err
+	}
+
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if affected == 0 {
+		return ErrLoginKeyChanged
+	}
+	return nil
+}
+
 // DeleteUserByID deletes a user from the database by their ID.
 // Parameters:
 // - id: the ID of the user to delete
@@ -123,3 +257,131 @@ func (r *UserRepository) DeleteUserByID(id uint32) error {
 
 	return nil
 }
+
+// IsEmailVerified tells whether a user verified their email address.
+// Parameters:
+// - userID: the ID of the user
+// Returns: whether the address is verified, or an error if no user is found or a query error occurs
+func (r *UserRepository) IsEmailVerified(userID uint32) (bool, error) {
+	var verified bool
+	err := r.DB.QueryRow(`SELECT email_verified FROM users WHERE id = ?`, userID).Scan(&verified)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return false, errors.New("user not found")
+		}
+		return false, err
+	}
+	return verified, nil
+}
+
+// MarkEmailVerified marks the address of a user as verified, as long as it is still the one the token was sent to.
+// Parameters:
+// - userID: the ID of the user
+// - email: the address the verification was sent to
+// Returns: an error if the update fails
+func (r *UserRepository) MarkEmailVerified(userID uint32, email string) error {
+	_, err := r.DB.Exec(`UPDATE users SET email_verified = TRUE WHERE id = ? AND email = ?`, userID, email)
+	return err
+}
+
+// StartEmailChange stores an email change waiting for the confirmation of both addresses, replacing the pending one.
+// A current address that was never verified cannot be trusted to receive the confirmation, so only the new address
+// confirms the change then.
+// Parameters:
+// - userID: the ID of the user
+// - email: the new address
+// - nonce: the random identifier of the change, carried by the tokens of the confirmation emails
+// - expiresAt: when the change expires
+// Returns: an error if the update fails
+func (r *UserRepository) StartEmailChange(userID uint32, email, nonce string, expiresAt time.Time) error {
+	query := `
+		UPDATE users
+		SET pending_email = ?, email_change_nonce = ?, email_change_old_confirmed = NOT email_verified,
+			email_change_new_confirmed = FALSE, email_change_expires_at = ?
+		WHERE id = ?
+	`
+	_, err := r.DB.Exec(query, email, nonce, expiresAt, userID)
+	return err
+}
+
+// ConfirmEmailChange records the confirmation of a pending email change by one of its addresses, in a single
+// transaction. Once both addresses confirmed, the new address replaces the old one and is verified.
+// Parameters:
+// - userID: the ID of the user
+// - nonce: the identifier of the change carried by the token
+// - fromNewAddress: whether the confirmation comes from the new address or the current one
+// Returns: whether the email was changed, ErrEmailChangeNotFound if the change does not exist or expired,
+// ErrEmailTaken if another account took the new address in the meantime, or an error if a query fails
+func (r *UserRepository) ConfirmEmailChange(userID uint32, nonce string, fromNewAddress bool) (bool, error) {
+	tx, err := r.DB.Begin()
+	if err != nil {
+		return false, err
+	}
+	defer tx.Rollback()
+
+	selectQuery := `
+		SELECT pending_email, email_change_nonce, email_change_old_confirmed, email_change_new_confirmed,
+			email_change_expires_at
+		FROM users WHERE id = ? FOR UPDATE
+	`
+	var pendingEmail, pendingNonce string
+	var oldConfirmed, newConfirmed bool
+	var expiresAt sql.NullTime
+	err = tx.QueryRow(selectQuery, userID).Scan(&pendingEmail, &pendingNonce, &oldConfirmed, &newConfirmed, &expiresAt)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return false, fmt.Errorf("%w: userID %d", ErrEmailChangeNotFound, userID)
+		}
+		return false, err
+	}
+	if pendingEmail == "" || pendingNonce != nonce || !expiresAt.Valid || time.Now().UTC().After(expiresAt.Time) {
+		return false, fmt.Errorf("%w: userID %d", ErrEmailChangeNotFound, userID)
+	}
+
+	if fromNewAddress {
+		newConfirmed = true
+	} else {
+		oldConfirmed = true
+	}
+	changed := oldConfirmed && newConfirmed
+
+	if changed {
+		changeQuery := `
+			UPDATE users
+			SET email = pending_email, email_verified = TRUE, pending_email = '', email_change_nonce = '',
+				email_change_old_confirmed = FALSE, email_change_new_confirmed = FALSE, email_change_expires_at = NULL
+			WHERE id = ?
+		`
+		if _, err := tx.Exec(changeQuery, userID); err != nil {
+			if strings.Contains(err.Error(), "Duplicate entry") {
+				return false, ErrEmailTaken
+			}
+			return false, fmt.Errorf("error changing the email: %v", err)
+		}
+	} else {
+		confirmQuery := `UPDATE users SET email_change_old_confirmed = ?, email_change_new_confirmed = ? WHERE id = ?`
+		if _, err := tx.Exec(confirmQuery, oldConfirmed, newConfirmed, userID); err != nil {
+			return false, fmt.Errorf("error confirming the email change: %v", err)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return false, err
+	}
+	return changed, nil
+}
+
+// CancelEmailChange drops the pending email change of a user, the links already sent stop working.
+// Parameters:
+// - userID: the ID of the user
+// Returns: an error if the update fails
+func (r *UserRepository) CancelEmailChange(userID uint32) error {
+	query := `
+		UPDATE users
+		SET pending_email = '', email_change_nonce = '', email_change_old_confirmed = FALSE,
+			email_change_new_confirmed = FALSE, email_change_expires_at = NULL
+		WHERE id = ?
+	`
+	_, err := r.DB.Exec(query, userID)
+	return err
+}
@@ -0,0 +1,119 @@
+package db
+
+import (
+	"backend/models"
+	"database/sql"
+	"fmt"
+)
+
+// VaultRepository reads everything the server keeps about the notes of a user, to export it as a vault archive.
+// Fields:
+// - DB: a pointer to the SQL database connection
+type VaultRepository struct {
+	DB *sql.DB
+}
+
+// NewVaultRepository creates a new instance of VaultRepository.
+// Parameters:
+// - db: a pointer to the SQL database connection
+// Returns: a pointer to the newly created VaultRepository
+func NewVaultRepository(db *sql.DB) *VaultRepository {
+	return &VaultRepository{
+		DB: db,
+	}
+}
+
+// GetVaultNotes retrieves every note of a user, including the ones inside the trash, without their blocks.
+// Parameters:
+// - userID: the ID of the user
+// Returns: the notes with their encrypted metadata, oldest first, or an error if the query fails
+func (r *VaultRepository) GetVaultNotes(userID uint32) ([]*models.VaultNote, error) {
+	const query = `
+		SELECT n.id, n.created_at, n.updated_at, n.head_hash, n.deleted, n.deleted_at,
+		       m.cipher_meta, m.iv_meta, m.folder_token
+		FROM notes n
+		LEFT JOIN note_metadata m ON m.note_id = n.id
+		WHERE n.user_id = ?
+		ORDER BY n.created_at ASC, n.id ASC
+	`
+
+	rows, err := r.DB.Query(query, userID)
+	if err != nil {
+		return nil, fmt.Errorf("error querying notes: %v", err)
+	}
+	defer rows.Close()
+
+	var notes []*models.VaultNote
+	for rows.Next() {
+		note := &models.VaultNote{}
+		var deletedAt sql.NullTime
+		var cipherMeta, ivMeta, folderToken sql.NullString
+		if err := rows.Scan(
+			&note.NoteID,
+			&note.CreatedAt,
+			&note.UpdatedAt,
+			&note.HeadHash,
+			&note.Deleted,
+			&deletedAt,
+			&cipherMeta,
+			&ivMeta,
+			&folderToken,
+		); err != nil {
+			return nil, fmt.Errorf("error scanning note: %v", err)
+		}
+
+		if deletedAt.Valid {
+			note.DeletedAt = &deletedAt.Time
+		}
+		if cipherMeta.Valid {
+			note.Metadata = &models.NoteMetadata{
+				NoteID:      note.NoteID,
+				CipherMeta:  cipherMeta.String,
+				IVMeta:      ivMeta.String,
+				FolderToken: folderToken.String,
+			}
+		}
+		notes = append(notes, note)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating notes: %v", err)
+	}
+
+	return notes, nil
+}
+
+// GetNoteTokens retrieves the blind index tokens of a note.
+// Parameters:
+// - userID: the ID of the user
+// - noteID: the ID of the note
+// Returns: the tag tokens and the keyword tokens of the head block, or an error if a query fails
+func (r *VaultRepository) GetNoteTokens(userID uint32, noteID string) ([]string, []string, error) {
+	tagTokens, err := r.queryTokens(`SELECT tag_token FROM note_tags WHERE note_id = ? AND user_id = ? ORDER BY tag_token`, noteID, userID)
+	if err != nil {
+		return nil, nil, fmt.Errorf("error querying tag tokens: %v", err)
+	}
+	searchTokens, err := r.queryTokens(`SELECT token FROM search_index WHERE note_id = ? AND user_id = ? ORDER BY token`, noteID, userID)
+	if err != nil {
+		return nil, nil, fmt.Errorf("error querying search tokens: %v", err)
+	}
+	return tagTokens, searchTokens, nil
+}
+
+// queryTokens runs a query returning a single string column
+func (r *VaultRepository) queryTokens(query string, args ...any) ([]string, error) {
+	rows, err := r.DB.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var tokens []string
+	for rows.Next() {
+		var token string
+		if err := rows.Scan(&token); err != nil {
+			return nil, err
+		}
+		tokens = append(tokens, token)
+	}
+	return tokens, rows.Err()
+}
@@ -0,0 +1,39 @@
+package mail
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// LogMailer stands in for a real mailer: it writes the messages to the log and, when Dir is set, each one to a
+// file of Dir, where tests and developers can read the links they carry.
+// Fields:
+// - From: the sender address
+// - Dir: the directory the messages are written to, empty to only log them
+type LogMailer struct {
+	From string
+	Dir  string
+}
+
+// Send logs a message and writes it to a file of Dir
+func (m *LogMailer) Send(message *Message) error {
+	log.Printf("Mail to %s: %s\n%s", message.To, message.Subject, message.Body)
+	if m.Dir == "" {
+		return nil
+	}
+
+	if err := os.MkdirAll(m.Dir, 0o700); err != nil {
+		return err
+	}
+	suffix := make([]byte, 4)
+	if _, err := rand.Read(suffix); err != nil {
+		return err
+	}
+	name := fmt.Sprintf("%s-%s.eml", time.Now().UTC().Format("20060102T150405.000000000"), hex.EncodeToString(suffix))
+	return os.WriteFile(filepath.Join(m.Dir, name), format(m.From, message), 0o600)
+}
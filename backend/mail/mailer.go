@@ -0,0 +1,94 @@
+// Package mail sends the emails of the server: the verification of the addresses and the confirmations of their
+// changes.
+//
+// The mailer is pluggable: SMTPMailer delivers the messages through an SMTP server, LogMailer writes them to the
+// log and optionally to a directory, for development and tests.
+package mail
+
+import (
+	"errors"
+	"fmt"
+)
+
+// Kinds of mailer, chosen with the MAILER setting
+const (
+	KindSMTP = "smtp" // Deliver through an SMTP server
+	KindFile = "file" // Write every message to a file of a directory, and to the log
+	KindLog  = "log"  // Write every message to the log
+)
+
+// ErrNoMailer is returned when no mailer is configured
+var ErrNoMailer = errors.New("no mailer is configured")
+
+// Message is a plain text email
+type Message struct {
+	To      string
+	Subject string
+	Body    string
+}
+
+// Mailer sends emails
+type Mailer interface {
+	// Send delivers a message, or fails without retrying
+	Send(message *Message) error
+}
+
+var mailer Mailer
+
+// SetMailer sets the mailer used by the server.
+// Parameters:
+// - m: the mailer, nil disables the emails
+func SetMailer(m Mailer) {
+	mailer = m
+}
+
+// GetMailer returns the mailer used by the server.
+// Returns: the configured mailer, or ErrNoMailer if there is none
+func GetMailer() (Mailer, error) {
+	if mailer == nil {
+		return nil, ErrNoMailer
+	}
+	return mailer, nil
+}
+
+// Send delivers a message with the mailer of the server.
+// Parameters:
+// - message: a pointer to the message
+// Returns: ErrNoMailer if there is no mailer, or the error of the mailer
+func Send(message *Message) error {
+	m, err := GetMailer()
+	if err != nil {
+		return err
+	}
+	return m.Send(message)
+}
+
+// Config holds the settings of the mailers
+type Config struct {
+	Kind         string // KindSMTP, KindFile or KindLog
+	From         string // Sender address of the messages
+	Dir          string // Directory of KindFile
+	SMTPHost     string
+	SMTPPort     int
+	SMTPUsername string // Empty to send without authentication
+	SMTPPassword string
+}
+
+// NewMailer builds the mailer described by the configuration.
+// Parameters:
+// - cfg: the settings of the mailer
+// Returns: the mailer, or an error if the configuration is invalid
+func NewMailer(cfg Config) (Mailer, error) {
+	switch cfg.Kind {
+	case KindSMTP:
+		return NewSMTPMailer(cfg.SMTPHost, cfg.SMTPPort, cfg.SMTPUsername, cfg.SMTPPassword, cfg.From)
+	case KindFile:
+		if cfg.Dir == "" {
+			return nil, errors.New("the file mailer needs a directory")
+		}
+		return &LogMailer{From: cfg.From, Dir: cfg.Dir}, nil
+	case KindLog, "":
+		return &LogMailer{From: cfg.From}, nil
+	}
+	return nil, fmt.Errorf("unknown mailer %q, must be %s, %s or %s", cfg.Kind, KindSMTP, KindFile, KindLog)
+}
@@ -0,0 +1,68 @@
+package mail
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"net/smtp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// SMTPMailer delivers the messages through an SMTP server, upgrading the connection with STARTTLS when the
+// server offers it. Credentials are only sent over TLS, net/smtp refuses PLAIN auth in clear text except to
+// localhost.
+// Fields:
+// - Addr: the host and port of the server
+// - Auth: the credentials, nil to send without authentication
+// - From: the sender address
+type SMTPMailer struct {
+	Addr string
+	Auth smtp.Auth
+	From string
+}
+
+// NewSMTPMailer creates a mailer for an SMTP server.
+// Parameters:
+// - host: the host name of the server
+// - port: the port of the server, usually 587
+// - username: the user name, empty to send without authentication
+// - password: the password of the user
+// - from: the sender address
+// Returns: a pointer to the mailer, or an error if the host or the sender is missing
+func NewSMTPMailer(host string, port int, username, password, from string) (*SMTPMailer, error) {
+	if host == "" || from == "" {
+		return nil, errors.New("the SMTP mailer needs a host and a sender address")
+	}
+	mailer := &SMTPMailer{
+		Addr: net.JoinHostPort(host, strconv.Itoa(port)),
+		From: from,
+	}
+	if username != "" {
+		mailer.Auth = smtp.PlainAuth("", username, password, host)
+	}
+	return mailer, nil
+}
+
+// Send delivers a message through the SMTP server
+func (m *SMTPMailer) Send(message *Message) error {
+	if strings.ContainsAny(message.To, "\r\n") || strings.ContainsAny(message.Subject, "\r\n") {
+		return errors.New("the recipient and the subject must be a single line")
+	}
+	return smtp.SendMail(m.Addr, m.Auth, m.From, []string{message.To}, format(m.From, message))
+}
+
+// format builds the RFC 5322 text of a message
+func format(from string, message *Message) []byte {
+	var b strings.Builder
+	fmt.Fprintf(&b, "From: %s\r\n", from)
+	fmt.Fprintf(&b, "To: %s\r\n", message.To)
+	fmt.Fprintf(&b, "Subject: %s\r\n", message.Subject)
+	fmt.Fprintf(&b, "Date: %s\r\n", time.Now().UTC().Format(time.RFC1123Z))
+	b.WriteString("MIME-Version: 1.0\r\n")
+	b.WriteString("Content-Type: text/plain; charset=UTF-8\r\n")
+	b.WriteString("\r\n")
+	b.WriteString(strings.ReplaceAll(message.Body, "\n", "\r\n"))
+	return []byte(b.String())
+}
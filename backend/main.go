@@ -4,26 +4,76 @@ import (
 	"backend/auth"
 	"backend/config"
 	"backend/cron"
+	"backend/crypto"
 	"backend/db"
+	"backend/mail"
 	"backend/middleware"
+	"backend/models"
+	"backend/notify"
 	routes "backend/routes"
+	"backend/tsa"
 	"log"
 	"net/http"
 	"strconv"
+	"time"
 )
 
 func main() {
 	// Load configuration
 	cfg := config.LoadConfig()
 	dbCfg := config.LoadDbConfig()
+	if err := cfg.Validate(); err != nil {
+		log.Fatalf("Invalid configuration: %v", err)
+	}
 
 	// Set JWT configuration
 	auth.SetJWTConfig(cfg.JWTSecret, cfg.JWTExpiration)
 
+	// Set the key the server signs its receipts with
+	if err := crypto.SetServerSigningKey(cfg.ServerSigningKey); err != nil {
+		log.Fatalf("Invalid server signing key: %v", err)
+	}
+
+	// Set the time-stamp authority new blocks are timestamped with
+	authority, err := tsa.NewAuthority(cfg.TSAURL, cfg.TSACAFile, cfg.TSAKey, cfg.TSAPolicyOID)
+	if err != nil {
+		log.Fatalf("Invalid time-stamp authority configuration: %v", err)
+	}
+	tsa.SetAuthority(authority)
+
+	// Set the mailer of the verification and email change emails
+	mailer, err := mail.NewMailer(mail.Config{
+		Kind:         cfg.Mailer,
+		From:         cfg.MailFrom,
+		Dir:          cfg.MailDir,
+		SMTPHost:     cfg.SMTPHost,
+		SMTPPort:     cfg.SMTPPort,
+		SMTPUsername: cfg.SMTPUsername,
+		SMTPPassword: cfg.SMTPPassword,
+	})
+	if err != nil {
+		log.Fatalf("Invalid mailer configuration: %v", err)
+	}
+	mail.SetMailer(mailer)
+
 	// Initialize database connection
 	db.InitDB(dbCfg)
 	defer db.CloseDB()
 
+	// Start the bus of the security notifications
+	notificationBus := notify.NewBus(db.GetDB(), map[string]notify.Notifier{
+		models.ChannelEmail:   notify.NewEmailNotifier(),
+		models.ChannelWebhook: notify.NewWebhookNotifier(cfg.WebhookAllowPrivate),
+	}, notify.Config{
+		MaxAttempts:      cfg.NotifyMaxAttempts,
+		RetryDelay:       time.Duration(cfg.NotifyBackoffSeconds) * time.Second,
+		FailureThreshold: cfg.FailedSignatureBurst,
+		FailureWindow:    time.Duration(cfg.FailedSignatureMinutes) * time.Minute,
+	})
+	notificationBus.Start()
+	defer notificationBus.Stop()
+	notify.SetBus(notificationBus)
+
 	// Start cron scheduler for cleanup tasks
 	cronScheduler := cron.NewCronScheduler()
 	cronScheduler.Start()
@@ -46,7 +96,7 @@ func main() {
 	serverAddr := ":" + strconv.Itoa(cfg.Port)
 	log.Printf("CantTouchMe server starting on port %d in %s mode!",
 		cfg.Port, cfg.Environment)
-	err := http.ListenAndServe(serverAddr, handler)
+	err = http.ListenAndServe(serverAddr, handler)
 	if err != nil {
 		log.Fatalf("Server failed to start: %v", err)
 	}
@@ -34,7 +34,7 @@ func CorsMiddleware(next http.Handler) http.Handler {
 		// Set headers for CORS preflight requests
 		w.Header().Set("Access-Control-Allow-Methods", "GET, POST, PUT, DELETE, OPTIONS")
 		// Set allowed headers for CORS requests
-		w.Header().Set("Access-Control-Allow-Headers", "Accept, Content-Type, Content-Length, Accept-Encoding, X-CSRF-Token, Authorization")
+		w.Header().Set("Access-Control-Allow-Headers", "Accept, Content-Type, Content-Length, Accept-Encoding, X-CSRF-Token, Authorization, X-Import-Proof")
 
 		if r.Method == "OPTIONS" {
 			// Handle preflight requests by returning a 200 OK status
@@ -109,7 +109,7 @@ func LogMiddleware() func(http.Handler) http.Handler {
 
 			// Log errors with additional detail
 			if wrapped.statusCode >= 400 {
-				log.Printf("%s[ERROR]%s %s%s %s%s - %sStatus: %s%d%s - %sUA: %s%s",
+				log.Printf("%s[ERROR]%s %s%s%s %s%s%s - Status: %s%d%s - %sUA: %s%s",
 					ColorRed+ColorBold, ColorReset, // [ERROR]
 					methodColor+ColorBold, r.Method, ColorReset, // METHOD
 					ColorCyan, r.URL.Path, ColorReset, // /path
@@ -0,0 +1,60 @@
+package middleware
+
+import (
+	"backend/config"
+	"backend/db"
+	"encoding/json"
+	"log"
+	"net/http"
+)
+
+// RequireVerifiedEmail refuses the requests that change the notes of a user who did not verify their email, unless
+// the UNVERIFIED_ACCESS setting is "full". It runs after AuthMiddleware, which sets the user ID in the context.
+func RequireVerifiedEmail(next http.HandlerFunc) http.HandlerFunc {
+	return requireVerifiedEmail(next, config.UnverifiedAccessReadOnly, config.UnverifiedAccessNone)
+}
+
+// RequireVerifiedEmailToRead refuses the requests that read the notes of a user who did not verify their email when
+// the UNVERIFIED_ACCESS setting is "none". It runs after AuthMiddleware, which sets the user ID in the context.
+func RequireVerifiedEmailToRead(next http.HandlerFunc) http.HandlerFunc {
+	return requireVerifiedEmail(next, config.UnverifiedAccessNone)
+}
+
+// requireVerifiedEmail refuses the requests of the unverified users when UNVERIFIED_ACCESS is one of the policies
+func requireVerifiedEmail(next http.HandlerFunc, policies ...string) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		access := config.GetConfig().UnverifiedAccess
+		restricted := false
+		for _, policy := range policies {
+			restricted = restricted || access == policy
+		}
+		if !restricted {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		userID, ok := r.Context().Value("UserID").(uint32)
+		if !ok {
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusUnauthorized)
+			json.NewEncoder(w).Encode(map[string]string{"error": "Authentication required"})
+			return
+		}
+
+		verified, err := db.NewUserRepository(db.GetDB()).IsEmailVerified(userID)
+		if err != nil {
+			log.Printf("Error checking the email verification of user %d: %v", userID, err)
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusInternalServerError)
+			json.NewEncoder(w).Encode(map[string]string{"error": "Error checking the email verification"})
+			return
+		}
+		if !verified {
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusForbidden)
+			json.NewEncoder(w).Encode(map[string]string{"error": "Verify your email address first"})
+			return
+		}
+		next.ServeHTTP(w, r)
+	}
+}
@@ -0,0 +1,137 @@
+package migrate
+
+import (
+	"archive/tar"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// joplinTypeNote is the type_ of a note item, the other items are notebooks, tags, resources...
+const joplinTypeNote = "1"
+
+// ReadJoplin reads a Joplin RAW export directory or a JEX archive (a tar of the same files).
+// Every item is a Markdown file whose first line is the title, followed by the body and a block of
+// "key: value" properties; only the notes are read, encrypted items are rejected.
+// Parameters:
+// - path: the RAW export directory or the .jex file
+// Returns: the notes, or an error if the export cannot be read
+func ReadJoplin(path string) ([]Note, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var notes []Note
+	add := func(name, content string) error {
+		note, ok, err := joplinNote(name, content)
+		if err != nil {
+			return err
+		}
+		if ok {
+			notes = append(notes, note)
+		}
+		return nil
+	}
+
+	if info.IsDir() {
+		entries, err := os.ReadDir(path)
+		if err != nil {
+			return nil, err
+		}
+		for _, entry := range entries {
+			if entry.IsDir() || filepath.Ext(entry.Name()) != ".md" {
+				continue
+			}
+			data, err := os.ReadFile(filepath.Join(path, entry.Name()))
+			if err != nil {
+				return nil, err
+			}
+			if err := add(entry.Name(), string(data)); err != nil {
+				return nil, err
+			}
+		}
+		return notes, nil
+	}
+
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	tr := tar.NewReader(file)
+	for {
+		header, err := tr.Next()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("malformed JEX archive: %v", err)
+		}
+		// Resources are stored in a sub directory, the items at the root
+		if header.Typeflag != tar.TypeReg || strings.Contains(header.Name, "/") || filepath.Ext(header.Name) != ".md" {
+			continue
+		}
+		data, err := io.ReadAll(tr)
+		if err != nil {
+			return nil, err
+		}
+		if err := add(header.Name, string(data)); err != nil {
+			return nil, err
+		}
+	}
+	return notes, nil
+}
+
+// joplinNote parses a Joplin item file.
+// Parameters:
+// - name: the name of the file, to report errors
+// - content: the content of the file
+// Returns: the note, false if the item is not a note, or an error if the note is encrypted
+func joplinNote(name, content string) (Note, bool, error) {
+	lines := strings.Split(strings.ReplaceAll(content, "\r\n", "\n"), "\n")
+
+	// The properties are the last lines of the file, after the last empty line
+	end := len(lines)
+	for end > 0 && lines[end-1] == "" {
+		end--
+	}
+	start := end
+	properties := make(map[string]string)
+	for start > 0 && lines[start-1] != "" {
+		key, value, ok := strings.Cut(lines[start-1], ": ")
+		if !ok {
+			key, ok = strings.CutSuffix(lines[start-1], ":")
+		}
+		if !ok || strings.ContainsAny(key, " \t") {
+			break
+		}
+		properties[key] = value
+		start--
+	}
+
+	if properties["type_"] != joplinTypeNote {
+		return Note{}, false, nil
+	}
+	if properties["encryption_applied"] == "1" {
+		return Note{}, false, fmt.Errorf("%s is encrypted, export the notes from Joplin with encryption disabled", name)
+	}
+
+	// The title is the first line, separated from the body by an empty line
+	title := ""
+	bodyLines := lines[:start]
+	if len(bodyLines) > 0 {
+		title = bodyLines[0]
+		bodyLines = bodyLines[1:]
+	}
+	body := strings.Trim(strings.Join(bodyLines, "\n"), "\n")
+	if title == "" {
+		title = titleFromPath(name)
+	}
+
+	return Note{Title: title, Body: body, Source: name}, true, nil
+}
@@ -0,0 +1,108 @@
+// Package migrate reads the exports of other note taking tools so they can be uploaded to CantTouchMe.
+//
+// The readers only parse plaintext exports; the notes are then encrypted and signed locally by the
+// client package before being uploaded, so the server never sees their content.
+package migrate
+
+import (
+	"backend/client"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// Supported export formats
+const (
+	FormatMarkdown      = "markdown"      // A directory of Markdown or text files, or a single file
+	FormatJoplin        = "joplin"        // A Joplin RAW export directory or a JEX archive
+	FormatStandardNotes = "standardnotes" // A decrypted Standard Notes backup file, or the zip holding it
+)
+
+// Note is a plaintext note read from an export
+type Note struct {
+	Title  string
+	Body   string
+	Source string // Where the note was read from, to report errors
+}
+
+// Read reads every note of an export.
+// Parameters:
+// - format: one of the Format constants
+// - path: the file or directory of the export
+// Returns: the notes, or an error if the export cannot be read
+func Read(format, path string) ([]Note, error) {
+	switch format {
+	case FormatMarkdown:
+		return ReadMarkdown(path)
+	case FormatJoplin:
+		return ReadJoplin(path)
+	case FormatStandardNotes:
+		return ReadStandardNotes(path)
+	default:
+		return nil, fmt.Errorf("unsupported format %s, expected %s, %s or %s", format, FormatMarkdown, FormatJoplin, FormatStandardNotes)
+	}
+}
+
+// Upload encrypts, signs and uploads notes as new notes, one at a time.
+// Parameters:
+// - c: a logged in client
+// - notes: the notes to upload
+// - progress: called after each note with the ID of the new note, or the error that prevented its upload
+// Returns: the number of notes uploaded
+func Upload(c *client.Client, notes []Note, progress func(note Note, noteID string, err error)) int {
+	uploaded := 0
+	for _, note := range notes {
+		noteID, _, err := c.CreateNote(note.Title, note.Body)
+		if err == nil {
+			uploaded++
+		}
+		progress(note, noteID, err)
+	}
+	return uploaded
+}
+
+// titleFromPath names a note without a title after its file
+func titleFromPath(path string) string {
+	return strings.TrimSuffix(filepath.Base(path), filepath.Ext(path))
+}
+
+// ReadMarkdown reads a directory of Markdown or text files, or a single file.
+// The title of a note is its first line when it is a level 1 heading, the name of its file otherwise.
+// Parameters:
+// - path: the file or directory
+// Returns: one note per file, or an error if a file cannot be read
+func ReadMarkdown(path string) ([]Note, error) {
+	var notes []Note
+	err := filepath.WalkDir(path, func(file string, entry os.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if entry.IsDir() {
+			return nil
+		}
+		switch strings.ToLower(filepath.Ext(file)) {
+		case ".md", ".markdown", ".txt":
+		default:
+			return nil
+		}
+
+		data, err := os.ReadFile(file)
+		if err != nil {
+			return err
+		}
+		notes = append(notes, markdownNote(file, string(data)))
+		return nil
+	})
+	return notes, err
+}
+
+// markdownNote splits the title heading from the body of a Markdown file
+func markdownNote(path, content string) Note {
+	content = strings.ReplaceAll(content, "\r\n", "\n")
+	firstLine, rest, _ := strings.Cut(content, "\n")
+	if title, ok := strings.CutPrefix(firstLine, "# "); ok && strings.TrimSpace(title) != "" {
+		return Note{Title: strings.TrimSpace(title), Body: strings.TrimLeft(rest, "\n"), Source: path}
+	}
+	return Note{Title: titleFromPath(path), Body: content, Source: path}
+}
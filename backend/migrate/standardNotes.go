@@ -0,0 +1,106 @@
+package migrate
+
+import (
+	"archive/zip"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// standardNotesBackup is a decrypted Standard Notes backup file
+type standardNotesBackup struct {
+	Items []struct {
+		UUID        string          `json:"uuid"`
+		ContentType string          `json:"content_type"`
+		Content     json.RawMessage `json:"content"`
+		Deleted     bool            `json:"deleted"`
+	} `json:"items"`
+}
+
+// standardNotesNote is the content of a Note item
+type standardNotesNote struct {
+	Title   string `json:"title"`
+	Text    string `json:"text"`
+	Trashed bool   `json:"trashed"`
+}
+
+// ReadStandardNotes reads a decrypted Standard Notes backup, or the zip export holding it.
+// Encrypted backups cannot be read: their items are encrypted with keys only Standard Notes can derive.
+// Parameters:
+// - path: the backup file (.txt or .json) or the zip export
+// Returns: the notes that are neither deleted nor trashed, or an error if the backup cannot be read
+func ReadStandardNotes(path string) ([]Note, error) {
+	var data []byte
+	var err error
+	if strings.ToLower(filepath.Ext(path)) == ".zip" {
+		data, err = readStandardNotesZip(path)
+	} else {
+		data, err = os.ReadFile(path)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var backup standardNotesBackup
+	if err := json.Unmarshal(data, &backup); err != nil {
+		return nil, fmt.Errorf("malformed Standard Notes backup: %v", err)
+	}
+
+	var notes []Note
+	for _, item := range backup.Items {
+		if item.ContentType != "Note" || item.Deleted {
+			continue
+		}
+		// An encrypted item has a string content instead of an object
+		if len(item.Content) > 0 && item.Content[0] == '"' {
+			return nil, errors.New("the backup is encrypted, export a decrypted backup from Standard Notes")
+		}
+
+		var content standardNotesNote
+		if err := json.Unmarshal(item.Content, &content); err != nil {
+			return nil, fmt.Errorf("malformed note %s: %v", item.UUID, err)
+		}
+		if content.Trashed {
+			continue
+		}
+		title := content.Title
+		if title == "" {
+			title = "Untitled"
+		}
+		notes = append(notes, Note{Title: title, Body: content.Text, Source: item.UUID})
+	}
+	return notes, nil
+}
+
+// readStandardNotesZip extracts the backup file from a Standard Notes zip export
+func readStandardNotesZip(path string) ([]byte, error) {
+	archive, err := zip.OpenReader(path)
+	if err != nil {
+		return nil, err
+	}
+	defer archive.Close()
+
+	for _, file := range archive.File {
+		ext := strings.ToLower(filepath.Ext(file.Name))
+		if strings.Contains(file.Name, "/") || (ext != ".txt" && ext != ".json") {
+			continue
+		}
+		reader, err := file.Open()
+		if err != nil {
+			return nil, err
+		}
+		data, err := io.ReadAll(reader)
+		reader.Close()
+		if err != nil {
+			return nil, err
+		}
+		if strings.Contains(string(data), `"items"`) {
+			return data, nil
+		}
+	}
+	return nil, errors.New("no Standard Notes backup file found in the zip")
+}
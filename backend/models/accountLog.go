@@ -0,0 +1,42 @@
+package models
+
+import "time"
+
+// Events recorded in the account log, one leaf is appended every time the head of a note changes
+const (
+	LogEventHead    = "head"    // A note was created or a block was appended to it
+	LogEventTrash   = "trash"   // A note was moved to the trash, the hash is its tombstone block
+	LogEventRestore = "restore" // A note was moved out of the trash, the hash is its restore block
+	LogEventPurge   = "purge"   // A note was permanently deleted from the trash
+)
+
+// LogLeaf is one entry of the append-only account log of a user.
+// The Merkle tree of the account is built over the hashes of these leaves.
+type LogLeaf struct {
+	Index     int       `json:"leaf_index"` // Position of the leaf in the log, starting at 0
+	NoteID    string    `json:"note_id"`
+	Event     string    `json:"event"`     // One of the LogEvent constants
+	HeadHash  string    `json:"head_hash"` // Head of the note after the event
+	LeafHash  string    `json:"leaf_hash"` // Base64 RFC 6962 leaf hash
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// TreeHead is the root of the Merkle tree of an account at a given size
+type TreeHead struct {
+	TreeSize int    `json:"tree_size"`
+	RootHash string `json:"root_hash"` // Base64 root hash
+}
+
+// InclusionProof proves that a leaf is part of the account tree
+type InclusionProof struct {
+	Leaf      *LogLeaf `json:"leaf"`
+	TreeHead  TreeHead `json:"tree_head"`
+	AuditPath []string `json:"audit_path"` // Base64 hashes from the leaf to the root
+}
+
+// ConsistencyProof proves that a tree head is an append-only extension of an older one
+type ConsistencyProof struct {
+	First  TreeHead `json:"first"`
+	Second TreeHead `json:"second"`
+	Proof  []string `json:"proof"` // Base64 hashes
+}
@@ -5,13 +5,27 @@ import (
 )
 
 // This matches the structure of the block in frontend
+// Blocks of the AES-128 encryption types carry the IVs and the MAC, blocks of the AEAD encryption types
+// carry the nonces and the authentication tags instead and leave the IVs and the MAC empty.
+// Blocks of the users with hybrid signatures also carry an ML-DSA signature.
+// The AEAD fields and the ML-DSA signature are omitted from the JSON when empty so the hashes of the older blocks do not change.
+// The lifecycle blocks, a tombstone or a restore, carry no content: they record that the note was moved to the trash or
+// out of it, and only set their kind, the ID of their note, the previous hash, the timestamp and the signatures.
 type Block struct {
-	PrevHash    string    `json:"prev_hash"`    // Hash of the previous block
-	IV          string    `json:"iv"`           // Initialization vector for body encryption
-	IVTitle     string    `json:"iv_title"`     // Initialization vector for title encryption
-	CipherTitle string    `json:"cipher_title"` // Encrypted title
-	Ciphertext  string    `json:"ciphertext"`   // Encrypted body content
-	MAC         string    `json:"mac"`          // Message Authentication Code
-	Signature   string    `json:"signature"`    // Digital signature of the block
-	Timestamp   time.Time `json:"timestamp"`    // Block creation timestamp
+	PrevHash    string    `json:"prev_hash"`              // Hash of the previous block
+	IV          string    `json:"iv"`                     // Initialization vector for body encryption
+	IVTitle     string    `json:"iv_title"`               // Initialization vector for title encryption
+	CipherTitle string    `json:"cipher_title"`           // Encrypted title
+	Ciphertext  string    `json:"ciphertext"`             // Encrypted body content
+	MAC         string    `json:"mac"`                    // Message Authentication Code
+	Signature   string    `json:"signature"`              // Digital signature of the block
+	Timestamp   time.Time `json:"timestamp"`              // Block creation timestamp
+	Nonce       string    `json:"nonce,omitempty"`        // AEAD nonce of the body
+	NonceTitle  string    `json:"nonce_title,omitempty"`  // AEAD nonce of the title
+	Tag         string    `json:"tag,omitempty"`          // AEAD authentication tag of the body
+	TagTitle    string    `json:"tag_title,omitempty"`    // AEAD authentication tag of the title
+	PQSignature string    `json:"pq_signature,omitempty"` // ML-DSA-65 signature of the hybrid blocks, omitted for the Ed25519 blocks
+	Kind        string    `json:"kind,omitempty"`         // BlockKindTombstone or BlockKindRestore, empty for the blocks of content
+	NoteID      string    `json:"note_id,omitempty"`      // Note a lifecycle block belongs to, part of its signature
+	SuiteID     uint16    `json:"-"`                      // Crypto suite the server validated the block with, not part of the hash
 }
@@ -0,0 +1,25 @@
+package models
+
+import "time"
+
+// DeviceKey is the signing key of one device of a user.
+// A device generates its own key and is enrolled by a key the user already trusts, the key derived from the
+// password or the key of another active device, which signs the new key. A lost device is revoked on its own,
+// the blocks it signed before keep verifying with the key recorded next to them.
+type DeviceKey struct {
+	ID         uint32     `json:"id"`
+	Name       string     `json:"name"`                    // Label chosen by the user, for example "Work laptop"
+	PubKey     string     `json:"public_key"`              // Base64 Ed25519 public key of the device
+	PQPubKey   string     `json:"pq_public_key,omitempty"` // Base64 ML-DSA public key, for the hybrid signature types
+	EnrolledBy string     `json:"enrolled_by"`             // Public key that signed the enrollment
+	Signature  string     `json:"signature"`               // Base64 signature of the enrollment payload
+	CreatedAt  time.Time  `json:"created_at"`
+	RevokedAt  *time.Time `json:"revoked_at,omitempty"` // Set once the device is revoked, it can no longer sign blocks
+}
+
+// BlockSigner identifies the keys a block was verified with when it was stored
+type BlockSigner struct {
+	PubKey   string  // Base64 Ed25519 public key
+	PQPubKey string  // Base64 ML-DSA public key of the hybrid signature types, empty otherwise
+	DeviceID *uint32 // Device key that signed the block, nil for the key derived from the password
+}
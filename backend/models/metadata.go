@@ -0,0 +1,20 @@
+package models
+
+// NoteMetadata holds the encrypted organisation data (folder and tags) of a note.
+// The folder and tag names are only ever stored encrypted inside CipherMeta,
+// the server only sees the blind index tokens computed by the client with HMAC.
+type NoteMetadata struct {
+	NoteID      string   `json:"note_id"`
+	CipherMeta  string   `json:"cipher_meta"`            // Encrypted folder and tag names
+	IVMeta      string   `json:"iv_meta"`                // Initialization vector for metadata encryption
+	FolderToken string   `json:"folder_token,omitempty"` // Blind index of the folder, empty if the note has no folder
+	TagTokens   []string `json:"tag_tokens,omitempty"`   // Blind indexes of the tags
+}
+
+// TitleFilter restricts the titles returned to the ones matching the blind index tokens
+type TitleFilter struct {
+	FolderToken     string   // only notes inside this folder
+	TagTokens       []string // only notes that have all of these tags
+	SearchTokens    []string // only notes whose current version was indexed with these keyword tokens
+	MatchAnyKeyword bool     // match notes with at least one of the search tokens instead of all of them
+}
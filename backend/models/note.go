@@ -6,19 +6,70 @@ import (
 
 // type note is note_id and block
 type Note struct {
-	NoteID uint  `json:"note_id"` // Unique identifier for the note
-	Block  Block `json:"block"`   // The block data associated with the note
+	NoteID       string   `json:"note_id"`                 // Opaque identifier of the note (32 hex characters)
+	Block        Block    `json:"block"`                   // The block data associated with the note
+	SearchTokens []string `json:"search_tokens,omitempty"` // Keyed keyword tokens of this version of the note
 }
 
 type NoteBlockChain struct {
-	NoteID uint    `json:"note_id"` // Unique identifier for the note
+	NoteID string  `json:"note_id"` // Opaque identifier of the note
 	Blocks []Block `json:"blocks"`  // List of blocks in the note's blockchain
 }
 
+// NoteHead is the latest version of a note: its latest block of content, then the lifecycle blocks appended
+// after it when the note was deleted and restored since, the last of them is the head of the note
+type NoteHead struct {
+	Block
+	Lifecycle []Block `json:"lifecycle,omitempty"` // Tombstone and restore blocks after the block of content, in chain order
+}
+
+// NoteInfo is the server side metadata of a note, kept in the notes table
+type NoteInfo struct {
+	NoteID     string    `json:"note_id"`
+	CreatedAt  time.Time `json:"created_at"`  // Timestamp of the first block
+	UpdatedAt  time.Time `json:"updated_at"`  // Timestamp of the latest block of content
+	HeadHash   string    `json:"head_hash"`   // Hash of the head block
+	BlockCount uint      `json:"block_count"` // Number of blocks, also the seq of the head block
+}
+
 // For the endpoint that returns the titles
 type Title struct {
-	NoteID      uint      `json:"note_id"`
+	NoteID      string    `json:"note_id"`
 	CipherTitle string    `json:"cipher_title"`
-	Timestamp   time.Time `json:"timestamp"`
+	Timestamp   time.Time `json:"timestamp"`  // Timestamp of the latest block (last modification)
+	CreatedAt   time.Time `json:"created_at"` // Timestamp of the first block
 	IV          string    `json:"iv_title"`
+	NonceTitle  string    `json:"nonce_title,omitempty"` // AEAD nonce of the title, for the AEAD encryption types
+	TagTitle    string    `json:"tag_title,omitempty"`   // AEAD authentication tag of the title
+	HeadHash    string    `json:"head_hash"`             // Hash of the head block, provable against the account tree
+	CipherMeta  string    `json:"cipher_meta,omitempty"` // Encrypted folder and tags, empty if the note has none
+	IVMeta      string    `json:"iv_meta,omitempty"`
+}
+
+// Sort orders supported when listing the titles
+const (
+	SortByModified = "modified"
+	SortByCreated  = "created"
+)
+
+// TitleCursor is the position of the last title of a page, the next page starts right after it
+type TitleCursor struct {
+	SortValue time.Time // updated_at or created_at of the last title, depending on the sort order
+	NoteID    string    // tie breaker for notes with the same timestamp
+}
+
+// TitlePagination selects which page of titles to return
+type TitlePagination struct {
+	Sort      string       // SortByModified or SortByCreated
+	Ascending bool         // oldest first instead of newest first
+	Limit     int          // maximum number of titles in the page
+	After     *TitleCursor // nil for the first page
+}
+
+// TitlePage is a page of titles returned by the titles endpoint
+type TitlePage struct {
+	Titles     []*Title `json:"titles"`
+	NextCursor string   `json:"next_cursor,omitempty"` // empty on the last page
+	Total      *int     `json:"total,omitempty"`       // number of notes matching the filter across all pages, first page only
+	TreeHead   TreeHead `json:"tree_head"`             // root of the account log, to detect dropped or rolled back notes
 }
@@ -0,0 +1,43 @@
+package models
+
+import "time"
+
+// Channels the security notifications are delivered through
+const (
+	ChannelEmail   = "email"   // An email to the address of the user
+	ChannelWebhook = "webhook" // A signed POST request to the URL chosen by the user
+)
+
+// Statuses of a notification delivery
+const (
+	DeliveryPending = "pending" // Waiting for its first attempt, or for a retry after a failure
+	DeliverySent    = "sent"    // Accepted by the mailer or the webhook
+	DeliveryFailed  = "failed"  // Every attempt failed, it is no longer retried
+)
+
+// NotificationPreferences are the settings of the security notifications of a user. A user without stored
+// preferences gets the emails of every event and no webhook.
+type NotificationPreferences struct {
+	EmailEnabled bool     `json:"email_enabled"` // The critical events are emailed even when disabled
+	WebhookURL   string   `json:"webhook_url"`   // Empty for no webhook
+	MutedEvents  []string `json:"muted_events"`  // Event types the user does not want, the critical ones cannot be muted
+	// HMAC-SHA256 key of the signatures of the webhook requests, hex encoded. Only sent to the user when it is
+	// generated, with a new webhook URL or on request.
+	WebhookSecret string `json:"webhook_secret,omitempty"`
+}
+
+// NotificationDelivery is a notification of an event to one channel, retried until it is sent or runs out of attempts
+type NotificationDelivery struct {
+	ID            uint64    `json:"id"`
+	UserID        uint32    `json:"-"`
+	EventType     string    `json:"event_type"`
+	Channel       string    `json:"channel"`
+	Target        string    `json:"target"` // Email address or webhook URL
+	Secret        string    `json:"-"`      // Webhook secret when the event happened, empty for the emails
+	Payload       string    `json:"-"`      // JSON of the event
+	Status        string    `json:"status"` // DeliveryPending, DeliverySent or DeliveryFailed
+	Attempts      int       `json:"attempts"`
+	NextAttemptAt time.Time `json:"next_attempt_at"`
+	LastError     string    `json:"last_error,omitempty"`
+	CreatedAt     time.Time `json:"created_at"`
+}
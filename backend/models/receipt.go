@@ -0,0 +1,22 @@
+package models
+
+import "time"
+
+// Receipt is the proof, signed by the server, that a block was accepted and stored.
+// A user holding a receipt can prove the server committed to a version of a note even if
+// the server later denies it or drops it.
+type Receipt struct {
+	NoteID     string    `json:"note_id"`
+	Seq        uint      `json:"seq"`         // Position of the block in the note chain, starting at 1
+	BlockHash  string    `json:"block_hash"`  // Hash of the accepted block
+	ReceivedAt time.Time `json:"received_at"` // Server time when the block was stored
+	KeyID      string    `json:"key_id"`      // Identifier of the server key that signed the receipt
+	Signature  string    `json:"signature"`   // Base64 Ed25519 signature of the receipt
+}
+
+// ServerKey is a public key published by the server
+type ServerKey struct {
+	KeyID     string `json:"key_id"`
+	Algorithm string `json:"algorithm"`
+	PublicKey string `json:"public_key"` // Base64 public key
+}
@@ -0,0 +1,54 @@
+package models
+
+import "time"
+
+// Statuses of a social recovery
+const (
+	RecoveryPending   = "pending"   // The contacts can submit their shares, they are released after ReleaseAt
+	RecoveryCancelled = "cancelled" // The owner of the account cancelled the recovery
+	RecoveryCompleted = "completed" // The account was re-keyed with the rebuilt recovery key
+)
+
+// RecoveryShare is a share of the recovery key of a user, sealed to the public key of a trusted contact
+type RecoveryShare struct {
+	ContactID      uint32    `json:"contact_id"`
+	ContactName    string    `json:"contact_name,omitempty"`
+	ContactEmail   string    `json:"contact_email,omitempty"`
+	ShareIndex     uint8     `json:"share_index"`               // x coordinate of the share, from 1
+	Threshold      uint8     `json:"threshold"`                 // Shares needed to rebuild the recovery key
+	ContactKey     string    `json:"contact_key"`               // Public key of the contact the share is sealed to
+	EncryptedShare string    `json:"encrypted_share,omitempty"` // Only sent to the contact holding the share
+	CreatedAt      time.Time `json:"created_at"`
+	// The contact changed their login key since, they can no longer open the share and it should be distributed again
+	Stale bool `json:"stale,omitempty"`
+}
+
+// HeldShare is a share a contact holds for another user, with the social recoveries of the user waiting for it
+type HeldShare struct {
+	OwnerName      string `json:"owner_name"`
+	OwnerEmail     string `json:"owner_email"`
+	ShareIndex     uint8  `json:"share_index"`
+	Threshold      uint8  `json:"threshold"`
+	ContactKey     string `json:"contact_key"` // The key the share is sealed to, the login key of the contact at the time
+	EncryptedShare string `json:"encrypted_share"`
+	// Pending recoveries of the owner, the contact checks with the requester which one is theirs
+	Requests []*RecoveryRequest `json:"requests"`
+}
+
+// RecoveryRequest is a social recovery of an account
+type RecoveryRequest struct {
+	ID           string    `json:"id"`
+	Status       string    `json:"status"`        // One of the Recovery status constants
+	EphemeralKey string    `json:"ephemeral_key"` // X25519 key of the requester, the contacts seal their shares to it
+	Threshold    uint8     `json:"threshold"`
+	Submitted    int       `json:"submitted"` // Number of shares submitted by the contacts
+	CreatedAt    time.Time `json:"created_at"`
+	ReleaseAt    time.Time `json:"release_at"` // The shares are released to the requester from then on
+	ExpiresAt    time.Time `json:"expires_at"`
+}
+
+// RecoverySubmission is a share submitted by a contact for a social recovery
+type RecoverySubmission struct {
+	ShareIndex     uint8  `json:"share_index"`
+	EncryptedShare string `json:"encrypted_share"` // Sealed to the ephemeral key of the request
+}
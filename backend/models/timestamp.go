@@ -0,0 +1,40 @@
+package models
+
+import "time"
+
+// BlockTimestamp is an RFC 3161 time-stamp token over the hash of a block
+type BlockTimestamp struct {
+	Seq       uint      `json:"seq"`
+	Authority string    `json:"authority"` // "local" or the URL of the time-stamp authority
+	GenTime   time.Time `json:"gen_time"`  // Time written in the token by the authority
+	Token     []byte    `json:"token"`     // DER encoded token, Base64 in JSON
+}
+
+// TimestampStatus is the result of the verification of the time-stamp token of a block
+type TimestampStatus struct {
+	BlockTimestamp
+	Verified  bool    `json:"verified"`        // The token is valid, covers the block hash and is signed by the authority
+	Error     string  `json:"error,omitempty"` // Why the token is not valid
+	ClockSkew float64 `json:"clock_skew"`      // Seconds between the timestamp claimed in the block and the time of the token
+}
+
+// BlockHistory describes one version of a note
+type BlockHistory struct {
+	Seq            uint             `json:"seq"`
+	BlockHash      string           `json:"block_hash"`
+	Timestamp      time.Time        `json:"timestamp"`           // Creation time claimed by the client in the block
+	ValidSignature bool             `json:"valid_signature"`     // The block is signed with the key of the user
+	DeviceID       *uint32          `json:"device_id,omitempty"` // Device key that signed the block, nil for the password key
+	Kind           string           `json:"kind,omitempty"`      // Kind of a lifecycle block, empty for a version of the content
+	TimestampToken *TimestampStatus `json:"timestamp_token"`     // nil if the block was never timestamped
+}
+
+// NoteVerification is the report of the verification of a whole note
+type NoteVerification struct {
+	NoteID          string          `json:"note_id"`
+	ChainValid      bool            `json:"chain_valid"`      // Every block links to the hash of the previous one
+	SignaturesValid bool            `json:"signatures_valid"` // Every block is signed with the key of the user
+	TimestampsValid bool            `json:"timestamps_valid"` // Every block has a valid token consistent with its claimed time
+	Blocks          []*BlockHistory `json:"blocks"`
+	Problems        []string        `json:"problems"` // Human readable description of every failed check
+}
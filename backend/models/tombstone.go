@@ -0,0 +1,42 @@
+package models
+
+import "time"
+
+// Kinds of the lifecycle blocks, see Block.Kind
+const (
+	BlockKindTombstone = "tombstone" // The note was moved to the trash
+	BlockKindRestore   = "restore"   // The note was moved out of the trash
+)
+
+// Tombstone is the signed request of a client moving a note to the trash, or out of it for a restore.
+// It is signed with a key of the user over the head of the note, and stored as a lifecycle block appended to
+// the chain of the note, so the deletions and restores of a note are part of its signed history.
+type Tombstone struct {
+	NoteID      string    `json:"note_id"`                // Note being deleted or restored
+	HeadHash    string    `json:"head_hash"`              // Hash of the head block at the time of the request
+	Timestamp   time.Time `json:"timestamp"`              // Deletion or restore timestamp
+	Signature   string    `json:"signature"`              // Digital signature of the tombstone
+	PQSignature string    `json:"pq_signature,omitempty"` // ML-DSA-65 signature of the hybrid signature types
+}
+
+// Block builds the lifecycle block of the request.
+// Parameters:
+// - kind: BlockKindTombstone or BlockKindRestore
+// Returns: the block appended to the chain of the note, its signature covers the same payload as the request
+func (t *Tombstone) Block(kind string) Block {
+	return Block{
+		PrevHash:    t.HeadHash,
+		Timestamp:   t.Timestamp,
+		Signature:   t.Signature,
+		PQSignature: t.PQSignature,
+		Kind:        kind,
+		NoteID:      t.NoteID,
+	}
+}
+
+// TrashedTitle is a title of a note inside the trash
+type TrashedTitle struct {
+	Title
+	DeletedAt time.Time `json:"deleted_at"` // When the note was moved to the trash
+	PurgeAt   time.Time `json:"purge_at"`   // When the note will be permanently deleted
+}
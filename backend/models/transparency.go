@@ -0,0 +1,33 @@
+package models
+
+import "time"
+
+// LogEntry is one entry of the global transparency log, the commitment to an accepted block.
+// Only the index and the leaf hash are published: the leaf hash commits to the note ID, the seq and the hash of the
+// block without revealing them, so the log does not tell how many notes an account has or when they are edited.
+// The owner of a note recomputes the leaf hash of its blocks with crypto.TransparencyLeafHash, the auditor reads the
+// other fields from the database.
+type LogEntry struct {
+	Index     int       `json:"leaf_index"` // Position of the entry in the log, starting at 0
+	NoteID    string    `json:"-"`
+	Seq       uint      `json:"-"`         // Position of the block in the note chain
+	BlockHash string    `json:"-"`         // Hash of the accepted block
+	LeafHash  string    `json:"leaf_hash"` // Base64 RFC 6962 leaf hash
+	CreatedAt time.Time `json:"-"`
+}
+
+// SignedTreeHead is a tree head of the transparency log signed by the server.
+// Once published, the server can no longer present a log that does not extend it.
+type SignedTreeHead struct {
+	TreeHead
+	Timestamp time.Time `json:"timestamp"` // When the tree head was signed
+	KeyID     string    `json:"key_id"`    // Identifier of the server key that signed the tree head
+	Signature string    `json:"signature"` // Base64 Ed25519 signature of the tree head
+}
+
+// LogInclusionProof proves that a block commitment is part of a signed tree head
+type LogInclusionProof struct {
+	Entry     *LogEntry       `json:"entry"`
+	TreeHead  *SignedTreeHead `json:"tree_head"`
+	AuditPath []string        `json:"audit_path"` // Base64 hashes from the leaf to the root
+}
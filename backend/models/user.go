@@ -2,13 +2,37 @@ package models
 
 // represents a user in the system.
 type User struct {
-	ID             uint32 `json:"id"`
-	Name           string `json:"name"`
-	Email          string `json:"email"`
-	PubKey         string `json:"public_key"`
-	EncryptionSalt string `json:"encryption_salt"`
-	HMACSalt       string `json:"hmac_salt"`
-	HMACType       string `json:"hmac_type"`
-	EncryptionType string `json:"encryption_type"`
-	LoginSalt      string `json:"login_salt"`
+	ID             uint32    `json:"id"`
+	Name           string    `json:"name"`
+	Email          string    `json:"email"`
+	PubKey         string    `json:"public_key"`
+	EncryptionSalt string    `json:"encryption_salt"`
+	HMACSalt       string    `json:"hmac_salt"`
+	HMACType       string    `json:"hmac_type"`
+	EncryptionType string    `json:"encryption_type"`
+	LoginSalt      string    `json:"login_salt"`
+	SuiteID        uint16    `json:"suite_id"`                // Crypto suite of the account, see crypto.Suites
+	KDF            KDFParams `json:"kdf"`                     // Derivation of the login key from the password and the login salt
+	SignatureType  string    `json:"signature_type"`          // Signature scheme of the blocks, see crypto.ValidSignatureType
+	PQPubKey       string    `json:"pq_public_key,omitempty"` // ML-DSA-65 public key of the hybrid signature types
+	// Ed25519 public key of the offline recovery key, empty when the user did not set one up
+	RecoveryPubKey string `json:"recovery_public_key,omitempty"`
+	// Encryption and HMAC keys sealed under the recovery key, only sent by the recovery flow
+	RecoveryEscrow string `json:"-"`
+	// Encryption and HMAC keys sealed under a key derived from the password, set once the password was recovered:
+	// the keys can no longer be derived from the new password, see crypto.KeyEnvelopePassword
+	WrappedKeys string `json:"wrapped_keys,omitempty"`
+	// The user confirmed they receive the emails sent to Email
+	EmailVerified bool `json:"email_verified"`
+	// New address of an email change waiting for the confirmation of both addresses
+	PendingEmail string `json:"pending_email,omitempty"`
+}
+
+// KDFParams are the parameters of the derivation of the Ed25519 login key of a user from their password and login
+// salt. The server stores them next to the salt so their cost can be raised, see crypto.IsKDFUpgrade.
+type KDFParams struct {
+	Algorithm   string `json:"algorithm"`             // "pbkdf2-sha256" or "argon2id"
+	Iterations  uint32 `json:"iterations"`            // PBKDF2 iterations, or Argon2id passes
+	MemoryKiB   uint32 `json:"memory_kib,omitempty"`  // Argon2id memory, in KiB
+	Parallelism uint8  `json:"parallelism,omitempty"` // Argon2id lanes
 }
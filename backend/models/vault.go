@@ -0,0 +1,100 @@
+package models
+
+import "time"
+
+// VaultFormatVersion is the version of the vault archive format written by the export endpoint.
+// Version 2 keeps the tombstones and the restores of the notes inside their chains.
+const VaultFormatVersion = 2
+
+// VaultManifest describes the content of a vault archive.
+// It lists every note file with its hash, so the server signature over the manifest covers the whole archive.
+type VaultManifest struct {
+	Version    int              `json:"version"`     // VaultFormatVersion of the archive
+	ExportedAt time.Time        `json:"exported_at"` // When the archive was built
+	Account    VaultAccount     `json:"account"`
+	Notes      []VaultNoteEntry `json:"notes"`
+}
+
+// VaultAccount holds the public key and the salts the client needs to derive its keys and decrypt the notes
+type VaultAccount struct {
+	Name           string     `json:"name"`
+	Email          string     `json:"email"`
+	PubKey         string     `json:"public_key"`
+	LoginSalt      string     `json:"login_salt"`
+	EncryptionSalt string     `json:"encryption_salt"`
+	HMACSalt       string     `json:"hmac_salt"`
+	HMACType       string     `json:"hmac_type"`
+	EncryptionType string     `json:"encryption_type"`
+	SuiteID        uint16     `json:"suite_id,omitempty"`       // Crypto suite of the account, missing from the older archives
+	KDF            *KDFParams `json:"kdf,omitempty"`            // Derivation of the login key, PBKDF2 when missing
+	SignatureType  string     `json:"signature_type,omitempty"` // Signature scheme of the blocks, Ed25519 when missing
+	PQPubKey       string     `json:"pq_public_key,omitempty"`  // ML-DSA public key of the hybrid signature types
+	WrappedKeys    string     `json:"wrapped_keys,omitempty"`   // Keys sealed under the password of a recovered account
+	SignerKeys     []string   `json:"signer_keys,omitempty"`    // Every other key that signed blocks: historic keys and devices, revoked ones included
+}
+
+// VaultNoteEntry is the manifest entry of one note file
+type VaultNoteEntry struct {
+	NoteID     string `json:"note_id"`
+	Path       string `json:"path"`        // Path of the note file inside the archive
+	SHA256     string `json:"sha256"`      // Base64 SHA-256 of the note file
+	HeadHash   string `json:"head_hash"`   // Hash of the head block of the note
+	BlockCount uint   `json:"block_count"` // Number of blocks in the chain
+	Deleted    bool   `json:"deleted"`     // The note is inside the trash, its head is a tombstone block
+}
+
+// VaultNote is the content of a note file: the full chain of the note and everything the server keeps about it
+type VaultNote struct {
+	NoteID       string            `json:"note_id"`
+	CreatedAt    time.Time         `json:"created_at"`
+	UpdatedAt    time.Time         `json:"updated_at"`
+	HeadHash     string            `json:"head_hash"`
+	Deleted      bool              `json:"deleted"`
+	DeletedAt    *time.Time        `json:"deleted_at,omitempty"`
+	Blocks       []Block           `json:"blocks"`                   // Every block of the chain, in order, lifecycle blocks included
+	SignerKeys   []string          `json:"signer_keys"`              // Public key that signed each block, same order as Blocks
+	PQSignerKeys []string          `json:"pq_signer_keys,omitempty"` // ML-DSA key that signed each block, empty for Ed25519 blocks
+	Metadata     *NoteMetadata     `json:"metadata,omitempty"`       // Encrypted folder and tags
+	SearchTokens []string          `json:"search_tokens,omitempty"`  // Keyword tokens of the head block
+	Timestamps   []*BlockTimestamp `json:"timestamps,omitempty"`     // RFC 3161 tokens of the blocks
+}
+
+// VaultSignature is the server signature over the manifest of a vault archive
+type VaultSignature struct {
+	KeyID     string `json:"key_id"`    // Identifier of the server key, published at /.well-known/server-keys
+	Algorithm string `json:"algorithm"` // Always ed25519
+	Signature string `json:"signature"` // Base64 signature of the manifest file
+}
+
+// Statuses of a note in an import report
+const (
+	ImportStatusImported  = "imported"  // The note was stored under its own ID
+	ImportStatusRenamed   = "renamed"   // The note ID was taken, the note was stored under a new ID
+	ImportStatusUnchanged = "unchanged" // The user already has this note with the same head
+	ImportStatusSkipped   = "skipped"   // The note ID was taken and the conflict policy is to skip, or the note cannot be renamed
+	ImportStatusRejected  = "rejected"  // The chain or a signature of the note is invalid
+	ImportStatusFailed    = "failed"    // The note could not be stored
+)
+
+// ImportResult is the outcome of the import of one note
+type ImportResult struct {
+	NoteID     string `json:"note_id"`               // ID of the note inside the archive
+	ImportedAs string `json:"imported_as,omitempty"` // ID the note was stored under
+	Status     string `json:"status"`                // One of the ImportStatus constants
+	Error      string `json:"error,omitempty"`       // Why the note was rejected or skipped
+}
+
+// ImportReport is the outcome of the import of a vault archive
+type ImportReport struct {
+	ManifestVerified *bool           `json:"manifest_verified,omitempty"` // Always true, archives whose manifest does not verify are rejected
+	Notes            []*ImportResult `json:"notes"`
+}
+
+// PQSignerKey returns the ML-DSA key that signed a block of the note, empty when the block is signed with Ed25519
+// alone or the archive predates the hybrid signatures
+func (n *VaultNote) PQSignerKey(i int) string {
+	if i < len(n.PQSignerKeys) {
+		return n.PQSignerKeys[i]
+	}
+	return ""
+}
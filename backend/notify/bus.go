@@ -0,0 +1,313 @@
+package notify
+
+import (
+	"backend/db"
+	"backend/models"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"log"
+	"slices"
+	"strconv"
+	"sync"
+	"time"
+)
+
+const (
+	deliveryWorkers   = 4                // Deliveries attempted at once
+	deliveryQueueSize = 256              // Deliveries waiting for a worker, the retries pick up the overflow
+	deliveryLease     = 5 * time.Minute  // How long an attempt can run before another worker may retry it
+	maxRetryDelay     = 6 * time.Hour    // Longest wait between two attempts
+	retryBatchSize    = 100              // Due deliveries attempted by each run of the retries
+	failureMapLimit   = 10000            // Users tracked by the failure counter before the stale ones are dropped
+	minRetryDelay     = 10 * time.Second // Shortest wait before the first retry
+)
+
+// Config holds the settings of the bus
+type Config struct {
+	MaxAttempts      int           // Attempts of a delivery before it is given up
+	RetryDelay       time.Duration // Wait before the first retry, doubled after every failure
+	FailureThreshold int           // Failed signatures of a user that make a burst
+	FailureWindow    time.Duration // Window the failed signatures of a burst fall in
+}
+
+// failureCount counts the failed signatures of a user in the current window
+type failureCount struct {
+	start time.Time
+	count int
+}
+
+// Bus stores the events as deliveries to the channels the users chose, and attempts them in the background.
+// Fields:
+// - repo: the repository of the preferences and the deliveries
+// - users: the repository the email and the name of the users are looked up in
+// - notifiers: the notifier of each channel, the channels without one are never delivered to
+// - cfg: the settings of the bus
+type Bus struct {
+	repo      *db.NotificationRepository
+	users     *db.UserRepository
+	notifiers map[string]Notifier
+	cfg       Config
+
+	queue chan *models.NotificationDelivery
+	stop  chan struct{}
+	wg    sync.WaitGroup
+
+	failuresMu sync.Mutex
+	failures   map[uint32]*failureCount
+}
+
+// NewBus creates a bus, Start launches its workers.
+// Parameters:
+// - database: the SQL database connection
+// - notifiers: the notifier of each channel, models.ChannelEmail or models.ChannelWebhook
+// - cfg: the settings of the bus
+// Returns: a pointer to the bus
+func NewBus(database *sql.DB, notifiers map[string]Notifier, cfg Config) *Bus {
+	if cfg.MaxAttempts < 1 {
+		cfg.MaxAttempts = 1
+	}
+	if cfg.RetryDelay < minRetryDelay {
+		cfg.RetryDelay = minRetryDelay
+	}
+	return &Bus{
+		repo:      db.NewNotificationRepository(database),
+		users:     db.NewUserRepository(database),
+		notifiers: notifiers,
+		cfg:       cfg,
+		queue:     make(chan *models.NotificationDelivery, deliveryQueueSize),
+		stop:      make(chan struct{}),
+		failures:  make(map[uint32]*failureCount),
+	}
+}
+
+// Start launches the workers attempting the new deliveries
+func (b *Bus) Start() {
+	for range deliveryWorkers {
+		b.wg.Add(1)
+		go func() {
+			defer b.wg.Done()
+			for {
+				select {
+				case delivery := <-b.queue:
+					b.attempt(delivery)
+				case <-b.stop:
+					return
+				}
+			}
+		}()
+	}
+}
+
+// Stop stops the workers once their current attempt is over, the queued deliveries are left to the retries
+func (b *Bus) Stop() {
+	close(b.stop)
+	b.wg.Wait()
+}
+
+// Publish stores the deliveries of an event to the channels the user chose and queues them for the workers.
+// Parameters:
+// - event: a pointer to the event, its email, name and time are filled in when missing
+// Returns: an error if the user or their preferences cannot be read, or the deliveries cannot be stored
+func (b *Bus) Publish(event *Event) error {
+	if !IsValidEventType(event.Type) {
+		return fmt.Errorf("unknown event type %q", event.Type)
+	}
+	if event.Time.IsZero() {
+		event.Time = time.Now().UTC()
+	}
+	if event.Email == "" || event.Name == "" {
+		user, err := b.users.GetUserByID(event.UserID)
+		if err != nil {
+			return err
+		}
+		if event.Email == "" {
+			event.Email = user.Email
+		}
+		if event.Name == "" {
+			event.Name = user.Name
+		}
+	}
+	prefs := event.Preferences
+	if prefs == nil {
+		var err error
+		if prefs, err = b.repo.GetPreferences(event.UserID); err != nil {
+			return err
+		}
+	}
+
+	payload, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+
+	deliveries := b.deliveries(event, prefs, payload, time.Now().UTC())
+	if len(deliveries) == 0 {
+		return nil
+	}
+	if err := b.repo.CreateDeliveries(deliveries); err != nil {
+		return err
+	}
+
+	for _, delivery := range deliveries {
+		select {
+		case b.queue <- delivery:
+		default:
+			// The queue is full, the delivery is due and the next run of the retries attempts it
+		}
+	}
+	return nil
+}
+
+// deliveries builds the deliveries of an event to the channels the user chose: the email unless it is disabled,
+// the webhook when one is set, nothing for a muted event. The critical events are emailed anyway and cannot be muted.
+func (b *Bus) deliveries(event *Event, prefs *models.NotificationPreferences, payload []byte,
+	now time.Time) []*models.NotificationDelivery {
+	critical := IsCritical(event.Type)
+	if !critical && slices.Contains(prefs.MutedEvents, event.Type) {
+		return nil
+	}
+
+	newDelivery := func(channel, target, secret string) *models.NotificationDelivery {
+		return &models.NotificationDelivery{
+			UserID:        event.UserID,
+			EventType:     event.Type,
+			Channel:       channel,
+			Target:        target,
+			Secret:        secret,
+			Payload:       string(payload),
+			Status:        models.DeliveryPending,
+			NextAttemptAt: now,
+			CreatedAt:     now,
+		}
+	}
+	deliveries := []*models.NotificationDelivery{}
+	if (prefs.EmailEnabled || critical) && b.notifiers[models.ChannelEmail] != nil {
+		deliveries = append(deliveries, newDelivery(models.ChannelEmail, event.Email, ""))
+	}
+	if prefs.WebhookURL != "" && b.notifiers[models.ChannelWebhook] != nil {
+		deliveries = append(deliveries, newDelivery(models.ChannelWebhook, prefs.WebhookURL, prefs.WebhookSecret))
+	}
+	return deliveries
+}
+
+// RetryDue attempts the deliveries whose retry is due, the cron scheduler runs it regularly
+func (b *Bus) RetryDue() {
+	deliveries, err := b.repo.GetDueDeliveries(time.Now().UTC(), retryBatchSize)
+	if err != nil {
+		log.Printf("Error reading the due notification deliveries: %v", err)
+		return
+	}
+	for _, delivery := range deliveries {
+		b.attempt(delivery)
+	}
+}
+
+// PurgeDeliveries removes the sent and failed deliveries created before a date.
+// Parameters:
+// - before: the deliveries created before this time are removed
+// Returns: the number of removed deliveries, or an error if the deletion fails
+func (b *Bus) PurgeDeliveries(before time.Time) (int64, error) {
+	return b.repo.DeleteDeliveriesBefore(before)
+}
+
+// RecordFailedSignature counts a failed signature of a user, and emits EventFailedSignatures when the failures
+// of the current window reach the threshold. A burst is reported once per window.
+// Parameters:
+// - userID: the ID of the user
+// - details: what identifies the attempt, like the IP address
+func (b *Bus) RecordFailedSignature(userID uint32, details map[string]string) {
+	if b.cfg.FailureThreshold < 1 {
+		return
+	}
+	now := time.Now().UTC()
+
+	b.failuresMu.Lock()
+	if len(b.failures) >= failureMapLimit {
+		for id, failures := range b.failures {
+			if now.Sub(failures.start) > b.cfg.FailureWindow {
+				delete(b.failures, id)
+			}
+		}
+	}
+	failures := b.failures[userID]
+	if failures == nil || now.Sub(failures.start) > b.cfg.FailureWindow {
+		failures = &failureCount{start: now}
+		b.failures[userID] = failures
+	}
+	failures.count++
+	burst := failures.count == b.cfg.FailureThreshold
+	since := failures.start
+	b.failuresMu.Unlock()
+
+	if !burst {
+		return
+	}
+	eventDetails := map[string]string{
+		"failures": strconv.Itoa(b.cfg.FailureThreshold),
+		"since":    since.Format(time.RFC3339),
+	}
+	for key, value := range details {
+		eventDetails[key] = value
+	}
+	if err := b.Publish(&Event{Type: EventFailedSignatures, UserID: userID, Details: eventDetails}); err != nil {
+		log.Printf("Error publishing the failed signatures of user %d: %v", userID, err)
+	}
+}
+
+// attempt claims a delivery, sends it with the notifier of its channel and records the outcome
+func (b *Bus) attempt(delivery *models.NotificationDelivery) {
+	now := time.Now().UTC()
+	claimed, err := b.repo.ClaimDelivery(delivery.ID, now, now.Add(deliveryLease))
+	if err != nil {
+		log.Printf("Error claiming the notification delivery %d: %v", delivery.ID, err)
+		return
+	}
+	if !claimed {
+		return
+	}
+
+	var event Event
+	err = json.Unmarshal([]byte(delivery.Payload), &event)
+	if err == nil {
+		notifier := b.notifiers[delivery.Channel]
+		if notifier == nil {
+			err = fmt.Errorf("no notifier for the %s channel", delivery.Channel)
+		} else {
+			err = notifier.Notify(delivery, &event)
+		}
+	}
+
+	b.recordOutcome(delivery, now, err)
+	if err := b.repo.UpdateDelivery(delivery); err != nil {
+		log.Printf("Error updating the notification delivery %d: %v", delivery.ID, err)
+	}
+}
+
+// recordOutcome counts an attempt of a delivery: it is sent, scheduled for a retry, or given up once it ran out of
+// attempts
+func (b *Bus) recordOutcome(delivery *models.NotificationDelivery, now time.Time, err error) {
+	delivery.Attempts++
+	if err == nil {
+		delivery.Status = models.DeliverySent
+		delivery.LastError = ""
+		return
+	}
+
+	delivery.LastError = err.Error()
+	if delivery.Attempts >= b.cfg.MaxAttempts {
+		delivery.Status = models.DeliveryFailed
+		log.Printf("Giving up the notification delivery %d after %d attempts: %v", delivery.ID, delivery.Attempts, err)
+	} else {
+		delivery.NextAttemptAt = now.Add(b.retryDelay(delivery.Attempts))
+	}
+}
+
+// retryDelay is the wait after a number of failed attempts, doubled after every failure up to maxRetryDelay
+func (b *Bus) retryDelay(attempts int) time.Duration {
+	delay := b.cfg.RetryDelay
+	for i := 1; i < attempts && delay < maxRetryDelay; i++ {
+		delay *= 2
+	}
+	return min(delay, maxRetryDelay)
+}
@@ -0,0 +1,140 @@
+package notify
+
+import (
+	"backend/models"
+	"errors"
+	"testing"
+	"time"
+)
+
+// nopNotifier stands for a channel in the tests that never send anything
+type nopNotifier struct{}
+
+func (nopNotifier) Notify(*models.NotificationDelivery, *Event) error { return nil }
+
+func testBus(cfg Config, channels ...string) *Bus {
+	notifiers := map[string]Notifier{}
+	for _, channel := range channels {
+		notifiers[channel] = nopNotifier{}
+	}
+	return NewBus(nil, notifiers, cfg)
+}
+
+func TestNewBusClampsSettings(t *testing.T) {
+	bus := testBus(Config{MaxAttempts: 0, RetryDelay: time.Second})
+	if bus.cfg.MaxAttempts != 1 {
+		t.Errorf("got %d attempts, want 1", bus.cfg.MaxAttempts)
+	}
+	if bus.cfg.RetryDelay != minRetryDelay {
+		t.Errorf("got retry delay %v, want %v", bus.cfg.RetryDelay, minRetryDelay)
+	}
+}
+
+func TestRetryDelay(t *testing.T) {
+	bus := testBus(Config{MaxAttempts: 50, RetryDelay: time.Minute})
+	tests := []struct {
+		attempts int
+		want     time.Duration
+	}{
+		{1, time.Minute},
+		{2, 2 * time.Minute},
+		{3, 4 * time.Minute},
+		{8, 128 * time.Minute},
+		{9, 256 * time.Minute},
+		{10, maxRetryDelay},
+		{40, maxRetryDelay},
+	}
+	for _, tt := range tests {
+		if got := bus.retryDelay(tt.attempts); got != tt.want {
+			t.Errorf("%d attempts: got delay %v, want %v", tt.attempts, got, tt.want)
+		}
+	}
+}
+
+func TestRecordOutcome(t *testing.T) {
+	bus := testBus(Config{MaxAttempts: 3, RetryDelay: time.Minute})
+	now := time.Date(2026, 10, 19, 8, 0, 0, 0, time.UTC)
+	delivery := &models.NotificationDelivery{Status: models.DeliveryPending}
+
+	// The failed attempts are retried with a doubling delay until the last one
+	for attempt, wantDelay := range []time.Duration{time.Minute, 2 * time.Minute} {
+		bus.recordOutcome(delivery, now, errors.New("unreachable"))
+		if delivery.Status != models.DeliveryPending || delivery.Attempts != attempt+1 {
+			t.Fatalf("attempt %d: got status %s after %d attempts", attempt+1, delivery.Status, delivery.Attempts)
+		}
+		if !delivery.NextAttemptAt.Equal(now.Add(wantDelay)) {
+			t.Errorf("attempt %d: got next attempt at %v, want %v", attempt+1, delivery.NextAttemptAt, now.Add(wantDelay))
+		}
+		if delivery.LastError != "unreachable" {
+			t.Errorf("attempt %d: got last error %q", attempt+1, delivery.LastError)
+		}
+	}
+
+	// The delivery is given up at the last attempt
+	bus.recordOutcome(delivery, now, errors.New("unreachable"))
+	if delivery.Status != models.DeliveryFailed || delivery.Attempts != 3 {
+		t.Errorf("got status %s after %d attempts, want %s after 3", delivery.Status, delivery.Attempts, models.DeliveryFailed)
+	}
+
+	// A success clears the error of the previous attempts
+	delivery = &models.NotificationDelivery{Status: models.DeliveryPending, Attempts: 1, LastError: "unreachable"}
+	bus.recordOutcome(delivery, now, nil)
+	if delivery.Status != models.DeliverySent || delivery.Attempts != 2 || delivery.LastError != "" {
+		t.Errorf("got status %s after %d attempts with error %q", delivery.Status, delivery.Attempts, delivery.LastError)
+	}
+}
+
+func TestDeliveries(t *testing.T) {
+	both := testBus(Config{}, models.ChannelEmail, models.ChannelWebhook)
+	emailOnly := testBus(Config{}, models.ChannelEmail)
+	webhook := "https://example.com/hook"
+
+	tests := []struct {
+		name      string
+		bus       *Bus
+		eventType string
+		prefs     models.NotificationPreferences
+		want      []string
+	}{
+		{"email and webhook", both, EventNewLogin,
+			models.NotificationPreferences{EmailEnabled: true, WebhookURL: webhook}, []string{models.ChannelEmail, models.ChannelWebhook}},
+		{"email disabled", both, EventNewLogin,
+			models.NotificationPreferences{WebhookURL: webhook}, []string{models.ChannelWebhook}},
+		{"no webhook", both, EventNewLogin,
+			models.NotificationPreferences{EmailEnabled: true}, []string{models.ChannelEmail}},
+		{"nothing enabled", both, EventNewLogin,
+			models.NotificationPreferences{}, nil},
+		{"muted event", both, EventNewLogin,
+			models.NotificationPreferences{EmailEnabled: true, WebhookURL: webhook, MutedEvents: []string{EventNewLogin}}, nil},
+		{"other event muted", both, EventNewDevice,
+			models.NotificationPreferences{EmailEnabled: true, MutedEvents: []string{EventNewLogin}}, []string{models.ChannelEmail}},
+		{"critical event with email disabled", both, EventKeyRotation,
+			models.NotificationPreferences{}, []string{models.ChannelEmail}},
+		{"muted critical event", both, EventEmailChange,
+			models.NotificationPreferences{WebhookURL: webhook, MutedEvents: []string{EventEmailChange}}, []string{models.ChannelEmail, models.ChannelWebhook}},
+		{"channel without notifier", emailOnly, EventNewLogin,
+			models.NotificationPreferences{EmailEnabled: true, WebhookURL: webhook}, []string{models.ChannelEmail}},
+	}
+
+	now := time.Date(2026, 10, 19, 8, 0, 0, 0, time.UTC)
+	for _, tt := range tests {
+		event := &Event{Type: tt.eventType, UserID: 7, Email: "alice@example.com"}
+		deliveries := tt.bus.deliveries(event, &tt.prefs, []byte("{}"), now)
+		if len(deliveries) != len(tt.want) {
+			t.Errorf("%s: got %d deliveries, want %d", tt.name, len(deliveries), len(tt.want))
+			continue
+		}
+		for i, delivery := range deliveries {
+			if delivery.Channel != tt.want[i] || delivery.UserID != 7 || delivery.Status != models.DeliveryPending ||
+				!delivery.NextAttemptAt.Equal(now) {
+				t.Errorf("%s: unexpected delivery %+v", tt.name, delivery)
+			}
+			if delivery.Channel == models.ChannelEmail && delivery.Target != event.Email {
+				t.Errorf("%s: got email target %q", tt.name, delivery.Target)
+			}
+			if delivery.Channel == models.ChannelWebhook && delivery.Target != webhook {
+				t.Errorf("%s: got webhook target %q", tt.name, delivery.Target)
+			}
+		}
+	}
+}
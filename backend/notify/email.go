@@ -0,0 +1,91 @@
+package notify
+
+import (
+	"backend/mail"
+	"backend/models"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+)
+
+// emailTemplate is the subject and the first paragraph of the email of an event type
+type emailTemplate struct {
+	subject string
+	intro   string
+}
+
+var emailTemplates = map[string]emailTemplate{
+	EventNewLogin: {
+		subject: "New login to your account",
+		intro:   "Your account was logged in from a client it was never used from before.",
+	},
+	EventNewDevice: {
+		subject: "A new device was added to your account",
+		intro:   "A new device key was enrolled, it can now sign the notes of your account.",
+	},
+	EventDeviceRevoked: {
+		subject: "A device was removed from your account",
+		intro:   "A device key was revoked, it can no longer sign the notes of your account.",
+	},
+	EventKeyRotation: {
+		subject: "A key of your account changed",
+		intro:   "One of the keys of your account was replaced.",
+	},
+	EventEmailChange: {
+		subject: "The email of your account changed",
+		intro:   "The email address of your account was changed, this address no longer receives its emails.",
+	},
+	EventRecoveryRequest: {
+		subject: "A recovery of your account was started",
+		intro: "Someone asked your trusted contacts for the shares of your recovery key. If it was not you, " +
+			"cancel the recovery from your account before the release time below.",
+	},
+	EventAccountDeletion: {
+		subject: "Your account was deleted",
+		intro:   "Your account and all its notes were deleted.",
+	},
+	EventFailedSignatures: {
+		subject: "Failed logins to your account",
+		intro:   "Several logins to your account failed with a wrong password in a short time.",
+	},
+}
+
+// EmailNotifier delivers the notifications by email, through the mailer of the server
+type EmailNotifier struct{}
+
+// NewEmailNotifier creates a notifier sending the emails with mail.Send.
+// Returns: a pointer to the notifier
+func NewEmailNotifier() *EmailNotifier {
+	return &EmailNotifier{}
+}
+
+// Notify emails an event to the address of a delivery
+func (n *EmailNotifier) Notify(delivery *models.NotificationDelivery, event *Event) error {
+	template, ok := emailTemplates[event.Type]
+	if !ok {
+		return fmt.Errorf("no email for the %s event", event.Type)
+	}
+
+	var body strings.Builder
+	fmt.Fprintf(&body, "Hello %s,\n\n%s\n\n", event.Name, template.intro)
+	fmt.Fprintf(&body, "- time: %s\n", event.Time.UTC().Format(time.RFC1123))
+	keys := make([]string, 0, len(event.Details))
+	for key := range event.Details {
+		keys = append(keys, key)
+	}
+	slices.Sort(keys)
+	for _, key := range keys {
+		fmt.Fprintf(&body, "- %s: %s\n", strings.ReplaceAll(key, "_", " "), event.Details[key])
+	}
+	if event.Type != EventAccountDeletion {
+		body.WriteString("\nIf this was not you, change your password and review the devices and the recovery " +
+			"contacts of your account.\n")
+	}
+
+	return mail.Send(&mail.Message{
+		To:      delivery.Target,
+		Subject: template.subject,
+		Body:    body.String(),
+	})
+}
@@ -0,0 +1,113 @@
+// Package notify tells the users about the sensitive events of their account: logins from new clients, new and
+// revoked devices, key rotations, email changes, social recoveries, the deletion of the account and bursts of
+// failed signatures.
+//
+// The handlers emit the events on the bus, which stores one delivery per channel the user chose and hands them
+// to the notifiers. A failed delivery is retried with an exponential backoff until it runs out of attempts.
+package notify
+
+import (
+	"backend/models"
+	"errors"
+	"log"
+	"slices"
+	"time"
+)
+
+// Types of the security events
+const (
+	EventNewLogin         = "new-login"              // A login from a client the user never logged in from
+	EventNewDevice        = "new-device"             // A device key was enrolled
+	EventDeviceRevoked    = "device-revoked"         // A device key was revoked
+	EventKeyRotation      = "key-rotation"           // The login key or the recovery key changed
+	EventEmailChange      = "email-change"           // The email of the account changed
+	EventAccountDeletion  = "account-deletion"       // The account was deleted
+	EventFailedSignatures = "failed-signature-burst" // Many logins failed with a wrong signature in a short time
+	EventRecoveryRequest  = "recovery-request"       // A social recovery of the account was started
+)
+
+// EventTypes lists every event type, in the order they are shown to the users
+var EventTypes = []string{
+	EventNewLogin, EventNewDevice, EventDeviceRevoked, EventKeyRotation, EventEmailChange, EventRecoveryRequest,
+	EventAccountDeletion, EventFailedSignatures,
+}
+
+// criticalEvents cannot be muted and are always emailed: whoever caused them may also control the preferences,
+// and the owner of an account being recovered only has until the shares are released to cancel the recovery
+var criticalEvents = []string{EventKeyRotation, EventEmailChange, EventRecoveryRequest, EventAccountDeletion}
+
+// ErrNoBus is returned when the notifications are not set up
+var ErrNoBus = errors.New("no notification bus is configured")
+
+// Event is a security event of an account
+type Event struct {
+	Type    string            `json:"type"`
+	UserID  uint32            `json:"user_id"`
+	Email   string            `json:"email"` // Address the email is sent to, the current one of the user when empty
+	Name    string            `json:"name"`
+	Time    time.Time         `json:"time"`
+	Details map[string]string `json:"details,omitempty"` // What the user needs to recognize the event, like the IP
+	// Preferences of the user, looked up when nil. Set when the user is gone by the time the event is emitted.
+	Preferences *models.NotificationPreferences `json:"-"`
+}
+
+// Notifier delivers the notifications of one channel
+type Notifier interface {
+	// Notify sends an event to the target of a delivery, or fails without retrying
+	Notify(delivery *models.NotificationDelivery, event *Event) error
+}
+
+// IsValidEventType tells whether a string is one of the event types
+func IsValidEventType(eventType string) bool {
+	return slices.Contains(EventTypes, eventType)
+}
+
+// IsCritical tells whether an event type cannot be muted
+func IsCritical(eventType string) bool {
+	return slices.Contains(criticalEvents, eventType)
+}
+
+var bus *Bus
+
+// SetBus sets the bus the events are emitted on.
+// Parameters:
+// - b: the bus, nil disables the notifications
+func SetBus(b *Bus) {
+	bus = b
+}
+
+// GetBus returns the bus the events are emitted on.
+// Returns: the configured bus, or ErrNoBus if there is none
+func GetBus() (*Bus, error) {
+	if bus == nil {
+		return nil, ErrNoBus
+	}
+	return bus, nil
+}
+
+// Emit publishes an event on the bus of the server. A notification must never fail the request that caused it,
+// so the errors are only logged.
+// Parameters:
+// - event: a pointer to the event
+func Emit(event *Event) {
+	b, err := GetBus()
+	if err != nil {
+		return
+	}
+	if err := b.Publish(event); err != nil {
+		log.Printf("Error publishing the %s event of user %d: %v", event.Type, event.UserID, err)
+	}
+}
+
+// RecordFailedSignature counts a failed signature of a user on the bus of the server, and emits
+// EventFailedSignatures once they add up to a burst.
+// Parameters:
+// - userID: the ID of the user
+// - details: what identifies the attempt, like the IP address
+func RecordFailedSignature(userID uint32, details map[string]string) {
+	b, err := GetBus()
+	if err != nil {
+		return
+	}
+	b.RecordFailedSignature(userID, details)
+}
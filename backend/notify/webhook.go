@@ -0,0 +1,132 @@
+package notify
+
+import (
+	"backend/models"
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"strconv"
+	"syscall"
+	"time"
+)
+
+const (
+	webhookTimeout      = 10 * time.Second
+	maxWebhookURLLength = 2048
+)
+
+// errPrivateAddress is returned when a webhook resolves to an address of the private network of the server
+var errPrivateAddress = errors.New("the webhook resolves to a private address")
+
+// WebhookNotifier delivers the notifications as JSON POST requests to the URL chosen by the user, signed with the
+// webhook secret: the X-Signature header is "sha256=" followed by the hex HMAC-SHA256 of the body keyed with the
+// secret. A retried delivery keeps its X-Delivery-ID, so the receiver can drop the duplicates.
+// Fields:
+// - Client: the HTTP client of the requests
+type WebhookNotifier struct {
+	Client *http.Client
+}
+
+// NewWebhookNotifier creates a webhook notifier. Unless allowPrivate is set, the requests refuse to connect to
+// the loopback, private and link-local addresses, so a webhook cannot reach the internal services of the server.
+// Parameters:
+// - allowPrivate: whether the webhooks may reach the private addresses, for development
+// Returns: a pointer to the notifier
+func NewWebhookNotifier(allowPrivate bool) *WebhookNotifier {
+	dialer := &net.Dialer{Timeout: webhookTimeout}
+	if !allowPrivate {
+		// Checked on the address actually dialed, a host name cannot resolve to a private address after validation
+		dialer.Control = func(network, address string, _ syscall.RawConn) error {
+			host, _, err := net.SplitHostPort(address)
+			if err != nil {
+				return err
+			}
+			ip := net.ParseIP(host)
+			if ip == nil || !isPublicIP(ip) {
+				return errPrivateAddress
+			}
+			return nil
+		}
+	}
+	return &WebhookNotifier{
+		Client: &http.Client{
+			Timeout: webhookTimeout,
+			Transport: &http.Transport{
+				DialContext:         dialer.DialContext,
+				TLSHandshakeTimeout: webhookTimeout,
+			},
+			// A redirect could lead to a private address, it counts as a failure
+			CheckRedirect: func(*http.Request, []*http.Request) error {
+				return http.ErrUseLastResponse
+			},
+		},
+	}
+}
+
+// Notify posts an event to the URL of a delivery, any status but 2xx is a failure
+func (n *WebhookNotifier) Notify(delivery *models.NotificationDelivery, event *Event) error {
+	body := []byte(delivery.Payload)
+	ctx, cancel := context.WithTimeout(context.Background(), webhookTimeout)
+	defer cancel()
+	request, err := http.NewRequestWithContext(ctx, http.MethodPost, delivery.Target, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+
+	mac := hmac.New(sha256.New, []byte(delivery.Secret))
+	mac.Write(body)
+	request.Header.Set("Content-Type", "application/json")
+	request.Header.Set("User-Agent", "CantTouchMe-Notifications")
+	request.Header.Set("X-Event-Type", event.Type)
+	request.Header.Set("X-Delivery-ID", strconv.FormatUint(delivery.ID, 10))
+	request.Header.Set("X-Signature", "sha256="+hex.EncodeToString(mac.Sum(nil)))
+
+	response, err := n.Client.Do(request)
+	if err != nil {
+		return err
+	}
+	defer response.Body.Close()
+	io.Copy(io.Discard, io.LimitReader(response.Body, 64*1024))
+
+	if response.StatusCode < 200 || response.StatusCode > 299 {
+		return fmt.Errorf("the webhook answered %s", response.Status)
+	}
+	return nil
+}
+
+// ValidateWebhookURL checks that a webhook URL can be stored: an absolute HTTPS URL without credentials, or HTTP
+// as well when the private addresses are allowed, for development.
+// Parameters:
+// - rawURL: the URL to validate
+// - allowPrivate: whether the webhooks may reach the private addresses
+// Returns: an error describing why the URL is refused, nil if it is valid
+func ValidateWebhookURL(rawURL string, allowPrivate bool) error {
+	if len(rawURL) > maxWebhookURLLength {
+		return fmt.Errorf("the webhook URL must be at most %d characters", maxWebhookURLLength)
+	}
+	parsed, err := url.Parse(rawURL)
+	if err != nil || parsed.Host == "" || parsed.User != nil {
+		return errors.New("the webhook URL must be an absolute URL without credentials")
+	}
+	if parsed.Scheme != "https" && (parsed.Scheme != "http" || !allowPrivate) {
+		return errors.New("the webhook URL must use https")
+	}
+	if ip := net.ParseIP(parsed.Hostname()); ip != nil && !allowPrivate && !isPublicIP(ip) {
+		return errPrivateAddress
+	}
+	return nil
+}
+
+// isPublicIP tells whether an address is reachable on the internet, and not one of the network of the server
+func isPublicIP(ip net.IP) bool {
+	return !ip.IsLoopback() && !ip.IsPrivate() && !ip.IsLinkLocalUnicast() && !ip.IsLinkLocalMulticast() &&
+		!ip.IsUnspecified() && !ip.IsMulticast() && !ip.IsInterfaceLocalMulticast()
+}
@@ -1,9 +1,12 @@
 package routes
 
 import (
+	"backend/auth"
 	"backend/crypto"
 	"backend/db"
 	"backend/models"
+	"encoding/base64"
+	"encoding/binary"
 	"encoding/json"
 	"log"
 	"net/http"
@@ -18,9 +21,10 @@ type ChallengeRequestBody struct {
 
 // ChallengeResponseBody represents the JSON response with the challenge
 type ChallengeResponseBody struct {
-	Challenge string `json:"challenge"`
-	LoginSalt string `json:"login_salt"`
-	ExpiresAt string `json:"expires_at"`
+	Challenge string           `json:"challenge"`
+	LoginSalt string           `json:"login_salt"`
+	KDF       models.KDFParams `json:"kdf"` // How the login key is derived from the password and the login salt
+	ExpiresAt string           `json:"expires_at"`
 }
 
 // ChallengeHandler generates and sends an authentication challenge
@@ -60,16 +64,18 @@ func ChallengeHandler(w http.ResponseWriter, r *http.Request) {
 	userRepo := db.NewUserRepository(db.GetDB())
 	user, err := userRepo.GetUserByEmail(strings.ToLower(requestBody.Email))
 	if err != nil {
-		// even if no user is found, we still create a challenge with a random salt and send it, this is to prevent user enumeration attacks
-		dummySalt, err := crypto.GenerateSaltBase64(32)
+		// even if no user is found, we still create a challenge and send it, this is to prevent user enumeration attacks.
+		// The salt and the KDF are derived from the email, asking twice gives the same answer like for a real account
+		dummySalt, dummyKDF, err := dummyLoginParams(userRepo, requestBody.Email)
 		if err != nil {
-			log.Printf("Error generating dummy salt: %v", err)
+			log.Printf("Error deriving dummy login parameters: %v", err)
 			http.Error(w, "Unknown error ocurred when generating challange", http.StatusInternalServerError)
 			return
 		}
 		response := ChallengeResponseBody{
 			Challenge: string(challengeValue),
 			LoginSalt: dummySalt,
+			KDF:       dummyKDF,
 			ExpiresAt: expiresAt.Format(time.RFC3339),
 		}
 
@@ -99,9 +105,39 @@ func ChallengeHandler(w http.ResponseWriter, r *http.Request) {
 	response := ChallengeResponseBody{
 		Challenge: string(challengeValue),
 		LoginSalt: user.LoginSalt,
+		KDF:       user.KDF,
 		ExpiresAt: expiresAt.Format(time.RFC3339),
 	}
 
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(response)
 }
+
+// dummyLoginParams derives the login salt and the KDF answered for an unknown email, the same on every request.
+// The KDF is crypto.LegacyKDF for the same share of the emails as of the real accounts, crypto.RecommendedKDF for
+// the others. As the accounts upgrade and the share shrinks, an email only ever moves to the recommended one.
+// Parameters:
+// - userRepo: the repository the share of the legacy accounts is read from
+// - email: the unknown email
+// Returns: the Base64 login salt, the KDF parameters, or an error if the share cannot be read
+func dummyLoginParams(userRepo *db.UserRepository, email string) (string, models.KDFParams, error) {
+	salt, err := auth.EmailDigest("login-salt", email)
+	if err != nil {
+		return "", models.KDFParams{}, err
+	}
+	draw, err := auth.EmailDigest("login-kdf", email)
+	if err != nil {
+		return "", models.KDFParams{}, err
+	}
+	legacyShare, err := userRepo.GetLegacyKDFShare()
+	if err != nil {
+		return "", models.KDFParams{}, err
+	}
+
+	// The first 53 bits of the digest, uniform in [0, 1)
+	kdf := crypto.RecommendedKDF
+	if float64(binary.BigEndian.Uint64(draw)>>11)/(1<<53) < legacyShare {
+		kdf = crypto.LegacyKDF
+	}
+	return base64.StdEncoding.EncodeToString(salt[:crypto.RegistrationSaltSize]), kdf, nil
+}
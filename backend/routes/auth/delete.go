@@ -2,6 +2,7 @@ package routes
 
 import (
 	"backend/db"
+	"backend/notify"
 	"log"
 	"net/http"
 )
@@ -24,14 +25,37 @@ func DeleteUserHandler(w http.ResponseWriter, r *http.Request) {
 	// Initialize the user repository
 	userRepo := db.NewUserRepository(db.GetDB())
 
+	// The address and the notification preferences are gone with the user, read them first
+	user, err := userRepo.GetUserByID(userID)
+	if err != nil {
+		log.Printf("Failed to retrieve user: %v", err)
+		http.Error(w, "Failed to delete user", http.StatusInternalServerError)
+		return
+	}
+	prefs, err := db.NewNotificationRepository(db.GetDB()).GetPreferences(userID)
+	if err != nil {
+		log.Printf("Failed to retrieve the notification preferences of user %d: %v", userID, err)
+		http.Error(w, "Failed to delete user", http.StatusInternalServerError)
+		return
+	}
+
 	// Delete the user by ID
-	err := userRepo.DeleteUserByID(userID)
+	err = userRepo.DeleteUserByID(userID)
 	if err != nil {
 		log.Printf("Failed to delete user: %v", err)
 		http.Error(w, "Failed to delete user", http.StatusInternalServerError)
 		return
 	}
 
+	notify.Emit(&notify.Event{
+		Type:        notify.EventAccountDeletion,
+		UserID:      userID,
+		Email:       user.Email,
+		Name:        user.Name,
+		Details:     requestDetails(r),
+		Preferences: prefs,
+	})
+
 	// Respond with success
 	w.WriteHeader(http.StatusOK)
 	w.Write([]byte("User deleted successfully"))
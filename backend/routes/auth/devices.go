@@ -0,0 +1,294 @@
+package routes
+
+import (
+	"backend/crypto"
+	"backend/db"
+	"backend/models"
+	"backend/notify"
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// maxActiveDevices is the number of device keys a user can have active at once, each block is checked
+// against every active key until one verifies it
+const maxActiveDevices = 16
+
+// EnrollDeviceRequestBody represents the JSON body of a device enrollment
+type EnrollDeviceRequestBody struct {
+	Name       string `json:"name"`        // Label of the device
+	PublicKey  string `json:"public_key"`  // Ed25519 public key generated by the new device
+	EnrolledBy string `json:"enrolled_by"` // Trusted key vouching for the device, the login key or an active device key
+	Signature  string `json:"signature"`   // Signature of the enrollment payload with the enrolling key
+	// Signature of the enrollment payload with the key of the new device, proving it owns the key
+	DeviceSignature string `json:"device_signature"`
+	// Hybrid signature types: the ML-DSA key of the new device, and its signature of the enrollment payload
+	PQPublicKey string `json:"pq_public_key,omitempty"`
+	PQSignature string `json:"pq_signature,omitempty"`
+}
+
+// RevokeDeviceRequestBody represents the JSON body of a device revocation
+type RevokeDeviceRequestBody struct {
+	DeviceID  uint32 `json:"device_id"`
+	RevokedBy string `json:"revoked_by"` // Trusted key revoking the device, the login key or an active device key
+	Signature string `json:"signature"`  // Signature of the revocation payload with the revoking key
+}
+
+// DeviceResponseBody is the response of the enrollment and the revocation of a device
+type DeviceResponseBody struct {
+	Message string            `json:"message"`
+	Device  *models.DeviceKey `json:"device,omitempty"`
+}
+
+// ListDevicesHandler returns every device key of the logged in user, the revoked ones included
+func ListDevicesHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+
+	userID, ok := r.Context().Value("UserID").(uint32)
+	if !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	deviceRepo := db.NewDeviceRepository(db.GetDB())
+	devices, err := deviceRepo.GetDevices(userID, false)
+	if err != nil {
+		log.Printf("Error retrieving devices of user %d: %v", userID, err)
+		writeJSONError(w, "Error retrieving devices", http.StatusInternalServerError)
+		return
+	}
+
+	err = json.NewEncoder(w).Encode(devices)
+	if err != nil {
+		log.Printf("Error encoding response: %v", err)
+	}
+}
+
+// EnrollDeviceHandler enrolls the signing key of a new device of the logged in user.
+// The new device generates its key and shows it to a device the user already trusts, which signs the enrollment
+// with the login key or its own device key. Once enrolled, the blocks signed by the device are accepted.
+func EnrollDeviceHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+
+	userID, ok := r.Context().Value("UserID").(uint32)
+	if !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	var request EnrollDeviceRequestBody
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		log.Printf("Error parsing request body: %v", err)
+		writeJSONError(w, "Invalid request format", http.StatusBadRequest)
+		return
+	}
+	if err := validateEnrollDeviceRequest(request); err != nil {
+		writeJSONError(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	userRepo := db.NewUserRepository(db.GetDB())
+	user, err := userRepo.GetUserByID(userID)
+	if err != nil {
+		log.Printf("Error retrieving user: %v", err)
+		writeJSONError(w, "Error retrieving user", http.StatusInternalServerError)
+		return
+	}
+
+	// The devices of the hybrid accounts sign their blocks with both schemes too
+	if err := validateSignatureKeys(user.SignatureType, &request.PQPublicKey); err != nil {
+		writeJSONError(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if request.PublicKey == user.PubKey {
+		writeJSONError(w, "The login key cannot be enrolled as a device", http.StatusBadRequest)
+		return
+	}
+
+	deviceRepo := db.NewDeviceRepository(db.GetDB())
+	devices, err := deviceRepo.GetDevices(userID, true)
+	if err != nil {
+		log.Printf("Error retrieving devices of user %d: %v", userID, err)
+		writeJSONError(w, "Error retrieving devices", http.StatusInternalServerError)
+		return
+	}
+	if len(devices) >= maxActiveDevices {
+		writeJSONError(w, "Too many active devices, revoke one first", http.StatusConflict)
+		return
+	}
+
+	// Only the login key and the active device keys can vouch for a new device
+	trusted := request.EnrolledBy == user.PubKey
+	for _, device := range devices {
+		trusted = trusted || request.EnrolledBy == device.PubKey
+	}
+	if !trusted {
+		writeJSONError(w, "The enrolling key is not an active key of the account", http.StatusForbidden)
+		return
+	}
+
+	payload := crypto.DeviceEnrollmentPayload(userID, request.EnrolledBy, request.PublicKey, request.PQPublicKey, request.Name)
+	valid, err := crypto.VerifyDeviceEnrollmentEd25519Signature(request.EnrolledBy, payload, request.Signature)
+	if err != nil || !valid {
+		writeJSONError(w, "Invalid signature of the enrolling key", http.StatusUnauthorized)
+		return
+	}
+	valid, err = crypto.VerifyDeviceEnrollmentEd25519Signature(request.PublicKey, payload, request.DeviceSignature)
+	if err != nil || !valid {
+		writeJSONError(w, "Invalid signature of the device key", http.StatusBadRequest)
+		return
+	}
+	if crypto.IsHybridSignature(user.SignatureType) {
+		valid, err = crypto.VerifyMLDSASignature(request.PQPublicKey, payload, request.PQSignature)
+		if err != nil || !valid {
+			writeJSONError(w, "Invalid signature of the ML-DSA device key", http.StatusBadRequest)
+			return
+		}
+	}
+
+	device := &models.DeviceKey{
+		Name:       request.Name,
+		PubKey:     request.PublicKey,
+		PQPubKey:   request.PQPublicKey,
+		EnrolledBy: request.EnrolledBy,
+		Signature:  request.Signature,
+		CreatedAt:  time.Now().UTC(),
+	}
+	device.ID, err = deviceRepo.CreateDevice(userID, device)
+	switch {
+	case errors.Is(err, db.ErrDeviceExists):
+		writeJSONError(w, "This key is already enrolled", http.StatusConflict)
+		return
+	case err != nil:
+		log.Printf("Error enrolling a device for user %d: %v", userID, err)
+		writeJSONError(w, "Error enrolling the device", http.StatusInternalServerError)
+		return
+	}
+
+	details := requestDetails(r)
+	details["device"] = device.Name
+	notify.Emit(&notify.Event{Type: notify.EventNewDevice, UserID: userID, Details: details})
+
+	w.WriteHeader(http.StatusCreated)
+	err = json.NewEncoder(w).Encode(DeviceResponseBody{
+		Message: "Device enrolled successfully",
+		Device:  device,
+	})
+	if err != nil {
+		log.Printf("Error encoding response: %v", err)
+	}
+}
+
+// RevokeDeviceHandler revokes a device key of the logged in user, typically a lost device.
+// Like an enrollment, the revocation is signed by the login key or an active device key, a stolen session alone
+// cannot remove the devices of the user. The revoked key can no longer sign blocks nor enroll devices, the blocks
+// it signed before keep verifying.
+func RevokeDeviceHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+
+	userID, ok := r.Context().Value("UserID").(uint32)
+	if !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	var request RevokeDeviceRequestBody
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil || request.DeviceID == 0 ||
+		request.RevokedBy == "" || request.Signature == "" {
+		writeJSONError(w, "Invalid request format", http.StatusBadRequest)
+		return
+	}
+
+	userRepo := db.NewUserRepository(db.GetDB())
+	user, err := userRepo.GetUserByID(userID)
+	if err != nil {
+		log.Printf("Error retrieving user: %v", err)
+		writeJSONError(w, "Error retrieving user", http.StatusInternalServerError)
+		return
+	}
+
+	deviceRepo := db.NewDeviceRepository(db.GetDB())
+	devices, err := deviceRepo.GetDevices(userID, true)
+	if err != nil {
+		log.Printf("Error retrieving devices of user %d: %v", userID, err)
+		writeJSONError(w, "Error retrieving devices", http.StatusInternalServerError)
+		return
+	}
+
+	// Only the login key and the active device keys can revoke a device
+	var revoked *models.DeviceKey
+	trusted := request.RevokedBy == user.PubKey
+	for _, device := range devices {
+		trusted = trusted || request.RevokedBy == device.PubKey
+		if device.ID == request.DeviceID {
+			revoked = device
+		}
+	}
+	if revoked == nil {
+		writeJSONError(w, "Device not found", http.StatusNotFound)
+		return
+	}
+	if !trusted {
+		writeJSONError(w, "The revoking key is not an active key of the account", http.StatusForbidden)
+		return
+	}
+
+	payload := crypto.DeviceRevocationPayload(userID, request.RevokedBy, revoked.PubKey)
+	valid, err := crypto.VerifyDeviceRevocationEd25519Signature(request.RevokedBy, payload, request.Signature)
+	if err != nil || !valid {
+		writeJSONError(w, "Invalid signature of the revoking key", http.StatusUnauthorized)
+		return
+	}
+
+	err = deviceRepo.RevokeDevice(userID, request.DeviceID)
+	switch {
+	case errors.Is(err, db.ErrDeviceNotFound):
+		writeJSONError(w, "Device not found", http.StatusNotFound)
+		return
+	case err != nil:
+		log.Printf("Error revoking device %d of user %d: %v", request.DeviceID, userID, err)
+		writeJSONError(w, "Error revoking the device", http.StatusInternalServerError)
+		return
+	}
+
+	details := requestDetails(r)
+	details["device"] = revoked.Name
+	notify.Emit(&notify.Event{Type: notify.EventDeviceRevoked, UserID: userID, Email: user.Email, Name: user.Name, Details: details})
+
+	err = json.NewEncoder(w).Encode(DeviceResponseBody{Message: "Device revoked successfully"})
+	if err != nil {
+		log.Printf("Error encoding response: %v", err)
+	}
+}
+
+// validateEnrollDeviceRequest validates the fields of a device enrollment
+func validateEnrollDeviceRequest(request EnrollDeviceRequestBody) error {
+	switch {
+	case strings.TrimSpace(request.Name) == "" || request.PublicKey == "" || request.EnrolledBy == "" ||
+		request.Signature == "" || request.DeviceSignature == "":
+		return errors.New("Required fields are missing")
+	case len(request.Name) > 255:
+		return errors.New("name must be at most 255 characters")
+	case len(request.EnrolledBy) != 44:
+		return errors.New("public key must be encoded in base64")
+	}
+	return crypto.ValidateEd25519PublicKey(request.PublicKey)
+}
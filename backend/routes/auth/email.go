@@ -0,0 +1,326 @@
+package routes
+
+import (
+	"backend/auth"
+	"backend/config"
+	"backend/crypto"
+	"backend/db"
+	"backend/mail"
+	"backend/models"
+	"backend/notify"
+	"backend/util"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// Statuses of a confirmed email token
+const (
+	EmailStatusVerified = "verified" // The address of the account is verified
+	EmailStatusPending  = "pending"  // The email change waits for the confirmation of the other address
+	EmailStatusChanged  = "changed"  // Both addresses confirmed, the new address replaced the old one
+)
+
+// errEmailUnchanged is returned when an email change asks for the current address
+var errEmailUnchanged = errors.New("the new email is the current one")
+
+// ConfirmEmailRequestBody represents the JSON body with the token of a verification or email change link
+type ConfirmEmailRequestBody struct {
+	Token string `json:"token"`
+}
+
+// ConfirmEmailResponseBody represents the JSON response of a confirmed token
+type ConfirmEmailResponseBody struct {
+	Status  string `json:"status"`
+	Message string `json:"message"`
+}
+
+// EmailRequestBody represents a JSON body holding an email address
+type EmailRequestBody struct {
+	Email string `json:"email"`
+}
+
+// EmailChangeResponseBody represents the JSON response of a started email change
+type EmailChangeResponseBody struct {
+	Message      string    `json:"message"`
+	PendingEmail string    `json:"pending_email"`
+	ExpiresAt    time.Time `json:"expires_at"`
+}
+
+// ConfirmEmailHandler applies the token of a link sent by email: it verifies the address of an account, or
+// confirms an email change from one of its two addresses. The token is the proof, no session is needed.
+func ConfirmEmailHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+
+	var request ConfirmEmailRequestBody
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil || request.Token == "" {
+		writeJSONError(w, "Invalid request format", http.StatusBadRequest)
+		return
+	}
+
+	claims, err := auth.VerifyEmailToken(request.Token, auth.EmailTokenVerify, auth.EmailTokenChangeOld, auth.EmailTokenChangeNew)
+	if err != nil {
+		writeJSONError(w, "Invalid or expired link", http.StatusUnauthorized)
+		return
+	}
+
+	userRepo := db.NewUserRepository(db.GetDB())
+	response := ConfirmEmailResponseBody{}
+	if claims.Purpose == auth.EmailTokenVerify {
+		if err := userRepo.MarkEmailVerified(claims.UserID, claims.Email); err != nil {
+			log.Printf("Error verifying the email of user %d: %v", claims.UserID, err)
+			writeJSONError(w, "Error verifying the email", http.StatusInternalServerError)
+			return
+		}
+		response.Status = EmailStatusVerified
+		response.Message = "Email verified"
+	} else {
+		user, err := userRepo.GetUserByID(claims.UserID)
+		if err != nil {
+			writeJSONError(w, "The email change was cancelled, replaced or expired", http.StatusNotFound)
+			return
+		}
+		changed, err := userRepo.ConfirmEmailChange(claims.UserID, claims.Nonce, claims.Purpose == auth.EmailTokenChangeNew)
+		switch {
+		case errors.Is(err, db.ErrEmailChangeNotFound):
+			writeJSONError(w, "The email change was cancelled, replaced or expired", http.StatusNotFound)
+			return
+		case errors.Is(err, db.ErrEmailTaken):
+			writeJSONError(w, "Email already in use", http.StatusConflict)
+			return
+		case err != nil:
+			log.Printf("Error confirming the email change of user %d: %v", claims.UserID, err)
+			writeJSONError(w, "Error confirming the email change", http.StatusInternalServerError)
+			return
+		}
+		response.Status = EmailStatusPending
+		response.Message = "Confirmed, the email changes once the other address confirms too"
+		if changed {
+			response.Status = EmailStatusChanged
+			response.Message = "Email changed"
+			// The old address is told, it may belong to the owner of a hijacked account
+			notify.Emit(&notify.Event{
+				Type:    notify.EventEmailChange,
+				UserID:  user.ID,
+				Email:   user.Email,
+				Name:    user.Name,
+				Details: map[string]string{"new_email": user.PendingEmail},
+			})
+		}
+	}
+
+	if err := json.NewEncoder(w).Encode(response); err != nil {
+		log.Printf("Error encoding response: %v", err)
+		http.Error(w, "Error encoding response", http.StatusInternalServerError)
+		return
+	}
+}
+
+// ResendVerificationHandler sends the verification email of an account again. The response is the same whether
+// the account exists, is already verified or not, so it cannot be used to look up the registered addresses.
+func ResendVerificationHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+
+	var request EmailRequestBody
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		writeJSONError(w, "Invalid request format", http.StatusBadRequest)
+		return
+	}
+	if err := util.ValidateEmail(request.Email); err != nil {
+		writeJSONError(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	user, err := db.NewUserRepository(db.GetDB()).GetUserByEmail(strings.ToLower(request.Email))
+	if err == nil && !user.EmailVerified {
+		if err := sendVerificationEmail(user.ID, user.Email); err != nil {
+			log.Printf("Error sending the verification email of user %d: %v", user.ID, err)
+		}
+	}
+
+	json.NewEncoder(w).Encode(map[string]string{
+		"message": "If the account exists and is not verified, a new link was sent",
+	})
+}
+
+// ChangeEmailHandler starts the change of the email of the user, which only applies once both the current and
+// the new address confirmed it
+func ChangeEmailHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+
+	userID, ok := r.Context().Value("UserID").(uint32)
+	if !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	var request EmailRequestBody
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		writeJSONError(w, "Invalid request format", http.StatusBadRequest)
+		return
+	}
+	if err := util.ValidateEmail(request.Email); err != nil {
+		writeJSONError(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	user, err := db.NewUserRepository(db.GetDB()).GetUserByID(userID)
+	if err != nil {
+		log.Printf("Error retrieving user: %v", err)
+		writeJSONError(w, "Error retrieving user", http.StatusInternalServerError)
+		return
+	}
+
+	expiresAt, status, err := startEmailChange(user, request.Email)
+	if err != nil {
+		writeJSONError(w, err.Error(), status)
+		return
+	}
+
+	err = json.NewEncoder(w).Encode(EmailChangeResponseBody{
+		Message:      "Confirm the change from both the current and the new address",
+		PendingEmail: user.PendingEmail,
+		ExpiresAt:    expiresAt,
+	})
+	if err != nil {
+		log.Printf("Error encoding response: %v", err)
+		http.Error(w, "Error encoding response", http.StatusInternalServerError)
+		return
+	}
+}
+
+// CancelEmailChangeHandler drops the pending email change of the user
+func CancelEmailChangeHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+
+	userID, ok := r.Context().Value("UserID").(uint32)
+	if !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	if err := db.NewUserRepository(db.GetDB()).CancelEmailChange(userID); err != nil {
+		log.Printf("Error cancelling the email change of user %d: %v", userID, err)
+		writeJSONError(w, "Error cancelling the email change", http.StatusInternalServerError)
+		return
+	}
+
+	json.NewEncoder(w).Encode(map[string]string{"message": "Email change cancelled"})
+}
+
+// startEmailChange stores a pending email change and mails its confirmation links to both addresses. The current
+// address is only asked when it was verified, see UserRepository.StartEmailChange.
+// Parameters:
+// - user: a pointer to the user, its PendingEmail is set on success
+// - email: the new address, already validated
+// Returns: when the change expires, or the HTTP status and the error to report
+func startEmailChange(user *models.User, email string) (time.Time, int, error) {
+	email = strings.ToLower(email)
+	if email == user.Email {
+		return time.Time{}, http.StatusBadRequest, errEmailUnchanged
+	}
+
+	userRepo := db.NewUserRepository(db.GetDB())
+	existingUser, err := userRepo.GetUserByEmail(email)
+	if err == nil && existingUser.ID != user.ID {
+		return time.Time{}, http.StatusConflict, db.ErrEmailTaken
+	}
+
+	nonce, err := crypto.GenerateIDHex(16)
+	if err != nil {
+		log.Printf("Error generating the email change nonce: %v", err)
+		return time.Time{}, http.StatusInternalServerError, errors.New("error starting the email change")
+	}
+	ttl := time.Duration(config.GetConfig().EmailTokenHours) * time.Hour
+	expiresAt := time.Now().UTC().Add(ttl)
+	if err := userRepo.StartEmailChange(user.ID, email, nonce, expiresAt); err != nil {
+		log.Printf("Error starting the email change of user %d: %v", user.ID, err)
+		return time.Time{}, http.StatusInternalServerError, errors.New("error starting the email change")
+	}
+
+	newLink, err := emailLink(auth.EmailTokenChangeNew, user.ID, email, nonce, ttl)
+	if err == nil {
+		err = mail.Send(&mail.Message{
+			To:      email,
+			Subject: "Confirm your new email address",
+			Body: fmt.Sprintf("Hello %s,\n\nA change of the email of your account to this address was requested. "+
+				"Open this link to confirm it:\n\n%s\n\nThe link expires on %s.\n",
+				user.Name, newLink, expiresAt.Format(time.RFC1123)),
+		})
+	}
+	if err == nil && user.EmailVerified {
+		var oldLink string
+		oldLink, err = emailLink(auth.EmailTokenChangeOld, user.ID, user.Email, nonce, ttl)
+		if err == nil {
+			err = mail.Send(&mail.Message{
+				To:      user.Email,
+				Subject: "Confirm the change of your email address",
+				Body: fmt.Sprintf("Hello %s,\n\nA change of the email of your account to %s was requested. "+
+					"Open this link to confirm it:\n\n%s\n\nIf you did not ask for it, do not open the link: the "+
+					"change needs the confirmation of both addresses. Change your password and cancel the change "+
+					"from your account instead.\n",
+					user.Name, email, oldLink),
+			})
+		}
+	}
+	if err != nil {
+		log.Printf("Error sending the email change confirmations of user %d: %v", user.ID, err)
+		return time.Time{}, http.StatusBadGateway, errors.New("error sending the confirmation emails, try again later")
+	}
+
+	user.PendingEmail = email
+	return expiresAt, http.StatusOK, nil
+}
+
+// sendVerificationEmail mails the verification link of the address of an account.
+// Parameters:
+// - userID: the ID of the user
+// - email: the address to verify
+// Returns: an error if the token cannot be signed or the email cannot be sent
+func sendVerificationEmail(userID uint32, email string) error {
+	ttl := time.Duration(config.GetConfig().EmailTokenHours) * time.Hour
+	link, err := emailLink(auth.EmailTokenVerify, userID, email, "", ttl)
+	if err != nil {
+		return err
+	}
+	return mail.Send(&mail.Message{
+		To:      email,
+		Subject: "Verify your email address",
+		Body: fmt.Sprintf("Welcome,\n\nOpen this link to verify the email address of your account:\n\n%s\n\n"+
+			"If you did not create an account, ignore this email.\n", link),
+	})
+}
+
+// emailLink builds the link of the frontend that confirms an email token, see auth.GenerateEmailToken
+func emailLink(purpose string, userID uint32, email, nonce string, ttl time.Duration) (string, error) {
+	token, err := auth.GenerateEmailToken(purpose, userID, email, nonce, ttl)
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimRight(config.GetConfig().AppURL, "/") + "/confirm-email?token=" + url.QueryEscape(token), nil
+}
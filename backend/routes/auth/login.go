@@ -6,6 +6,7 @@ import (
 	"backend/crypto"
 	"backend/db"
 	"backend/models"
+	"backend/notify"
 	"backend/util"
 	"encoding/json"
 	"log"
@@ -27,6 +28,10 @@ type LoginRequestBody struct {
 type LoginResponseBody struct {
 	Message string      `json:"message"`
 	User    models.User `json:"user"`
+	// Stronger KDF parameters the client should upgrade the login key to, see UpgradeKDFHandler
+	KDFUpgrade *models.KDFParams `json:"kdf_upgrade,omitempty"`
+	// Social recoveries of the account waiting for their delay, the user cancels the ones they did not start
+	PendingRecoveries []*models.RecoveryRequest `json:"pending_recoveries,omitempty"`
 }
 
 // LoginHandler verifies the signed challenge and issues a JWT token on success
@@ -102,15 +107,22 @@ func LoginHandler(w http.ResponseWriter, r *http.Request) {
 
 	if err != nil {
 		log.Printf("Signature verification error: %v", err)
+		notify.RecordFailedSignature(user.ID, requestDetails(r))
 		http.Error(w, "Signature verification failed", http.StatusUnauthorized)
 		return
 	}
 
 	if !valid {
+		notify.RecordFailedSignature(user.ID, requestDetails(r))
 		http.Error(w, "Invalid signature", http.StatusUnauthorized)
 		return
 	}
 
+	if !user.EmailVerified && config.GetConfig().UnverifiedAccess == config.UnverifiedAccessNone {
+		http.Error(w, "Verify your email address before logging in", http.StatusForbidden)
+		return
+	}
+
 	// Delete the used challenge
 	err = challengeRepo.DeleteChallenge(challenge.ID)
 	if err != nil {
@@ -119,6 +131,16 @@ func LoginHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	// A login from a client the user never logged in from is notified
+	details := requestDetails(r)
+	notificationRepo := db.NewNotificationRepository(db.GetDB())
+	newSource, err := notificationRepo.RecordLoginSource(user.ID, loginFingerprint(details), time.Now().UTC())
+	if err != nil {
+		log.Printf("Error recording the login source of user %d: %v", user.ID, err)
+	} else if newSource {
+		notify.Emit(&notify.Event{Type: notify.EventNewLogin, UserID: user.ID, Email: user.Email, Name: user.Name, Details: details})
+	}
+
 	// Generate a JWT token
 	token, err := auth.GenerateJWTToken(user.ID, user.HMACType)
 	if err != nil {
@@ -151,6 +173,14 @@ func LoginHandler(w http.ResponseWriter, r *http.Request) {
 		Message: "Login successful",
 		User:    *user,
 	}
+	if crypto.IsKDFUpgrade(user.KDF, crypto.RecommendedKDF) {
+		recommended := crypto.RecommendedKDF
+		response.KDFUpgrade = &recommended
+	}
+	recoveryRepo := db.NewSocialRecoveryRepository(db.GetDB())
+	if response.PendingRecoveries, err = recoveryRepo.GetPendingRequests(user.ID); err != nil {
+		log.Printf("Error retrieving the recoveries of user %d: %v", user.ID, err)
+	}
 
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(response)
@@ -0,0 +1,203 @@
+package routes
+
+import (
+	"backend/config"
+	"backend/crypto"
+	"backend/db"
+	"backend/models"
+	"backend/notify"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net"
+	"net/http"
+	"slices"
+)
+
+// recentDeliveries is the number of notification deliveries shown with the preferences
+const recentDeliveries = 20
+
+// NotificationPreferencesResponseBody represents the JSON response with the notification settings of the user
+type NotificationPreferencesResponseBody struct {
+	Preferences    *models.NotificationPreferences `json:"preferences"`
+	EventTypes     []string                        `json:"event_types"`
+	CriticalEvents []string                        `json:"critical_events"` // Always emailed, they cannot be muted
+	// Latest deliveries of the notifications of the user, with their retries
+	Deliveries []*models.NotificationDelivery `json:"deliveries"`
+}
+
+// UpdateNotificationPreferencesRequestBody represents the JSON body replacing the notification preferences
+type UpdateNotificationPreferencesRequestBody struct {
+	EmailEnabled bool     `json:"email_enabled"`
+	WebhookURL   string   `json:"webhook_url"`
+	MutedEvents  []string `json:"muted_events"`
+	// Generate a new webhook secret, a new secret is also generated when the webhook URL changes
+	RotateWebhookSecret bool `json:"rotate_webhook_secret"`
+}
+
+// NotificationPreferencesHandler returns the notification preferences of the user, the event types and the
+// latest deliveries
+func NotificationPreferencesHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+
+	userID, ok := r.Context().Value("UserID").(uint32)
+	if !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	notificationRepo := db.NewNotificationRepository(db.GetDB())
+	prefs, err := notificationRepo.GetPreferences(userID)
+	if err != nil {
+		log.Printf("Error retrieving the notification preferences of user %d: %v", userID, err)
+		writeJSONError(w, "Error retrieving the notification preferences", http.StatusInternalServerError)
+		return
+	}
+	prefs.WebhookSecret = ""
+	deliveries, err := notificationRepo.GetRecentDeliveries(userID, recentDeliveries)
+	if err != nil {
+		log.Printf("Error retrieving the notifications of user %d: %v", userID, err)
+		writeJSONError(w, "Error retrieving the notification preferences", http.StatusInternalServerError)
+		return
+	}
+
+	err = json.NewEncoder(w).Encode(NotificationPreferencesResponseBody{
+		Preferences:    prefs,
+		EventTypes:     notify.EventTypes,
+		CriticalEvents: criticalEventTypes(),
+		Deliveries:     deliveries,
+	})
+	if err != nil {
+		log.Printf("Error encoding response: %v", err)
+	}
+}
+
+// UpdateNotificationPreferencesHandler replaces the notification preferences of the user. The webhook secret is
+// only returned when a new one is generated.
+func UpdateNotificationPreferencesHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPut {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+
+	userID, ok := r.Context().Value("UserID").(uint32)
+	if !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	var request UpdateNotificationPreferencesRequestBody
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		log.Printf("Error parsing request body: %v", err)
+		writeJSONError(w, "Invalid request format", http.StatusBadRequest)
+		return
+	}
+	if err := validateNotificationPreferences(request); err != nil {
+		writeJSONError(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	notificationRepo := db.NewNotificationRepository(db.GetDB())
+	current, err := notificationRepo.GetPreferences(userID)
+	if err != nil {
+		log.Printf("Error retrieving the notification preferences of user %d: %v", userID, err)
+		writeJSONError(w, "Error updating the notification preferences", http.StatusInternalServerError)
+		return
+	}
+
+	prefs := &models.NotificationPreferences{
+		EmailEnabled:  request.EmailEnabled,
+		WebhookURL:    request.WebhookURL,
+		MutedEvents:   []string{},
+		WebhookSecret: current.WebhookSecret,
+	}
+	for _, event := range request.MutedEvents {
+		if !slices.Contains(prefs.MutedEvents, event) {
+			prefs.MutedEvents = append(prefs.MutedEvents, event)
+		}
+	}
+	newSecret := false
+	if prefs.WebhookURL == "" {
+		prefs.WebhookSecret = ""
+	} else if prefs.WebhookSecret == "" || prefs.WebhookURL != current.WebhookURL || request.RotateWebhookSecret {
+		if prefs.WebhookSecret, err = crypto.GenerateIDHex(32); err != nil {
+			log.Printf("Error generating a webhook secret: %v", err)
+			writeJSONError(w, "Error updating the notification preferences", http.StatusInternalServerError)
+			return
+		}
+		newSecret = true
+	}
+
+	if err := notificationRepo.SavePreferences(userID, prefs); err != nil {
+		log.Printf("Error storing the notification preferences of user %d: %v", userID, err)
+		writeJSONError(w, "Error updating the notification preferences", http.StatusInternalServerError)
+		return
+	}
+
+	if !newSecret {
+		prefs.WebhookSecret = ""
+	}
+	err = json.NewEncoder(w).Encode(map[string]any{
+		"message":     "Notification preferences updated successfully",
+		"preferences": prefs,
+	})
+	if err != nil {
+		log.Printf("Error encoding response: %v", err)
+	}
+}
+
+// validateNotificationPreferences validates the muted events and the webhook URL of new preferences
+func validateNotificationPreferences(request UpdateNotificationPreferencesRequestBody) error {
+	for _, event := range request.MutedEvents {
+		if !notify.IsValidEventType(event) {
+			return fmt.Errorf("unknown event type %q", event)
+		}
+		if notify.IsCritical(event) {
+			return fmt.Errorf("the %s event cannot be muted", event)
+		}
+	}
+	if request.WebhookURL != "" {
+		if err := notify.ValidateWebhookURL(request.WebhookURL, config.GetConfig().WebhookAllowPrivate); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// criticalEventTypes lists the event types that cannot be muted
+func criticalEventTypes() []string {
+	events := []string{}
+	for _, event := range notify.EventTypes {
+		if notify.IsCritical(event) {
+			events = append(events, event)
+		}
+	}
+	return events
+}
+
+// requestDetails describes the client of a request in the security events: its IP address and its user agent
+func requestDetails(r *http.Request) map[string]string {
+	ip, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		ip = r.RemoteAddr
+	}
+	return map[string]string{
+		"ip":         ip,
+		"user_agent": r.UserAgent(),
+	}
+}
+
+// loginFingerprint identifies the client of a login, the hex SHA-256 of its IP address and its user agent
+func loginFingerprint(details map[string]string) string {
+	hash := sha256.Sum256([]byte(details["ip"] + "\x00" + details["user_agent"]))
+	return hex.EncodeToString(hash[:])
+}
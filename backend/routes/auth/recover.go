@@ -0,0 +1,247 @@
+package routes
+
+import (
+	"backend/auth"
+	"backend/crypto"
+	"backend/db"
+	"backend/models"
+	"backend/notify"
+	"backend/util"
+	"crypto/ed25519"
+	"crypto/hkdf"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"strings"
+)
+
+// RecoveryStartRequestBody represents the JSON body of the start of a recovery
+type RecoveryStartRequestBody struct {
+	Email string `json:"email"`
+}
+
+// RecoveryStartResponseBody holds what the client needs to open the escrowed keys and sign the recovery
+type RecoveryStartResponseBody struct {
+	PublicKey      string `json:"public_key"` // Current login key, named by the recovery payload
+	EncryptionSalt string `json:"encryption_salt"`
+	HMACSalt       string `json:"hmac_salt"`
+	HMACType       string `json:"hmac_type"`
+	EncryptionType string `json:"encryption_type"`
+	SuiteID        uint16 `json:"suite_id"`
+	SignatureType  string `json:"signature_type"`
+	RecoveryEscrow string `json:"recovery_escrow"` // Encryption and HMAC keys sealed under the recovery key
+}
+
+// RecoverRequestBody represents the JSON body of a recovery
+type RecoverRequestBody struct {
+	Email        string           `json:"email"`
+	KDF          models.KDFParams `json:"kdf"`           // KDF parameters of the new login key
+	LoginSalt    string           `json:"login_salt"`    // New login salt
+	PublicKey    string           `json:"public_key"`    // Public key derived from the new password
+	WrappedKeys  string           `json:"wrapped_keys"`  // Encryption and HMAC keys sealed under the new password
+	Signature    string           `json:"signature"`     // Signature of the recovery payload with the recovery key
+	NewSignature string           `json:"new_signature"` // Signature of the recovery payload with the new login key
+	// Hybrid signature types: the ML-DSA key derived from the new login key, and its signature of the recovery payload
+	PQPublicKey string `json:"pq_public_key,omitempty"`
+	PQSignature string `json:"pq_signature,omitempty"`
+}
+
+// RecoveryStartHandler returns the escrowed keys of an account, sealed under its offline recovery key.
+// Like the challenge, it answers with values derived from the email for an unknown email or an account without a
+// recovery key, the same on every request, so it cannot be used to find out which emails are registered.
+func RecoveryStartHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+
+	var request RecoveryStartRequestBody
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		writeJSONError(w, "Invalid request format", http.StatusBadRequest)
+		return
+	}
+	if err := util.ValidateEmail(request.Email); err != nil {
+		writeJSONError(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	userRepo := db.NewUserRepository(db.GetDB())
+	user, err := userRepo.GetUserByEmail(strings.ToLower(request.Email))
+	if err != nil || user.RecoveryPubKey == "" {
+		response, err := dummyRecoveryStart(request.Email)
+		if err != nil {
+			log.Printf("Error generating dummy recovery values: %v", err)
+			writeJSONError(w, "Unknown error ocurred when starting the recovery", http.StatusInternalServerError)
+			return
+		}
+		json.NewEncoder(w).Encode(response)
+		return
+	}
+
+	response := RecoveryStartResponseBody{
+		PublicKey:      user.PubKey,
+		EncryptionSalt: user.EncryptionSalt,
+		HMACSalt:       user.HMACSalt,
+		HMACType:       user.HMACType,
+		EncryptionType: user.EncryptionType,
+		SuiteID:        user.SuiteID,
+		SignatureType:  user.SignatureType,
+		RecoveryEscrow: user.RecoveryEscrow,
+	}
+	err = json.NewEncoder(w).Encode(response)
+	if err != nil {
+		log.Printf("Error encoding response: %v", err)
+	}
+}
+
+// RecoverHandler re-keys an account to a new password with its offline recovery key.
+// The client opens the escrowed encryption and HMAC keys with the recovery key, seals them under the new password
+// and derives a new login key from it. The recovery key signs the change, proving its possession, and the new
+// login key signs it too, proving it is the client's own. The notes stay readable, and the blocks keep the keys
+// that signed them so the existing chains still verify.
+func RecoverHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+
+	var request RecoverRequestBody
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		log.Printf("Error parsing request body: %v", err)
+		writeJSONError(w, "Invalid request format", http.StatusBadRequest)
+		return
+	}
+	if err := validateRecoverRequest(request); err != nil {
+		writeJSONError(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	// An unknown email and an account without a recovery key get the same answer as a wrong recovery key
+	userRepo := db.NewUserRepository(db.GetDB())
+	user, err := userRepo.GetUserByEmail(strings.ToLower(request.Email))
+	if err != nil || user.RecoveryPubKey == "" {
+		writeJSONError(w, "Invalid signature of the recovery key", http.StatusUnauthorized)
+		return
+	}
+
+	suite, err := crypto.SuiteOf(user)
+	if err != nil {
+		log.Printf("Error resolving the crypto suite of user %d: %v", user.ID, err)
+		writeJSONError(w, "Unsupported crypto suite", http.StatusInternalServerError)
+		return
+	}
+	if err := crypto.ValidateKeyEnvelope(request.WrappedKeys, suite); err != nil {
+		writeJSONError(w, "Invalid wrapped keys: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	if err := validateSignatureKeys(user.SignatureType, &request.PQPublicKey); err != nil {
+		writeJSONError(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	payload := crypto.RecoveryPayload(user.Email, user.PubKey, request.PublicKey, request.PQPublicKey, request.LoginSalt,
+		request.KDF, request.WrappedKeys)
+	valid, err := crypto.VerifyRecoveryEd25519Signature(user.RecoveryPubKey, payload, request.Signature)
+	if err != nil || !valid {
+		writeJSONError(w, "Invalid signature of the recovery key", http.StatusUnauthorized)
+		return
+	}
+	valid, err = crypto.VerifyRecoveryEd25519Signature(request.PublicKey, payload, request.NewSignature)
+	if err != nil || !valid {
+		writeJSONError(w, "Invalid signature of the new login key", http.StatusBadRequest)
+		return
+	}
+	if crypto.IsHybridSignature(user.SignatureType) {
+		valid, err = crypto.VerifyMLDSASignature(request.PQPublicKey, payload, request.PQSignature)
+		if err != nil || !valid {
+			writeJSONError(w, "Invalid signature of the new ML-DSA key", http.StatusBadRequest)
+			return
+		}
+	}
+
+	err = userRepo.RecoverLoginKey(user.ID, user.PubKey, request.PublicKey, request.PQPublicKey, request.LoginSalt,
+		request.KDF, request.WrappedKeys)
+	switch {
+	case errors.Is(err, db.ErrLoginKeyChanged):
+		writeJSONError(w, "The login key changed in the meantime, start the recovery again", http.StatusConflict)
+		return
+	case err != nil:
+		log.Printf("Error recovering the login key of user %d: %v", user.ID, err)
+		writeJSONError(w, "Error recovering the account", http.StatusInternalServerError)
+		return
+	}
+
+	// The recovery key may have been rebuilt by a social recovery, it is over
+	recoveryRepo := db.NewSocialRecoveryRepository(db.GetDB())
+	if err := recoveryRepo.CompleteRequests(user.ID); err != nil {
+		log.Printf("Error completing the social recoveries of user %d: %v", user.ID, err)
+	}
+
+	details := requestDetails(r)
+	details["change"] = "password reset with the recovery key"
+	notify.Emit(&notify.Event{Type: notify.EventKeyRotation, UserID: user.ID, Email: user.Email, Name: user.Name, Details: details})
+
+	err = json.NewEncoder(w).Encode(map[string]string{"message": "Account recovered successfully, log in with the new password"})
+	if err != nil {
+		log.Printf("Error encoding response: %v", err)
+	}
+}
+
+// validateRecoverRequest validates the fields of a recovery
+func validateRecoverRequest(request RecoverRequestBody) error {
+	switch {
+	case request.Email == "" || request.LoginSalt == "" || request.PublicKey == "" || request.WrappedKeys == "" ||
+		request.Signature == "" || request.NewSignature == "":
+		return errors.New("Required fields are missing")
+	}
+	if err := util.ValidateEmail(request.Email); err != nil {
+		return err
+	}
+	if err := crypto.ValidateSalt("login salt", request.LoginSalt); err != nil {
+		return err
+	}
+	if err := crypto.ValidateEd25519PublicKey(request.PublicKey); err != nil {
+		return err
+	}
+	return crypto.ValidateKDFParams(request.KDF)
+}
+
+// dummyRecoveryStart builds the answer of RecoveryStartHandler for the accounts that cannot be recovered, shaped
+// like an AES-256-GCM account. Every value is derived from the email, and the public key is a valid Ed25519 key.
+func dummyRecoveryStart(email string) (*RecoveryStartResponseBody, error) {
+	suite, err := crypto.SuiteFor(crypto.EncryptionAES256GCM, crypto.HMACSHA256)
+	if err != nil {
+		return nil, err
+	}
+	purposes := []string{"recovery-key", "recovery-encryption-salt", "recovery-hmac-salt", "recovery-escrow"}
+	sizes := []int{ed25519.SeedSize, crypto.RegistrationSaltSize, crypto.RegistrationSaltSize, crypto.KeyEnvelopeSize(suite)}
+	values := make([][]byte, len(purposes))
+	for i, purpose := range purposes {
+		digest, err := auth.EmailDigest(purpose, email)
+		if err != nil {
+			return nil, err
+		}
+		if values[i], err = hkdf.Expand(sha256.New, digest, purpose, sizes[i]); err != nil {
+			return nil, err
+		}
+	}
+	publicKey := ed25519.NewKeyFromSeed(values[0]).Public().(ed25519.PublicKey)
+
+	return &RecoveryStartResponseBody{
+		PublicKey:      base64.StdEncoding.EncodeToString(publicKey),
+		EncryptionSalt: base64.StdEncoding.EncodeToString(values[1]),
+		HMACSalt:       base64.StdEncoding.EncodeToString(values[2]),
+		HMACType:       suite.MAC.Name,
+		EncryptionType: suite.Cipher.Name,
+		SuiteID:        suite.ID,
+		SignatureType:  crypto.SignatureEd25519,
+		RecoveryEscrow: base64.StdEncoding.EncodeToString(values[3]),
+	}, nil
+}
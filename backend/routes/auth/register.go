@@ -1,16 +1,23 @@
 package routes
 
 import (
+	"backend/crypto"
 	"backend/db"
 	"backend/models"
 	"backend/util"
+	"encoding/base64"
 	"encoding/json"
 	"errors"
+	"fmt"
 	"log"
 	"net/http"
 	"strings"
+	"time"
 )
 
+// registrationChallengeTTL is how long a registration challenge can be signed, like the login challenges
+const registrationChallengeTTL = 5 * time.Minute
+
 // RegisterRequestBody represents the JSON body for registration
 type RegisterRequestBody struct {
 	Name           string `json:"name"`
@@ -21,14 +28,75 @@ type RegisterRequestBody struct {
 	EncryptionSalt string `json:"encryption_salt"`
 	HMACSalt       string `json:"hmac_salt"`
 	PublicKey      string `json:"public_key"`
+	// IDs of the crypto suites the client supports, most preferred first. When set, the server picks the suite
+	// and the hmac_type and encryption_type may be left empty.
+	Suites []uint16 `json:"suites,omitempty"`
+	// Derivation of the login key, the clients that do not send it derive it with crypto.LegacyKDF
+	KDF *models.KDFParams `json:"kdf,omitempty"`
+	// Signature scheme of the blocks, crypto.SignatureEd25519 when empty
+	SignatureType string `json:"signature_type"`
+	// ML-DSA public key, required by the hybrid signature types
+	PQPublicKey *string `json:"pq_public_key,omitempty"`
+	// Optional offline recovery key, all three or none: its Ed25519 public key, the encryption and HMAC keys
+	// sealed under it, and its signature of crypto.RecoveryEnrollmentPayload
+	RecoveryPublicKey *string `json:"recovery_public_key,omitempty"`
+	RecoveryEscrow    *string `json:"recovery_escrow,omitempty"`
+	RecoverySignature *string `json:"recovery_signature,omitempty"`
+	// Challenge from RegistrationChallengeHandler, and the signature of crypto.RegistrationPayload with the login key
+	Challenge string `json:"challenge"`
+	Signature string `json:"signature"`
+	// Hybrid signature types: the signature of crypto.RegistrationPayload with the ML-DSA key
+	PQSignature *string `json:"pq_signature,omitempty"`
+}
+
+// RegistrationChallengeResponseBody represents the JSON response with a registration challenge
+type RegistrationChallengeResponseBody struct {
+	Challenge string `json:"challenge"`
+	ExpiresAt string `json:"expires_at"`
 }
 
 // RegisterResponseBody represents the JSON response for registration
 type RegisterResponseBody struct {
 	UserID  uint32 `json:"user_id"`
+	SuiteID uint16 `json:"suite_id"` // Crypto suite of the new account
 	Message string `json:"message"`
 }
 
+// RegistrationChallengeHandler generates a challenge for a new account to sign with its keys.
+// Registering is a two-step flow: the client fetches a challenge, then signs it along with its salts and profile,
+// proving it holds the private keys of the public keys it registers.
+func RegistrationChallengeHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+
+	challengeValue, err := crypto.GenerateSaltBase64(32)
+	if err != nil {
+		log.Printf("Error generating challenge: %v", err)
+		writeJSONError(w, "Failed to generate challenge", http.StatusInternalServerError)
+		return
+	}
+	expiresAt := time.Now().UTC().Add(registrationChallengeTTL)
+
+	challengeRepo := db.NewChallengeRepository(db.GetDB())
+	if err := challengeRepo.CreateRegistrationChallenge(challengeValue, expiresAt); err != nil {
+		log.Printf("Error creating registration challenge: %v", err)
+		writeJSONError(w, "Failed to create challenge", http.StatusInternalServerError)
+		return
+	}
+
+	err = json.NewEncoder(w).Encode(RegistrationChallengeResponseBody{
+		Challenge: challengeValue,
+		ExpiresAt: expiresAt.Format(time.RFC3339),
+	})
+	if err != nil {
+		log.Printf("Error encoding response: %v", err)
+	}
+}
+
 // RegisterHandler handles user registration via REST API
 func RegisterHandler(w http.ResponseWriter, r *http.Request) {
 	// Only accept POST requests
@@ -49,12 +117,47 @@ func RegisterHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	// The signed fields are the ones the client sent, before the validation fills in the algorithms of the suite
+	payload := crypto.RegistrationPayload(request.Challenge, registrationProfile(&request))
+
 	// Validate request
-	if err := validateRegistrationRequest(request); err != nil {
+	suite, err := validateRegistrationRequest(&request)
+	if err != nil {
 		writeJSONError(w, err.Error(), http.StatusBadRequest)
 		return
 	}
 
+	// Proof of possession of the login key, and of the ML-DSA key of the hybrid signature types
+	valid, err := crypto.VerifyEd25519Signature(request.PublicKey, base64.StdEncoding.EncodeToString(payload), request.Signature)
+	if err != nil || !valid {
+		writeJSONError(w, "Invalid signature of the registration", http.StatusUnauthorized)
+		return
+	}
+	if crypto.IsHybridSignature(request.SignatureType) {
+		if request.PQSignature == nil {
+			writeJSONError(w, "The hybrid signature types require the ML-DSA signature of the registration", http.StatusBadRequest)
+			return
+		}
+		valid, err = crypto.VerifyMLDSASignature(*request.PQPublicKey, payload, *request.PQSignature)
+		if err != nil || !valid {
+			writeJSONError(w, "Invalid ML-DSA signature of the registration", http.StatusUnauthorized)
+			return
+		}
+	}
+
+	// The challenge is only consumed by a valid registration, a single time
+	challengeRepo := db.NewChallengeRepository(db.GetDB())
+	err = challengeRepo.ConsumeRegistrationChallenge(request.Challenge)
+	switch {
+	case errors.Is(err, db.ErrRegistrationChallengeInvalid):
+		writeJSONError(w, "Invalid or expired challenge", http.StatusUnauthorized)
+		return
+	case err != nil:
+		log.Printf("Error consuming registration challenge: %v", err)
+		writeJSONError(w, "Error checking the challenge", http.StatusInternalServerError)
+		return
+	}
+
 	// Check if email is already registered
 	userRepo := db.NewUserRepository(db.GetDB())
 	existingUser, err := userRepo.GetUserByEmail(strings.ToLower(request.Email))
@@ -78,6 +181,19 @@ func RegisterHandler(w http.ResponseWriter, r *http.Request) {
 		HMACSalt:       request.HMACSalt,
 		HMACType:       request.HMACType,
 		EncryptionType: request.EncryptionType,
+		SuiteID:        suite.ID,
+		KDF:            crypto.LegacyKDF,
+		SignatureType:  request.SignatureType,
+	}
+	if request.KDF != nil {
+		user.KDF = *request.KDF
+	}
+	if request.PQPublicKey != nil {
+		user.PQPubKey = *request.PQPublicKey
+	}
+	if request.RecoveryPublicKey != nil {
+		user.RecoveryPubKey = *request.RecoveryPublicKey
+		user.RecoveryEscrow = *request.RecoveryEscrow
 	}
 
 	// Save user to database
@@ -92,8 +208,14 @@ func RegisterHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	// The account works without the email, a lost verification can be sent again
+	if err := sendVerificationEmail(userID, user.Email); err != nil {
+		log.Printf("Error sending the verification email: %v", err)
+	}
+
 	response := RegisterResponseBody{
 		UserID:  userID,
+		SuiteID: suite.ID,
 		Message: "User registered successfully",
 	}
 
@@ -112,25 +234,162 @@ func writeJSONError(w http.ResponseWriter, message string, statusCode int) {
 	json.NewEncoder(w).Encode(map[string]string{"error": message})
 }
 
-// validateRegistrationRequest validates that all required fields are present and valid for registration
-func validateRegistrationRequest(request RegisterRequestBody) error {
-	if !util.ValidateStruct(request) {
-		return errors.New("Required fields are missing")
+// validateRegistrationRequest validates that all required fields are present and valid for registration.
+// It also picks the crypto suite of the account: from the suites offered by the client when there are some,
+// otherwise from the hmac_type and encryption_type, which are then filled in from the suite.
+// Parameters:
+// - request: a pointer to the registration request
+// Returns: a pointer to the suite of the new account, or an error describing the invalid field
+func validateRegistrationRequest(request *RegisterRequestBody) (*crypto.Suite, error) {
+	suite, err := registrationSuite(request)
+	if err != nil {
+		return nil, err
+	}
+	request.HMACType = suite.MAC.Name
+	request.EncryptionType = suite.Cipher.Name
+	if request.SignatureType == "" {
+		request.SignatureType = crypto.SignatureEd25519
+	}
+
+	if !util.ValidateStruct(*request) {
+		return nil, errors.New("Required fields are missing")
 	}
 
 	// Check if all fields are valid
+	if err := util.ValidateEmail(request.Email); err != nil {
+		return nil, err
+	}
+	if len(request.Name) > 255 {
+		return nil, errors.New("name must be at most 255 characters")
+	}
+	for name, salt := range map[string]string{
+		"login salt":      request.LoginSalt,
+		"encryption salt": request.EncryptionSalt,
+		"hmac salt":       request.HMACSalt,
+	} {
+		if err := crypto.ValidateSalt(name, salt); err != nil {
+			return nil, err
+		}
+	}
+	if err := crypto.ValidateEd25519PublicKey(request.PublicKey); err != nil {
+		return nil, err
+	}
+	if request.KDF != nil {
+		if err := crypto.ValidateKDFParams(*request.KDF); err != nil {
+			return nil, err
+		}
+	}
+	if err := validateSignatureKeys(request.SignatureType, request.PQPublicKey); err != nil {
+		return nil, err
+	}
+	if err := validateRecoveryEnrollment(request, suite); err != nil {
+		return nil, err
+	}
+
+	return suite, nil
+}
+
+// validateRecoveryEnrollment checks the optional recovery key of a registration: the escrowed keys must have the
+// size of the keys of the suite, and the recovery key must sign the identity of the account and the escrow.
+// Parameters:
+// - request: a pointer to the registration request
+// - suite: a pointer to the suite of the new account
+// Returns: an error describing the invalid field, nil if there is no recovery key or it is valid
+func validateRecoveryEnrollment(request *RegisterRequestBody, suite *crypto.Suite) error {
+	if request.RecoveryPublicKey == nil && request.RecoveryEscrow == nil && request.RecoverySignature == nil {
+		return nil
+	}
 	switch {
-	case !strings.Contains(request.Email, "@") || !strings.Contains(request.Email, "."):
-		return errors.New("invalid email format")
-	case request.HMACType != "hmac-sha256" && request.HMACType != "hmac-sha512":
-		return errors.New("hmac type must be either hmac-sha256 or hmac-sha512")
-	case request.EncryptionType != "aes-128-cbc" && request.EncryptionType != "aes-128-ctr":
-		return errors.New("encryption type must be either aes-128-cbc or aes-128-ctr")
-	case len(request.LoginSalt) < 44 || len(request.EncryptionSalt) < 44 || len(request.HMACSalt) < 44:
-		return errors.New("salt must be encoded in base64")
-	case len(request.PublicKey) < 44:
-		return errors.New("public key must be encoded in base64")
+	case request.RecoveryPublicKey == nil || request.RecoveryEscrow == nil || request.RecoverySignature == nil:
+		return errors.New("the recovery key needs a public key, an escrow and a signature")
+	case *request.RecoveryPublicKey == request.PublicKey:
+		return errors.New("the recovery key must differ from the login key")
+	}
+	if err := crypto.ValidateEd25519PublicKey(*request.RecoveryPublicKey); err != nil {
+		return fmt.Errorf("invalid recovery key: %v", err)
+	}
+	if err := crypto.ValidateKeyEnvelope(*request.RecoveryEscrow, suite); err != nil {
+		return fmt.Errorf("invalid recovery escrow: %v", err)
 	}
 
+	payload := crypto.RecoveryEnrollmentPayload(request.PublicKey, *request.RecoveryPublicKey, *request.RecoveryEscrow)
+	valid, err := crypto.VerifyRecoveryEd25519Signature(*request.RecoveryPublicKey, payload, *request.RecoverySignature)
+	if err != nil || !valid {
+		return errors.New("invalid signature of the recovery key")
+	}
+	return nil
+}
+
+// registrationProfile collects the signed fields of a registration, see crypto.RegistrationPayload
+func registrationProfile(request *RegisterRequestBody) *crypto.RegistrationProfile {
+	profile := &crypto.RegistrationProfile{
+		Email:          request.Email,
+		Name:           request.Name,
+		LoginSalt:      request.LoginSalt,
+		EncryptionSalt: request.EncryptionSalt,
+		HMACSalt:       request.HMACSalt,
+		PublicKey:      request.PublicKey,
+		HMACType:       request.HMACType,
+		EncryptionType: request.EncryptionType,
+		Suites:         request.Suites,
+		KDF:            request.KDF,
+		SignatureType:  request.SignatureType,
+	}
+	if request.PQPublicKey != nil {
+		profile.PQPublicKey = *request.PQPublicKey
+	}
+	if request.RecoveryPublicKey != nil {
+		profile.RecoveryPublicKey = *request.RecoveryPublicKey
+	}
+	if request.RecoveryEscrow != nil {
+		profile.RecoveryEscrow = *request.RecoveryEscrow
+	}
+	return profile
+}
+
+// registrationSuite negotiates the crypto suite of a new account, deprecated suites are refused
+func registrationSuite(request *RegisterRequestBody) (*crypto.Suite, error) {
+	if len(request.Suites) > 0 {
+		suite, err := crypto.NegotiateSuite(request.Suites)
+		if err != nil {
+			return nil, errors.New("none of the offered suites is supported, see /crypto/suites")
+		}
+		return suite, nil
+	}
+
+	if request.HMACType == "" || request.EncryptionType == "" {
+		return nil, errors.New("Required fields are missing")
+	}
+	suite, err := crypto.SuiteFor(request.EncryptionType, request.HMACType)
+	if err != nil {
+		return nil, fmt.Errorf("unsupported combination of %s and %s, see /crypto/suites", request.EncryptionType, request.HMACType)
+	}
+	if suite.Deprecated {
+		return nil, fmt.Errorf("the %s suite is deprecated and cannot be chosen for a new account", suite.Name)
+	}
+	return suite, nil
+}
+
+// validateSignatureKeys checks that the public keys sent with a signature type match it: the hybrid types need
+// a valid ML-DSA public key, the Ed25519 type none.
+// Parameters:
+// - signatureType: the signature type of the account
+// - pqPublicKey: the Base64 ML-DSA public key, nil when the client sent none
+// Returns: an error describing the invalid key, nil if the keys match the signature type
+func validateSignatureKeys(signatureType string, pqPublicKey *string) error {
+	switch {
+	case !crypto.ValidSignatureType(signatureType):
+		return fmt.Errorf("unsupported signature type %q", signatureType)
+	case !crypto.IsHybridSignature(signatureType):
+		if pqPublicKey != nil && *pqPublicKey != "" {
+			return fmt.Errorf("the %s signature type takes no ML-DSA public key", signatureType)
+		}
+		return nil
+	case pqPublicKey == nil:
+		return fmt.Errorf("the %s signature type requires an ML-DSA public key", signatureType)
+	}
+	if _, err := crypto.ParseMLDSAPublicKey(*pqPublicKey); err != nil {
+		return fmt.Errorf("invalid ML-DSA public key: %v", err)
+	}
 	return nil
 }
@@ -0,0 +1,671 @@
+package routes
+
+import (
+	"backend/auth"
+	"backend/config"
+	"backend/crypto"
+	"backend/db"
+	"backend/models"
+	"backend/notify"
+	"backend/util"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// Bounds of the shares of a recovery key
+const (
+	minShareThreshold = 2  // A single contact must never be able to recover the account alone
+	maxRecoveryShares = 10 // Every contact is notified of the recoveries, keep the list short
+)
+
+// socialRecoveryWindow is how long the contacts can submit their shares, and the requester collect them,
+// once the shares are released
+const socialRecoveryWindow = 7 * 24 * time.Hour
+
+// maxPendingRecoveries is the number of social recoveries of an account that can be pending at once.
+// Several are allowed so a stranger starting recoveries cannot lock the owner out of their own.
+const maxPendingRecoveries = 3
+
+// ContactRequestBody represents the JSON body of the lookup of a trusted contact
+type ContactRequestBody struct {
+	Email string `json:"email"`
+}
+
+// ContactResponseBody holds the key a share is sealed to
+type ContactResponseBody struct {
+	UserID    uint32 `json:"user_id"`
+	Name      string `json:"name"`
+	PublicKey string `json:"public_key"`
+}
+
+// DistributedShare is one share of a distribution
+type DistributedShare struct {
+	ContactID      uint32 `json:"contact_id"`
+	ShareIndex     uint8  `json:"share_index"`
+	ContactKey     string `json:"contact_key"`     // Current login key of the contact, the share is sealed to it
+	EncryptedShare string `json:"encrypted_share"` // The share sealed to the contact key
+}
+
+// DistributeSharesRequestBody represents the JSON body of the distribution of the shares of a recovery key.
+// The shares split the current recovery key of the user, or a new one replacing it, given with its escrow.
+type DistributeSharesRequestBody struct {
+	Threshold uint8               `json:"threshold"`
+	Shares    []*DistributedShare `json:"shares"`
+	// New recovery key: its public key, the keys sealed under it and its signature of the recovery enrollment
+	RecoveryPublicKey *string `json:"recovery_public_key,omitempty"`
+	RecoveryEscrow    *string `json:"recovery_escrow,omitempty"`
+	RecoverySignature *string `json:"recovery_signature,omitempty"`
+	// Signature of the recovery enrollment with the login key, replacing the recovery key needs more than a session
+	Signature *string `json:"signature,omitempty"`
+}
+
+// SubmitShareRequestBody represents the JSON body of a share submitted by a contact
+type SubmitShareRequestBody struct {
+	RequestID      string `json:"request_id"`
+	EncryptedShare string `json:"encrypted_share"` // The share sealed to the ephemeral key of the recovery
+}
+
+// SocialRecoveryStartRequestBody represents the JSON body of the start of a social recovery
+type SocialRecoveryStartRequestBody struct {
+	Email        string `json:"email"`
+	EphemeralKey string `json:"ephemeral_key"` // X25519 key the contacts seal their shares to
+}
+
+// SocialRecoveryStartResponseBody identifies a social recovery to its requester
+type SocialRecoveryStartResponseBody struct {
+	RequestID string    `json:"request_id"` // Secret, only the requester learns it
+	ReleaseAt time.Time `json:"release_at"`
+	ExpiresAt time.Time `json:"expires_at"`
+}
+
+// RecoveryRequestIDBody represents the JSON body naming a social recovery
+type RecoveryRequestIDBody struct {
+	RequestID string `json:"request_id"`
+}
+
+// SocialRecoveryStatusResponseBody is the progress of a social recovery, with the shares once released
+type SocialRecoveryStatusResponseBody struct {
+	models.RecoveryRequest
+	Submissions []*models.RecoverySubmission `json:"submissions,omitempty"`
+}
+
+// ContactHandler looks up a user by email for the logged in user to seal a share to their key
+func ContactHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+
+	userID, ok := r.Context().Value("UserID").(uint32)
+	if !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	var request ContactRequestBody
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil || request.Email == "" {
+		writeJSONError(w, "Invalid request format", http.StatusBadRequest)
+		return
+	}
+
+	userRepo := db.NewUserRepository(db.GetDB())
+	contact, err := userRepo.GetUserByEmail(strings.ToLower(request.Email))
+	if err != nil {
+		writeJSONError(w, "Contact not found", http.StatusNotFound)
+		return
+	}
+	if contact.ID == userID {
+		writeJSONError(w, "You cannot be your own trusted contact", http.StatusBadRequest)
+		return
+	}
+
+	err = json.NewEncoder(w).Encode(ContactResponseBody{
+		UserID:    contact.ID,
+		Name:      contact.Name,
+		PublicKey: contact.PubKey,
+	})
+	if err != nil {
+		log.Printf("Error encoding response: %v", err)
+	}
+}
+
+// DistributeSharesHandler replaces the shares of the recovery key of the logged in user.
+// The client splits the recovery key into one share per contact, any threshold of them rebuilding it, and seals
+// each share to the login key of its contact. The previous shares are dropped and the pending recoveries cancelled.
+func DistributeSharesHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+
+	userID, ok := r.Context().Value("UserID").(uint32)
+	if !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	var request DistributeSharesRequestBody
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		log.Printf("Error parsing request body: %v", err)
+		writeJSONError(w, "Invalid request format", http.StatusBadRequest)
+		return
+	}
+	if err := validateDistributeSharesRequest(request); err != nil {
+		writeJSONError(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	userRepo := db.NewUserRepository(db.GetDB())
+	user, err := userRepo.GetUserByID(userID)
+	if err != nil {
+		log.Printf("Error retrieving user: %v", err)
+		writeJSONError(w, "Error retrieving user", http.StatusInternalServerError)
+		return
+	}
+
+	var recoveryPubKey, recoveryEscrow string
+	if request.RecoveryPublicKey != nil {
+		if err := validateRecoveryKeyReplacement(request, user); err != nil {
+			writeJSONError(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		recoveryPubKey, recoveryEscrow = *request.RecoveryPublicKey, *request.RecoveryEscrow
+	} else if user.RecoveryPubKey == "" {
+		writeJSONError(w, "The account has no recovery key to split, send a new one", http.StatusBadRequest)
+		return
+	}
+
+	// Every share is sealed to the current login key of an existing contact
+	shares := make([]*models.RecoveryShare, len(request.Shares))
+	for i, share := range request.Shares {
+		if share.ContactID == userID {
+			writeJSONError(w, "You cannot be your own trusted contact", http.StatusBadRequest)
+			return
+		}
+		contact, err := userRepo.GetUserByID(share.ContactID)
+		if err != nil {
+			writeJSONError(w, fmt.Sprintf("Contact %d not found", share.ContactID), http.StatusBadRequest)
+			return
+		}
+		if contact.PubKey != share.ContactKey {
+			writeJSONError(w, fmt.Sprintf("The share of contact %d is not sealed to their current key", share.ContactID), http.StatusConflict)
+			return
+		}
+		shares[i] = &models.RecoveryShare{
+			ContactID:      share.ContactID,
+			ShareIndex:     share.ShareIndex,
+			Threshold:      request.Threshold,
+			ContactKey:     share.ContactKey,
+			EncryptedShare: share.EncryptedShare,
+		}
+	}
+
+	recoveryRepo := db.NewSocialRecoveryRepository(db.GetDB())
+	if err := recoveryRepo.ReplaceShares(userID, shares, recoveryPubKey, recoveryEscrow); err != nil {
+		log.Printf("Error distributing the shares of user %d: %v", userID, err)
+		writeJSONError(w, "Error distributing the shares", http.StatusInternalServerError)
+		return
+	}
+
+	if recoveryPubKey != "" {
+		details := requestDetails(r)
+		details["change"] = "recovery key replaced"
+		notify.Emit(&notify.Event{Type: notify.EventKeyRotation, UserID: userID, Email: user.Email, Name: user.Name, Details: details})
+	}
+
+	err = json.NewEncoder(w).Encode(map[string]string{"message": "Shares distributed successfully"})
+	if err != nil {
+		log.Printf("Error encoding response: %v", err)
+	}
+}
+
+// ListSharesHandler returns the shares the logged in user distributed, without their content
+func ListSharesHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+
+	userID, ok := r.Context().Value("UserID").(uint32)
+	if !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	recoveryRepo := db.NewSocialRecoveryRepository(db.GetDB())
+	shares, err := recoveryRepo.GetShares(userID)
+	if err != nil {
+		log.Printf("Error retrieving the shares of user %d: %v", userID, err)
+		writeJSONError(w, "Error retrieving shares", http.StatusInternalServerError)
+		return
+	}
+
+	err = json.NewEncoder(w).Encode(shares)
+	if err != nil {
+		log.Printf("Error encoding response: %v", err)
+	}
+}
+
+// HeldSharesHandler returns the shares the logged in user holds for other users, with their pending recoveries
+func HeldSharesHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+
+	userID, ok := r.Context().Value("UserID").(uint32)
+	if !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	recoveryRepo := db.NewSocialRecoveryRepository(db.GetDB())
+	shares, err := recoveryRepo.GetHeldShares(userID)
+	if err != nil {
+		log.Printf("Error retrieving the shares held by user %d: %v", userID, err)
+		writeJSONError(w, "Error retrieving shares", http.StatusInternalServerError)
+		return
+	}
+
+	err = json.NewEncoder(w).Encode(shares)
+	if err != nil {
+		log.Printf("Error encoding response: %v", err)
+	}
+}
+
+// SubmitShareHandler stores the share the logged in user holds for the account of a pending social recovery.
+// The contact opens their share and seals it again to the ephemeral key of the recovery, after checking with the
+// requester, out of band, that the recovery is theirs.
+func SubmitShareHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+
+	userID, ok := r.Context().Value("UserID").(uint32)
+	if !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	var request SubmitShareRequestBody
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil || request.RequestID == "" {
+		writeJSONError(w, "Invalid request format", http.StatusBadRequest)
+		return
+	}
+	if err := crypto.ValidateSealedShare(request.EncryptedShare); err != nil {
+		writeJSONError(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	recoveryRepo := db.NewSocialRecoveryRepository(db.GetDB())
+	recovery, ownerID, err := recoveryRepo.GetRequest(request.RequestID)
+	if err != nil && !errors.Is(err, db.ErrRecoveryRequestNotFound) {
+		log.Printf("Error retrieving recovery %s: %v", request.RequestID, err)
+		writeJSONError(w, "Error retrieving the recovery", http.StatusInternalServerError)
+		return
+	}
+	if err != nil || recovery.Status != models.RecoveryPending || time.Now().UTC().After(recovery.ExpiresAt) {
+		writeJSONError(w, "Recovery not found or no longer pending", http.StatusNotFound)
+		return
+	}
+
+	share, err := recoveryRepo.GetShare(ownerID, userID)
+	switch {
+	case errors.Is(err, db.ErrNoRecoveryShares):
+		writeJSONError(w, "You hold no share for this account", http.StatusForbidden)
+		return
+	case err != nil:
+		log.Printf("Error retrieving the share of user %d for user %d: %v", userID, ownerID, err)
+		writeJSONError(w, "Error retrieving the share", http.StatusInternalServerError)
+		return
+	}
+
+	submission := &models.RecoverySubmission{
+		ShareIndex:     share.ShareIndex,
+		EncryptedShare: request.EncryptedShare,
+	}
+	err = recoveryRepo.AddSubmission(request.RequestID, userID, submission)
+	switch {
+	case errors.Is(err, db.ErrShareSubmitted):
+		writeJSONError(w, "You already submitted your share for this recovery", http.StatusConflict)
+		return
+	case err != nil:
+		log.Printf("Error submitting the share of user %d for recovery %s: %v", userID, request.RequestID, err)
+		writeJSONError(w, "Error submitting the share", http.StatusInternalServerError)
+		return
+	}
+
+	err = json.NewEncoder(w).Encode(map[string]string{"message": "Share submitted successfully"})
+	if err != nil {
+		log.Printf("Error encoding response: %v", err)
+	}
+}
+
+// SocialRecoveryStartHandler starts the social recovery of an account.
+// The requester generates an ephemeral X25519 key and keeps the returned request ID, their contacts seal their
+// shares to that key. The shares are only released after a delay, during which the owner, notified at once, sees
+// the recovery when logging in and can cancel it. Like the recovery start, an unknown email or an account without
+// shares gets a made up recovery, stored and limited like the real ones, so it cannot be used to find out which
+// emails are registered.
+func SocialRecoveryStartHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+
+	var request SocialRecoveryStartRequestBody
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		writeJSONError(w, "Invalid request format", http.StatusBadRequest)
+		return
+	}
+	if err := util.ValidateEmail(request.Email); err != nil {
+		writeJSONError(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if err := crypto.ValidateX25519Key(request.EphemeralKey); err != nil {
+		writeJSONError(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	requestID, err := crypto.GenerateIDHex(16)
+	if err != nil {
+		log.Printf("Error generating recovery ID: %v", err)
+		writeJSONError(w, "Unknown error ocurred when starting the recovery", http.StatusInternalServerError)
+		return
+	}
+	now := time.Now().UTC()
+	recovery := &models.RecoveryRequest{
+		ID:           requestID,
+		Status:       models.RecoveryPending,
+		EphemeralKey: request.EphemeralKey,
+		CreatedAt:    now,
+		ReleaseAt:    now.Add(time.Duration(config.GetConfig().SocialRecoveryHours) * time.Hour),
+	}
+	recovery.ExpiresAt = recovery.ReleaseAt.Add(socialRecoveryWindow)
+	response := SocialRecoveryStartResponseBody{
+		RequestID: recovery.ID,
+		ReleaseAt: recovery.ReleaseAt,
+		ExpiresAt: recovery.ExpiresAt,
+	}
+
+	userRepo := db.NewUserRepository(db.GetDB())
+	recoveryRepo := db.NewSocialRecoveryRepository(db.GetDB())
+	user, err := userRepo.GetUserByEmail(strings.ToLower(request.Email))
+	if err == nil {
+		recovery.Threshold, err = recoveryRepo.GetThreshold(user.ID)
+	}
+	if err != nil && user != nil && !errors.Is(err, db.ErrNoRecoveryShares) {
+		log.Printf("Error retrieving the shares of user %d: %v", user.ID, err)
+		writeJSONError(w, "Error starting the recovery", http.StatusInternalServerError)
+		return
+	}
+	if err != nil {
+		startDummyRecovery(w, request.Email, recovery, response)
+		return
+	}
+
+	pending, err := recoveryRepo.GetPendingRequests(user.ID)
+	if err != nil {
+		log.Printf("Error retrieving the recoveries of user %d: %v", user.ID, err)
+		writeJSONError(w, "Error starting the recovery", http.StatusInternalServerError)
+		return
+	}
+	if len(pending) >= maxPendingRecoveries {
+		writeJSONError(w, "Too many recoveries are pending for this account", http.StatusTooManyRequests)
+		return
+	}
+
+	if err := recoveryRepo.CreateRequest(user.ID, recovery); err != nil {
+		log.Printf("Error creating a recovery for user %d: %v", user.ID, err)
+		writeJSONError(w, "Error starting the recovery", http.StatusInternalServerError)
+		return
+	}
+
+	// The owner is told at once, they can only cancel the recovery until the shares are released
+	details := requestDetails(r)
+	details["release_at"] = recovery.ReleaseAt.Format(time.RFC3339)
+	notify.Emit(&notify.Event{Type: notify.EventRecoveryRequest, UserID: user.ID, Email: user.Email, Name: user.Name, Details: details})
+
+	w.WriteHeader(http.StatusCreated)
+	err = json.NewEncoder(w).Encode(response)
+	if err != nil {
+		log.Printf("Error encoding response: %v", err)
+	}
+}
+
+// startDummyRecovery answers the social recovery of an email without shares like a real one: the made up recovery
+// is stored, so its status can be looked up, and too many pending ones are refused with the same error.
+// Its threshold is drawn from the email, the same for every recovery of it.
+func startDummyRecovery(w http.ResponseWriter, email string, recovery *models.RecoveryRequest, response SocialRecoveryStartResponseBody) {
+	digest, err := auth.EmailDigest("social-recovery", email)
+	if err != nil {
+		log.Printf("Error computing the email digest: %v", err)
+		writeJSONError(w, "Error starting the recovery", http.StatusInternalServerError)
+		return
+	}
+	emailDigest := hex.EncodeToString(digest)
+
+	recoveryRepo := db.NewSocialRecoveryRepository(db.GetDB())
+	pending, err := recoveryRepo.CountPendingDummyRequests(emailDigest)
+	if err != nil {
+		log.Printf("Error counting the made up recoveries: %v", err)
+		writeJSONError(w, "Error starting the recovery", http.StatusInternalServerError)
+		return
+	}
+	if pending >= maxPendingRecoveries {
+		writeJSONError(w, "Too many recoveries are pending for this account", http.StatusTooManyRequests)
+		return
+	}
+
+	recovery.Threshold = minShareThreshold + digest[0]%2
+	if err := recoveryRepo.CreateDummyRequest(emailDigest, recovery); err != nil {
+		log.Printf("Error creating a made up recovery: %v", err)
+		writeJSONError(w, "Error starting the recovery", http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusCreated)
+	if err := json.NewEncoder(w).Encode(response); err != nil {
+		log.Printf("Error encoding response: %v", err)
+	}
+}
+
+// SocialRecoveryStatusHandler returns the progress of a social recovery to its requester.
+// Once the delay is over and enough contacts submitted their shares, the response carries them: the requester
+// opens them with the ephemeral key, rebuilds the recovery key and re-keys the account with RecoverHandler.
+func SocialRecoveryStatusHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+
+	var request RecoveryRequestIDBody
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil || request.RequestID == "" {
+		writeJSONError(w, "Invalid request format", http.StatusBadRequest)
+		return
+	}
+
+	recoveryRepo := db.NewSocialRecoveryRepository(db.GetDB())
+	recovery, _, err := recoveryRepo.GetRequest(request.RequestID)
+	switch {
+	case errors.Is(err, db.ErrRecoveryRequestNotFound):
+		writeJSONError(w, "Recovery not found", http.StatusNotFound)
+		return
+	case err != nil:
+		log.Printf("Error retrieving recovery %s: %v", request.RequestID, err)
+		writeJSONError(w, "Error retrieving the recovery", http.StatusInternalServerError)
+		return
+	}
+
+	response := SocialRecoveryStatusResponseBody{RecoveryRequest: *recovery}
+	now := time.Now().UTC()
+	released := recovery.Status == models.RecoveryPending && !now.Before(recovery.ReleaseAt) &&
+		now.Before(recovery.ExpiresAt) && recovery.Submitted >= int(recovery.Threshold)
+	if released {
+		response.Submissions, err = recoveryRepo.GetSubmissions(recovery.ID)
+		if err != nil {
+			log.Printf("Error retrieving the shares of recovery %s: %v", recovery.ID, err)
+			writeJSONError(w, "Error retrieving the shares", http.StatusInternalServerError)
+			return
+		}
+	}
+
+	err = json.NewEncoder(w).Encode(response)
+	if err != nil {
+		log.Printf("Error encoding response: %v", err)
+	}
+}
+
+// RecoveryRequestsHandler returns the pending social recoveries of the account of the logged in user
+func RecoveryRequestsHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+
+	userID, ok := r.Context().Value("UserID").(uint32)
+	if !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	recoveryRepo := db.NewSocialRecoveryRepository(db.GetDB())
+	requests, err := recoveryRepo.GetPendingRequests(userID)
+	if err != nil {
+		log.Printf("Error retrieving the recoveries of user %d: %v", userID, err)
+		writeJSONError(w, "Error retrieving recoveries", http.StatusInternalServerError)
+		return
+	}
+
+	err = json.NewEncoder(w).Encode(requests)
+	if err != nil {
+		log.Printf("Error encoding response: %v", err)
+	}
+}
+
+// CancelRecoveryHandler cancels a pending social recovery of the account of the logged in user, the shares
+// submitted for it are never released
+func CancelRecoveryHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+
+	userID, ok := r.Context().Value("UserID").(uint32)
+	if !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	var request RecoveryRequestIDBody
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil || request.RequestID == "" {
+		writeJSONError(w, "Invalid request format", http.StatusBadRequest)
+		return
+	}
+
+	recoveryRepo := db.NewSocialRecoveryRepository(db.GetDB())
+	err := recoveryRepo.CancelRequest(userID, request.RequestID)
+	switch {
+	case errors.Is(err, db.ErrRecoveryRequestNotFound):
+		writeJSONError(w, "Recovery not found", http.StatusNotFound)
+		return
+	case err != nil:
+		log.Printf("Error cancelling recovery %s of user %d: %v", request.RequestID, userID, err)
+		writeJSONError(w, "Error cancelling the recovery", http.StatusInternalServerError)
+		return
+	}
+
+	err = json.NewEncoder(w).Encode(map[string]string{"message": "Recovery cancelled successfully"})
+	if err != nil {
+		log.Printf("Error encoding response: %v", err)
+	}
+}
+
+// validateDistributeSharesRequest validates the threshold and the shares of a distribution
+func validateDistributeSharesRequest(request DistributeSharesRequestBody) error {
+	switch {
+	case len(request.Shares) > maxRecoveryShares:
+		return fmt.Errorf("at most %d shares can be distributed", maxRecoveryShares)
+	case request.Threshold < minShareThreshold:
+		return fmt.Errorf("threshold must be at least %d", minShareThreshold)
+	case int(request.Threshold) > len(request.Shares):
+		return errors.New("threshold cannot exceed the number of shares")
+	}
+
+	contacts := map[uint32]bool{}
+	indexes := map[uint8]bool{}
+	for _, share := range request.Shares {
+		switch {
+		case share == nil || share.ContactID == 0 || share.ShareIndex == 0 || share.ContactKey == "":
+			return errors.New("Required fields are missing")
+		case contacts[share.ContactID]:
+			return errors.New("each contact can hold a single share")
+		case indexes[share.ShareIndex]:
+			return errors.New("share indexes must be distinct")
+		}
+		if err := crypto.ValidateSealedShare(share.EncryptedShare); err != nil {
+			return err
+		}
+		contacts[share.ContactID] = true
+		indexes[share.ShareIndex] = true
+	}
+	return nil
+}
+
+// validateRecoveryKeyReplacement verifies the new recovery key of a distribution.
+// The recovery key signs its enrollment like at registration, and the login key signs it too.
+func validateRecoveryKeyReplacement(request DistributeSharesRequestBody, user *models.User) error {
+	switch {
+	case request.RecoveryEscrow == nil || request.RecoverySignature == nil || request.Signature == nil:
+		return errors.New("the recovery key needs an escrow, its signature and the signature of the login key")
+	case *request.RecoveryPublicKey == user.PubKey:
+		return errors.New("the recovery key must differ from the login key")
+	}
+	if err := crypto.ValidateEd25519PublicKey(*request.RecoveryPublicKey); err != nil {
+		return fmt.Errorf("invalid recovery key: %v", err)
+	}
+
+	suite, err := crypto.SuiteOf(user)
+	if err != nil {
+		return errors.New("unsupported crypto suite")
+	}
+	if err := crypto.ValidateKeyEnvelope(*request.RecoveryEscrow, suite); err != nil {
+		return fmt.Errorf("invalid recovery escrow: %v", err)
+	}
+
+	payload := crypto.RecoveryEnrollmentPayload(user.PubKey, *request.RecoveryPublicKey, *request.RecoveryEscrow)
+	valid, err := crypto.VerifyRecoveryEd25519Signature(*request.RecoveryPublicKey, payload, *request.RecoverySignature)
+	if err != nil || !valid {
+		return errors.New("invalid signature of the recovery key")
+	}
+	valid, err = crypto.VerifyRecoveryEd25519Signature(user.PubKey, payload, *request.Signature)
+	if err != nil || !valid {
+		return errors.New("invalid signature of the login key")
+	}
+	return nil
+}
@@ -3,6 +3,7 @@ package routes
 import (
 	"backend/db"
 	"backend/models"
+	"backend/util"
 	"encoding/json"
 	"errors"
 	"log"
@@ -70,14 +71,14 @@ func UpdateUserHandler(w http.ResponseWriter, r *http.Request) {
 	if request.Name != "" {
 		currentUser.Name = request.Name
 	}
-	if request.Email != "" {
-		// Check if email is already taken by another user
-		existingUser, err := userRepo.GetUserByEmail(strings.ToLower(request.Email))
-		if err == nil && existingUser.ID != userID {
-			writeJSONError(w, "Email already in use", http.StatusConflict)
+	// A session alone cannot change the login email, the change waits for the confirmation of both addresses
+	message := "User updated successfully"
+	if request.Email != "" && !strings.EqualFold(request.Email, currentUser.Email) {
+		if _, status, err := startEmailChange(currentUser, request.Email); err != nil {
+			writeJSONError(w, err.Error(), status)
 			return
 		}
-		currentUser.Email = strings.ToLower(request.Email)
+		message = "User updated, confirm the new email from both the current and the new address"
 	}
 
 	// Update user in database
@@ -90,7 +91,7 @@ func UpdateUserHandler(w http.ResponseWriter, r *http.Request) {
 
 	// Prepare response
 	response := UpdateUserResponseBody{
-		Message: "User updated successfully",
+		Message: message,
 		User:    *currentUser,
 	}
 
@@ -106,9 +107,12 @@ func UpdateUserHandler(w http.ResponseWriter, r *http.Request) {
 // validateUpdateRequest validates the update request fields
 func validateUpdateRequest(request UpdateUserRequestBody) error {
 	if request.Email != "" {
-		if !strings.Contains(request.Email, "@") || !strings.Contains(request.Email, ".") {
-			return errors.New("invalid email format")
+		if err := util.ValidateEmail(request.Email); err != nil {
+			return err
 		}
 	}
+	if len(request.Name) > 255 {
+		return errors.New("name must be at most 255 characters")
+	}
 	return nil
 }
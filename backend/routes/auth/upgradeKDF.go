@@ -0,0 +1,140 @@
+package routes
+
+import (
+	"backend/crypto"
+	"backend/db"
+	"backend/models"
+	"backend/notify"
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+)
+
+// UpgradeKDFRequestBody represents the JSON body of a login key upgrade
+type UpgradeKDFRequestBody struct {
+	KDF          models.KDFParams `json:"kdf"`           // New KDF parameters, stronger than the current ones
+	LoginSalt    string           `json:"login_salt"`    // New login salt
+	PublicKey    string           `json:"public_key"`    // Public key derived with the new salt and parameters
+	Signature    string           `json:"signature"`     // Signature of the upgrade payload with the current login key
+	NewSignature string           `json:"new_signature"` // Signature of the upgrade payload with the new login key
+	// Hybrid signature types: the ML-DSA key derived from the new login key, and its signature of the upgrade payload
+	PQPublicKey string `json:"pq_public_key,omitempty"`
+	PQSignature string `json:"pq_signature,omitempty"`
+}
+
+// UpgradeKDFHandler raises the cost of the derivation of the login key of the logged in user.
+// The client derives a new login key with a fresh salt and the stronger parameters, then signs the change with
+// both the current key, proving it still knows the password, and the new key, proving the new key is its own.
+// The ML-DSA key of the hybrid accounts is derived from the login key and is replaced with it.
+// The blocks keep the keys that signed them, so the existing chains still verify.
+func UpgradeKDFHandler(w http.ResponseWriter, r *http.Request) {
+	// Only accept POST requests
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+
+	// Get user ID from context (set by auth middleware)
+	userID, ok := r.Context().Value("UserID").(uint32)
+	if !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	var request UpgradeKDFRequestBody
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		log.Printf("Error parsing request body: %v", err)
+		writeJSONError(w, "Invalid request format", http.StatusBadRequest)
+		return
+	}
+	if err := validateUpgradeKDFRequest(request); err != nil {
+		writeJSONError(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	userRepo := db.NewUserRepository(db.GetDB())
+	user, err := userRepo.GetUserByID(userID)
+	if err != nil {
+		log.Printf("Error retrieving user: %v", err)
+		writeJSONError(w, "Error retrieving user", http.StatusInternalServerError)
+		return
+	}
+
+	if !crypto.IsKDFUpgrade(user.KDF, request.KDF) {
+		writeJSONError(w, "The new KDF parameters must be stronger than the current ones", http.StatusBadRequest)
+		return
+	}
+
+	// The hybrid accounts replace their ML-DSA key too, the Ed25519 accounts must not send one
+	if err := validateSignatureKeys(user.SignatureType, &request.PQPublicKey); err != nil {
+		writeJSONError(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	// Both keys sign the same payload, which names the current key so the upgrade cannot be replayed
+	payload := crypto.KDFUpgradePayload(user.PubKey, request.PublicKey, request.PQPublicKey, request.LoginSalt, request.KDF)
+	valid, err := crypto.VerifyKDFUpgradeEd25519Signature(user.PubKey, payload, request.Signature)
+	if err != nil || !valid {
+		writeJSONError(w, "Invalid signature of the current login key", http.StatusUnauthorized)
+		return
+	}
+	valid, err = crypto.VerifyKDFUpgradeEd25519Signature(request.PublicKey, payload, request.NewSignature)
+	if err != nil || !valid {
+		writeJSONError(w, "Invalid signature of the new login key", http.StatusBadRequest)
+		return
+	}
+	if crypto.IsHybridSignature(user.SignatureType) {
+		valid, err = crypto.VerifyMLDSASignature(request.PQPublicKey, payload, request.PQSignature)
+		if err != nil || !valid {
+			writeJSONError(w, "Invalid signature of the new ML-DSA key", http.StatusBadRequest)
+			return
+		}
+	}
+
+	err = userRepo.UpdateLoginKey(userID, user.PubKey, request.PublicKey, request.PQPublicKey, request.LoginSalt, request.KDF)
+	switch {
+	case errors.Is(err, db.ErrLoginKeyChanged):
+		writeJSONError(w, "The login key changed in the meantime, log in again", http.StatusConflict)
+		return
+	case err != nil:
+		log.Printf("Error upgrading the login key of user %d: %v", userID, err)
+		writeJSONError(w, "Error upgrading the login key", http.StatusInternalServerError)
+		return
+	}
+
+	details := requestDetails(r)
+	details["change"] = "login key upgraded to a stronger derivation"
+	notify.Emit(&notify.Event{Type: notify.EventKeyRotation, UserID: userID, Email: user.Email, Name: user.Name, Details: details})
+
+	user.PubKey = request.PublicKey
+	user.PQPubKey = request.PQPublicKey
+	user.LoginSalt = request.LoginSalt
+	user.KDF = request.KDF
+
+	response := UpdateUserResponseBody{
+		Message: "Login key upgraded successfully",
+		User:    *user,
+	}
+	err = json.NewEncoder(w).Encode(response)
+	if err != nil {
+		log.Printf("Error encoding response: %v", err)
+	}
+}
+
+// validateUpgradeKDFRequest validates the fields of a login key upgrade
+func validateUpgradeKDFRequest(request UpgradeKDFRequestBody) error {
+	switch {
+	case request.LoginSalt == "" || request.PublicKey == "" || request.Signature == "" || request.NewSignature == "":
+		return errors.New("Required fields are missing")
+	}
+	if err := crypto.ValidateSalt("login salt", request.LoginSalt); err != nil {
+		return err
+	}
+	if err := crypto.ValidateEd25519PublicKey(request.PublicKey); err != nil {
+		return err
+	}
+	return crypto.ValidateKDFParams(request.KDF)
+}
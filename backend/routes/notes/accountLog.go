@@ -0,0 +1,204 @@
+package routes
+
+import (
+	"backend/crypto"
+	"backend/db"
+	"backend/models"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"strconv"
+)
+
+// loadAccountTree reads the account log of a user and decodes its leaf hashes.
+// Parameters:
+// - userID: the ID of the user
+// - treeSize: the number of leaves to load, 0 loads the whole log
+// Returns: the leaves, their decoded hashes, or an error if the log cannot be read
+func loadAccountTree(userID uint32, treeSize int) ([]*models.LogLeaf, [][]byte, error) {
+	logRepo := db.NewAccountLogRepository(db.GetDB())
+	leaves, err := logRepo.GetLeaves(userID, treeSize)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	hashes := make([][]byte, len(leaves))
+	for i, leaf := range leaves {
+		hashes[i], err = base64.StdEncoding.DecodeString(leaf.LeafHash)
+		if err != nil {
+			return nil, nil, fmt.Errorf("invalid leaf hash at index %d: %v", leaf.Index, err)
+		}
+	}
+
+	return leaves, hashes, nil
+}
+
+// treeHead builds the tree head of a list of leaf hashes
+func treeHead(hashes [][]byte) models.TreeHead {
+	return models.TreeHead{
+		TreeSize: len(hashes),
+		RootHash: base64.StdEncoding.EncodeToString(crypto.MerkleRoot(hashes)),
+	}
+}
+
+// encodeHashes encodes a list of hashes in Base64
+func encodeHashes(hashes [][]byte) []string {
+	encoded := make([]string, len(hashes))
+	for i, hash := range hashes {
+		encoded[i] = base64.StdEncoding.EncodeToString(hash)
+	}
+	return encoded
+}
+
+// parseTreeSize reads an optional tree size from the query parameters
+func parseTreeSize(query url.Values, name string) (int, error) {
+	value := query.Get(name)
+	if value == "" {
+		return 0, nil
+	}
+	size, err := strconv.Atoi(value)
+	if err != nil || size < 0 {
+		return 0, errors.New(name + " must be a positive integer")
+	}
+	return size, nil
+}
+
+// AccountInclusionHandler returns the proof that the latest leaf of a note is part of the account tree.
+// Query parameters: note_id, and optionally tree_size to prove against an older tree head.
+func AccountInclusionHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+
+	// Extract userID from context
+	userID, ok := r.Context().Value("UserID").(uint32)
+	if !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	query := r.URL.Query()
+	noteID := query.Get("note_id")
+	if !validNoteID(noteID) {
+		http.Error(w, "Invalid note ID", http.StatusBadRequest)
+		return
+	}
+	treeSize, err := parseTreeSize(query, "tree_size")
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	leaves, hashes, err := loadAccountTree(userID, treeSize)
+	if err != nil {
+		log.Printf("Error loading account log for user %d: %v", userID, err)
+		http.Error(w, "Error retrieving account log", http.StatusInternalServerError)
+		return
+	}
+	if treeSize > len(hashes) {
+		http.Error(w, "tree_size is larger than the account log", http.StatusBadRequest)
+		return
+	}
+
+	// The latest leaf of the note holds its current head
+	var leaf *models.LogLeaf
+	for i := len(leaves) - 1; i >= 0; i-- {
+		if leaves[i].NoteID == noteID {
+			leaf = leaves[i]
+			break
+		}
+	}
+	if leaf == nil {
+		http.Error(w, "Note not found in the account log", http.StatusNotFound)
+		return
+	}
+
+	path, err := crypto.MerkleInclusionProof(hashes, leaf.Index)
+	if err != nil {
+		log.Printf("Error building inclusion proof for user %d and note %s: %v", userID, noteID, err)
+		http.Error(w, "Error building inclusion proof", http.StatusInternalServerError)
+		return
+	}
+
+	response := models.InclusionProof{
+		Leaf:      leaf,
+		TreeHead:  treeHead(hashes),
+		AuditPath: encodeHashes(path),
+	}
+
+	err = json.NewEncoder(w).Encode(response)
+	if err != nil {
+		log.Printf("Error encoding response: %v", err)
+	}
+}
+
+// AccountConsistencyHandler returns the proof that the account tree only grew since an older tree head.
+// Query parameters: first, the size of the tree head last seen by the client, and optionally second
+// to prove against a tree head other than the current one.
+func AccountConsistencyHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+
+	// Extract userID from context
+	userID, ok := r.Context().Value("UserID").(uint32)
+	if !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	query := r.URL.Query()
+	if query.Get("first") == "" {
+		http.Error(w, "Missing fields", http.StatusBadRequest)
+		return
+	}
+	first, err := parseTreeSize(query, "first")
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	second, err := parseTreeSize(query, "second")
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	_, hashes, err := loadAccountTree(userID, second)
+	if err != nil {
+		log.Printf("Error loading account log for user %d: %v", userID, err)
+		http.Error(w, "Error retrieving account log", http.StatusInternalServerError)
+		return
+	}
+	if second > len(hashes) || first > len(hashes) {
+		// A client that saw a larger tree than the server now has is the rollback this proof detects
+		http.Error(w, "Tree size is larger than the account log", http.StatusBadRequest)
+		return
+	}
+
+	proof, err := crypto.MerkleConsistencyProof(hashes, first)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	response := models.ConsistencyProof{
+		First:  treeHead(hashes[:first]),
+		Second: treeHead(hashes),
+		Proof:  encodeHashes(proof),
+	}
+
+	err = json.NewEncoder(w).Encode(response)
+	if err != nil {
+		log.Printf("Error encoding response: %v", err)
+	}
+}
@@ -6,6 +6,7 @@ import (
 	"backend/models"
 	"backend/util"
 	"encoding/json"
+	"errors"
 	"log"
 	"net/http"
 	"time"
@@ -14,8 +15,9 @@ import (
 // AddBlockRquest represents the request body for adding a block
 
 type AddBlockResponse struct {
-	TimeStamp string `json:"timestamp"`
-	Message   string `json:"message"`
+	TimeStamp string          `json:"timestamp"`
+	Message   string          `json:"message"`
+	Receipt   *models.Receipt `json:"receipt,omitempty"` // Server signed proof that the block was stored
 }
 
 // this can also be tought of as the edit note endpoint
@@ -40,6 +42,16 @@ func AddBlockHandler(w http.ResponseWriter, r *http.Request) {
 		http.Error(w, "Missing fields", http.StatusBadRequest)
 		return
 	}
+	if !validNoteID(request.NoteID) {
+		http.Error(w, "Invalid note ID", http.StatusBadRequest)
+		return
+	}
+
+	// Validate the keyword tokens of the new version
+	if err := validateTokens(request.SearchTokens, maxSearchTokensPerVersion); err != nil {
+		http.Error(w, "Invalid search tokens: "+err.Error(), http.StatusBadRequest)
+		return
+	}
 
 	// get the user ID from the context set by the JWT middleware
 	userID, ok :=
@@ -57,11 +69,30 @@ func AddBlockHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// Check if the signature is valid
-	isValid, err := crypto.VerifyBlockEd25519Signature(user.PubKey, &request.Block)
-	if err != nil || !isValid {
+	// The block must carry the fields of the crypto suite of the user, and is stored with its ID
+	suite, err := crypto.SuiteOf(user)
+	if err != nil {
+		log.Printf("Error resolving the crypto suite of user %d: %v", userID, err)
+		http.Error(w, "Unsupported crypto suite", http.StatusInternalServerError)
+		return
+	}
+	if err := crypto.ValidateBlockFields(&request.Block, suite); err != nil {
+		http.Error(w, "Invalid block: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	request.Block.SuiteID = suite.ID
+
+	// The block must be signed by the key of the user or of one of their active devices,
+	// both signatures for the hybrid signature types
+	signer, err := blockSigner(user, &request.Block)
+	switch {
+	case errors.Is(err, errUnknownSigner):
 		http.Error(w, "Invalid signature", http.StatusBadRequest)
 		return
+	case err != nil:
+		log.Printf("Error retrieving devices of user %d: %v", userID, err)
+		http.Error(w, "Error verifying signature", http.StatusInternalServerError)
+		return
 	}
 
 	blockRepo := db.NewBlockRepository(db.GetDB())
@@ -69,7 +100,7 @@ func AddBlockHandler(w http.ResponseWriter, r *http.Request) {
 	// Get all blocks to determine the previous hash
 	blockchain, err := blockRepo.GetNoteBlockChain(userID, request.NoteID)
 	if err != nil {
-		log.Printf("Error retrieving blocks for user %d and note %d: %v", userID, request.NoteID, err)
+		log.Printf("Error retrieving blocks for user %d and note %s: %v", userID, request.NoteID, err)
 		http.Error(w, "Error retrieving blocks", http.StatusInternalServerError)
 		return
 	}
@@ -78,15 +109,23 @@ func AddBlockHandler(w http.ResponseWriter, r *http.Request) {
 	// If the blockchain is invalid, it will become impossible to edit the note
 	valid, err := crypto.VerifyBlockChain(blockchain.Blocks)
 	if err != nil || !valid {
-		log.Printf("Invalid block chhain for note %d: %v! You can no longer edit this note!", request.NoteID, err)
+		log.Printf("Invalid block chhain for note %s: %v! You can no longer edit this note!", request.NoteID, err)
 		http.Error(w, "Invalid block chain! You can no longer edit this note!", http.StatusBadRequest)
 		return
 	}
 
-	// Create the block in the database
-	err = blockRepo.CreateBlock(userID, request.NoteID, &request.Block)
-	if err != nil {
-		log.Printf("Error creating block for user %d and note %d: %v", userID, request.NoteID, err)
+	// Create the block in the database, it must extend the current head of the note, and its search tokens
+	// replace the tokens of the previous version
+	seq, err := blockRepo.CreateBlock(userID, request.NoteID, &request.Block, *signer, request.SearchTokens)
+	switch {
+	case errors.Is(err, db.ErrNoteNotFound):
+		http.Error(w, "Note not found", http.StatusNotFound)
+		return
+	case errors.Is(err, db.ErrHeadMismatch):
+		http.Error(w, "The note was modified in the meantime, reload it before editing", http.StatusConflict)
+		return
+	case err != nil:
+		log.Printf("Error creating block for user %d and note %s: %v", userID, request.NoteID, err)
 		http.Error(w, "Error creating block", http.StatusInternalServerError)
 		return
 	}
@@ -96,6 +135,14 @@ func AddBlockHandler(w http.ResponseWriter, r *http.Request) {
 		Message:   "Block created successfully!",
 	}
 
+	headHash, err := crypto.BlockHash(request.Block)
+	if err != nil {
+		log.Printf("Error hashing block for user %d and note %s: %v", userID, request.NoteID, err)
+	} else {
+		response.Receipt = signReceipt(request.NoteID, seq, headHash)
+		go timestampBlock(request.NoteID, seq, headHash)
+	}
+
 	err = json.NewEncoder(w).Encode(response)
 	if err != nil {
 		log.Printf("Error encoding response: %v", err)
@@ -1,17 +1,84 @@
 package routes
 
 import (
+	"backend/crypto"
 	"backend/db"
-	"backend/util"
+	"backend/models"
 	"encoding/json"
-	"fmt"
+	"errors"
+	"log"
 	"net/http"
+	"time"
 )
 
-type DeleteNoteRequest struct {
-	NoteID uint `json:"note_id"`
+// lifecycleBlock reads a tombstone request and builds the signed lifecycle block it appends to the chain of the note,
+// writing the error response if it fails.
+// Parameters:
+// - w: the response writer
+// - r: the request, its body is a models.Tombstone signed over the current head of the note
+// - kind: models.BlockKindTombstone or models.BlockKindRestore
+// Returns: the ID of the user, the block, the keys its signatures were verified with, and false if an error
+// response was written
+func lifecycleBlock(w http.ResponseWriter, r *http.Request, kind string) (uint32, *models.Block, *models.BlockSigner, bool) {
+	// Extract userID from context
+	userID, ok := r.Context().Value("UserID").(uint32)
+	if !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return 0, nil, nil, false
+	}
+
+	// Parse the request body
+	var request models.Tombstone
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return 0, nil, nil, false
+	}
+
+	// Ensure the request body is not empty, the ML-DSA signature is only required for the hybrid signature types
+	if request.NoteID == "" || request.HeadHash == "" || request.Signature == "" || request.Timestamp.IsZero() {
+		http.Error(w, "Missing fields", http.StatusBadRequest)
+		return 0, nil, nil, false
+	}
+	if !validNoteID(request.NoteID) {
+		http.Error(w, "Invalid note ID", http.StatusBadRequest)
+		return 0, nil, nil, false
+	}
+
+	// Fetch the public key from the database using the user ID
+	userRepo := db.NewUserRepository(db.GetDB())
+	user, err := userRepo.GetUserByID(userID)
+	if err != nil {
+		http.Error(w, "User not found", http.StatusUnauthorized)
+		return 0, nil, nil, false
+	}
+
+	suite, err := crypto.SuiteOf(user)
+	if err != nil {
+		log.Printf("Error resolving the crypto suite of user %d: %v", userID, err)
+		http.Error(w, "Unsupported crypto suite", http.StatusInternalServerError)
+		return 0, nil, nil, false
+	}
+	block := request.Block(kind)
+	block.SuiteID = suite.ID
+
+	// The block must be signed like a block of content, by the key of the user or of one of their active devices
+	signer, err := blockSigner(user, &block)
+	switch {
+	case errors.Is(err, errUnknownSigner):
+		http.Error(w, "Invalid signature", http.StatusBadRequest)
+		return 0, nil, nil, false
+	case err != nil:
+		log.Printf("Error retrieving devices of user %d: %v", userID, err)
+		http.Error(w, "Error verifying signature", http.StatusInternalServerError)
+		return 0, nil, nil, false
+	}
+
+	return userID, &block, signer, true
 }
 
+// DeleteNoteHandler moves a note to the trash.
+// The request is a tombstone signed by the user over the current head of the note, it is appended to the chain
+// of the note as a tombstone block. The note is only permanently deleted once the retention period of the trash expires.
 func DeleteNoteHandler(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodDelete {
 		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
@@ -20,38 +87,37 @@ func DeleteNoteHandler(w http.ResponseWriter, r *http.Request) {
 
 	w.Header().Set("Content-Type", "application/json")
 
-	// Extract userID from context
-	userID, ok := r.Context().Value("UserID").(uint32)
+	userID, block, signer, ok := lifecycleBlock(w, r, models.BlockKindTombstone)
 	if !ok {
-		http.Error(w, "Unauthorized", http.StatusUnauthorized)
 		return
 	}
 
-	// Parse the request body
-	var request DeleteNoteRequest
-	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
-		http.Error(w, "Invalid request body", http.StatusBadRequest)
-		return
-	}
+	trashRepo := db.NewTrashRepository(db.GetDB())
 
-	// Ensure the request body is not empty
-	if !util.ValidateStruct(request) {
-		http.Error(w, "Missing fields", http.StatusBadRequest)
+	// Move the note to the trash, it must still be at the head the user signed
+	seq, blockHash, err := trashRepo.TrashNote(userID, block, *signer)
+	switch {
+	case errors.Is(err, db.ErrNoteNotFound):
+		http.Error(w, "Note not found", http.StatusNotFound)
+		return
+	case errors.Is(err, db.ErrHeadMismatch):
+		http.Error(w, "The note was modified in the meantime, reload it before deleting", http.StatusConflict)
+		return
+	case err != nil:
+		log.Printf("Error trashing note %s for user %d: %v", block.NoteID, userID, err)
+		http.Error(w, "Error deleting note", http.StatusInternalServerError)
 		return
 	}
 
-	noteRepo := db.NewBlockRepository(db.GetDB())
-
-	// Attempt to delete the note
-	if err := noteRepo.DeleteNoteBlocks(userID, request.NoteID); err != nil {
-		if err.Error() == fmt.Sprintf("no blocks found for noteID %d and userID %d", request.NoteID, userID) {
-			http.Error(w, "Note not found", http.StatusNotFound)
-		} else {
-			http.Error(w, "Error deleting note", http.StatusInternalServerError)
-		}
-		return
+	response := AddBlockResponse{
+		TimeStamp: block.Timestamp.Format(time.RFC3339),
+		Message:   "Note moved to the trash",
+		Receipt:   signReceipt(block.NoteID, seq, blockHash),
 	}
+	go timestampBlock(block.NoteID, seq, blockHash)
 
-	w.WriteHeader(http.StatusOK)
-	w.Write([]byte(`{"message": "Note deleted successfully"}`))
+	err = json.NewEncoder(w).Encode(response)
+	if err != nil {
+		log.Printf("Error encoding response: %v", err)
+	}
 }
@@ -0,0 +1,169 @@
+package routes
+
+import (
+	"backend/crypto"
+	"backend/db"
+	"backend/models"
+	"backend/vault"
+	"fmt"
+	"log"
+	"net/http"
+	"slices"
+	"sort"
+)
+
+// ExportHandler streams the whole vault of the user as a signed archive, see the vault package for its layout.
+// The archive holds the notes inside the trash too, so it is a complete backup of the account.
+func ExportHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	userID, ok := r.Context().Value("UserID").(uint32)
+	if !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	userRepo := db.NewUserRepository(db.GetDB())
+	user, err := userRepo.GetUserByID(userID)
+	if err != nil {
+		http.Error(w, "User not found", http.StatusUnauthorized)
+		return
+	}
+
+	vaultRepo := db.NewVaultRepository(db.GetDB())
+	notes, err := vaultRepo.GetVaultNotes(userID)
+	if err != nil {
+		log.Printf("Error retrieving notes of user %d: %v", userID, err)
+		http.Error(w, "Error retrieving notes", http.StatusInternalServerError)
+		return
+	}
+
+	// The keys that signed the blocks before the current one, and the devices even once revoked, are listed in the
+	// signed manifest so that the importing server can verify the whole history of the chains
+	signerKeys, err := vaultSignerKeys(userID)
+	if err != nil {
+		log.Printf("Error retrieving signer keys of user %d: %v", userID, err)
+		http.Error(w, "Error retrieving keys", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/gzip")
+	w.Header().Set("Content-Disposition", "attachment; filename=\"canttouchme-vault.tar.gz\"")
+
+	// From here the archive is streamed, an error can only abort it: the client detects the truncated archive
+	writer := vault.NewWriter(w, models.VaultAccount{
+		Name:           user.Name,
+		Email:          user.Email,
+		PubKey:         user.PubKey,
+		LoginSalt:      user.LoginSalt,
+		EncryptionSalt: user.EncryptionSalt,
+		HMACSalt:       user.HMACSalt,
+		HMACType:       user.HMACType,
+		EncryptionType: user.EncryptionType,
+		SuiteID:        user.SuiteID,
+		KDF:            &user.KDF,
+		SignatureType:  user.SignatureType,
+		PQPubKey:       user.PQPubKey,
+		WrappedKeys:    user.WrappedKeys,
+		SignerKeys:     signerKeys,
+	})
+
+	for _, note := range notes {
+		if err := loadVaultNote(userID, note); err != nil {
+			log.Printf("Error exporting note %s of user %d: %v", note.NoteID, userID, err)
+			return
+		}
+		if err := writer.AddNote(note); err != nil {
+			log.Printf("Error writing note %s of user %d: %v", note.NoteID, userID, err)
+			return
+		}
+	}
+
+	if err := writer.Close(crypto.SignVaultManifest); err != nil {
+		log.Printf("Error writing the vault manifest of user %d: %v", userID, err)
+	}
+}
+
+// loadVaultNote fills a note read by GetVaultNotes with its blocks, tokens and time-stamp tokens.
+// Parameters:
+// - userID: the ID of the user
+// - note: a pointer to the note to fill
+// Returns: an error if a query fails
+func loadVaultNote(userID uint32, note *models.VaultNote) error {
+	blockRepo := db.NewBlockRepository(db.GetDB())
+	blockchain, err := blockRepo.GetNoteBlockChain(userID, note.NoteID)
+	if err != nil {
+		return err
+	}
+	if len(blockchain.Blocks) == 0 {
+		return fmt.Errorf("note %s has no blocks", note.NoteID)
+	}
+	note.Blocks = blockchain.Blocks
+
+	signers, err := blockRepo.GetNoteSigners(userID, note.NoteID)
+	if err != nil {
+		return err
+	}
+	pqSignerKeys := make([]string, len(signers))
+	hybrid := false
+	for i, signer := range signers {
+		note.SignerKeys = append(note.SignerKeys, signer.PubKey)
+		pqSignerKeys[i] = signer.PQPubKey
+		hybrid = hybrid || signer.PQPubKey != ""
+	}
+	// The ML-DSA keys are only listed when a block of the note has a hybrid signature
+	if hybrid {
+		note.PQSignerKeys = pqSignerKeys
+	}
+
+	vaultRepo := db.NewVaultRepository(db.GetDB())
+	tagTokens, searchTokens, err := vaultRepo.GetNoteTokens(userID, note.NoteID)
+	if err != nil {
+		return err
+	}
+	if note.Metadata != nil {
+		note.Metadata.TagTokens = tagTokens
+	}
+	note.SearchTokens = searchTokens
+
+	timestampRepo := db.NewTimestampRepository(db.GetDB())
+	timestamps, err := timestampRepo.GetNoteTimestamps(userID, note.NoteID)
+	if err != nil {
+		return err
+	}
+	for _, timestamp := range timestamps {
+		note.Timestamps = append(note.Timestamps, timestamp)
+	}
+	sort.Slice(note.Timestamps, func(i, j int) bool {
+		return note.Timestamps[i].Seq < note.Timestamps[j].Seq
+	})
+
+	return nil
+}
+
+// vaultSignerKeys lists every key that signed blocks of a user: the historic keys and the keys of all their devices,
+// revoked ones included since their blocks stay in the chains.
+// Parameters:
+// - userID: the ID of the user
+// Returns: the distinct Ed25519 and ML-DSA keys, or an error if a query error occurs
+func vaultSignerKeys(userID uint32) ([]string, error) {
+	keys, err := db.NewBlockRepository(db.GetDB()).GetUserSignerKeys(userID)
+	if err != nil {
+		return nil, err
+	}
+	devices, err := db.NewDeviceRepository(db.GetDB()).GetDevices(userID, false)
+	if err != nil {
+		return nil, err
+	}
+	for _, device := range devices {
+		keys = append(keys, device.PubKey)
+		if device.PQPubKey != "" {
+			keys = append(keys, device.PQPubKey)
+		}
+	}
+	slices.Sort(keys)
+	return slices.Compact(keys), nil
+}
@@ -3,19 +3,21 @@ package routes
 import (
 	"backend/crypto"
 	"backend/db"
+	"backend/models"
 	"backend/util"
 	"encoding/json"
-	"fmt"
+	"errors"
 	"log"
 	"net/http"
 )
 
 // GetNotesRequest defines the JSON shape for the note request from clients
 type GetNotesRequest struct {
-	NoteID uint `json:"note_id"`
+	NoteID string `json:"note_id"`
 }
 
-// Returns the latest block of a note
+// Returns the latest block of content of a note, followed by the lifecycle blocks appended since it was written
+// when the note was deleted and restored, so the client can hash its way to the head of the note
 func GetNoteHandler(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodPost {
 		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
@@ -43,13 +45,18 @@ func GetNoteHandler(w http.ResponseWriter, r *http.Request) {
 		http.Error(w, "Missing fields", http.StatusBadRequest)
 		return
 	}
+	if !validNoteID(request.NoteID) {
+		http.Error(w, "Invalid note ID", http.StatusBadRequest)
+		return
+	}
 
 	blockRepo := db.NewBlockRepository(db.GetDB())
 
-	// Fetch the note block for the given userID and noteID
-	block, err := blockRepo.GetNoteBlock(userID, request.NoteID)
+	// Fetch the head of the note for the given userID and noteID, with the keys that signed its blocks:
+	// the key of the user or of one of their devices
+	head, signers, err := blockRepo.GetNoteHead(userID, request.NoteID)
 	if err != nil {
-		if err.Error() == fmt.Sprintf("no block found for noteID %d and userID %d", request.NoteID, userID) {
+		if errors.Is(err, db.ErrNoteNotFound) {
 			http.Error(w, "Note not found", http.StatusNotFound)
 		} else {
 			log.Printf("Error retrieving note: %v", err)
@@ -58,22 +65,17 @@ func GetNoteHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// Fetch the public key from the database using the user ID
-	userRepo := db.NewUserRepository(db.GetDB())
-	user, err := userRepo.GetUserByID(userID)
-	if err != nil {
-		http.Error(w, "User not found", http.StatusUnauthorized)
-		return
-	}
-
-	// Check if the signature is valid
-	isValid, err := crypto.VerifyBlockEd25519Signature(user.PubKey, block)
-	if err != nil || !isValid {
-		http.Error(w, "Invalid signature!", http.StatusBadRequest)
-		return
+	// Check if the signatures are valid, both signatures for the hybrid signature types
+	blocks := append([]models.Block{head.Block}, head.Lifecycle...)
+	for i := range blocks {
+		isValid, err := crypto.VerifyBlockSignature(signers[i].PubKey, signers[i].PQPubKey, &blocks[i])
+		if err != nil || !isValid {
+			http.Error(w, "Invalid signature!", http.StatusBadRequest)
+			return
+		}
 	}
 
-	err = json.NewEncoder(w).Encode(block)
+	err = json.NewEncoder(w).Encode(head)
 	if err != nil {
 		log.Printf("Error encoding response: %v", err)
 	}
@@ -1,14 +1,13 @@
 package routes
 
 import (
-	"backend/db"
 	"backend/models"
-	"encoding/json"
-	"log"
 	"net/http"
 )
 
-// GetTitlesHandler gets titles of all the notes for a user
+// GetTitlesHandler gets a page of the titles of the notes of a user
+// The titles can be filtered with the folder and tag blind index tokens, and paginated with a cursor:
+// /notes/titles?folder=<token>&tag=<token>&sort=modified|created&order=desc|asc&limit=50&cursor=<next_cursor>
 func GetTitlesHandler(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodGet {
 		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
@@ -24,23 +23,26 @@ func GetTitlesHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	blockRepo := db.NewBlockRepository(db.GetDB())
-
-	// Fetch titles for the user
-	titles, err := blockRepo.GetTitles(userID)
-	if err != nil {
-		log.Printf("Error retrieving titles: %v", err)
-		http.Error(w, "Error retrieving titles", http.StatusInternalServerError)
+	// Parse the optional filters
+	query := r.URL.Query()
+	filter := models.TitleFilter{
+		FolderToken: query.Get("folder"),
+		TagTokens:   query["tag"],
+	}
+	if filter.FolderToken != "" && !validBlindIndexToken(filter.FolderToken) {
+		http.Error(w, "Invalid folder token", http.StatusBadRequest)
 		return
 	}
-
-	// Check if titles is nil and send an empty array if so
-	if titles == nil {
-		titles = []*models.Title{}
+	if err := validateTokens(filter.TagTokens, maxTagsPerNote); err != nil {
+		http.Error(w, "Invalid tag tokens: "+err.Error(), http.StatusBadRequest)
+		return
 	}
 
-	err = json.NewEncoder(w).Encode(titles)
+	page, err := parseTitlePagination(query)
 	if err != nil {
-		log.Printf("Error encoding response: %v", err)
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
 	}
+
+	writeTitlePage(w, userID, filter, page)
 }
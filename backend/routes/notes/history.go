@@ -0,0 +1,183 @@
+package routes
+
+import (
+	"backend/crypto"
+	"backend/db"
+	"backend/models"
+	"backend/util"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"time"
+)
+
+// noteHistory reads a note request and builds the history of the note, writing the error response if it fails.
+// Parameters:
+// - w: the response writer
+// - r: the request, its body is a GetNotesRequest
+// Returns: the ID of the note, the history of its blocks in chain order, the blocks themselves, and false if
+// an error response was written
+func noteHistory(w http.ResponseWriter, r *http.Request) (string, []*models.BlockHistory, []models.Block, bool) {
+	userID, ok := r.Context().Value("UserID").(uint32)
+	if !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return "", nil, nil, false
+	}
+
+	var request GetNotesRequest
+	err := json.NewDecoder(r.Body).Decode(&request)
+	if err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return "", nil, nil, false
+	}
+
+	if !util.ValidateStruct(request) {
+		http.Error(w, "Missing fields", http.StatusBadRequest)
+		return "", nil, nil, false
+	}
+	if !validNoteID(request.NoteID) {
+		http.Error(w, "Invalid note ID", http.StatusBadRequest)
+		return "", nil, nil, false
+	}
+
+	blockRepo := db.NewBlockRepository(db.GetDB())
+	if _, err := blockRepo.GetNote(userID, request.NoteID); err != nil {
+		if errors.Is(err, db.ErrNoteNotFound) {
+			http.Error(w, "Note not found", http.StatusNotFound)
+		} else {
+			log.Printf("Error retrieving note: %v", err)
+			http.Error(w, "Error retrieving note", http.StatusInternalServerError)
+		}
+		return "", nil, nil, false
+	}
+
+	blockchain, err := blockRepo.GetNoteBlockChain(userID, request.NoteID)
+	if err != nil {
+		log.Printf("Error retrieving blocks for user %d and note %s: %v", userID, request.NoteID, err)
+		http.Error(w, "Error retrieving blocks", http.StatusInternalServerError)
+		return "", nil, nil, false
+	}
+
+	timestampRepo := db.NewTimestampRepository(db.GetDB())
+	timestamps, err := timestampRepo.GetNoteTimestamps(userID, request.NoteID)
+	if err != nil {
+		log.Printf("Error retrieving timestamps for user %d and note %s: %v", userID, request.NoteID, err)
+		http.Error(w, "Error retrieving timestamps", http.StatusInternalServerError)
+		return "", nil, nil, false
+	}
+
+	// Each block is checked against the key that signed it, which differs from the current key of the user
+	// for blocks signed before a key change or imported from another vault
+	signers, err := blockRepo.GetNoteSigners(userID, request.NoteID)
+	if err != nil || len(signers) != len(blockchain.Blocks) {
+		log.Printf("Error retrieving signer keys for user %d and note %s: %v", userID, request.NoteID, err)
+		http.Error(w, "Error retrieving blocks", http.StatusInternalServerError)
+		return "", nil, nil, false
+	}
+
+	history := make([]*models.BlockHistory, len(blockchain.Blocks))
+	for i := range blockchain.Blocks {
+		block := &blockchain.Blocks[i]
+		seq := uint(i + 1)
+
+		blockHash, err := crypto.BlockHash(*block)
+		if err != nil {
+			log.Printf("Error hashing block %d of note %s: %v", seq, request.NoteID, err)
+			http.Error(w, "Error hashing blocks", http.StatusInternalServerError)
+			return "", nil, nil, false
+		}
+		validSignature, err := crypto.VerifyBlockSignature(signers[i].PubKey, signers[i].PQPubKey, block)
+
+		entry := &models.BlockHistory{
+			Seq:            seq,
+			BlockHash:      blockHash,
+			Timestamp:      block.Timestamp,
+			ValidSignature: err == nil && validSignature,
+			DeviceID:       signers[i].DeviceID,
+			Kind:           block.Kind,
+		}
+		if timestamp, ok := timestamps[seq]; ok {
+			entry.TimestampToken = verifyTimestamp(timestamp, blockHash, block.Timestamp)
+		}
+		history[i] = entry
+	}
+
+	return request.NoteID, history, blockchain.Blocks, true
+}
+
+// NoteHistoryHandler returns every version of a note with its signature and time-stamp token status
+func NoteHistoryHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+
+	_, history, _, ok := noteHistory(w, r)
+	if !ok {
+		return
+	}
+
+	err := json.NewEncoder(w).Encode(history)
+	if err != nil {
+		log.Printf("Error encoding response: %v", err)
+	}
+}
+
+// VerifyNoteHandler checks the chain, the signatures and the time-stamp tokens of a note and reports every problem
+func VerifyNoteHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+
+	noteID, history, blocks, ok := noteHistory(w, r)
+	if !ok {
+		return
+	}
+
+	report := models.NoteVerification{
+		NoteID:          noteID,
+		SignaturesValid: true,
+		TimestampsValid: true,
+		Blocks:          history,
+		Problems:        []string{},
+	}
+
+	chainValid, err := crypto.VerifyBlockChain(blocks)
+	report.ChainValid = err == nil && chainValid
+	if !report.ChainValid {
+		report.Problems = append(report.Problems, "the blocks do not form a valid chain")
+	}
+
+	for _, entry := range history {
+		if !entry.ValidSignature {
+			report.SignaturesValid = false
+			report.Problems = append(report.Problems, fmt.Sprintf("block %d has an invalid signature", entry.Seq))
+		}
+
+		status := entry.TimestampToken
+		switch {
+		case status == nil:
+			report.TimestampsValid = false
+			report.Problems = append(report.Problems, fmt.Sprintf("block %d has no time-stamp token", entry.Seq))
+		case !status.Verified:
+			report.TimestampsValid = false
+			report.Problems = append(report.Problems, fmt.Sprintf("block %d has an invalid time-stamp token: %s", entry.Seq, status.Error))
+		case skewTooLarge(status):
+			report.TimestampsValid = false
+			report.Problems = append(report.Problems, fmt.Sprintf("block %d claims %s, %.0f seconds away from its time-stamp token",
+				entry.Seq, entry.Timestamp.Format(time.RFC3339), status.ClockSkew))
+		}
+	}
+
+	err = json.NewEncoder(w).Encode(report)
+	if err != nil {
+		log.Printf("Error encoding response: %v", err)
+	}
+}
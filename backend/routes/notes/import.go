@@ -0,0 +1,272 @@
+package routes
+
+import (
+	"backend/config"
+	"backend/crypto"
+	"backend/db"
+	"backend/models"
+	"backend/vault"
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"slices"
+)
+
+// Conflict policies of the import, chosen with the on_conflict query parameter
+const (
+	conflictSkip   = "skip"   // Keep the existing note and skip the imported one
+	conflictRename = "rename" // Store the imported note under a new random ID
+)
+
+// importProofHeader carries the proof that the owner of the archived account allows the import.
+// It is only needed when the archive was exported from an account with another key, typically on another instance:
+// the client derives the old key from the password and the login salt of the archive and signs the current key.
+const importProofHeader = "X-Import-Proof"
+
+// ImportHandler imports a vault archive written by the export endpoint.
+// Every note is verified on its own, its chain and block signatures are checked against the keys that signed
+// them, and it is stored atomically; the response reports what happened to each note.
+// The manifest must be signed by this server, or by one of the servers the operator trusts in TRUSTED_SERVER_KEYS:
+// the key is chosen by the client, so any other key is refused, and an archive whose manifest does not verify is rejected.
+// Query parameters: on_conflict (skip or rename, default skip), and server_key, the Base64 public key of the
+// exporting server, required when the archive comes from another server.
+func ImportHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+
+	userID, ok := r.Context().Value("UserID").(uint32)
+	if !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	query := r.URL.Query()
+	onConflict := query.Get("on_conflict")
+	if onConflict == "" {
+		onConflict = conflictSkip
+	}
+	if onConflict != conflictSkip && onConflict != conflictRename {
+		http.Error(w, "on_conflict must be skip or rename", http.StatusBadRequest)
+		return
+	}
+
+	maxSize := int64(config.GetConfig().ImportMaxMB) << 20
+	archive, err := vault.Read(http.MaxBytesReader(w, r.Body, maxSize), maxSize)
+	if err != nil {
+		http.Error(w, "Invalid archive: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	// The manifest lists the hashes of the notes and the keys of the account, nothing is trusted before it verifies.
+	// A manifest signed by a key the client picked proves nothing, only this server and the configured ones count.
+	localKey, err := crypto.ServerPublicKey()
+	if err != nil {
+		log.Printf("Error retrieving the server key: %v", err)
+		http.Error(w, "Error verifying the archive", http.StatusInternalServerError)
+		return
+	}
+	serverKey := query.Get("server_key")
+	if serverKey == "" {
+		serverKey = localKey.PublicKey
+	}
+	if serverKey != localKey.PublicKey && !slices.Contains(config.GetConfig().TrustedServerKeys, serverKey) {
+		http.Error(w, "The server_key is not one of the servers trusted by this instance", http.StatusForbidden)
+		return
+	}
+	valid, err := crypto.VerifyVaultManifest(serverKey, archive.ManifestBytes, archive.Signature)
+	if err != nil {
+		http.Error(w, "Invalid server_key: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	if !valid {
+		http.Error(w, "The signature of the manifest does not verify, give the server_key of the exporting server", http.StatusBadRequest)
+		return
+	}
+
+	userRepo := db.NewUserRepository(db.GetDB())
+	user, err := userRepo.GetUserByID(userID)
+	if err != nil {
+		http.Error(w, "User not found", http.StatusUnauthorized)
+		return
+	}
+
+	// The current key of the user, the keys of their devices and every key that signed one of their blocks
+	blockRepo := db.NewBlockRepository(db.GetDB())
+	knownKeys, err := blockRepo.GetUserSignerKeys(userID)
+	if err != nil {
+		log.Printf("Error retrieving signer keys of user %d: %v", userID, err)
+		http.Error(w, "Error retrieving keys", http.StatusInternalServerError)
+		return
+	}
+	trusted := map[string]bool{user.PubKey: true}
+	if user.PQPubKey != "" {
+		trusted[user.PQPubKey] = true
+	}
+	for _, key := range knownKeys {
+		trusted[key] = true
+	}
+	deviceRepo := db.NewDeviceRepository(db.GetDB())
+	devices, err := deviceRepo.GetDevices(userID, true)
+	if err != nil {
+		log.Printf("Error retrieving devices of user %d: %v", userID, err)
+		http.Error(w, "Error retrieving keys", http.StatusInternalServerError)
+		return
+	}
+	for _, device := range devices {
+		trusted[device.PubKey] = true
+		if device.PQPubKey != "" {
+			trusted[device.PQPubKey] = true
+		}
+	}
+
+	// An archive of another account needs the proof that its owner allows this account to import it
+	archiveKey := archive.Manifest.Account.PubKey
+	if !trusted[archiveKey] {
+		proof := r.Header.Get(importProofHeader)
+		valid, err := crypto.VerifyImportEd25519Signature(archiveKey, user.PubKey, proof)
+		if proof == "" || err != nil || !valid {
+			http.Error(w, "The archive belongs to another account, a valid "+importProofHeader+" header is required", http.StatusForbidden)
+			return
+		}
+	}
+
+	// Once the archive is allowed, its account key may sign its blocks, with the post-quantum key and the historic and
+	// device keys the signed manifest lists. The signer keys listed by the notes themselves are never trusted.
+	trusted[archiveKey] = true
+	if archive.Manifest.Account.PQPubKey != "" {
+		trusted[archive.Manifest.Account.PQPubKey] = true
+	}
+	for _, key := range archive.Manifest.Account.SignerKeys {
+		trusted[key] = true
+	}
+
+	report := models.ImportReport{Notes: []*models.ImportResult{}, ManifestVerified: &valid}
+
+	// The blocks keep the suite of the account that wrote them, 0 when the archive does not tell
+	var suiteID uint16
+	account := archive.Manifest.Account
+	if suite, err := crypto.SuiteOf(&models.User{SuiteID: account.SuiteID, EncryptionType: account.EncryptionType, HMACType: account.HMACType}); err == nil {
+		suiteID = suite.ID
+	}
+
+	for _, note := range archive.Notes {
+		report.Notes = append(report.Notes, importNote(userID, note, suiteID, onConflict, func(key string) bool {
+			return trusted[key]
+		}))
+	}
+
+	err = json.NewEncoder(w).Encode(report)
+	if err != nil {
+		log.Printf("Error encoding response: %v", err)
+	}
+}
+
+// importNote verifies and stores one note of an archive.
+// Parameters:
+// - userID: the ID of the user importing the note
+// - note: a pointer to the note to import
+// - suiteID: the crypto suite of the account of the archive
+// - onConflict: the conflict policy, conflictSkip or conflictRename
+// - trusted: tells whether a public key may sign the blocks of the note
+// Returns: the outcome of the import of the note
+func importNote(userID uint32, note *models.VaultNote, suiteID uint16, onConflict string, trusted func(string) bool) *models.ImportResult {
+	result := &models.ImportResult{NoteID: note.NoteID}
+
+	if !validNoteID(note.NoteID) {
+		result.Status = models.ImportStatusRejected
+		result.Error = "invalid note ID"
+		return result
+	}
+	if err := vault.VerifyNote(note, trusted); err != nil {
+		result.Status = models.ImportStatusRejected
+		result.Error = err.Error()
+		return result
+	}
+	if err := validateTokens(note.SearchTokens, maxSearchTokensPerVersion); err != nil {
+		result.Status = models.ImportStatusRejected
+		result.Error = "invalid search tokens: " + err.Error()
+		return result
+	}
+	if note.Metadata != nil {
+		if note.Metadata.FolderToken != "" && !validBlindIndexToken(note.Metadata.FolderToken) {
+			result.Status = models.ImportStatusRejected
+			result.Error = "invalid folder token"
+			return result
+		}
+		if err := validateTokens(note.Metadata.TagTokens, maxTagsPerNote); err != nil {
+			result.Status = models.ImportStatusRejected
+			result.Error = "invalid tag tokens: " + err.Error()
+			return result
+		}
+	}
+
+	importRepo := db.NewImportRepository(db.GetDB())
+	noteID := note.NoteID
+
+	headHash, err := importRepo.FindNote(userID, noteID)
+	switch {
+	case err == nil && headHash == note.HeadHash:
+		result.ImportedAs = noteID
+		result.Status = models.ImportStatusUnchanged
+		return result
+	case err == nil && onConflict == conflictSkip:
+		result.Status = models.ImportStatusSkipped
+		result.Error = "a note with this ID already exists"
+		return result
+	case err == nil:
+		if noteID, err = crypto.GenerateIDHex(16); err != nil {
+			log.Printf("Error generating note ID: %v", err)
+			result.Status = models.ImportStatusFailed
+			result.Error = "the note could not be stored"
+			return result
+		}
+	case !errors.Is(err, db.ErrNoteNotFound):
+		log.Printf("Error looking up note %s: %v", noteID, err)
+		result.Status = models.ImportStatusFailed
+		result.Error = "the note could not be stored"
+		return result
+	}
+
+	for i := range note.Blocks {
+		note.Blocks[i].SuiteID = suiteID
+	}
+	err = importRepo.ImportNote(userID, noteID, note)
+	if errors.Is(err, db.ErrNoteExists) && noteID == note.NoteID {
+		// The ID is taken by a note the user does not own, or by one another import just stored for them
+		if _, findErr := importRepo.FindNote(userID, noteID); findErr == nil {
+			result.Status = models.ImportStatusSkipped
+			result.Error = "a note with this ID already exists"
+			return result
+		}
+		if noteID, err = crypto.GenerateIDHex(16); err == nil {
+			err = importRepo.ImportNote(userID, noteID, note)
+		}
+	}
+	switch {
+	case errors.Is(err, db.ErrNoteNotRenamable):
+		result.Status = models.ImportStatusSkipped
+		result.Error = "the deletions and restores of the note are signed for its ID, it cannot be stored under a new one"
+		return result
+	case errors.Is(err, db.ErrNoteExists):
+		result.Status = models.ImportStatusSkipped
+		result.Error = "a note with this ID already exists"
+		return result
+	case err != nil:
+		log.Printf("Error importing note %s for user %d: %v", noteID, userID, err)
+		result.Status = models.ImportStatusFailed
+		result.Error = "the note could not be stored"
+		return result
+	}
+
+	result.ImportedAs = noteID
+	result.Status = models.ImportStatusImported
+	if noteID != note.NoteID {
+		result.Status = models.ImportStatusRenamed
+	}
+	return result
+}
@@ -4,18 +4,25 @@ import (
 	"backend/crypto"
 	"backend/db"
 	"backend/models"
-	"backend/util"
 	"encoding/json"
+	"errors"
 	"log"
 	"net/http"
 	"time"
 )
 
+// NewNoteRequest is the first block of the note plus the keyword tokens of its content
+type NewNoteRequest struct {
+	models.Block
+	SearchTokens []string `json:"search_tokens,omitempty"`
+}
+
 // Bassically the same logic as add block but with an id generated by the db
 type NewNoteResponse struct {
-	TimeStamp string `json:"timestamp"`
-	NoteID    uint   `json:"note_id"`
-	Message   string `json:"message"`
+	TimeStamp string          `json:"timestamp"`
+	NoteID    string          `json:"note_id"`
+	Message   string          `json:"message"`
+	Receipt   *models.Receipt `json:"receipt,omitempty"` // Server signed proof that the first block was stored
 }
 
 func NewNoteHandler(w http.ResponseWriter, r *http.Request) {
@@ -27,16 +34,16 @@ func NewNoteHandler(w http.ResponseWriter, r *http.Request) {
 	w.Header().Set("Content-Type", "application/json")
 
 	// Decode the request payload into NewNoteRequest
-	var request models.Block
+	var request NewNoteRequest
 	err := json.NewDecoder(r.Body).Decode(&request)
 	if err != nil {
 		http.Error(w, "Invalid request body", http.StatusBadRequest)
 		return
 	}
 
-	// Validate the request body
-	if !util.ValidateStruct(request) {
-		http.Error(w, "Missing fields", http.StatusBadRequest)
+	// Validate the keyword tokens of the first version
+	if err := validateTokens(request.SearchTokens, maxSearchTokensPerVersion); err != nil {
+		http.Error(w, "Invalid search tokens: "+err.Error(), http.StatusBadRequest)
 		return
 	}
 
@@ -61,17 +68,36 @@ func NewNoteHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// Check if the signature is valid
-	isValid, err := crypto.VerifyBlockEd25519Signature(user.PubKey, &request)
-	if err != nil || !isValid {
+	// The block must carry the fields of the crypto suite of the user, and is stored with its ID
+	suite, err := crypto.SuiteOf(user)
+	if err != nil {
+		log.Printf("Error resolving the crypto suite of user %d: %v", userID, err)
+		http.Error(w, "Unsupported crypto suite", http.StatusInternalServerError)
+		return
+	}
+	if err := crypto.ValidateBlockFields(&request.Block, suite); err != nil {
+		http.Error(w, "Invalid block: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	request.Block.SuiteID = suite.ID
+
+	// The block must be signed by the key of the user or of one of their active devices,
+	// both signatures for the hybrid signature types
+	signer, err := blockSigner(user, &request.Block)
+	switch {
+	case errors.Is(err, errUnknownSigner):
 		http.Error(w, "Invalid signature", http.StatusBadRequest)
 		return
+	case err != nil:
+		log.Printf("Error retrieving devices of user %d: %v", userID, err)
+		http.Error(w, "Error verifying signature", http.StatusInternalServerError)
+		return
 	}
 
 	blockRepo := db.NewBlockRepository(db.GetDB())
 
-	// Create a new note in the database
-	NoteId, err := blockRepo.CreateNewNote(userID, &request)
+	// Create a new note in the database, with the keyword tokens of its first version
+	NoteId, err := blockRepo.CreateNewNote(userID, &request.Block, *signer, request.SearchTokens)
 	if err != nil {
 		log.Printf("Error creating new note: %v", err)
 		http.Error(w, "Error creating block", http.StatusInternalServerError)
@@ -84,6 +110,15 @@ func NewNoteHandler(w http.ResponseWriter, r *http.Request) {
 		Message:   "Block created successfully!",
 	}
 
+	headHash, err := crypto.BlockHash(request.Block)
+	if err != nil {
+		log.Printf("Error hashing block for user %d and note %s: %v", userID, NoteId, err)
+	} else {
+		// The first block is always at seq 1
+		response.Receipt = signReceipt(NoteId, 1, headHash)
+		go timestampBlock(NoteId, 1, headHash)
+	}
+
 	err = json.NewEncoder(w).Encode(response)
 	if err != nil {
 		log.Printf("Error encoding response: %v", err)
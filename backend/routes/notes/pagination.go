@@ -0,0 +1,170 @@
+package routes
+
+import (
+	"backend/db"
+	"backend/models"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	defaultTitlesLimit = 50  // page size when the client does not send a limit
+	maxTitlesLimit     = 200 // largest page a client can request
+)
+
+// parseTitlePagination reads the pagination parameters of a titles request.
+// Supported parameters: sort (modified or created), order (desc or asc), limit and cursor.
+// Parameters:
+// - query: the URL query parameters of the request
+// Returns: the pagination to apply, or an error if a parameter is invalid
+func parseTitlePagination(query url.Values) (models.TitlePagination, error) {
+	page := models.TitlePagination{
+		Sort:  models.SortByModified,
+		Limit: defaultTitlesLimit,
+	}
+
+	switch query.Get("sort") {
+	case "", models.SortByModified:
+	case models.SortByCreated:
+		page.Sort = models.SortByCreated
+	default:
+		return page, errors.New("sort must be either modified or created")
+	}
+
+	switch query.Get("order") {
+	case "", "desc":
+	case "asc":
+		page.Ascending = true
+	default:
+		return page, errors.New("order must be either asc or desc")
+	}
+
+	if limit := query.Get("limit"); limit != "" {
+		value, err := strconv.Atoi(limit)
+		if err != nil || value < 1 || value > maxTitlesLimit {
+			return page, fmt.Errorf("limit must be between 1 and %d", maxTitlesLimit)
+		}
+		page.Limit = value
+	}
+
+	if cursor := query.Get("cursor"); cursor != "" {
+		after, err := decodeTitleCursor(cursor)
+		if err != nil {
+			return page, err
+		}
+		page.After = after
+	}
+
+	return page, nil
+}
+
+// encodeTitleCursor builds the opaque cursor pointing right after a title.
+// Parameters:
+// - title: the last title of the page
+// - sort: the sort order of the page
+// Returns: the base64url encoded cursor
+func encodeTitleCursor(title *models.Title, sort string) string {
+	sortValue := title.Timestamp
+	if sort == models.SortByCreated {
+		sortValue = title.CreatedAt
+	}
+	// Nanoseconds keep the exact timestamp read from the database, seconds would skip or repeat the notes of a
+	// column with fractional seconds
+	raw := fmt.Sprintf("%d:%s", sortValue.UnixNano(), title.NoteID)
+	return base64.RawURLEncoding.EncodeToString([]byte(raw))
+}
+
+// decodeTitleCursor parses a cursor created by encodeTitleCursor.
+// Parameters:
+// - cursor: the base64url encoded cursor sent by the client
+// Returns: the decoded cursor, or an error if the cursor is malformed
+func decodeTitleCursor(cursor string) (*models.TitleCursor, error) {
+	invalid := errors.New("invalid cursor")
+
+	raw, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return nil, invalid
+	}
+	parts := strings.SplitN(string(raw), ":", 2)
+	if len(parts) != 2 {
+		return nil, invalid
+	}
+
+	nanoseconds, err := strconv.ParseInt(parts[0], 10, 64)
+	if err != nil {
+		return nil, invalid
+	}
+	if !validNoteID(parts[1]) {
+		return nil, invalid
+	}
+
+	return &models.TitleCursor{
+		SortValue: time.Unix(0, nanoseconds).UTC(),
+		NoteID:    parts[1],
+	}, nil
+}
+
+// writeTitlePage fetches a page of titles and writes it as the JSON response.
+// Parameters:
+// - w: the response writer
+// - userID: the ID of the user
+// - filter: the folder, tag and keyword tokens the notes must match
+// - page: the page to return
+func writeTitlePage(w http.ResponseWriter, userID uint32, filter models.TitleFilter, page models.TitlePagination) {
+	blockRepo := db.NewBlockRepository(db.GetDB())
+
+	titles, hasMore, err := blockRepo.GetTitles(userID, filter, page)
+	if err != nil {
+		log.Printf("Error retrieving titles: %v", err)
+		http.Error(w, "Error retrieving titles", http.StatusInternalServerError)
+		return
+	}
+
+	// The total only changes with the filter, so it is counted for the first page alone
+	var total *int
+	if page.After == nil {
+		count, err := blockRepo.CountTitles(userID, filter)
+		if err != nil {
+			log.Printf("Error counting titles: %v", err)
+			http.Error(w, "Error retrieving titles", http.StatusInternalServerError)
+			return
+		}
+		total = &count
+	}
+
+	// The root of the account log lets the client check that no note was dropped or rolled back, it is stored
+	// with every append so the page never reads the whole log
+	head, err := db.NewAccountLogRepository(db.GetDB()).GetTreeHead(userID)
+	if err != nil {
+		log.Printf("Error loading account log: %v", err)
+		http.Error(w, "Error retrieving titles", http.StatusInternalServerError)
+		return
+	}
+
+	// Check if titles is nil and send an empty array if so
+	if titles == nil {
+		titles = []*models.Title{}
+	}
+
+	response := models.TitlePage{
+		Titles:   titles,
+		Total:    total,
+		TreeHead: head,
+	}
+	if hasMore {
+		response.NextCursor = encodeTitleCursor(titles[len(titles)-1], page.Sort)
+	}
+
+	err = json.NewEncoder(w).Encode(response)
+	if err != nil {
+		log.Printf("Error encoding response: %v", err)
+	}
+}
@@ -0,0 +1,135 @@
+package routes
+
+import (
+	"backend/models"
+	"encoding/base64"
+	"net/url"
+	"testing"
+	"time"
+)
+
+const testNoteID = "0123456789abcdef0123456789abcdef"
+
+func TestTitleCursorRoundTrip(t *testing.T) {
+	modified := time.Date(2025, 3, 14, 15, 9, 26, 535897932, time.UTC)
+	created := time.Date(2024, 1, 2, 3, 4, 5, 6, time.UTC)
+	title := &models.Title{NoteID: testNoteID, Timestamp: modified, CreatedAt: created}
+
+	tests := []struct {
+		sort string
+		want time.Time
+	}{
+		{models.SortByModified, modified},
+		{models.SortByCreated, created},
+	}
+	for _, tt := range tests {
+		cursor, err := decodeTitleCursor(encodeTitleCursor(title, tt.sort))
+		if err != nil {
+			t.Fatalf("%s: %v", tt.sort, err)
+		}
+		if !cursor.SortValue.Equal(tt.want) || cursor.NoteID != testNoteID {
+			t.Errorf("%s: decoded %v %s, want %v %s", tt.sort, cursor.SortValue, cursor.NoteID, tt.want, testNoteID)
+		}
+	}
+}
+
+func TestTitleCursorKeepsTheExactTimestamp(t *testing.T) {
+	// Timestamps of the same second, and the edges of the range a cursor can hold
+	second := time.Date(2025, 6, 1, 12, 0, 0, 0, time.UTC)
+	times := []time.Time{
+		second,
+		second.Add(time.Nanosecond),
+		second.Add(time.Microsecond),
+		second.Add(time.Second - time.Nanosecond),
+		time.Unix(0, 0),
+		time.Unix(0, -1),
+		time.Date(1970, 1, 1, 0, 0, 0, 1, time.FixedZone("UTC+1", 3600)),
+	}
+
+	seen := map[string]bool{}
+	for _, value := range times {
+		encoded := encodeTitleCursor(&models.Title{NoteID: testNoteID, Timestamp: value}, models.SortByModified)
+		if seen[encoded] {
+			t.Errorf("%v: the cursor collides with another timestamp", value)
+		}
+		seen[encoded] = true
+
+		cursor, err := decodeTitleCursor(encoded)
+		if err != nil {
+			t.Fatalf("%v: %v", value, err)
+		}
+		if !cursor.SortValue.Equal(value) {
+			t.Errorf("decoded %v, want %v", cursor.SortValue, value)
+		}
+		if cursor.SortValue.Location() != time.UTC {
+			t.Errorf("%v: decoded in %v, want UTC", value, cursor.SortValue.Location())
+		}
+	}
+}
+
+func TestDecodeTitleCursorRejectsMalformedCursors(t *testing.T) {
+	encode := func(raw string) string {
+		return base64.RawURLEncoding.EncodeToString([]byte(raw))
+	}
+
+	tests := []struct {
+		name   string
+		cursor string
+	}{
+		{"not base64url", "not a cursor!"},
+		{"padded base64", base64.URLEncoding.EncodeToString([]byte("1:" + testNoteID))},
+		{"missing separator", encode("1700000000" + testNoteID)},
+		{"missing timestamp", encode(":" + testNoteID)},
+		{"timestamp not a number", encode("yesterday:" + testNoteID)},
+		{"timestamp overflowing", encode("99999999999999999999:" + testNoteID)},
+		{"missing note ID", encode("1700000000:")},
+		{"short note ID", encode("1700000000:" + testNoteID[1:])},
+		{"upper case note ID", encode("1700000000:0123456789ABCDEF0123456789ABCDEF")},
+		{"note ID with a separator", encode("1700000000:" + testNoteID[:31] + ":")},
+	}
+	for _, tt := range tests {
+		if _, err := decodeTitleCursor(tt.cursor); err == nil {
+			t.Errorf("%s: the cursor was accepted", tt.name)
+		}
+	}
+}
+
+func TestParseTitlePagination(t *testing.T) {
+	page, err := parseTitlePagination(url.Values{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if page.Sort != models.SortByModified || page.Ascending || page.Limit != defaultTitlesLimit || page.After != nil {
+		t.Errorf("unexpected default pagination %+v", page)
+	}
+
+	cursor := encodeTitleCursor(&models.Title{NoteID: testNoteID, CreatedAt: time.Unix(1700000000, 42)}, models.SortByCreated)
+	page, err = parseTitlePagination(url.Values{"sort": {"created"}, "order": {"asc"}, "limit": {"200"}, "cursor": {cursor}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if page.Sort != models.SortByCreated || !page.Ascending || page.Limit != maxTitlesLimit {
+		t.Errorf("unexpected pagination %+v", page)
+	}
+	if page.After == nil || !page.After.SortValue.Equal(time.Unix(1700000000, 42)) || page.After.NoteID != testNoteID {
+		t.Errorf("unexpected cursor %+v", page.After)
+	}
+
+	invalid := []url.Values{
+		{"limit": {"0"}},
+		{"limit": {"201"}},
+		{"limit": {"-1"}},
+		{"limit": {"ten"}},
+		{"sort": {"title"}},
+		{"order": {"up"}},
+		{"cursor": {"invalid!"}},
+	}
+	for _, query := range invalid {
+		if _, err := parseTitlePagination(query); err == nil {
+			t.Errorf("%v was accepted", query)
+		}
+	}
+	if page, err := parseTitlePagination(url.Values{"limit": {"1"}}); err != nil || page.Limit != 1 {
+		t.Errorf("the smallest page was refused: %v", err)
+	}
+}
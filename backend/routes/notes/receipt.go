@@ -0,0 +1,30 @@
+package routes
+
+import (
+	"backend/crypto"
+	"backend/models"
+	"log"
+	"time"
+)
+
+// signReceipt builds and signs the receipt of a block that was just stored.
+// A receipt that cannot be signed is logged and omitted, the block itself is already committed.
+// Parameters:
+// - noteID: the ID of the note
+// - seq: the position of the block in the note chain
+// - blockHash: the hash of the stored block
+// Returns: the signed receipt, or nil if the signing failed
+func signReceipt(noteID string, seq uint, blockHash string) *models.Receipt {
+	receipt := &models.Receipt{
+		NoteID:     noteID,
+		Seq:        seq,
+		BlockHash:  blockHash,
+		ReceivedAt: time.Now().UTC().Truncate(time.Second),
+	}
+
+	if err := crypto.SignReceipt(receipt); err != nil {
+		log.Printf("Error signing receipt for note %s: %v", noteID, err)
+		return nil
+	}
+	return receipt
+}
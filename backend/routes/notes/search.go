@@ -0,0 +1,71 @@
+package routes
+
+import (
+	"backend/models"
+	"encoding/json"
+	"net/http"
+)
+
+// maxSearchTokensPerVersion limits how many keyword tokens can be indexed for one note version
+const maxSearchTokensPerVersion = 2048
+
+// maxSearchTokensPerQuery limits how many keyword tokens a single search can contain
+const maxSearchTokensPerQuery = 32
+
+// SearchRequest defines the JSON shape of a search request.
+// Tokens are computed by the client with a keyed hash of each keyword, so the server can match
+// them against the index without learning the keywords.
+type SearchRequest struct {
+	Tokens []string `json:"tokens"`
+	Match  string   `json:"match,omitempty"` // "all" (default) or "any"
+}
+
+// SearchHandler returns a page of the titles of the notes whose current version matches the keyword tokens
+func SearchHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+
+	// Extract userID from context
+	userID, ok := r.Context().Value("UserID").(uint32)
+	if !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	var request SearchRequest
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	switch {
+	case len(request.Tokens) == 0:
+		http.Error(w, "Missing fields", http.StatusBadRequest)
+		return
+	case request.Match != "" && request.Match != "all" && request.Match != "any":
+		http.Error(w, "match must be either all or any", http.StatusBadRequest)
+		return
+	}
+	if err := validateTokens(request.Tokens, maxSearchTokensPerQuery); err != nil {
+		http.Error(w, "Invalid search tokens: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	filter := models.TitleFilter{
+		SearchTokens:    request.Tokens,
+		MatchAnyKeyword: request.Match == "any",
+	}
+
+	// The matches are paginated like the titles endpoint, through the query parameters
+	page, err := parseTitlePagination(r.URL.Query())
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	writeTitlePage(w, userID, filter, page)
+}
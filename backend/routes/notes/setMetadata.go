@@ -0,0 +1,87 @@
+package routes
+
+import (
+	"backend/db"
+	"backend/models"
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+)
+
+// maxTagsPerNote limits how many tag tokens a single note can have
+const maxTagsPerNote = 64
+
+// SetMetadataResponse is the response of the set metadata endpoint
+type SetMetadataResponse struct {
+	Message string `json:"message"`
+}
+
+// SetMetadataHandler stores the encrypted folder and tags of a note.
+// The client sends the metadata encrypted and the blind index tokens (HMAC of the folder and tag names),
+// so a note can be moved between folders without appending a new block to its chain.
+func SetMetadataHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+
+	// Extract userID from context
+	userID, ok := r.Context().Value("UserID").(uint32)
+	if !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	var request models.NoteMetadata
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	// The folder token is optional, everything else is required
+	if request.NoteID == "" || request.CipherMeta == "" || request.IVMeta == "" {
+		http.Error(w, "Missing fields", http.StatusBadRequest)
+		return
+	}
+	if !validNoteID(request.NoteID) {
+		http.Error(w, "Invalid note ID", http.StatusBadRequest)
+		return
+	}
+
+	// Validate the blind index tokens
+	if request.FolderToken != "" && !validBlindIndexToken(request.FolderToken) {
+		http.Error(w, "Invalid folder token", http.StatusBadRequest)
+		return
+	}
+	if err := validateTokens(request.TagTokens, maxTagsPerNote); err != nil {
+		http.Error(w, "Invalid tag tokens: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	// Make sure the note exists and belongs to the user
+	blockRepo := db.NewBlockRepository(db.GetDB())
+	if _, err := blockRepo.GetNote(userID, request.NoteID); err != nil {
+		if errors.Is(err, db.ErrNoteNotFound) {
+			http.Error(w, "Note not found", http.StatusNotFound)
+		} else {
+			log.Printf("Error retrieving note: %v", err)
+			http.Error(w, "Error retrieving note", http.StatusInternalServerError)
+		}
+		return
+	}
+
+	metaRepo := db.NewMetadataRepository(db.GetDB())
+	if err := metaRepo.SetNoteMetadata(userID, &request); err != nil {
+		log.Printf("Error storing metadata for user %d and note %s: %v", userID, request.NoteID, err)
+		http.Error(w, "Error storing metadata", http.StatusInternalServerError)
+		return
+	}
+
+	err := json.NewEncoder(w).Encode(SetMetadataResponse{Message: "Metadata updated successfully!"})
+	if err != nil {
+		log.Printf("Error encoding response: %v", err)
+	}
+}
@@ -0,0 +1,36 @@
+package routes
+
+import (
+	"backend/crypto"
+	"backend/db"
+	"backend/models"
+	"errors"
+)
+
+// errUnknownSigner is returned when no active key of the user verifies the signatures of a block
+var errUnknownSigner = errors.New("the block is not signed by an active key of the user")
+
+// blockSigner finds the key that signed a new block: the key derived from the password of the user,
+// or the key of one of their active devices.
+// Parameters:
+// - user: a pointer to the user
+// - block: a pointer to the block, both signatures are checked for the hybrid signature types
+// Returns: the keys the block was verified with, errUnknownSigner if no active key verifies it,
+// or an error if the devices cannot be retrieved
+func blockSigner(user *models.User, block *models.Block) (*models.BlockSigner, error) {
+	if valid, err := crypto.VerifyBlockSignature(user.PubKey, user.PQPubKey, block); err == nil && valid {
+		return &models.BlockSigner{PubKey: user.PubKey, PQPubKey: user.PQPubKey}, nil
+	}
+
+	deviceRepo := db.NewDeviceRepository(db.GetDB())
+	devices, err := deviceRepo.GetDevices(user.ID, true)
+	if err != nil {
+		return nil, err
+	}
+	for _, device := range devices {
+		if valid, err := crypto.VerifyBlockSignature(device.PubKey, device.PQPubKey, block); err == nil && valid {
+			return &models.BlockSigner{PubKey: device.PubKey, PQPubKey: device.PQPubKey, DeviceID: &device.ID}, nil
+		}
+	}
+	return nil, errUnknownSigner
+}
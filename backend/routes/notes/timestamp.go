@@ -0,0 +1,104 @@
+package routes
+
+import (
+	"backend/db"
+	"backend/models"
+	"backend/tsa"
+	"encoding/base64"
+	"errors"
+	"log"
+	"math"
+	"time"
+)
+
+// maxClockSkew is how far the timestamp claimed in a block may be from the time of its token.
+// The client stamps the block right before sending it, so a larger gap means the claimed time is wrong.
+const maxClockSkew = 5 * time.Minute
+
+// timestampBlock obtains a time-stamp token over the hash of a block that was just stored and saves it.
+// It runs after the response is sent, so a slow or unreachable authority never delays the edition of a note;
+// a block without a token is reported as such by the history and verify endpoints.
+// Parameters:
+// - noteID: the ID of the note
+// - seq: the position of the block in the note chain
+// - blockHash: the Base64 hash of the stored block
+func timestampBlock(noteID string, seq uint, blockHash string) {
+	authority, err := tsa.GetAuthority()
+	if err != nil {
+		if !errors.Is(err, tsa.ErrNoAuthority) {
+			log.Printf("Error getting the time-stamp authority: %v", err)
+		}
+		return
+	}
+
+	hash, err := base64.StdEncoding.DecodeString(blockHash)
+	if err != nil {
+		log.Printf("Error decoding hash of block %d of note %s: %v", seq, noteID, err)
+		return
+	}
+
+	token, err := authority.Timestamp(hash)
+	if err != nil {
+		log.Printf("Error timestamping block %d of note %s: %v", seq, noteID, err)
+		return
+	}
+
+	// Never store a token that could not be verified later
+	info, err := tsa.Verify(token, hash, authority)
+	if err != nil {
+		log.Printf("Invalid time-stamp token for block %d of note %s: %v", seq, noteID, err)
+		return
+	}
+
+	timestampRepo := db.NewTimestampRepository(db.GetDB())
+	err = timestampRepo.SaveBlockTimestamp(noteID, &models.BlockTimestamp{
+		Seq:       seq,
+		Authority: authority.Name(),
+		GenTime:   info.GenTime,
+		Token:     token,
+	})
+	if err != nil {
+		log.Printf("Error saving time-stamp token: %v", err)
+	}
+}
+
+// verifyTimestamp checks the time-stamp token of a block.
+// Parameters:
+// - timestamp: the stored token of the block
+// - blockHash: the Base64 hash of the block
+// - claimed: the timestamp written in the block by the client
+// Returns: the verification status of the token
+func verifyTimestamp(timestamp *models.BlockTimestamp, blockHash string, claimed time.Time) *models.TimestampStatus {
+	status := &models.TimestampStatus{
+		BlockTimestamp: *timestamp,
+		ClockSkew:      timestamp.GenTime.Sub(claimed).Seconds(),
+	}
+
+	authority, err := tsa.GetAuthority()
+	if err != nil {
+		status.Error = err.Error()
+		return status
+	}
+	hash, err := base64.StdEncoding.DecodeString(blockHash)
+	if err != nil {
+		status.Error = "invalid block hash"
+		return status
+	}
+
+	info, err := tsa.Verify(timestamp.Token, hash, authority)
+	if err != nil {
+		status.Error = err.Error()
+		return status
+	}
+
+	// The time signed by the authority is the one to trust, not the one stored next to the token
+	status.GenTime = info.GenTime
+	status.ClockSkew = info.GenTime.Sub(claimed).Seconds()
+	status.Verified = true
+	return status
+}
+
+// skewTooLarge tells whether the claimed time of a block is too far from the time of its token
+func skewTooLarge(status *models.TimestampStatus) bool {
+	return math.Abs(status.ClockSkew) > maxClockSkew.Seconds()
+}
@@ -0,0 +1,43 @@
+package routes
+
+import (
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"strings"
+)
+
+// validNoteID checks that a note ID has the format of the IDs generated by the server (128 bit, lowercase hex)
+func validNoteID(noteID string) bool {
+	if len(noteID) != 32 {
+		return false
+	}
+	_, err := hex.DecodeString(noteID)
+	return err == nil && noteID == strings.ToLower(noteID)
+}
+
+// validBlindIndexToken checks that a token is a base64 encoded HMAC-SHA256 or HMAC-SHA512 output
+func validBlindIndexToken(token string) bool {
+	decoded, err := base64.StdEncoding.Strict().DecodeString(token)
+	if err != nil {
+		return false
+	}
+	return len(decoded) == 32 || len(decoded) == 64
+}
+
+// validateTokens checks a list of client computed tokens (blind indexes or search tokens).
+// Parameters:
+// - tokens: the tokens to validate
+// - max: the maximum number of tokens allowed
+// Returns: an error describing the first invalid token, or nil if all the tokens are valid
+func validateTokens(tokens []string, max int) error {
+	if len(tokens) > max {
+		return errors.New("too many tokens")
+	}
+	for _, token := range tokens {
+		if !validBlindIndexToken(token) {
+			return errors.New("invalid token format")
+		}
+	}
+	return nil
+}
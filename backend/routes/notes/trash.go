@@ -0,0 +1,93 @@
+package routes
+
+import (
+	"backend/config"
+	"backend/db"
+	"backend/models"
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"time"
+)
+
+// GetTrashHandler returns the titles of the notes inside the trash with their purge date
+func GetTrashHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+
+	// Extract userID from context
+	userID, ok := r.Context().Value("UserID").(uint32)
+	if !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	retention := time.Duration(config.GetConfig().TrashRetentionDays) * 24 * time.Hour
+
+	trashRepo := db.NewTrashRepository(db.GetDB())
+	titles, err := trashRepo.GetTrash(userID, retention)
+	if err != nil {
+		log.Printf("Error retrieving trash for user %d: %v", userID, err)
+		http.Error(w, "Error retrieving trash", http.StatusInternalServerError)
+		return
+	}
+
+	// Check if titles is nil and send an empty array if so
+	if titles == nil {
+		titles = []*models.TrashedTitle{}
+	}
+
+	err = json.NewEncoder(w).Encode(titles)
+	if err != nil {
+		log.Printf("Error encoding response: %v", err)
+	}
+}
+
+// RestoreNoteHandler moves a note out of the trash.
+// The request is a tombstone signed by the user over the tombstone block at the head of the note, it is appended
+// to the chain of the note as a restore block.
+func RestoreNoteHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+
+	userID, block, signer, ok := lifecycleBlock(w, r, models.BlockKindRestore)
+	if !ok {
+		return
+	}
+
+	trashRepo := db.NewTrashRepository(db.GetDB())
+	seq, blockHash, err := trashRepo.RestoreNote(userID, block, *signer)
+	switch {
+	case errors.Is(err, db.ErrNoteNotFound):
+		http.Error(w, "Note not found in the trash", http.StatusNotFound)
+		return
+	case errors.Is(err, db.ErrHeadMismatch):
+		http.Error(w, "The restore is not signed over the tombstone of the note", http.StatusConflict)
+		return
+	case err != nil:
+		log.Printf("Error restoring note %s for user %d: %v", block.NoteID, userID, err)
+		http.Error(w, "Error restoring note", http.StatusInternalServerError)
+		return
+	}
+
+	response := AddBlockResponse{
+		TimeStamp: block.Timestamp.Format(time.RFC3339),
+		Message:   "Note restored successfully",
+		Receipt:   signReceipt(block.NoteID, seq, blockHash),
+	}
+	go timestampBlock(block.NoteID, seq, blockHash)
+
+	err = json.NewEncoder(w).Encode(response)
+	if err != nil {
+		log.Printf("Error encoding response: %v", err)
+	}
+}
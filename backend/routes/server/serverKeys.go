@@ -0,0 +1,41 @@
+package routes
+
+import (
+	"backend/crypto"
+	"backend/models"
+	"encoding/json"
+	"log"
+	"net/http"
+)
+
+// ServerKeysResponse lists the public keys the server signs with
+type ServerKeysResponse struct {
+	Keys []*models.ServerKey `json:"keys"`
+}
+
+// ServerKeysHandler publishes the public keys clients verify the server receipts with.
+// It is served under /.well-known so it can be fetched and pinned without authentication.
+func ServerKeysHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+
+	key, err := crypto.ServerPublicKey()
+	if err != nil {
+		log.Printf("Error reading server key: %v", err)
+		http.Error(w, "Server key unavailable", http.StatusInternalServerError)
+		return
+	}
+
+	response := ServerKeysResponse{
+		Keys: []*models.ServerKey{key},
+	}
+
+	err = json.NewEncoder(w).Encode(response)
+	if err != nil {
+		log.Printf("Error encoding response: %v", err)
+	}
+}
@@ -0,0 +1,35 @@
+package routes
+
+import (
+	"backend/crypto"
+	"encoding/json"
+	"log"
+	"net/http"
+)
+
+// SuitesResponse lists the crypto suites of the server
+type SuitesResponse struct {
+	Suites  []*crypto.Suite `json:"suites"`
+	Default uint16          `json:"default"` // Suite picked for a client that registers without preferences
+}
+
+// SuitesHandler publishes the crypto suite registry, so clients can negotiate the suite of a new account and
+// find the algorithms of an existing one from its suite_id. It does not need authentication.
+func SuitesHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+
+	response := SuitesResponse{
+		Suites:  crypto.Suites(),
+		Default: crypto.DefaultSuiteID,
+	}
+
+	err := json.NewEncoder(w).Encode(response)
+	if err != nil {
+		log.Printf("Error encoding response: %v", err)
+	}
+}
@@ -4,6 +4,8 @@ import (
 	"backend/middleware"
 	auth "backend/routes/auth"
 	notes "backend/routes/notes"
+	server "backend/routes/server"
+	transparency "backend/routes/transparency"
 	"net/http"
 )
 
@@ -11,6 +13,12 @@ import (
 func SetupAuthRoutes(mux *http.ServeMux) {
 	// Register route
 	mux.HandleFunc("/auth/register", auth.RegisterHandler)
+	// Challenge signed by the new accounts to prove they hold their keys
+	mux.HandleFunc("/auth/register/challenge", auth.RegistrationChallengeHandler)
+
+	// Links of the verification and email change emails, and a new verification link
+	mux.HandleFunc("/auth/email/confirm", auth.ConfirmEmailHandler)
+	mux.HandleFunc("/auth/email/resend", auth.ResendVerificationHandler)
 
 	// Challenge route
 	mux.HandleFunc("/auth/challenge", auth.ChallengeHandler)
@@ -18,6 +26,13 @@ func SetupAuthRoutes(mux *http.ServeMux) {
 	// Login route
 	mux.HandleFunc("/auth/login", auth.LoginHandler)
 
+	// Re-key a forgotten password with the offline recovery key
+	mux.HandleFunc("/auth/recover/start", auth.RecoveryStartHandler)
+	mux.HandleFunc("/auth/recover", auth.RecoverHandler)
+	// Social recovery, the requester collects the shares of the recovery key from the trusted contacts
+	mux.HandleFunc("/auth/recovery/social/start", auth.SocialRecoveryStartHandler)
+	mux.HandleFunc("/auth/recovery/social/status", auth.SocialRecoveryStatusHandler)
+
 	// Logout route
 	mux.HandleFunc("/auth/logout", auth.LogoutHandler)
 
@@ -25,19 +40,81 @@ func SetupAuthRoutes(mux *http.ServeMux) {
 	mux.HandleFunc("/auth/delete", middleware.AuthMiddleware(auth.DeleteUserHandler))
 	// Update user route
 	mux.HandleFunc("/auth/update", middleware.AuthMiddleware(auth.UpdateUserHandler))
+	// Change of the login email, confirmed from both addresses
+	mux.HandleFunc("/auth/email/change", middleware.AuthMiddleware(auth.ChangeEmailHandler))
+	mux.HandleFunc("/auth/email/change/cancel", middleware.AuthMiddleware(auth.CancelEmailChangeHandler))
+	// Raise the cost of the derivation of the login key
+	mux.HandleFunc("/auth/kdf", middleware.AuthMiddleware(auth.UpgradeKDFHandler))
+	// Signing keys of the devices of the user
+	mux.HandleFunc("/auth/devices", middleware.AuthMiddleware(auth.ListDevicesHandler))
+	mux.HandleFunc("/auth/devices/enroll", middleware.AuthMiddleware(auth.EnrollDeviceHandler))
+	mux.HandleFunc("/auth/devices/revoke", middleware.AuthMiddleware(auth.RevokeDeviceHandler))
+	// Security notifications of the account: preferences, webhook and latest deliveries
+	mux.HandleFunc("/auth/notifications", middleware.AuthMiddleware(auth.NotificationPreferencesHandler))
+	mux.HandleFunc("/auth/notifications/update", middleware.AuthMiddleware(auth.UpdateNotificationPreferencesHandler))
+	// Shares of the recovery key held by trusted contacts, and the social recoveries of the account
+	mux.HandleFunc("/auth/recovery/contact", middleware.AuthMiddleware(auth.ContactHandler))
+	mux.HandleFunc("/auth/recovery/shares", middleware.AuthMiddleware(auth.ListSharesHandler))
+	mux.HandleFunc("/auth/recovery/shares/distribute", middleware.AuthMiddleware(auth.DistributeSharesHandler))
+	mux.HandleFunc("/auth/recovery/held", middleware.AuthMiddleware(auth.HeldSharesHandler))
+	mux.HandleFunc("/auth/recovery/submit", middleware.AuthMiddleware(auth.SubmitShareHandler))
+	mux.HandleFunc("/auth/recovery/requests", middleware.AuthMiddleware(auth.RecoveryRequestsHandler))
+	mux.HandleFunc("/auth/recovery/requests/cancel", middleware.AuthMiddleware(auth.CancelRecoveryHandler))
+
+	// Public keys of the server, used to verify the block receipts
+	mux.HandleFunc("/.well-known/server-keys", server.ServerKeysHandler)
+
+	// Crypto suites the accounts can be registered with
+	mux.HandleFunc("/crypto/suites", server.SuitesHandler)
+
+	// Public transparency log of every accepted block
+	mux.HandleFunc("/log/sth", transparency.TreeHeadHandler)
+	mux.HandleFunc("/log/entries", transparency.LogEntriesHandler)
+	mux.HandleFunc("/log/inclusion", transparency.LogInclusionHandler)
+	mux.HandleFunc("/log/consistency", transparency.LogConsistencyHandler)
 
 	// Note edition adds a new block to the note blockchain
-	mux.HandleFunc("/notes/edit", middleware.AuthMiddleware(notes.AddBlockHandler))
+	mux.HandleFunc("/notes/edit", middleware.AuthMiddleware(middleware.RequireVerifiedEmail(notes.AddBlockHandler)))
 
 	// Creates a new note from 0
-	mux.HandleFunc("/notes/new", middleware.AuthMiddleware(notes.NewNoteHandler))
+	mux.HandleFunc("/notes/new", middleware.AuthMiddleware(middleware.RequireVerifiedEmail(notes.NewNoteHandler)))
 
 	// get all the notes titles
-	mux.HandleFunc("/notes/titles", middleware.AuthMiddleware(notes.GetTitlesHandler))
+	mux.HandleFunc("/notes/titles", middleware.AuthMiddleware(middleware.RequireVerifiedEmailToRead(notes.GetTitlesHandler)))
 
 	// get note by id
-	mux.HandleFunc("/notes/get", middleware.AuthMiddleware(notes.GetNoteHandler))
+	mux.HandleFunc("/notes/get", middleware.AuthMiddleware(middleware.RequireVerifiedEmailToRead(notes.GetNoteHandler)))
+
+	// every version of a note with its signature and time-stamp token status
+	mux.HandleFunc("/notes/history", middleware.AuthMiddleware(middleware.RequireVerifiedEmailToRead(notes.NoteHistoryHandler)))
+
+	// check the chain, the signatures and the time-stamp tokens of a note
+	mux.HandleFunc("/notes/verify", middleware.AuthMiddleware(middleware.RequireVerifiedEmailToRead(notes.VerifyNoteHandler)))
+
+	// download the whole vault as a signed archive
+	mux.HandleFunc("/notes/export", middleware.AuthMiddleware(middleware.RequireVerifiedEmailToRead(notes.ExportHandler)))
+
+	// import a vault archive, verifying every chain
+	mux.HandleFunc("/notes/import", middleware.AuthMiddleware(middleware.RequireVerifiedEmail(notes.ImportHandler)))
+
+	// set the encrypted folder and tags of a note
+	mux.HandleFunc("/notes/meta", middleware.AuthMiddleware(middleware.RequireVerifiedEmail(notes.SetMetadataHandler)))
+
+	// search the notes with keyed keyword tokens
+	mux.HandleFunc("/notes/search", middleware.AuthMiddleware(middleware.RequireVerifiedEmailToRead(notes.SearchHandler)))
+
+	// prove that the latest head of a note is part of the account log
+	mux.HandleFunc("/notes/log/inclusion", middleware.AuthMiddleware(middleware.RequireVerifiedEmailToRead(notes.AccountInclusionHandler)))
+
+	// prove that the account log only grew since an older tree head
+	mux.HandleFunc("/notes/log/consistency", middleware.AuthMiddleware(middleware.RequireVerifiedEmailToRead(notes.AccountConsistencyHandler)))
+
+	// move a note to the trash with a signed tombstone
+	mux.HandleFunc("/notes/delete", middleware.AuthMiddleware(middleware.RequireVerifiedEmail(notes.DeleteNoteHandler)))
+
+	// list the notes inside the trash
+	mux.HandleFunc("/notes/trash", middleware.AuthMiddleware(middleware.RequireVerifiedEmailToRead(notes.GetTrashHandler)))
 
-	// delete a note by id
-	mux.HandleFunc("/notes/delete", middleware.AuthMiddleware(notes.DeleteNoteHandler))
+	// move a note out of the trash
+	mux.HandleFunc("/notes/restore", middleware.AuthMiddleware(middleware.RequireVerifiedEmail(notes.RestoreNoteHandler)))
 }
@@ -0,0 +1,290 @@
+package routes
+
+import (
+	"backend/db"
+	"backend/models"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"strconv"
+)
+
+// maxLogEntries limits how many entries a single /log/entries request can return
+const maxLogEntries = 1000
+
+// The transparency log endpoints are public: anyone must be able to audit the log. They only expose the leaf hashes,
+// which commit to the blocks without telling which note or account they belong to, see models.LogEntry.
+
+// parseSize reads a non negative integer from the query parameters
+// Parameters:
+// - query: the URL query parameters
+// - name: the name of the parameter
+// - defaultValue: the value returned when the parameter is missing
+// Returns: the value, or an error if the parameter is not a non negative integer
+func parseSize(query url.Values, name string, defaultValue int) (int, error) {
+	value := query.Get(name)
+	if value == "" {
+		return defaultValue, nil
+	}
+	size, err := strconv.Atoi(value)
+	if err != nil || size < 0 {
+		return 0, errors.New(name + " must be a positive integer")
+	}
+	return size, nil
+}
+
+// encodeHashes encodes a list of hashes in Base64
+func encodeHashes(hashes [][]byte) []string {
+	encoded := make([]string, len(hashes))
+	for i, hash := range hashes {
+		encoded[i] = base64.StdEncoding.EncodeToString(hash)
+	}
+	return encoded
+}
+
+// latestTreeHead returns the latest signed tree head, writing the error response if there is none
+func latestTreeHead(w http.ResponseWriter, repo *db.TransparencyRepository) (*models.SignedTreeHead, bool) {
+	sth, err := repo.GetLatestTreeHead()
+	if err != nil {
+		if errors.Is(err, db.ErrTreeHeadNotFound) {
+			http.Error(w, "No tree head was signed yet", http.StatusNotFound)
+		} else {
+			log.Printf("Error reading the latest tree head: %v", err)
+			http.Error(w, "Error retrieving tree head", http.StatusInternalServerError)
+		}
+		return nil, false
+	}
+	return sth, true
+}
+
+// TreeHeadHandler returns the latest signed tree head, or the one of a given tree_size
+func TreeHeadHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+
+	treeSize, err := parseSize(r.URL.Query(), "tree_size", -1)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	repo := db.NewTransparencyRepository(db.GetDB())
+
+	var sth *models.SignedTreeHead
+	if treeSize < 0 {
+		var ok bool
+		if sth, ok = latestTreeHead(w, repo); !ok {
+			return
+		}
+	} else {
+		sth, err = repo.GetTreeHead(treeSize)
+		if err != nil {
+			if errors.Is(err, db.ErrTreeHeadNotFound) {
+				http.Error(w, "No tree head was signed for this size", http.StatusNotFound)
+			} else {
+				log.Printf("Error reading tree head %d: %v", treeSize, err)
+				http.Error(w, "Error retrieving tree head", http.StatusInternalServerError)
+			}
+			return
+		}
+	}
+
+	err = json.NewEncoder(w).Encode(sth)
+	if err != nil {
+		log.Printf("Error encoding response: %v", err)
+	}
+}
+
+// LogEntriesHandler returns the entries of the log between start (included) and end (excluded)
+func LogEntriesHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+
+	query := r.URL.Query()
+	start, err := parseSize(query, "start", 0)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	end, err := parseSize(query, "end", start+maxLogEntries)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if end < start || end-start > maxLogEntries {
+		http.Error(w, fmt.Sprintf("end must be after start and at most %d entries can be requested", maxLogEntries), http.StatusBadRequest)
+		return
+	}
+
+	repo := db.NewTransparencyRepository(db.GetDB())
+	entries, err := repo.GetEntries(start, end)
+	if err != nil {
+		log.Printf("Error reading log entries %d to %d: %v", start, end, err)
+		http.Error(w, "Error retrieving log entries", http.StatusInternalServerError)
+		return
+	}
+
+	// Check if entries is nil and send an empty array if so
+	if entries == nil {
+		entries = []*models.LogEntry{}
+	}
+
+	err = json.NewEncoder(w).Encode(entries)
+	if err != nil {
+		log.Printf("Error encoding response: %v", err)
+	}
+}
+
+// LogInclusionHandler returns the proof that a block is part of a signed tree head.
+// The caller already knows the block, it checks the leaf hash of the entry against its note ID, seq and hash.
+// Query parameters: block_hash, and optionally tree_size to prove against an older signed tree head.
+func LogInclusionHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+
+	query := r.URL.Query()
+	blockHash := query.Get("block_hash")
+	if blockHash == "" {
+		http.Error(w, "Missing fields", http.StatusBadRequest)
+		return
+	}
+	treeSize, err := parseSize(query, "tree_size", -1)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	repo := db.NewTransparencyRepository(db.GetDB())
+
+	// Proofs are always given against a signed tree head
+	var sth *models.SignedTreeHead
+	if treeSize < 0 {
+		var ok bool
+		if sth, ok = latestTreeHead(w, repo); !ok {
+			return
+		}
+	} else if sth, err = repo.GetTreeHead(treeSize); err != nil {
+		if errors.Is(err, db.ErrTreeHeadNotFound) {
+			http.Error(w, "No tree head was signed for this size", http.StatusNotFound)
+		} else {
+			log.Printf("Error reading tree head %d: %v", treeSize, err)
+			http.Error(w, "Error retrieving tree head", http.StatusInternalServerError)
+		}
+		return
+	}
+
+	entry, err := repo.GetEntryByBlockHash(blockHash)
+	if err != nil {
+		if errors.Is(err, db.ErrLogEntryNotFound) {
+			http.Error(w, "Block not found in the log", http.StatusNotFound)
+		} else {
+			log.Printf("Error reading log entry of block %s: %v", blockHash, err)
+			http.Error(w, "Error retrieving log entry", http.StatusInternalServerError)
+		}
+		return
+	}
+	if entry.Index >= sth.TreeSize {
+		http.Error(w, "The block is not part of this tree head yet", http.StatusNotFound)
+		return
+	}
+
+	path, err := repo.GetInclusionProof(entry.Index, sth.TreeSize)
+	if err != nil {
+		log.Printf("Error building inclusion proof for block %s: %v", blockHash, err)
+		http.Error(w, "Error building inclusion proof", http.StatusInternalServerError)
+		return
+	}
+
+	response := models.LogInclusionProof{
+		Entry:     entry,
+		TreeHead:  sth,
+		AuditPath: encodeHashes(path),
+	}
+
+	err = json.NewEncoder(w).Encode(response)
+	if err != nil {
+		log.Printf("Error encoding response: %v", err)
+	}
+}
+
+// LogConsistencyHandler returns the proof that the log only grew between two tree sizes.
+// Query parameters: first, and optionally second which defaults to the latest signed tree head, neither can be
+// larger than the latest signed tree head.
+func LogConsistencyHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+
+	query := r.URL.Query()
+	if query.Get("first") == "" {
+		http.Error(w, "Missing fields", http.StatusBadRequest)
+		return
+	}
+	first, err := parseSize(query, "first", 0)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	second, err := parseSize(query, "second", -1)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	// Proofs are only given up to the latest signed tree head, the tree of the log is cached up to it
+	repo := db.NewTransparencyRepository(db.GetDB())
+	sth, ok := latestTreeHead(w, repo)
+	if !ok {
+		return
+	}
+	if second < 0 {
+		second = sth.TreeSize
+	}
+	if first > second || second > sth.TreeSize {
+		http.Error(w, "first must not be larger than second, nor second larger than the latest signed tree head", http.StatusBadRequest)
+		return
+	}
+
+	proof, firstRoot, secondRoot, err := repo.GetConsistencyProof(first, second)
+	if err != nil {
+		log.Printf("Error building consistency proof from %d to %d: %v", first, second, err)
+		http.Error(w, "Error building consistency proof", http.StatusInternalServerError)
+		return
+	}
+
+	response := models.ConsistencyProof{
+		First: models.TreeHead{
+			TreeSize: first,
+			RootHash: base64.StdEncoding.EncodeToString(firstRoot),
+		},
+		Second: models.TreeHead{
+			TreeSize: second,
+			RootHash: base64.StdEncoding.EncodeToString(secondRoot),
+		},
+		Proof: encodeHashes(proof),
+	}
+
+	err = json.NewEncoder(w).Encode(response)
+	if err != nil {
+		log.Printf("Error encoding response: %v", err)
+	}
+}
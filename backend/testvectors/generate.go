@@ -0,0 +1,186 @@
+package testvectors
+
+import (
+	"backend/client"
+	"backend/crypto"
+	"backend/models"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"time"
+)
+
+// baseTime is the timestamp of the first block of every note, the next versions follow a minute apart
+var baseTime = time.Date(2025, time.January, 2, 3, 4, 5, 0, time.UTC)
+
+// userSpecs covers every combination of the AES-128 and HMAC types, each AEAD type, each login KDF and each
+// signature type once
+var userSpecs = []struct {
+	name           string
+	password       string
+	encryptionType string
+	hmacType       string
+	kdf            models.KDFParams
+	signatureType  string
+}{
+	{"cbc-sha256", "correct horse battery staple", client.AES128CBC, client.HMACSHA256, crypto.LegacyKDF, crypto.SignatureEd25519},
+	{"cbc-sha512", "pässwörd with ünïcode ✓", client.AES128CBC, client.HMACSHA512, crypto.LegacyKDF, crypto.SignatureEd25519},
+	{"ctr-sha256", "correct horse battery staple", client.AES128CTR, client.HMACSHA256, crypto.LegacyKDF, crypto.SignatureEd25519},
+	{"ctr-sha512", "pässwörd with ünïcode ✓", client.AES128CTR, client.HMACSHA512, crypto.LegacyKDF, crypto.SignatureEd25519},
+	{"gcm-sha256", "correct horse battery staple", client.AES256GCM, client.HMACSHA256, crypto.LegacyKDF, crypto.SignatureEd25519},
+	{"xchacha-sha512", "pässwörd with ünïcode ✓", client.XChaCha20Poly1305, client.HMACSHA512, crypto.LegacyKDF, crypto.SignatureEd25519},
+	{"xchacha-argon2id", "pässwörd with ünïcode ✓", client.XChaCha20Poly1305, client.HMACSHA256, crypto.RecommendedKDF, crypto.SignatureEd25519},
+	{"gcm-mldsa65", "correct horse battery staple", client.AES256GCM, client.HMACSHA512, crypto.LegacyKDF, crypto.SignatureEd25519MLDSA65},
+}
+
+// versionSpecs are the versions of every note, chosen around the AES block size and with multi-byte characters
+var versionSpecs = []struct {
+	title string
+	body  string
+}{
+	{"Shopping list", ""},
+	{"Exactly 16 bytes", "Multi-line\nbody with ünïcödé, an emoji 🔐, \"quotes\", <tags> & ampersands"},
+	{"Fifteen bytes!!", "A longer body that spans several AES blocks, to exercise the chaining of the cipher mode " +
+		"and the padding of the last block.\n\tTabs and trailing spaces stay as they are.  "},
+}
+
+// seeded derives deterministic bytes from a label, the vectors must not change between runs
+func seeded(size int, label ...any) []byte {
+	sum := sha256.Sum256([]byte(fmt.Sprint(label...)))
+	return sum[:size]
+}
+
+// Generate builds the vectors with the client package, the same code as the Go clients.
+// Returns: a pointer to the vectors, or an error if a key cannot be derived or a block cannot be built
+func Generate() (*Vectors, error) {
+	vectors := &Vectors{
+		Version: FormatVersion,
+		Comment: "Generated by go run ./cmd/testvectors from the backend directory, do not edit by hand. " +
+			"Checked by the Go tests and the frontend tests.",
+		InitialHash: client.InitialHash,
+		Users:       []*User{},
+	}
+
+	for _, spec := range userSpecs {
+		user, err := generateUser(spec.name, spec.password, spec.encryptionType, spec.hmacType, spec.kdf, spec.signatureType)
+		if err != nil {
+			return nil, fmt.Errorf("user %s: %v", spec.name, err)
+		}
+		vectors.Users = append(vectors.Users, user)
+	}
+	return vectors, nil
+}
+
+// generateUser derives the keys of a user and builds their note
+func generateUser(name, password, encryptionType, hmacType string, kdf models.KDFParams, signatureType string) (*User, error) {
+	account := &models.User{
+		LoginSalt:      base64.StdEncoding.EncodeToString(seeded(32, name, "/login_salt")),
+		EncryptionSalt: base64.StdEncoding.EncodeToString(seeded(32, name, "/encryption_salt")),
+		HMACSalt:       base64.StdEncoding.EncodeToString(seeded(32, name, "/hmac_salt")),
+		HMACType:       hmacType,
+		EncryptionType: encryptionType,
+		KDF:            kdf,
+		SignatureType:  signatureType,
+	}
+	keys, err := client.DeriveKeys(password, account)
+	if err != nil {
+		return nil, err
+	}
+
+	note, err := generateNote(keys, hex.EncodeToString(seeded(16, name, "/note_id")))
+	if err != nil {
+		return nil, err
+	}
+
+	var pqSeed string
+	if keys.PQSigningKey != nil {
+		pqSeed = base64.StdEncoding.EncodeToString(keys.PQSigningKey.Bytes())
+	}
+
+	return &User{
+		Name:           name,
+		Password:       password,
+		LoginSalt:      account.LoginSalt,
+		EncryptionSalt: account.EncryptionSalt,
+		HMACSalt:       account.HMACSalt,
+		HMACType:       hmacType,
+		EncryptionType: encryptionType,
+		SuiteID:        keys.Suite.ID,
+		KDF:            kdf,
+		SigningSeed:    base64.StdEncoding.EncodeToString(keys.SigningKey.Seed()),
+		PublicKey:      keys.PublicKey(),
+		EncryptionKey:  base64.StdEncoding.EncodeToString(keys.EncryptionKey),
+		HMACKey:        base64.StdEncoding.EncodeToString(keys.HMACKey),
+		SignatureType:  signatureType,
+		PQSeed:         pqSeed,
+		PQPublicKey:    keys.PQPublicKey(),
+		Notes:          []*Note{note},
+	}, nil
+}
+
+// generateNote builds the chain of versions of a note and the tombstone block moving it to the trash
+func generateNote(keys *client.Keys, noteID string) (*Note, error) {
+	note := &Note{NoteID: noteID, Versions: []*Version{}}
+
+	prevHash := client.InitialHash
+	timestamp := baseTime
+	for i, spec := range versionSpecs {
+		ivSize := crypto.IVSize(keys.EncryptionType)
+		block, err := client.BuildBlock(keys, spec.title, spec.body, prevHash,
+			seeded(ivSize, noteID, "/", i, "/iv_title"), seeded(ivSize, noteID, "/", i, "/iv"), timestamp)
+		if err != nil {
+			return nil, err
+		}
+
+		blockJSON, err := json.Marshal(block)
+		if err != nil {
+			return nil, err
+		}
+		blockHash, err := crypto.BlockHash(*block)
+		if err != nil {
+			return nil, err
+		}
+		signaturePayload := crypto.BlockSignaturePayload(block)
+		if block.PQSignature != "" {
+			signaturePayload = crypto.HybridBlockSignaturePayload(block)
+		}
+
+		note.Versions = append(note.Versions, &Version{
+			Title:            spec.title,
+			Body:             spec.body,
+			Block:            *block,
+			SignaturePayload: string(signaturePayload),
+			BlockJSON:        string(blockJSON),
+			BlockHash:        blockHash,
+		})
+
+		prevHash = blockHash
+		timestamp = timestamp.Add(time.Minute)
+	}
+
+	tombstone, err := client.SignLifecycleAt(keys, models.BlockKindTombstone, noteID, prevHash, timestamp)
+	if err != nil {
+		return nil, err
+	}
+	block := tombstone.Block(models.BlockKindTombstone)
+	blockJSON, err := json.Marshal(block)
+	if err != nil {
+		return nil, err
+	}
+	blockHash, err := crypto.BlockHash(block)
+	if err != nil {
+		return nil, err
+	}
+	signaturePayload := crypto.BlockSignaturePayload(&block)
+	if block.PQSignature != "" {
+		signaturePayload = crypto.HybridBlockSignaturePayload(&block)
+	}
+
+	note.Tombstone = tombstone
+	note.TombstonePayload = string(signaturePayload)
+	note.TombstoneBlockJSON = string(blockJSON)
+	note.TombstoneHash = blockHash
+	return note, nil
+}
@@ -0,0 +1,107 @@
+// Package testvectors generates and loads the cross-language crypto test vectors.
+//
+// The vectors pin the bytes every client must agree on: the keys derived from a password and salts,
+// the ciphertexts and MACs of the notes, the JSON a block is hashed from, the block hashes and the
+// Ed25519 and ML-DSA signatures. They are committed in testvectors/crypto.json at the root of the repository and
+// checked by the Go tests of the crypto and client packages and by the frontend tests, so a change to
+// models.Block, the key derivation or the encryption fails both builds instead of breaking the chains.
+//
+// The corpus is written by go run ./cmd/testvectors, and a test fails if it drifts from Generate.
+package testvectors
+
+import (
+	"backend/models"
+	"bytes"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"runtime"
+)
+
+// FormatVersion is the version of the layout of the vectors file
+const FormatVersion = 1
+
+// Vectors is the content of the vectors file
+type Vectors struct {
+	Version     int     `json:"version"`
+	Comment     string  `json:"comment"`
+	InitialHash string  `json:"initial_hash"` // prev_hash of the first block of every note
+	Users       []*User `json:"users"`
+}
+
+// User is an account with the keys derived from its password, all binary values are Base64
+type User struct {
+	Name           string           `json:"name"`
+	Password       string           `json:"password"`
+	LoginSalt      string           `json:"login_salt"`
+	EncryptionSalt string           `json:"encryption_salt"`
+	HMACSalt       string           `json:"hmac_salt"`
+	HMACType       string           `json:"hmac_type"`
+	EncryptionType string           `json:"encryption_type"`
+	SuiteID        uint16           `json:"suite_id"`                // ID of the crypto suite of the encryption and HMAC types
+	KDF            models.KDFParams `json:"kdf"`                     // Derivation of the signing seed from the login salt
+	SigningSeed    string           `json:"signing_seed"`            // 32 byte Ed25519 seed derived from the login salt with the KDF
+	PublicKey      string           `json:"public_key"`              // Ed25519 public key of the seed
+	EncryptionKey  string           `json:"encryption_key"`          // AES-128 key, or 32 byte AEAD key, derived from the encryption salt
+	HMACKey        string           `json:"hmac_key"`                // HMAC key derived from the HMAC salt
+	SignatureType  string           `json:"signature_type"`          // Signature scheme of the blocks
+	PQSeed         string           `json:"pq_seed,omitempty"`       // 32 byte ML-DSA-65 seed derived from the signing seed, hybrid types only
+	PQPublicKey    string           `json:"pq_public_key,omitempty"` // ML-DSA-65 public key of the seed
+	Notes          []*Note          `json:"notes"`
+}
+
+// Note is a chain of versions of a note, moved to the trash at its last version
+type Note struct {
+	NoteID             string            `json:"note_id"`
+	Versions           []*Version        `json:"versions"`
+	Tombstone          *models.Tombstone `json:"tombstone"`
+	TombstonePayload   string            `json:"tombstone_payload"`    // Exact string signed by the tombstone
+	TombstoneBlockJSON string            `json:"tombstone_block_json"` // Exact JSON the hash of the tombstone block is computed from
+	TombstoneHash      string            `json:"tombstone_hash"`       // Hash of the tombstone block, the head of the trashed note
+}
+
+// Version is one block of a note with its plaintext and the values derived from it
+type Version struct {
+	Title            string       `json:"title"`
+	Body             string       `json:"body"`
+	Block            models.Block `json:"block"`
+	SignaturePayload string       `json:"signature_payload"` // Exact string signed by the block
+	BlockJSON        string       `json:"block_json"`        // Exact JSON the block hash is computed from
+	BlockHash        string       `json:"block_hash"`        // prev_hash of the next version
+}
+
+// Path returns the path of the vectors file, found from the location of this source file so it works from
+// the tests of any package
+func Path() string {
+	_, file, _, _ := runtime.Caller(0)
+	return filepath.Join(filepath.Dir(file), "..", "..", "testvectors", "crypto.json")
+}
+
+// Load reads the vectors file.
+// Returns: a pointer to the vectors, or an error if the file cannot be read or parsed
+func Load() (*Vectors, error) {
+	data, err := os.ReadFile(Path())
+	if err != nil {
+		return nil, err
+	}
+	var vectors Vectors
+	if err := json.Unmarshal(data, &vectors); err != nil {
+		return nil, err
+	}
+	return &vectors, nil
+}
+
+// Encode serializes vectors in the committed layout.
+// Parameters:
+// - vectors: a pointer to the vectors
+// Returns: the indented JSON, or an error if it cannot be encoded
+func Encode(vectors *Vectors) ([]byte, error) {
+	var buf bytes.Buffer
+	encoder := json.NewEncoder(&buf)
+	encoder.SetEscapeHTML(false)
+	encoder.SetIndent("", "  ")
+	if err := encoder.Encode(vectors); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
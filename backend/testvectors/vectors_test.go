@@ -0,0 +1,29 @@
+package testvectors
+
+import (
+	"bytes"
+	"os"
+	"testing"
+)
+
+// TestVectorsUpToDate fails when the committed vectors differ from what the code produces today
+func TestVectorsUpToDate(t *testing.T) {
+	committed, err := os.ReadFile(Path())
+	if err != nil {
+		t.Fatalf("reading the vectors: %v", err)
+	}
+
+	vectors, err := Generate()
+	if err != nil {
+		t.Fatalf("generating the vectors: %v", err)
+	}
+	generated, err := Encode(vectors)
+	if err != nil {
+		t.Fatalf("encoding the vectors: %v", err)
+	}
+
+	if !bytes.Equal(committed, generated) {
+		t.Fatal("testvectors/crypto.json is out of date: the protocol changed, existing chains may no longer verify. " +
+			"If the change is deliberate, run go run ./cmd/testvectors and update every client")
+	}
+}
@@ -0,0 +1,76 @@
+// Package tsa obtains and verifies RFC 3161 time-stamp tokens over block hashes.
+//
+// A token is signed by a time-stamp authority (TSA) and proves that a block hash existed at
+// the time written in the token, unlike the block timestamp which is only a client assertion.
+// The authority is pluggable: HTTPAuthority talks to any RFC 3161 server, LocalAuthority signs
+// the tokens itself for tests and air-gapped deployments.
+package tsa
+
+import (
+	"crypto/x509"
+	"errors"
+	"log"
+	"math/big"
+	"time"
+)
+
+var (
+	// ErrNoAuthority is returned when no time-stamp authority is configured
+	ErrNoAuthority = errors.New("no time-stamp authority is configured")
+	// ErrUntrustedSigner is returned when a token is signed by a certificate the authority does not trust
+	ErrUntrustedSigner = errors.New("the token is not signed by a trusted time-stamp authority")
+)
+
+// Authority is a time-stamp authority able to timestamp hashes and to check who signed a token.
+type Authority interface {
+	// Name identifies the authority, it is stored with every token it issues
+	Name() string
+	// Timestamp obtains a DER encoded time-stamp token over a SHA-256 hash
+	Timestamp(hash []byte) ([]byte, error)
+	// VerifySigner checks that the certificate which signed a token is trusted at the time of the token
+	VerifySigner(signer *x509.Certificate, intermediates []*x509.Certificate, genTime time.Time) error
+}
+
+// TokenInfo is the content of a verified time-stamp token
+type TokenInfo struct {
+	GenTime      time.Time // Time at which the authority signed the token
+	SerialNumber *big.Int  // Serial number of the token, unique for the authority
+	Policy       string    // Policy under which the token was issued
+}
+
+var authority Authority
+
+// SetAuthority sets the time-stamp authority used by the server.
+// Parameters:
+// - a: the authority, nil disables the timestamping of new blocks
+func SetAuthority(a Authority) {
+	authority = a
+}
+
+// GetAuthority returns the time-stamp authority used by the server.
+// Returns: the configured authority, or ErrNoAuthority if there is none
+func GetAuthority() (Authority, error) {
+	if authority == nil {
+		return nil, ErrNoAuthority
+	}
+	return authority, nil
+}
+
+// NewAuthority builds the authority described by the configuration.
+// Parameters:
+// - url: the URL of an RFC 3161 server, empty to use the bundled local authority
+// - caFile: a PEM file with the roots trusted for the tokens of the remote server
+// - keyBase64: the Base64 PKCS#8 ECDSA key of the local authority, empty generates an ephemeral key
+// - policy: the policy OID of the local authority
+// Returns: the authority, or an error if the configuration is invalid
+func NewAuthority(url, caFile, keyBase64, policy string) (Authority, error) {
+	if url != "" {
+		return NewHTTPAuthority(url, caFile)
+	}
+
+	if keyBase64 == "" {
+		// Tokens signed with an ephemeral key can no longer be verified once the server restarts
+		log.Println("WARNING: TSA_KEY is not set, using an ephemeral local time-stamp authority key")
+	}
+	return NewLocalAuthority(keyBase64, policy)
+}
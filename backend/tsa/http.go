@@ -0,0 +1,159 @@
+package tsa
+
+import (
+	"bytes"
+	"crypto/rand"
+	"crypto/x509"
+	"encoding/asn1"
+	"errors"
+	"fmt"
+	"io"
+	"math/big"
+	"net/http"
+	"os"
+	"time"
+)
+
+// maxResponseSize limits the size of a time-stamp response read from a remote authority
+const maxResponseSize = 1 << 20
+
+// HTTPAuthority is a remote RFC 3161 time-stamp authority reached over HTTP.
+type HTTPAuthority struct {
+	url    string
+	client *http.Client
+	roots  *x509.CertPool
+}
+
+// NewHTTPAuthority creates a client for a remote time-stamp authority.
+// Parameters:
+// - url: the URL the time-stamp queries are posted to
+// - caFile: a PEM file with the roots trusted for the tokens of the authority, empty to use the system roots
+// Returns: the authority, or an error if the roots cannot be loaded
+func NewHTTPAuthority(url, caFile string) (*HTTPAuthority, error) {
+	var roots *x509.CertPool
+	if caFile == "" {
+		var err error
+		roots, err = x509.SystemCertPool()
+		if err != nil {
+			return nil, fmt.Errorf("error loading the system roots: %v", err)
+		}
+	} else {
+		pem, err := os.ReadFile(caFile)
+		if err != nil {
+			return nil, fmt.Errorf("error reading %s: %v", caFile, err)
+		}
+		roots = x509.NewCertPool()
+		if !roots.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("no certificate found in %s", caFile)
+		}
+	}
+
+	return &HTTPAuthority{
+		url:    url,
+		client: &http.Client{Timeout: 10 * time.Second},
+		roots:  roots,
+	}, nil
+}
+
+// Name identifies the remote authority by its URL
+func (h *HTTPAuthority) Name() string {
+	return h.url
+}
+
+// Timestamp requests a time-stamp token over a SHA-256 hash from the remote authority.
+// Parameters:
+// - hash: the SHA-256 hash to timestamp
+// Returns: the DER encoded time-stamp token, or an error if the authority refused or could not be reached
+func (h *HTTPAuthority) Timestamp(hash []byte) ([]byte, error) {
+	nonce, err := rand.Int(rand.Reader, new(big.Int).Lsh(big.NewInt(1), 64))
+	if err != nil {
+		return nil, err
+	}
+
+	query, err := asn1.Marshal(timeStampReq{
+		Version: 1,
+		MessageImprint: messageImprint{
+			HashAlgorithm: algorithmIdentifier{Algorithm: oidSHA256},
+			HashedMessage: hash,
+		},
+		Nonce:   nonce,
+		CertReq: true,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := h.client.Post(h.url, "application/timestamp-query", bytes.NewReader(query))
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("time-stamp authority returned HTTP %d", resp.StatusCode)
+	}
+
+	body, err := io.ReadAll(io.LimitReader(resp.Body, maxResponseSize))
+	if err != nil {
+		return nil, err
+	}
+
+	var tsResp timeStampResp
+	if _, err := asn1.Unmarshal(body, &tsResp); err != nil {
+		return nil, errors.New("malformed time-stamp response")
+	}
+	// 0 is granted, 1 is granted with modifications
+	if tsResp.Status.Status > 1 {
+		return nil, fmt.Errorf("time-stamp authority rejected the request with status %d", tsResp.Status.Status)
+	}
+	token := tsResp.TimeStampToken.FullBytes
+	if len(token) == 0 {
+		return nil, errors.New("time-stamp response has no token")
+	}
+
+	// The nonce protects against a replayed response, the rest of the token is checked by Verify
+	info, err := parseTSTInfo(token)
+	if err != nil {
+		return nil, err
+	}
+	if info.Nonce == nil || info.Nonce.Cmp(nonce) != 0 {
+		return nil, errors.New("time-stamp response does not match the request nonce")
+	}
+
+	return token, nil
+}
+
+// VerifySigner checks that the signer certificate chains to a trusted root at the time of the token
+func (h *HTTPAuthority) VerifySigner(signer *x509.Certificate, intermediates []*x509.Certificate, genTime time.Time) error {
+	pool := x509.NewCertPool()
+	for _, cert := range intermediates {
+		pool.AddCert(cert)
+	}
+
+	_, err := signer.Verify(x509.VerifyOptions{
+		Roots:         h.roots,
+		Intermediates: pool,
+		CurrentTime:   genTime,
+		KeyUsages:     []x509.ExtKeyUsage{x509.ExtKeyUsageTimeStamping},
+	})
+	if err != nil {
+		return fmt.Errorf("%w: %v", ErrUntrustedSigner, err)
+	}
+	return nil
+}
+
+// parseTSTInfo extracts the TSTInfo of a token without verifying it
+func parseTSTInfo(token []byte) (*tstInfo, error) {
+	var ci contentInfo
+	if _, err := asn1.Unmarshal(token, &ci); err != nil {
+		return nil, errors.New("malformed time-stamp token")
+	}
+	var sd signedData
+	if _, err := asn1.Unmarshal(ci.Content.Bytes, &sd); err != nil {
+		return nil, errors.New("malformed signed data")
+	}
+	var info tstInfo
+	if _, err := asn1.Unmarshal(sd.EncapContentInfo.EContent, &info); err != nil {
+		return nil, errors.New("malformed TSTInfo")
+	}
+	return &info, nil
+}
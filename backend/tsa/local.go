@@ -0,0 +1,245 @@
+package tsa
+
+import (
+	"bytes"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"encoding/base64"
+	"errors"
+	"math/big"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// LocalAuthority is a time-stamp authority bundled with the server.
+// It signs the tokens itself with an ECDSA P-256 key and a self-signed certificate, so its tokens only
+// prove that the server saw a hash at a given time; use HTTPAuthority to involve an independent party.
+type LocalAuthority struct {
+	key    *ecdsa.PrivateKey
+	cert   *x509.Certificate
+	policy asn1.ObjectIdentifier
+}
+
+// NewLocalAuthority creates the bundled time-stamp authority.
+// Parameters:
+// - keyBase64: the Base64 PKCS#8 ECDSA P-256 key of the authority, empty generates an ephemeral key
+// - policy: the dotted policy OID written in the tokens
+// Returns: the authority, or an error if the key or the policy is invalid
+func NewLocalAuthority(keyBase64, policy string) (*LocalAuthority, error) {
+	var key *ecdsa.PrivateKey
+	if keyBase64 == "" {
+		var err error
+		key, err = ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+		if err != nil {
+			return nil, err
+		}
+	} else {
+		der, err := base64.StdEncoding.DecodeString(keyBase64)
+		if err != nil {
+			return nil, errors.New("invalid time-stamp authority key format")
+		}
+		parsed, err := x509.ParsePKCS8PrivateKey(der)
+		if err != nil {
+			return nil, errors.New("invalid time-stamp authority key, expected a PKCS#8 key")
+		}
+		var ok bool
+		if key, ok = parsed.(*ecdsa.PrivateKey); !ok || key.Curve != elliptic.P256() {
+			return nil, errors.New("the time-stamp authority key must be an ECDSA P-256 key")
+		}
+	}
+
+	policyOID, err := parseOID(policy)
+	if err != nil {
+		return nil, err
+	}
+
+	cert, err := selfSignedCertificate(key)
+	if err != nil {
+		return nil, err
+	}
+
+	return &LocalAuthority{key: key, cert: cert, policy: policyOID}, nil
+}
+
+// Name identifies the local authority
+func (l *LocalAuthority) Name() string {
+	return "local"
+}
+
+// Timestamp signs a time-stamp token over a SHA-256 hash.
+// Parameters:
+// - hash: the SHA-256 hash to timestamp
+// Returns: the DER encoded time-stamp token, or an error if the hash is not a SHA-256 hash
+func (l *LocalAuthority) Timestamp(hash []byte) ([]byte, error) {
+	if len(hash) != sha256.Size {
+		return nil, errors.New("the hash to timestamp must be a SHA-256 hash")
+	}
+
+	serialNumber, err := rand.Int(rand.Reader, new(big.Int).Lsh(big.NewInt(1), 64))
+	if err != nil {
+		return nil, err
+	}
+
+	info := tstInfo{
+		Version: 1,
+		Policy:  l.policy,
+		MessageImprint: messageImprint{
+			HashAlgorithm: algorithmIdentifier{Algorithm: oidSHA256},
+			HashedMessage: hash,
+		},
+		SerialNumber: serialNumber,
+		GenTime:      time.Now().UTC().Truncate(time.Second),
+		Accuracy:     accuracy{Seconds: 1},
+	}
+	content, err := asn1.Marshal(info)
+	if err != nil {
+		return nil, err
+	}
+
+	signedAttrs, err := l.signedAttributes(content)
+	if err != nil {
+		return nil, err
+	}
+
+	// The signature covers the signed attributes encoded as a SET, they are stored with an implicit [0] tag
+	signedBytes, err := asn1.Marshal(asn1.RawValue{Tag: asn1.TagSet, IsCompound: true, Bytes: signedAttrs})
+	if err != nil {
+		return nil, err
+	}
+	digest := sha256.Sum256(signedBytes)
+	signature, err := ecdsa.SignASN1(rand.Reader, l.key, digest[:])
+	if err != nil {
+		return nil, err
+	}
+
+	sd := signedData{
+		Version:          3,
+		DigestAlgorithms: []algorithmIdentifier{{Algorithm: oidSHA256}},
+		EncapContentInfo: encapsulatedContentInfo{EContentType: oidTSTInfo, EContent: content},
+		Certificates:     asn1.RawValue{Class: asn1.ClassContextSpecific, Tag: 0, IsCompound: true, Bytes: l.cert.Raw},
+		SignerInfos: []signerInfo{{
+			Version: 1,
+			SID: issuerAndSerialNumber{
+				Issuer:       asn1.RawValue{FullBytes: l.cert.RawIssuer},
+				SerialNumber: l.cert.SerialNumber,
+			},
+			DigestAlgorithm:    algorithmIdentifier{Algorithm: oidSHA256},
+			SignedAttrs:        asn1.RawValue{Class: asn1.ClassContextSpecific, Tag: 0, IsCompound: true, Bytes: signedAttrs},
+			SignatureAlgorithm: algorithmIdentifier{Algorithm: oidECDSAWithSHA256},
+			Signature:          signature,
+		}},
+	}
+	sdBytes, err := asn1.Marshal(sd)
+	if err != nil {
+		return nil, err
+	}
+
+	return asn1.Marshal(contentInfo{
+		ContentType: oidSignedData,
+		Content:     asn1.RawValue{Class: asn1.ClassContextSpecific, Tag: 0, IsCompound: true, Bytes: sdBytes},
+	})
+}
+
+// signedAttributes encodes the content type, message digest and signing certificate attributes.
+// Parameters:
+// - content: the DER encoded TSTInfo
+// Returns: the concatenated attributes, sorted as required by the DER encoding of a SET OF
+func (l *LocalAuthority) signedAttributes(content []byte) ([]byte, error) {
+	contentDigest := sha256.Sum256(content)
+	certHash := sha256.Sum256(l.cert.Raw)
+
+	values := []struct {
+		oid   asn1.ObjectIdentifier
+		value any
+	}{
+		{oidAttrContentType, oidTSTInfo},
+		{oidAttrMessageDigest, contentDigest[:]},
+		{oidAttrSigningCertV2, signingCertificateV2{Certs: []essCertIDv2{{CertHash: certHash[:]}}}},
+	}
+
+	var encoded [][]byte
+	for _, v := range values {
+		value, err := asn1.Marshal(v.value)
+		if err != nil {
+			return nil, err
+		}
+		attr, err := asn1.Marshal(attribute{
+			Type:   v.oid,
+			Values: asn1.RawValue{Tag: asn1.TagSet, IsCompound: true, Bytes: value},
+		})
+		if err != nil {
+			return nil, err
+		}
+		encoded = append(encoded, attr)
+	}
+
+	sort.Slice(encoded, func(i, j int) bool {
+		return bytes.Compare(encoded[i], encoded[j]) < 0
+	})
+	return bytes.Join(encoded, nil), nil
+}
+
+// VerifySigner checks that a token was signed with the key of the local authority.
+// The certificate is self-signed, so the key is the only thing that can be trusted.
+func (l *LocalAuthority) VerifySigner(signer *x509.Certificate, _ []*x509.Certificate, _ time.Time) error {
+	publicKey, ok := signer.PublicKey.(*ecdsa.PublicKey)
+	if !ok || !publicKey.Equal(&l.key.PublicKey) {
+		return ErrUntrustedSigner
+	}
+	return nil
+}
+
+// selfSignedCertificate builds the certificate of the local authority.
+// Its fields only depend on the key, so the same key always yields an equivalent certificate.
+func selfSignedCertificate(key *ecdsa.PrivateKey) (*x509.Certificate, error) {
+	publicKey, err := x509.MarshalPKIXPublicKey(&key.PublicKey)
+	if err != nil {
+		return nil, err
+	}
+	keyHash := sha256.Sum256(publicKey)
+
+	// RFC 3161 requires the time stamping extended key usage to be the only one, and critical
+	extKeyUsage, err := asn1.Marshal([]asn1.ObjectIdentifier{oidExtKeyUsageTimestamp})
+	if err != nil {
+		return nil, err
+	}
+
+	template := &x509.Certificate{
+		SerialNumber:    new(big.Int).SetBytes(keyHash[:8]),
+		Subject:         pkix.Name{CommonName: "Local Time-Stamp Authority"},
+		NotBefore:       time.Date(2000, 1, 1, 0, 0, 0, 0, time.UTC),
+		NotAfter:        time.Date(2100, 1, 1, 0, 0, 0, 0, time.UTC),
+		KeyUsage:        x509.KeyUsageDigitalSignature,
+		ExtraExtensions: []pkix.Extension{{Id: oidExtKeyUsage, Critical: true, Value: extKeyUsage}},
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		return nil, err
+	}
+	return x509.ParseCertificate(der)
+}
+
+// parseOID parses a dotted object identifier such as 1.2.3.4.1
+func parseOID(s string) (asn1.ObjectIdentifier, error) {
+	parts := strings.Split(s, ".")
+	if len(parts) < 2 {
+		return nil, errors.New("invalid policy OID " + s)
+	}
+	oid := make(asn1.ObjectIdentifier, len(parts))
+	for i, part := range parts {
+		n, err := strconv.Atoi(part)
+		if err != nil || n < 0 {
+			return nil, errors.New("invalid policy OID " + s)
+		}
+		oid[i] = n
+	}
+	return oid, nil
+}
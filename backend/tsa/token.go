@@ -0,0 +1,209 @@
+package tsa
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/asn1"
+	"errors"
+	"fmt"
+)
+
+// oidECPublicKey is sometimes used by authorities as the signature algorithm of ECDSA signatures
+var oidECPublicKey = asn1.ObjectIdentifier{1, 2, 840, 10045, 2, 1}
+
+// Verify checks an RFC 3161 time-stamp token over a SHA-256 hash.
+// Parameters:
+// - token: the DER encoded time-stamp token
+// - hash: the SHA-256 hash the token must cover
+// - a: the authority that decides whether the signer of the token is trusted
+// Returns: the content of the token, or an error if the token is malformed, does not cover the hash,
+// has an invalid signature or is not signed by a trusted authority
+func Verify(token []byte, hash []byte, a Authority) (*TokenInfo, error) {
+	var ci contentInfo
+	if rest, err := asn1.Unmarshal(token, &ci); err != nil || len(rest) > 0 {
+		return nil, errors.New("malformed time-stamp token")
+	}
+	if !ci.ContentType.Equal(oidSignedData) {
+		return nil, errors.New("the time-stamp token is not a signed data")
+	}
+
+	var sd signedData
+	if rest, err := asn1.Unmarshal(ci.Content.Bytes, &sd); err != nil || len(rest) > 0 {
+		return nil, errors.New("malformed signed data")
+	}
+	if !sd.EncapContentInfo.EContentType.Equal(oidTSTInfo) {
+		return nil, errors.New("the signed data does not hold a TSTInfo")
+	}
+
+	var info tstInfo
+	if rest, err := asn1.Unmarshal(sd.EncapContentInfo.EContent, &info); err != nil || len(rest) > 0 {
+		return nil, errors.New("malformed TSTInfo")
+	}
+	if !info.MessageImprint.HashAlgorithm.Algorithm.Equal(oidSHA256) || !bytes.Equal(info.MessageImprint.HashedMessage, hash) {
+		return nil, errors.New("the time-stamp token does not cover this hash")
+	}
+
+	if len(sd.SignerInfos) != 1 {
+		return nil, errors.New("the time-stamp token must have exactly one signer")
+	}
+	signer := sd.SignerInfos[0]
+
+	certificates, err := x509.ParseCertificates(sd.Certificates.Bytes)
+	if err != nil {
+		return nil, fmt.Errorf("malformed certificates: %v", err)
+	}
+
+	// Find the certificate of the signer among the certificates of the token
+	var signerCert *x509.Certificate
+	var intermediates []*x509.Certificate
+	for _, cert := range certificates {
+		if bytes.Equal(cert.RawIssuer, signer.SID.Issuer.FullBytes) && cert.SerialNumber.Cmp(signer.SID.SerialNumber) == 0 {
+			signerCert = cert
+		} else {
+			intermediates = append(intermediates, cert)
+		}
+	}
+	if signerCert == nil {
+		return nil, errors.New("the certificate of the signer is not included in the token")
+	}
+
+	if err := verifySignedAttributes(&signer, sd.EncapContentInfo.EContent, signerCert); err != nil {
+		return nil, err
+	}
+
+	// The signature covers the DER encoding of the signed attributes as a SET
+	signedBytes := append([]byte{}, signer.SignedAttrs.FullBytes...)
+	signedBytes[0] = 0x31
+
+	algorithm, err := signatureAlgorithm(signer.DigestAlgorithm.Algorithm, signer.SignatureAlgorithm.Algorithm)
+	if err != nil {
+		return nil, err
+	}
+	if err := signerCert.CheckSignature(algorithm, signedBytes, signer.Signature); err != nil {
+		return nil, fmt.Errorf("invalid time-stamp token signature: %v", err)
+	}
+
+	if !hasTimestampingUsage(signerCert) {
+		return nil, errors.New("the signer certificate is not allowed to issue time-stamp tokens")
+	}
+	if err := a.VerifySigner(signerCert, intermediates, info.GenTime); err != nil {
+		return nil, err
+	}
+
+	return &TokenInfo{
+		GenTime:      info.GenTime,
+		SerialNumber: info.SerialNumber,
+		Policy:       info.Policy.String(),
+	}, nil
+}
+
+// verifySignedAttributes checks the content type, message digest and signing certificate attributes of a signer.
+// Parameters:
+// - signer: the signer info of the token
+// - content: the encapsulated TSTInfo the message digest must match
+// - signerCert: the certificate of the signer
+// Returns: an error if a mandatory attribute is missing or does not match
+func verifySignedAttributes(signer *signerInfo, content []byte, signerCert *x509.Certificate) error {
+	if len(signer.SignedAttrs.FullBytes) == 0 {
+		return errors.New("the time-stamp token has no signed attributes")
+	}
+
+	digest, ok := hashes[signer.DigestAlgorithm.Algorithm.String()]
+	if !ok || !digest.Available() {
+		return errors.New("unsupported digest algorithm")
+	}
+	h := digest.New()
+	h.Write(content)
+	expectedDigest := h.Sum(nil)
+
+	var contentTypeOK, messageDigestOK bool
+	for rest := signer.SignedAttrs.Bytes; len(rest) > 0; {
+		var attr attribute
+		var err error
+		if rest, err = asn1.Unmarshal(rest, &attr); err != nil {
+			return errors.New("malformed signed attribute")
+		}
+
+		switch {
+		case attr.Type.Equal(oidAttrContentType):
+			var contentType asn1.ObjectIdentifier
+			if _, err := asn1.Unmarshal(attr.Values.Bytes, &contentType); err != nil {
+				return errors.New("malformed content type attribute")
+			}
+			contentTypeOK = contentType.Equal(oidTSTInfo)
+		case attr.Type.Equal(oidAttrMessageDigest):
+			var messageDigest []byte
+			if _, err := asn1.Unmarshal(attr.Values.Bytes, &messageDigest); err != nil {
+				return errors.New("malformed message digest attribute")
+			}
+			messageDigestOK = bytes.Equal(messageDigest, expectedDigest)
+		case attr.Type.Equal(oidAttrSigningCertV2):
+			var signingCert signingCertificateV2
+			if _, err := asn1.Unmarshal(attr.Values.Bytes, &signingCert); err != nil || len(signingCert.Certs) == 0 {
+				return errors.New("malformed signing certificate attribute")
+			}
+			certID := signingCert.Certs[0]
+			if len(certID.HashAlgorithm.Algorithm) == 0 || certID.HashAlgorithm.Algorithm.Equal(oidSHA256) {
+				certHash := sha256.Sum256(signerCert.Raw)
+				if !bytes.Equal(certID.CertHash, certHash[:]) {
+					return errors.New("the signing certificate attribute does not match the signer")
+				}
+			}
+		}
+	}
+
+	if !contentTypeOK {
+		return errors.New("missing or invalid content type attribute")
+	}
+	if !messageDigestOK {
+		return errors.New("missing or invalid message digest attribute")
+	}
+	return nil
+}
+
+// signatureAlgorithm maps the digest and signature algorithms of a signer to an x509 signature algorithm
+func signatureAlgorithm(digest, signature asn1.ObjectIdentifier) (x509.SignatureAlgorithm, error) {
+	switch {
+	case signature.Equal(oidECDSAWithSHA256):
+		return x509.ECDSAWithSHA256, nil
+	case signature.Equal(oidECDSAWithSHA384):
+		return x509.ECDSAWithSHA384, nil
+	case signature.Equal(oidECDSAWithSHA512):
+		return x509.ECDSAWithSHA512, nil
+	case signature.Equal(oidSHA256WithRSA):
+		return x509.SHA256WithRSA, nil
+	case signature.Equal(oidSHA384WithRSA):
+		return x509.SHA384WithRSA, nil
+	case signature.Equal(oidSHA512WithRSA):
+		return x509.SHA512WithRSA, nil
+	case signature.Equal(oidRSAEncryption), signature.Equal(oidECPublicKey):
+		// The key algorithm alone, the hash comes from the digest algorithm
+		rsa := signature.Equal(oidRSAEncryption)
+		switch {
+		case digest.Equal(oidSHA256) && rsa:
+			return x509.SHA256WithRSA, nil
+		case digest.Equal(oidSHA384) && rsa:
+			return x509.SHA384WithRSA, nil
+		case digest.Equal(oidSHA512) && rsa:
+			return x509.SHA512WithRSA, nil
+		case digest.Equal(oidSHA256):
+			return x509.ECDSAWithSHA256, nil
+		case digest.Equal(oidSHA384):
+			return x509.ECDSAWithSHA384, nil
+		case digest.Equal(oidSHA512):
+			return x509.ECDSAWithSHA512, nil
+		}
+	}
+	return x509.UnknownSignatureAlgorithm, errors.New("unsupported signature algorithm")
+}
+
+// hasTimestampingUsage checks that a certificate is allowed to sign time-stamp tokens
+func hasTimestampingUsage(cert *x509.Certificate) bool {
+	for _, usage := range cert.ExtKeyUsage {
+		if usage == x509.ExtKeyUsageTimeStamping {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,228 @@
+package tsa
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/asn1"
+	"errors"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+	"time"
+)
+
+// The recorded responses were issued by OpenSSL 3.0, an independent RFC 3161 implementation, with an ECDSA P-256
+// authority certified by the root in testdata/openssl_ca.pem:
+//
+//	openssl ts -query -data data.txt -sha256 -cert -out query.tsq
+//	openssl ts -reply -config ts.cnf -queryfile query.tsq -out openssl_response.tsr
+//
+// data.txt holds recordedData. openssl_rejected.tsr answers a SHA-512 query the authority does not support.
+const recordedData = "canttouchme tsa test vector"
+
+var (
+	recordedGenTime = time.Date(2026, 10, 19, 7, 31, 23, 0, time.UTC)
+	recordedNonce   = big.NewInt(0x3D588B7C06342CD4)
+)
+
+// readTestdata reads a file of the testdata directory
+func readTestdata(t *testing.T, name string) []byte {
+	t.Helper()
+	data, err := os.ReadFile("testdata/" + name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return data
+}
+
+// recordedToken returns the token of the recorded response and the hash it covers
+func recordedToken(t *testing.T) ([]byte, []byte) {
+	t.Helper()
+	var resp timeStampResp
+	if rest, err := asn1.Unmarshal(readTestdata(t, "openssl_response.tsr"), &resp); err != nil || len(rest) > 0 {
+		t.Fatalf("the recorded response does not parse: %v", err)
+	}
+	if resp.Status.Status != 0 {
+		t.Fatalf("the recorded response has status %d", resp.Status.Status)
+	}
+	hash := sha256.Sum256([]byte(recordedData))
+	return resp.TimeStampToken.FullBytes, hash[:]
+}
+
+// recordedAuthority returns a remote authority trusting the root of the recorded responses
+func recordedAuthority(t *testing.T, url string) *HTTPAuthority {
+	t.Helper()
+	a, err := NewHTTPAuthority(url, "testdata/openssl_ca.pem")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return a
+}
+
+func TestVerifyRecordedResponse(t *testing.T) {
+	token, hash := recordedToken(t)
+
+	info, err := Verify(token, hash, recordedAuthority(t, ""))
+	if err != nil {
+		t.Fatalf("the recorded token does not verify: %v", err)
+	}
+	if !info.GenTime.Equal(recordedGenTime) {
+		t.Errorf("got time %v, want %v", info.GenTime, recordedGenTime)
+	}
+	if info.SerialNumber.Cmp(big.NewInt(2)) != 0 {
+		t.Errorf("got serial number %v, want 2", info.SerialNumber)
+	}
+	if info.Policy != "1.2.3.4.1" {
+		t.Errorf("got policy %s, want 1.2.3.4.1", info.Policy)
+	}
+
+	parsed, err := parseTSTInfo(token)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if parsed.Nonce == nil || parsed.Nonce.Cmp(recordedNonce) != 0 {
+		t.Errorf("got nonce %v, want %v", parsed.Nonce, recordedNonce)
+	}
+}
+
+func TestVerifyRejectsTheRecordedTokenForAnotherHashOrSigner(t *testing.T) {
+	token, hash := recordedToken(t)
+
+	other := sha256.Sum256([]byte("another block"))
+	if _, err := Verify(token, other[:], recordedAuthority(t, "")); err == nil {
+		t.Error("the token verified for another hash")
+	}
+
+	// The signer is not the bundled authority, nor certified by another root
+	local, err := NewLocalAuthority("", "1.2.3.4.1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := Verify(token, hash, local); !errors.Is(err, ErrUntrustedSigner) {
+		t.Errorf("the local authority trusted the recorded token: %v", err)
+	}
+	roots := x509.NewCertPool()
+	roots.AddCert(local.cert)
+	if _, err := Verify(token, hash, &HTTPAuthority{roots: roots}); !errors.Is(err, ErrUntrustedSigner) {
+		t.Errorf("another root trusted the recorded token: %v", err)
+	}
+}
+
+func TestVerifyRejectsMalformedTokens(t *testing.T) {
+	token, hash := recordedToken(t)
+	a := recordedAuthority(t, "")
+
+	// Every truncation of the token, down to nothing
+	for size := 0; size < len(token); size++ {
+		if _, err := Verify(token[:size], hash, a); err == nil {
+			t.Fatalf("the token truncated to %d of %d bytes verified", size, len(token))
+		}
+	}
+
+	tests := []struct {
+		name  string
+		token []byte
+	}{
+		{"trailing data", append(append([]byte{}, token...), 0)},
+		{"not DER", []byte("not a time-stamp token")},
+		{"time-stamp response instead of the token", readTestdata(t, "openssl_response.tsr")},
+		{"modified signature", modify(token, len(token)-1)},
+		{"modified time", modify(token, bytes.Index(token, []byte("20261019073123Z")))},
+		{"modified hash", modify(token, bytes.Index(token, hash))},
+	}
+	for _, tt := range tests {
+		if _, err := Verify(tt.token, hash, a); err == nil {
+			t.Errorf("%s: the token verified", tt.name)
+		}
+	}
+}
+
+// modify returns a copy of a token with one byte flipped
+func modify(token []byte, index int) []byte {
+	modified := append([]byte{}, token...)
+	modified[index] ^= 1
+	return modified
+}
+
+func TestTimestampOverHTTP(t *testing.T) {
+	response := readTestdata(t, "openssl_response.tsr")
+	rejected := readTestdata(t, "openssl_rejected.tsr")
+
+	tests := []struct {
+		name   string
+		status int
+		body   []byte
+		err    string
+	}{
+		{"replayed response", http.StatusOK, response, "nonce"},
+		{"truncated response", http.StatusOK, response[:len(response)/2], "malformed"},
+		{"empty response", http.StatusOK, nil, "malformed"},
+		{"rejected request", http.StatusOK, rejected, "status 2"},
+		{"HTTP error", http.StatusInternalServerError, nil, "HTTP 500"},
+	}
+	for _, tt := range tests {
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.Header.Get("Content-Type") != "application/timestamp-query" {
+				t.Errorf("%s: got content type %q", tt.name, r.Header.Get("Content-Type"))
+			}
+			w.WriteHeader(tt.status)
+			w.Write(tt.body)
+		}))
+		hash := sha256.Sum256([]byte(recordedData))
+		_, err := recordedAuthority(t, server.URL).Timestamp(hash[:])
+		server.Close()
+
+		if err == nil || !strings.Contains(err.Error(), tt.err) {
+			t.Errorf("%s: got error %v, want one mentioning %q", tt.name, err, tt.err)
+		}
+	}
+}
+
+func TestLocalAuthorityRoundTrip(t *testing.T) {
+	local, err := NewLocalAuthority("", "1.2.3.4.1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	hash := sha256.Sum256([]byte("block"))
+
+	token, err := local.Timestamp(hash[:])
+	if err != nil {
+		t.Fatal(err)
+	}
+	info, err := Verify(token, hash[:], local)
+	if err != nil {
+		t.Fatalf("the local token does not verify: %v", err)
+	}
+	if info.Policy != "1.2.3.4.1" || time.Since(info.GenTime) > time.Minute {
+		t.Errorf("unexpected token content: %+v", info)
+	}
+
+	other, err := NewLocalAuthority("", "1.2.3.4.1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := Verify(token, hash[:], other); !errors.Is(err, ErrUntrustedSigner) {
+		t.Errorf("another local authority trusted the token: %v", err)
+	}
+	if _, err := local.Timestamp(hash[:16]); err == nil {
+		t.Error("a hash that is not SHA-256 was timestamped")
+	}
+}
+
+func TestNewLocalAuthorityRejectsInvalidSettings(t *testing.T) {
+	tests := []struct{ key, policy string }{
+		{"not base64!", "1.2.3.4.1"},
+		{"AAAA", "1.2.3.4.1"},
+		{"", "1"},
+		{"", "1.2.x"},
+	}
+	for _, tt := range tests {
+		if _, err := NewLocalAuthority(tt.key, tt.policy); err == nil {
+			t.Errorf("key %q and policy %q were accepted", tt.key, tt.policy)
+		}
+	}
+}
@@ -1,9 +1,15 @@
 package util
 
 import (
+	"errors"
+	"net/mail"
 	"reflect"
+	"strings"
 )
 
+// MaxEmailLength is the longest address that can be delivered, per RFC 5321
+const MaxEmailLength = 254
+
 // ValidateStruct checks if all fields in a struct are valid (non-zero values).
 // Parameters:
 // - s: the struct to validate, can be a pointer to a struct or a struct itself
@@ -44,3 +50,24 @@ func ValidateStruct(s interface{}) bool {
 
 	return true // Return true if all fields are valid
 }
+
+// ValidateEmail checks that a string is a single bare address, like "name@example.com", that a mail server can
+// deliver to: no display name, no comment, a domain with a dot and at most MaxEmailLength characters.
+// Parameters:
+// - email: the address to validate
+// Returns: an error describing why the address is refused, nil if it is valid
+func ValidateEmail(email string) error {
+	if len(email) > MaxEmailLength {
+		return errors.New("email must be at most 254 characters")
+	}
+	address, err := mail.ParseAddress(email)
+	if err != nil || address.Address != email || address.Name != "" {
+		return errors.New("invalid email format")
+	}
+	at := strings.LastIndex(email, "@")
+	domain := email[at+1:]
+	if !strings.Contains(domain, ".") || strings.HasPrefix(domain, ".") || strings.HasSuffix(domain, ".") {
+		return errors.New("invalid email format")
+	}
+	return nil
+}
@@ -0,0 +1,131 @@
+package vault
+
+import (
+	"archive/tar"
+	"backend/models"
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+)
+
+const (
+	maxFileSize     = 64 << 20 // Limits the size of a single file inside an archive
+	maxArchiveFiles = 100000   // Limits the number of entries of an archive, every note is a file
+)
+
+// DefaultMaxSize is the limit of the decompressed content of the archives read by the clients
+const DefaultMaxSize = 1 << 30
+
+// Archive is a vault archive read by Read
+type Archive struct {
+	Manifest      models.VaultManifest
+	ManifestBytes []byte                 // Exact bytes of manifest.json, covered by Signature
+	Signature     *models.VaultSignature // Server signature of the manifest
+	Notes         []*models.VaultNote    // The notes, in the order of the manifest
+}
+
+// Read reads a vault archive and checks that it is complete.
+// The files of the notes are checked against the hashes of the manifest, but neither the manifest
+// signature nor the block chains are verified: that depends on the keys the caller trusts.
+// The archive is held in memory, so the decompressed size and the number of files are limited: a small
+// compressed archive cannot expand past maxSize.
+// Parameters:
+// - r: the gzip compressed tar archive
+// - maxSize: the limit of the total decompressed size of the files, in bytes
+// Returns: the archive, or an error if it is malformed, incomplete, too large or holds files the manifest does
+// not list
+func Read(r io.Reader, maxSize int64) (*Archive, error) {
+	gz, err := gzip.NewReader(r)
+	if err != nil {
+		return nil, fmt.Errorf("the archive is not gzip compressed: %v", err)
+	}
+	defer gz.Close()
+
+	files := make(map[string][]byte)
+	tr := tar.NewReader(gz)
+	remaining := maxSize
+	for entries := 0; ; entries++ {
+		header, err := tr.Next()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("malformed archive: %v", err)
+		}
+		if entries >= maxArchiveFiles {
+			return nil, fmt.Errorf("the archive holds more than %d files", maxArchiveFiles)
+		}
+		if header.Typeflag != tar.TypeReg {
+			continue
+		}
+		if header.Size > maxFileSize {
+			return nil, fmt.Errorf("%s is too large", header.Name)
+		}
+		if header.Size > remaining {
+			return nil, fmt.Errorf("the archive is larger than %d bytes once decompressed", maxSize)
+		}
+		if _, exists := files[header.Name]; exists {
+			return nil, fmt.Errorf("%s appears twice in the archive", header.Name)
+		}
+		data, err := io.ReadAll(io.LimitReader(tr, header.Size))
+		if err != nil {
+			return nil, fmt.Errorf("error reading %s: %v", header.Name, err)
+		}
+		remaining -= int64(len(data))
+		files[header.Name] = data
+	}
+
+	archive := &Archive{}
+	var ok bool
+	if archive.ManifestBytes, ok = files[ManifestFile]; !ok {
+		return nil, errors.New("the archive has no manifest")
+	}
+	if err := json.Unmarshal(archive.ManifestBytes, &archive.Manifest); err != nil {
+		return nil, fmt.Errorf("malformed manifest: %v", err)
+	}
+	if archive.Manifest.Version != models.VaultFormatVersion {
+		return nil, fmt.Errorf("unsupported archive version %d", archive.Manifest.Version)
+	}
+
+	signature, ok := files[SignatureFile]
+	if !ok {
+		return nil, errors.New("the archive has no manifest signature")
+	}
+	if err := json.Unmarshal(signature, &archive.Signature); err != nil {
+		return nil, fmt.Errorf("malformed manifest signature: %v", err)
+	}
+
+	for _, entry := range archive.Manifest.Notes {
+		data, ok := files[entry.Path]
+		if !ok {
+			return nil, fmt.Errorf("%s is listed in the manifest but missing from the archive", entry.Path)
+		}
+		delete(files, entry.Path)
+
+		hash := sha256.Sum256(data)
+		if base64.StdEncoding.EncodeToString(hash[:]) != entry.SHA256 {
+			return nil, fmt.Errorf("%s does not match the hash of the manifest", entry.Path)
+		}
+
+		note := &models.VaultNote{}
+		if err := json.Unmarshal(data, note); err != nil {
+			return nil, fmt.Errorf("malformed note file %s: %v", entry.Path, err)
+		}
+		if note.NoteID != entry.NoteID {
+			return nil, fmt.Errorf("%s holds note %s instead of %s", entry.Path, note.NoteID, entry.NoteID)
+		}
+		archive.Notes = append(archive.Notes, note)
+	}
+
+	delete(files, ManifestFile)
+	delete(files, SignatureFile)
+	for name := range files {
+		return nil, fmt.Errorf("%s is not listed in the manifest", name)
+	}
+
+	return archive, nil
+}
@@ -0,0 +1,355 @@
+package vault_test
+
+import (
+	"archive/tar"
+	"backend/crypto"
+	"backend/models"
+	"backend/vault"
+	"bytes"
+	"compress/gzip"
+	"crypto/ed25519"
+	"encoding/base64"
+	"fmt"
+	"io"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+)
+
+const genesisHash = "AAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAA="
+
+// testKey is a deterministic Ed25519 key, so the tests do not depend on a random source
+func testKey(seed byte) (ed25519.PrivateKey, string) {
+	privateKey := ed25519.NewKeyFromSeed(bytes.Repeat([]byte{seed}, ed25519.SeedSize))
+	return privateKey, base64.StdEncoding.EncodeToString(privateKey.Public().(ed25519.PublicKey))
+}
+
+// signedNote builds a note of versions signed with a key, followed by a tombstone when deleted is set
+func signedNote(t *testing.T, noteID string, key ed25519.PrivateKey, publicKey string, versions int, deleted bool) *models.VaultNote {
+	t.Helper()
+
+	note := &models.VaultNote{NoteID: noteID, Deleted: deleted}
+	prevHash := genesisHash
+	timestamp := time.Date(2026, 10, 19, 8, 0, 0, 0, time.UTC)
+	appendBlock := func(block models.Block) {
+		block.PrevHash = prevHash
+		block.Timestamp = timestamp
+		block.Signature = base64.StdEncoding.EncodeToString(ed25519.Sign(key, crypto.BlockSignaturePayload(&block)))
+		hash, err := crypto.BlockHash(block)
+		if err != nil {
+			t.Fatalf("error hashing block: %v", err)
+		}
+		note.Blocks = append(note.Blocks, block)
+		note.SignerKeys = append(note.SignerKeys, publicKey)
+		prevHash = hash
+		timestamp = timestamp.Add(time.Minute)
+	}
+
+	for i := 0; i < versions; i++ {
+		appendBlock(models.Block{
+			IV:          "aXYtb2YtdGhlLWJvZHk=",
+			IVTitle:     "aXYtb2YtdGhlLXRpdGxl",
+			CipherTitle: "dGl0bGU=",
+			Ciphertext:  base64.StdEncoding.EncodeToString([]byte{byte(i)}),
+			MAC:         "bWFj",
+		})
+	}
+	if deleted {
+		appendBlock(models.Block{Kind: models.BlockKindTombstone, NoteID: noteID})
+	}
+	note.HeadHash = prevHash
+	return note
+}
+
+// writeArchive writes the notes to an archive whose manifest is signed with the server signing key
+func writeArchive(t *testing.T, notes ...*models.VaultNote) []byte {
+	t.Helper()
+
+	var buf bytes.Buffer
+	writer := vault.NewWriter(&buf, models.VaultAccount{Name: "alice"})
+	for _, note := range notes {
+		if err := writer.AddNote(note); err != nil {
+			t.Fatalf("error adding note: %v", err)
+		}
+	}
+	if err := writer.Close(crypto.SignVaultManifest); err != nil {
+		t.Fatalf("error closing archive: %v", err)
+	}
+	return buf.Bytes()
+}
+
+// rewriteArchive unpacks an archive, lets edit change its files and packs them again in the same order.
+// The files edit sets to nil are left out.
+func rewriteArchive(t *testing.T, archive []byte, edit func(files map[string][]byte)) []byte {
+	t.Helper()
+
+	gz, err := gzip.NewReader(bytes.NewReader(archive))
+	if err != nil {
+		t.Fatalf("error reading archive: %v", err)
+	}
+	var names []string
+	files := make(map[string][]byte)
+	tr := tar.NewReader(gz)
+	for {
+		header, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatalf("error reading archive: %v", err)
+		}
+		data, err := io.ReadAll(tr)
+		if err != nil {
+			t.Fatalf("error reading %s: %v", header.Name, err)
+		}
+		names = append(names, header.Name)
+		files[header.Name] = data
+	}
+
+	edit(files)
+	for name := range files {
+		if !slices.Contains(names, name) {
+			names = append(names, name)
+		}
+	}
+
+	var buf bytes.Buffer
+	gzw := gzip.NewWriter(&buf)
+	tw := tar.NewWriter(gzw)
+	for _, name := range names {
+		data := files[name]
+		if data == nil {
+			continue
+		}
+		if err := tw.WriteHeader(&tar.Header{Name: name, Mode: 0o644, Size: int64(len(data))}); err != nil {
+			t.Fatalf("error writing %s: %v", name, err)
+		}
+		if _, err := tw.Write(data); err != nil {
+			t.Fatalf("error writing %s: %v", name, err)
+		}
+	}
+	if err := tw.Close(); err != nil {
+		t.Fatalf("error closing archive: %v", err)
+	}
+	if err := gzw.Close(); err != nil {
+		t.Fatalf("error closing archive: %v", err)
+	}
+	return buf.Bytes()
+}
+
+// serverPublicKey sets a fixed server signing key and returns its public half
+func serverPublicKey(t *testing.T) string {
+	t.Helper()
+
+	if err := crypto.SetServerSigningKey(base64.StdEncoding.EncodeToString(bytes.Repeat([]byte{7}, ed25519.SeedSize))); err != nil {
+		t.Fatalf("error setting server signing key: %v", err)
+	}
+	serverKey, err := crypto.ServerPublicKey()
+	if err != nil {
+		t.Fatalf("error reading server public key: %v", err)
+	}
+	return serverKey.PublicKey
+}
+
+func TestReadRoundTrip(t *testing.T) {
+	publicKey := serverPublicKey(t)
+	key, signerKey := testKey(1)
+	notes := []*models.VaultNote{
+		signedNote(t, "note-a", key, signerKey, 2, false),
+		signedNote(t, "note-b", key, signerKey, 1, true),
+	}
+
+	archive, err := vault.Read(bytes.NewReader(writeArchive(t, notes...)), vault.DefaultMaxSize)
+	if err != nil {
+		t.Fatalf("error reading archive: %v", err)
+	}
+	if valid, err := crypto.VerifyVaultManifest(publicKey, archive.ManifestBytes, archive.Signature); err != nil || !valid {
+		t.Errorf("the manifest signature does not verify: %v", err)
+	}
+	if len(archive.Notes) != len(notes) {
+		t.Fatalf("got %d notes, want %d", len(archive.Notes), len(notes))
+	}
+	for i, note := range archive.Notes {
+		if note.NoteID != notes[i].NoteID || note.HeadHash != notes[i].HeadHash || len(note.Blocks) != len(notes[i].Blocks) {
+			t.Errorf("note %d does not match the written note", i+1)
+		}
+		if err := vault.VerifyNote(note, func(key string) bool { return key == signerKey }); err != nil {
+			t.Errorf("note %s does not verify: %v", note.NoteID, err)
+		}
+	}
+}
+
+func TestReadRejectsIncompleteArchives(t *testing.T) {
+	serverPublicKey(t)
+	key, signerKey := testKey(1)
+	archive := writeArchive(t, signedNote(t, "note-a", key, signerKey, 2, false))
+	notePath := vault.NotesDir + "note-a.json"
+
+	tests := []struct {
+		name string
+		edit func(files map[string][]byte)
+		want string
+	}{
+		{"tampered note", func(files map[string][]byte) {
+			files[notePath] = bytes.Replace(files[notePath], []byte("note-a"), []byte("note-b"), 1)
+		}, "does not match the hash of the manifest"},
+		{"missing note", func(files map[string][]byte) {
+			files[notePath] = nil
+		}, "missing from the archive"},
+		{"unlisted file", func(files map[string][]byte) {
+			files[vault.NotesDir+"note-c.json"] = []byte("{}")
+		}, "not listed in the manifest"},
+		{"missing manifest", func(files map[string][]byte) {
+			files[vault.ManifestFile] = nil
+		}, "no manifest"},
+		{"missing signature", func(files map[string][]byte) {
+			files[vault.SignatureFile] = nil
+		}, "no manifest signature"},
+		{"malformed manifest", func(files map[string][]byte) {
+			files[vault.ManifestFile] = []byte("{")
+		}, "malformed manifest"},
+		{"unsupported version", func(files map[string][]byte) {
+			files[vault.ManifestFile] = bytes.Replace(files[vault.ManifestFile],
+				fmt.Appendf(nil, `"version": %d`, models.VaultFormatVersion), []byte(`"version": 99`), 1)
+		}, "unsupported archive version"},
+	}
+
+	for _, tt := range tests {
+		_, err := vault.Read(bytes.NewReader(rewriteArchive(t, archive, tt.edit)), vault.DefaultMaxSize)
+		if err == nil || !strings.Contains(err.Error(), tt.want) {
+			t.Errorf("%s: got error %v, want %q", tt.name, err, tt.want)
+		}
+	}
+
+	if _, err := vault.Read(bytes.NewReader(archive), 16); err == nil {
+		t.Error("an archive larger than the limit was read")
+	}
+}
+
+func TestTamperedManifestDoesNotVerify(t *testing.T) {
+	publicKey := serverPublicKey(t)
+	key, signerKey := testKey(1)
+	archive := writeArchive(t, signedNote(t, "note-a", key, signerKey, 1, false))
+
+	// The manifest still lists the note file, but claims the account of someone else
+	tampered := rewriteArchive(t, archive, func(files map[string][]byte) {
+		files[vault.ManifestFile] = bytes.Replace(files[vault.ManifestFile], []byte(`"alice"`), []byte(`"mallory"`), 1)
+	})
+	read, err := vault.Read(bytes.NewReader(tampered), vault.DefaultMaxSize)
+	if err != nil {
+		t.Fatalf("error reading archive: %v", err)
+	}
+	if valid, _ := crypto.VerifyVaultManifest(publicKey, read.ManifestBytes, read.Signature); valid {
+		t.Error("the signature verifies a tampered manifest")
+	}
+
+	// A manifest signed by another server does not verify against the key of this one
+	foreign := signedNote(t, "note-a", key, signerKey, 1, false)
+	if err := crypto.SetServerSigningKey(base64.StdEncoding.EncodeToString(bytes.Repeat([]byte{8}, ed25519.SeedSize))); err != nil {
+		t.Fatalf("error setting server signing key: %v", err)
+	}
+	read, err = vault.Read(bytes.NewReader(writeArchive(t, foreign)), vault.DefaultMaxSize)
+	if err != nil {
+		t.Fatalf("error reading archive: %v", err)
+	}
+	if valid, _ := crypto.VerifyVaultManifest(publicKey, read.ManifestBytes, read.Signature); valid {
+		t.Error("a manifest signed by another server verifies")
+	}
+}
+
+func TestVerifyNote(t *testing.T) {
+	key, signerKey := testKey(1)
+	foreignKey, foreignSignerKey := testKey(2)
+	trusted := func(key string) bool { return key == signerKey }
+
+	tests := []struct {
+		name string
+		edit func(note *models.VaultNote)
+		want string
+	}{
+		{"valid", func(note *models.VaultNote) {}, ""},
+		{"no blocks", func(note *models.VaultNote) {
+			note.Blocks, note.SignerKeys = nil, nil
+		}, "no blocks"},
+		{"missing signer key", func(note *models.VaultNote) {
+			note.SignerKeys = note.SignerKeys[1:]
+		}, "signer key of every block"},
+		{"modified block", func(note *models.VaultNote) {
+			note.Blocks[0].Ciphertext = "bW9kaWZpZWQ="
+		}, "valid chain"},
+		{"dropped block", func(note *models.VaultNote) {
+			note.Blocks = append(note.Blocks[:1], note.Blocks[2:]...)
+			note.SignerKeys = note.SignerKeys[1:]
+		}, "valid chain"},
+		{"wrong genesis", func(note *models.VaultNote) {
+			note.Blocks[0].PrevHash = note.HeadHash
+		}, "valid chain"},
+		{"wrong head hash", func(note *models.VaultNote) {
+			note.HeadHash = note.Blocks[0].PrevHash
+		}, "head hash"},
+		{"foreign signer", func(note *models.VaultNote) {
+			note.SignerKeys[1] = foreignSignerKey
+		}, "untrusted key"},
+		{"wrong signer", func(note *models.VaultNote) {
+			note.SignerKeys[1] = note.SignerKeys[0]
+			note.Blocks[1].Signature = base64.StdEncoding.EncodeToString(ed25519.Sign(foreignKey, crypto.BlockSignaturePayload(&note.Blocks[1])))
+			rechain(t, note, 2)
+		}, "invalid signature"},
+		{"listed in the trash", func(note *models.VaultNote) {
+			note.Deleted = true
+		}, "head is not a tombstone"},
+	}
+
+	for _, tt := range tests {
+		note := signedNote(t, "note-a", key, signerKey, 3, false)
+		tt.edit(note)
+		err := vault.VerifyNote(note, trusted)
+		switch {
+		case tt.want == "" && err != nil:
+			t.Errorf("%s: got error %v, want none", tt.name, err)
+		case tt.want != "" && (err == nil || !strings.Contains(err.Error(), tt.want)):
+			t.Errorf("%s: got error %v, want %q", tt.name, err, tt.want)
+		}
+	}
+}
+
+func TestVerifyNoteLifecycle(t *testing.T) {
+	key, signerKey := testKey(1)
+	trusted := func(key string) bool { return key == signerKey }
+
+	note := signedNote(t, "note-a", key, signerKey, 2, true)
+	if err := vault.VerifyNote(note, trusted); err != nil {
+		t.Errorf("a deleted note does not verify: %v", err)
+	}
+
+	// A chain whose tombstone was signed for another note is not accepted for this one
+	moved := signedNote(t, "note-b", key, signerKey, 2, true)
+	moved.NoteID = "note-a"
+	if err := vault.VerifyNote(moved, trusted); err == nil || !strings.Contains(err.Error(), "belongs to another note") {
+		t.Errorf("got error %v for a tombstone of another note", err)
+	}
+
+	// A tombstone listed outside of the trash is rejected
+	note.Deleted = false
+	if err := vault.VerifyNote(note, trusted); err == nil {
+		t.Error("a note whose head is a tombstone verifies outside of the trash")
+	}
+}
+
+// rechain fixes the previous hashes from block from on and the head hash after a block was changed
+func rechain(t *testing.T, note *models.VaultNote, from int) {
+	t.Helper()
+
+	for i := from; i <= len(note.Blocks); i++ {
+		hash, err := crypto.BlockHash(note.Blocks[i-1])
+		if err != nil {
+			t.Fatalf("error hashing block: %v", err)
+		}
+		if i == len(note.Blocks) {
+			note.HeadHash = hash
+		} else {
+			note.Blocks[i].PrevHash = hash
+		}
+	}
+}
@@ -0,0 +1,99 @@
+package vault
+
+import (
+	"backend/crypto"
+	"backend/models"
+	"errors"
+	"fmt"
+)
+
+// VerifyNote checks the block chain of a note read from an archive.
+// Parameters:
+// - note: a pointer to the note to check
+// - trusted: tells whether a public key, Ed25519 or ML-DSA, may sign the blocks of the note
+// Returns: an error describing the first problem found, nil if the chain, every block signature and the order of
+// the deletions and restores of the note are valid
+func VerifyNote(note *models.VaultNote, trusted func(publicKey string) bool) error {
+	if len(note.Blocks) == 0 {
+		return errors.New("the note has no blocks")
+	}
+	if len(note.SignerKeys) != len(note.Blocks) {
+		return errors.New("the note does not list the signer key of every block")
+	}
+	if len(note.PQSignerKeys) != 0 && len(note.PQSignerKeys) != len(note.Blocks) {
+		return errors.New("the note does not list the ML-DSA signer key of every block")
+	}
+
+	valid, err := crypto.VerifyBlockChain(note.Blocks)
+	if err != nil || !valid {
+		return errors.New("the blocks do not form a valid chain")
+	}
+
+	headHash, err := crypto.BlockHash(note.Blocks[len(note.Blocks)-1])
+	if err != nil {
+		return err
+	}
+	if headHash != note.HeadHash {
+		return errors.New("the head hash does not match the last block")
+	}
+
+	for i := range note.Blocks {
+		signerKey := note.SignerKeys[i]
+		if !trusted(signerKey) {
+			return fmt.Errorf("block %d is signed with an untrusted key", i+1)
+		}
+		pqSignerKey := note.PQSignerKey(i)
+		if pqSignerKey != "" && !trusted(pqSignerKey) {
+			return fmt.Errorf("block %d is signed with an untrusted ML-DSA key", i+1)
+		}
+		valid, err := crypto.VerifyBlockSignature(signerKey, pqSignerKey, &note.Blocks[i])
+		if err != nil || !valid {
+			return fmt.Errorf("block %d has an invalid signature", i+1)
+		}
+	}
+
+	if err := verifyLifecycle(note); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// verifyLifecycle checks the lifecycle blocks of a note: each one belongs to the note, a tombstone only follows a
+// note outside of the trash, and a restore or a new version only follows a note inside of it
+func verifyLifecycle(note *models.VaultNote) error {
+	deleted := false
+	for i := range note.Blocks {
+		block := &note.Blocks[i]
+		switch block.Kind {
+		case "":
+			if deleted {
+				return fmt.Errorf("block %d is a new version of a note inside the trash", i+1)
+			}
+			if block.NoteID != "" {
+				return fmt.Errorf("block %d is a version of the note but carries a note ID", i+1)
+			}
+			continue
+		case models.BlockKindTombstone:
+			if deleted || i == 0 {
+				return fmt.Errorf("block %d deletes a note that is not outside of the trash", i+1)
+			}
+		case models.BlockKindRestore:
+			if !deleted {
+				return fmt.Errorf("block %d restores a note that is not inside the trash", i+1)
+			}
+		}
+		if err := crypto.ValidateLifecycleBlock(block); err != nil {
+			return fmt.Errorf("block %d: %v", i+1, err)
+		}
+		if block.NoteID != note.NoteID {
+			return fmt.Errorf("block %d belongs to another note", i+1)
+		}
+		deleted = block.Kind == models.BlockKindTombstone
+	}
+
+	if deleted != note.Deleted {
+		return errors.New("the note is listed in the trash but its head is not a tombstone, or the other way around")
+	}
+	return nil
+}
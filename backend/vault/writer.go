@@ -0,0 +1,137 @@
+// Package vault reads and writes vault archives, the portable backup of a whole account.
+//
+// A vault archive is a gzip compressed tar file holding:
+//   - notes/<note_id>.json: one file per note with its full block chain (models.VaultNote)
+//   - manifest.json: the account keys and salts and the SHA-256 of every note file (models.VaultManifest)
+//   - manifest.sig: the server signature over the exact bytes of manifest.json (models.VaultSignature)
+//
+// The note files come first so the archive can be streamed without holding the whole vault in memory.
+package vault
+
+import (
+	"archive/tar"
+	"backend/models"
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"io"
+	"time"
+)
+
+// Names of the files inside a vault archive
+const (
+	ManifestFile  = "manifest.json"
+	SignatureFile = "manifest.sig"
+	NotesDir      = "notes/"
+)
+
+// Signer signs the manifest of an archive, crypto.SignVaultManifest for the server
+type Signer func(manifest []byte) (*models.VaultSignature, error)
+
+// Writer streams a vault archive.
+// Fields:
+// - gz: the gzip stream wrapping the output
+// - tw: the tar stream inside the gzip stream
+// - manifest: the manifest being built as the notes are added
+// - modTime: the modification time written on every file of the archive
+type Writer struct {
+	gz       *gzip.Writer
+	tw       *tar.Writer
+	manifest models.VaultManifest
+	modTime  time.Time
+}
+
+// NewWriter starts a vault archive.
+// Parameters:
+// - w: the output the archive is streamed to
+// - account: the keys and salts of the exported account
+// Returns: a pointer to the writer, Close must be called to write the manifest
+func NewWriter(w io.Writer, account models.VaultAccount) *Writer {
+	gz := gzip.NewWriter(w)
+	now := time.Now().UTC().Truncate(time.Second)
+	return &Writer{
+		gz: gz,
+		tw: tar.NewWriter(gz),
+		manifest: models.VaultManifest{
+			Version:    models.VaultFormatVersion,
+			ExportedAt: now,
+			Account:    account,
+			Notes:      []models.VaultNoteEntry{},
+		},
+		modTime: now,
+	}
+}
+
+// AddNote writes the file of a note and records it in the manifest.
+// Parameters:
+// - note: a pointer to the note to write
+// Returns: an error if the note cannot be encoded or written
+func (v *Writer) AddNote(note *models.VaultNote) error {
+	data, err := json.MarshalIndent(note, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	path := NotesDir + note.NoteID + ".json"
+	if err := v.writeFile(path, data); err != nil {
+		return err
+	}
+
+	hash := sha256.Sum256(data)
+	v.manifest.Notes = append(v.manifest.Notes, models.VaultNoteEntry{
+		NoteID:     note.NoteID,
+		Path:       path,
+		SHA256:     base64.StdEncoding.EncodeToString(hash[:]),
+		HeadHash:   note.HeadHash,
+		BlockCount: uint(len(note.Blocks)),
+		Deleted:    note.Deleted,
+	})
+	return nil
+}
+
+// Close writes the manifest and its signature and flushes the archive.
+// Parameters:
+// - sign: the function signing the manifest
+// Returns: an error if the manifest cannot be signed or written
+func (v *Writer) Close(sign Signer) error {
+	manifest, err := json.MarshalIndent(v.manifest, "", "  ")
+	if err != nil {
+		return err
+	}
+	signature, err := sign(manifest)
+	if err != nil {
+		return err
+	}
+	signatureData, err := json.MarshalIndent(signature, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	if err := v.writeFile(ManifestFile, manifest); err != nil {
+		return err
+	}
+	if err := v.writeFile(SignatureFile, signatureData); err != nil {
+		return err
+	}
+
+	if err := v.tw.Close(); err != nil {
+		return err
+	}
+	return v.gz.Close()
+}
+
+// writeFile writes one regular file to the archive
+func (v *Writer) writeFile(name string, data []byte) error {
+	header := &tar.Header{
+		Name:    name,
+		Mode:    0o644,
+		Size:    int64(len(data)),
+		ModTime: v.modTime,
+	}
+	if err := v.tw.WriteHeader(header); err != nil {
+		return err
+	}
+	_, err := v.tw.Write(data)
+	return err
+}
@@ -14,6 +14,25 @@ CREATE TABLE users (
     hmac_salt VARCHAR(255) NOT NULL,
     hmac_type VARCHAR(30) NOT NULL,
     encryption_type VARCHAR(30) NOT NULL,
+    suite_id SMALLINT UNSIGNED NOT NULL DEFAULT 0, -- crypto suite of the account, see crypto/suites.go
+    -- derivation of the login key from the password and login_salt, see crypto/kdf.go
+    kdf_algorithm VARCHAR(30) NOT NULL DEFAULT 'pbkdf2-sha256',
+    kdf_iterations INT UNSIGNED NOT NULL DEFAULT 100000,
+    kdf_memory_kib INT UNSIGNED NOT NULL DEFAULT 0,
+    kdf_parallelism TINYINT UNSIGNED NOT NULL DEFAULT 0,
+    signature_type VARCHAR(30) NOT NULL DEFAULT 'ed25519', -- signature scheme of the blocks, see crypto/mldsa.go
+    pq_pub_key TEXT NOT NULL, -- ML-DSA-65 public key of the hybrid signature types, empty otherwise
+    -- offline recovery key: its Ed25519 public key, and the encryption and HMAC keys sealed under it
+    recovery_pub_key VARCHAR(255) NOT NULL DEFAULT '',
+    recovery_escrow TEXT NOT NULL,
+    wrapped_keys TEXT NOT NULL, -- keys sealed under the password once it was recovered, empty while they are derived from it
+    email_verified BOOLEAN NOT NULL DEFAULT FALSE,
+    -- email change waiting for the confirmation of both addresses, the nonce is named by the tokens of the emails
+    pending_email VARCHAR(255) NOT NULL DEFAULT '',
+    email_change_nonce VARCHAR(64) NOT NULL DEFAULT '',
+    email_change_old_confirmed BOOLEAN NOT NULL DEFAULT FALSE,
+    email_change_new_confirmed BOOLEAN NOT NULL DEFAULT FALSE,
+    email_change_expires_at TIMESTAMP NULL,
     created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
     updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP,
     INDEX (email)
@@ -32,10 +51,99 @@ CREATE TABLE challenges (
     INDEX (expires_at)
 );
 
--- Notes table for storing encrypted notes
+-- Challenges signed by the new accounts to prove they hold their private key, not tied to a user yet
+CREATE TABLE registration_challenges (
+    challenge_value VARCHAR(255) NOT NULL PRIMARY KEY,
+    expires_at TIMESTAMP NOT NULL,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    INDEX (expires_at)
+);
+
+-- Notes table, one row per note with the metadata the server needs about it
+-- id is a random 128 bit identifier (hex) generated by the server, so it does not reveal how many notes a user has
+CREATE TABLE notes (
+    id CHAR(32) NOT NULL PRIMARY KEY,
+    user_id INT UNSIGNED NOT NULL,
+    created_at TIMESTAMP NOT NULL, -- timestamp of the first block
+    updated_at TIMESTAMP NOT NULL, -- timestamp of the latest block of content
+    head_hash VARCHAR(255) NOT NULL, -- hash of the head block
+    block_count INT UNSIGNED NOT NULL, -- number of blocks, also the seq of the head block
+    content_seq INT UNSIGNED NOT NULL, -- seq of the latest block of content, the head unless the note was deleted or restored since
+    deleted BOOLEAN NOT NULL DEFAULT FALSE, -- TRUE while the note is inside the trash
+    deleted_at TIMESTAMP NULL, -- when the note was moved to the trash, it is purged once the retention period expires
+    FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE, -- if a user is deleted, their notes are also deleted
+    INDEX (user_id, updated_at, id), -- keyset pagination sorted by last modification
+    INDEX (user_id, created_at, id), -- keyset pagination sorted by creation
+    INDEX (deleted, deleted_at) -- purge of the expired notes
+);
+
+-- Signing keys of the devices of each user, next to the key derived from the password
+-- A device is enrolled by a key the user already trusts, which signs the new key (see crypto.DeviceEnrollmentPayload)
+CREATE TABLE device_keys (
+    id INT UNSIGNED AUTO_INCREMENT PRIMARY KEY,
+    user_id INT UNSIGNED NOT NULL,
+    name VARCHAR(255) NOT NULL,
+    pub_key VARCHAR(255) NOT NULL, -- Base64 Ed25519 public key of the device
+    pq_pub_key TEXT NOT NULL, -- ML-DSA-65 public key of the hybrid signature types, empty otherwise
+    enrolled_by VARCHAR(255) NOT NULL, -- public key that signed the enrollment
+    signature TEXT NOT NULL, -- signature of the enrollment payload
+    created_at TIMESTAMP NOT NULL,
+    revoked_at TIMESTAMP NULL, -- a revoked key can no longer sign blocks nor enroll devices
+    FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE,
+    UNIQUE (user_id, pub_key) -- a revoked key can never be enrolled again
+);
+
+-- Shares of the recovery key of a user, split with Shamir's secret sharing and sealed to trusted contacts
+-- The server cannot open them, any threshold of them rebuilds the recovery key (see client/shamir.go)
+CREATE TABLE recovery_shares (
+    owner_id INT UNSIGNED NOT NULL,
+    contact_id INT UNSIGNED NOT NULL,
+    share_index TINYINT UNSIGNED NOT NULL, -- x coordinate of the share, from 1
+    threshold TINYINT UNSIGNED NOT NULL, -- number of shares needed to rebuild the recovery key
+    contact_key VARCHAR(255) NOT NULL, -- public key of the contact the share is sealed to
+    encrypted_share TEXT NOT NULL,
+    created_at TIMESTAMP NOT NULL,
+    FOREIGN KEY (owner_id) REFERENCES users(id) ON DELETE CASCADE,
+    FOREIGN KEY (contact_id) REFERENCES users(id) ON DELETE CASCADE,
+    PRIMARY KEY (owner_id, contact_id),
+    UNIQUE (owner_id, share_index),
+    INDEX (contact_id)
+);
+
+-- Social recoveries started by someone who lost the password of an account
+-- The contacts submit their shares sealed to the ephemeral key, they are only released after release_at
+CREATE TABLE recovery_requests (
+    id CHAR(32) NOT NULL PRIMARY KEY,
+    user_id INT UNSIGNED NULL, -- NULL for the made up recoveries of the emails without shares
+    email_digest CHAR(64) NULL, -- hex HMAC of the email of a made up recovery, NULL for the real ones
+    ephemeral_key VARCHAR(255) NOT NULL, -- X25519 public key of the requester
+    threshold TINYINT UNSIGNED NOT NULL,
+    status VARCHAR(20) NOT NULL DEFAULT 'pending', -- pending, cancelled or completed
+    created_at TIMESTAMP NOT NULL,
+    release_at TIMESTAMP NOT NULL, -- the owner can cancel the recovery until then
+    expires_at TIMESTAMP NOT NULL,
+    FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE,
+    INDEX (user_id, status),
+    INDEX (email_digest, status)
+);
+
+-- Shares submitted by the contacts for a social recovery, sealed to the ephemeral key of the request
+CREATE TABLE recovery_submissions (
+    request_id CHAR(32) NOT NULL,
+    contact_id INT UNSIGNED NOT NULL,
+    share_index TINYINT UNSIGNED NOT NULL,
+    encrypted_share TEXT NOT NULL,
+    created_at TIMESTAMP NOT NULL,
+    FOREIGN KEY (request_id) REFERENCES recovery_requests(id) ON DELETE CASCADE,
+    FOREIGN KEY (contact_id) REFERENCES users(id) ON DELETE CASCADE,
+    PRIMARY KEY (request_id, contact_id)
+);
+
+-- Blocks table for storing the encrypted blockchain of each note
 CREATE TABLE blocks (
-    note_id INT UNSIGNED NOT NULL,
+    note_id CHAR(32) NOT NULL,
     user_id INT UNSIGNED NOT NULL,
+    seq INT UNSIGNED NOT NULL, -- position of the block in the chain, starting at 1
     prev_hash VARCHAR(255) NOT NULL, -- Cant't be UNIQUE as all blockchains get initial block with prev_hash = 0
     timestamp TIMESTAMP NOT NULL,
     iv VARCHAR(255) NOT NULL,
@@ -44,8 +152,163 @@ CREATE TABLE blocks (
     ciphertext LONGTEXT NOT NULL,
     mac VARCHAR(255) NOT NULL,
     signature TEXT NOT NULL,
-    FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE, -- if a user is deleted, their notes are also deleted
-    PRIMARY KEY (note_id, user_id, prev_hash), -- Composite primary key
+    signer_key TEXT NOT NULL, -- public key the signature was verified with, blocks keep it when the user key changes
+    -- AEAD encryption types: nonces and authentication tags, iv, iv_title and mac stay empty
+    nonce VARCHAR(255) NOT NULL DEFAULT '',
+    nonce_title VARCHAR(255) NOT NULL DEFAULT '',
+    tag VARCHAR(255) NOT NULL DEFAULT '',
+    tag_title VARCHAR(255) NOT NULL DEFAULT '',
+    suite_id SMALLINT UNSIGNED NOT NULL DEFAULT 0, -- crypto suite the block was validated with, 0 if unknown
+    -- hybrid signatures: ML-DSA-65 signature and the ML-DSA key it was verified with, empty for the Ed25519 blocks
+    pq_signature TEXT NOT NULL,
+    pq_signer_key TEXT NOT NULL,
+    device_id INT UNSIGNED NULL, -- device key that signed the block, NULL for the key derived from the password
+    kind VARCHAR(16) NOT NULL DEFAULT '', -- tombstone or restore for the lifecycle blocks, empty for the blocks of content
+    FOREIGN KEY (note_id) REFERENCES notes(id) ON DELETE CASCADE, -- if a note is deleted, its blocks are also deleted
+    PRIMARY KEY (note_id, seq), -- a note can never have two blocks at the same position
+    UNIQUE (note_id, prev_hash),
     INDEX (user_id),
     INDEX (prev_hash)
 );
+
+-- Encrypted folder and tags of each note
+-- folder_token and tag_token are blind indexes (HMAC of the names computed by the client),
+-- the names themselves only exist encrypted inside cipher_meta
+CREATE TABLE note_metadata (
+    note_id CHAR(32) NOT NULL PRIMARY KEY,
+    user_id INT UNSIGNED NOT NULL,
+    cipher_meta TEXT NOT NULL,
+    iv_meta VARCHAR(255) NOT NULL,
+    folder_token VARCHAR(255) NULL, -- NULL when the note is not inside a folder
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP,
+    FOREIGN KEY (note_id) REFERENCES notes(id) ON DELETE CASCADE,
+    INDEX (user_id, folder_token)
+);
+
+CREATE TABLE note_tags (
+    note_id CHAR(32) NOT NULL,
+    user_id INT UNSIGNED NOT NULL,
+    tag_token VARCHAR(255) NOT NULL,
+    FOREIGN KEY (note_id) REFERENCES notes(id) ON DELETE CASCADE,
+    PRIMARY KEY (note_id, tag_token),
+    INDEX (user_id, tag_token)
+);
+
+-- Searchable encryption index
+-- token is a keyed hash of a keyword computed by the client, the server never sees the keyword.
+-- Only the tokens of the current head are kept, block_hash identifies the version they were computed for
+CREATE TABLE search_index (
+    note_id CHAR(32) NOT NULL,
+    user_id INT UNSIGNED NOT NULL,
+    token VARCHAR(255) NOT NULL,
+    block_hash VARCHAR(255) NOT NULL,
+    FOREIGN KEY (note_id) REFERENCES notes(id) ON DELETE CASCADE,
+    PRIMARY KEY (note_id, token),
+    INDEX (user_id, token)
+);
+
+-- Append-only account log, one leaf every time the head of a note changes
+-- the Merkle tree (RFC 6962) over leaf_hash lets a client detect a dropped or rolled back note.
+-- There is no foreign key to notes, the leaves of purged notes stay in the log
+CREATE TABLE account_log (
+    user_id INT UNSIGNED NOT NULL,
+    leaf_index INT UNSIGNED NOT NULL, -- position of the leaf in the log of the user, starting at 0
+    note_id CHAR(32) NOT NULL,
+    event VARCHAR(16) NOT NULL, -- head, trash, restore or purge
+    head_hash VARCHAR(255) NOT NULL,
+    leaf_hash VARCHAR(255) NOT NULL, -- base64 SHA-256 of 0x00 || "event:note_id:head_hash"
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE,
+    PRIMARY KEY (user_id, leaf_index),
+    INDEX (user_id, note_id)
+);
+
+-- Size and root of the account log of every user, updated with each append so the titles pages never read the log
+CREATE TABLE account_log_heads (
+    user_id INT UNSIGNED PRIMARY KEY,
+    tree_size INT UNSIGNED NOT NULL,
+    root_hash VARCHAR(255) NOT NULL, -- base64 Merkle root of the leaves
+    frontier TEXT NOT NULL, -- base64 roots of the largest complete subtrees, comma separated
+    FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE
+);
+
+-- Global transparency log, one entry for every accepted block (certificate transparency style)
+-- entries only hold hashes, and stay in the log after a note is purged
+CREATE TABLE transparency_log (
+    id BIGINT UNSIGNED NOT NULL AUTO_INCREMENT PRIMARY KEY, -- order the entries were appended in
+    leaf_index BIGINT UNSIGNED NULL UNIQUE, -- position in the log, starting at 0, without gaps, NULL until sequenced
+    note_id CHAR(32) NOT NULL,
+    seq INT UNSIGNED NOT NULL,
+    block_hash VARCHAR(255) NOT NULL,
+    leaf_hash VARCHAR(255) NOT NULL, -- base64 SHA-256 of 0x00 || "block:note_id:seq:block_hash"
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    INDEX (block_hash),
+    INDEX (note_id, seq)
+);
+
+-- Single row holding the size of the transparency log, only locked by the sequencing of the pending entries
+CREATE TABLE transparency_log_size (
+    id TINYINT UNSIGNED NOT NULL PRIMARY KEY,
+    size BIGINT UNSIGNED NOT NULL
+);
+
+INSERT INTO transparency_log_size (id, size) VALUES (1, 0);
+
+-- Tree heads of the transparency log signed with the server key
+CREATE TABLE signed_tree_heads (
+    tree_size BIGINT UNSIGNED NOT NULL PRIMARY KEY,
+    root_hash VARCHAR(255) NOT NULL,
+    timestamp TIMESTAMP NOT NULL,
+    key_id VARCHAR(64) NOT NULL,
+    signature TEXT NOT NULL
+);
+
+-- RFC 3161 time-stamp tokens over the hash of the blocks, signed by an independent time-stamp authority
+CREATE TABLE block_timestamps (
+    note_id CHAR(32) NOT NULL,
+    seq INT UNSIGNED NOT NULL,
+    authority VARCHAR(255) NOT NULL, -- "local" for the bundled authority, the URL of a remote one otherwise
+    token BLOB NOT NULL, -- DER encoded time-stamp token
+    gen_time TIMESTAMP NOT NULL, -- time written in the token by the authority
+    FOREIGN KEY (note_id, seq) REFERENCES blocks(note_id, seq) ON DELETE CASCADE,
+    PRIMARY KEY (note_id, seq)
+);
+
+-- Security notification settings of the users, the users without a row get the emails of every event
+CREATE TABLE notification_preferences (
+    user_id INT UNSIGNED NOT NULL PRIMARY KEY,
+    email_enabled BOOLEAN NOT NULL DEFAULT TRUE,
+    webhook_url VARCHAR(2048) NOT NULL DEFAULT '',
+    webhook_secret VARCHAR(64) NOT NULL DEFAULT '', -- hex HMAC-SHA256 key signing the webhook requests
+    muted_events VARCHAR(255) NOT NULL DEFAULT '', -- comma separated event types the user does not want
+    FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE
+);
+
+-- Deliveries of the security notifications, retried with a backoff until they are sent or run out of attempts.
+-- There is no foreign key to users, the notification of a deleted account is still delivered
+CREATE TABLE notification_deliveries (
+    id BIGINT UNSIGNED AUTO_INCREMENT PRIMARY KEY,
+    user_id INT UNSIGNED NOT NULL,
+    event_type VARCHAR(32) NOT NULL,
+    channel VARCHAR(16) NOT NULL, -- email or webhook
+    target VARCHAR(2048) NOT NULL, -- address or URL the notification is sent to
+    secret VARCHAR(64) NOT NULL DEFAULT '', -- webhook secret when the event happened
+    payload TEXT NOT NULL, -- JSON of the event
+    status VARCHAR(16) NOT NULL DEFAULT 'pending', -- pending, sent or failed
+    attempts INT UNSIGNED NOT NULL DEFAULT 0,
+    next_attempt_at TIMESTAMP NOT NULL, -- also pushed back while an attempt is running, so it is not made twice
+    last_error VARCHAR(255) NOT NULL DEFAULT '',
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    INDEX (status, next_attempt_at),
+    INDEX (user_id, created_at)
+);
+
+-- Clients each user logged in from, a login from an unknown one is notified
+CREATE TABLE login_sources (
+    user_id INT UNSIGNED NOT NULL,
+    fingerprint CHAR(64) NOT NULL, -- hex SHA-256 of the IP address and the user agent
+    first_seen TIMESTAMP NOT NULL,
+    last_seen TIMESTAMP NOT NULL,
+    FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE,
+    PRIMARY KEY (user_id, fingerprint)
+);
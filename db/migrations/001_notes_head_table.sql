@@ -0,0 +1,28 @@
+-- Migration 001: notes head table
+-- init.sql already contains these changes, this file only needs to be applied
+-- to databases created before the notes table was introduced.
+
+ALTER TABLE blocks ADD INDEX (user_id, note_id, timestamp);
+
+CREATE TABLE notes (
+    note_id INT UNSIGNED NOT NULL,
+    user_id INT UNSIGNED NOT NULL,
+    head_prev_hash VARCHAR(255) NOT NULL,
+    created_at TIMESTAMP NOT NULL,
+    updated_at TIMESTAMP NOT NULL,
+    FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE,
+    PRIMARY KEY (user_id, note_id),
+    INDEX (user_id, updated_at, note_id),
+    INDEX (user_id, created_at, note_id)
+);
+
+-- Point every existing note to its latest block
+INSERT IGNORE INTO notes (note_id, user_id, head_prev_hash, created_at, updated_at)
+SELECT b.note_id, b.user_id, b.prev_hash, bounds.created_at, bounds.updated_at
+FROM blocks b
+INNER JOIN (
+    SELECT note_id, user_id, MIN(timestamp) AS created_at, MAX(timestamp) AS updated_at
+    FROM blocks
+    GROUP BY note_id, user_id
+) bounds
+ON b.note_id = bounds.note_id AND b.user_id = bounds.user_id AND b.timestamp = bounds.updated_at;
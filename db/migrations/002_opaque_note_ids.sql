@@ -0,0 +1,117 @@
+-- Migration 002: first-class notes table with opaque note IDs
+-- init.sql already contains these changes, this file only needs to be applied
+-- to databases created before migration 002.
+--
+-- The head hash is the hash of the JSON encoding of the head block, which SQL cannot reproduce reliably:
+-- the notes are created with an empty head hash, run `go run ./cmd/headhashes` from the backend right after this
+-- migration, and before migration 004, to compute them with crypto.BlockHash.
+
+-- 1. Give every existing note a random 128 bit ID
+CREATE TABLE note_id_map (
+    user_id INT UNSIGNED NOT NULL,
+    old_note_id INT UNSIGNED NOT NULL,
+    new_note_id CHAR(32) NOT NULL,
+    PRIMARY KEY (user_id, old_note_id)
+);
+
+INSERT INTO note_id_map (user_id, old_note_id, new_note_id)
+SELECT user_id, note_id, LOWER(HEX(RANDOM_BYTES(16)))
+FROM notes;
+
+-- 2. Number the blocks of every chain, in the same order they were returned before (by timestamp)
+ALTER TABLE blocks
+    ADD COLUMN new_note_id CHAR(32) NULL,
+    ADD COLUMN seq INT UNSIGNED NULL;
+
+UPDATE blocks b
+INNER JOIN note_id_map m ON m.user_id = b.user_id AND m.old_note_id = b.note_id
+SET b.new_note_id = m.new_note_id;
+
+CREATE TEMPORARY TABLE block_seq AS
+SELECT note_id, user_id, prev_hash,
+       ROW_NUMBER() OVER (PARTITION BY user_id, note_id ORDER BY timestamp ASC) AS seq
+FROM blocks;
+
+UPDATE blocks b
+INNER JOIN block_seq s ON s.note_id = b.note_id AND s.user_id = b.user_id AND s.prev_hash = b.prev_hash
+SET b.seq = s.seq;
+
+DROP TEMPORARY TABLE block_seq;
+
+-- 3. Replace the notes head table with the first-class notes table
+RENAME TABLE notes TO notes_old;
+
+CREATE TABLE notes (
+    id CHAR(32) NOT NULL PRIMARY KEY,
+    user_id INT UNSIGNED NOT NULL,
+    created_at TIMESTAMP NOT NULL,
+    updated_at TIMESTAMP NOT NULL,
+    head_hash VARCHAR(255) NOT NULL,
+    block_count INT UNSIGNED NOT NULL,
+    deleted BOOLEAN NOT NULL DEFAULT FALSE,
+    FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE,
+    INDEX (user_id, updated_at, id),
+    INDEX (user_id, created_at, id)
+);
+
+INSERT INTO notes (id, user_id, created_at, updated_at, head_hash, block_count)
+SELECT m.new_note_id, o.user_id, o.created_at, o.updated_at, '', counts.block_count
+FROM notes_old o
+INNER JOIN note_id_map m ON m.user_id = o.user_id AND m.old_note_id = o.note_id
+INNER JOIN (
+    SELECT new_note_id, COUNT(*) AS block_count
+    FROM blocks
+    GROUP BY new_note_id
+) counts ON counts.new_note_id = m.new_note_id;
+
+DROP TABLE notes_old;
+
+-- 4. Blocks reference the notes table
+DELETE FROM blocks WHERE new_note_id IS NULL;
+
+ALTER TABLE blocks
+    DROP PRIMARY KEY,
+    DROP COLUMN note_id;
+
+ALTER TABLE blocks
+    CHANGE COLUMN new_note_id note_id CHAR(32) NOT NULL FIRST,
+    MODIFY COLUMN seq INT UNSIGNED NOT NULL AFTER user_id,
+    ADD PRIMARY KEY (note_id, seq),
+    ADD UNIQUE (note_id, prev_hash),
+    ADD FOREIGN KEY (note_id) REFERENCES notes(id) ON DELETE CASCADE;
+
+-- 5. Metadata, tags and search index reference the notes table
+ALTER TABLE note_metadata ADD COLUMN new_note_id CHAR(32) NULL;
+UPDATE note_metadata t
+INNER JOIN note_id_map m ON m.user_id = t.user_id AND m.old_note_id = t.note_id
+SET t.new_note_id = m.new_note_id;
+DELETE FROM note_metadata WHERE new_note_id IS NULL;
+ALTER TABLE note_metadata DROP PRIMARY KEY, DROP COLUMN note_id;
+ALTER TABLE note_metadata
+    CHANGE COLUMN new_note_id note_id CHAR(32) NOT NULL FIRST,
+    ADD PRIMARY KEY (note_id),
+    ADD FOREIGN KEY (note_id) REFERENCES notes(id) ON DELETE CASCADE;
+
+ALTER TABLE note_tags ADD COLUMN new_note_id CHAR(32) NULL;
+UPDATE note_tags t
+INNER JOIN note_id_map m ON m.user_id = t.user_id AND m.old_note_id = t.note_id
+SET t.new_note_id = m.new_note_id;
+DELETE FROM note_tags WHERE new_note_id IS NULL;
+ALTER TABLE note_tags DROP PRIMARY KEY, DROP COLUMN note_id;
+ALTER TABLE note_tags
+    CHANGE COLUMN new_note_id note_id CHAR(32) NOT NULL FIRST,
+    ADD PRIMARY KEY (note_id, tag_token),
+    ADD FOREIGN KEY (note_id) REFERENCES notes(id) ON DELETE CASCADE;
+
+ALTER TABLE search_index ADD COLUMN new_note_id CHAR(32) NULL;
+UPDATE search_index t
+INNER JOIN note_id_map m ON m.user_id = t.user_id AND m.old_note_id = t.note_id
+SET t.new_note_id = m.new_note_id;
+DELETE FROM search_index WHERE new_note_id IS NULL;
+ALTER TABLE search_index DROP PRIMARY KEY, DROP COLUMN note_id;
+ALTER TABLE search_index
+    CHANGE COLUMN new_note_id note_id CHAR(32) NOT NULL FIRST,
+    ADD PRIMARY KEY (note_id, token),
+    ADD FOREIGN KEY (note_id) REFERENCES notes(id) ON DELETE CASCADE;
+
+DROP TABLE note_id_map;
@@ -0,0 +1,21 @@
+-- Migration 003: note trash
+-- init.sql already contains these changes, this file only needs to be applied
+-- to databases created before notes could be moved to the trash.
+
+ALTER TABLE notes
+    ADD COLUMN deleted_at TIMESTAMP NULL AFTER deleted,
+    ADD INDEX (deleted, deleted_at);
+
+-- Notes flagged as deleted before the trash existed start their retention period now
+UPDATE notes SET deleted_at = CURRENT_TIMESTAMP WHERE deleted = TRUE;
+
+CREATE TABLE tombstones (
+    note_id CHAR(32) NOT NULL PRIMARY KEY,
+    user_id INT UNSIGNED NOT NULL,
+    head_hash VARCHAR(255) NOT NULL,
+    timestamp TIMESTAMP NOT NULL,
+    signature TEXT NOT NULL,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    FOREIGN KEY (note_id) REFERENCES notes(id) ON DELETE CASCADE,
+    INDEX (user_id)
+);
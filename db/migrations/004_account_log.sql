@@ -0,0 +1,31 @@
+-- Migration 004: account log
+-- init.sql already contains these changes, this file only needs to be applied
+-- to databases created before the account Merkle tree was introduced.
+-- The leaves are built from the head hashes of the notes, run `go run ./cmd/headhashes` after migration 002 first.
+
+CREATE TABLE account_log (
+    user_id INT UNSIGNED NOT NULL,
+    leaf_index INT UNSIGNED NOT NULL,
+    note_id CHAR(32) NOT NULL,
+    event VARCHAR(16) NOT NULL,
+    head_hash VARCHAR(255) NOT NULL,
+    leaf_hash VARCHAR(255) NOT NULL,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE,
+    PRIMARY KEY (user_id, leaf_index),
+    INDEX (user_id, note_id)
+);
+
+-- Start the log of every account with the current head of each of its notes,
+-- followed by a trash leaf for the notes already inside the trash.
+-- The leaf hash mirrors crypto.AccountLeafHash: SHA-256 of 0x00 || "event:note_id:head_hash"
+INSERT INTO account_log (user_id, leaf_index, note_id, event, head_hash, leaf_hash)
+SELECT user_id,
+       ROW_NUMBER() OVER (PARTITION BY user_id ORDER BY rank_order, created_at, id) - 1,
+       id, event, head_hash,
+       TO_BASE64(UNHEX(SHA2(CONCAT(X'00', CONVERT(CONCAT(event, ':', id, ':', head_hash) USING utf8mb4)), 256)))
+FROM (
+    SELECT user_id, id, head_hash, created_at, 'head' AS event, 0 AS rank_order FROM notes
+    UNION ALL
+    SELECT user_id, id, head_hash, deleted_at AS created_at, 'trash' AS event, 1 AS rank_order FROM notes WHERE deleted = TRUE
+) entries;
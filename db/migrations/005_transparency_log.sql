@@ -0,0 +1,53 @@
+-- Migration 005: transparency log
+-- init.sql already contains these changes, this file only needs to be applied
+-- to databases created before the transparency log was introduced.
+-- The hash of every block is recomputed here with the same JSON encoding as crypto.BlockHash,
+-- run it with the same time zone as the application connections (UTC by default).
+
+CREATE TABLE transparency_log (
+    leaf_index BIGINT UNSIGNED NOT NULL PRIMARY KEY,
+    note_id CHAR(32) NOT NULL,
+    seq INT UNSIGNED NOT NULL,
+    block_hash VARCHAR(255) NOT NULL,
+    leaf_hash VARCHAR(255) NOT NULL,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    INDEX (block_hash),
+    INDEX (note_id, seq)
+);
+
+CREATE TABLE transparency_log_size (
+    id TINYINT UNSIGNED NOT NULL PRIMARY KEY,
+    size BIGINT UNSIGNED NOT NULL
+);
+
+CREATE TABLE signed_tree_heads (
+    tree_size BIGINT UNSIGNED NOT NULL PRIMARY KEY,
+    root_hash VARCHAR(255) NOT NULL,
+    timestamp TIMESTAMP NOT NULL,
+    key_id VARCHAR(64) NOT NULL,
+    signature TEXT NOT NULL
+);
+
+-- Commit every existing block in the order it was accepted
+-- The leaf hash mirrors crypto.TransparencyLeafHash: SHA-256 of 0x00 || "block:note_id:seq:block_hash"
+INSERT INTO transparency_log (leaf_index, note_id, seq, block_hash, leaf_hash)
+SELECT ROW_NUMBER() OVER (ORDER BY hashed.timestamp, hashed.note_id, hashed.seq) - 1,
+       hashed.note_id, hashed.seq, hashed.block_hash,
+       TO_BASE64(UNHEX(SHA2(CONCAT(X'00', CONVERT(CONCAT('block:', hashed.note_id, ':', hashed.seq, ':', hashed.block_hash) USING utf8mb4)), 256)))
+FROM (
+    SELECT b.note_id, b.seq, b.timestamp,
+           TO_BASE64(UNHEX(SHA2(CONCAT(
+               '{"prev_hash":"', b.prev_hash,
+               '","iv":"', b.iv,
+               '","iv_title":"', b.iv_title,
+               '","cipher_title":"', b.cipher_title,
+               '","ciphertext":"', b.ciphertext,
+               '","mac":"', b.mac,
+               '","signature":"', b.signature,
+               '","timestamp":"', DATE_FORMAT(b.timestamp, '%Y-%m-%dT%H:%i:%sZ'),
+               '"}'), 256))) AS block_hash
+    FROM blocks b
+) hashed;
+
+INSERT INTO transparency_log_size (id, size)
+SELECT 1, COUNT(*) FROM transparency_log;
@@ -0,0 +1,14 @@
+-- Migration 006: block timestamps
+-- init.sql already contains these changes, this file only needs to be applied
+-- to databases created before blocks were timestamped.
+-- Blocks accepted before this migration have no time-stamp token.
+
+CREATE TABLE block_timestamps (
+    note_id CHAR(32) NOT NULL,
+    seq INT UNSIGNED NOT NULL,
+    authority VARCHAR(255) NOT NULL,
+    token BLOB NOT NULL,
+    gen_time TIMESTAMP NOT NULL,
+    FOREIGN KEY (note_id, seq) REFERENCES blocks(note_id, seq) ON DELETE CASCADE,
+    PRIMARY KEY (note_id, seq)
+);
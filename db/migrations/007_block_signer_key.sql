@@ -0,0 +1,12 @@
+-- Migration 007: block signer key
+-- init.sql already contains these changes, this file only needs to be applied
+-- to databases created before vaults could be imported.
+-- The key that signed the blocks stored before is unknown, the current key of their owner is the best guess.
+
+ALTER TABLE blocks ADD COLUMN signer_key TEXT NULL AFTER signature;
+
+UPDATE blocks b
+INNER JOIN users u ON u.id = b.user_id
+SET b.signer_key = u.pub_key;
+
+ALTER TABLE blocks MODIFY signer_key TEXT NOT NULL;
@@ -0,0 +1,10 @@
+-- Migration 008: AEAD blocks
+-- init.sql already contains these changes, this file only needs to be applied
+-- to databases created before the AEAD encryption types were supported.
+-- The blocks stored before are AES-128 blocks, their AEAD fields stay empty.
+
+ALTER TABLE blocks
+    ADD COLUMN nonce VARCHAR(255) NOT NULL DEFAULT '' AFTER signer_key,
+    ADD COLUMN nonce_title VARCHAR(255) NOT NULL DEFAULT '' AFTER nonce,
+    ADD COLUMN tag VARCHAR(255) NOT NULL DEFAULT '' AFTER nonce_title,
+    ADD COLUMN tag_title VARCHAR(255) NOT NULL DEFAULT '' AFTER tag;
@@ -0,0 +1,27 @@
+-- Migration 009: crypto suites
+-- init.sql already contains these changes, this file only needs to be applied
+-- to databases created before the crypto suite registry.
+-- The IDs match the registry in backend/crypto/suites.go, users and blocks get the suite of their
+-- encryption and HMAC types.
+
+ALTER TABLE users
+    ADD COLUMN suite_id SMALLINT UNSIGNED NOT NULL DEFAULT 0 AFTER encryption_type;
+
+UPDATE users SET suite_id = CASE CONCAT(encryption_type, '+', hmac_type)
+    WHEN 'aes-128-cbc+hmac-sha256' THEN 1
+    WHEN 'aes-128-cbc+hmac-sha512' THEN 2
+    WHEN 'aes-128-ctr+hmac-sha256' THEN 3
+    WHEN 'aes-128-ctr+hmac-sha512' THEN 4
+    WHEN 'aes-256-gcm+hmac-sha256' THEN 5
+    WHEN 'aes-256-gcm+hmac-sha512' THEN 6
+    WHEN 'xchacha20-poly1305+hmac-sha256' THEN 7
+    WHEN 'xchacha20-poly1305+hmac-sha512' THEN 8
+    ELSE 0
+END;
+
+ALTER TABLE blocks
+    ADD COLUMN suite_id SMALLINT UNSIGNED NOT NULL DEFAULT 0 AFTER tag_title;
+
+UPDATE blocks b
+    INNER JOIN users u ON u.id = b.user_id
+    SET b.suite_id = u.suite_id;
@@ -0,0 +1,10 @@
+-- Migration 010: login KDF parameters
+-- init.sql already contains these changes, this file only needs to be applied
+-- to databases created before the login KDF parameters were stored.
+-- Every existing account derived its login key with 100k iterations of PBKDF2-SHA256, the defaults.
+
+ALTER TABLE users
+    ADD COLUMN kdf_algorithm VARCHAR(30) NOT NULL DEFAULT 'pbkdf2-sha256' AFTER suite_id,
+    ADD COLUMN kdf_iterations INT UNSIGNED NOT NULL DEFAULT 100000 AFTER kdf_algorithm,
+    ADD COLUMN kdf_memory_kib INT UNSIGNED NOT NULL DEFAULT 0 AFTER kdf_iterations,
+    ADD COLUMN kdf_parallelism TINYINT UNSIGNED NOT NULL DEFAULT 0 AFTER kdf_memory_kib;
@@ -0,0 +1,20 @@
+-- Migration 011: hybrid signatures
+-- init.sql already contains these changes, this file only needs to be applied
+-- to databases created before the blocks could carry an ML-DSA signature.
+-- The existing accounts and blocks use Ed25519 alone, their ML-DSA key and signature stay empty.
+
+ALTER TABLE users
+    ADD COLUMN signature_type VARCHAR(30) NOT NULL DEFAULT 'ed25519' AFTER kdf_parallelism,
+    ADD COLUMN pq_pub_key TEXT NULL AFTER signature_type;
+
+ALTER TABLE blocks
+    ADD COLUMN pq_signature TEXT NULL AFTER suite_id,
+    ADD COLUMN pq_signer_key TEXT NULL AFTER pq_signature;
+
+UPDATE users SET pq_pub_key = '';
+UPDATE blocks SET pq_signature = '', pq_signer_key = '';
+
+ALTER TABLE users MODIFY pq_pub_key TEXT NOT NULL;
+ALTER TABLE blocks
+    MODIFY pq_signature TEXT NOT NULL,
+    MODIFY pq_signer_key TEXT NOT NULL;
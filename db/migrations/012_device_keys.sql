@@ -0,0 +1,21 @@
+-- Migration 012: device keys
+-- init.sql already contains these changes, this file only needs to be applied
+-- to databases created before the users could enroll several device keys.
+-- The existing blocks were signed with the key derived from the password, their device stays NULL.
+
+CREATE TABLE device_keys (
+    id INT UNSIGNED AUTO_INCREMENT PRIMARY KEY,
+    user_id INT UNSIGNED NOT NULL,
+    name VARCHAR(255) NOT NULL,
+    pub_key VARCHAR(255) NOT NULL,
+    pq_pub_key TEXT NOT NULL,
+    enrolled_by VARCHAR(255) NOT NULL,
+    signature TEXT NOT NULL,
+    created_at TIMESTAMP NOT NULL,
+    revoked_at TIMESTAMP NULL,
+    FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE,
+    UNIQUE (user_id, pub_key)
+);
+
+ALTER TABLE blocks
+    ADD COLUMN device_id INT UNSIGNED NULL AFTER pq_signer_key;
@@ -0,0 +1,15 @@
+-- Migration 013: account recovery
+-- init.sql already contains these changes, this file only needs to be applied
+-- to databases created before the users could set up an offline recovery key.
+-- The existing accounts have no recovery key and derive their keys from the password.
+
+ALTER TABLE users
+    ADD COLUMN recovery_pub_key VARCHAR(255) NOT NULL DEFAULT '' AFTER pq_pub_key,
+    ADD COLUMN recovery_escrow TEXT NULL AFTER recovery_pub_key,
+    ADD COLUMN wrapped_keys TEXT NULL AFTER recovery_escrow;
+
+UPDATE users SET recovery_escrow = '', wrapped_keys = '';
+
+ALTER TABLE users
+    MODIFY recovery_escrow TEXT NOT NULL,
+    MODIFY wrapped_keys TEXT NOT NULL;
@@ -0,0 +1,42 @@
+-- Migration 014: social recovery
+-- init.sql already contains these changes, this file only needs to be applied
+-- to databases created before the recovery key could be split between trusted contacts.
+
+CREATE TABLE recovery_shares (
+    owner_id INT UNSIGNED NOT NULL,
+    contact_id INT UNSIGNED NOT NULL,
+    share_index TINYINT UNSIGNED NOT NULL,
+    threshold TINYINT UNSIGNED NOT NULL,
+    contact_key VARCHAR(255) NOT NULL,
+    encrypted_share TEXT NOT NULL,
+    created_at TIMESTAMP NOT NULL,
+    FOREIGN KEY (owner_id) REFERENCES users(id) ON DELETE CASCADE,
+    FOREIGN KEY (contact_id) REFERENCES users(id) ON DELETE CASCADE,
+    PRIMARY KEY (owner_id, contact_id),
+    UNIQUE (owner_id, share_index),
+    INDEX (contact_id)
+);
+
+CREATE TABLE recovery_requests (
+    id CHAR(32) NOT NULL PRIMARY KEY,
+    user_id INT UNSIGNED NOT NULL,
+    ephemeral_key VARCHAR(255) NOT NULL,
+    threshold TINYINT UNSIGNED NOT NULL,
+    status VARCHAR(20) NOT NULL DEFAULT 'pending',
+    created_at TIMESTAMP NOT NULL,
+    release_at TIMESTAMP NOT NULL,
+    expires_at TIMESTAMP NOT NULL,
+    FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE,
+    INDEX (user_id, status)
+);
+
+CREATE TABLE recovery_submissions (
+    request_id CHAR(32) NOT NULL,
+    contact_id INT UNSIGNED NOT NULL,
+    share_index TINYINT UNSIGNED NOT NULL,
+    encrypted_share TEXT NOT NULL,
+    created_at TIMESTAMP NOT NULL,
+    FOREIGN KEY (request_id) REFERENCES recovery_requests(id) ON DELETE CASCADE,
+    FOREIGN KEY (contact_id) REFERENCES users(id) ON DELETE CASCADE,
+    PRIMARY KEY (request_id, contact_id)
+);
@@ -0,0 +1,10 @@
+-- Migration 015: registration challenges
+-- init.sql already contains these changes, this file only needs to be applied
+-- to databases created before the registrations had to sign a challenge.
+
+CREATE TABLE registration_challenges (
+    challenge_value VARCHAR(255) NOT NULL PRIMARY KEY,
+    expires_at TIMESTAMP NOT NULL,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    INDEX (expires_at)
+);
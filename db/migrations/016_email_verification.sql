@@ -0,0 +1,15 @@
+-- Migration 016: email verification
+-- init.sql already contains these changes, this file only needs to be applied
+-- to databases created before the email addresses were verified.
+-- The existing accounts registered before the verification existed, they are marked verified so they are not
+-- locked out.
+
+ALTER TABLE users
+    ADD COLUMN email_verified BOOLEAN NOT NULL DEFAULT FALSE AFTER wrapped_keys,
+    ADD COLUMN pending_email VARCHAR(255) NOT NULL DEFAULT '' AFTER email_verified,
+    ADD COLUMN email_change_nonce VARCHAR(64) NOT NULL DEFAULT '' AFTER pending_email,
+    ADD COLUMN email_change_old_confirmed BOOLEAN NOT NULL DEFAULT FALSE AFTER email_change_nonce,
+    ADD COLUMN email_change_new_confirmed BOOLEAN NOT NULL DEFAULT FALSE AFTER email_change_old_confirmed,
+    ADD COLUMN email_change_expires_at TIMESTAMP NULL AFTER email_change_new_confirmed;
+
+UPDATE users SET email_verified = TRUE;
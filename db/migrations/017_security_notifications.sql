@@ -0,0 +1,42 @@
+-- Migration 017: security notifications
+-- init.sql already contains these changes, this file only needs to be applied
+-- to databases created before the security notifications.
+
+-- Security notification settings of the users, the users without a row get the emails of every event
+CREATE TABLE notification_preferences (
+    user_id INT UNSIGNED NOT NULL PRIMARY KEY,
+    email_enabled BOOLEAN NOT NULL DEFAULT TRUE,
+    webhook_url VARCHAR(2048) NOT NULL DEFAULT '',
+    webhook_secret VARCHAR(64) NOT NULL DEFAULT '', -- hex HMAC-SHA256 key signing the webhook requests
+    muted_events VARCHAR(255) NOT NULL DEFAULT '', -- comma separated event types the user does not want
+    FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE
+);
+
+-- Deliveries of the security notifications, retried with a backoff until they are sent or run out of attempts.
+-- There is no foreign key to users, the notification of a deleted account is still delivered
+CREATE TABLE notification_deliveries (
+    id BIGINT UNSIGNED AUTO_INCREMENT PRIMARY KEY,
+    user_id INT UNSIGNED NOT NULL,
+    event_type VARCHAR(32) NOT NULL,
+    channel VARCHAR(16) NOT NULL, -- email or webhook
+    target VARCHAR(2048) NOT NULL, -- address or URL the notification is sent to
+    secret VARCHAR(64) NOT NULL DEFAULT '', -- webhook secret when the event happened
+    payload TEXT NOT NULL, -- JSON of the event
+    status VARCHAR(16) NOT NULL DEFAULT 'pending', -- pending, sent or failed
+    attempts INT UNSIGNED NOT NULL DEFAULT 0,
+    next_attempt_at TIMESTAMP NOT NULL, -- also pushed back while an attempt is running, so it is not made twice
+    last_error VARCHAR(255) NOT NULL DEFAULT '',
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    INDEX (status, next_attempt_at),
+    INDEX (user_id, created_at)
+);
+
+-- Clients each user logged in from, a login from an unknown one is notified
+CREATE TABLE login_sources (
+    user_id INT UNSIGNED NOT NULL,
+    fingerprint CHAR(64) NOT NULL, -- hex SHA-256 of the IP address and the user agent
+    first_seen TIMESTAMP NOT NULL,
+    last_seen TIMESTAMP NOT NULL,
+    FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE,
+    PRIMARY KEY (user_id, fingerprint)
+);
@@ -0,0 +1,16 @@
+-- Migration 018: tombstones and restores as blocks of the note chains
+-- init.sql already contains these changes, this file only needs to be applied
+-- to databases created before the deletions and restores were signed blocks of the chains.
+
+ALTER TABLE blocks
+    ADD COLUMN kind VARCHAR(16) NOT NULL DEFAULT '' AFTER device_id;
+
+ALTER TABLE notes
+    ADD COLUMN content_seq INT UNSIGNED NOT NULL DEFAULT 0 AFTER block_count;
+
+-- Every block stored so far is a block of content
+UPDATE notes SET content_seq = block_count;
+
+-- The tombstones table is kept: a tombstone block is hashed like every block, which SQL cannot do reliably.
+-- Run `go run ./cmd/tombstones` from the backend to append the stored tombstones to the chains of their notes,
+-- it lists the tombstones it skips, then drop the table with `DROP TABLE tombstones;` once they are reviewed.
@@ -0,0 +1,11 @@
+-- Migration 019: sequence the transparency log in the background
+-- init.sql already contains these changes, this file only needs to be applied
+-- to databases created before the entries of the transparency log were sequenced by the tree head job.
+-- The blocks append pending entries without locking the log, the job gives them their index.
+
+-- The table is rebuilt in the order of its old primary key, so the existing entries are numbered in index order
+ALTER TABLE transparency_log
+    DROP PRIMARY KEY,
+    ADD COLUMN id BIGINT UNSIGNED NOT NULL AUTO_INCREMENT PRIMARY KEY FIRST,
+    MODIFY leaf_index BIGINT UNSIGNED NULL,
+    ADD UNIQUE (leaf_index);
@@ -0,0 +1,12 @@
+-- Migration 020: store the size and root of the account logs
+-- init.sql already contains these changes, this file only needs to be applied
+-- to databases created before the tree heads of the account logs were stored.
+-- The existing logs get their row the first time they are read or appended to.
+
+CREATE TABLE account_log_heads (
+    user_id INT UNSIGNED PRIMARY KEY,
+    tree_size INT UNSIGNED NOT NULL,
+    root_hash VARCHAR(255) NOT NULL, -- base64 Merkle root of the leaves
+    frontier TEXT NOT NULL, -- base64 roots of the largest complete subtrees, comma separated
+    FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE
+);
@@ -0,0 +1,8 @@
+-- Migration 021: store the made up social recoveries of the emails without shares
+-- init.sql already contains these changes, this file only needs to be applied
+-- to databases created before the recoveries of the unknown emails were stored like the real ones.
+
+ALTER TABLE recovery_requests
+    MODIFY user_id INT UNSIGNED NULL, -- NULL for the made up recoveries of the emails without shares
+    ADD COLUMN email_digest CHAR(64) NULL AFTER user_id, -- hex HMAC of the email of a made up recovery
+    ADD INDEX (email_digest, status);
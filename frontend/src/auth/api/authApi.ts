@@ -3,9 +3,19 @@ import api from '@/lib/api';
 import type { User } from '@/models/user';
 import type {
   RegistrationPayload,
+  RegistrationChallengeResponse,
   ChallengeResponse,
   LoginRequestPayload,
+  LoginResponse,
+  KDFUpgradePayload,
+  ConfirmEmailResponse,
 } from '@/models/auth';
+import type { SuitesResponse } from '@/models/suite';
+import type {
+  NotificationPreferences,
+  NotificationPreferencesResponse,
+  UpdateNotificationPreferencesPayload,
+} from '@/models/notification';
 
 // sends a registration request to the backend
 export async function sendRegistrationData(payload: RegistrationPayload): Promise<any> {
@@ -18,6 +28,28 @@ export async function sendRegistrationData(payload: RegistrationPayload): Promis
   }
 }
 
+// requests the challenge a new account signs to prove it holds its keys
+export async function requestRegistrationChallenge(): Promise<RegistrationChallengeResponse> {
+  try {
+    const res = await api.post('/auth/register/challenge');
+    return res.data as RegistrationChallengeResponse;
+  } catch (error: any) {
+    const errorMessage = error.response?.data || error.message || 'Failed to get the registration challenge';
+    throw new Error(errorMessage);
+  }
+}
+
+// fetches the crypto suites the server supports, to offer only the ones a new account may choose
+export async function fetchSuites(): Promise<SuitesResponse> {
+  try {
+    const res = await api.get('/crypto/suites');
+    return res.data as SuitesResponse;
+  } catch (error: any) {
+    const errorMessage = error.response?.data || error.message || 'Failed to get the crypto suites';
+    throw new Error(errorMessage);
+  }
+}
+
 // sends the user's email to the backend to request a login challenge and login salt
 export async function requestLoginChallenge(email: string): Promise<ChallengeResponse> {
   try {
@@ -30,16 +62,27 @@ export async function requestLoginChallenge(email: string): Promise<ChallengeRes
 }
 
 // sends the signed challenge to the backend for login
-export async function sendLoginSignature(payload: LoginRequestPayload): Promise<User> {
+export async function sendLoginSignature(payload: LoginRequestPayload): Promise<LoginResponse> {
   try {
     const res = await api.post('/auth/login', payload);
-    return res.data.user as User;
+    return res.data as LoginResponse;
   } catch (error: any) {
     const errorMessage = error.response?.data || error.message || 'Login failed';
     throw new Error(errorMessage);
   }
 }
 
+// sends the signed upgrade of the login key derivation, returns the updated user
+export async function sendKDFUpgrade(payload: KDFUpgradePayload): Promise<User> {
+  try {
+    const res = await api.post('/auth/kdf', payload);
+    return res.data.user as User;
+  } catch (error: any) {
+    const errorMessage = error.response?.data || error.message || 'Failed to upgrade the login key';
+    throw new Error(errorMessage);
+  }
+}
+
 // sends a logout request to the backend to clear the HTTP-only cookie
 export async function logout(): Promise<void> {
   try {
@@ -59,3 +102,56 @@ export async function deleteUser(): Promise<void> {
     throw new Error(errorMessage);
   }
 }
+
+// sends the token of a verification or email change link, no session is needed
+export async function confirmEmail(token: string): Promise<ConfirmEmailResponse> {
+  try {
+    const res = await api.post('/auth/email/confirm', { token });
+    return res.data as ConfirmEmailResponse;
+  } catch (error: any) {
+    const errorMessage = error.response?.data?.error || error.message || 'Failed to confirm the email';
+    throw new Error(errorMessage);
+  }
+}
+
+// asks for a new verification link, the answer is the same whether the account exists or not
+export async function resendVerification(email: string): Promise<void> {
+  try {
+    await api.post('/auth/email/resend', { email });
+  } catch (error: any) {
+    const errorMessage = error.response?.data?.error || error.message || 'Failed to send the verification email';
+    throw new Error(errorMessage);
+  }
+}
+
+// drops the pending email change of the user, the links already sent stop working
+export async function cancelEmailChange(): Promise<void> {
+  try {
+    await api.post('/auth/email/change/cancel');
+  } catch (error: any) {
+    const errorMessage = error.response?.data?.error || error.message || 'Failed to cancel the email change';
+    throw new Error(errorMessage);
+  }
+}
+
+// fetches the security notification preferences of the user, the event types and the latest deliveries
+export async function fetchNotificationPreferences(): Promise<NotificationPreferencesResponse> {
+  try {
+    const res = await api.get('/auth/notifications');
+    return res.data as NotificationPreferencesResponse;
+  } catch (error: any) {
+    const errorMessage = error.response?.data?.error || error.message || 'Failed to get the notification preferences';
+    throw new Error(errorMessage);
+  }
+}
+
+// replaces the security notification preferences, a new webhook secret is returned once when generated
+export async function updateNotificationPreferences(payload: UpdateNotificationPreferencesPayload): Promise<NotificationPreferences> {
+  try {
+    const res = await api.put('/auth/notifications/update', payload);
+    return res.data.preferences as NotificationPreferences;
+  } catch (error: any) {
+    const errorMessage = error.response?.data?.error || error.message || 'Failed to update the notification preferences';
+    throw new Error(errorMessage);
+  }
+}
@@ -1,23 +1,64 @@
 import { pbkdf2Async } from '@noble/hashes/pbkdf2';
+import { argon2idAsync } from '@noble/hashes/argon2';
 import { sha256, sha512 } from '@noble/hashes/sha2';
+import { hkdf } from '@noble/hashes/hkdf';
+import { gcm } from '@noble/ciphers/aes.js';
+import { ml_dsa65 } from '@noble/post-quantum/ml-dsa';
 import { toByteArray as fromBase64 } from 'base64-js';
-import type { User } from '@/models/user';
+import type { KDFParams, User } from '@/models/user';
+import type { SignatureType } from '@/models/block';
+import type { CipherType } from '@/models/block';
+import { isAEAD } from '../../notes/crypto/encryption';
 
-// derives a 32-byte Ed25519 private key from a password and base64-encoded salt
-export async function derivePrivateKey(password: string, saltBase64: string): Promise<Uint8Array> {
+// the login key derivation of the accounts created before the server stored the parameters
+export const legacyKDF: KDFParams = { algorithm: 'pbkdf2-sha256', iterations: 100_000 };
+
+// the login key derivation of the new accounts, the same as RecommendedKDF in the backend
+export const recommendedKDF: KDFParams = { algorithm: 'argon2id', iterations: 2, memory_kib: 19 * 1024, parallelism: 1 };
+
+// derives a 32-byte Ed25519 private key from a password and base64-encoded salt,
+// with the KDF parameters the server stores for the user
+export async function derivePrivateKey(
+  password: string,
+  saltBase64: string,
+  kdf: KDFParams = legacyKDF
+): Promise<Uint8Array> {
   const salt = fromBase64(saltBase64);
   const passwordBytes = new TextEncoder().encode(password);
-  return await pbkdf2Async(sha256, passwordBytes, salt, {c: 100_000, dkLen: 32,});
+  if (kdf.algorithm === 'argon2id') {
+    return await argon2idAsync(passwordBytes, salt, {
+      t: kdf.iterations,
+      m: kdf.memory_kib ?? 0,
+      p: kdf.parallelism ?? 1,
+      dkLen: 32,
+    });
+  }
+  return await pbkdf2Async(sha256, passwordBytes, salt, {c: kdf.iterations, dkLen: 32,});
 }
 
-// derives a 128-bit (16-byte) AES encryption key from password and base64-encoded salt
+// tells whether the blocks of a signature type are also signed with ML-DSA
+export function isHybridSignature(signatureType?: SignatureType): boolean {
+  return signatureType === 'ed25519-mldsa65';
+}
+
+// derives the ML-DSA-65 key pair of a hybrid account from its Ed25519 private key,
+// the seed goes through HKDF-SHA256 like DerivePQSigningKey in the backend client
+export function derivePQKeyPair(privateKey: Uint8Array): { publicKey: Uint8Array; secretKey: Uint8Array } {
+  const seed = hkdf(sha256, privateKey, undefined, 'ml-dsa-65', 32);
+  return ml_dsa65.keygen(seed);
+}
+
+// derives an encryption key from password and base64-encoded salt:
+// 128-bit (16-byte) for the AES-128 modes, 256-bit (32-byte) for the AEAD modes
 export async function deriveEncryptionKey(
   password: string,
-  encryptionSaltBase64: string
+  encryptionSaltBase64: string,
+  encryptionType: CipherType
 ): Promise<Uint8Array> {
   const salt = fromBase64(encryptionSaltBase64);
   const passwordBytes = new TextEncoder().encode(password);
-  return await pbkdf2Async(sha256, passwordBytes, salt, { c: 100_000, dkLen: 16 }); 
+  const dkLen = isAEAD(encryptionType) ? 32 : 16;
+  return await pbkdf2Async(sha256, passwordBytes, salt, { c: 100_000, dkLen }); 
 }
 
 // derives an HMAC key (32 or 64 bytes depending on hash function) from password and salt
@@ -34,7 +75,34 @@ export async function deriveHMACKey(
   return await pbkdf2Async(hashFn, passwordBytes, salt, { c: 100_000, dkLen });
 }
 
-// derives both encryption and HMAC keys from the user's password and stored salts
+// opens the encryption and HMAC keys of a recovered account, which can no longer be derived from its new password.
+// they are sealed like sealKeys in the backend client: base64(nonce || AES-256-GCM ciphertext || tag), under a key
+// derived from the password and the encryption salt, the encryption key first
+async function unwrapKeys(
+  password: string,
+  user: User
+): Promise<{
+  encryptionKey: Uint8Array;
+  hmacKey: Uint8Array;
+}> {
+  const secret = await pbkdf2Async(sha256, new TextEncoder().encode(password), fromBase64(user.encryption_salt), { c: 100_000, dkLen: 32 });
+  const wrappingKey = hkdf(sha256, secret, undefined, 'wrapped-keys', 32);
+  const envelope = fromBase64(user.wrapped_keys ?? '');
+  const keys = gcm(wrappingKey, envelope.subarray(0, 12), new TextEncoder().encode('wrapped-keys')).decrypt(envelope.subarray(12));
+  const encryptionKeySize = isAEAD(user.encryption_type) ? 32 : 16;
+  return { encryptionKey: keys.slice(0, encryptionKeySize), hmacKey: keys.slice(encryptionKeySize) };
+}
+
+// returns the encryption key of the user, derived from the password or opened from the wrapped keys
+export async function getEncryptionKey(password: string, user: User): Promise<Uint8Array> {
+  if (user.wrapped_keys) {
+    return (await unwrapKeys(password, user)).encryptionKey;
+  }
+  return await deriveEncryptionKey(password, user.encryption_salt, user.encryption_type);
+}
+
+// derives both encryption and HMAC keys from the user's password and stored salts,
+// a recovered account opens them from its wrapped keys instead
 export async function getSessionKeys(
   password: string,
   user: User
@@ -42,7 +110,10 @@ export async function getSessionKeys(
   encryptionKey: Uint8Array;
   hmacKey: Uint8Array;
 }> {
-  const encryptionKey = await deriveEncryptionKey(password, user.encryption_salt);
+  if (user.wrapped_keys) {
+    return await unwrapKeys(password, user);
+  }
+  const encryptionKey = await deriveEncryptionKey(password, user.encryption_salt, user.encryption_type);
   const hmacKey = await deriveHMACKey(password, user.hmac_salt, user.hmac_type);
   return { encryptionKey, hmacKey };
 }
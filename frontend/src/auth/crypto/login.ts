@@ -2,20 +2,22 @@ import * as ed from '@noble/ed25519';
 import { toByteArray as fromBase64 } from 'base64-js';
 import { fromByteArray as toBase64 } from 'base64-js';
 import { derivePrivateKey } from './keyDerivation';
+import type { KDFParams } from '@/models/user';
 
 // signs the challenge using the Ed25519 private key derived from password + salt
 export async function signLoginChallenge(
   email: string,
   password: string,
   challengeBase64: string,
-  loginSaltBase64: string
+  loginSaltBase64: string,
+  kdf?: KDFParams
 ): Promise<{
   email: string;
   challenge: string;
   signature: string;
 }> {
   // derive the Ed25519 private key deterministically from the user's password and login salt
-  const privateKey = await derivePrivateKey(password, loginSaltBase64);
+  const privateKey = await derivePrivateKey(password, loginSaltBase64, kdf);
 
   // decode the base64-encoded challenge into a byte array
   const challengeBytes = fromBase64(challengeBase64);
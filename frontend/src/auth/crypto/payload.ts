@@ -0,0 +1,13 @@
+import type { KDFParams } from '@/models/user';
+
+// builds a signed payload the same way as lengthPrefixed in the backend: a prefix naming what is signed,
+// then every field as ':' length ':' field, the length in bytes, so a field cannot run into the next one
+export function lengthPrefixed(prefix: string, fields: string[]): Uint8Array {
+  const encoder = new TextEncoder();
+  return encoder.encode(prefix + fields.map((field) => `:${encoder.encode(field).length}:${field}`).join(''));
+}
+
+// formats KDF parameters as a field of a signed payload
+export function kdfField(kdf: KDFParams): string {
+  return `${kdf.algorithm}:${kdf.iterations}:${kdf.memory_kib ?? 0}:${kdf.parallelism ?? 0}`;
+}
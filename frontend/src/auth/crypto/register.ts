@@ -1,35 +1,56 @@
 import * as ed from '@noble/ed25519';
+import { ml_dsa65 } from '@noble/post-quantum/ml-dsa';
 import { randomBytes } from '@noble/hashes/utils';
 import { fromByteArray as toBase64 } from 'base64-js';
-import { derivePrivateKey } from './keyDerivation';
+import { derivePrivateKey, derivePQKeyPair, isHybridSignature, recommendedKDF } from './keyDerivation';
+import { kdfField, lengthPrefixed } from './payload';
 import type { RegistrationPayload } from '@/models/auth';
+import type { CipherType, SignatureType } from '@/models/block';
 
 // generate a random 32-byte salt
 function generateSalt(): Uint8Array {
     return randomBytes(32);
 }
 
-// generates a deterministic Ed25519 key pair and returns the full registration payload
+// builds the bytes signed by a new account, the same string as RegistrationPayload in the backend:
+// the challenge, then every field prefixed with its length in bytes so a field cannot run into the next one
+function registrationMessage(challenge: string, payload: RegistrationPayload): Uint8Array {
+  const kdf = payload.kdf ? kdfField(payload.kdf) : '';
+  return lengthPrefixed('register' + challenge, [
+    payload.email, payload.name, payload.login_salt, payload.encryption_salt, payload.hmac_salt,
+    payload.public_key, payload.hmac_type, payload.encryption_type, '', kdf, payload.signature_type ?? '',
+    payload.pq_public_key ?? '', '', '',
+  ]);
+}
+
+// generates a deterministic Ed25519 key pair and returns the full registration payload,
+// signed along with the registration challenge to prove the keys are ours
 export async function registerUser(
+  challenge: string,
   name: string,
   email: string,
   password: string,
   hmacType: "hmac-sha256" | "hmac-sha512",
-  encryptionType: "aes-128-cbc" | "aes-128-ctr"
+  encryptionType: CipherType,
+  signatureType: SignatureType = 'ed25519'
 ): Promise<RegistrationPayload> {
   // generate all salts
   const loginSalt = generateSalt();
   const encryptionSalt = generateSalt();
   const hmacSalt = generateSalt();
 
-  // derive the Ed25519 private key from password + loginSalt (PBKDF2)
-  const privateKey = await derivePrivateKey(password, toBase64(loginSalt));
+  // derive the Ed25519 private key from password + loginSalt (Argon2id)
+  const privateKey = await derivePrivateKey(password, toBase64(loginSalt), recommendedKDF);
 
   // derive the Ed25519 public key from the private key
   const publicKey = await ed.getPublicKeyAsync(privateKey);
 
-  // return a registration payload with all required values encoded in base64
-  return {
+  // the hybrid signature types also register the ML-DSA-65 public key derived from the Ed25519 key
+  const pqKeyPair = isHybridSignature(signatureType) ? derivePQKeyPair(privateKey) : undefined;
+  const pqPublicKey = pqKeyPair ? toBase64(pqKeyPair.publicKey) : undefined;
+
+  // a registration payload with all required values encoded in base64
+  const payload = {
     name,
     email,
     hmac_type: hmacType,
@@ -38,5 +59,16 @@ export async function registerUser(
     encryption_salt: toBase64(encryptionSalt),
     hmac_salt: toBase64(hmacSalt),
     public_key: toBase64(publicKey),
+    kdf: recommendedKDF,
+    signature_type: signatureType,
+    pq_public_key: pqPublicKey,
+    challenge,
   } as RegistrationPayload;
+
+  const message = registrationMessage(challenge, payload);
+  payload.signature = toBase64(await ed.signAsync(message, privateKey));
+  if (pqKeyPair) {
+    payload.pq_signature = toBase64(ml_dsa65.sign(pqKeyPair.secretKey, message));
+  }
+  return payload;
 }
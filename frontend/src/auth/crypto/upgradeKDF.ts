@@ -0,0 +1,38 @@
+import * as ed from '@noble/ed25519';
+import { ml_dsa65 } from '@noble/post-quantum/ml-dsa';
+import { randomBytes } from '@noble/hashes/utils';
+import { fromByteArray as toBase64 } from 'base64-js';
+import { derivePrivateKey, derivePQKeyPair, isHybridSignature } from './keyDerivation';
+import { kdfField, lengthPrefixed } from './payload';
+import type { KDFParams, User } from '@/models/user';
+import type { KDFUpgradePayload } from '@/models/auth';
+
+// builds the request that upgrades the derivation of the login key to stronger parameters.
+//
+// a new login key is derived with a fresh salt and the new parameters, and the change is signed
+// with both keys: the current one proves the password is known, the new one that the key is ours.
+// the payload names the current public key, so the upgrade cannot be replayed once applied.
+// only the login key changes, the encryption and HMAC keys stay the same and no note is re-encrypted.
+// the ML-DSA key of the hybrid accounts is derived from the login key, it is replaced and signs the payload too.
+export async function buildKDFUpgrade(password: string, user: User, kdf: KDFParams): Promise<KDFUpgradePayload> {
+  const currentKey = await derivePrivateKey(password, user.login_salt, user.kdf);
+
+  const loginSalt = toBase64(randomBytes(32));
+  const newKey = await derivePrivateKey(password, loginSalt, kdf);
+  const publicKey = toBase64(await ed.getPublicKeyAsync(newKey));
+  const pqKeyPair = isHybridSignature(user.signature_type) ? derivePQKeyPair(newKey) : undefined;
+  const pqPublicKey = pqKeyPair ? toBase64(pqKeyPair.publicKey) : '';
+
+  // the same bytes as KDFUpgradePayload in the backend
+  const payload = lengthPrefixed('kdf_upgrade', [user.public_key, publicKey, loginSalt, kdfField(kdf), pqPublicKey]);
+
+  return {
+    kdf,
+    login_salt: loginSalt,
+    public_key: publicKey,
+    signature: toBase64(await ed.signAsync(payload, currentKey)),
+    new_signature: toBase64(await ed.signAsync(payload, newKey)),
+    pq_public_key: pqKeyPair ? pqPublicKey : undefined,
+    pq_signature: pqKeyPair ? toBase64(ml_dsa65.sign(pqKeyPair.secretKey, payload)) : undefined,
+  };
+}
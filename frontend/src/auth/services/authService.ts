@@ -1,5 +1,6 @@
-import { requestLoginChallenge, sendLoginSignature, logout as logoutApi } from '@/auth/api/authApi';
+import { requestLoginChallenge, sendLoginSignature, sendKDFUpgrade, logout as logoutApi } from '@/auth/api/authApi';
 import { signLoginChallenge } from '@/auth/crypto/login';
+import { buildKDFUpgrade } from '@/auth/crypto/upgradeKDF';
 import type { User } from '@/models/user';
 import router from '@/router';
 import { userStore } from '@/store/userStore';
@@ -7,8 +8,10 @@ import { userStore } from '@/store/userStore';
 import { fetchNoteTitles } from '@/notes/api/notesApi';
 import { decryptBlockTitle } from '@/notes/crypto/decryptTitle';
 import type { CipherType } from '@/models/block';
-import type {  NoteTitle, EncryptedTitle } from '@/models/title';
+import type { NoteTitle, TitlePage } from '@/models/title';
 import { noteTitleStore } from '@/store/noteTitleStore';
+import { checkTreeHead } from '@/notes/accountLogService';
+import { renderAlert } from '@/store/notifications';
 
 /**
  * performs the full login flow:
@@ -16,6 +19,7 @@ import { noteTitleStore } from '@/store/noteTitleStore';
  * 2. signs the challenge using a key derived from the user's password
  * 3. sends the signed challenge to the server for authentication
  * 4. if successful, stores the user and decrypted note titles in state
+ * 5. upgrades the derivation of the login key when the server asks for stronger parameters
  */
 export async function loginWithPassword(email: string, password: string) {
     // reset any previously stored user or titles
@@ -23,19 +27,28 @@ export async function loginWithPassword(email: string, password: string) {
     noteTitleStore.clearNoteTitles();
 
     // step 1: request challenge and login salt from backend
-    const { challenge, login_salt } = await requestLoginChallenge(email);
+    const { challenge, login_salt, kdf } = await requestLoginChallenge(email);
 
     // step 2: derive private key and sign the challenge
-    const signedPayload = await signLoginChallenge(email, password, challenge, login_salt);
+    const signedPayload = await signLoginChallenge(email, password, challenge, login_salt, kdf);
 
     // step 3: send signed challenge to backend and receive authenticated user data
-    const loginResponse: User = await sendLoginSignature(signedPayload);
+    const { user, kdf_upgrade } = await sendLoginSignature(signedPayload);
 
     // save authenticated user in store
-    userStore.setUser(loginResponse);
+    userStore.setUser(user);
 
-    // step 4: decrypt and store note titles using the password and encryption type
-    fetchAndDecryptTitles(password, loginResponse.encryption_type);
+    // step 4: decrypt and store the first page of note titles using the password and encryption type
+    fetchAndDecryptTitles(password, user.encryption_type);
+
+    // step 5: the current login key keeps working, a failed upgrade is offered again at the next login
+    if (kdf_upgrade) {
+        try {
+            userStore.setUser(await sendKDFUpgrade(await buildKDFUpgrade(password, user, kdf_upgrade)));
+        } catch (error) {
+            console.warn('Failed to upgrade the login key:', error);
+        }
+    }
 
     return;
 }
@@ -72,24 +85,36 @@ export async function getUser() {
     return JSON.parse(user) as User;
 }
 
-// fetches all encrypted note titles from the server
+// fetches one page of encrypted note titles from the server, the first one when no cursor is given
 // decrypts each title using the user's password
-// saves the decrypted titles to the store
-export async function fetchAndDecryptTitles(password: string, encryptionType: CipherType) {
+// saves the decrypted titles and the cursor of the next page to the store
+export async function fetchAndDecryptTitles(password: string, encryptionType: CipherType, cursor?: string) {
   const user = userStore.getUser();
-  const eTitles: EncryptedTitle[] = await fetchNoteTitles();
+  const page: TitlePage = await fetchNoteTitles(cursor);
+
+  // a tree that does not extend the last one seen means notes were dropped or rolled back
+  if (!cursor && !(await checkTreeHead(page.tree_head))) {
+    console.warn('The account log is not consistent with the last verified tree head');
+    renderAlert({ message: 'Some notes may have been removed or rolled back by the server.', type: 'error' });
+  }
   
-  for (const eTitle of eTitles) {
+  const titles: NoteTitle[] = [];
+  for (const eTitle of page.titles) {
     try {
-      const title : NoteTitle = await decryptBlockTitle(
+      titles.push(await decryptBlockTitle(
         eTitle, 
         password, 
-        user.encryption_salt,
-        encryptionType
-      );
-      noteTitleStore.addNoteTitle(title);
+        { ...user, encryption_type: encryptionType }
+      ));
     } catch (err) {
       console.warn('Failed to decrypt title:', err);
     }
   }
+
+  // a page where no title decrypts is kept for another try, most likely the password was wrong
+  if (page.titles.length > 0 && titles.length === 0) {
+    throw new Error('Failed to decrypt the note titles, check your password');
+  }
+  titles.forEach((title) => noteTitleStore.addNoteTitle(title));
+  noteTitleStore.setNextCursor(page.next_cursor);
 }
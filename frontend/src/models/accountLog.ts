@@ -0,0 +1,29 @@
+// root of the account Merkle tree at a given size
+export type TreeHead = {
+  tree_size: number;
+  root_hash: string; // base64
+}
+
+// one entry of the append-only account log
+export type LogLeaf = {
+  leaf_index: number;
+  note_id: string;
+  event: 'head' | 'trash' | 'restore' | 'purge';
+  head_hash: string;
+  leaf_hash: string;
+  created_at: string;
+}
+
+// proof that a leaf is part of the account tree
+export type InclusionProof = {
+  leaf: LogLeaf;
+  tree_head: TreeHead;
+  audit_path: string[];
+}
+
+// proof that a tree head only appended leaves to an older one
+export type ConsistencyProof = {
+  first: TreeHead;
+  second: TreeHead;
+  proof: string[];
+}
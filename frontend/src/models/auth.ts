@@ -1,26 +1,66 @@
+import type { CipherType, SignatureType } from './block';
+import type { KDFParams, User } from './user';
+
 // payload sent during user registration.
 // all salts and the public key are base64-encoded.
 export type RegistrationPayload = {
   name: string;
   email: string;
   hmac_type: 'hmac-sha256' | 'hmac-sha512';
-  encryption_type: 'aes-128-cbc' | 'aes-128-ctr';
+  encryption_type: CipherType;
   login_salt: string;
   encryption_salt: string;
   hmac_salt: string;
   public_key: string;
+  kdf?: KDFParams;
+  signature_type?: SignatureType;
+  pq_public_key?: string; // required by the hybrid signature types
+  challenge: string; // registration challenge, signed along with the other fields
+  signature: string; // signature of the registration with the Ed25519 key
+  pq_signature?: string; // and with the ML-DSA key of the hybrid signature types
+};
+
+// response received from the server when requesting a registration challenge.
+export type RegistrationChallengeResponse = {
+  challenge: string;
+  expires_at: string;
 };
 
 // response received from the server when requesting a login challenge.
 export type ChallengeResponse = {
   challenge: string;
   login_salt: string;
+  kdf?: KDFParams; // missing from the older servers
   expires_at: string;
 };
 
+// response received from the server after a successful login.
+export type LoginResponse = {
+  message: string;
+  user: User;
+  kdf_upgrade?: KDFParams; // stronger parameters the login key should be upgraded to
+};
+
+// payload sent to upgrade the derivation of the login key, signed with the current and the new key.
+export type KDFUpgradePayload = {
+  kdf: KDFParams;
+  login_salt: string;
+  public_key: string;
+  signature: string;
+  new_signature: string;
+  pq_public_key?: string; // hybrid signature types: the ML-DSA key derived from the new login key
+  pq_signature?: string; // and its signature of the payload
+};
+
 // payload sent when responding to a login challenge.
 export type LoginRequestPayload = {
   email: string;
   challenge: string;
   signature: string;
 };
+
+// result of a verification or email change link
+export type ConfirmEmailResponse = {
+  status: 'verified' | 'pending' | 'changed';
+  message: string;
+}
@@ -9,10 +9,32 @@ export type Block = {
   mac: string;
   signature: string;
   timestamp: string;             
+  // the AEAD encryption types carry nonces and tags instead of the IVs and the MAC, which are left empty
+  nonce?: string;
+  nonce_title?: string;
+  tag?: string;
+  tag_title?: string;
+  // the blocks of the hybrid signature types are also signed with ML-DSA-65
+  pq_signature?: string;
+  // the lifecycle blocks record that the note was moved to the trash or out of it, they carry no content
+  kind?: BlockKind;
+  note_id?: string;
+};
+
+// kinds of the lifecycle blocks
+export type BlockKind = 'tombstone' | 'restore';
+
+// the latest version of a note returned by the backend: its latest block of content, then the lifecycle blocks
+// appended since it was written, the last of them is the head of the note
+export type NoteHead = Block & {
+  lifecycle?: Block[];
 };
 
 // supported HMAC hashing algorithms for block integrity
 export type HashType = 'hmac-sha256' | 'hmac-sha512';
 
-// supported AES encryption modes for note encryption
-export type CipherType = 'aes-128-cbc' | 'aes-128-ctr'; 
\ No newline at end of file
+// supported signature schemes of the blocks, the hybrid one requires both signatures to be valid
+export type SignatureType = 'ed25519' | 'ed25519-mldsa65';
+
+// supported encryption modes for note encryption
+export type CipherType = 'aes-128-cbc' | 'aes-128-ctr' | 'aes-256-gcm' | 'xchacha20-poly1305';
\ No newline at end of file
@@ -0,0 +1,29 @@
+// RFC 3161 time-stamp token over the hash of a block, and the result of its verification by the server
+export type TimestampStatus = {
+  seq: number;
+  authority: string; // "local" or the URL of the time-stamp authority
+  gen_time: string; // time written in the token by the authority
+  token: string; // base64 DER encoded token
+  verified: boolean;
+  error?: string;
+  clock_skew: number; // seconds between the timestamp claimed in the block and the time of the token
+}
+
+// one version of a note
+export type BlockHistory = {
+  seq: number;
+  block_hash: string;
+  timestamp: string; // creation time claimed by the client
+  valid_signature: boolean;
+  timestamp_token: TimestampStatus | null; // null if the block was never timestamped
+}
+
+// report of the verification of a whole note
+export type NoteVerification = {
+  note_id: string;
+  chain_valid: boolean;
+  signatures_valid: boolean;
+  timestamps_valid: boolean;
+  blocks: BlockHistory[];
+  problems: string[];
+}
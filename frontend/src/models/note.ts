@@ -2,8 +2,9 @@ import type { Block } from './block';
 
 // represents a single block belonging to a note.
 export type NoteBlock = {
-  note_id: number | null;
+  note_id: string | null;
   block: Block;
+  search_tokens?: string[]; // keyed keyword tokens of this version, replaces the previous ones
 };
 
 // represents a full chain of blocks for a given note.
@@ -14,7 +15,7 @@ export type NoteBlockChain = {
 
 // represents a fully decrypted note, used in the frontend to render UI.
 export type Note = {
-    note_id: number;
+    note_id: string;
     title: string;
     body: string;
     timestamp: string;
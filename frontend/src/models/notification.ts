@@ -0,0 +1,45 @@
+// security events of an account the user is notified of
+export type SecurityEventType =
+  | 'new-login'
+  | 'new-device'
+  | 'device-revoked'
+  | 'key-rotation'
+  | 'email-change'
+  | 'recovery-request'
+  | 'account-deletion'
+  | 'failed-signature-burst';
+
+// settings of the security notifications, the critical events are always emailed
+export interface NotificationPreferences {
+  email_enabled: boolean;
+  webhook_url: string; // empty for no webhook
+  muted_events: SecurityEventType[];
+  webhook_secret?: string; // only sent when a new secret is generated, it signs the webhook requests
+}
+
+// a notification of an event to one channel, retried until it is sent or runs out of attempts
+export interface NotificationDelivery {
+  id: number;
+  event_type: SecurityEventType;
+  channel: 'email' | 'webhook';
+  target: string;
+  status: 'pending' | 'sent' | 'failed';
+  attempts: number;
+  next_attempt_at: string;
+  last_error?: string;
+  created_at: string;
+}
+
+export type NotificationPreferencesResponse = {
+  preferences: NotificationPreferences;
+  event_types: SecurityEventType[];
+  critical_events: SecurityEventType[];
+  deliveries: NotificationDelivery[];
+}
+
+export type UpdateNotificationPreferencesPayload = {
+  email_enabled: boolean;
+  webhook_url: string;
+  muted_events: SecurityEventType[];
+  rotate_webhook_secret?: boolean;
+}
@@ -0,0 +1,16 @@
+// proof, signed by the server, that a block was accepted and stored
+export type Receipt = {
+  note_id: string;
+  seq: number; // position of the block in the note chain, starting at 1
+  block_hash: string;
+  received_at: string;
+  key_id: string; // identifier of the server key that signed the receipt
+  signature: string;
+}
+
+// a public key published by the server
+export type ServerKey = {
+  key_id: string;
+  algorithm: string;
+  public_key: string; // base64
+}
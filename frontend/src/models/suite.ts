@@ -0,0 +1,34 @@
+import type { CipherType, HashType } from './block';
+
+// a crypto suite of the server registry, as published at /crypto/suites.
+// users reference their suite by id, deprecated suites cannot be chosen for a new account.
+export type CryptoSuite = {
+  id: number;
+  name: string;
+  cipher: {
+    name: CipherType;
+    key_size: number;
+    iv_size: number;
+    tag_size?: number; // only for the AEAD ciphers
+    aead: boolean;
+  };
+  mac: {
+    name: HashType;
+    hash: string;
+    key_size: number;
+  };
+  kdf: {
+    algorithm: string;
+    hash: string;
+    iterations: number;
+  };
+  signature: string;
+  hash_version: number;
+  deprecated: boolean;
+};
+
+// the registry of the server, with the suite it picks when the client has no preference
+export type SuitesResponse = {
+  suites: CryptoSuite[];
+  default: number;
+};
@@ -1,14 +1,35 @@
+import type { TreeHead } from './accountLog';
+
 // represents the encrypted form of a note title, as stored in the backend
 export type EncryptedTitle = {
-  note_id: number;
+  note_id: string; // opaque 128 bit id generated by the server
   cipher_title: string;
   timestamp: string;
   iv_title: string;
+  nonce_title?: string; // nonce and tag of the AEAD encryption types, which leave iv_title empty
+  tag_title?: string;
+  head_hash?: string; // hash of the head block, provable against the account tree
+  cipher_meta?: string; // encrypted folder and tags, missing if the note has none
+  iv_meta?: string;
+  created_at?: string;
+}
+
+// a page of encrypted titles, as returned by the titles endpoint
+export type TitlePage = {
+  titles: EncryptedTitle[];
+  next_cursor?: string; // missing on the last page
+  total?: number; // number of matching notes, only sent with the first page
+  tree_head: TreeHead; // root of the account log
 }
 
 // represents the decrypted form of a note title, as shown in the UI
 export type NoteTitle = {
-    note_id: number;
+    note_id: string;
     title: string;
     timestamp: string;
-};
\ No newline at end of file
+};
+// an encrypted title of a note inside the trash
+export type TrashedTitle = EncryptedTitle & {
+  deleted_at: string;
+  purge_at: string; // when the note will be permanently deleted
+}
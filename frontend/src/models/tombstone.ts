@@ -0,0 +1,9 @@
+// signed request moving a note to the trash, or out of it for a restore.
+// the backend appends it to the chain of the note as a lifecycle block
+export type Tombstone = {
+  note_id: string;
+  head_hash: string; // hash of the head block the user deleted or restored
+  timestamp: string;
+  signature: string;
+  pq_signature?: string; // ML-DSA-65 signature of the hybrid signature types
+}
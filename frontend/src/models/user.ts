@@ -1,4 +1,4 @@
-import type { HashType, CipherType } from './block';
+import type { HashType, CipherType, SignatureType } from './block';
 
 // defines the structure of a User object used throughout the application
 export interface User {
@@ -11,4 +11,20 @@ export interface User {
     hmac_type: HashType;
     login_salt: string;
     encryption_type: CipherType;
+    suite_id?: number; // crypto suite of the account, see /crypto/suites
+    kdf?: KDFParams; // derivation of the login key, pbkdf2-sha256 with 100k iterations when missing
+    signature_type?: SignatureType; // signature scheme of the blocks, ed25519 when missing
+    pq_public_key?: string; // ML-DSA-65 public key of the hybrid signature types
+    recovery_public_key?: string; // Ed25519 public key of the offline recovery key, if the user set one up
+    wrapped_keys?: string; // encryption and HMAC keys sealed under the password of a recovered account
+    email_verified?: boolean; // the user confirmed they receive the emails sent to the address
+    pending_email?: string; // new address of an email change waiting for the confirmation of both addresses
+}
+
+// parameters of the derivation of the Ed25519 login key from the password and the login salt
+export type KDFParams = {
+    algorithm: 'pbkdf2-sha256' | 'argon2id';
+    iterations: number; // pbkdf2 iterations, or argon2id passes
+    memory_kib?: number; // argon2id only
+    parallelism?: number; // argon2id only
 }
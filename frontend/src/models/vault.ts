@@ -0,0 +1,13 @@
+// outcome of the import of one note of a vault archive
+export type ImportResult = {
+  note_id: string; // id of the note inside the archive
+  imported_as?: string; // id the note was stored under
+  status: 'imported' | 'renamed' | 'unchanged' | 'skipped' | 'rejected' | 'failed';
+  error?: string;
+}
+
+// outcome of the import of a vault archive
+export type ImportReport = {
+  manifest_verified?: boolean; // always true, archives whose manifest does not verify are rejected
+  notes: ImportResult[];
+}
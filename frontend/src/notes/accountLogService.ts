@@ -0,0 +1,66 @@
+import { fetchConsistencyProof, fetchInclusionProof } from './api/notesApi';
+import { accountLeafHash, verifyConsistency, verifyInclusion } from './crypto/merkle';
+import { userStore } from '@/store/userStore';
+import type { TreeHead } from '@/models/accountLog';
+
+// the last account tree head this browser verified, per user
+function storageKey(): string {
+  return `tree_head_${userStore.getUser().id}`;
+}
+
+function lastSeenTreeHead(): TreeHead | null {
+  const stored = localStorage.getItem(storageKey());
+  return stored ? (JSON.parse(stored) as TreeHead) : null;
+}
+
+/**
+ * Checks that a tree head returned by the server extends the last one this browser saw.
+ * A smaller tree or a failing consistency proof means notes were dropped or rolled back.
+ * @param treeHead - The tree head returned with the titles
+ * @returns Promise<boolean> - true if the account log only grew
+ */
+export async function checkTreeHead(treeHead: TreeHead): Promise<boolean> {
+  const previous = lastSeenTreeHead();
+
+  if (previous && previous.tree_size > 0) {
+    if (treeHead.tree_size < previous.tree_size) return false;
+
+    const proof = await fetchConsistencyProof(previous.tree_size, treeHead.tree_size);
+    const consistent = proof.second.root_hash === treeHead.root_hash && verifyConsistency(
+      previous.tree_size,
+      treeHead.tree_size,
+      previous.root_hash,
+      treeHead.root_hash,
+      proof.proof
+    );
+    if (!consistent) return false;
+  }
+
+  localStorage.setItem(storageKey(), JSON.stringify(treeHead));
+  return true;
+}
+
+/**
+ * Checks that the head of a note is the latest one recorded in the account log.
+ * @param noteId - The ID of the note
+ * @param headHash - The hash of the head block served for the note
+ * @returns Promise<boolean> - true if the head is proven to be part of a consistent account tree
+ */
+export async function checkNoteInclusion(noteId: string, headHash: string): Promise<boolean> {
+  const proof = await fetchInclusionProof(noteId);
+
+  const leafHash = accountLeafHash(proof.leaf.event, noteId, headHash);
+  if (proof.leaf.note_id !== noteId || proof.leaf.head_hash !== headHash || proof.leaf.leaf_hash !== leafHash) {
+    return false;
+  }
+
+  const included = verifyInclusion(
+    leafHash,
+    proof.leaf.leaf_index,
+    proof.tree_head.tree_size,
+    proof.audit_path,
+    proof.tree_head.root_hash
+  );
+
+  return included && checkTreeHead(proof.tree_head);
+}
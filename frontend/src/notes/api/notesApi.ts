@@ -1,15 +1,20 @@
 import api from '@/lib/api';
 import type { NoteBlock } from '@/models/note';
-import type { Block } from '@/models/block';
-import type { EncryptedTitle } from '@/models/title';
+import type { Block, NoteHead } from '@/models/block';
+import type { EncryptedTitle, TitlePage, TrashedTitle } from '@/models/title';
+import type { Tombstone } from '@/models/tombstone';
+import type { Receipt, ServerKey } from '@/models/receipt';
+import type { TreeHead, InclusionProof, ConsistencyProof } from '@/models/accountLog';
+import type { BlockHistory, NoteVerification } from '@/models/history';
+import type { ImportReport } from '@/models/vault';
 
 // creates a new note
 // note: assumes the user is authenticated and token is set as httpOnly cookie
-export async function createNote(noteBlock: Block): Promise<[number, string]> {
+export async function createNote(noteBlock: Block): Promise<[string, string, Receipt | undefined]> {
   try {
     const res = await api.post('/notes/new', noteBlock);
-    // return the note id and timestamp as a tuple
-    return [res.data.note_id, res.data.timestamp];
+    // return the note id, timestamp and server receipt as a tuple
+    return [res.data.note_id, res.data.timestamp, res.data.receipt];
   } catch (error: any) {
     const errorMessage = error.response?.data || error.message || 'Failed to create record';
     throw new Error(errorMessage);
@@ -19,22 +24,23 @@ export async function createNote(noteBlock: Block): Promise<[number, string]> {
 
 // sends a new encrypted record block to the backend
 // note: assumes the user is authenticated and token is set as httpOnly cookie
-export async function editNote(noteBlock: NoteBlock): Promise<string> {
+export async function editNote(noteBlock: NoteBlock): Promise<[string, Receipt | undefined]> {
   try {
     const res = await api.post('/notes/edit', noteBlock);
-    return res.data.timestamp; // Return the timestamp from the response
+    return [res.data.timestamp, res.data.receipt]; // Return the timestamp and server receipt from the response
   } catch (error: any) {
     const errorMessage = error.response?.data || error.message || 'Failed to save record';
     throw new Error(errorMessage);
   }
 }
 
-// fetches the latest encrypted record block for the currently authenticated user
+// fetches the latest encrypted record block for the currently authenticated user,
+// followed by the tombstone and restore blocks appended since it was written
 // note: assumes token is send as httpOnly cookie
-export async function fetchNotes(noteId: number): Promise<Block> {
+export async function fetchNotes(noteId: string): Promise<NoteHead> {
   try {
     const res = await api.post('/notes/get', { note_id: noteId });
-    return res.data as Block;
+    return res.data as NoteHead;
   } catch (error: any) {
     // Extract the error message from the backend response
     const errorMessage = error.response?.data || error.message || 'Failed to fetch note';
@@ -42,25 +48,143 @@ export async function fetchNotes(noteId: number): Promise<Block> {
   }
 }
 
-// fetches all note titles for the currently authenticated user
+// fetches one page of the note titles of the currently authenticated user, most recently modified first
+// the first page is requested without a cursor, the next ones with the next_cursor of the previous page
 // note: assumes token is sent as an httpOnly cookie
-export async function fetchNoteTitles(): Promise<EncryptedTitle[]> {
+export async function fetchNoteTitles(cursor?: string): Promise<TitlePage> {
   try {
-    const res = await api.get('/notes/titles');
-    return res.data as EncryptedTitle[];
+    const res = await api.get('/notes/titles', { params: { cursor } });
+    return res.data as TitlePage;
   } catch (error: any) {
     const errorMessage = error.response?.data || error.message || 'Failed to fetch note titles';
     throw new Error(errorMessage);
   }
 }
 
-// deletes a note by id
+// fetches the proof that the latest head of a note is part of the account log
+// note: assumes token is sent as an httpOnly cookie
+export async function fetchInclusionProof(noteId: string): Promise<InclusionProof> {
+  try {
+    const res = await api.get('/notes/log/inclusion', { params: { note_id: noteId } });
+    return res.data as InclusionProof;
+  } catch (error: any) {
+    const errorMessage = error.response?.data || error.message || 'Failed to fetch inclusion proof';
+    throw new Error(errorMessage);
+  }
+}
+
+// fetches the proof that the account log only grew since an older tree size
+// note: assumes token is sent as an httpOnly cookie
+export async function fetchConsistencyProof(first: number, second: number): Promise<ConsistencyProof> {
+  try {
+    const res = await api.get('/notes/log/consistency', { params: { first, second } });
+    return res.data as ConsistencyProof;
+  } catch (error: any) {
+    const errorMessage = error.response?.data || error.message || 'Failed to fetch consistency proof';
+    throw new Error(errorMessage);
+  }
+}
+
+// moves a note to the trash with a signed tombstone
 // note: assumes the user is authenticated and token is set as httpOnly cookie
-export async function deleteNote(noteId: number): Promise<void> {
+export async function deleteNote(tombstone: Tombstone): Promise<Receipt | undefined> {
   try {
-    await api.delete(`/notes/delete`, { data: { note_id: noteId } });
+    const res = await api.delete(`/notes/delete`, { data: tombstone });
+    return res.data.receipt;
   } catch (error: any) {
     const errorMessage = error.response?.data || error.message || 'Failed to delete note';
     throw new Error(errorMessage);
   }
-}
\ No newline at end of file
+}
+
+// fetches the encrypted titles of the notes inside the trash
+// note: assumes token is sent as an httpOnly cookie
+export async function fetchTrash(): Promise<TrashedTitle[]> {
+  try {
+    const res = await api.get('/notes/trash');
+    return res.data as TrashedTitle[];
+  } catch (error: any) {
+    const errorMessage = error.response?.data || error.message || 'Failed to fetch trash';
+    throw new Error(errorMessage);
+  }
+}
+
+// moves a note out of the trash with a restore signed over its tombstone, see signTombstone
+// note: assumes the user is authenticated and token is set as httpOnly cookie
+export async function restoreNote(restore: Tombstone): Promise<Receipt | undefined> {
+  try {
+    const res = await api.post('/notes/restore', restore);
+    return res.data.receipt;
+  } catch (error: any) {
+    const errorMessage = error.response?.data || error.message || 'Failed to restore note';
+    throw new Error(errorMessage);
+  }
+}
+
+// fetches the public keys the server signs its receipts with
+export async function fetchServerKeys(): Promise<ServerKey[]> {
+  try {
+    const res = await api.get('/.well-known/server-keys');
+    return res.data.keys as ServerKey[];
+  } catch (error: any) {
+    const errorMessage = error.response?.data || error.message || 'Failed to fetch server keys';
+    throw new Error(errorMessage);
+  }
+}
+
+// fetches every version of a note with its signature and time-stamp token status
+// note: assumes token is sent as an httpOnly cookie
+export async function fetchNoteHistory(noteId: string): Promise<BlockHistory[]> {
+  try {
+    const res = await api.post('/notes/history', { note_id: noteId });
+    return res.data as BlockHistory[];
+  } catch (error: any) {
+    const errorMessage = error.response?.data || error.message || 'Failed to fetch note history';
+    throw new Error(errorMessage);
+  }
+}
+
+// asks the server to check the chain, the signatures and the time-stamp tokens of a note
+// note: assumes token is sent as an httpOnly cookie
+export async function verifyNote(noteId: string): Promise<NoteVerification> {
+  try {
+    const res = await api.post('/notes/verify', { note_id: noteId });
+    return res.data as NoteVerification;
+  } catch (error: any) {
+    const errorMessage = error.response?.data || error.message || 'Failed to verify note';
+    throw new Error(errorMessage);
+  }
+}
+
+// downloads the whole vault as a signed tar.gz archive
+// note: assumes token is sent as an httpOnly cookie
+export async function exportVault(): Promise<Blob> {
+  try {
+    const res = await api.get('/notes/export', { responseType: 'blob' });
+    return res.data as Blob;
+  } catch (error: any) {
+    const errorMessage = error.response?.data || error.message || 'Failed to export vault';
+    throw new Error(errorMessage);
+  }
+}
+
+// imports a vault archive, notes whose id is taken are skipped or stored under a new id
+// importProof is only needed for an archive of another account, see signImportProof
+// note: assumes token is sent as an httpOnly cookie
+export async function importVault(
+  archive: Blob,
+  onConflict: 'skip' | 'rename' = 'skip',
+  importProof?: string
+): Promise<ImportReport> {
+  try {
+    const headers: Record<string, string> = { 'Content-Type': 'application/gzip' };
+    if (importProof) {
+      headers['X-Import-Proof'] = importProof;
+    }
+    const res = await api.post('/notes/import', archive, { params: { on_conflict: onConflict }, headers });
+    return res.data as ImportReport;
+  } catch (error: any) {
+    const errorMessage = error.response?.data || error.message || 'Failed to import vault';
+    throw new Error(errorMessage);
+  }
+}
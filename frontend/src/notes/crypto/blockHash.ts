@@ -6,7 +6,9 @@ import { fromByteArray as toBase64 } from 'base64-js';
 //
 // this can be used to uniquely identify a block and ensure integrity.
 export function blockHash(block: Block): string {
-  // create a string representation of the block
+  // create a string representation of the block, in the field order of the backend model.
+  // the AEAD fields, the ML-DSA signature and the lifecycle fields are left undefined when empty so JSON.stringify
+  // omits them like the backend does
   const blockString = JSON.stringify({
     prev_hash: block.prev_hash,
     iv: block.iv,
@@ -15,7 +17,14 @@ export function blockHash(block: Block): string {
     ciphertext: block.ciphertext,
     mac: block.mac,
     signature: block.signature,
-    timestamp: block.timestamp
+    timestamp: block.timestamp,
+    nonce: block.nonce || undefined,
+    nonce_title: block.nonce_title || undefined,
+    tag: block.tag || undefined,
+    tag_title: block.tag_title || undefined,
+    pq_signature: block.pq_signature || undefined,
+    kind: block.kind || undefined,
+    note_id: block.note_id || undefined
   });
 
   // compute the SHA-256 hash of the block string
@@ -2,8 +2,8 @@ import { randomBytes } from '@noble/hashes/utils';
 import { hmac } from '@noble/hashes/hmac';
 import { sha256, sha512 } from '@noble/hashes/sha2';
 import { fromByteArray as toBase64 } from 'base64-js';
-import { aes128cbcEncrypt, aes128ctrEncrypt } from './encryption';
-import { getSessionKeys, derivePrivateKey } from '../../auth/crypto/keyDerivation';
+import { aes128cbcEncrypt, aes128ctrEncrypt, aeadEncrypt, isAEAD, ivLength, titleAAD, bodyAAD } from './encryption';
+import { getSessionKeys, derivePrivateKey, derivePQKeyPair, isHybridSignature } from '../../auth/crypto/keyDerivation';
 import type { Block } from '@/models/block';
 import type { User } from '@/models/user';
 import { signBlock } from './signBlock';
@@ -21,9 +21,39 @@ export async function createBlock(
   // derive encryption and HMAC keys from password and salts
   const { encryptionKey, hmacKey } = await getSessionKeys(password, user);
 
-  // generate fresh random IVs for both the title and body encryption
-  const ivTitleBytes = randomBytes(16);
-  const ivBodyBytes = randomBytes(16);
+  // generate fresh random IVs (or nonces) for both the title and body encryption
+  const ivTitleBytes = randomBytes(ivLength(user.encryption_type));
+  const ivBodyBytes = randomBytes(ivLength(user.encryption_type));
+
+  // prepare metadata
+  const prev = prevHashBase64;
+  const timestamp = new Date().toISOString().replace(/\.\d{3}Z$/, 'Z'); // RFC3339 format
+
+  // derive Ed25519 private key to sign the block
+  const privateKey = await derivePrivateKey(password, user.login_salt, user.kdf);
+  // and the ML-DSA-65 key of the hybrid signature types, derived from it
+  const pqSecretKey = isHybridSignature(user.signature_type) ? derivePQKeyPair(privateKey).secretKey : undefined;
+
+  // the AEAD modes authenticate the ciphertexts with their tags, the block has no IV and no MAC
+  if (isAEAD(user.encryption_type)) {
+    const sealedTitle = await aeadEncrypt(title, encryptionKey, ivTitleBytes, user.encryption_type, titleAAD);
+    const sealedBody = await aeadEncrypt(body, encryptionKey, ivBodyBytes, user.encryption_type, bodyAAD);
+
+    return signBlock({
+      prev_hash: prev,
+      iv: "",
+      iv_title: "",
+      cipher_title: sealedTitle.ciphertext,
+      ciphertext: sealedBody.ciphertext,
+      mac: "",
+      signature: "",
+      timestamp: timestamp,
+      nonce: toBase64(ivBodyBytes),
+      nonce_title: toBase64(ivTitleBytes),
+      tag: sealedBody.tag,
+      tag_title: sealedTitle.tag,
+    }, privateKey, pqSecretKey);
+  }
 
   // encrypt the title using the selected AES mode
   const cipherTitle = user.encryption_type === 'aes-128-cbc'
@@ -35,10 +65,6 @@ export async function createBlock(
     ? await aes128cbcEncrypt(body, encryptionKey, ivBodyBytes)
     : await aes128ctrEncrypt(body, encryptionKey, ivBodyBytes);
 
-  // prepare metadata
-  const prev = prevHashBase64;
-  const timestamp = new Date().toISOString().replace(/\.\d{3}Z$/, 'Z'); // RFC3339 format
-
   // construct input to HMAC: concatenate encrypted title and body
   const encoder = new TextEncoder();
   const macInput = encoder.encode(cipherTitle + ciphertext);
@@ -50,9 +76,6 @@ export async function createBlock(
     macInput
   );
 
-  // derive Ed25519 private key to sign the block
-  const privateKey = await derivePrivateKey(password, user.login_salt);
-
   // build the block (with empty signature for now)
   const block: Block = {
     prev_hash: prev,
@@ -66,5 +89,5 @@ export async function createBlock(
   };
 
   // sign the block and return the finalized version
-  return signBlock(block, privateKey);
+  return signBlock(block, privateKey, pqSecretKey);
 }
@@ -1,5 +1,5 @@
 import { getSessionKeys } from '../../auth/crypto/keyDerivation';
-import { aes128cbcDecrypt, aes128ctrDecrypt } from './encryption';
+import { aes128cbcDecrypt, aes128ctrDecrypt, aeadDecrypt, isAEAD, bodyAAD } from './encryption';
 import { hmac } from '@noble/hashes/hmac';
 import { sha256, sha512 } from '@noble/hashes/sha2';
 import { toByteArray as fromBase64 } from 'base64-js';
@@ -46,6 +46,23 @@ export async function decryptBodyFromBlock(
   // derive encryption and integrity keys from the password
   const { encryptionKey, hmacKey } = await getSessionKeys(password, user);
 
+  // the AEAD modes check the tag while decrypting, a tampered body cannot be decrypted at all
+  if (isAEAD(user.encryption_type)) {
+    try {
+      const body = await aeadDecrypt(
+        block.ciphertext,
+        block.tag ?? '',
+        encryptionKey,
+        fromBase64(block.nonce ?? ''),
+        user.encryption_type,
+        bodyAAD
+      );
+      return { body, isIntegrityValid: true };
+    } catch {
+      return { body: '', isIntegrityValid: false };
+    }
+  }
+
   // validate the block's MAC before decrypting
   const macValid = validateMac(block, hmacKey, user.hmac_type);
 
@@ -1,7 +1,7 @@
-import { aes128cbcDecrypt, aes128ctrDecrypt } from './encryption';
-import { deriveEncryptionKey } from '../../auth/crypto/keyDerivation';
+import { aes128cbcDecrypt, aes128ctrDecrypt, aeadDecrypt, isAEAD, titleAAD } from './encryption';
+import { getEncryptionKey } from '../../auth/crypto/keyDerivation';
 import { toByteArray as fromBase64 } from 'base64-js';
-import type { CipherType } from '@/models/block';
+import type { User } from '@/models/user';
 import type { NoteTitle, EncryptedTitle } from '@/models/title';
 
 
@@ -9,14 +9,30 @@ import type { NoteTitle, EncryptedTitle } from '@/models/title';
 //
 // this function only decrypts the 'cipher_title' field to retrieve the plaintext title.
 // it does not validate the integrity (MAC) — this is expected to be done later when the full note is decrypted.
+// the AEAD modes are the exception: their tag is checked here, and a tampered title throws.
 export async function decryptBlockTitle(
   eTitle: EncryptedTitle,
   password: string,
-  encryptionSalt: string,
-  encryptionType: CipherType
+  user: User
 ): Promise<NoteTitle> {
   // derive encryption key from password + encryption salt
-  const encryptionKey = await deriveEncryptionKey(password, encryptionSalt);
+  const encryptionKey = await getEncryptionKey(password, user);
+  const encryptionType = user.encryption_type;
+
+  if (isAEAD(encryptionType)) {
+    return {
+      note_id: eTitle.note_id,
+      title: await aeadDecrypt(
+        eTitle.cipher_title,
+        eTitle.tag_title ?? '',
+        encryptionKey,
+        fromBase64(eTitle.nonce_title ?? ''),
+        encryptionType,
+        titleAAD
+      ),
+      timestamp: eTitle.timestamp,
+    };
+  }
 
   // decode the IV from base64
   const iv = fromBase64(eTitle.iv_title);
@@ -1,5 +1,7 @@
-import { cbc, ctr } from '@noble/ciphers/aes.js';
+import { cbc, ctr, gcm } from '@noble/ciphers/aes.js';
+import { xchacha20poly1305 } from '@noble/ciphers/chacha.js';
 import { fromByteArray as toBase64, toByteArray as fromBase64 } from 'base64-js';
+import type { CipherType } from '@/models/block';
 
 
 // AES-CBC requires the plaintext to be a multiple of 16 bytes (block size)
@@ -78,3 +80,71 @@ export async function aes128ctrDecrypt(
   const plaintextBytes = cipher.decrypt(ciphertextBytes);
   return new TextDecoder().decode(plaintextBytes);
 }
+
+
+// the AEAD modes (AES-256-GCM, XChaCha20-Poly1305) authenticate the ciphertext themselves:
+//   - the 16-byte tag is split from the ciphertext and stored next to it instead of an HMAC
+//   - the additional data binds each ciphertext to its field, so a title cannot be swapped with a body
+//   - decryption throws when the tag does not match, nothing is returned for a tampered ciphertext
+
+// the additional data of the title and the body, must match the Go client
+export const titleAAD = new TextEncoder().encode('title');
+export const bodyAAD = new TextEncoder().encode('body');
+
+const aeadTagLength = 16;
+
+// tells whether an encryption type is an AEAD, whose blocks carry nonces and tags instead of IVs and a MAC
+export function isAEAD(encryptionType: CipherType): boolean {
+  return encryptionType === 'aes-256-gcm' || encryptionType === 'xchacha20-poly1305';
+}
+
+// length of the IVs of an encryption type, or of its nonces for the AEAD types
+export function ivLength(encryptionType: CipherType): number {
+  switch (encryptionType) {
+    case 'aes-256-gcm':
+      return 12;
+    case 'xchacha20-poly1305':
+      return 24;
+    default:
+      return 16;
+  }
+}
+
+function aeadCipher(encryptionType: CipherType, key: Uint8Array, nonce: Uint8Array, aad: Uint8Array) {
+  return encryptionType === 'xchacha20-poly1305'
+    ? xchacha20poly1305(key, nonce, aad)
+    : gcm(key, nonce, aad);
+}
+
+// AES-256-GCM or XChaCha20-Poly1305 encryption, returns the ciphertext and the tag separately
+export async function aeadEncrypt(
+  text: string,
+  key: Uint8Array,
+  nonce: Uint8Array,
+  encryptionType: CipherType,
+  aad: Uint8Array
+): Promise<{ ciphertext: string; tag: string }> {
+  const sealed = aeadCipher(encryptionType, key, nonce, aad).encrypt(new TextEncoder().encode(text));
+  return {
+    ciphertext: toBase64(sealed.slice(0, sealed.length - aeadTagLength)),
+    tag: toBase64(sealed.slice(sealed.length - aeadTagLength)),
+  };
+}
+
+// AES-256-GCM or XChaCha20-Poly1305 decryption, throws if the tag does not match
+export async function aeadDecrypt(
+  base64Ciphertext: string,
+  base64Tag: string,
+  key: Uint8Array,
+  nonce: Uint8Array,
+  encryptionType: CipherType,
+  aad: Uint8Array
+): Promise<string> {
+  const ciphertextBytes = fromBase64(base64Ciphertext);
+  const tagBytes = fromBase64(base64Tag);
+  const sealed = new Uint8Array(ciphertextBytes.length + tagBytes.length);
+  sealed.set(ciphertextBytes);
+  sealed.set(tagBytes, ciphertextBytes.length);
+  const plaintextBytes = aeadCipher(encryptionType, key, nonce, aad).decrypt(sealed);
+  return new TextDecoder().decode(plaintextBytes);
+}
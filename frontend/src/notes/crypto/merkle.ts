@@ -0,0 +1,106 @@
+import { sha256 } from '@noble/hashes/sha2';
+import { toByteArray, fromByteArray as toBase64 } from 'base64-js';
+
+// verification of the account Merkle tree (RFC 6962), mirrors backend/crypto/merkle.go.
+// leaves and inner nodes are hashed with different prefixes, so a leaf can never be passed off as a node.
+
+function nodeHash(left: Uint8Array, right: Uint8Array): Uint8Array {
+  const buffer = new Uint8Array(1 + left.length + right.length);
+  buffer[0] = 0x01;
+  buffer.set(left, 1);
+  buffer.set(right, 1 + left.length);
+  return sha256(buffer);
+}
+
+function equal(a: Uint8Array, b: Uint8Array): boolean {
+  return a.length === b.length && a.every((byte, i) => byte === b[i]);
+}
+
+// computes the base64 leaf hash of an account log entry: SHA-256(0x00 || "event:note_id:head_hash")
+export function accountLeafHash(event: string, noteId: string, headHash: string): string {
+  const data = new TextEncoder().encode(`${event}:${noteId}:${headHash}`);
+  const buffer = new Uint8Array(1 + data.length);
+  buffer.set(data, 1);
+  return toBase64(sha256(buffer));
+}
+
+// checks that a leaf is part of the tree with the given root (RFC 9162 section 2.1.3.2)
+export function verifyInclusion(
+  leafHashBase64: string,
+  index: number,
+  treeSize: number,
+  auditPath: string[],
+  rootBase64: string
+): boolean {
+  if (index < 0 || index >= treeSize) return false;
+
+  let fn = index;
+  let sn = treeSize - 1;
+  let hash = toByteArray(leafHashBase64);
+  for (const sibling of auditPath.map(toByteArray)) {
+    if (sn === 0) return false;
+    if (fn % 2 === 1 || fn === sn) {
+      hash = nodeHash(sibling, hash);
+      while (fn % 2 === 0 && fn !== 0) {
+        fn = Math.floor(fn / 2);
+        sn = Math.floor(sn / 2);
+      }
+    } else {
+      hash = nodeHash(hash, sibling);
+    }
+    fn = Math.floor(fn / 2);
+    sn = Math.floor(sn / 2);
+  }
+
+  return sn === 0 && equal(hash, toByteArray(rootBase64));
+}
+
+// checks that the newer tree only appended leaves to the older one (RFC 9162 section 2.1.4.2)
+export function verifyConsistency(
+  oldSize: number,
+  newSize: number,
+  oldRootBase64: string,
+  newRootBase64: string,
+  proofBase64: string[]
+): boolean {
+  const oldRoot = toByteArray(oldRootBase64);
+  const newRoot = toByteArray(newRootBase64);
+  let proof = proofBase64.map(toByteArray);
+
+  if (oldSize < 0 || oldSize > newSize) return false;
+  if (oldSize === newSize) return proof.length === 0 && equal(oldRoot, newRoot);
+  if (oldSize === 0) return proof.length === 0; // every tree extends the empty tree
+  if (proof.length === 0) return false;
+
+  // when the older tree is a complete subtree, its root is the first node of the path
+  if ((oldSize & (oldSize - 1)) === 0) {
+    proof = [oldRoot, ...proof];
+  }
+
+  let fn = oldSize - 1;
+  let sn = newSize - 1;
+  while (fn % 2 === 1) {
+    fn = Math.floor(fn / 2);
+    sn = Math.floor(sn / 2);
+  }
+
+  let fr = proof[0];
+  let sr = proof[0];
+  for (const node of proof.slice(1)) {
+    if (sn === 0) return false;
+    if (fn % 2 === 1 || fn === sn) {
+      fr = nodeHash(node, fr);
+      sr = nodeHash(node, sr);
+      while (fn % 2 === 0 && fn !== 0) {
+        fn = Math.floor(fn / 2);
+        sn = Math.floor(sn / 2);
+      }
+    } else {
+      sr = nodeHash(sr, node);
+    }
+    fn = Math.floor(fn / 2);
+    sn = Math.floor(sn / 2);
+  }
+
+  return sn === 0 && equal(fr, oldRoot) && equal(sr, newRoot);
+}
@@ -1,5 +1,6 @@
 import type { Block } from '@/models/block';
 import * as ed from '@noble/ed25519';
+import { ml_dsa65 } from '@noble/post-quantum/ml-dsa';
 import { fromByteArray as toBase64 } from 'base64-js';
 
 // signs a record block using Ed25519 and returns the signed block.
@@ -10,18 +11,38 @@ import { fromByteArray as toBase64 } from 'base64-js';
 // it also guarantees non-repudiation: only the user with the correct password
 // (from which the private key is derived) can produce a valid signature,
 // making it cryptographically infeasible to deny authorship of the block.
-export async function signBlock(block: Block, privateKey: Uint8Array): Promise<Block> {
+//
+// the blocks of the hybrid signature types are also signed with the ML-DSA-65 secret key. both signatures
+// cover the payload behind a "hybrid" prefix, so the Ed25519 signature alone never verifies a hybrid block.
+export async function signBlock(block: Block, privateKey: Uint8Array, pqSecretKey?: Uint8Array): Promise<Block> {
   const encoder = new TextEncoder();
 
-  // prepare the data to sign by concatenating critical fields
+  // prepare the data to sign by concatenating critical fields.
+  // the AEAD blocks sign their nonces and tags behind an "aead" prefix, so a block cannot be read as the other kind.
+  // the lifecycle blocks sign their kind, the note ID and the head they follow, the kind prefix keeps them from
+  // ever being valid for a block of content
+  const isAEADBlock = !!(block.nonce || block.nonce_title || block.tag || block.tag_title);
   const dataToSign = encoder.encode(
-    block.prev_hash +
-    block.iv +
-    block.iv_title +
-    block.cipher_title +
-    block.ciphertext +
-    block.mac +
-    block.timestamp
+    (pqSecretKey ? 'hybrid' : '') +
+    (block.kind
+      ? block.kind + block.note_id + block.prev_hash + block.timestamp
+      : isAEADBlock
+      ? 'aead' +
+        block.prev_hash +
+        block.nonce +
+        block.nonce_title +
+        block.cipher_title +
+        block.ciphertext +
+        block.tag +
+        block.tag_title +
+        block.timestamp
+      : block.prev_hash +
+        block.iv +
+        block.iv_title +
+        block.cipher_title +
+        block.ciphertext +
+        block.mac +
+        block.timestamp)
   );
 
   // generate Ed25519 signature using the user's private key
@@ -29,6 +50,9 @@ export async function signBlock(block: Block, privateKey: Uint8Array): Promise<B
 
   // store the base64-encoded signature in the block
   block.signature = toBase64(signature);
+  if (pqSecretKey) {
+    block.pq_signature = toBase64(ml_dsa65.sign(pqSecretKey, dataToSign));
+  }
 
   return block;
 }
\ No newline at end of file
@@ -0,0 +1,23 @@
+import * as ed from '@noble/ed25519';
+import { fromByteArray as toBase64 } from 'base64-js';
+import { derivePrivateKey } from '../../auth/crypto/keyDerivation';
+import type { KDFParams } from '@/models/user';
+
+// signs the proof that the owner of an archived vault allows the current account to import it.
+//
+// the key of the archived account is derived from the password, the login salt and the kdf found in the
+// manifest of the archive, and signs the public key of the current account.
+// the "vault_import" prefix keeps the signature from ever being valid for a block or a tombstone.
+export async function signImportProof(
+  password: string,
+  archiveLoginSalt: string,
+  currentPublicKey: string,
+  archiveKDF?: KDFParams
+): Promise<string> {
+  const dataToSign = new TextEncoder().encode('vault_import' + currentPublicKey);
+
+  const privateKey = await derivePrivateKey(password, archiveLoginSalt, archiveKDF);
+  const signature = await ed.signAsync(dataToSign, privateKey);
+
+  return toBase64(signature);
+}
@@ -0,0 +1,57 @@
+import { derivePrivateKey, derivePQKeyPair, isHybridSignature } from '../../auth/crypto/keyDerivation';
+import type { User } from '@/models/user';
+import type { Block, BlockKind } from '@/models/block';
+import type { Tombstone } from '@/models/tombstone';
+import { signBlock } from './signBlock';
+
+// builds the lifecycle block the backend appends to the chain of the note for a tombstone or a restore.
+// it carries no content, only the kind, the note ID, the head it follows, the timestamp and the signatures.
+export function lifecycleBlock(tombstone: Tombstone, kind: BlockKind): Block {
+  return {
+    prev_hash: tombstone.head_hash,
+    iv: '',
+    iv_title: '',
+    cipher_title: '',
+    ciphertext: '',
+    mac: '',
+    signature: tombstone.signature,
+    timestamp: tombstone.timestamp,
+    pq_signature: tombstone.pq_signature,
+    kind: kind,
+    note_id: tombstone.note_id,
+  };
+}
+
+// creates a tombstone, or a restore, signed with the user's Ed25519 key and the ML-DSA-65 key of the hybrid
+// signature types.
+//
+// the signature covers the hash of the head block, so the server can prove that the user asked for this exact
+// version of the note to be moved to the trash, or out of it. it is signed like the lifecycle block the backend
+// appends to the chain of the note, the kind prefix keeps the signature from ever being valid for a block of content.
+export async function signTombstone(
+  noteId: string,
+  headHash: string,
+  password: string,
+  user: User,
+  kind: BlockKind = 'tombstone'
+): Promise<Tombstone> {
+  const timestamp = new Date().toISOString().replace(/\.\d{3}Z$/, 'Z'); // RFC3339 format
+
+  // derive Ed25519 private key to sign the tombstone, and the ML-DSA-65 key of the hybrid signature types
+  const privateKey = await derivePrivateKey(password, user.login_salt, user.kdf);
+  const pqSecretKey = isHybridSignature(user.signature_type) ? derivePQKeyPair(privateKey).secretKey : undefined;
+
+  const tombstone: Tombstone = {
+    note_id: noteId,
+    head_hash: headHash,
+    timestamp: timestamp,
+    signature: '',
+  };
+  const signed = await signBlock(lifecycleBlock(tombstone, kind), privateKey, pqSecretKey);
+
+  return {
+    ...tombstone,
+    signature: signed.signature,
+    pq_signature: signed.pq_signature,
+  };
+}
@@ -0,0 +1,210 @@
+import { describe, expect, it } from 'vitest';
+import { readFileSync } from 'node:fs';
+import * as ed from '@noble/ed25519';
+import { ml_dsa65 } from '@noble/post-quantum/ml-dsa';
+import { hmac } from '@noble/hashes/hmac';
+import { sha256, sha512 } from '@noble/hashes/sha2';
+import { fromByteArray as toBase64, toByteArray as fromBase64 } from 'base64-js';
+import { derivePrivateKey, derivePQKeyPair, deriveEncryptionKey, deriveHMACKey } from '../../auth/crypto/keyDerivation';
+import { aes128cbcEncrypt, aes128ctrEncrypt, aeadEncrypt, isAEAD, titleAAD, bodyAAD } from './encryption';
+import { decryptBodyFromBlock } from './decryptBody';
+import { decryptBlockTitle } from './decryptTitle';
+import { signBlock } from './signBlock';
+import { blockHash } from './blockHash';
+import { lifecycleBlock } from './signTombstone';
+import type { Block, CipherType, HashType, SignatureType } from '@/models/block';
+import type { KDFParams, User } from '@/models/user';
+import type { Tombstone } from '@/models/tombstone';
+
+// the cross-language test vectors, written by `go run ./cmd/testvectors` in the backend.
+// if a test fails, the frontend no longer agrees with the server byte for byte and every chain breaks.
+type VectorVersion = {
+  title: string;
+  body: string;
+  block: Block;
+  signature_payload: string;
+  block_json: string;
+  block_hash: string;
+};
+
+type VectorUser = {
+  name: string;
+  password: string;
+  login_salt: string;
+  encryption_salt: string;
+  hmac_salt: string;
+  hmac_type: HashType;
+  encryption_type: CipherType;
+  suite_id: number;
+  kdf: KDFParams;
+  signing_seed: string;
+  public_key: string;
+  encryption_key: string;
+  hmac_key: string;
+  signature_type: SignatureType;
+  pq_seed?: string;
+  pq_public_key?: string;
+  notes: {
+    note_id: string;
+    versions: VectorVersion[];
+    tombstone: Tombstone;
+    tombstone_payload: string;
+    tombstone_block_json: string;
+    tombstone_hash: string;
+  }[];
+};
+
+const vectors: { initial_hash: string; users: VectorUser[] } = JSON.parse(
+  readFileSync(new URL('../../../../testvectors/crypto.json', import.meta.url), 'utf-8')
+);
+
+// every key is derived with 100k PBKDF2 iterations
+const timeout = 60_000;
+
+function asUser(vector: VectorUser): User {
+  return {
+    id: 0,
+    name: vector.name,
+    email: `${vector.name}@example.com`,
+    public_key: vector.public_key,
+    encryption_salt: vector.encryption_salt,
+    hmac_salt: vector.hmac_salt,
+    hmac_type: vector.hmac_type,
+    login_salt: vector.login_salt,
+    encryption_type: vector.encryption_type,
+    suite_id: vector.suite_id,
+    kdf: vector.kdf,
+    signature_type: vector.signature_type,
+    pq_public_key: vector.pq_public_key,
+  };
+}
+
+describe.each(vectors.users)('test vectors of $name', (vector) => {
+  it('derives the same keys', async () => {
+    const seed = await derivePrivateKey(vector.password, vector.login_salt, vector.kdf);
+    expect(toBase64(seed)).toBe(vector.signing_seed);
+    expect(toBase64(await ed.getPublicKeyAsync(seed))).toBe(vector.public_key);
+
+    const encryptionKey = await deriveEncryptionKey(vector.password, vector.encryption_salt, vector.encryption_type);
+    expect(toBase64(encryptionKey)).toBe(vector.encryption_key);
+
+    const hmacKey = await deriveHMACKey(vector.password, vector.hmac_salt, vector.hmac_type);
+    expect(toBase64(hmacKey)).toBe(vector.hmac_key);
+
+    if (vector.pq_public_key) {
+      expect(toBase64(derivePQKeyPair(seed).publicKey)).toBe(vector.pq_public_key);
+    }
+  }, timeout);
+
+  it('encrypts, authenticates, signs and hashes the same blocks', async () => {
+    const seed = fromBase64(vector.signing_seed);
+    const encryptionKey = fromBase64(vector.encryption_key);
+    const hmacKey = fromBase64(vector.hmac_key);
+    const encrypt = vector.encryption_type === 'aes-128-cbc' ? aes128cbcEncrypt : aes128ctrEncrypt;
+
+    for (const note of vector.notes) {
+      let prevHash = vectors.initial_hash;
+      for (const version of note.versions) {
+        const expected = version.block;
+        expect(expected.prev_hash).toBe(prevHash);
+
+        if (isAEAD(vector.encryption_type)) {
+          // the tags replace the IVs and the MAC
+          expect(expected.iv + expected.iv_title + expected.mac).toBe('');
+          const sealedTitle = await aeadEncrypt(
+            version.title, encryptionKey, fromBase64(expected.nonce_title!), vector.encryption_type, titleAAD
+          );
+          const sealedBody = await aeadEncrypt(
+            version.body, encryptionKey, fromBase64(expected.nonce!), vector.encryption_type, bodyAAD
+          );
+          expect(sealedTitle).toEqual({ ciphertext: expected.cipher_title, tag: expected.tag_title });
+          expect(sealedBody).toEqual({ ciphertext: expected.ciphertext, tag: expected.tag });
+        } else {
+          const cipherTitle = await encrypt(version.title, encryptionKey, fromBase64(expected.iv_title));
+          const ciphertext = await encrypt(version.body, encryptionKey, fromBase64(expected.iv));
+          expect(cipherTitle).toBe(expected.cipher_title);
+          expect(ciphertext).toBe(expected.ciphertext);
+
+          const mac = hmac(
+            vector.hmac_type === 'hmac-sha512' ? sha512 : sha256,
+            hmacKey,
+            new TextEncoder().encode(cipherTitle + ciphertext)
+          );
+          expect(toBase64(mac)).toBe(expected.mac);
+        }
+
+        // the Ed25519 signature is deterministic, the ML-DSA one is randomized so both are verified instead
+        const pqSecretKey = vector.pq_public_key ? derivePQKeyPair(seed).secretKey : undefined;
+        const signed = await signBlock({ ...expected, signature: '', pq_signature: undefined }, seed, pqSecretKey);
+        expect(signed.signature).toBe(expected.signature);
+        if (vector.pq_public_key) {
+          const payload = new TextEncoder().encode(version.signature_payload);
+          const publicKey = fromBase64(vector.pq_public_key);
+          expect(ml_dsa65.verify(publicKey, payload, fromBase64(expected.pq_signature!))).toBe(true);
+          expect(ml_dsa65.verify(publicKey, payload, fromBase64(signed.pq_signature!))).toBe(true);
+        } else {
+          expect(signed).toEqual(expected);
+        }
+
+        expect(JSON.stringify(expected)).toBe(version.block_json);
+        expect(blockHash(expected)).toBe(version.block_hash);
+        prevHash = version.block_hash;
+      }
+
+      // the tombstone is signed over the head of the chain, like the lifecycle block the backend appends to it
+      expect(note.tombstone.head_hash).toBe(prevHash);
+      expect(note.tombstone_payload).toBe(
+        (vector.pq_public_key ? 'hybrid' : '') + 'tombstone' + note.note_id + prevHash + note.tombstone.timestamp
+      );
+      const valid = await ed.verifyAsync(
+        fromBase64(note.tombstone.signature),
+        new TextEncoder().encode(note.tombstone_payload),
+        fromBase64(vector.public_key)
+      );
+      expect(valid).toBe(true);
+
+      const tombstoneBlock = lifecycleBlock(note.tombstone, 'tombstone');
+      expect(JSON.stringify(tombstoneBlock)).toBe(note.tombstone_block_json);
+      expect(blockHash(tombstoneBlock)).toBe(note.tombstone_hash);
+
+      const pqSecretKey = vector.pq_public_key ? derivePQKeyPair(seed).secretKey : undefined;
+      const signedTombstone = await signBlock({ ...tombstoneBlock, signature: '', pq_signature: undefined }, seed, pqSecretKey);
+      expect(signedTombstone.signature).toBe(note.tombstone.signature);
+    }
+  }, timeout);
+
+  it('decrypts the blocks written by the Go clients', async () => {
+    const user = asUser(vector);
+    for (const note of vector.notes) {
+      const head = note.versions[note.versions.length - 1];
+
+      const title = await decryptBlockTitle(
+        {
+          note_id: note.note_id,
+          cipher_title: head.block.cipher_title,
+          iv_title: head.block.iv_title,
+          nonce_title: head.block.nonce_title,
+          tag_title: head.block.tag_title,
+          timestamp: head.block.timestamp,
+        },
+        vector.password,
+        vector.encryption_salt,
+        vector.encryption_type
+      );
+      expect(title.title).toBe(head.title);
+
+      const { body, isIntegrityValid } = await decryptBodyFromBlock(head.block, vector.password, user);
+      expect(isIntegrityValid).toBe(true);
+      expect(body).toBe(head.body);
+    }
+  }, timeout);
+
+  it('rejects a tampered block', async () => {
+    const user = asUser(vector);
+    const head = vector.notes[0].versions[vector.notes[0].versions.length - 1];
+    const tampered = { ...head.block, ciphertext: head.block.cipher_title, tag: head.block.tag_title };
+
+    const { isIntegrityValid } = await decryptBodyFromBlock(tampered, vector.password, user);
+    expect(isIntegrityValid).toBe(false);
+  }, timeout);
+});
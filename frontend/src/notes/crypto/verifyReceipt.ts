@@ -0,0 +1,31 @@
+import * as ed from '@noble/ed25519';
+import { sha256 } from '@noble/hashes/sha2';
+import { bytesToHex } from '@noble/hashes/utils';
+import { toByteArray } from 'base64-js';
+import type { Receipt } from '@/models/receipt';
+
+// computes the identifier of a server key: the first 8 bytes of its SHA-256 hash, hex encoded
+export function keyId(publicKey: Uint8Array): string {
+  return bytesToHex(sha256(publicKey).slice(0, 8));
+}
+
+// verifies the server signature of a receipt, mirrors crypto.VerifyReceipt on the backend.
+//
+// a valid receipt proves the server accepted this exact version of the note,
+// so it can be shown later if the server denies or drops the block.
+export async function verifyReceipt(receipt: Receipt, publicKeyBase64: string): Promise<boolean> {
+  const publicKey = toByteArray(publicKeyBase64);
+  if (receipt.key_id !== keyId(publicKey)) return false;
+
+  const encoder = new TextEncoder();
+  const signedData = encoder.encode(
+    'receipt' +
+    receipt.note_id +
+    receipt.seq +
+    receipt.block_hash +
+    receipt.received_at +
+    receipt.key_id
+  );
+
+  return ed.verifyAsync(toByteArray(receipt.signature), signedData, publicKey);
+}
@@ -1,11 +1,10 @@
 import { fetchNotes } from './api/notesApi';
 import { decryptBodyFromBlock } from './crypto/decryptBody';
 import { userStore } from '@/store/userStore';
-import { aes128cbcDecrypt, aes128ctrDecrypt } from './crypto/encryption';
-import { deriveEncryptionKey } from '../auth/crypto/keyDerivation';
-import { toByteArray } from 'base64-js';
+import { decryptBlockTitle } from './crypto/decryptTitle';
 import type { Note } from '@/models/note';
 import { blockHash } from './crypto/blockHash';
+import { checkNoteInclusion } from './accountLogService';
 
 /**
  * Fetches and decrypts a complete note using the user's encryption settings
@@ -13,21 +12,28 @@ import { blockHash } from './crypto/blockHash';
  * @param noteId - The ID of the note to fetch and decrypt
  * @returns Promise<Note> - The decrypted note with title and body
  */
-export async function fetchAndDecryptNote(password: string, noteId: number): Promise<Note> {
+export async function fetchAndDecryptNote(password: string, noteId: string): Promise<Note> {
   try {
     // Get user encryption settings
     const user = userStore.getUser();
 
-    // Fetch the encrypted note block (now returns single block)
-    const block = await fetchNotes(noteId);
+    // Fetch the encrypted note block, followed by the tombstones and restores appended since it was written
+    const head = await fetchNotes(noteId);
+    const { lifecycle = [], ...block } = head;
 
     // Decrypt the title from the block
-    const encryptionKey = await deriveEncryptionKey(password, user.encryption_salt);
-    const titleIv = toByteArray(block.iv_title);
-
-    const decryptedTitle = user.encryption_type === 'aes-128-cbc'
-      ? await aes128cbcDecrypt(block.cipher_title, encryptionKey, titleIv)
-      : await aes128ctrDecrypt(block.cipher_title, encryptionKey, titleIv);
+    const { title: decryptedTitle } = await decryptBlockTitle(
+      {
+        note_id: noteId,
+        cipher_title: block.cipher_title,
+        iv_title: block.iv_title,
+        nonce_title: block.nonce_title,
+        tag_title: block.tag_title,
+        timestamp: block.timestamp,
+      },
+      password,
+      user
+    );
 
     // Decrypt the body from the block and get integrity status
     const { body: decryptedBody, isIntegrityValid } = await decryptBodyFromBlock(
@@ -36,6 +42,21 @@ export async function fetchAndDecryptNote(password: string, noteId: number): Pro
       user,
     );
 
+    // The tombstones and restores must extend the block, the last of them is the head of the note
+    let hash = blockHash(block);
+    for (const lifecycleBlock of lifecycle) {
+      if (lifecycleBlock.prev_hash !== hash || lifecycleBlock.note_id !== noteId) {
+        throw new Error('the lifecycle blocks do not extend the note');
+      }
+      hash = blockHash(lifecycleBlock);
+    }
+
+    // The head must be the latest one recorded in the account log, otherwise the server served an older version
+    const isHeadIncluded = await checkNoteInclusion(noteId, hash).catch(() => false);
+    if (!isHeadIncluded) {
+      console.warn(`The head of note ${noteId} is not proven by the account log`);
+    }
+
     // Get timestamp from the block
     const timestamp = block.timestamp || new Date().toISOString();
     
@@ -45,8 +66,8 @@ export async function fetchAndDecryptNote(password: string, noteId: number): Pro
       title: decryptedTitle.trim() || 'Untitled Note',
       body: decryptedBody,
       timestamp: timestamp,
-      hash: blockHash(block),
-      isIntegrityValid,
+      hash: hash,
+      isIntegrityValid: isIntegrityValid && isHeadIncluded,
     };
 
     return decryptedNote;
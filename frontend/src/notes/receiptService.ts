@@ -0,0 +1,23 @@
+import { fetchServerKeys } from './api/notesApi';
+import { verifyReceipt } from './crypto/verifyReceipt';
+import { receiptStore } from '@/store/receiptStore';
+import type { Receipt, ServerKey } from '@/models/receipt';
+
+let serverKeys: ServerKey[] | null = null;
+
+/**
+ * Verifies a receipt returned by the server and keeps it as proof that the block was stored
+ * @param receipt - The receipt returned when the block was stored
+ * @param blockHash - The hash of the block the client sent
+ * @returns Promise<boolean> - true if the receipt is signed by a server key and covers the block
+ */
+export async function keepReceipt(receipt: Receipt | undefined, blockHash: string): Promise<boolean> {
+  if (!receipt || receipt.block_hash !== blockHash) return false;
+
+  serverKeys ??= await fetchServerKeys();
+  const key = serverKeys.find((serverKey) => serverKey.key_id === receipt.key_id);
+  if (!key || !(await verifyReceipt(receipt, key.public_key))) return false;
+
+  receiptStore.addReceipt(receipt);
+  return true;
+}
@@ -3,6 +3,7 @@ import Login from '@/components/Login.vue'
 import Register from '@/components/Register.vue'
 import Home from '@/pages/NoteForm.vue'
 import Account from '@/pages/Account.vue'
+import ConfirmEmail from '@/pages/ConfirmEmail.vue'
 
 
 const routes = [
@@ -11,6 +12,7 @@ const routes = [
   { path: '/register', name: 'Register', component: Register },
   { path: '/home', name: 'Home', component: Home },
   { path: '/account', name: 'Account', component: Account },
+  { path: '/confirm-email', name: 'ConfirmEmail', component: ConfirmEmail },
 ]
 
 const router = createRouter({
@@ -21,7 +23,9 @@ const router = createRouter({
 // Navigation guard to check authentication
 router.beforeEach((to, _, next) => {
   const publicPages = ['/login', '/register'];
-  const authRequired = !publicPages.includes(to.path);
+  // the links of the emails work logged in or not
+  const openPages = ['/confirm-email'];
+  const authRequired = !publicPages.includes(to.path) && !openPages.includes(to.path);
   
   try {
     const user = localStorage.getItem('user');
@@ -6,9 +6,15 @@ export interface UpdateUserRequest {
   email?: string;
 }
 
+export interface UpdateUserResponse {
+  message: string;
+  user: User;
+}
+
 export const userService = {
-  async updateUser(data: UpdateUserRequest): Promise<User> {
+  // a new email is not applied right away, it is returned as pending_email until both addresses confirm it
+  async updateUser(data: UpdateUserRequest): Promise<UpdateUserResponse> {
     const response = await api.put('/auth/update', data);
-    return response.data.user;
+    return response.data;
   },
 }; 
\ No newline at end of file
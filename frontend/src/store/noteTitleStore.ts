@@ -3,6 +3,7 @@ import { reactive } from 'vue';
 
 const state = reactive({
   noteTitles: [] as NoteTitle[],
+  nextCursor: localStorage.getItem('noteTitlesCursor') ?? '', // cursor of the next page of titles, empty once all are loaded
 });
 
 export const noteTitleStore = {
@@ -40,7 +41,7 @@ export const noteTitleStore = {
     return [];
   },
 
-  clearNoteTitleById(noteId: number) {
+  clearNoteTitleById(noteId: string) {
     const noteTitlesData = localStorage.getItem('noteTitles');
     if (noteTitlesData) {
       try {
@@ -53,8 +54,23 @@ export const noteTitleStore = {
     }
   },
 
+  getNextCursor(): string {
+    return state.nextCursor;
+  },
+
+  setNextCursor(cursor: string | undefined) {
+    state.nextCursor = cursor ?? '';
+    if (state.nextCursor) {
+      localStorage.setItem('noteTitlesCursor', state.nextCursor);
+    } else {
+      localStorage.removeItem('noteTitlesCursor');
+    }
+  },
+
   clearNoteTitles() {
     localStorage.removeItem('noteTitles');
+    localStorage.removeItem('noteTitlesCursor');
     state.noteTitles = [];
+    state.nextCursor = '';
   },
 };
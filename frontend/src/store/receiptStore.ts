@@ -0,0 +1,31 @@
+import type { Receipt } from '@/models/receipt';
+
+// receipts are kept in localStorage, they are the user's proof that the server stored each version
+const storageKey = 'receipts';
+
+function load(): Receipt[] {
+  const receiptsData = localStorage.getItem(storageKey);
+  if (!receiptsData) return [];
+  try {
+    return JSON.parse(receiptsData) as Receipt[];
+  } catch (error) {
+    console.error('Failed to parse receipts from localStorage:', error);
+    return [];
+  }
+}
+
+export const receiptStore = {
+  addReceipt(receipt: Receipt) {
+    const receipts = load();
+    receipts.push(receipt);
+    localStorage.setItem(storageKey, JSON.stringify(receipts));
+  },
+
+  getReceipts(noteId: string): Receipt[] {
+    return load().filter((receipt) => receipt.note_id === noteId);
+  },
+
+  clearReceipts() {
+    localStorage.removeItem(storageKey);
+  },
+};